@@ -6,6 +6,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -55,7 +56,15 @@ func main() {
 		return nil
 	})
 
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	_, err := app.Parse(os.Args[1:])
+
+	// Some commands complete successfully but need to exit with a specific exit code.
+	var exitCodeErr *commands.ExitCodeError
+	if errors.As(err, &exitCodeErr) {
+		pushGateway.Stop()
+		os.Exit(exitCodeErr.Code)
+	}
+	kingpin.MustParse("", err)
 
 	pushGateway.Stop()
 }
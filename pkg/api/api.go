@@ -279,7 +279,7 @@ func (a *API) RegisterIngester(i Ingester, pushConfig distributor.Config) {
 }
 
 // RegisterRuler registers routes associated with the Ruler service.
-func (a *API) RegisterRuler(r *ruler.Ruler) {
+func (a *API) RegisterRuler(r *ruler.Ruler, configAPIEnabled bool) {
 	a.indexPage.AddLinks(defaultWeight, "Ruler", []IndexPageLink{
 		{Desc: "Ring status", Path: "/ruler/ring"},
 	})
@@ -291,6 +291,26 @@ func (a *API) RegisterRuler(r *ruler.Ruler) {
 	// List all user rule groups
 	a.RegisterRoute("/ruler/rule_groups", http.HandlerFunc(r.ListAllRules), false, true, "GET")
 
+	// List the tenant's rules whose evaluation is failing, across all rulers.
+	a.RegisterRoute("/ruler/failing_rules", http.HandlerFunc(r.ListFailingRules), true, true, "GET")
+
+	// List the tenant's rule groups taking the longest to evaluate, across all rulers.
+	a.RegisterRoute("/ruler/slowest_rule_groups", http.HandlerFunc(r.ListSlowestRuleGroups), true, true, "GET")
+
+	// Administrative API, lists the tenant's stored rules whose expression can't be parsed by this ruler.
+	a.RegisterRoute("/ruler/validate_rule_expressions", http.HandlerFunc(r.ValidateRuleExpressions), true, true, "GET")
+
+	// Administrative API, triggers a sync of the rules run by this ruler.
+	a.RegisterRoute("/ruler/sync", http.HandlerFunc(r.SyncRules), false, true, "POST")
+
+	// Reports whether the initial rules sync has completed, eg. to be used as readiness check.
+	a.RegisterRoute("/ruler/ready", http.HandlerFunc(r.Ready), false, true, "GET")
+
+	if configAPIEnabled {
+		// Evaluates an alerting rule of the tenant once, without storing it or sending notifications.
+		a.RegisterRoute("/ruler/preview_alert_rule", http.HandlerFunc(r.PreviewAlertRule), true, true, "GET", "POST")
+	}
+
 	ruler.RegisterRulerServer(a.server.GRPC, r)
 }
 
@@ -219,6 +219,12 @@ func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, quer
 		"fetched_chunks_count", numChunks,
 		"sharded_queries", stats.LoadShardedQueries(),
 		"split_queries", stats.LoadSplitQueries(),
+		"queried_blocks", stats.LoadQueriedBlocks(),
+		"store_gateway_refetches", stats.LoadStoreGatewayRefetches(),
+		"touched_block_series", stats.LoadTouchedBlockSeries(),
+		"store_gateway_shard_instances", stats.LoadStoreGatewayShardInstances(),
+		"fetched_chunk_bytes_from_cache", stats.LoadFetchedChunkBytesFromCache(),
+		"fetched_chunk_bytes_from_storage", stats.LoadFetchedChunkBytesFromStorage(),
 	}, formatQueryString(queryString)...)
 
 	level.Info(util_log.WithContext(r.Context(), f.log)).Log(logMessage...)
@@ -579,7 +579,15 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 		if err != nil {
 			return nil, err
 		}
-		remoteQuerier := ruler.NewRemoteQuerier(queryFrontendClient, t.Cfg.Querier.EngineConfig.Timeout, t.Cfg.API.PrometheusHTTPPrefix, util_log.Logger, ruler.WithOrgIDMiddleware)
+		remoteQuerier := ruler.NewRemoteQuerier(
+			queryFrontendClient,
+			t.Cfg.Ruler.QueryFrontend.EvaluationTimeout(t.Cfg.Querier.EngineConfig.Timeout),
+			t.Cfg.Ruler.QueryFrontend.RetryConfig(),
+			t.Cfg.API.PrometheusHTTPPrefix,
+			util_log.Logger,
+			t.Registerer,
+			ruler.WithOrgIDMiddleware,
+		)
 
 		embeddedQueryable = prom_remote.NewSampleAndChunkQueryableClient(
 			remoteQuerier,
@@ -641,7 +649,7 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	)
 
 	dnsResolver := dns.NewProvider(util_log.Logger, dnsProviderReg, dns.GolangResolverType)
-	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, t.Registerer, util_log.Logger, dnsResolver)
+	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, t.Overrides, t.Registerer, util_log.Logger, dnsResolver)
 	if err != nil {
 		return nil, err
 	}
@@ -653,13 +661,14 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 		util_log.Logger,
 		t.RulerStorage,
 		t.Overrides,
+		queryFunc,
 	)
 	if err != nil {
 		return
 	}
 
 	// Expose HTTP/GRPC admin endpoints for the Ruler service
-	t.API.RegisterRuler(t.Ruler)
+	t.API.RegisterRuler(t.Ruler, t.Cfg.Ruler.EnableAPI)
 
 	// Expose HTTP configuration and prometheus-compatible Ruler APIs
 	t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, t.RulerStorage, util_log.Logger), t.Cfg.Ruler.EnableAPI, t.BuildInfoHandler)
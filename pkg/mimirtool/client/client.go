@@ -7,6 +7,7 @@ package client
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -15,7 +16,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/tenant"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/weaveworks/common/user"
@@ -24,11 +27,19 @@ import (
 const (
 	rulerAPIPath  = "/prometheus/config/v1/rules"
 	legacyAPIPath = "/api/v1/rules"
+
+	defaultRetryMinBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+
+	// tenantIDsSeparator separates the tenant IDs of a federated read, like the Grafana Mimir tenant federation does.
+	tenantIDsSeparator = "|"
 )
 
 var (
 	ErrResourceNotFound = errors.New("requested resource not found")
 	errConflict         = errors.New("conflict with current state of target resource")
+
+	errMultipleTenantsWrite = errors.New("multiple tenant IDs are only supported by read requests, a single tenant ID is required")
 )
 
 // Config is used to configure a MimirClient.
@@ -38,8 +49,10 @@ type Config struct {
 	Address         string `yaml:"address"`
 	ID              string `yaml:"id"`
 	TLS             tls.ClientConfig
-	UseLegacyRoutes bool   `yaml:"use_legacy_routes"`
-	AuthToken       string `yaml:"auth_token"`
+	UseLegacyRoutes bool          `yaml:"use_legacy_routes"`
+	AuthToken       string        `yaml:"auth_token"`
+	Timeout         time.Duration `yaml:"timeout"`
+	Retries         int           `yaml:"retries"`
 }
 
 // MimirClient is a client to the Mimir API.
@@ -51,6 +64,12 @@ type MimirClient struct {
 	Client    http.Client
 	apiPath   string
 	authToken string
+
+	// Backoff between the retries of the requests which support them.
+	retryBackoff backoff.Config
+
+	// Whether the client is configured with multiple tenant IDs, which only allows read requests.
+	multipleTenants bool
 }
 
 // New returns a new MimirClient.
@@ -60,6 +79,11 @@ func New(cfg Config) (*MimirClient, error) {
 		return nil, err
 	}
 
+	id, err := normalizeTenantID(cfg.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	log.WithFields(log.Fields{
 		"address": cfg.Address,
 		"id":      cfg.ID,
@@ -85,6 +109,7 @@ func New(cfg Config) (*MimirClient, error) {
 		}
 		client = http.Client{Transport: transport}
 	}
+	client.Timeout = cfg.Timeout
 
 	path := rulerAPIPath
 	if cfg.UseLegacyRoutes {
@@ -94,14 +119,45 @@ func New(cfg Config) (*MimirClient, error) {
 	return &MimirClient{
 		user:      cfg.User,
 		key:       cfg.Key,
-		id:        cfg.ID,
+		id:        id,
 		endpoint:  endpoint,
 		Client:    client,
 		apiPath:   path,
 		authToken: cfg.AuthToken,
+		retryBackoff: backoff.Config{
+			MinBackoff: defaultRetryMinBackoff,
+			MaxBackoff: defaultRetryMaxBackoff,
+			MaxRetries: cfg.Retries + 1, // The first attempt is not a retry.
+		},
+		multipleTenants: MultipleTenantIDs(id),
 	}, nil
 }
 
+// MultipleTenantIDs returns whether the tenant ID is made of multiple tenant IDs separated by "|",
+// to read the data of all of them at once. The server must have the tenant federation enabled.
+func MultipleTenantIDs(id string) bool {
+	return strings.Contains(id, tenantIDsSeparator)
+}
+
+// normalizeTenantID validates the tenant IDs separated by "|" in the input tenant ID,
+// and returns them sorted and de-duplicated. A single tenant ID is returned as is.
+func normalizeTenantID(id string) (string, error) {
+	if !MultipleTenantIDs(id) {
+		return id, nil
+	}
+
+	ids := strings.Split(id, tenantIDsSeparator)
+	for _, tenantID := range ids {
+		if tenantID == "" {
+			return "", fmt.Errorf("invalid tenant IDs %q: empty tenant ID", id)
+		}
+		if err := tenant.ValidTenantID(tenantID); err != nil {
+			return "", errors.Wrapf(err, "invalid tenant IDs %q", id)
+		}
+	}
+	return tenant.JoinTenantIDs(tenant.NormalizeTenantIDs(ids)), nil
+}
+
 // Query executes a PromQL query against the Mimir cluster.
 func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response, error) {
 	req := fmt.Sprintf("/prometheus/api/v1/query?query=%s&time=%d", url.QueryEscape(query), time.Now().Unix())
@@ -115,6 +171,66 @@ func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response,
 }
 
 func (r *MimirClient) doRequest(path, method string, payload io.Reader, contentLength int64) (*http.Response, error) {
+	req, err := r.newRequest(path, method, payload, contentLength)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.sendRequest(req)
+}
+
+// doRequestWithRetries is like doRequest, but retries the request on failures which are likely
+// transient, up to the configured number of retries. The payload is buffered to be sent again.
+func (r *MimirClient) doRequestWithRetries(ctx context.Context, path, method string, payload []byte) (*http.Response, error) {
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	boff := backoff.New(ctx, r.retryBackoff)
+	for boff.Ongoing() {
+		var body io.Reader
+		contentLength := int64(-1)
+		if payload != nil {
+			body = bytes.NewReader(payload)
+			contentLength = int64(len(payload))
+		}
+
+		var req *http.Request
+		req, err = r.newRequest(path, method, body, contentLength)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = r.sendRequest(req.WithContext(ctx))
+		if err == nil || !isRetriable(ctx, err) {
+			return resp, err
+		}
+
+		boff.Wait()
+		if boff.Ongoing() {
+			log.WithFields(log.Fields{
+				"url":     req.URL.String(),
+				"method":  req.Method,
+				"error":   err.Error(),
+				"attempt": boff.NumRetries() + 1,
+			}).Warnln("retrying request to Grafana Mimir API")
+		}
+	}
+
+	// The context may have been canceled while waiting for the backoff.
+	if err == nil {
+		err = boff.Err()
+	}
+	return nil, err
+}
+
+// newRequest builds an authenticated request to the Mimir API.
+func (r *MimirClient) newRequest(path, method string, payload io.Reader, contentLength int64) (*http.Request, error) {
+	if r.multipleTenants && method != http.MethodGet {
+		return nil, errMultipleTenantsWrite
+	}
+
 	req, err := buildRequest(path, method, *r.endpoint, payload, contentLength)
 	if err != nil {
 		return nil, err
@@ -142,6 +258,11 @@ func (r *MimirClient) doRequest(path, method string, payload io.Reader, contentL
 
 	req.Header.Add(user.OrgIDHeaderName, r.id)
 
+	return req, nil
+}
+
+// sendRequest sends the request to the Mimir API, and checks the response for errors.
+func (r *MimirClient) sendRequest(req *http.Request) (*http.Response, error) {
 	log.WithFields(log.Fields{
 		"url":    req.URL.String(),
 		"method": req.Method,
@@ -206,7 +327,37 @@ func checkResponse(r *http.Response) error {
 		"msg":    msg,
 	}).Errorln(errMsg)
 
-	return errors.New(errMsg)
+	return &statusError{statusCode: r.StatusCode, msg: errMsg}
+}
+
+// statusError is an error response of the Mimir API.
+type statusError struct {
+	statusCode int
+	msg        string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// isRetriable returns whether a request failed with the input error may succeed if retried.
+func isRetriable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.statusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	// Errors sending the request, like connection resets or timeouts.
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
 }
 
 func joinPath(baseURLPath, targetPath string) string {
@@ -7,11 +7,15 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 )
 
 func TestBuildURL(t *testing.T) {
@@ -99,3 +103,52 @@ func TestBuildURL(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_MultipleTenantIDs(t *testing.T) {
+	var orgIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		orgIDs = append(orgIDs, r.Header.Get(user.OrgIDHeaderName))
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	t.Run("should send the tenant IDs sorted and de-duplicated on read requests", func(t *testing.T) {
+		orgIDs = nil
+
+		client, err := New(Config{Address: ts.URL, ID: "tenant-b|tenant-a|tenant-b"})
+		require.NoError(t, err)
+
+		_, err = client.ListRules(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tenant-a|tenant-b"}, orgIDs)
+	})
+
+	t.Run("should fail write requests", func(t *testing.T) {
+		orgIDs = nil
+
+		client, err := New(Config{Address: ts.URL, ID: "tenant-a|tenant-b"})
+		require.NoError(t, err)
+
+		err = client.DeleteRuleGroup(context.Background(), "namespace", "group")
+		require.ErrorIs(t, err, errMultipleTenantsWrite)
+		assert.Empty(t, orgIDs)
+	})
+
+	t.Run("should send a single tenant ID as is", func(t *testing.T) {
+		orgIDs = nil
+
+		client, err := New(Config{Address: ts.URL, ID: "tenant-a"})
+		require.NoError(t, err)
+
+		_, err = client.ListRules(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"tenant-a"}, orgIDs)
+	})
+
+	for _, id := range []string{"tenant-a|", "|tenant-a", "tenant-a||tenant-b", "tenant-a|tenant#b"} {
+		t.Run("should fail on invalid tenant IDs "+id, func(t *testing.T) {
+			_, err := New(Config{Address: ts.URL, ID: id})
+			require.Error(t, err)
+		})
+	}
+}
@@ -11,8 +11,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
 func TestMimirClient_X(t *testing.T) {
@@ -79,3 +85,119 @@ func TestMimirClient_X(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_Retries(t *testing.T) {
+	const listRulesResponse = `
+namespace:
+  - name: group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`
+
+	tests := map[string]struct {
+		retries          int
+		failures         int
+		failureStatus    int
+		expectedErr      bool
+		expectedRequests int64
+	}{
+		"should succeed after retrying on 503": {
+			retries:          2,
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedRequests: 3,
+		},
+		"should succeed after retrying on 429": {
+			retries:          2,
+			failures:         1,
+			failureStatus:    http.StatusTooManyRequests,
+			expectedRequests: 2,
+		},
+		"should fail when the retries are exhausted": {
+			retries:          1,
+			failures:         2,
+			failureStatus:    http.StatusServiceUnavailable,
+			expectedErr:      true,
+			expectedRequests: 2,
+		},
+		"should not retry on 400": {
+			retries:          2,
+			failures:         2,
+			failureStatus:    http.StatusBadRequest,
+			expectedErr:      true,
+			expectedRequests: 1,
+		},
+		"should not retry on 500": {
+			retries:          2,
+			failures:         2,
+			failureStatus:    http.StatusInternalServerError,
+			expectedErr:      true,
+			expectedRequests: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Each operation is a function of a client, which is run against a new server.
+			operations := map[string]func(c *MimirClient) error{
+				"list": func(c *MimirClient) error {
+					rules, err := c.ListRules(context.Background(), "")
+					if err == nil {
+						assert.Len(t, rules["namespace"], 1)
+					}
+					return err
+				},
+				"create": func(c *MimirClient) error {
+					return c.CreateRuleGroup(context.Background(), "namespace", rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "group"}})
+				},
+				"delete": func(c *MimirClient) error {
+					return c.DeleteRuleGroup(context.Background(), "namespace", "group")
+				},
+			}
+
+			for opName, op := range operations {
+				t.Run(opName, func(t *testing.T) {
+					requests := atomic.NewInt64(0)
+					ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if requests.Inc() <= int64(tc.failures) {
+							http.Error(w, "failure", tc.failureStatus)
+							return
+						}
+						fmt.Fprint(w, listRulesResponse)
+					}))
+					t.Cleanup(ts.Close)
+
+					client, err := New(Config{Address: ts.URL, ID: "my-id", Retries: tc.retries})
+					require.NoError(t, err)
+					client.retryBackoff.MinBackoff = time.Millisecond
+					client.retryBackoff.MaxBackoff = time.Millisecond
+
+					err = op(client)
+					if tc.expectedErr {
+						require.Error(t, err)
+					} else {
+						require.NoError(t, err)
+					}
+					assert.Equal(t, tc.expectedRequests, requests.Load())
+				})
+			}
+		})
+	}
+}
+
+func TestMimirClient_Timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id", Timeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	_, err = client.ListRules(context.Background(), "")
+	require.Error(t, err)
+}
@@ -0,0 +1,16 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import "fmt"
+
+// ExitCodeError is returned by a command which completed but needs mimirtool to terminate
+// with a specific exit code, distinct from the one used when a command fails.
+type ExitCodeError struct {
+	Code   int
+	Reason string
+}
+
+func (e *ExitCodeError) Error() string {
+	return fmt.Sprintf("%s (exit code %d)", e.Reason, e.Code)
+}
@@ -6,16 +6,27 @@
 package commands
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
 
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/multierror"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -29,11 +40,17 @@ import (
 
 const (
 	defaultPrepareAggregationLabel = "cluster"
+
+	// The exit code of the diff command when changes are detected and --exit-code is set.
+	// It's distinct from the exit code used when the command fails.
+	diffChangesDetectedExitCode = 2
 )
 
 var (
-	backends = []string{rules.MimirBackend}      // list of supported backend types
-	formats  = []string{"json", "yaml", "table"} // list of supported formats for the list command
+	backends        = []string{rules.MimirBackend}      // list of supported backend types
+	formats         = []string{"json", "yaml", "table"} // list of supported formats for the list command
+	validateFormats = []string{"text", "json"}          // list of supported formats for the validate command
+	outputs         = []string{"text", "json"}          // list of supported outputs for the diff and grep commands
 )
 
 // RuleCommand configures and executes rule related mimir operations
@@ -50,11 +67,13 @@ type RuleCommand struct {
 	RuleGroup string
 
 	// Load Rules Config
-	RuleFilesList []string
-	RuleFiles     string
-	RuleFilesPath string
+	RuleFilesList  []string
+	RuleFiles      string
+	RuleFilesPath  string
+	StdinNamespace string
+	stdin          io.Reader
 
-	// Sync/Diff Rules Config
+	// Sync/Diff/Backup/Copy Rules Config
 	Namespaces           string
 	namespacesMap        map[string]struct{}
 	IgnoredNamespaces    string
@@ -62,15 +81,30 @@ type RuleCommand struct {
 
 	// Prepare Rules Config
 	InPlaceEdit                            bool
-	AggregationLabel                       string
+	AggregationLabels                      []string
 	AggregationLabelExcludedRuleGroups     string
 	aggregationLabelExcludedRuleGroupsList map[string]struct{}
 
 	// Lint Rules Config
-	LintDryRun bool
+	LintDryRun          bool
+	LintOnlyExpressions bool
 
 	// Rules check flags
-	Strict bool
+	Strict         bool
+	CrossNamespace bool
+
+	// Validate Rules Config
+	ValidateFormat string
+
+	// Backup Rules Config
+	OutputDir string
+
+	// Merge Rules Config
+	MergeOutput string
+
+	// Grep Rules Config
+	GrepPattern string
+	GrepOutput  string
 
 	// List Rules Config
 	Format string
@@ -78,7 +112,19 @@ type RuleCommand struct {
 	DisableColor bool
 
 	// Diff Rules Config
-	Verbose bool
+	Verbose    bool
+	ExitCode   bool
+	DiffOutput string
+	CompareTo  string
+
+	// Sync Rules Config
+	DryRun          bool
+	SyncConcurrency int
+
+	// Copy Rules Config
+	CopySourceID string
+	CopyDestID   string
+	CopyYes      bool
 
 	// Metrics.
 	ruleLoadTimestamp        prometheus.Gauge
@@ -124,18 +170,54 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 	checkCmd := rulesCmd.
 		Command("check", "Run various best practice checks against rules.").
 		Action(r.checkRecordingRuleNames)
+	backupCmd := rulesCmd.
+		Command("backup", "Backup the rules currently in the Grafana Mimir ruler to local files, one for each namespace.").
+		Action(r.backupRules)
+	grepCmd := rulesCmd.
+		Command("grep", "List the rules referencing the metrics matching a regular expression, without contacting Grafana Mimir.").
+		Action(r.grepRules)
+	copyCmd := rulesCmd.
+		Command("copy", "Copy the rules of a tenant to another tenant, creating or updating only the rule groups which differ.").
+		Action(r.copyRules)
+	validateCmd := rulesCmd.
+		Command("validate", "Validate a set of rule files, without contacting Grafana Mimir.").
+		Action(r.validateRules)
+	mergeCmd := rulesCmd.
+		Command("merge", "Merge a set of rule files into a single file, combining the rule groups by namespace, without contacting Grafana Mimir.").
+		Action(r.mergeRules)
 
 	// Require Mimir cluster address and tentant ID on all these commands
-	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd} {
-		c.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").
-			Envar(envVars.Address).
-			Required().
-			StringVar(&r.ClientConfig.Address)
-
-		c.Flag("id", "Grafana Mimir tenant ID; alternatively, set "+envVars.TenantID+".").
-			Envar(envVars.TenantID).
-			Required().
-			StringVar(&r.ClientConfig.ID)
+	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd, backupCmd, copyCmd} {
+		address := c.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").
+			Envar(envVars.Address)
+		if c != diffRulesCmd {
+			address.Required()
+		}
+		address.StringVar(&r.ClientConfig.Address)
+
+		// The copy command has a tenant ID for each side of the copy. The read only commands support
+		// multiple tenant IDs, to inspect the rules of the tenants federated by Grafana Mimir. The diff
+		// command doesn't contact Grafana Mimir when comparing to local rule files.
+		switch c {
+		case copyCmd:
+		case diffRulesCmd:
+			c.Flag("id", "Grafana Mimir tenant ID; alternatively, set "+envVars.TenantID+". Required unless --compare-to is set.").
+				Envar(envVars.TenantID).
+				StringVar(&r.ClientConfig.ID)
+			c.PreAction(r.requireSingleTenant)
+			c.PreAction(r.requireClusterUnlessComparingToDirs)
+		case listCmd, printRulesCmd, getRuleGroupCmd:
+			c.Flag("id", "Grafana Mimir tenant ID, or multiple tenant IDs separated by \"|\" to read the rules of all of them, which requires the tenant federation enabled in Grafana Mimir; alternatively, set "+envVars.TenantID+".").
+				Envar(envVars.TenantID).
+				Required().
+				StringVar(&r.ClientConfig.ID)
+		default:
+			c.Flag("id", "Grafana Mimir tenant ID; alternatively, set "+envVars.TenantID+".").
+				Envar(envVars.TenantID).
+				Required().
+				StringVar(&r.ClientConfig.ID)
+			c.PreAction(r.requireSingleTenant)
+		}
 
 		c.Flag("use-legacy-routes", "If set, the API requests to Grafana Mimir use the legacy /api/v1/rules routes instead of /prometheus/config/v1/rules; alternatively, set "+envVars.UseLegacyRoutes+".").
 			Default("false").
@@ -157,6 +239,19 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 			Envar(envVars.TLSKeyPath).
 			StringVar(&r.ClientConfig.TLS.KeyPath)
 
+		c.Flag("timeout", "Timeout of each request to the Grafana Mimir API. 0 means no timeout.").
+			Default("0s").
+			DurationVar(&r.ClientConfig.Timeout)
+
+		c.Flag("retries", "How many times a request to the Grafana Mimir API is retried, when failing with a connection error or the HTTP status codes 429, 502, 503, or 504. 0 means no retries.").
+			Default("3").
+			IntVar(&r.ClientConfig.Retries)
+	}
+
+	// Commands supporting reading the rules from the standard input.
+	for _, c := range []*kingpin.CmdClause{loadRulesCmd, diffRulesCmd, syncRulesCmd, lintCmd, checkCmd} {
+		c.Flag("stdin-namespace", "Namespace of the rules read from the standard input, when the rule file is \""+rules.StdinFile+"\" and the rules don't set the namespace.").
+			StringVar(&r.StdinNamespace)
 	}
 
 	// Print Rules Command
@@ -172,33 +267,52 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 	deleteRuleGroupCmd.Arg("group", "Name of the rulegroup ot delete.").Required().StringVar(&r.RuleGroup)
 
 	// Load Rules Command
-	loadRulesCmd.Arg("rule-files", "The rule files to check.").Required().ExistingFilesVar(&r.RuleFilesList)
+	loadRulesCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported. Use \""+rules.StdinFile+"\", after \"--\", to read the rules from the standard input.").Required().StringsVar(&r.RuleFilesList)
 
 	// Diff Command
-	diffRulesCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	diffRulesCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported. Use \""+rules.StdinFile+"\", after \"--\", to read the rules from the standard input.").StringsVar(&r.RuleFilesList)
 	diffRulesCmd.Flag("namespaces", "comma-separated list of namespaces to check during a diff. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
 	diffRulesCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a diff. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
-	diffRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	diffRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
 	diffRulesCmd.Flag(
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	diffRulesCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
 	diffRulesCmd.Flag("verbose", "show diff output with rules changes").BoolVar(&r.Verbose)
+	diffRulesCmd.Flag("output", "Output format of the diff: <text|json>. The text output is colored, unless --disable-color is set.").Default("text").EnumVar(&r.DiffOutput, outputs...)
+	diffRulesCmd.Flag("exit-code", fmt.Sprintf("exit with status %d if any change is detected, similar to git diff --exit-code", diffChangesDetectedExitCode)).BoolVar(&r.ExitCode)
+	diffRulesCmd.Flag(
+		"compare-to",
+		"Comma separated list of paths to directories containing the rules yaml files to compare the rule files to, instead of the rules in Grafana Mimir. Each file in a directory with a .yml or .yaml suffix will be parsed. When set, Grafana Mimir is not contacted, so --address and --id are not required.",
+	).StringVar(&r.CompareTo)
 
 	// Sync Command
-	syncRulesCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
+	syncRulesCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported. Use \""+rules.StdinFile+"\", after \"--\", to read the rules from the standard input.").StringsVar(&r.RuleFilesList)
 	syncRulesCmd.Flag("namespaces", "comma-separated list of namespaces to check during a diff. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
 	syncRulesCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a sync. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
-	syncRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	syncRulesCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
 	syncRulesCmd.Flag(
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
+	syncRulesCmd.Flag("concurrency", "How many rule groups are created, updated or deleted concurrently.").Default("8").IntVar(&r.SyncConcurrency)
+	syncRulesCmd.Flag("dry-run", "Performs a trial run that prints the changes which would be made, without applying them.").Short('n').BoolVar(&r.DryRun)
+
+	// Copy Command
+	copyCmd.Flag("source-id", "Grafana Mimir tenant ID to copy the rules from.").Required().StringVar(&r.CopySourceID)
+	copyCmd.Flag("dest-id", "Grafana Mimir tenant ID to copy the rules to.").Required().StringVar(&r.CopyDestID)
+	copyCmd.Flag("namespaces", "comma-separated list of namespaces to copy. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	copyCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to not copy. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
+	copyCmd.Flag("concurrency", "How many rule groups are created or updated concurrently.").Default("8").IntVar(&r.SyncConcurrency)
+	copyCmd.Flag("dry-run", "Performs a trial run that prints the changes which would be made, without applying them.").Short('n').BoolVar(&r.DryRun)
+	copyCmd.Flag("yes", "Copy the rules without asking for confirmation.").Short('y').BoolVar(&r.CopyYes)
+	copyCmd.Flag("disable-color", "disable colored output").BoolVar(&r.DisableColor)
+	copyCmd.Flag("verbose", "show diff output with rules changes").BoolVar(&r.Verbose)
 
 	// Prepare Command
-	prepareCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
-	prepareCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	prepareCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported.").StringsVar(&r.RuleFilesList)
+	prepareCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
 	prepareCmd.Flag(
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
@@ -207,26 +321,62 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 		"in-place",
 		"edits the rule file in place",
 	).Short('i').BoolVar(&r.InPlaceEdit)
-	prepareCmd.Flag("label", "label to include as part of the aggregations.").Default(defaultPrepareAggregationLabel).Short('l').StringVar(&r.AggregationLabel)
+	prepareCmd.Flag("label", "label to include as part of the aggregations. Flag can be reused, or set to a comma separated list, to include multiple labels.").Default(defaultPrepareAggregationLabel).Short('l').StringsVar(&r.AggregationLabels)
 	prepareCmd.Flag("label-excluded-rule-groups", "Comma separated list of rule group names to exclude when including the configured label to aggregations.").StringVar(&r.AggregationLabelExcludedRuleGroups)
 
 	// Lint Command
-	lintCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
-	lintCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	lintCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported. Use \""+rules.StdinFile+"\", after \"--\", to read the rules from the standard input.").StringsVar(&r.RuleFilesList)
+	lintCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
 	lintCmd.Flag(
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
 	lintCmd.Flag("dry-run", "Performs a trial run that doesn't make any changes and (mostly) produces the same outpupt as a real run.").Short('n').BoolVar(&r.LintDryRun)
+	lintCmd.Flag("strict", "Fails if the rule files have validation errors, like invalid durations or label names, instead of reporting them as warnings.").BoolVar(&r.Strict)
+	lintCmd.Flag("only-expressions", "Format only the PromQL expressions, preserving the order of the keys and the comments in the rule files.").BoolVar(&r.LintOnlyExpressions)
 
 	// Check Command
-	checkCmd.Arg("rule-files", "The rule files to check.").ExistingFilesVar(&r.RuleFilesList)
-	checkCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	checkCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported. Use \""+rules.StdinFile+"\", after \"--\", to read the rules from the standard input.").StringsVar(&r.RuleFilesList)
+	checkCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
 	checkCmd.Flag(
 		"rule-dirs",
 		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
 	).StringVar(&r.RuleFilesPath)
-	checkCmd.Flag("strict", "fails rules checks that do not match best practices exactly").BoolVar(&r.Strict)
+	checkCmd.Flag("strict", "fails rules checks that do not match best practices exactly, or have invalid alert templates").BoolVar(&r.Strict)
+	checkCmd.Flag("cross-namespace", "fails rules checks if recording rules in different namespaces or rule groups produce the same series").BoolVar(&r.CrossNamespace)
+
+	// Validate Command
+	validateCmd.Arg("rule-files", "The rule files to validate. Glob patterns are supported.").StringsVar(&r.RuleFilesList)
+	validateCmd.Flag("rule-files", "The rule files to validate. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
+	validateCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	validateCmd.Flag("format", "Output format of the validation errors: <text|json>").Default("text").EnumVar(&r.ValidateFormat, validateFormats...)
+
+	// Merge Command
+	mergeCmd.Arg("rule-files", "The rule files to merge. Glob patterns are supported.").StringsVar(&r.RuleFilesList)
+	mergeCmd.Flag("rule-files", "The rule files to merge. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
+	mergeCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	mergeCmd.Flag("output", "The file where the merged rules are written. If empty, the merged rules are written to the standard output.").StringVar(&r.MergeOutput)
+
+	// Grep Command
+	grepCmd.Arg("metric", "Regular expression matching the metric names. The regular expression is fully anchored.").Required().StringVar(&r.GrepPattern)
+	grepCmd.Arg("rule-files", "The rule files to check. Glob patterns are supported.").StringsVar(&r.RuleFilesList)
+	grepCmd.Flag("rule-files", "The rule files to check. Flag can be reused to load multiple files. Glob patterns are supported.").StringVar(&r.RuleFiles)
+	grepCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	grepCmd.Flag("output", "Output format of the matching rules: <text|json>").Default("text").EnumVar(&r.GrepOutput, outputs...)
+
+	// Backup Command
+	backupCmd.Flag("output-dir", "The directory where the rule files are written. Each namespace is written to a file which can be loaded with the load command.").Required().StringVar(&r.OutputDir)
+	backupCmd.Flag("namespaces", "comma-separated list of namespaces to backup. Cannot be used together with --ignored-namespaces.").StringVar(&r.Namespaces)
+	backupCmd.Flag("ignored-namespaces", "comma-separated list of namespaces to ignore during a backup. Cannot be used together with --namespaces.").StringVar(&r.IgnoredNamespaces)
 
 	// List Command
 	listCmd.Flag("format", "Backend type to interact with: <json|yaml|table>").Default("table").EnumVar(&r.Format, formats...)
@@ -245,6 +395,11 @@ func (r *RuleCommand) setup(_ *kingpin.ParseContext, reg prometheus.Registerer)
 		Help:      "The timestamp of the last successful rule load.",
 	})
 
+	// A negative number of retries would make the client retry forever.
+	if r.ClientConfig.Retries < 0 {
+		return errors.New("--retries must be greater than or equal to 0")
+	}
+
 	cli, err := client.New(r.ClientConfig)
 	if err != nil {
 		return err
@@ -254,29 +409,32 @@ func (r *RuleCommand) setup(_ *kingpin.ParseContext, reg prometheus.Registerer)
 	return nil
 }
 
-func (r *RuleCommand) setupFiles() error {
-	if r.Namespaces != "" && r.IgnoredNamespaces != "" {
-		return errors.New("--namespaces and --ignored-namespaces cannot be set at the same time")
+// requireSingleTenant fails if the command is run with multiple tenant IDs, which are only supported by the read only commands.
+func (r *RuleCommand) requireSingleTenant(_ *kingpin.ParseContext) error {
+	if client.MultipleTenantIDs(r.ClientConfig.ID) {
+		return fmt.Errorf("multiple tenant IDs %q are only supported by the list, print and get commands, a single tenant ID is required", r.ClientConfig.ID)
 	}
+	return nil
+}
 
-	// Set up ignored namespaces map for sync/diff command
-	if r.IgnoredNamespaces != "" {
-		r.ignoredNamespacesMap = map[string]struct{}{}
-		for _, ns := range strings.Split(r.IgnoredNamespaces, ",") {
-			if ns != "" {
-				r.ignoredNamespacesMap[ns] = struct{}{}
-			}
-		}
+// requireClusterUnlessComparingToDirs fails if the Grafana Mimir address or tenant ID aren't set, unless the rules are
+// compared to local rule files, which doesn't contact Grafana Mimir.
+func (r *RuleCommand) requireClusterUnlessComparingToDirs(_ *kingpin.ParseContext) error {
+	if r.CompareTo != "" {
+		return nil
+	}
+	if r.ClientConfig.Address == "" {
+		return errors.New("--address is required, unless --compare-to is set")
 	}
+	if r.ClientConfig.ID == "" {
+		return errors.New("--id is required, unless --compare-to is set")
+	}
+	return nil
+}
 
-	// Set up allowed namespaces map for sync/diff command
-	if r.Namespaces != "" {
-		r.namespacesMap = map[string]struct{}{}
-		for _, ns := range strings.Split(r.Namespaces, ",") {
-			if ns != "" {
-				r.namespacesMap[ns] = struct{}{}
-			}
-		}
+func (r *RuleCommand) setupFiles() error {
+	if err := r.setupNamespaces(); err != nil {
+		return err
 	}
 
 	// Set up rule groups excluded from label aggregation.
@@ -296,7 +454,30 @@ func (r *RuleCommand) setupFiles() error {
 		}
 	}
 
-	for _, dir := range strings.Split(r.RuleFilesPath, ",") {
+	// Expand the glob patterns, so that they're supported regardless of the shell.
+	files, err := expandRuleFiles(r.RuleFilesList)
+	if err != nil {
+		return err
+	}
+	r.RuleFilesList = files
+
+	dirFiles, err := ruleFilesInDirs(r.RuleFilesPath)
+	if err != nil {
+		return err
+	}
+	r.RuleFilesList = append(r.RuleFilesList, dirFiles...)
+
+	// The same file may be matched by multiple patterns or rule dirs.
+	r.RuleFilesList = uniqueRuleFiles(r.RuleFilesList)
+
+	return nil
+}
+
+// ruleFilesInDirs returns the rules yaml files, with a .yml or .yaml suffix, in the input comma separated
+// list of directories and their subdirectories.
+func ruleFilesInDirs(dirs string) ([]string, error) {
+	var files []string
+	for _, dir := range strings.Split(dirs, ",") {
 		if dir != "" {
 			err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 				if err != nil {
@@ -311,7 +492,7 @@ func (r *RuleCommand) setupFiles() error {
 						"file": info.Name(),
 						"path": path,
 					}).Debugf("adding file in rule-path")
-					r.RuleFilesList = append(r.RuleFilesList, path)
+					files = append(files, path)
 					return nil
 				}
 				log.WithFields(log.Fields{
@@ -321,7 +502,126 @@ func (r *RuleCommand) setupFiles() error {
 				return nil
 			})
 			if err != nil {
-				return fmt.Errorf("error walking the path %q: %v", dir, err)
+				return nil, fmt.Errorf("error walking the path %q: %v", dir, err)
+			}
+		}
+	}
+	return files, nil
+}
+
+// expandRuleFiles expands the glob patterns in the input rule files. Existing files are
+// kept as they are, even if their name contains glob meta characters.
+func expandRuleFiles(files []string) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		if file == rules.StdinFile {
+			expanded = append(expanded, file)
+			continue
+		}
+		if _, err := os.Stat(file); err == nil {
+			expanded = append(expanded, file)
+			continue
+		}
+
+		matches, err := filepath.Glob(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule files pattern %q: %v", file, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no rule files found matching %q", file)
+		}
+
+		log.WithFields(log.Fields{
+			"pattern": file,
+			"files":   matches,
+		}).Debugf("adding files matching pattern")
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// uniqueRuleFiles removes the duplicated rule files, preserving the order.
+func uniqueRuleFiles(files []string) []string {
+	seen := make(map[string]struct{}, len(files))
+	unique := files[:0]
+	for _, file := range files {
+		key := filepath.Clean(file)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, file)
+	}
+	return unique
+}
+
+// parseRuleFiles parses the rule files, reading the rules from the standard input
+// in place of the rule file named rules.StdinFile.
+func (r *RuleCommand) parseRuleFiles() (map[string]rules.RuleNamespace, error) {
+	var (
+		files     []string
+		readStdin bool
+	)
+	for _, file := range r.RuleFilesList {
+		if file == rules.StdinFile {
+			readStdin = true
+			continue
+		}
+		files = append(files, file)
+	}
+
+	nss, err := rules.ParseFiles(r.Backend, files)
+	if err != nil || !readStdin {
+		return nss, err
+	}
+
+	stdinNss, err := rules.ParseReader(r.Backend, r.stdinReader(), rules.StdinFile, r.StdinNamespace)
+	if errors.Is(err, rules.ErrMissingNamespace) {
+		return nil, errors.New("the rules read from the standard input must set the namespace, or --stdin-namespace must be set")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, ns := range stdinNss {
+		if _, exists := nss[name]; exists {
+			return nil, fmt.Errorf("namespace %q is loaded from both the standard input and the rule files", name)
+		}
+		nss[name] = ns
+	}
+	return nss, nil
+}
+
+// stdinReader returns the reader of the rules read from the standard input.
+func (r *RuleCommand) stdinReader() io.Reader {
+	if r.stdin != nil {
+		return r.stdin
+	}
+	return os.Stdin
+}
+
+// setupNamespaces sets up the namespaces to check, or ignore, in the commands supporting namespaces filtering.
+func (r *RuleCommand) setupNamespaces() error {
+	if r.Namespaces != "" && r.IgnoredNamespaces != "" {
+		return errors.New("--namespaces and --ignored-namespaces cannot be set at the same time")
+	}
+
+	// Set up ignored namespaces map for sync/diff/backup/copy command
+	if r.IgnoredNamespaces != "" {
+		r.ignoredNamespacesMap = map[string]struct{}{}
+		for _, ns := range strings.Split(r.IgnoredNamespaces, ",") {
+			if ns != "" {
+				r.ignoredNamespacesMap[ns] = struct{}{}
+			}
+		}
+	}
+
+	// Set up allowed namespaces map for sync/diff/backup/copy command
+	if r.Namespaces != "" {
+		r.namespacesMap = map[string]struct{}{}
+		for _, ns := range strings.Split(r.Namespaces, ",") {
+			if ns != "" {
+				r.namespacesMap[ns] = struct{}{}
 			}
 		}
 	}
@@ -377,7 +677,12 @@ func (r *RuleCommand) deleteRuleGroup(k *kingpin.ParseContext) error {
 }
 
 func (r *RuleCommand) loadRules(k *kingpin.ParseContext) error {
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "load operation unsuccessful, unable to load rules files")
+	}
+
+	nss, err := r.parseRuleFiles()
 	if err != nil {
 		return errors.Wrap(err, "load operation unsuccessful, unable to parse rules files")
 	}
@@ -439,19 +744,45 @@ func (r *RuleCommand) diffRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "diff operation unsuccessful, unable to load rules files")
 	}
 
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	nss, err := r.parseRuleFiles()
 	if err != nil {
 		return errors.Wrap(err, "diff operation unsuccessful, unable to parse rules files")
 	}
 
-	currentNamespaceMap, err := r.cli.ListRules(context.Background(), "")
-	//TODO: Skipping the 404s here might end up in an unsual scenario.
-	// If we're unable to reach the Mimir API due to a bad URL, we'll assume no rules are
-	// part of the namespace and provide a diff of the whole ruleset.
-	if err != nil && err != client.ErrResourceNotFound {
-		return errors.Wrap(err, "diff operation unsuccessful, unable to contact Grafana Mimir API")
+	var currentNamespaceMap map[string][]rwrulefmt.RuleGroup
+	if r.CompareTo != "" {
+		currentNamespaceMap, err = r.parseCompareToDirs()
+		if err != nil {
+			return errors.Wrap(err, "diff operation unsuccessful, unable to parse the rules files to compare to")
+		}
+	} else {
+		currentNamespaceMap, err = r.cli.ListRules(context.Background(), "")
+		//TODO: Skipping the 404s here might end up in an unsual scenario.
+		// If we're unable to reach the Mimir API due to a bad URL, we'll assume no rules are
+		// part of the namespace and provide a diff of the whole ruleset.
+		if err != nil && err != client.ErrResourceNotFound {
+			return errors.Wrap(err, "diff operation unsuccessful, unable to contact Grafana Mimir API")
+		}
 	}
 
+	changes := r.diffNamespaces(nss, currentNamespaceMap)
+
+	p := printer.New(r.DisableColor)
+	if r.DiffOutput == "json" {
+		err = p.PrintComparisonResultJSON(changes, r.Verbose, os.Stdout)
+	} else {
+		err = p.PrintComparisonResult(changes, r.Verbose)
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.diffExitCode(changes)
+}
+
+// diffNamespaces compares the input namespaces to the current ones, skipping the namespaces not to check.
+// The current namespaces map is modified.
+func (r *RuleCommand) diffNamespaces(nss map[string]rules.RuleNamespace, currentNamespaceMap map[string][]rwrulefmt.RuleGroup) []rules.NamespaceChange {
 	changes := []rules.NamespaceChange{}
 
 	for _, ns := range nss {
@@ -492,17 +823,54 @@ func (r *RuleCommand) diffRules(k *kingpin.ParseContext) error {
 		})
 	}
 
-	p := printer.New(r.DisableColor)
-	return p.PrintComparisonResult(changes, r.Verbose)
+	return changes
+}
+
+// parseCompareToDirs parses the rule files in the directories to compare the rule files to, returning
+// the rule groups by namespace like they're listed by Grafana Mimir.
+func (r *RuleCommand) parseCompareToDirs() (map[string][]rwrulefmt.RuleGroup, error) {
+	files, err := ruleFilesInDirs(r.CompareTo)
+	if err != nil {
+		return nil, err
+	}
+
+	nss, err := rules.ParseFiles(r.Backend, files)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]rwrulefmt.RuleGroup, len(nss))
+	for name, ns := range nss {
+		groups[name] = ns.Groups
+	}
+	return groups, nil
+}
+
+// diffExitCode returns an ExitCodeError if the exit code has been requested and there's any change.
+func (r *RuleCommand) diffExitCode(changes []rules.NamespaceChange) error {
+	if !r.ExitCode {
+		return nil
+	}
+
+	created, updated, deleted := rules.SummarizeChanges(changes)
+	if created+updated+deleted == 0 {
+		return nil
+	}
+
+	return &ExitCodeError{Code: diffChangesDetectedExitCode, Reason: "changes detected"}
 }
 
 func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
+	if r.SyncConcurrency < 1 {
+		return errors.New("sync operation unsuccessful, --concurrency must be greater than 0")
+	}
+
 	err := r.setupFiles()
 	if err != nil {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to load rules files")
 	}
 
-	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	nss, err := r.parseRuleFiles()
 	if err != nil {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to parse rules files")
 	}
@@ -560,64 +928,255 @@ func (r *RuleCommand) syncRules(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "sync operation unsuccessful, unable to complete executing changes.")
 	}
 
+	r.printChangesSummary("Sync", changes)
+	return nil
+}
+
+// copyRules copies the rule groups of the source tenant to the destination tenant, creating or updating
+// only the rule groups which differ. The rule groups of the destination tenant are never deleted.
+func (r *RuleCommand) copyRules(k *kingpin.ParseContext) error {
+	if r.SyncConcurrency < 1 {
+		return errors.New("copy operation unsuccessful, --concurrency must be greater than 0")
+	}
+	if r.CopySourceID == r.CopyDestID {
+		return errors.New("copy operation unsuccessful, --source-id and --dest-id must be different")
+	}
+	if err := r.setupNamespaces(); err != nil {
+		return errors.Wrap(err, "copy operation unsuccessful, invalid namespaces")
+	}
+
+	sourceCli, err := r.tenantClient(r.CopySourceID)
+	if err != nil {
+		return err
+	}
+	destCli, err := r.tenantClient(r.CopyDestID)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	sourceNamespaces, err := sourceCli.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to list the rules of the source tenant")
+	}
+	destNamespaces, err := destCli.ListRules(ctx, "")
+	if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to list the rules of the destination tenant")
+	}
+
+	changes := copyChanges(sourceNamespaces, destNamespaces, r.shouldCheckNamespace)
+
+	p := printer.New(r.DisableColor)
+	if err := p.PrintComparisonResult(changes, r.Verbose); err != nil {
+		return err
+	}
+
+	if created, updated, _ := rules.SummarizeChanges(changes); created+updated == 0 {
+		return nil
+	}
+
+	if !r.CopyYes && !r.DryRun {
+		confirmed, err := confirm(r.stdinReader(), os.Stdout, fmt.Sprintf("Copy the rules of tenant %q to tenant %q?", r.CopySourceID, r.CopyDestID))
+		if err != nil {
+			return errors.Wrap(err, "copy operation unsuccessful, unable to read the confirmation")
+		}
+		if !confirmed {
+			fmt.Println("Copy aborted: no changes have been applied.")
+			return nil
+		}
+	}
+
+	r.cli = destCli
+	if err := r.executeChanges(ctx, changes); err != nil {
+		return errors.Wrap(err, "copy operation unsuccessful, unable to complete executing changes.")
+	}
+
+	r.printChangesSummary("Copy", changes)
+	return nil
+}
+
+// tenantClient returns a client of the tenant, configured like the client of the command otherwise.
+func (r *RuleCommand) tenantClient(tenantID string) (*client.MimirClient, error) {
+	cfg := r.ClientConfig
+	cfg.ID = tenantID
+	return client.New(cfg)
+}
+
+// copyChanges returns the changes needed to copy the source rule groups to the destination ones.
+// The destination rule groups not existing in the source namespaces are preserved.
+func copyChanges(source, dest map[string][]rwrulefmt.RuleGroup, shouldCheckNamespace func(string) bool) []rules.NamespaceChange {
+	names := make([]string, 0, len(source))
+	for name := range source {
+		if shouldCheckNamespace(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := make([]rules.NamespaceChange, 0, len(names))
+	for _, name := range names {
+		destGroups, exists := dest[name]
+		if !exists {
+			changes = append(changes, rules.NamespaceChange{
+				State:         rules.Created,
+				Namespace:     name,
+				GroupsCreated: source[name],
+			})
+			continue
+		}
+
+		change := rules.CompareNamespaces(
+			rules.RuleNamespace{Namespace: name, Groups: destGroups},
+			rules.RuleNamespace{Namespace: name, Groups: source[name]},
+		)
+		change.GroupsDeleted = nil
+		if len(change.GroupsCreated) == 0 && len(change.GroupsUpdated) == 0 {
+			change.State = rules.Unchanged
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// confirm asks the question, and returns whether the answer read from the input is affirmative.
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (r *RuleCommand) backupRules(k *kingpin.ParseContext) error {
+	if err := r.setupNamespaces(); err != nil {
+		return errors.Wrap(err, "backup operation unsuccessful, invalid namespaces")
+	}
+
+	currentNamespaceMap, err := r.cli.ListRules(context.Background(), "")
+	if err != nil {
+		if errors.Is(err, client.ErrResourceNotFound) {
+			log.Infof("no rule groups currently exist for this user")
+			return nil
+		}
+		return errors.Wrap(err, "backup operation unsuccessful, unable to contact Grafana Mimir API")
+	}
+
+	if err := os.MkdirAll(r.OutputDir, 0755); err != nil {
+		return errors.Wrap(err, "backup operation unsuccessful, unable to create the output directory")
+	}
+
+	nss := map[string]rules.RuleNamespace{}
+	for ns, groups := range currentNamespaceMap {
+		if !r.shouldCheckNamespace(ns) {
+			continue
+		}
+
+		// The namespace is explicitly set in the file, so that it's preserved when loaded back,
+		// while the file name is escaped like the ruler does.
+		nss[ns] = rules.RuleNamespace{
+			Namespace: ns,
+			Filepath:  filepath.Join(r.OutputDir, url.PathEscape(ns)+".yaml"),
+			Groups:    groups,
+		}
+	}
+
+	if err := save(nss, true); err != nil {
+		return errors.Wrap(err, "backup operation unsuccessful, unable to write rules files")
+	}
+
+	log.Infof("SUCCESS: %d namespaces backed up to %s", len(nss), r.OutputDir)
 	return nil
 }
 
+// ruleGroupOperation is a change to apply to a single rule group.
+type ruleGroupOperation struct {
+	namespace string
+	group     rwrulefmt.RuleGroup
+	delete    bool
+	msg       string
+}
+
+// executeChanges applies the changes to the rule groups in Grafana Mimir, running up to the configured
+// number of concurrent requests. In dry-run mode, the changes are only logged and summarized.
 func (r *RuleCommand) executeChanges(ctx context.Context, changes []rules.NamespaceChange) error {
-	var err error
+	var ops []ruleGroupOperation
 	for _, ch := range changes {
-		for _, g := range ch.GroupsCreated {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
-			}
-
-			log.WithFields(log.Fields{
-				"group":     g.Name,
-				"namespace": ch.Namespace,
-			}).Infof("creating group")
-			err = r.cli.CreateRuleGroup(ctx, ch.Namespace, g)
-			if err != nil {
-				return err
-			}
+		if !r.shouldCheckNamespace(ch.Namespace) {
+			continue
 		}
 
+		for _, g := range ch.GroupsCreated {
+			ops = append(ops, ruleGroupOperation{namespace: ch.Namespace, group: g, msg: "creating group"})
+		}
 		for _, g := range ch.GroupsUpdated {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
-			}
+			ops = append(ops, ruleGroupOperation{namespace: ch.Namespace, group: g.New, msg: "updating group"})
+		}
+		for _, g := range ch.GroupsDeleted {
+			ops = append(ops, ruleGroupOperation{namespace: ch.Namespace, group: g, delete: true, msg: "deleting group"})
+		}
+	}
 
-			log.WithFields(log.Fields{
-				"group":     g.New.Name,
-				"namespace": ch.Namespace,
-			}).Infof("updating group")
-			err = r.cli.CreateRuleGroup(ctx, ch.Namespace, g.New)
-			if err != nil {
-				return err
-			}
+	var (
+		errsMx sync.Mutex
+		errs   multierror.MultiError
+	)
+	err := concurrency.ForEachJob(ctx, len(ops), r.SyncConcurrency, func(ctx context.Context, idx int) error {
+		op := ops[idx]
+
+		log.WithFields(log.Fields{
+			"group":     op.group.Name,
+			"namespace": op.namespace,
+			"dry_run":   r.DryRun,
+		}).Info(op.msg)
+		if r.DryRun {
+			return nil
 		}
 
-		for _, g := range ch.GroupsDeleted {
-			if !r.shouldCheckNamespace(ch.Namespace) {
-				continue
+		var err error
+		if op.delete {
+			err = r.cli.DeleteRuleGroup(ctx, op.namespace, op.group.Name)
+			if errors.Is(err, client.ErrResourceNotFound) {
+				err = nil
 			}
+		} else {
+			err = r.cli.CreateRuleGroup(ctx, op.namespace, op.group)
+		}
 
-			log.WithFields(log.Fields{
-				"group":     g.Name,
-				"namespace": ch.Namespace,
-			}).Infof("deleting group")
-			err = r.cli.DeleteRuleGroup(ctx, ch.Namespace, g.Name)
-			if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
-				return err
-			}
+		// The remaining operations aren't started after a failure, but the ones
+		// running concurrently complete, so their errors are reported too.
+		if err != nil {
+			errsMx.Lock()
+			errs.Add(errors.Wrapf(err, "%s %q in namespace %q", op.msg, op.group.Name, op.namespace))
+			errsMx.Unlock()
 		}
+		return err
+	})
+	if err != nil {
+		if aggregated := errs.Err(); aggregated != nil {
+			return aggregated
+		}
+		return err
 	}
 
-	updated, created, deleted := rules.SummarizeChanges(changes)
-	fmt.Println()
-	fmt.Printf("Sync Summary: %v Groups Created, %v Groups Updated, %v Groups Deleted\n", created, updated, deleted)
 	return nil
 }
 
+// printChangesSummary prints the number of rule groups changed by the operation.
+func (r *RuleCommand) printChangesSummary(operation string, changes []rules.NamespaceChange) {
+	created, updated, deleted := rules.SummarizeChanges(changes)
+	fmt.Println()
+	fmt.Printf("%s Summary: %v Groups Created, %v Groups Updated, %v Groups Deleted\n", operation, created, updated, deleted)
+	if r.DryRun {
+		fmt.Println("Dry run: no changes have been applied.")
+	}
+}
+
 func (r *RuleCommand) prepare(k *kingpin.ParseContext) error {
 	err := r.setupFiles()
 	if err != nil {
@@ -635,9 +1194,18 @@ func (r *RuleCommand) prepare(k *kingpin.ParseContext) error {
 		return !excluded
 	}
 
+	var labels []string
+	for _, value := range r.AggregationLabels {
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				labels = append(labels, label)
+			}
+		}
+	}
+
 	var count, mod int
 	for _, ruleNamespace := range namespaces {
-		c, m, err := ruleNamespace.AggregateBy(r.AggregationLabel, applyTo)
+		c, m, err := ruleNamespace.AggregateByLabels(labels, applyTo)
 		if err != nil {
 			return err
 		}
@@ -662,7 +1230,28 @@ func (r *RuleCommand) lint(k *kingpin.ParseContext) error {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to load rules files")
 	}
 
-	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	if err := r.validateLintFiles(); err != nil {
+		return err
+	}
+
+	if r.LintOnlyExpressions {
+		return r.lintOnlyExpressions()
+	}
+
+	// The files which can't be parsed have already been reported, and can't be formatted.
+	files := make([]string, 0, len(r.RuleFilesList))
+	for _, file := range r.RuleFilesList {
+		if file != rules.StdinFile {
+			if _, errs := rules.Parse(file); len(errs) > 0 {
+				log.WithField("file", file).Warnln("skipping rules file which can't be parsed")
+				continue
+			}
+		}
+		files = append(files, file)
+	}
+	r.RuleFilesList = files
+
+	namespaces, err := r.parseRuleFiles()
 	if err != nil {
 		return errors.Wrap(err, "prepare operation unsuccessful, unable to parse rules files")
 	}
@@ -690,13 +1279,84 @@ func (r *RuleCommand) lint(k *kingpin.ParseContext) error {
 	return nil
 }
 
+// validateLintFiles validates the rule files to lint, reporting the errors found as warnings,
+// or failing if the strict mode is enabled.
+func (r *RuleCommand) validateLintFiles() error {
+	// The rules read from the standard input are validated when parsed.
+	files := make([]string, 0, len(r.RuleFilesList))
+	for _, file := range r.RuleFilesList {
+		if file != rules.StdinFile {
+			files = append(files, file)
+		}
+	}
+
+	errs := rules.ValidateFiles(files)
+	for _, e := range errs {
+		if r.Strict {
+			log.Errorln(e.String())
+		} else {
+			log.Warnln(e.String())
+		}
+	}
+
+	if r.Strict && len(errs) > 0 {
+		return fmt.Errorf("lint operation unsuccessful, %d validation errors found in rule files", len(errs))
+	}
+	return nil
+}
+
+// lintOnlyExpressions formats the expressions in the rule files, leaving the rest of the files untouched.
+func (r *RuleCommand) lintOnlyExpressions() error {
+	var count, mod int
+	for _, file := range r.RuleFilesList {
+		var (
+			content []byte
+			err     error
+		)
+		if file == rules.StdinFile {
+			content, err = io.ReadAll(r.stdinReader())
+		} else {
+			content, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "lint operation unsuccessful, unable to read rules file %s", file)
+		}
+
+		linted, c, m, err := rules.LintFileExpressions(r.Backend, content)
+		if err != nil {
+			return errors.Wrapf(err, "lint operation unsuccessful, unable to lint rules file %s", file)
+		}
+
+		count += c
+		mod += m
+
+		// linting will always in-place edit unless is a dry-run.
+		if r.LintDryRun {
+			continue
+		}
+		if file == rules.StdinFile {
+			if _, err := os.Stdout.Write(linted); err != nil {
+				return err
+			}
+		} else if m > 0 {
+			if err := os.WriteFile(file, linted, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Infof("SUCCESS: %d rules found, %d linted expressions", count, mod)
+
+	return nil
+}
+
 func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 	err := r.setupFiles()
 	if err != nil {
 		return errors.Wrap(err, "check operation unsuccessful, unable to load rules files")
 	}
 
-	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	namespaces, err := r.parseRuleFiles()
 	if err != nil {
 		return errors.Wrap(err, "check operation unsuccessful, unable to parse rules files")
 	}
@@ -706,6 +1366,10 @@ func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 		if n != 0 {
 			return fmt.Errorf("%d erroneous recording rule names", n)
 		}
+		// Invalid alert templates only fail the check in strict mode, like the validation errors in lint.
+		if n := ruleNamespace.CheckAlertTemplates(r.Strict); n != 0 && r.Strict {
+			return fmt.Errorf("%d erroneous alert templates", n)
+		}
 		duplicateRules := checkDuplicates(ruleNamespace.Groups)
 		if len(duplicateRules) != 0 {
 			fmt.Printf("%d duplicate rule(s) found.\n", len(duplicateRules))
@@ -719,6 +1383,218 @@ func (r *RuleCommand) checkRecordingRuleNames(k *kingpin.ParseContext) error {
 		}
 	}
 
+	if r.CrossNamespace {
+		duplicates := checkCrossGroupDuplicates(namespaces)
+		if len(duplicates) != 0 {
+			fmt.Printf("%d series recorded by multiple rule groups found.\n", len(duplicates))
+			for _, d := range duplicates {
+				fmt.Printf("Metric: %s\nLabel(s):\n", d.output.metric)
+				for i, l := range d.output.label {
+					fmt.Printf("\t%s: %s\n", i, l)
+				}
+				fmt.Println("Rule(s):")
+				for _, ref := range d.rules {
+					fmt.Printf("\tnamespace: %s, group: %s, rule: %s\n", ref.namespace, ref.group, ref.rule)
+				}
+			}
+			return fmt.Errorf("%d series recorded by multiple rule groups", len(duplicates))
+		}
+	}
+
+	return nil
+}
+
+// recordingRuleRef identifies a recording rule.
+type recordingRuleRef struct {
+	namespace string
+	group     string
+	rule      string
+}
+
+// crossGroupDuplicate is a series recorded by rules in different rule groups, which overwrite each other.
+type crossGroupDuplicate struct {
+	output compareRuleType
+	rules  []recordingRuleRef
+}
+
+// checkCrossGroupDuplicates returns the series recorded by rules in different rule groups, across all
+// the namespaces. Duplicates within the same rule group are reported by checkDuplicates.
+func checkCrossGroupDuplicates(namespaces map[string]rules.RuleNamespace) []crossGroupDuplicate {
+	nsNames := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		nsNames = append(nsNames, name)
+	}
+	sort.Strings(nsNames)
+
+	var (
+		keys    []string
+		outputs = map[string]*crossGroupDuplicate{}
+	)
+	for _, nsName := range nsNames {
+		for _, group := range namespaces[nsName].Groups {
+			for _, rule := range group.Rules {
+				if rule.Record.Value == "" {
+					continue
+				}
+
+				key := rule.Record.Value + labels.FromMap(rule.Labels).String()
+				d, ok := outputs[key]
+				if !ok {
+					d = &crossGroupDuplicate{output: compareRuleType{metric: rule.Record.Value, label: rule.Labels}}
+					outputs[key] = d
+					keys = append(keys, key)
+				}
+				d.rules = append(d.rules, recordingRuleRef{namespace: nsName, group: group.Name, rule: rule.Record.Value})
+			}
+		}
+	}
+
+	var duplicates []crossGroupDuplicate
+	for _, key := range keys {
+		d := outputs[key]
+		for _, ref := range d.rules[1:] {
+			if ref.namespace != d.rules[0].namespace || ref.group != d.rules[0].group {
+				duplicates = append(duplicates, *d)
+				break
+			}
+		}
+	}
+	return duplicates
+}
+
+func (r *RuleCommand) validateRules(k *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "validate operation unsuccessful, unable to load rules files")
+	}
+
+	errs := rules.ValidateFiles(r.RuleFilesList)
+	if err := printValidationErrors(os.Stdout, errs, r.ValidateFormat); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d validation errors found in rule files", len(errs))
+	}
+	return nil
+}
+
+func (r *RuleCommand) mergeRules(k *kingpin.ParseContext) error {
+	err := r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "merge operation unsuccessful, unable to load rules files")
+	}
+
+	namespaces, warnings, err := rules.MergeFiles(r.Backend, r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "merge operation unsuccessful")
+	}
+
+	for _, w := range warnings {
+		log.Warnln(w.String())
+	}
+
+	if r.MergeOutput == "" {
+		return writeNamespaces(os.Stdout, namespaces)
+	}
+
+	f, err := os.Create(r.MergeOutput)
+	if err != nil {
+		return errors.Wrap(err, "merge operation unsuccessful, unable to create the output file")
+	}
+	if err := writeNamespaces(f, namespaces); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "merge operation unsuccessful, unable to write the output file")
+	}
+	return f.Close()
+}
+
+// writeNamespaces writes the rule namespaces as YAML documents, in the input order.
+func writeNamespaces(w io.Writer, namespaces []rules.RuleNamespace) error {
+	for idx, ns := range namespaces {
+		payload, err := yamlv3.Marshal(ns)
+		if err != nil {
+			return err
+		}
+		if idx > 0 {
+			payload = append([]byte("---\n"), payload...)
+		}
+		if _, err := w.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RuleCommand) grepRules(k *kingpin.ParseContext) error {
+	// The regular expression is anchored, like label matchers in PromQL.
+	re, err := regexp.Compile("^(?:" + r.GrepPattern + ")$")
+	if err != nil {
+		return errors.Wrap(err, "grep operation unsuccessful, invalid metric regular expression")
+	}
+
+	err = r.setupFiles()
+	if err != nil {
+		return errors.Wrap(err, "grep operation unsuccessful, unable to load rules files")
+	}
+
+	namespaces, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "grep operation unsuccessful, unable to parse rules files")
+	}
+
+	nsNames := make([]string, 0, len(namespaces))
+	for name := range namespaces {
+		nsNames = append(nsNames, name)
+	}
+	sort.Strings(nsNames)
+
+	refs := []rules.MetricReference{}
+	for _, name := range nsNames {
+		nsRefs, err := namespaces[name].FindMetricReferences(re)
+		if err != nil {
+			return errors.Wrapf(err, "grep operation unsuccessful, unable to parse rules in namespace %q", name)
+		}
+		refs = append(refs, nsRefs...)
+	}
+
+	return printMetricReferences(os.Stdout, refs, r.GrepOutput)
+}
+
+// printMetricReferences prints the rules referencing metrics in the requested format.
+func printMetricReferences(w io.Writer, refs []rules.MetricReference, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(refs)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+	fmt.Fprintln(tw, "Namespace\t Rule Group\t Rule\t Metrics")
+	for _, ref := range refs {
+		fmt.Fprintf(tw, "%s\t %s\t %s\t %s\n", ref.Namespace, ref.Group, ref.Rule, strings.Join(ref.Metrics, ", "))
+	}
+	return tw.Flush()
+}
+
+// printValidationErrors prints the rule files validation errors in the requested format.
+func printValidationErrors(w io.Writer, errs []rules.ValidationError, format string) error {
+	if format == "json" {
+		if errs == nil {
+			errs = []rules.ValidationError{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Errors []rules.ValidationError `json:"errors"`
+		}{Errors: errs})
+	}
+
+	for _, e := range errs {
+		if _, err := fmt.Fprintln(w, e.String()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -761,9 +1637,16 @@ func ruleMetric(rule rulefmt.RuleNode) string {
 // End taken from https://github.com/prometheus/prometheus/blob/8c8de46003d1800c9d40121b4a5e5de8582ef6e1/cmd/promtool/main.go#L403
 
 // save saves a set of rule files to to disk. You can specify whenever you want the
-// file(s) to be edited in-place.
+// file(s) to be edited in-place. The rules read from the standard input are written
+// to the standard output instead.
 func save(nss map[string]rules.RuleNamespace, i bool) error {
-	for _, ns := range nss {
+	var stdinNamespaces []string
+	for name, ns := range nss {
+		if ns.Filepath == rules.StdinFile {
+			stdinNamespaces = append(stdinNamespaces, name)
+			continue
+		}
+
 		payload, err := yamlv3.Marshal(ns)
 		if err != nil {
 			return err
@@ -779,5 +1662,20 @@ func save(nss map[string]rules.RuleNamespace, i bool) error {
 		}
 	}
 
+	// Write the namespaces as YAML documents in a stable order.
+	sort.Strings(stdinNamespaces)
+	for idx, name := range stdinNamespaces {
+		payload, err := yamlv3.Marshal(nss[name])
+		if err != nil {
+			return err
+		}
+		if idx > 0 {
+			payload = append([]byte("---\n"), payload...)
+		}
+		if _, err := os.Stdout.Write(payload); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
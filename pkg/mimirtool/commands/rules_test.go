@@ -6,12 +6,24 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/mimir/pkg/mimirtool/client"
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
@@ -67,3 +79,771 @@ func TestCheckDuplicates(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckCrossGroupDuplicates(t *testing.T) {
+	newNamespace := func(namespace, group string, rs ...rulefmt.RuleNode) rules.RuleNamespace {
+		return rules.RuleNamespace{
+			Namespace: namespace,
+			Groups:    []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{Name: group, Rules: rs}}},
+		}
+	}
+	recordingRule := func(record string, labels map[string]string) rulefmt.RuleNode {
+		return rulefmt.RuleNode{Record: yaml.Node{Value: record}, Expr: yaml.Node{Value: "sum(up)"}, Labels: labels}
+	}
+
+	for _, tc := range []struct {
+		name string
+		in   map[string]rules.RuleNamespace
+		want []crossGroupDuplicate
+	}{
+		{
+			name: "no duplicates",
+			in: map[string]rules.RuleNamespace{
+				"ns1": newNamespace("ns1", "group", recordingRule("job:up:sum", map[string]string{"env": "prod"})),
+				"ns2": newNamespace("ns2", "group", recordingRule("job:up:sum", map[string]string{"env": "dev"})),
+			},
+			want: nil,
+		},
+		{
+			name: "duplicates in the same group are ignored",
+			in: map[string]rules.RuleNamespace{
+				"ns1": newNamespace("ns1", "group", recordingRule("job:up:sum", nil), recordingRule("job:up:sum", nil)),
+			},
+			want: nil,
+		},
+		{
+			name: "duplicates across namespaces",
+			in: map[string]rules.RuleNamespace{
+				"ns1": newNamespace("ns1", "group", recordingRule("job:up:sum", map[string]string{"env": "prod"})),
+				"ns2": newNamespace("ns2", "other", recordingRule("job:up:sum", map[string]string{"env": "prod"})),
+			},
+			want: []crossGroupDuplicate{{
+				output: compareRuleType{metric: "job:up:sum", label: map[string]string{"env": "prod"}},
+				rules: []recordingRuleRef{
+					{namespace: "ns1", group: "group", rule: "job:up:sum"},
+					{namespace: "ns2", group: "other", rule: "job:up:sum"},
+				},
+			}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, checkCrossGroupDuplicates(tc.in))
+		})
+	}
+}
+
+func TestDiffExitCode(t *testing.T) {
+	group := rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: "rulegroup"}}
+	unchanged := []rules.NamespaceChange{{State: rules.Unchanged, Namespace: "unchanged"}}
+	changed := append(unchanged, rules.NamespaceChange{State: rules.Created, Namespace: "created", GroupsCreated: []rwrulefmt.RuleGroup{group}})
+
+	for _, tc := range []struct {
+		name         string
+		exitCode     bool
+		changes      []rules.NamespaceChange
+		expectedCode int
+	}{
+		{name: "exit code disabled and no changes", changes: unchanged},
+		{name: "exit code disabled and changes", changes: changed},
+		{name: "exit code enabled and no changes", exitCode: true, changes: unchanged},
+		{name: "exit code enabled and changes", exitCode: true, changes: changed, expectedCode: diffChangesDetectedExitCode},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RuleCommand{ExitCode: tc.exitCode}
+			err := r.diffExitCode(tc.changes)
+			if tc.expectedCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var exitCodeErr *ExitCodeError
+			require.ErrorAs(t, err, &exitCodeErr)
+			assert.Equal(t, tc.expectedCode, exitCodeErr.Code)
+		})
+	}
+}
+
+func TestDiffRules_CompareTo(t *testing.T) {
+	const (
+		// The snapshot has the groups "unchanged", "updated" and "deleted" in the "changed" namespace,
+		// and the "deleted" namespace which isn't proposed anymore.
+		snapshotChanged = `namespace: changed
+groups:
+  - name: unchanged
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+  - name: updated
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+  - name: deleted
+    rules:
+      - record: job:down:sum
+        expr: sum by (job) (1 - up)
+`
+		snapshotDeleted = `namespace: deleted
+groups:
+  - name: group
+    rules:
+      - record: job:up:count
+        expr: count by (job) (up)
+`
+		// The proposed rules update the "updated" group, create the "created" group and the "created" namespace.
+		proposedChanged = `namespace: changed
+groups:
+  - name: unchanged
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+  - name: updated
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+        for: 5m
+  - name: created
+    rules:
+      - record: job:up:avg
+        expr: avg by (job) (up)
+`
+		proposedCreated = `namespace: created
+groups:
+  - name: group
+    rules:
+      - record: job:up:max
+        expr: max by (job) (up)
+`
+	)
+
+	snapshotDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, "changed.yaml"), []byte(snapshotChanged), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, "deleted.yml"), []byte(snapshotDeleted), 0644))
+	// Files without a rules yaml suffix are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(snapshotDir, "README.md"), []byte("not rules"), 0644))
+
+	proposedDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(proposedDir, "changed.yaml"), []byte(proposedChanged), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(proposedDir, "created.yaml"), []byte(proposedCreated), 0644))
+
+	t.Run("should compare the rule files to the ones in the directory", func(t *testing.T) {
+		r := RuleCommand{Backend: rules.MimirBackend, RuleFilesPath: proposedDir, CompareTo: snapshotDir}
+		require.NoError(t, r.setupFiles())
+
+		nss, err := r.parseRuleFiles()
+		require.NoError(t, err)
+		current, err := r.parseCompareToDirs()
+		require.NoError(t, err)
+
+		changes := map[string]rules.NamespaceChange{}
+		for _, change := range r.diffNamespaces(nss, current) {
+			changes[change.Namespace] = change
+		}
+		require.Len(t, changes, 3)
+
+		assert.Equal(t, rules.Updated, changes["changed"].State)
+		require.Len(t, changes["changed"].GroupsCreated, 1)
+		assert.Equal(t, "created", changes["changed"].GroupsCreated[0].Name)
+		require.Len(t, changes["changed"].GroupsUpdated, 1)
+		assert.Equal(t, "updated", changes["changed"].GroupsUpdated[0].New.Name)
+		require.Len(t, changes["changed"].GroupsDeleted, 1)
+		assert.Equal(t, "deleted", changes["changed"].GroupsDeleted[0].Name)
+
+		assert.Equal(t, rules.Created, changes["created"].State)
+		require.Len(t, changes["created"].GroupsCreated, 1)
+		assert.Equal(t, "group", changes["created"].GroupsCreated[0].Name)
+
+		assert.Equal(t, rules.Deleted, changes["deleted"].State)
+		require.Len(t, changes["deleted"].GroupsDeleted, 1)
+		assert.Equal(t, "group", changes["deleted"].GroupsDeleted[0].Name)
+	})
+
+	t.Run("should run the diff without contacting Grafana Mimir", func(t *testing.T) {
+		// The client isn't set, so the diff fails if it contacts Grafana Mimir.
+		r := RuleCommand{Backend: rules.MimirBackend, RuleFilesPath: proposedDir, CompareTo: snapshotDir, DiffOutput: "json", ExitCode: true}
+		require.NoError(t, r.requireClusterUnlessComparingToDirs(nil))
+
+		var exitCodeErr *ExitCodeError
+		require.ErrorAs(t, r.diffRules(nil), &exitCodeErr)
+		assert.Equal(t, diffChangesDetectedExitCode, exitCodeErr.Code)
+	})
+
+	t.Run("should report no changes comparing a directory to itself", func(t *testing.T) {
+		r := RuleCommand{Backend: rules.MimirBackend, RuleFilesPath: snapshotDir, CompareTo: snapshotDir, DiffOutput: "json", ExitCode: true}
+		require.NoError(t, r.diffRules(nil))
+	})
+
+	t.Run("should require the Grafana Mimir address and tenant ID if not comparing to local rule files", func(t *testing.T) {
+		r := RuleCommand{}
+		require.EqualError(t, r.requireClusterUnlessComparingToDirs(nil), "--address is required, unless --compare-to is set")
+
+		r.ClientConfig.Address = "http://localhost"
+		require.EqualError(t, r.requireClusterUnlessComparingToDirs(nil), "--id is required, unless --compare-to is set")
+
+		r.ClientConfig.ID = "user-1"
+		require.NoError(t, r.requireClusterUnlessComparingToDirs(nil))
+	})
+}
+
+func TestBackupRules(t *testing.T) {
+	const listRulesResponse = `
+first:
+  - name: first-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+second/namespace:
+  - name: second-group
+    interval: 1m
+    rules:
+      - alert: Down
+        expr: up == 0
+        for: 5m
+third:
+  - name: third-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, listRulesResponse)
+	}))
+	t.Cleanup(ts.Close)
+
+	cli, err := client.New(client.Config{Address: ts.URL, ID: "user-1"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name               string
+		namespaces         string
+		ignoredNamespaces  string
+		expectedFiles      []string
+		expectedNamespaces []string
+	}{
+		{
+			name:               "all namespaces",
+			expectedFiles:      []string{"first.yaml", "second%2Fnamespace.yaml", "third.yaml"},
+			expectedNamespaces: []string{"first", "second/namespace", "third"},
+		},
+		{
+			name:               "allowed namespaces",
+			namespaces:         "first,second/namespace",
+			expectedFiles:      []string{"first.yaml", "second%2Fnamespace.yaml"},
+			expectedNamespaces: []string{"first", "second/namespace"},
+		},
+		{
+			name:               "ignored namespaces",
+			ignoredNamespaces:  "first",
+			expectedFiles:      []string{"second%2Fnamespace.yaml", "third.yaml"},
+			expectedNamespaces: []string{"second/namespace", "third"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RuleCommand{
+				cli:               cli,
+				Backend:           rules.MimirBackend,
+				OutputDir:         filepath.Join(t.TempDir(), "backup"),
+				Namespaces:        tc.namespaces,
+				IgnoredNamespaces: tc.ignoredNamespaces,
+			}
+			require.NoError(t, r.backupRules(nil))
+
+			entries, err := os.ReadDir(r.OutputDir)
+			require.NoError(t, err)
+
+			var files []string
+			for _, e := range entries {
+				files = append(files, filepath.Join(r.OutputDir, e.Name()))
+			}
+			for i, f := range tc.expectedFiles {
+				tc.expectedFiles[i] = filepath.Join(r.OutputDir, f)
+			}
+			require.Equal(t, tc.expectedFiles, files)
+
+			// The backed up files must be loadable back.
+			nss, err := rules.ParseFiles(rules.MimirBackend, files)
+			require.NoError(t, err)
+
+			var namespaces []string
+			for ns := range nss {
+				namespaces = append(namespaces, ns)
+			}
+			assert.ElementsMatch(t, tc.expectedNamespaces, namespaces)
+			if ns, ok := nss["second/namespace"]; ok {
+				require.Len(t, ns.Groups, 1)
+				assert.Equal(t, "second-group", ns.Groups[0].Name)
+				assert.Equal(t, "Down", ns.Groups[0].Rules[0].Alert.Value)
+			}
+		})
+	}
+}
+
+func TestRuleCommand_Setup_ShouldRejectNegativeRetries(t *testing.T) {
+	cmd := &RuleCommand{}
+	cmd.ClientConfig.Address = "http://localhost:8080"
+	cmd.ClientConfig.ID = "user-1"
+	cmd.ClientConfig.Retries = -1
+
+	assert.EqualError(t, cmd.setup(nil, prometheus.NewPedanticRegistry()), "--retries must be greater than or equal to 0")
+
+	cmd.ClientConfig.Retries = 0
+	assert.NoError(t, cmd.setup(nil, prometheus.NewPedanticRegistry()))
+}
+
+func TestRuleCommand_SetupFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"first.yaml", "second.yaml", "third.yml", "not-rules.txt", "nested/fourth.yaml"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, f)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte("groups: []\n"), 0644))
+	}
+
+	for _, tc := range []struct {
+		name          string
+		ruleFilesList []string
+		ruleFiles     string
+		ruleFilesPath string
+		expectedFiles []string
+		expectedErr   string
+	}{
+		{
+			name:          "literal paths",
+			ruleFilesList: []string{filepath.Join(dir, "first.yaml")},
+			ruleFiles:     filepath.Join(dir, "third.yml"),
+			expectedFiles: []string{filepath.Join(dir, "first.yaml"), filepath.Join(dir, "third.yml")},
+		},
+		{
+			name:          "glob matching multiple files",
+			ruleFilesList: []string{filepath.Join(dir, "*.yaml")},
+			ruleFiles:     filepath.Join(dir, "*.yml"),
+			expectedFiles: []string{filepath.Join(dir, "first.yaml"), filepath.Join(dir, "second.yaml"), filepath.Join(dir, "third.yml")},
+		},
+		{
+			name:          "glob matching no files",
+			ruleFilesList: []string{filepath.Join(dir, "*.json")},
+			expectedErr:   fmt.Sprintf("no rule files found matching %q", filepath.Join(dir, "*.json")),
+		},
+		{
+			name:        "literal path not existing",
+			ruleFiles:   filepath.Join(dir, "not-existing.yaml"),
+			expectedErr: fmt.Sprintf("no rule files found matching %q", filepath.Join(dir, "not-existing.yaml")),
+		},
+		{
+			name:          "overlapping globs and literal paths",
+			ruleFilesList: []string{filepath.Join(dir, "first.yaml"), filepath.Join(dir, "f*.yaml")},
+			ruleFiles:     filepath.Join(dir, "*.yaml"),
+			expectedFiles: []string{filepath.Join(dir, "first.yaml"), filepath.Join(dir, "second.yaml")},
+		},
+		{
+			name:          "overlapping globs and rule dirs",
+			ruleFilesList: []string{filepath.Join(dir, "nested", "*.yaml"), filepath.Join(dir, "s*.yaml")},
+			ruleFilesPath: dir,
+			expectedFiles: []string{
+				filepath.Join(dir, "nested", "fourth.yaml"),
+				filepath.Join(dir, "second.yaml"),
+				filepath.Join(dir, "first.yaml"),
+				filepath.Join(dir, "third.yml"),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RuleCommand{
+				RuleFilesList: tc.ruleFilesList,
+				RuleFiles:     tc.ruleFiles,
+				RuleFilesPath: tc.ruleFilesPath,
+			}
+
+			err := r.setupFiles()
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFiles, r.RuleFilesList)
+		})
+	}
+}
+
+func TestSyncRules_DryRun(t *testing.T) {
+	const listRulesResponse = `
+created-in-file:
+  - name: created-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+deleted:
+  - name: deleted-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`
+	const ruleFile = `
+namespace: created-in-file
+groups:
+  - name: created-group
+    rules:
+      - record: summed_up
+        expr: sum(up) by (job)
+  - name: another-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`
+
+	var (
+		mtx     sync.Mutex
+		methods []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		methods = append(methods, r.Method)
+		mtx.Unlock()
+
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, listRulesResponse)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	cli, err := client.New(client.Config{Address: ts.URL, ID: "user-1"})
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(ruleFile), 0644))
+
+	for _, dryRun := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry run: %t", dryRun), func(t *testing.T) {
+			mtx.Lock()
+			methods = nil
+			mtx.Unlock()
+
+			r := RuleCommand{
+				cli:             cli,
+				Backend:         rules.MimirBackend,
+				RuleFilesList:   []string{file},
+				DryRun:          dryRun,
+				SyncConcurrency: 1,
+			}
+			require.NoError(t, r.syncRules(nil))
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if dryRun {
+				// Only the rules listing is expected.
+				assert.Equal(t, []string{http.MethodGet}, methods)
+			} else {
+				// The created and updated groups are posted, the removed namespace group is deleted.
+				assert.ElementsMatch(t, []string{http.MethodGet, http.MethodPost, http.MethodPost, http.MethodDelete}, methods)
+			}
+		})
+	}
+}
+
+func TestSyncRules_Stdin(t *testing.T) {
+	const ruleFile = `
+groups:
+  - name: piped-group
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`
+
+	var (
+		mtx   sync.Mutex
+		posts []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			mtx.Lock()
+			posts = append(posts, r.URL.Path)
+			mtx.Unlock()
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	cli, err := client.New(client.Config{Address: ts.URL, ID: "user-1"})
+	require.NoError(t, err)
+
+	t.Run("should sync the rules read from the standard input", func(t *testing.T) {
+		r := RuleCommand{
+			cli:             cli,
+			Backend:         rules.MimirBackend,
+			RuleFilesList:   []string{rules.StdinFile},
+			StdinNamespace:  "piped",
+			SyncConcurrency: 1,
+			stdin:           strings.NewReader(ruleFile),
+		}
+		require.NoError(t, r.syncRules(nil))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.Equal(t, []string{"/prometheus/config/v1/rules/piped"}, posts)
+	})
+
+	t.Run("should fail if the namespace is not set", func(t *testing.T) {
+		r := RuleCommand{
+			cli:             cli,
+			Backend:         rules.MimirBackend,
+			RuleFilesList:   []string{rules.StdinFile},
+			SyncConcurrency: 1,
+			stdin:           strings.NewReader(ruleFile),
+		}
+		require.ErrorContains(t, r.syncRules(nil), "--stdin-namespace must be set")
+	})
+}
+
+func TestCopyRules(t *testing.T) {
+	rulesByTenant := map[string]string{
+		"source": `
+ns1:
+  - name: unchanged
+    rules:
+      - record: summed_up
+        expr: sum(up)
+  - name: updated
+    rules:
+      - record: summed_up
+        expr: sum(up) by (job)
+ns2:
+  - name: created
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`,
+		"dest": `
+ns1:
+  - name: unchanged
+    rules:
+      - record: summed_up
+        expr: sum(up)
+  - name: updated
+    rules:
+      - record: summed_up
+        expr: sum(up)
+ns3:
+  - name: preserved
+    rules:
+      - record: summed_up
+        expr: sum(up)
+`,
+	}
+
+	var (
+		mtx      sync.Mutex
+		requests []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID := r.Header.Get("X-Scope-OrgID")
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, rulesByTenant[tenantID])
+			return
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests = append(requests, fmt.Sprintf("%s %s %s", tenantID, r.Method, r.URL.Path))
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, tc := range []struct {
+		name              string
+		ignoredNamespaces string
+		yes               bool
+		answer            string
+		expected          []string
+	}{
+		{
+			name: "should create and update the rule groups differing in the destination tenant",
+			yes:  true,
+			expected: []string{
+				"dest POST /prometheus/config/v1/rules/ns1",
+				"dest POST /prometheus/config/v1/rules/ns2",
+			},
+		},
+		{
+			name:              "should skip the ignored namespaces",
+			ignoredNamespaces: "ns2",
+			yes:               true,
+			expected:          []string{"dest POST /prometheus/config/v1/rules/ns1"},
+		},
+		{
+			name:   "should copy the rules when confirmed",
+			answer: "y\n",
+			expected: []string{
+				"dest POST /prometheus/config/v1/rules/ns1",
+				"dest POST /prometheus/config/v1/rules/ns2",
+			},
+		},
+		{
+			name:     "should not copy the rules when not confirmed",
+			answer:   "n\n",
+			expected: nil,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mtx.Lock()
+			requests = nil
+			mtx.Unlock()
+
+			r := RuleCommand{
+				ClientConfig:      client.Config{Address: ts.URL},
+				CopySourceID:      "source",
+				CopyDestID:        "dest",
+				CopyYes:           tc.yes,
+				IgnoredNamespaces: tc.ignoredNamespaces,
+				SyncConcurrency:   1,
+				stdin:             strings.NewReader(tc.answer),
+			}
+			require.NoError(t, r.copyRules(nil))
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			assert.ElementsMatch(t, tc.expected, requests)
+		})
+	}
+
+	t.Run("should fail if the source and destination tenants are the same", func(t *testing.T) {
+		r := RuleCommand{
+			ClientConfig:    client.Config{Address: ts.URL},
+			CopySourceID:    "source",
+			CopyDestID:      "source",
+			SyncConcurrency: 1,
+		}
+		require.Error(t, r.copyRules(nil))
+	})
+}
+
+func TestLint_Validation(t *testing.T) {
+	const (
+		validFile = `namespace: valid
+groups:
+    - name: group
+      rules:
+        - record: job:up:sum
+          expr: sum(up)   by (job)
+`
+		invalidForFile = `namespace: invalid_for
+groups:
+  - name: group
+    rules:
+      - alert: MissingUnit
+        expr: up  ==  0
+        for: 5
+`
+		invalidLabelFile = `namespace: invalid_label
+groups:
+  - name: group
+    rules:
+      - record: job:up:sum
+        expr: sum(up)   by (job)
+        labels:
+          "invalid-label": value
+`
+	)
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict: %t", strict), func(t *testing.T) {
+			dir := t.TempDir()
+			files := map[string]string{
+				filepath.Join(dir, "valid.yaml"):         validFile,
+				filepath.Join(dir, "invalid_for.yaml"):   invalidForFile,
+				filepath.Join(dir, "invalid_label.yaml"): invalidLabelFile,
+			}
+			for file, content := range files {
+				require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+			}
+
+			r := RuleCommand{
+				Backend:       rules.MimirBackend,
+				RuleFilesList: []string{filepath.Join(dir, "*.yaml")},
+				Strict:        strict,
+			}
+			err := r.lint(nil)
+
+			if strict {
+				require.EqualError(t, err, "lint operation unsuccessful, 2 validation errors found in rule files")
+			} else {
+				require.NoError(t, err)
+			}
+
+			// The invalid files are never changed, while the valid one is formatted only if not strict.
+			for file, content := range files {
+				actual, err := os.ReadFile(file)
+				require.NoError(t, err)
+				if file == filepath.Join(dir, "valid.yaml") && !strict {
+					assert.Contains(t, string(actual), "sum by(job) (up)")
+				} else {
+					assert.Equal(t, content, string(actual))
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteChanges_Concurrency(t *testing.T) {
+	const numGroups = 50
+
+	var created []rwrulefmt.RuleGroup
+	for i := 0; i < numGroups; i++ {
+		created = append(created, rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{Name: fmt.Sprintf("group-%d", i)}})
+	}
+	changes := []rules.NamespaceChange{
+		{State: rules.Created, Namespace: "created", GroupsCreated: created},
+		{State: rules.Created, Namespace: "ignored", GroupsCreated: created},
+		{State: rules.Deleted, Namespace: "deleted", GroupsDeleted: created[:1]},
+	}
+
+	t.Run("should apply all the changes, except the ignored namespaces", func(t *testing.T) {
+		var (
+			mtx      sync.Mutex
+			requests = map[string]int{}
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			requests[r.Method+" "+r.URL.EscapedPath()]++
+		}))
+		t.Cleanup(ts.Close)
+
+		cli, err := client.New(client.Config{Address: ts.URL, ID: "user-1"})
+		require.NoError(t, err)
+
+		r := RuleCommand{cli: cli, SyncConcurrency: 8, IgnoredNamespaces: "ignored"}
+		require.NoError(t, r.setupNamespaces())
+		require.NoError(t, r.executeChanges(context.Background(), changes))
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.Equal(t, map[string]int{
+			"POST /prometheus/config/v1/rules/created":           numGroups,
+			"DELETE /prometheus/config/v1/rules/deleted/group-0": 1,
+		}, requests)
+	})
+
+	t.Run("should stop on failure and return the errors", func(t *testing.T) {
+		var (
+			mtx      sync.Mutex
+			requests int
+		)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mtx.Lock()
+			requests++
+			mtx.Unlock()
+			http.Error(w, "failure", http.StatusInternalServerError)
+		}))
+		t.Cleanup(ts.Close)
+
+		cli, err := client.New(client.Config{Address: ts.URL, ID: "user-1"})
+		require.NoError(t, err)
+
+		r := RuleCommand{cli: cli, SyncConcurrency: 2, IgnoredNamespaces: "ignored"}
+		require.NoError(t, r.setupNamespaces())
+		err = r.executeChanges(context.Background(), changes)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `creating group "group-`)
+		assert.Contains(t, err.Error(), `in namespace "created"`)
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		assert.LessOrEqual(t, requests, 2)
+	})
+}
@@ -187,6 +187,94 @@ func (p *Printer) PrintComparisonResult(results []rules.NamespaceChange, verbose
 	return nil
 }
 
+// PrintComparisonResultJSON prints the differences between the staged rules namespace
+// and active rules namespace as JSON. When verbose, the original and new version of each
+// updated rule group is included.
+func (p *Printer) PrintComparisonResultJSON(results []rules.NamespaceChange, verbose bool, writer io.Writer) error {
+	type updatedGroup struct {
+		Name     string      `json:"name"`
+		New      interface{} `json:"new,omitempty"`
+		Original interface{} `json:"original,omitempty"`
+	}
+	type namespaceChange struct {
+		Namespace     string         `json:"namespace"`
+		State         string         `json:"state"`
+		GroupsCreated []string       `json:"groups_created"`
+		GroupsUpdated []updatedGroup `json:"groups_updated"`
+		GroupsDeleted []string       `json:"groups_deleted"`
+	}
+	type summary struct {
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+		Deleted int `json:"deleted"`
+	}
+
+	output := struct {
+		Changes []namespaceChange `json:"changes"`
+		Summary summary           `json:"summary"`
+	}{
+		Changes: []namespaceChange{},
+	}
+	output.Summary.Created, output.Summary.Updated, output.Summary.Deleted = rules.SummarizeChanges(results)
+
+	for _, change := range results {
+		if change.State == rules.Unchanged {
+			continue
+		}
+
+		c := namespaceChange{
+			Namespace:     change.Namespace,
+			State:         change.State.String(),
+			GroupsCreated: []string{},
+			GroupsUpdated: []updatedGroup{},
+			GroupsDeleted: []string{},
+		}
+		for _, g := range change.GroupsCreated {
+			c.GroupsCreated = append(c.GroupsCreated, g.Name)
+		}
+		for _, g := range change.GroupsUpdated {
+			u := updatedGroup{Name: g.New.Name}
+			if verbose {
+				var err error
+				if u.New, err = ruleGroupToJSONValue(g.New); err != nil {
+					return err
+				}
+				if u.Original, err = ruleGroupToJSONValue(g.Original); err != nil {
+					return err
+				}
+			}
+			c.GroupsUpdated = append(c.GroupsUpdated, u)
+		}
+		for _, g := range change.GroupsDeleted {
+			c.GroupsDeleted = append(c.GroupsDeleted, g.Name)
+		}
+		output.Changes = append(output.Changes, c)
+	}
+
+	encoded, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(writer, string(encoded))
+	return err
+}
+
+// ruleGroupToJSONValue converts the rule group to a value which can be marshalled to JSON
+// the same way it's marshalled to YAML, because rule nodes only support YAML.
+func ruleGroupToJSONValue(group rwrulefmt.RuleGroup) (interface{}, error) {
+	encoded, err := yaml.Marshal(group)
+	if err != nil {
+		return nil, err
+	}
+
+	var value map[string]interface{}
+	if err := yaml.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
 func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format string, writer io.Writer) error {
 	nsKeys := make([]string, 0, len(rules))
 	for k := range rules {
@@ -195,16 +283,32 @@ func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format st
 	sort.Strings(nsKeys)
 
 	type namespaceAndRuleGroup struct {
-		Namespace string `json:"namespace" yaml:"namespace"`
-		RuleGroup string `json:"rulegroup" yaml:"rulegroup"`
+		Namespace      string `json:"namespace" yaml:"namespace"`
+		RuleGroup      string `json:"rulegroup" yaml:"rulegroup"`
+		Rules          int    `json:"rules" yaml:"rules"`
+		AlertingRules  int    `json:"alerting_rules" yaml:"alerting_rules"`
+		RecordingRules int    `json:"recording_rules" yaml:"recording_rules"`
+		SizeBytes      int    `json:"size_bytes" yaml:"size_bytes"`
 	}
 	var items []namespaceAndRuleGroup
 
 	for _, ns := range nsKeys {
 		for _, rg := range rules[ns] {
+			alerting, recording := countRuleTypes(rg)
+
+			// The size is approximated by the size of the rule group serialized to YAML.
+			size, err := yaml.Marshal(rg)
+			if err != nil {
+				return err
+			}
+
 			items = append(items, namespaceAndRuleGroup{
-				Namespace: ns,
-				RuleGroup: rg.Name,
+				Namespace:      ns,
+				RuleGroup:      rg.Name,
+				Rules:          len(rg.Rules),
+				AlertingRules:  alerting,
+				RecordingRules: recording,
+				SizeBytes:      len(size),
 			})
 		}
 	}
@@ -237,9 +341,9 @@ func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format st
 	default:
 		w := tabwriter.NewWriter(writer, 0, 0, 1, ' ', tabwriter.Debug)
 
-		fmt.Fprintln(w, "Namespace\t Rule Group")
+		fmt.Fprintln(w, "Namespace\t Rule Group\t Rules\t Alerting\t Recording\t Size (bytes)")
 		for _, item := range items {
-			fmt.Fprintf(w, "%s\t %s\n", item.Namespace, item.RuleGroup)
+			fmt.Fprintf(w, "%s\t %s\t %d\t %d\t %d\t %d\n", item.Namespace, item.RuleGroup, item.Rules, item.AlertingRules, item.RecordingRules, item.SizeBytes)
 		}
 
 		w.Flush()
@@ -247,3 +351,15 @@ func (p *Printer) PrintRuleSet(rules map[string][]rwrulefmt.RuleGroup, format st
 
 	return nil
 }
+
+// countRuleTypes returns the number of alerting and recording rules in the rule group.
+func countRuleTypes(rg rwrulefmt.RuleGroup) (alerting, recording int) {
+	for _, r := range rg.Rules {
+		if r.Alert.Value != "" {
+			alerting++
+		} else if r.Record.Value != "" {
+			recording++
+		}
+	}
+	return alerting, recording
+}
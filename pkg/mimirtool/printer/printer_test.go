@@ -7,13 +7,16 @@ package printer
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 
 	"github.com/alecthomas/chroma/quick"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
 )
 
@@ -29,26 +32,47 @@ func TestPrintRuleSet(t *testing.T) {
 		},
 	}
 
-	wantJSONOutput := `[{"namespace":"test-namespace-1","rulegroup":"test-rulegroup-a"},{"namespace":"test-namespace-1","rulegroup":"test-rulegroup-b"},{"namespace":"test-namespace-2","rulegroup":"test-rulegroup-c"},{"namespace":"test-namespace-2","rulegroup":"test-rulegroup-d"}]`
+	wantJSONOutput := `[` +
+		`{"namespace":"test-namespace-1","rulegroup":"test-rulegroup-a","rules":0,"alerting_rules":0,"recording_rules":0,"size_bytes":33},` +
+		`{"namespace":"test-namespace-1","rulegroup":"test-rulegroup-b","rules":0,"alerting_rules":0,"recording_rules":0,"size_bytes":33},` +
+		`{"namespace":"test-namespace-2","rulegroup":"test-rulegroup-c","rules":0,"alerting_rules":0,"recording_rules":0,"size_bytes":33},` +
+		`{"namespace":"test-namespace-2","rulegroup":"test-rulegroup-d","rules":0,"alerting_rules":0,"recording_rules":0,"size_bytes":33}` +
+		`]`
 	var wantColoredJSONBuffer bytes.Buffer
 	err := quick.Highlight(&wantColoredJSONBuffer, wantJSONOutput, "json", "terminal", "swapoff")
 	require.NoError(t, err)
 
-	wantTabOutput := `Namespace        | Rule Group
-test-namespace-1 | test-rulegroup-a
-test-namespace-1 | test-rulegroup-b
-test-namespace-2 | test-rulegroup-c
-test-namespace-2 | test-rulegroup-d
+	wantTabOutput := `Namespace        | Rule Group       | Rules | Alerting | Recording | Size (bytes)
+test-namespace-1 | test-rulegroup-a | 0     | 0        | 0         | 33
+test-namespace-1 | test-rulegroup-b | 0     | 0        | 0         | 33
+test-namespace-2 | test-rulegroup-c | 0     | 0        | 0         | 33
+test-namespace-2 | test-rulegroup-d | 0     | 0        | 0         | 33
 `
 
 	wantYAMLOutput := `- namespace: test-namespace-1
   rulegroup: test-rulegroup-a
+  rules: 0
+  alerting_rules: 0
+  recording_rules: 0
+  size_bytes: 33
 - namespace: test-namespace-1
   rulegroup: test-rulegroup-b
+  rules: 0
+  alerting_rules: 0
+  recording_rules: 0
+  size_bytes: 33
 - namespace: test-namespace-2
   rulegroup: test-rulegroup-c
+  rules: 0
+  alerting_rules: 0
+  recording_rules: 0
+  size_bytes: 33
 - namespace: test-namespace-2
   rulegroup: test-rulegroup-d
+  rules: 0
+  alerting_rules: 0
+  recording_rules: 0
+  size_bytes: 33
 `
 	var wantColoredYAMLBuffer bytes.Buffer
 	err = quick.Highlight(&wantColoredYAMLBuffer, wantYAMLOutput, "yaml", "terminal", "swapoff")
@@ -101,3 +125,98 @@ test-namespace-2 | test-rulegroup-d
 		})
 	}
 }
+
+func TestPrintRuleSet_RuleCounts(t *testing.T) {
+	rule := func(kind, name, expr string) rulefmt.RuleNode {
+		node := rulefmt.RuleNode{Expr: yaml.Node{Kind: yaml.ScalarNode, Value: expr}}
+		if kind == "alert" {
+			node.Alert = yaml.Node{Kind: yaml.ScalarNode, Value: name}
+		} else {
+			node.Record = yaml.Node{Kind: yaml.ScalarNode, Value: name}
+		}
+		return node
+	}
+
+	giveRules := map[string][]rwrulefmt.RuleGroup{
+		"test-namespace": {
+			{RuleGroup: rulefmt.RuleGroup{Name: "mixed", Rules: []rulefmt.RuleNode{
+				rule("record", "job:up:sum", "sum by(job) (up)"),
+				rule("alert", "Down", "up == 0"),
+				rule("record", "job:requests:rate5m", "sum by(job) (rate(requests_total[5m]))"),
+			}}},
+		},
+	}
+
+	size, err := yaml.Marshal(giveRules["test-namespace"][0])
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, New(true).PrintRuleSet(giveRules, "json", &b))
+	assert.JSONEq(t, fmt.Sprintf(`[{"namespace":"test-namespace","rulegroup":"mixed","rules":3,"alerting_rules":1,"recording_rules":2,"size_bytes":%d}]`, len(size)), b.String())
+
+	b.Reset()
+	require.NoError(t, New(true).PrintRuleSet(giveRules, "", &b))
+	assert.Equal(t, fmt.Sprintf(`Namespace      | Rule Group | Rules | Alerting | Recording | Size (bytes)
+test-namespace | mixed      | 3     | 1        | 2         | %d
+`, len(size)), b.String())
+}
+
+func TestPrintComparisonResultJSON(t *testing.T) {
+	ruleGroup := func(name, expr string) rwrulefmt.RuleGroup {
+		return rwrulefmt.RuleGroup{RuleGroup: rulefmt.RuleGroup{
+			Name: name,
+			Rules: []rulefmt.RuleNode{{
+				Record: yaml.Node{Kind: yaml.ScalarNode, Value: "summed_up"},
+				Expr:   yaml.Node{Kind: yaml.ScalarNode, Value: expr},
+			}},
+		}}
+	}
+
+	changes := []rules.NamespaceChange{
+		{State: rules.Unchanged, Namespace: "unchanged"},
+		{State: rules.Created, Namespace: "created", GroupsCreated: []rwrulefmt.RuleGroup{ruleGroup("group-a", "sum(up)")}},
+		{
+			State:         rules.Updated,
+			Namespace:     "updated",
+			GroupsCreated: []rwrulefmt.RuleGroup{ruleGroup("group-b", "sum(up)")},
+			GroupsUpdated: []rules.UpdatedRuleGroup{{New: ruleGroup("group-c", "sum(up)"), Original: ruleGroup("group-c", "sum(up) by (job)")}},
+		},
+		{State: rules.Deleted, Namespace: "deleted", GroupsDeleted: []rwrulefmt.RuleGroup{ruleGroup("group-d", "sum(up)")}},
+	}
+
+	tests := map[string]struct {
+		verbose    bool
+		changes    []rules.NamespaceChange
+		wantOutput string
+	}{
+		"no changes": {
+			changes:    changes[:1],
+			wantOutput: `{"changes":[],"summary":{"created":0,"updated":0,"deleted":0}}` + "\n",
+		},
+		"changes": {
+			changes: changes,
+			wantOutput: `{"changes":[` +
+				`{"namespace":"created","state":"created","groups_created":["group-a"],"groups_updated":[],"groups_deleted":[]},` +
+				`{"namespace":"updated","state":"updated","groups_created":["group-b"],"groups_updated":[{"name":"group-c"}],"groups_deleted":[]},` +
+				`{"namespace":"deleted","state":"deleted","groups_created":[],"groups_updated":[],"groups_deleted":["group-d"]}` +
+				`],"summary":{"created":2,"updated":1,"deleted":1}}` + "\n",
+		},
+		"changes with verbose": {
+			verbose: true,
+			changes: changes[2:3],
+			wantOutput: `{"changes":[` +
+				`{"namespace":"updated","state":"updated","groups_created":["group-b"],"groups_updated":[{"name":"group-c",` +
+				`"new":{"name":"group-c","rules":[{"expr":"sum(up)","record":"summed_up"}]},` +
+				`"original":{"name":"group-c","rules":[{"expr":"sum(up) by (job)","record":"summed_up"}]}}],"groups_deleted":[]}` +
+				`],"summary":{"created":1,"updated":1,"deleted":0}}` + "\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var output bytes.Buffer
+			require.NoError(t, New(false).PrintComparisonResultJSON(tc.changes, tc.verbose, &output))
+			assert.Equal(t, tc.wantOutput, output.String())
+		})
+	}
+}
@@ -41,6 +41,22 @@ const (
 	Deleted
 )
 
+// String returns the name of the state.
+func (s NamespaceState) String() string {
+	switch s {
+	case Unchanged:
+		return "unchanged"
+	case Created:
+		return "created"
+	case Updated:
+		return "updated"
+	case Deleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
 // NamespaceChange stores the various changes between a staged set of changes
 // and the active rules configs.
 type NamespaceChange struct {
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// MetricReference is a rule whose expression references some metrics.
+type MetricReference struct {
+	Namespace string   `json:"namespace"`
+	Group     string   `json:"group"`
+	Rule      string   `json:"rule"`
+	Expr      string   `json:"expr"`
+	Metrics   []string `json:"metrics"`
+}
+
+// FindMetricReferences returns the rules whose expression references a metric name fully matching
+// the input regular expression. Metric names are extracted from the vector selectors of the parsed
+// expressions, so that metric names in label values or strings are not matched.
+func (r RuleNamespace) FindMetricReferences(re *regexp.Regexp) ([]MetricReference, error) {
+	var refs []MetricReference
+
+	for _, group := range r.Groups {
+		for _, rule := range group.Rules {
+			expr, err := parser.ParseExpr(rule.Expr.Value)
+			if err != nil {
+				return nil, err
+			}
+
+			var metrics []string
+			for _, name := range metricNames(expr) {
+				if re.MatchString(name) {
+					metrics = append(metrics, name)
+				}
+			}
+			if len(metrics) == 0 {
+				continue
+			}
+
+			refs = append(refs, MetricReference{
+				Namespace: r.Namespace,
+				Group:     group.Name,
+				Rule:      getRuleName(rule),
+				Expr:      rule.Expr.Value,
+				Metrics:   metrics,
+			})
+		}
+	}
+
+	return refs, nil
+}
+
+// metricNames returns the sorted metric names selected by the vector selectors in the expression.
+func metricNames(expr parser.Expr) []string {
+	names := map[string]struct{}{}
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		if vs.Name != "" {
+			names[vs.Name] = struct{}{}
+			return nil
+		}
+
+		// The metric name can also be selected with a label matcher, like {__name__="metric"}.
+		for _, m := range vs.LabelMatchers {
+			if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+				names[m.Value] = struct{}{}
+			}
+		}
+		return nil
+	})
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+func TestRuleNamespace_FindMetricReferences(t *testing.T) {
+	ns := RuleNamespace{
+		Namespace: "namespace",
+		Groups: []rwrulefmt.RuleGroup{
+			{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "group-1",
+					Rules: []rulefmt.RuleNode{
+						{Record: yaml.Node{Value: "job:up:sum"}, Expr: yaml.Node{Value: `sum by(job) (up)`}},
+						{Record: yaml.Node{Value: "job:requests:rate5m"}, Expr: yaml.Node{Value: `sum by(job) (rate(http_requests_total[5m])) / sum by(job) (rate(http_requests_total{code="500"}[5m]))`}},
+						{Alert: yaml.Node{Value: "LabelValue"}, Expr: yaml.Node{Value: `absent(scrape_samples_scraped{job="up"})`}},
+					},
+				},
+			},
+			{
+				RuleGroup: rulefmt.RuleGroup{
+					Name: "group-2",
+					Rules: []rulefmt.RuleNode{
+						{Alert: yaml.Node{Value: "NameMatcher"}, Expr: yaml.Node{Value: `{__name__="up", job="api"} == 0 and on(job) http_requests_errors_total > 0`}},
+						{Record: yaml.Node{Value: "string"}, Expr: yaml.Node{Value: `label_replace(vector(1), "name", "up", "", "")`}},
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		pattern  string
+		expected []MetricReference
+	}{
+		"metric name": {
+			pattern: "up",
+			expected: []MetricReference{
+				{Namespace: "namespace", Group: "group-1", Rule: "job:up:sum", Expr: `sum by(job) (up)`, Metrics: []string{"up"}},
+				{Namespace: "namespace", Group: "group-2", Rule: "NameMatcher", Expr: `{__name__="up", job="api"} == 0 and on(job) http_requests_errors_total > 0`, Metrics: []string{"up"}},
+			},
+		},
+		"regular expression": {
+			pattern: "http_requests_.*",
+			expected: []MetricReference{
+				{Namespace: "namespace", Group: "group-1", Rule: "job:requests:rate5m", Expr: `sum by(job) (rate(http_requests_total[5m])) / sum by(job) (rate(http_requests_total{code="500"}[5m]))`, Metrics: []string{"http_requests_total"}},
+				{Namespace: "namespace", Group: "group-2", Rule: "NameMatcher", Expr: `{__name__="up", job="api"} == 0 and on(job) http_requests_errors_total > 0`, Metrics: []string{"http_requests_errors_total"}},
+			},
+		},
+		"partial match": {
+			pattern: "http_requests",
+		},
+		"no match": {
+			pattern: "not_existing",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			refs, err := ns.FindMetricReferences(regexp.MustCompile("^(?:" + tc.pattern + ")$"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, refs)
+		})
+	}
+
+	t.Run("invalid expression", func(t *testing.T) {
+		invalid := RuleNamespace{Groups: []rwrulefmt.RuleGroup{{RuleGroup: rulefmt.RuleGroup{
+			Name:  "group",
+			Rules: []rulefmt.RuleNode{{Record: yaml.Node{Value: "invalid"}, Expr: yaml.Node{Value: `sum(`}}},
+		}}}}
+		_, err := invalid.FindMetricReferences(regexp.MustCompile("up"))
+		require.Error(t, err)
+	})
+}
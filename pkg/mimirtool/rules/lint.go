@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"bytes"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// LintFileExpressions formats the rule expressions in the content of a rule file, like LintExpressions
+// does, without changing anything else in the file. Unlike re-marshalling the parsed namespaces, the
+// order of the keys and the comments are preserved. The input content is returned as is when no
+// expression has been changed.
+func LintFileExpressions(backend string, content []byte) ([]byte, int, int, error) {
+	queryLanguage, parseFn, err := expressionParser(backend)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	var (
+		docs       []*yaml.Node
+		count, mod int
+	)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, count, mod, err
+		}
+		docs = append(docs, &doc)
+
+		for _, rule := range ruleNodes(&doc) {
+			expr := mappingValue(rule, "expr")
+			if expr == nil {
+				continue
+			}
+
+			name := ruleNodeName(rule)
+			log.WithFields(log.Fields{"rule": name}).Debugf("linting %s", queryLanguage)
+			exp, err := parseFn(expr.Value)
+			if err != nil {
+				return nil, count, mod, err
+			}
+
+			count++
+			if expr.Value != exp.String() {
+				log.WithFields(log.Fields{
+					"rule":        name,
+					"currentExpr": expr.Value,
+					"afterExpr":   exp.String(),
+				}).Debugf("expression differs")
+
+				mod++
+				expr.Value = exp.String()
+				// The formatted expression is on a single line, so block styles are not needed anymore.
+				if expr.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+					expr.Style = 0
+				}
+			}
+		}
+	}
+
+	if mod == 0 {
+		return content, count, mod, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return nil, count, mod, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, count, mod, err
+	}
+
+	return buf.Bytes(), count, mod, nil
+}
+
+// ruleNodes returns the nodes of the rules of all the rule groups in the namespace document.
+func ruleNodes(doc *yaml.Node) []*yaml.Node {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	groups := mappingValue(node, "groups")
+	if groups == nil || groups.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	for _, group := range groups.Content {
+		rules := mappingValue(group, "rules")
+		if rules == nil || rules.Kind != yaml.SequenceNode {
+			continue
+		}
+		nodes = append(nodes, rules.Content...)
+	}
+	return nodes
+}
+
+// ruleNodeName returns the name of the alerting or recording rule node.
+func ruleNodeName(rule *yaml.Node) string {
+	if alert := mappingValue(rule, "alert"); alert != nil {
+		return alert.Value
+	}
+	if record := mappingValue(rule, "record"); record != nil {
+		return record.Value
+	}
+	return ""
+}
+
+// mappingValue returns the value of the key in the mapping node, or nil if not found.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
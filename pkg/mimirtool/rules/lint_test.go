@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintFileExpressions(t *testing.T) {
+	tt := []struct {
+		name            string
+		content         string
+		expected        string
+		err             string
+		count, modified int
+	}{
+		{
+			name: "it lints expressions preserving comments and keys order",
+			content: `# The namespace of the rules.
+namespace: example
+groups:
+  # Rules about the API.
+  - name: api
+    rules:
+      - record: job:http_requests:rate5m
+        # Request rate by job.
+        expr: sum(rate(http_requests_total[5m]))   by (job)
+        labels:
+          team: api # Owner.
+      - expr: |
+          up
+            == 0
+        alert: InstanceDown
+        for: 5m
+---
+namespace: other
+groups:
+  - name: other
+    rules:
+      - record: other:up
+        expr: "up  ==  1"
+`,
+			expected: `# The namespace of the rules.
+namespace: example
+groups:
+  # Rules about the API.
+  - name: api
+    rules:
+      - record: job:http_requests:rate5m
+        # Request rate by job.
+        expr: sum by(job) (rate(http_requests_total[5m]))
+        labels:
+          team: api # Owner.
+      - expr: up == 0
+        alert: InstanceDown
+        for: 5m
+---
+namespace: other
+groups:
+  - name: other
+    rules:
+      - record: other:up
+        expr: "up == 1"
+`,
+			count:    3,
+			modified: 3,
+		},
+		{
+			name: "it returns the content as is if no expression is linted",
+			content: `groups:
+    - name: api
+      rules:
+        - record: job:up:sum
+          expr: sum by(job) (up) # Comment.
+`,
+			expected: `groups:
+    - name: api
+      rules:
+        - record: job:up:sum
+          expr: sum by(job) (up) # Comment.
+`,
+			count:    1,
+			modified: 0,
+		},
+		{
+			name: "with an invalid expression",
+			content: `groups:
+  - name: api
+    rules:
+      - record: job:up:sum
+        expr: it fails
+`,
+			err: "1:4: parse error: unexpected identifier \"fails\"",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, count, modified, err := LintFileExpressions(MimirBackend, []byte(tc.content))
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(actual))
+			assert.Equal(t, tc.count, count)
+			assert.Equal(t, tc.modified, modified)
+		})
+	}
+}
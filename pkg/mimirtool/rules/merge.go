@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+)
+
+// MergeWarning reports a rule group which is semantically identical to a rule group defined in another file.
+type MergeWarning struct {
+	Namespace string
+	Group     string
+	File      string
+
+	IdenticalNamespace string
+	IdenticalGroup     string
+	IdenticalFile      string
+}
+
+func (w MergeWarning) String() string {
+	return fmt.Sprintf("rule group %q in namespace %q (%s) is identical to rule group %q in namespace %q (%s)",
+		w.Group, w.Namespace, w.File, w.IdenticalGroup, w.IdenticalNamespace, w.IdenticalFile)
+}
+
+// mergedRuleGroup is a rule group merged from a rule file.
+type mergedRuleGroup struct {
+	namespace string
+	file      string
+	group     rwrulefmt.RuleGroup
+}
+
+// MergeFiles parses the rule files and merges their rule groups by namespace, so that the rule groups
+// of the same namespace can be spread across multiple files. It's an error if a rule group with the
+// same name is defined multiple times in a namespace. Rule groups defined in different files whose
+// rules are identical are reported as warnings. The merged namespaces are sorted by name, and their
+// rule groups are in the order they're defined in the files.
+func MergeFiles(backend string, files []string) ([]RuleNamespace, []MergeWarning, error) {
+	var (
+		merged   = map[string]*RuleNamespace{}
+		groups   []mergedRuleGroup
+		warnings []MergeWarning
+	)
+
+	for _, f := range files {
+		nss, err := ParseFiles(backend, []string{f})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, name := range sortedNamespaces(nss) {
+			ns := merged[name]
+			if ns == nil {
+				ns = &RuleNamespace{Namespace: name}
+				merged[name] = ns
+			}
+
+			for _, group := range nss[name].Groups {
+				for _, other := range groups {
+					if other.namespace == name && other.group.Name == group.Name {
+						return nil, nil, fmt.Errorf("rule group %q in namespace %q is defined in both %s and %s", group.Name, name, other.file, f)
+					}
+
+					if other.file != f && identicalRuleGroups(other.group, group) {
+						warnings = append(warnings, MergeWarning{
+							Namespace:          name,
+							Group:              group.Name,
+							File:               f,
+							IdenticalNamespace: other.namespace,
+							IdenticalGroup:     other.group.Name,
+							IdenticalFile:      other.file,
+						})
+					}
+				}
+
+				ns.Groups = append(ns.Groups, group)
+				groups = append(groups, mergedRuleGroup{namespace: name, file: f, group: group})
+			}
+		}
+	}
+
+	result := make([]RuleNamespace, 0, len(merged))
+	for _, name := range sortedNamespaces(merged) {
+		result = append(result, *merged[name])
+	}
+	return result, warnings, nil
+}
+
+// identicalRuleGroups returns whether the input rule groups are identical, regardless of their name.
+func identicalRuleGroups(a, b rwrulefmt.RuleGroup) bool {
+	b.Name = a.Name
+	return CompareGroups(a, b) == nil
+}
+
+func sortedNamespaces[T any](nss map[string]T) []string {
+	names := make([]string, 0, len(nss))
+	for name := range nss {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writeRuleFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	first := writeRuleFile("first.yaml", `
+namespace: team-a
+groups:
+- name: group-1
+  rules:
+  - record: job:up:sum
+    expr: sum by(job) (up)
+---
+namespace: team-b
+groups:
+- name: group-1
+  rules:
+  - alert: Down
+    expr: up == 0
+`)
+	second := writeRuleFile("second.yaml", `
+namespace: team-a
+groups:
+- name: group-2
+  rules:
+  - record: job:up:sum
+    expr: sum by(job) (up)
+- name: group-3
+  rules:
+  - record: job:requests:rate5m
+    expr: sum by(job) (rate(http_requests_total[5m]))
+`)
+	// The namespace defaults to the file name, like when loading the rules.
+	third := writeRuleFile("team-c.yaml", `
+groups:
+- name: group-1
+  rules:
+  - alert: Down
+    expr: up == 0
+`)
+	collision := writeRuleFile("collision.yaml", `
+namespace: team-b
+groups:
+- name: group-1
+  rules:
+  - alert: Other
+    expr: up == 1
+`)
+
+	t.Run("should merge the rule groups of the same namespace across files", func(t *testing.T) {
+		nss, warnings, err := MergeFiles(MimirBackend, []string{first, second, third})
+		require.NoError(t, err)
+
+		require.Len(t, nss, 3)
+		assert.Equal(t, "team-a", nss[0].Namespace)
+		assert.Equal(t, "team-b", nss[1].Namespace)
+		assert.Equal(t, "team-c", nss[2].Namespace)
+
+		var groups []string
+		for _, g := range nss[0].Groups {
+			groups = append(groups, g.Name)
+		}
+		assert.Equal(t, []string{"group-1", "group-2", "group-3"}, groups)
+
+		// The rule groups with the same rules in different files are reported.
+		assert.Equal(t, []MergeWarning{
+			{
+				Namespace: "team-a", Group: "group-2", File: second,
+				IdenticalNamespace: "team-a", IdenticalGroup: "group-1", IdenticalFile: first,
+			},
+			{
+				Namespace: "team-c", Group: "group-1", File: third,
+				IdenticalNamespace: "team-b", IdenticalGroup: "group-1", IdenticalFile: first,
+			},
+		}, warnings)
+	})
+
+	t.Run("should fail if a rule group name is defined multiple times in a namespace", func(t *testing.T) {
+		_, _, err := MergeFiles(MimirBackend, []string{first, collision})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `rule group "group-1" in namespace "team-b" is defined in both`)
+	})
+}
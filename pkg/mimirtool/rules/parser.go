@@ -19,11 +19,17 @@ import (
 
 const (
 	MimirBackend = "mimir"
+
+	// StdinFile is the rule file meaning that the rules are read from the standard input.
+	StdinFile = "-"
 )
 
 var (
 	errFileReadError  = errors.New("file read error")
 	errInvalidBackend = errors.New("invalid backend type")
+
+	// ErrMissingNamespace is returned when parsing rules which don't set the namespace, and no default is provided.
+	ErrMissingNamespace = errors.New("the namespace is neither set in the rules nor provided")
 )
 
 // ParseFiles returns a formatted set of prometheus rule groups
@@ -44,31 +50,73 @@ func ParseFiles(backend string, files []string) (map[string]RuleNamespace, error
 			return nil, errFileReadError
 		}
 
-		for _, ns := range nss {
-			ns.Filepath = f
-
-			// Determine if the namespace is explicitly set. If not
-			// the file name without the extension is used.
-			namespace := ns.Namespace
-			if namespace == "" {
-				namespace = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
-				ns.Namespace = namespace
-			}
-
-			_, exists := ruleSet[namespace]
-			if exists {
-				log.WithFields(log.Fields{
-					"namespace": namespace,
-					"file":      f,
-				}).Errorln("repeated namespace attempted to be loaded")
-				return nil, errFileReadError
-			}
-			ruleSet[namespace] = ns
+		// Determine if the namespace is explicitly set. If not
+		// the file name without the extension is used.
+		if err := addNamespaces(ruleSet, nss, f, strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))); err != nil {
+			return nil, err
+		}
+	}
+	return ruleSet, nil
+}
+
+// ParseReader is like ParseFiles, but parses the rules read from the reader. The filename is only
+// used for logging and as the Filepath of the namespaces. The namespaces not explicitly set in the
+// rules default to defaultNamespace, and it's an error if defaultNamespace is empty too.
+func ParseReader(backend string, reader io.Reader, filename, defaultNamespace string) (map[string]RuleNamespace, error) {
+	if backend != MimirBackend {
+		return nil, errInvalidBackend
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		log.WithError(err).WithField("file", filename).Errorln("unable load rules file")
+		return nil, errFileReadError
+	}
+
+	nss, errs := ParseBytes(content)
+	for _, err := range errs {
+		log.WithError(err).WithField("file", filename).Errorln("unable parse rules file")
+		return nil, errFileReadError
+	}
+
+	for _, ns := range nss {
+		if ns.Namespace == "" && defaultNamespace == "" {
+			return nil, ErrMissingNamespace
 		}
 	}
+
+	ruleSet := map[string]RuleNamespace{}
+	if err := addNamespaces(ruleSet, nss, filename, defaultNamespace); err != nil {
+		return nil, err
+	}
 	return ruleSet, nil
 }
 
+// addNamespaces adds the namespaces parsed from the file f to the rule set. The namespaces
+// not explicitly set are assigned the input defaultNamespace.
+func addNamespaces(ruleSet map[string]RuleNamespace, nss []RuleNamespace, f, defaultNamespace string) error {
+	for _, ns := range nss {
+		ns.Filepath = f
+
+		namespace := ns.Namespace
+		if namespace == "" {
+			namespace = defaultNamespace
+			ns.Namespace = namespace
+		}
+
+		_, exists := ruleSet[namespace]
+		if exists {
+			log.WithFields(log.Fields{
+				"namespace": namespace,
+				"file":      f,
+			}).Errorln("repeated namespace attempted to be loaded")
+			return errFileReadError
+		}
+		ruleSet[namespace] = ns
+	}
+	return nil
+}
+
 // Parse parses and validates a set of rules.
 func Parse(f string) ([]RuleNamespace, []error) {
 	content, err := loadFile(f)
@@ -6,11 +6,17 @@
 package rules
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/template"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
@@ -30,17 +36,9 @@ type RuleNamespace struct {
 // LintExpressions runs the `expr` from a rule through the PromQL or LogQL parser and
 // compares its output. If it differs from the parser, it uses the parser's instead.
 func (r RuleNamespace) LintExpressions(backend string) (int, int, error) {
-	var parseFn func(string) (fmt.Stringer, error)
-	var queryLanguage string
-
-	switch backend {
-	case MimirBackend:
-		queryLanguage = "PromQL"
-		parseFn = func(s string) (fmt.Stringer, error) {
-			return parser.ParseExpr(s)
-		}
-	default:
-		return 0, 0, errInvalidBackend
+	queryLanguage, parseFn, err := expressionParser(backend)
+	if err != nil {
+		return 0, 0, err
 	}
 
 	// `count` represents the number of rules we evalated.
@@ -71,6 +69,18 @@ func (r RuleNamespace) LintExpressions(backend string) (int, int, error) {
 	return count, mod, nil
 }
 
+// expressionParser returns the query language and the parser of the rule expressions of the backend.
+func expressionParser(backend string) (string, func(string) (fmt.Stringer, error), error) {
+	switch backend {
+	case MimirBackend:
+		return "PromQL", func(s string) (fmt.Stringer, error) {
+			return parser.ParseExpr(s)
+		}, nil
+	default:
+		return "", nil, errInvalidBackend
+	}
+}
+
 // CheckRecordingRules checks that recording rules have at least one colon in their name, this is based
 // on the recording rules best practices here: https://prometheus.io/docs/practices/rules/
 // Returns the number of rules that don't match the requirements.
@@ -104,6 +114,85 @@ func (r RuleNamespace) CheckRecordingRules(strict bool) int {
 	return count
 }
 
+// CheckAlertTemplates checks that the labels and annotations of alerting rules are valid templates, parsing
+// them like Prometheus does when evaluating the rules. The invalid templates are logged as errors if strict
+// is true, or as warnings otherwise. Returns the number of invalid templates.
+func (r RuleNamespace) CheckAlertTemplates(strict bool) int {
+	var count int
+	for _, group := range r.Groups {
+		for _, rule := range group.Rules {
+			for _, err := range checkAlertTemplates(rule) {
+				count++
+				entry := log.WithFields(log.Fields{
+					"rule":      rule.Alert.Value,
+					"ruleGroup": group.Name,
+					"file":      r.Filepath,
+					"error":     err.Error(),
+				})
+				if strict {
+					entry.Errorf("bad alert template")
+				} else {
+					entry.Warnf("bad alert template")
+				}
+			}
+		}
+	}
+	return count
+}
+
+// checkAlertTemplates parses the labels and annotations of the alerting rule as templates, with the same
+// variables Prometheus defines when expanding them, and returns the parsing errors. Recording rules are skipped.
+func checkAlertTemplates(rule rulefmt.RuleNode) []error {
+	if rule.Alert.Value == "" {
+		return nil
+	}
+	log.WithFields(log.Fields{"rule": rule.Alert.Value}).Debugf("checking alert templates")
+
+	defs := []string{
+		"{{$labels := .Labels}}",
+		"{{$externalLabels := .ExternalLabels}}",
+		"{{$externalURL := .ExternalURL}}",
+		"{{$value := .Value}}",
+	}
+	data := template.AlertTemplateData(map[string]string{}, map[string]string{}, "", 0)
+
+	parse := func(text string) error {
+		expander := template.NewTemplateExpander(
+			context.Background(),
+			strings.Join(append(defs, text), ""),
+			"__alert_"+rule.Alert.Value,
+			data,
+			model.Time(timestamp.FromTime(time.Now())),
+			nil,
+			nil,
+			nil,
+		)
+		return expander.ParseTest()
+	}
+
+	var errs []error
+	for _, k := range sortedKeys(rule.Labels) {
+		if err := parse(rule.Labels[k]); err != nil {
+			errs = append(errs, fmt.Errorf("label %q: %w", k, err))
+		}
+	}
+	for _, k := range sortedKeys(rule.Annotations) {
+		if err := parse(rule.Annotations[k]); err != nil {
+			errs = append(errs, fmt.Errorf("annotation %q: %w", k, err))
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // AggregateBy modifies the aggregation rules in groups to include a given Label.
 // If the applyTo function is provided, the aggregation is applied only to rules
 // for which the applyTo function returns true.
@@ -154,6 +243,42 @@ func (r RuleNamespace) AggregateBy(label string, applyTo func(group rwrulefmt.Ru
 	return count, mod, nil
 }
 
+// AggregateByLabels modifies the aggregation rules in groups to include each of the given labels,
+// applying them in sequence. It returns the number of rules evaluated and the number of rules whose
+// expression has been modified, counting each rule once even if modified for multiple labels.
+func (r RuleNamespace) AggregateByLabels(labels []string, applyTo func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool) (int, int, error) {
+	original := make([][]string, len(r.Groups))
+	for i, group := range r.Groups {
+		for _, rule := range group.Rules {
+			original[i] = append(original[i], rule.Expr.Value)
+		}
+	}
+
+	var count int
+	for i, label := range labels {
+		c, _, err := r.AggregateBy(label, applyTo)
+		if err != nil {
+			return c, 0, err
+		}
+
+		// The same rules are evaluated for each label.
+		if i == 0 {
+			count = c
+		}
+	}
+
+	var mod int
+	for i, group := range r.Groups {
+		for j, rule := range group.Rules {
+			if rule.Expr.Value != original[i][j] {
+				mod++
+			}
+		}
+	}
+
+	return count, mod, nil
+}
+
 // exprNodeInspectorFunc returns a PromQL inspector.
 // It modifies most PromQL expressions to include a given label.
 func exprNodeInspectorFunc(rule rulefmt.RuleNode, label string) func(node parser.Node, path []parser.Node) error {
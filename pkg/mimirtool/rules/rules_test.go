@@ -209,6 +209,106 @@ func TestAggregateBy(t *testing.T) {
 	}
 }
 
+func TestAggregateByLabels(t *testing.T) {
+	rn := func() RuleNamespace {
+		return RuleNamespace{
+			Groups: []rwrulefmt.RuleGroup{
+				{
+					RuleGroup: rulefmt.RuleGroup{
+						Name: "WithAggregation",
+						Rules: []rulefmt.RuleNode{
+							{Record: yaml.Node{Value: "no_labels"}, Expr: yaml.Node{Value: `sum by(namespace) (test_series)`}},
+							{Record: yaml.Node{Value: "one_label"}, Expr: yaml.Node{Value: `sum by(namespace, cluster) (test_series)`}},
+							{Record: yaml.Node{Value: "all_labels"}, Expr: yaml.Node{Value: `sum by(namespace, cluster, region) (test_series)`}},
+							{Record: yaml.Node{Value: "no_aggregation"}, Expr: yaml.Node{Value: `test_series`}},
+						},
+					},
+				},
+				{
+					RuleGroup: rulefmt.RuleGroup{
+						Name: "Skipped",
+						Rules: []rulefmt.RuleNode{
+							{Record: yaml.Node{Value: "skipped"}, Expr: yaml.Node{Value: `sum by(namespace) (test_series)`}},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	applyTo := func(group rwrulefmt.RuleGroup, rule rulefmt.RuleNode) bool {
+		return group.Name != "Skipped"
+	}
+
+	tests := map[string]struct {
+		labels          []string
+		expectedExpr    []string
+		count, modified int
+	}{
+		"single label": {
+			labels: []string{"cluster"},
+			expectedExpr: []string{
+				`sum by(namespace, cluster) (test_series)`,
+				`sum by(namespace, cluster) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`test_series`,
+				`sum by(namespace) (test_series)`,
+			},
+			count: 5, modified: 1,
+		},
+		"multiple labels": {
+			labels: []string{"cluster", "region"},
+			expectedExpr: []string{
+				`sum by(namespace, cluster, region) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`test_series`,
+				`sum by(namespace) (test_series)`,
+			},
+			count: 5, modified: 2,
+		},
+		"repeated labels": {
+			labels: []string{"region", "region"},
+			expectedExpr: []string{
+				`sum by(namespace, region) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`test_series`,
+				`sum by(namespace) (test_series)`,
+			},
+			count: 5, modified: 2,
+		},
+		"no labels": {
+			expectedExpr: []string{
+				`sum by(namespace) (test_series)`,
+				`sum by(namespace, cluster) (test_series)`,
+				`sum by(namespace, cluster, region) (test_series)`,
+				`test_series`,
+				`sum by(namespace) (test_series)`,
+			},
+			count: 0, modified: 0,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ns := rn()
+			c, m, err := ns.AggregateByLabels(tc.labels, applyTo)
+			require.NoError(t, err)
+			assert.Equal(t, tc.count, c)
+			assert.Equal(t, tc.modified, m)
+
+			var actualExpr []string
+			for _, g := range ns.Groups {
+				for _, r := range g.Rules {
+					actualExpr = append(actualExpr, r.Expr.Value)
+				}
+			}
+			assert.Equal(t, tc.expectedExpr, actualExpr)
+		})
+	}
+}
+
 func TestLintExpressions(t *testing.T) {
 	tt := []struct {
 		name            string
@@ -345,3 +445,78 @@ func TestCheckRecordingRules(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckAlertTemplates(t *testing.T) {
+	tt := []struct {
+		name        string
+		rule        rulefmt.RuleNode
+		count       int
+		expectedErr []string
+	}{
+		{
+			name: "valid templates",
+			rule: rulefmt.RuleNode{
+				Alert:       yaml.Node{Value: "HighErrorRate"},
+				Expr:        yaml.Node{Value: "rate(errors_total[5m]) > 1"},
+				Labels:      map[string]string{"severity": "{{ if gt $value 10.0 }}critical{{ else }}warning{{ end }}"},
+				Annotations: map[string]string{"summary": "{{ $labels.job }} has {{ $value | humanize }} errors per second"},
+			},
+			count: 0,
+		},
+		{
+			name: "malformed annotation template",
+			rule: rulefmt.RuleNode{
+				Alert:       yaml.Node{Value: "HighErrorRate"},
+				Expr:        yaml.Node{Value: "rate(errors_total[5m]) > 1"},
+				Annotations: map[string]string{"summary": "{{ $value | humanize }", "description": "{{ $labels.job }}"},
+			},
+			count:       1,
+			expectedErr: []string{`annotation "summary":`},
+		},
+		{
+			name: "malformed label and annotation templates",
+			rule: rulefmt.RuleNode{
+				Alert:       yaml.Node{Value: "HighErrorRate"},
+				Expr:        yaml.Node{Value: "rate(errors_total[5m]) > 1"},
+				Labels:      map[string]string{"severity": "{{ if $value }}critical"},
+				Annotations: map[string]string{"summary": "{{ $undefined }}"},
+			},
+			count:       2,
+			expectedErr: []string{`label "severity":`, `annotation "summary":`},
+		},
+		{
+			name: "recording rule",
+			rule: rulefmt.RuleNode{
+				Record: yaml.Node{Value: "job:errors:rate5m"},
+				Expr:   yaml.Node{Value: "rate(errors_total[5m])"},
+				Labels: map[string]string{"invalid": "{{ $value | humanize }"},
+			},
+			count: 0,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := checkAlertTemplates(tc.rule)
+			require.Len(t, errs, len(tc.expectedErr))
+			for i, expected := range tc.expectedErr {
+				require.Contains(t, errs[i].Error(), expected)
+			}
+
+			r := RuleNamespace{
+				Groups: []rwrulefmt.RuleGroup{
+					{
+						RuleGroup: rulefmt.RuleGroup{
+							Name:  "group",
+							Rules: []rulefmt.RuleNode{tc.rule},
+						},
+					},
+				},
+			}
+
+			// The number of invalid templates doesn't depend on the strict mode, only their logging does.
+			require.Equal(t, tc.count, r.CheckAlertTemplates(false))
+			require.Equal(t, tc.count, r.CheckAlertTemplates(true))
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ValidationError is an error found while validating a rule file. Group, Rule and RuleName
+// are only set when the error refers to a specific rule group or rule.
+type ValidationError struct {
+	File      string `json:"file"`
+	Line      int    `json:"line,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Group     string `json:"group,omitempty"`
+	// Rule is the 0-based index of the rule in the group.
+	Rule     *int   `json:"rule,omitempty"`
+	RuleName string `json:"rule_name,omitempty"`
+	Error    string `json:"error"`
+}
+
+// String formats the error to be human-readable.
+func (e ValidationError) String() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+	}
+	if e.Namespace != "" {
+		fmt.Fprintf(&b, ": namespace %q", e.Namespace)
+	}
+	if e.Group != "" {
+		fmt.Fprintf(&b, ", group %q", e.Group)
+	}
+	if e.Rule != nil {
+		fmt.Fprintf(&b, ", rule %d", *e.Rule)
+	}
+	if e.RuleName != "" {
+		fmt.Fprintf(&b, ", %q", e.RuleName)
+	}
+	fmt.Fprintf(&b, ": %s", e.Error)
+	return b.String()
+}
+
+// ValidateFiles validates the rule files without stopping at the first error, and returns
+// all the errors found. Files are validated the same way ParseFiles does, plus some sanity
+// checks on the durations configured in rule groups and rules.
+func ValidateFiles(files []string) []ValidationError {
+	var errs []ValidationError
+	namespaces := map[string]string{}
+
+	for _, f := range files {
+		content, err := loadFile(f)
+		if err != nil {
+			errs = append(errs, ValidationError{File: f, Error: err.Error()})
+			continue
+		}
+
+		nss, fileErrs := validateBytes(f, content)
+		errs = append(errs, fileErrs...)
+
+		for _, ns := range nss {
+			if other, exists := namespaces[ns.Namespace]; exists {
+				errs = append(errs, ValidationError{
+					File:      f,
+					Namespace: ns.Namespace,
+					Error:     fmt.Sprintf("namespace is repeated, it's also defined in %s", other),
+				})
+				continue
+			}
+			namespaces[ns.Namespace] = f
+		}
+	}
+
+	return errs
+}
+
+func validateBytes(f string, content []byte) ([]RuleNamespace, []ValidationError) {
+	// The content is decoded twice: as nodes, to get the position of the rule groups,
+	// and as namespaces, failing on unknown fields like ParseFiles does.
+	nodeDecoder := yaml.NewDecoder(bytes.NewReader(content))
+	nsDecoder := yaml.NewDecoder(bytes.NewReader(content))
+	nsDecoder.KnownFields(true)
+
+	var (
+		nss  []RuleNamespace
+		errs []ValidationError
+	)
+	for {
+		var node yaml.Node
+		err := nodeDecoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The decoder can't continue after a syntax error.
+			return nss, append(errs, ValidationError{File: f, Error: err.Error()})
+		}
+
+		durationErrs := validateDurations(f, &node)
+		errs = append(errs, durationErrs...)
+
+		var ns RuleNamespace
+		if err := nsDecoder.Decode(&ns); err != nil {
+			// The error already includes the position of the invalid fields, except for the invalid
+			// durations, which have already been reported with their position.
+			if len(durationErrs) == 0 {
+				errs = append(errs, ValidationError{File: f, Error: err.Error()})
+			}
+			continue
+		}
+
+		// The namespace defaults to the file name, like in ParseFiles.
+		if ns.Namespace == "" {
+			ns.Namespace = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		}
+
+		errs = append(errs, validateNamespace(f, ns, groupLines(&node))...)
+		nss = append(nss, ns)
+	}
+
+	return nss, errs
+}
+
+func validateNamespace(f string, ns RuleNamespace, lines []int) []ValidationError {
+	var errs []ValidationError
+	groups := map[string]struct{}{}
+
+	for i, g := range ns.Groups {
+		newGroupError := func(line int, err error) ValidationError {
+			return ValidationError{File: f, Line: line, Namespace: ns.Namespace, Group: g.Name, Error: err.Error()}
+		}
+
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+
+		if g.Name == "" {
+			errs = append(errs, newGroupError(line, fmt.Errorf("group name must not be empty")))
+		} else if _, exists := groups[g.Name]; exists {
+			errs = append(errs, newGroupError(line, fmt.Errorf("group name is repeated in the same namespace")))
+		}
+		groups[g.Name] = struct{}{}
+
+		if g.Limit < 0 {
+			errs = append(errs, newGroupError(line, fmt.Errorf("limit must not be negative")))
+		}
+
+		for j := range g.Rules {
+			errs = append(errs, validateRule(f, ns.Namespace, g.RuleGroup, j)...)
+		}
+	}
+
+	return errs
+}
+
+func validateRule(f, namespace string, g rulefmt.RuleGroup, idx int) []ValidationError {
+	var errs []ValidationError
+	r := g.Rules[idx]
+
+	line := r.Expr.Line
+	if r.Alert.Line > 0 {
+		line = r.Alert.Line
+	} else if r.Record.Line > 0 {
+		line = r.Record.Line
+	}
+
+	newRuleError := func(err error) ValidationError {
+		return ValidationError{File: f, Line: line, Namespace: namespace, Group: g.Name, Rule: &idx, RuleName: getRuleName(r), Error: err.Error()}
+	}
+
+	for _, wrapped := range r.Validate() {
+		errs = append(errs, newRuleError(wrapped.Unwrap()))
+	}
+
+	// The alert is evaluated once per interval, so a shorter 'for' duration is effectively the interval.
+	if r.Alert.Value != "" && r.For > 0 && g.Interval > 0 && r.For < g.Interval {
+		errs = append(errs, newRuleError(fmt.Errorf("'for' duration (%s) should not be shorter than the evaluation interval (%s)", r.For, g.Interval)))
+	}
+
+	return errs
+}
+
+// validateDurations validates the durations in the namespace document. The errors decoding the
+// namespace don't report the position of the invalid durations, and stop at the first one.
+func validateDurations(f string, doc *yaml.Node) []ValidationError {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	namespace := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	if n := mappingValue(node, "namespace"); n != nil && n.Value != "" {
+		namespace = n.Value
+	}
+
+	groups := mappingValue(node, "groups")
+	if groups == nil || groups.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	var errs []ValidationError
+	for _, group := range groups.Content {
+		var groupName string
+		if n := mappingValue(group, "name"); n != nil {
+			groupName = n.Value
+		}
+
+		for _, key := range []string{"interval", "evaluation_delay"} {
+			if d := mappingValue(group, key); d != nil && !validDuration(d) {
+				errs = append(errs, ValidationError{File: f, Line: d.Line, Namespace: namespace, Group: groupName, Error: fmt.Sprintf("invalid %s: %q is not a valid duration", key, d.Value)})
+			}
+		}
+
+		rules := mappingValue(group, "rules")
+		if rules == nil || rules.Kind != yaml.SequenceNode {
+			continue
+		}
+		for i, rule := range rules.Content {
+			if d := mappingValue(rule, "for"); d != nil && !validDuration(d) {
+				idx := i
+				errs = append(errs, ValidationError{File: f, Line: d.Line, Namespace: namespace, Group: groupName, Rule: &idx, RuleName: ruleNodeName(rule), Error: fmt.Sprintf("invalid 'for' duration: %q is not a valid duration", d.Value)})
+			}
+		}
+	}
+	return errs
+}
+
+// validDuration returns whether the node is a valid duration, or null.
+func validDuration(node *yaml.Node) bool {
+	if node.ShortTag() == "!!null" {
+		return true
+	}
+	_, err := model.ParseDuration(node.Value)
+	return err == nil
+}
+
+// groupLines returns the line of each rule group in the namespace document.
+func groupLines(doc *yaml.Node) []int {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != "groups" {
+			continue
+		}
+
+		var lines []int
+		for _, g := range node.Content[i+1].Content {
+			lines = append(lines, g.Line)
+		}
+		return lines
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFiles(t *testing.T) {
+	ruleIdx := func(i int) *int { return &i }
+
+	tests := map[string]struct {
+		files    []string
+		expected []ValidationError
+	}{
+		"valid file": {
+			files: []string{"testdata/basic_namespace.yaml"},
+		},
+		"valid files with multiple namespaces": {
+			files: []string{"testdata/multiple_namespace.yaml"},
+		},
+		"repeated namespace": {
+			files: []string{"testdata/basic_namespace.yaml", "testdata/basic_namespace_repeated.yaml"},
+			expected: []ValidationError{
+				{File: "testdata/basic_namespace_repeated.yaml", Namespace: "example_namespace", Error: "namespace is repeated, it's also defined in testdata/basic_namespace.yaml"},
+			},
+		},
+		"invalid rules": {
+			files: []string{"testdata/invalid_rules.yaml"},
+			expected: []ValidationError{
+				{File: "testdata/invalid_rules.yaml", Line: 8, Namespace: "invalid_namespace", Group: "example_rule_group", Rule: ruleIdx(1), RuleName: "invalid-name", Error: "invalid recording rule name: invalid-name"},
+				{File: "testdata/invalid_rules.yaml", Line: 10, Namespace: "invalid_namespace", Group: "example_rule_group", Rule: ruleIdx(2), RuleName: "InvalidExpression", Error: "could not parse expression: 1:6: parse error: unexpected end of input"},
+				{File: "testdata/invalid_rules.yaml", Line: 12, Namespace: "invalid_namespace", Group: "example_rule_group", Rule: ruleIdx(3), RuleName: "ShortFor", Error: "'for' duration (30s) should not be shorter than the evaluation interval (1m)"},
+				{File: "testdata/invalid_rules.yaml", Line: 15, Namespace: "invalid_namespace", Group: "example_rule_group", Error: "group name is repeated in the same namespace"},
+				{File: "testdata/invalid_rules.yaml", Line: 15, Namespace: "invalid_namespace", Group: "example_rule_group", Error: "limit must not be negative"},
+				{File: "testdata/invalid_rules.yaml", Line: 18, Namespace: "invalid_namespace", Group: "example_rule_group", Rule: ruleIdx(0), RuleName: "InvalidLabel", Error: "invalid label name: invalid-label"},
+			},
+		},
+		"invalid durations": {
+			files: []string{"testdata/invalid_durations.yaml"},
+			expected: []ValidationError{
+				{File: "testdata/invalid_durations.yaml", Line: 4, Namespace: "invalid_durations", Group: "example_rule_group", Error: "invalid interval: \"1\" is not a valid duration"},
+				{File: "testdata/invalid_durations.yaml", Line: 8, Namespace: "invalid_durations", Group: "example_rule_group", Rule: ruleIdx(0), RuleName: "MissingUnit", Error: "invalid 'for' duration: \"5\" is not a valid duration"},
+			},
+		},
+		"unknown field": {
+			files: []string{"testdata/unknown_field.yaml"},
+			expected: []ValidationError{
+				{File: "testdata/unknown_field.yaml", Error: "yaml: unmarshal errors:\n  line 4: field unknown not found in type rwrulefmt.RuleGroup"},
+			},
+		},
+		"not existing file": {
+			files: []string{"testdata/not_existing.yaml"},
+			expected: []ValidationError{
+				{File: "testdata/not_existing.yaml", Error: "open testdata/not_existing.yaml: no such file or directory"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ValidateFiles(tc.files))
+		})
+	}
+}
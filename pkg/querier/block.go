@@ -6,9 +6,12 @@
 package querier
 
 import (
+	"bytes"
 	"math"
 	"sort"
+	"strings"
 
+	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
@@ -19,6 +22,41 @@ import (
 	"github.com/grafana/mimir/pkg/storage/series"
 )
 
+// normalizeMatchers returns the input matchers sorted in a canonical order (by name, type and value)
+// and without duplicates. Matchers are duplicated only if they have the same name, type and value,
+// so the returned matchers select the same series of the input ones. The input slice is not modified.
+func normalizeMatchers(matchers []*labels.Matcher) []*labels.Matcher {
+	if len(matchers) < 2 {
+		return matchers
+	}
+
+	sorted := make([]*labels.Matcher, len(matchers))
+	copy(sorted, matchers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareMatchers(sorted[i], sorted[j]) < 0
+	})
+
+	normalized := sorted[:1]
+	for _, m := range sorted[1:] {
+		if compareMatchers(normalized[len(normalized)-1], m) != 0 {
+			normalized = append(normalized, m)
+		}
+	}
+
+	return normalized
+}
+
+func compareMatchers(a, b *labels.Matcher) int {
+	switch {
+	case a.Name != b.Name:
+		return strings.Compare(a.Name, b.Name)
+	case a.Type != b.Type:
+		return int(a.Type) - int(b.Type)
+	default:
+		return strings.Compare(a.Value, b.Value)
+	}
+}
+
 func convertMatchersToLabelMatcher(matchers []*labels.Matcher) []storepb.LabelMatcher {
 	var converted []storepb.LabelMatcher
 	for _, m := range matchers {
@@ -48,6 +86,9 @@ type blockQuerierSeriesSet struct {
 	series   []*storepb.Series
 	warnings storage.Warnings
 
+	// The IDs of the blocks the series have been fetched from, if known.
+	blocks []ulid.ULID
+
 	// next response to process
 	next int
 
@@ -90,6 +131,72 @@ func (bqss *blockQuerierSeriesSet) Warnings() storage.Warnings {
 	return bqss.warnings
 }
 
+// dedupChunksSeriesMerge is a storage.VerticalSeriesMergeFunc merging the same series fetched from multiple
+// store-gateways, eg. when a block has been queried from different store-gateways. The chunks identical to
+// other chunks of the series are dropped, instead of passing the duplicated chunks downstream. It falls back
+// to storage.ChainedSeriesMerge if any of the input series has not been fetched from store-gateways.
+func dedupChunksSeriesMerge(s ...storage.Series) storage.Series {
+	if len(s) == 0 {
+		return nil
+	}
+
+	var chunks []storepb.AggrChunk
+	for _, curr := range s {
+		bqs, ok := curr.(*blockQuerierSeries)
+		if !ok {
+			return storage.ChainedSeriesMerge(s...)
+		}
+		chunks = append(chunks, bqs.chunks...)
+	}
+
+	return newBlockQuerierSeries(s[0].Labels(), dedupChunks(chunks))
+}
+
+// dedupChunks returns the input chunks without the chunks identical to another one, covering the same time
+// range with the same data. The input slice is sorted in place and reused for the returned chunks.
+func dedupChunks(chunks []storepb.AggrChunk) []storepb.AggrChunk {
+	if len(chunks) < 2 {
+		return chunks
+	}
+
+	sort.Slice(chunks, func(i, j int) bool {
+		return compareChunks(chunks[i], chunks[j]) < 0
+	})
+
+	deduped := chunks[:1]
+	for _, c := range chunks[1:] {
+		if compareChunks(deduped[len(deduped)-1], c) != 0 {
+			deduped = append(deduped, c)
+		}
+	}
+
+	return deduped
+}
+
+func compareChunks(a, b storepb.AggrChunk) int {
+	switch {
+	case a.MinTime != b.MinTime:
+		if a.MinTime < b.MinTime {
+			return -1
+		}
+		return 1
+	case a.MaxTime != b.MaxTime:
+		if a.MaxTime < b.MaxTime {
+			return -1
+		}
+		return 1
+	default:
+		return bytes.Compare(rawChunkData(a), rawChunkData(b))
+	}
+}
+
+func rawChunkData(c storepb.AggrChunk) []byte {
+	if c.Raw == nil {
+		return nil
+	}
+	return c.Raw.Data
+}
+
 // newBlockQuerierSeries makes a new blockQuerierSeries. Input labels must be already sorted by name.
 func newBlockQuerierSeries(lbls []labels.Label, chunks []storepb.AggrChunk) *blockQuerierSeries {
 	sort.Slice(chunks, func(i, j int) bool {
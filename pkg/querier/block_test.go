@@ -23,6 +23,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 
+	"github.com/grafana/mimir/pkg/storage/series"
 	"github.com/grafana/mimir/pkg/util"
 )
 
@@ -529,3 +530,153 @@ func Benchmark_blockQuerierSeriesSet_seek(b *testing.B) {
 		}
 	}
 }
+
+func TestNormalizeMatchers(t *testing.T) {
+	tests := map[string]struct {
+		input    []*labels.Matcher
+		expected []*labels.Matcher
+	}{
+		"no matchers": {
+			input:    nil,
+			expected: nil,
+		},
+		"single matcher": {
+			input:    []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "a", "1")},
+			expected: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "a", "1")},
+		},
+		"should sort matchers by name, type and value": {
+			input: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchRegexp, "b", "2|3"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "2"),
+				labels.MustNewMatcher(labels.MatchEqual, "b", "1"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "1"),
+			},
+			expected: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "2"),
+				labels.MustNewMatcher(labels.MatchEqual, "b", "1"),
+				labels.MustNewMatcher(labels.MatchRegexp, "b", "2|3"),
+			},
+		},
+		"should remove identical matchers": {
+			input: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchRegexp, "b", ".+"),
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchRegexp, "b", ".+"),
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+			},
+			expected: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchRegexp, "b", ".+"),
+			},
+		},
+		"should preserve conflicting matchers": {
+			input: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "a", "2"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+			},
+			expected: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "a", "1"),
+				labels.MustNewMatcher(labels.MatchEqual, "a", "2"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "a", "1"),
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// Keep a copy of the input to check it's not modified.
+			input := append([]*labels.Matcher(nil), testData.input...)
+
+			assert.Equal(t, testData.expected, normalizeMatchers(testData.input))
+			assert.Equal(t, input, testData.input)
+		})
+	}
+}
+
+func TestDedupChunksSeriesMerge(t *testing.T) {
+	lbls := labels.FromStrings(labels.MetricName, "test")
+
+	var (
+		chunk1       = createAggrChunkWithSamples(promql.Point{T: 10, V: 1}, promql.Point{T: 20, V: 2})
+		chunk2       = createAggrChunkWithSamples(promql.Point{T: 30, V: 3}, promql.Point{T: 40, V: 4})
+		chunk2Other  = createAggrChunkWithSamples(promql.Point{T: 30, V: 5}, promql.Point{T: 40, V: 6})
+		chunk3       = createAggrChunkWithSamples(promql.Point{T: 50, V: 5})
+		chunk1Subset = createAggrChunkWithSamples(promql.Point{T: 20, V: 2})
+	)
+
+	tests := map[string]struct {
+		series         []storage.Series
+		expectedChunks []storepb.AggrChunk
+		expectedPoints []promql.Point
+	}{
+		"should drop the chunks duplicated across series": {
+			series: []storage.Series{
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk1, chunk2}),
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk1, chunk2, chunk3}),
+			},
+			expectedChunks: []storepb.AggrChunk{chunk1, chunk2, chunk3},
+			expectedPoints: []promql.Point{{T: 10, V: 1}, {T: 20, V: 2}, {T: 30, V: 3}, {T: 40, V: 4}, {T: 50, V: 5}},
+		},
+		"should keep the chunks covering the same time range with different data": {
+			series: []storage.Series{
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk2}),
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk2Other}),
+			},
+			expectedChunks: sortedChunks(chunk2, chunk2Other),
+		},
+		"should keep the partially overlapping chunks, whose overlapping samples are skipped while iterating": {
+			series: []storage.Series{
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk1}),
+				newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk1Subset}),
+			},
+			expectedChunks: []storepb.AggrChunk{chunk1, chunk1Subset},
+			expectedPoints: []promql.Point{{T: 10, V: 1}, {T: 20, V: 2}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			merged := dedupChunksSeriesMerge(testData.series...)
+			require.Equal(t, lbls, merged.Labels())
+			require.IsType(t, &blockQuerierSeries{}, merged)
+			assert.Equal(t, testData.expectedChunks, merged.(*blockQuerierSeries).chunks)
+
+			if testData.expectedPoints != nil {
+				var points []promql.Point
+				it := merged.Iterator()
+				for it.Next() {
+					ts, v := it.At()
+					points = append(points, promql.Point{T: ts, V: v})
+				}
+				require.NoError(t, it.Err())
+				assert.Equal(t, testData.expectedPoints, points)
+			}
+		})
+	}
+
+	t.Run("should fall back to the chained merge for series not fetched from store-gateways", func(t *testing.T) {
+		merged := dedupChunksSeriesMerge(
+			newBlockQuerierSeries(lbls, []storepb.AggrChunk{chunk1}),
+			series.NewConcreteSeries(lbls, []model.SamplePair{{Timestamp: 20, Value: 2}, {Timestamp: 30, Value: 3}}),
+		)
+
+		var points []promql.Point
+		it := merged.Iterator()
+		for it.Next() {
+			ts, v := it.At()
+			points = append(points, promql.Point{T: ts, V: v})
+		}
+		require.NoError(t, it.Err())
+		assert.Equal(t, []promql.Point{{T: 10, V: 1}, {T: 20, V: 2}, {T: 30, V: 3}}, points)
+	})
+}
+
+func sortedChunks(chunks ...storepb.AggrChunk) []storepb.AggrChunk {
+	sort.Slice(chunks, func(i, j int) bool {
+		return compareChunks(chunks[i], chunks[j]) < 0
+	})
+	return chunks
+}
@@ -60,7 +60,7 @@ func (c *BlocksConsistencyChecker) Check(knownBlocks bucketindex.Blocks, knownDe
 		//   on the configured retention period).
 		// - Blocks uploaded by compactor: the source blocks are marked for deletion but will continue to be
 		//   queried by queriers for a while (depends on the configured deletion marks delay).
-		if c.uploadGracePeriod > 0 && time.Since(block.GetUploadedAt()) < c.uploadGracePeriod {
+		if c.isRecentlyUploaded(block) {
 			level.Debug(c.logger).Log("msg", "block skipped from consistency check because it was uploaded recently", "block", block.ID.String(), "uploadedAt", block.GetUploadedAt().String())
 			continue
 		}
@@ -69,13 +69,9 @@ func (c *BlocksConsistencyChecker) Check(knownBlocks bucketindex.Blocks, knownDe
 		// on blocks that can't be queried because they were offloaded. For this reason, we don't run the consistency check on any block
 		// which has been marked for deletion more then "grace period" time ago. Basically, the grace period is the time
 		// we still expect a block marked for deletion to be still queried.
-		if mark := knownDeletionMarks[block.ID]; mark != nil {
-			deletionTime := time.Unix(mark.DeletionTime, 0)
-
-			if c.deletionGracePeriod > 0 && time.Since(deletionTime) > c.deletionGracePeriod {
-				level.Debug(c.logger).Log("msg", "block skipped from consistency check because it is marked for deletion", "block", block.ID.String(), "deletionTime", deletionTime.String())
-				continue
-			}
+		if mark := knownDeletionMarks[block.ID]; mark != nil && c.isDeletionGracePeriodExpired(mark) {
+			level.Debug(c.logger).Log("msg", "block skipped from consistency check because it is marked for deletion", "block", block.ID.String(), "deletionTime", time.Unix(mark.DeletionTime, 0).String())
+			continue
 		}
 
 		if _, ok := actualBlocks[block.ID]; !ok {
@@ -89,3 +85,32 @@ func (c *BlocksConsistencyChecker) Check(knownBlocks bucketindex.Blocks, knownDe
 
 	return missingBlocks
 }
+
+// ExcludedBlocks returns the IDs of the known blocks which are currently excluded from the consistency
+// check, either because they've been recently uploaded or because they've been marked for deletion
+// more than the deletion grace period ago. The exclusion is computed the same way as in Check().
+func (c *BlocksConsistencyChecker) ExcludedBlocks(knownBlocks bucketindex.Blocks, knownDeletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark) (excludedBlocks []ulid.ULID) {
+	for _, block := range knownBlocks {
+		if c.isRecentlyUploaded(block) {
+			excludedBlocks = append(excludedBlocks, block.ID)
+			continue
+		}
+
+		if mark := knownDeletionMarks[block.ID]; mark != nil && c.isDeletionGracePeriodExpired(mark) {
+			excludedBlocks = append(excludedBlocks, block.ID)
+		}
+	}
+
+	return excludedBlocks
+}
+
+// isRecentlyUploaded returns whether the block has been uploaded within the upload grace period.
+func (c *BlocksConsistencyChecker) isRecentlyUploaded(block *bucketindex.Block) bool {
+	return c.uploadGracePeriod > 0 && time.Since(block.GetUploadedAt()) < c.uploadGracePeriod
+}
+
+// isDeletionGracePeriodExpired returns whether the block has been marked for deletion more than
+// the deletion grace period ago.
+func (c *BlocksConsistencyChecker) isDeletionGracePeriodExpired(mark *bucketindex.BlockDeletionMark) bool {
+	return c.deletionGracePeriod > 0 && time.Since(time.Unix(mark.DeletionTime, 0)) > c.deletionGracePeriod
+}
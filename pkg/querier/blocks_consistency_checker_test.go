@@ -124,3 +124,73 @@ func TestBlocksConsistencyChecker_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestBlocksConsistencyChecker_ExcludedBlocks(t *testing.T) {
+	now := time.Now()
+	uploadGracePeriod := 10 * time.Minute
+	deletionGracePeriod := 5 * time.Minute
+
+	block1 := ulid.MustNew(uint64(util.TimeToMillis(now.Add(-uploadGracePeriod*2))), nil)
+	block2 := ulid.MustNew(uint64(util.TimeToMillis(now.Add(-uploadGracePeriod*3))), nil)
+	block3 := ulid.MustNew(uint64(util.TimeToMillis(now.Add(-uploadGracePeriod*4))), nil)
+
+	tests := map[string]struct {
+		uploadGracePeriod      time.Duration
+		deletionGracePeriod    time.Duration
+		knownBlocks            bucketindex.Blocks
+		knownDeletionMarks     map[ulid.ULID]*bucketindex.BlockDeletionMark
+		expectedExcludedBlocks []ulid.ULID
+	}{
+		"no known blocks": {
+			uploadGracePeriod:   uploadGracePeriod,
+			deletionGracePeriod: deletionGracePeriod,
+			knownBlocks:         bucketindex.Blocks{},
+			knownDeletionMarks:  map[ulid.ULID]*bucketindex.BlockDeletionMark{},
+		},
+		"no block excluded": {
+			uploadGracePeriod:   uploadGracePeriod,
+			deletionGracePeriod: deletionGracePeriod,
+			knownBlocks: bucketindex.Blocks{
+				{ID: block1, UploadedAt: now.Add(-time.Hour).Unix()},
+				{ID: block2, UploadedAt: now.Add(-time.Hour).Unix()},
+			},
+			knownDeletionMarks: map[ulid.ULID]*bucketindex.BlockDeletionMark{
+				block2: {DeletionTime: now.Add(-deletionGracePeriod / 2).Unix()},
+			},
+		},
+		"recently uploaded and long time ago marked for deletion blocks are excluded": {
+			uploadGracePeriod:   uploadGracePeriod,
+			deletionGracePeriod: deletionGracePeriod,
+			knownBlocks: bucketindex.Blocks{
+				{ID: block1, UploadedAt: now.Add(-uploadGracePeriod).Add(time.Minute).Unix()},
+				{ID: block2, UploadedAt: now.Add(-time.Hour).Unix()},
+				{ID: block3, UploadedAt: now.Add(-time.Hour).Unix()},
+			},
+			knownDeletionMarks: map[ulid.ULID]*bucketindex.BlockDeletionMark{
+				block3: {DeletionTime: now.Add(-deletionGracePeriod * 2).Unix()},
+			},
+			expectedExcludedBlocks: []ulid.ULID{block1, block3},
+		},
+		"no block excluded if grace periods are disabled": {
+			knownBlocks: bucketindex.Blocks{
+				{ID: block1, UploadedAt: now.Add(-uploadGracePeriod).Add(time.Minute).Unix()},
+				{ID: block2, UploadedAt: now.Add(-time.Hour).Unix()},
+			},
+			knownDeletionMarks: map[ulid.ULID]*bucketindex.BlockDeletionMark{
+				block2: {DeletionTime: now.Add(-deletionGracePeriod * 2).Unix()},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			c := NewBlocksConsistencyChecker(testData.uploadGracePeriod, testData.deletionGracePeriod, log.NewNopLogger(), reg)
+
+			assert.Equal(t, testData.expectedExcludedBlocks, c.ExcludedBlocks(testData.knownBlocks, testData.knownDeletionMarks))
+
+			// Computing the excluded blocks is not accounted as a consistency check.
+			assert.Equal(t, float64(0), testutil.ToFloat64(c.checksTotal))
+		})
+	}
+}
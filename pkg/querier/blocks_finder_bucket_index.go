@@ -76,6 +76,14 @@ func (f *BucketIndexBlocksFinder) GetBlocks(ctx context.Context, userID string,
 		return nil, nil, newBucketIndexTooOldError(idx.GetUpdatedAt(), f.cfg.MaxStalePeriod)
 	}
 
+	blocks, deletionMarks := filterIndexBlocks(idx, minT, maxT, f.cfg.IgnoreDeletionMarksDelay)
+	return blocks, deletionMarks, nil
+}
+
+// filterIndexBlocks returns the blocks in the bucket index containing samples within the range minT
+// and maxT, along with their deletion marks. Blocks marked for deletion for longer than the ignore
+// deletion marks delay are excluded.
+func filterIndexBlocks(idx *bucketindex.Index, minT, maxT int64, ignoreDeletionMarksDelay time.Duration) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark) {
 	var (
 		matchingBlocks        = map[ulid.ULID]*bucketindex.Block{}
 		matchingDeletionMarks = map[ulid.ULID]*bucketindex.BlockDeletionMark{}
@@ -97,7 +105,7 @@ func (f *BucketIndexBlocksFinder) GetBlocks(ctx context.Context, userID string,
 		}
 
 		// Exclude blocks marked for deletion. This is the same logic as Thanos IgnoreDeletionMarkFilter.
-		if time.Since(time.Unix(mark.DeletionTime, 0)).Seconds() > f.cfg.IgnoreDeletionMarksDelay.Seconds() {
+		if time.Since(time.Unix(mark.DeletionTime, 0)).Seconds() > ignoreDeletionMarksDelay.Seconds() {
 			delete(matchingBlocks, mark.ID)
 			continue
 		}
@@ -111,9 +119,18 @@ func (f *BucketIndexBlocksFinder) GetBlocks(ctx context.Context, userID string,
 		blocks = append(blocks, b)
 	}
 
-	return blocks, matchingDeletionMarks, nil
+	return blocks, matchingDeletionMarks
+}
+
+// bucketIndexTooOldError is returned when the bucket index is older than the max stale period.
+type bucketIndexTooOldError struct {
+	msg string
+}
+
+func (e bucketIndexTooOldError) Error() string {
+	return e.msg
 }
 
 func newBucketIndexTooOldError(updatedAt time.Time, maxStalePeriod time.Duration) error {
-	return errors.New(globalerror.BucketIndexTooOld.Message(fmt.Sprintf("the bucket index is too old. It was last updated at %s, which exceeds the maximum allowed staleness period of %v", updatedAt.UTC().Format(time.RFC3339Nano), maxStalePeriod)))
+	return bucketIndexTooOldError{msg: globalerror.BucketIndexTooOld.Message(fmt.Sprintf("the bucket index is too old. It was last updated at %s, which exceeds the maximum allowed staleness period of %v", updatedAt.UTC().Format(time.RFC3339Nano), maxStalePeriod))}
 }
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+type BucketIndexFallbackBlocksFinderConfig struct {
+	// MinScanInterval is the minimum time between two scans of the bucket for the same tenant.
+	MinScanInterval          time.Duration
+	IgnoreDeletionMarksDelay time.Duration
+}
+
+// BucketIndexFallbackBlocksFinder implements BlocksFinder interface and finds blocks in the bucket
+// looking up the bucket index. If the bucket index of a tenant is too old (eg. because the compactor
+// is not running), it falls back to scanning the tenant blocks in the bucket, instead of failing the
+// query. The scans are rate limited per tenant, and the blocks found by the last scan are used in between.
+//
+// Store-gateways discover the blocks to load from the same bucket index, so they don't load the blocks
+// found scanning the bucket which are not in the too old bucket index yet. Such blocks are not queried,
+// and a warning is returned for them, instead of failing the query consistency check.
+type BucketIndexFallbackBlocksFinder struct {
+	*BucketIndexBlocksFinder
+
+	cfg         BucketIndexFallbackBlocksFinderConfig
+	bkt         objstore.Bucket
+	cfgProvider bucket.TenantConfigProvider
+	logger      log.Logger
+
+	scansMx sync.Mutex
+	scans   map[string]*tenantBucketScan
+
+	fallbacks    prometheus.Counter
+	scansTotal   prometheus.Counter
+	scanFailures prometheus.Counter
+}
+
+// tenantBucketScan holds the result of the last scan of the bucket for a tenant.
+type tenantBucketScan struct {
+	mx        sync.Mutex
+	idx       *bucketindex.Index
+	err       error
+	scannedAt time.Time
+}
+
+func NewBucketIndexFallbackBlocksFinder(cfg BucketIndexFallbackBlocksFinderConfig, indexFinder *BucketIndexBlocksFinder, bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger, reg prometheus.Registerer) *BucketIndexFallbackBlocksFinder {
+	return &BucketIndexFallbackBlocksFinder{
+		BucketIndexBlocksFinder: indexFinder,
+		cfg:                     cfg,
+		bkt:                     bkt,
+		cfgProvider:             cfgProvider,
+		logger:                  logger,
+		scans:                   map[string]*tenantBucketScan{},
+		fallbacks: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_bucket_index_stale_fallbacks_total",
+			Help: "Number of blocks lookups which fell back to the blocks found scanning the bucket, because the bucket index was too old.",
+		}),
+		scansTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_bucket_index_stale_fallback_scans_total",
+			Help: "Number of tenant bucket scans run because the bucket index was too old.",
+		}),
+		scanFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_bucket_index_stale_fallback_scan_failures_total",
+			Help: "Number of tenant bucket scans, run because the bucket index was too old, which failed.",
+		}),
+	}
+}
+
+// GetBlocks implements BlocksFinder.
+func (f *BucketIndexFallbackBlocksFinder) GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	blocks, deletionMarks, _, err := f.GetBlocksWithWarnings(ctx, userID, minT, maxT)
+	return blocks, deletionMarks, err
+}
+
+// GetBlocksWithWarnings implements BlocksFinderWithWarnings. It returns a warning if, falling back to
+// the blocks found scanning the bucket, some of them have been skipped because they're not in the
+// bucket index yet, and so they're not loaded by store-gateways.
+func (f *BucketIndexFallbackBlocksFinder) GetBlocksWithWarnings(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, error) {
+	blocks, deletionMarks, err := f.BucketIndexBlocksFinder.GetBlocks(ctx, userID, minT, maxT)
+
+	var tooOldErr bucketIndexTooOldError
+	if !errors.As(err, &tooOldErr) {
+		// The bucket index is up to date again (or failed for other reasons), so the last scan is not needed anymore.
+		f.dropScan(userID)
+		return blocks, deletionMarks, nil, err
+	}
+
+	f.fallbacks.Inc()
+	level.Warn(util_log.WithUserID(userID, f.logger)).Log("msg", "falling back to the blocks found scanning the bucket because the bucket index is too old", "err", err)
+
+	// The too old bucket index is the one store-gateways load the blocks from.
+	staleIdx, err := f.loader.GetIndex(ctx, userID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to read the bucket index")
+	}
+
+	idx, err := f.getScannedIndex(ctx, userID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "failed to scan the bucket after the bucket index was found too old")
+	}
+
+	blocks, deletionMarks = filterIndexBlocks(idx, minT, maxT, f.cfg.IgnoreDeletionMarksDelay)
+
+	// Skip the blocks not in the bucket index, because they're not loaded by store-gateways
+	// and querying them would fail the consistency check.
+	indexBlocks := make(map[ulid.ULID]struct{}, len(staleIdx.Blocks))
+	for _, b := range staleIdx.Blocks {
+		indexBlocks[b.ID] = struct{}{}
+	}
+
+	var skipped []ulid.ULID
+	knownBlocks := make(bucketindex.Blocks, 0, len(blocks))
+	for _, b := range blocks {
+		if _, ok := indexBlocks[b.ID]; !ok {
+			skipped = append(skipped, b.ID)
+			delete(deletionMarks, b.ID)
+			continue
+		}
+
+		knownBlocks = append(knownBlocks, b)
+	}
+
+	var warnings storage.Warnings
+	if len(skipped) > 0 {
+		warnings = append(warnings, fmt.Errorf("%d blocks have been skipped because they're not in the bucket index yet, which is too old: %s", len(skipped), strings.Join(convertULIDsToString(skipped), " ")))
+	}
+
+	return knownBlocks, deletionMarks, warnings, nil
+}
+
+// getScannedIndex returns the blocks of the tenant found scanning the bucket. The bucket is scanned
+// again only if the last scan is older than the min scan interval, otherwise its result is returned.
+func (f *BucketIndexFallbackBlocksFinder) getScannedIndex(ctx context.Context, userID string) (*bucketindex.Index, error) {
+	f.scansMx.Lock()
+	scan, ok := f.scans[userID]
+	if !ok {
+		scan = &tenantBucketScan{}
+		f.scans[userID] = scan
+	}
+	f.scansMx.Unlock()
+
+	// Concurrent queries of the same tenant wait for the running scan, instead of starting another one.
+	scan.mx.Lock()
+	defer scan.mx.Unlock()
+
+	if !scan.scannedAt.IsZero() && time.Since(scan.scannedAt) < f.cfg.MinScanInterval {
+		return scan.idx, scan.err
+	}
+
+	f.scansTotal.Inc()
+
+	// The previous scan result, if any, is passed to only read the metadata of the new blocks.
+	idx, _, err := bucketindex.NewUpdater(f.bkt, userID, f.cfgProvider, f.logger).UpdateIndex(ctx, scan.idx)
+	if err != nil && ctx.Err() != nil {
+		// The scan has been interrupted by the query, so the next query should scan again.
+		return nil, err
+	}
+
+	scan.scannedAt = time.Now()
+	if err != nil {
+		f.scanFailures.Inc()
+		scan.err = err
+
+		// Keep serving the blocks found by the previous scan, if any.
+		if scan.idx != nil {
+			level.Warn(util_log.WithUserID(userID, f.logger)).Log("msg", "failed to scan the bucket, using the blocks found by the previous scan", "err", err)
+			scan.err = nil
+		}
+		return scan.idx, scan.err
+	}
+
+	scan.idx, scan.err = idx, nil
+	return scan.idx, nil
+}
+
+func (f *BucketIndexFallbackBlocksFinder) dropScan(userID string) {
+	f.scansMx.Lock()
+	delete(f.scans, userID)
+	f.scansMx.Unlock()
+}
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
+	"github.com/grafana/mimir/pkg/util/limiter"
+)
+
+func TestBucketIndexFallbackBlocksFinder_GetBlocks(t *testing.T) {
+	const userID = "user-1"
+
+	t.Run("should scan the bucket if the bucket index is too old", func(t *testing.T) {
+		ctx := context.Background()
+		bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		block1 := mimir_testutil.MockStorageBlock(t, bkt, userID, 10, 20)
+		block2 := mimir_testutil.MockStorageBlock(t, bkt, userID, 20, 30)
+		block3 := mimir_testutil.MockStorageBlock(t, bkt, userID, 30, 40)
+		mimir_testutil.MockStorageDeletionMark(t, bucketindex.BucketWithGlobalMarkers(bkt), userID, block2)
+
+		// The stale bucket index doesn't contain the most recent block, nor the deletion mark.
+		require.NoError(t, bucketindex.WriteIndex(ctx, bkt, userID, nil, &bucketindex.Index{
+			Version: bucketindex.IndexVersion2,
+			Blocks: bucketindex.Blocks{
+				{ID: block1.ULID, MinTime: block1.MinTime, MaxTime: block1.MaxTime},
+				{ID: block2.ULID, MinTime: block2.MinTime, MaxTime: block2.MaxTime},
+			},
+			UpdatedAt: time.Now().Add(-2 * time.Hour).Unix(),
+		}))
+
+		finder := prepareBucketIndexFallbackBlocksFinder(t, bkt)
+
+		blocks, marks, warnings, err := finder.GetBlocksWithWarnings(ctx, userID, 0, 100)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID, block2.ULID}, blocks.GetULIDs())
+		assert.Len(t, marks, 1)
+		assert.Contains(t, marks, block2.ULID)
+		assert.Equal(t, float64(1), testutil.ToFloat64(finder.fallbacks))
+		assert.Equal(t, float64(1), testutil.ToFloat64(finder.scansTotal))
+
+		// The blocks not in the bucket index are not loaded by store-gateways, so they're skipped.
+		require.Len(t, warnings, 1)
+		assert.ErrorContains(t, warnings[0], "1 blocks have been skipped because they're not in the bucket index yet")
+		assert.ErrorContains(t, warnings[0], block3.ULID.String())
+
+		// Blocks are filtered by the queried time range, like when looking up the bucket index.
+		blocks, _, warnings, err = finder.GetBlocksWithWarnings(ctx, userID, 0, 25)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID, block2.ULID}, blocks.GetULIDs())
+		assert.Empty(t, warnings)
+		assert.Equal(t, float64(1), testutil.ToFloat64(finder.scansTotal))
+	})
+
+	t.Run("should use the blocks found by the last scan until the min scan interval expires", func(t *testing.T) {
+		ctx := context.Background()
+		bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		block1 := mimir_testutil.MockStorageBlock(t, bkt, userID, 10, 20)
+		require.NoError(t, bucketindex.WriteIndex(ctx, bkt, userID, nil, &bucketindex.Index{
+			Version:   bucketindex.IndexVersion2,
+			Blocks:    bucketindex.Blocks{{ID: block1.ULID, MinTime: block1.MinTime, MaxTime: block1.MaxTime}},
+			UpdatedAt: time.Now().Add(-2 * time.Hour).Unix(),
+		}))
+
+		finder := prepareBucketIndexFallbackBlocksFinder(t, bkt)
+
+		blocks, _, warnings, err := finder.GetBlocksWithWarnings(ctx, userID, 0, 100)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID}, blocks.GetULIDs())
+		assert.Empty(t, warnings)
+
+		// A new block is not found until the min scan interval expires.
+		block2 := mimir_testutil.MockStorageBlock(t, bkt, userID, 20, 30)
+
+		blocks, _, warnings, err = finder.GetBlocksWithWarnings(ctx, userID, 0, 100)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID}, blocks.GetULIDs())
+		assert.Empty(t, warnings)
+		assert.Equal(t, float64(2), testutil.ToFloat64(finder.fallbacks))
+		assert.Equal(t, float64(1), testutil.ToFloat64(finder.scansTotal))
+
+		finder.cfg.MinScanInterval = 0
+
+		blocks, _, warnings, err = finder.GetBlocksWithWarnings(ctx, userID, 0, 100)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID}, blocks.GetULIDs())
+		require.Len(t, warnings, 1)
+		assert.ErrorContains(t, warnings[0], block2.ULID.String())
+		assert.Equal(t, float64(2), testutil.ToFloat64(finder.scansTotal))
+	})
+
+	t.Run("should not scan the bucket if the bucket index is up to date", func(t *testing.T) {
+		ctx := context.Background()
+		bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+		block1 := mimir_testutil.MockStorageBlock(t, bkt, userID, 10, 20)
+		mimir_testutil.MockStorageBlock(t, bkt, userID, 20, 30)
+
+		require.NoError(t, bucketindex.WriteIndex(ctx, bkt, userID, nil, &bucketindex.Index{
+			Version:   bucketindex.IndexVersion2,
+			Blocks:    bucketindex.Blocks{{ID: block1.ULID, MinTime: block1.MinTime, MaxTime: block1.MaxTime}},
+			UpdatedAt: time.Now().Unix(),
+		}))
+
+		finder := prepareBucketIndexFallbackBlocksFinder(t, bkt)
+
+		blocks, _, err := finder.GetBlocks(ctx, userID, 0, 100)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ulid.ULID{block1.ULID}, blocks.GetULIDs())
+		assert.Equal(t, float64(0), testutil.ToFloat64(finder.fallbacks))
+		assert.Equal(t, float64(0), testutil.ToFloat64(finder.scansTotal))
+	})
+}
+
+func prepareBucketIndexFallbackBlocksFinder(t testing.TB, bkt objstore.Bucket) *BucketIndexFallbackBlocksFinder {
+	cfg := BucketIndexFallbackBlocksFinderConfig{
+		MinScanInterval:          time.Hour,
+		IgnoreDeletionMarksDelay: time.Hour,
+	}
+
+	return NewBucketIndexFallbackBlocksFinder(cfg, prepareBucketIndexBlocksFinder(t, bkt), bkt, nil, log.NewNopLogger(), nil)
+}
+
+func TestBlocksStoreQuerier_ShouldSkipTheBlocksNotInTheTooOldBucketIndex(t *testing.T) {
+	const (
+		userID     = "user-1"
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(40)
+	)
+
+	ctx := context.Background()
+	bkt, _ := mimir_testutil.PrepareFilesystemBucket(t)
+
+	block1 := mimir_testutil.MockStorageBlock(t, bkt, userID, 10, 20)
+	block2 := mimir_testutil.MockStorageBlock(t, bkt, userID, 20, 30)
+
+	// The stale bucket index, from which store-gateways load the blocks, doesn't contain the most recent block.
+	require.NoError(t, bucketindex.WriteIndex(ctx, bkt, userID, nil, &bucketindex.Index{
+		Version:   bucketindex.IndexVersion2,
+		Blocks:    bucketindex.Blocks{{ID: block1.ULID, MinTime: block1.MinTime, MaxTime: block1.MaxTime}},
+		UpdatedAt: time.Now().Add(-2 * time.Hour).Unix(),
+	}))
+
+	series := labels.FromStrings(labels.MetricName, metricName)
+
+	// A single store-gateways lookup is mocked, so the query would fail if the consistency
+	// check expected the skipped block and looked it up on other store-gateways.
+	stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(series, minT, 1),
+				mockHintsResponse(block1.ULID),
+			}}: {block1.ULID},
+		},
+	}}
+
+	q := &blocksStoreQuerier{
+		ctx:         limiter.AddQueryLimiterToContext(ctx, limiter.NewQueryLimiter(0, 0, 0)),
+		minT:        minT,
+		maxT:        maxT,
+		userID:      userID,
+		finder:      prepareBucketIndexFallbackBlocksFinder(t, bkt),
+		stores:      stores,
+		consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:      log.NewNopLogger(),
+		metrics:     newBlocksStoreQueryableMetrics(nil),
+		limits:      &blocksStoreLimitsMock{},
+	}
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.True(t, set.Next())
+	assert.Equal(t, series, set.At().Labels())
+	assert.False(t, set.Next())
+	require.NoError(t, set.Err())
+
+	require.Len(t, set.Warnings(), 1)
+	assert.ErrorContains(t, set.Warnings()[0], block2.ULID.String())
+	assert.Equal(t, 1, stores.nextResult)
+}
@@ -7,6 +7,7 @@ package querier
 
 import (
 	"context"
+	"fmt"
 	"path"
 	"path/filepath"
 	"sort"
@@ -22,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/storage"
 	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
@@ -71,6 +73,9 @@ type BucketScanBlocksFinder struct {
 	userMetasLookup   map[string]map[ulid.ULID]*bucketindex.Block
 	userDeletionMarks map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark
 
+	// Keep the per-tenant/user blocks skipped during the last run because their meta.json is corrupted.
+	userCorruptedBlocks map[string][]ulid.ULID
+
 	scanDuration    prometheus.Histogram
 	scanLastSuccess prometheus.Gauge
 }
@@ -96,6 +101,7 @@ func NewBucketScanBlocksFinder(cfg BucketScanBlocksFinderConfig, bucketClient ob
 			Name: "cortex_querier_blocks_last_successful_scan_timestamp_seconds",
 			Help: "Unix timestamp of the last successful blocks scan.",
 		}),
+		userCorruptedBlocks: map[string][]ulid.ULID{},
 	}
 
 	if reg != nil {
@@ -112,21 +118,34 @@ func NewBucketScanBlocksFinder(cfg BucketScanBlocksFinderConfig, bucketClient ob
 
 // GetBlocks returns known blocks for userID containing samples within the range minT
 // and maxT (milliseconds, both included). Returned blocks are sorted by MaxTime descending.
-func (d *BucketScanBlocksFinder) GetBlocks(_ context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+func (d *BucketScanBlocksFinder) GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	blocks, deletionMarks, _, err := d.GetBlocksWithWarnings(ctx, userID, minT, maxT)
+	return blocks, deletionMarks, err
+}
+
+// GetBlocksWithWarnings is like GetBlocks, but it also returns a warning if some blocks of userID have
+// been skipped because their meta.json is corrupted. The time range of such blocks is unknown, so the
+// warning is returned regardless of the input range.
+func (d *BucketScanBlocksFinder) GetBlocksWithWarnings(_ context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, error) {
 	// We need to ensure the initial full bucket scan succeeded.
 	if d.State() != services.Running {
-		return nil, nil, errBucketScanBlocksFinderNotRunning
+		return nil, nil, nil, errBucketScanBlocksFinderNotRunning
 	}
 	if maxT < minT {
-		return nil, nil, errInvalidBlocksRange
+		return nil, nil, nil, errInvalidBlocksRange
 	}
 
 	d.userMx.RLock()
 	defer d.userMx.RUnlock()
 
+	var warnings storage.Warnings
+	if corrupted := d.userCorruptedBlocks[userID]; len(corrupted) > 0 {
+		warnings = append(warnings, fmt.Errorf("%d blocks have been skipped because their meta.json is corrupted: %s", len(corrupted), strings.Join(convertULIDsToString(corrupted), " ")))
+	}
+
 	userMetas, ok := d.userMetas[userID]
 	if !ok {
-		return nil, nil, nil
+		return nil, nil, warnings, nil
 	}
 
 	// Given we do expect the large majority of queries to have a time range close
@@ -153,7 +172,7 @@ func (d *BucketScanBlocksFinder) GetBlocks(_ context.Context, userID string, min
 		}
 	}
 
-	return matchingMetas, matchingDeletionMarks, nil
+	return matchingMetas, matchingDeletionMarks, warnings, nil
 }
 
 func (d *BucketScanBlocksFinder) starting(ctx context.Context) error {
@@ -195,6 +214,7 @@ func (d *BucketScanBlocksFinder) scanBucket(ctx context.Context) (returnErr erro
 	resMetas := map[string]bucketindex.Blocks{}
 	resMetasLookup := map[string]map[ulid.ULID]*bucketindex.Block{}
 	resDeletionMarks := map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark{}
+	resCorruptedBlocks := map[string][]ulid.ULID{}
 	resErrs := tsdb_errors.NewMulti()
 
 	// Create a pool of workers which will synchronize metas. The pool size
@@ -208,7 +228,7 @@ func (d *BucketScanBlocksFinder) scanBucket(ctx context.Context) (returnErr erro
 			defer wg.Done()
 
 			for userID := range jobsChan {
-				metas, deletionMarks, err := d.scanUserBlocksWithRetries(ctx, userID)
+				metas, deletionMarks, corrupted, err := d.scanUserBlocksWithRetries(ctx, userID)
 
 				// Build the lookup map.
 				lookup := map[ulid.ULID]*bucketindex.Block{}
@@ -223,6 +243,9 @@ func (d *BucketScanBlocksFinder) scanBucket(ctx context.Context) (returnErr erro
 					resMetas[userID] = metas
 					resMetasLookup[userID] = lookup
 					resDeletionMarks[userID] = deletionMarks
+					if len(corrupted) > 0 {
+						resCorruptedBlocks[userID] = corrupted
+					}
 				}
 				resMx.Unlock()
 			}
@@ -253,6 +276,7 @@ pushJobsLoop:
 		d.userMetas = resMetas
 		d.userMetasLookup = resMetasLookup
 		d.userDeletionMarks = resDeletionMarks
+		d.userCorruptedBlocks = resCorruptedBlocks
 	} else {
 		// If an error occurred, we prefer to partially update the metas map instead of
 		// not updating it at all. At least we'll update blocks for the successful tenants.
@@ -266,6 +290,14 @@ pushJobsLoop:
 
 		for userID, deletionMarks := range resDeletionMarks {
 			d.userDeletionMarks[userID] = deletionMarks
+
+			// The deletion marks are set for each successful tenant, so we use them to know which
+			// tenants have been scanned and reset the blocks previously found corrupted.
+			if corrupted, ok := resCorruptedBlocks[userID]; ok {
+				d.userCorruptedBlocks[userID] = corrupted
+			} else {
+				delete(d.userCorruptedBlocks, userID)
+			}
 		}
 	}
 	d.userMx.Unlock()
@@ -275,7 +307,7 @@ pushJobsLoop:
 
 // scanUserBlocksWithRetries runs scanUserBlocks() retrying multiple times
 // in case of error.
-func (d *BucketScanBlocksFinder) scanUserBlocksWithRetries(ctx context.Context, userID string) (metas bucketindex.Blocks, deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark, err error) {
+func (d *BucketScanBlocksFinder) scanUserBlocksWithRetries(ctx context.Context, userID string) (metas bucketindex.Blocks, deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark, corrupted []ulid.ULID, err error) {
 	retries := backoff.New(ctx, backoff.Config{
 		MinBackoff: time.Second,
 		MaxBackoff: 30 * time.Second,
@@ -283,7 +315,7 @@ func (d *BucketScanBlocksFinder) scanUserBlocksWithRetries(ctx context.Context,
 	})
 
 	for retries.Ongoing() {
-		metas, deletionMarks, err = d.scanUserBlocks(ctx, userID)
+		metas, deletionMarks, corrupted, err = d.scanUserBlocks(ctx, userID)
 		if err == nil {
 			return
 		}
@@ -294,21 +326,31 @@ func (d *BucketScanBlocksFinder) scanUserBlocksWithRetries(ctx context.Context,
 	return
 }
 
-func (d *BucketScanBlocksFinder) scanUserBlocks(ctx context.Context, userID string) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+func (d *BucketScanBlocksFinder) scanUserBlocks(ctx context.Context, userID string) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, []ulid.ULID, error) {
 	fetcher, userBucket, deletionMarkFilter, err := d.getOrCreateMetaFetcher(userID)
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "create meta fetcher for user %s", userID)
+		return nil, nil, nil, errors.Wrapf(err, "create meta fetcher for user %s", userID)
 	}
 
 	metas, partials, err := fetcher.Fetch(ctx)
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "scan blocks for user %s", userID)
+		return nil, nil, nil, errors.Wrapf(err, "scan blocks for user %s", userID)
 	}
 
 	// In case we've found any partial block we log about it but continue cause we don't want
-	// to break the scanner just because there's a spurious block.
+	// to break the scanner just because there's a spurious block. The blocks whose meta.json is
+	// corrupted are tracked, so that queries can warn about them. The blocks without meta.json
+	// are not, because they're expected while a block is being uploaded.
+	var corrupted []ulid.ULID
 	if len(partials) > 0 {
 		logPartialBlocks(userID, partials, d.logger)
+
+		for id, err := range partials {
+			if errors.Cause(err) == block.ErrorSyncMetaCorrupted {
+				corrupted = append(corrupted, id)
+			}
+		}
+		sort.Slice(corrupted, func(i, j int) bool { return corrupted[i].Compare(corrupted[j]) < 0 })
 	}
 
 	res := make(bucketindex.Blocks, 0, len(metas))
@@ -323,7 +365,7 @@ func (d *BucketScanBlocksFinder) scanUserBlocks(ctx context.Context, userID stri
 		} else {
 			attrs, err := userBucket.Attributes(ctx, path.Join(m.ULID.String(), metadata.MetaFilename))
 			if err != nil {
-				return nil, nil, errors.Wrapf(err, "read %s attributes of block %s for user %s", metadata.MetaFilename, m.ULID.String(), userID)
+				return nil, nil, nil, errors.Wrapf(err, "read %s attributes of block %s for user %s", metadata.MetaFilename, m.ULID.String(), userID)
 			}
 
 			// Since the meta.json file is the last file of a block being uploaded and it's immutable
@@ -344,7 +386,7 @@ func (d *BucketScanBlocksFinder) scanUserBlocks(ctx context.Context, userID stri
 		marks[id] = bucketindex.BlockDeletionMarkFromThanosMarker(m)
 	}
 
-	return res, marks, nil
+	return res, marks, corrupted, nil
 }
 
 func (d *BucketScanBlocksFinder) getOrCreateMetaFetcher(userID string) (block.MetadataFetcher, objstore.Bucket, *block.IgnoreDeletionMarkFilter, error) {
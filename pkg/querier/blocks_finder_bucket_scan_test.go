@@ -20,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -388,6 +389,43 @@ func TestBucketScanBlocksFinder_PeriodicScanFindsUserWhichWasPreviouslyDeleted(t
 	assert.Empty(t, deletionMarks)
 }
 
+func TestBucketScanBlocksFinder_GetBlocksWithWarnings_ShouldReportBlocksWithCorruptedMeta(t *testing.T) {
+	ctx := context.Background()
+	s, bkt, _, _ := prepareBucketScanBlocksFinder(t, prepareBucketScanBlocksFinderConfig())
+
+	block1 := mimir_testutil.MockStorageBlock(t, bkt, "user-1", 10, 20)
+	block2 := mimir_testutil.MockStorageBlock(t, bkt, "user-1", 20, 30)
+	block3 := mimir_testutil.MockStorageBlock(t, bkt, "user-2", 10, 20)
+
+	// Corrupt the meta.json of a block, and upload another block without meta.json, like a block being uploaded.
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-1", block2.ULID.String(), "meta.json"), strings.NewReader("invalid")))
+	uploading := ulid.MustNew(uint64(time.Now().UnixMilli()), nil)
+	require.NoError(t, bkt.Upload(ctx, path.Join("user-1", uploading.String(), "index"), strings.NewReader("index")))
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+
+	blocks, _, warnings, err := s.GetBlocksWithWarnings(ctx, "user-1", 0, 30)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, block1.ULID, blocks[0].ID)
+	assert.Equal(t, storage.Warnings{fmt.Errorf("1 blocks have been skipped because their meta.json is corrupted: %s", block2.ULID.String())}, warnings)
+
+	// The blocks of other tenants are not affected.
+	blocks, _, warnings, err = s.GetBlocksWithWarnings(ctx, "user-2", 0, 30)
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	assert.Equal(t, block3.ULID, blocks[0].ID)
+	assert.Empty(t, warnings)
+
+	// The warning is not returned anymore once the corrupted block has been deleted.
+	require.NoError(t, bkt.Delete(ctx, path.Join("user-1", block2.ULID.String(), "meta.json")))
+	require.NoError(t, s.scan(ctx))
+
+	_, _, warnings, err = s.GetBlocksWithWarnings(ctx, "user-1", 0, 30)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
 func TestBucketScanBlocksFinder_GetBlocks(t *testing.T) {
 	ctx := context.Background()
 	s, bucket, _, _ := prepareBucketScanBlocksFinder(t, prepareBucketScanBlocksFinderConfig())
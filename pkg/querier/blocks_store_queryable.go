@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,20 +22,24 @@ import (
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/oklog/ulid"
+	otlog "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/extprom"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/thanos-io/thanos/pkg/store/hintspb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/strutil"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 	grpc_metadata "google.golang.org/grpc/metadata"
 
 	"github.com/grafana/dskit/tenant"
@@ -58,10 +63,22 @@ import (
 )
 
 const (
-	// The maximum number of times we attempt fetching missing blocks from different
-	// store-gateways. If no more store-gateways are left (ie. due to lower replication
-	// factor) than we'll end the retries earlier.
-	maxFetchSeriesAttempts = 3
+	// Values of the "limit" label of the limit rejections metric.
+	limitMaxChunksPerQuery = "max_chunks_per_query"
+	limitMaxChunkBytes     = "max_chunk_bytes"
+	limitMaxSeries         = "max_series"
+
+	// limitMaxEstimatedChunks is used when the query is rejected before querying store-gateways,
+	// because the number of chunks estimated from the blocks metadata exceeds the limit.
+	limitMaxEstimatedChunks = "max_estimated_chunks"
+
+	// limitMaxBlocks is used when the query is rejected before querying store-gateways,
+	// because the number of blocks to query exceeds the limit.
+	limitMaxBlocks = "max_blocks"
+
+	// limitMaxSeriesQueryLength is used when the query is rejected before querying store-gateways,
+	// because the time range to query from the blocks storage exceeds the limit.
+	limitMaxSeriesQueryLength = "max_series_query_length"
 )
 
 var (
@@ -69,6 +86,14 @@ var (
 		"the query exceeded the maximum number of chunks fetched from store-gateways when querying '%s' (limit: %d)",
 		validation.MaxChunksPerQueryFlag,
 	)
+	maxBlocksPerQueryLimitMsgFormat = globalerror.MaxBlocksPerQuery.MessageWithPerTenantLimitConfig(
+		"the query exceeded the maximum number of blocks to query from store-gateways (blocks: %d, limit: %d)",
+		validation.MaxBlocksPerQueryFlag,
+	)
+	maxSeriesQueryLengthLimitMsgFormat = globalerror.MaxSeriesQueryLength.MessageWithPerTenantLimitConfig(
+		"the time range of the query to the blocks storage exceeds the limit (query length: %s, limit: %s)",
+		validation.MaxSeriesQueryLengthFlag,
+	)
 )
 
 // BlocksStoreSet is the interface used to get the clients to query series on a set of blocks.
@@ -79,6 +104,15 @@ type BlocksStoreSet interface {
 	// query the set of blocks in input. The exclude parameter is the map of
 	// blocks -> store-gateway addresses that should be excluded.
 	GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error)
+
+	// GetAddressesFor is like GetClientsFor, but returns the store-gateway addresses instead of the clients.
+	// It has no side effects: the lookup is not tracked by the load balancing, circuit breaker and metrics,
+	// so that it can be used to plan a query without changing how the next queries are routed.
+	GetAddressesFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[string][]ulid.ULID, error)
+
+	// TenantShardInstancesCount returns the number of store-gateway instances
+	// in the shard of the tenant.
+	TenantShardInstancesCount(userID string) int
 }
 
 // BlocksFinder is the interface used to find blocks for a given user and time range.
@@ -90,6 +124,28 @@ type BlocksFinder interface {
 	GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error)
 }
 
+// BlocksFinderWithWarnings is an optional interface implemented by the BlocksFinder which can report
+// the blocks it skipped, eg. because their meta.json is corrupted, so that the missing data is visible
+// to the user instead of being silently not queried.
+type BlocksFinderWithWarnings interface {
+	BlocksFinder
+
+	// GetBlocksWithWarnings is like GetBlocks, but it also returns the warnings about the blocks of
+	// userID which have been skipped and may contain samples within the range minT and maxT.
+	GetBlocksWithWarnings(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, error)
+}
+
+// getBlocksWithWarnings returns the blocks found by the input finder, along with the warnings about the
+// skipped blocks if the finder reports them.
+func getBlocksWithWarnings(ctx context.Context, finder BlocksFinder, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, error) {
+	if f, ok := finder.(BlocksFinderWithWarnings); ok {
+		return f.GetBlocksWithWarnings(ctx, userID, minT, maxT)
+	}
+
+	blocks, deletionMarks, err := finder.GetBlocks(ctx, userID, minT, maxT)
+	return blocks, deletionMarks, nil, err
+}
+
 // BlocksStoreClient is the interface that should be implemented by any client used
 // to query a backend store-gateway.
 type BlocksStoreClient interface {
@@ -105,8 +161,44 @@ type BlocksStoreLimits interface {
 	bucket.TenantConfigProvider
 
 	MaxLabelsQueryLength(userID string) time.Duration
+
+	// MaxSeriesQueryLength returns the limit of the time range of the series queried from the blocks storage.
+	// The time range exceeding the limit is clamped, unless MaxSeriesQueryLengthReject is enabled.
+	MaxSeriesQueryLength(userID string) time.Duration
+
+	// MaxSeriesQueryLengthReject returns whether the queries whose time range exceeds MaxSeriesQueryLength
+	// are rejected, instead of being clamped to the most recent time range within the limit.
+	MaxSeriesQueryLengthReject(userID string) bool
+
 	MaxChunksPerQuery(userID string) int
+	MaxBlocksPerQuery(userID string) int
+
+	// MaxConcurrentQueriesPerTenant returns the max number of queries of the tenant concurrently fetching
+	// data from the store-gateways in a querier. 0 means unlimited.
+	MaxConcurrentQueriesPerTenant(userID string) int
+
 	StoreGatewayTenantShardSize(userID string) int
+
+	// BlocksStoreMaxRefetches returns the maximum number of times we attempt fetching missing blocks
+	// from different store-gateways, including the initial attempt. If no more store-gateways are left
+	// (ie. due to lower replication factor) than we'll end the retries earlier.
+	BlocksStoreMaxRefetches(userID string) int
+
+	// QueryPartialDataOnConsistencyFailure returns whether the querier should return the partial
+	// data it fetched, along with a warning, when some blocks can't be queried after all retries.
+	QueryPartialDataOnConsistencyFailure(userID string) bool
+
+	// StoreGatewayPartialResponse returns whether store-gateways should return the data they
+	// could fetch, along with warnings, instead of failing when some blocks can't be queried.
+	StoreGatewayPartialResponse(userID string) bool
+
+	// MaxEstimatedChunksPerQueryMultiplier returns the multiplier of MaxChunksPerQuery above which a query is
+	// rejected before querying store-gateways, based on the number of chunks estimated from the blocks metadata.
+	MaxEstimatedChunksPerQueryMultiplier(userID string) float64
+
+	// StoreGatewayChunksDeduplication returns whether the querier should drop the duplicated chunks
+	// when merging the same series fetched from multiple store-gateways.
+	StoreGatewayChunksDeduplication(userID string) bool
 }
 
 type blocksStoreQueryableMetrics struct {
@@ -116,38 +208,135 @@ type blocksStoreQueryableMetrics struct {
 	blocksFound                                       prometheus.Counter
 	blocksQueried                                     prometheus.Counter
 	blocksWithCompactorShardButIncompatibleQueryShard prometheus.Counter
+	blocksOverlapping                                 prometheus.Counter
+
+	// The age of the oldest block queried by each query, to understand how far back queries reach.
+	oldestQueriedBlockAge prometheus.Histogram
+
+	// The time spent by each store-gateway to serve a request, tracked per store-gateway
+	// instance in order to spot a single slow store-gateway.
+	storeGatewayRequestDuration *facadeHistogramVec
+
+	// Queries the querier gave up fetching from store-gateways because too close to their deadline.
+	deadlineAbortedQueries prometheus.Counter
+
+	// Queries rejected because a limit has been reached while fetching series, by limit.
+	limitRejections *facadeCounterVec
+
+	// Store-gateway calls which hit the per-call timeout, by operation.
+	perCallTimeouts *facadeCounterVec
+
+	// Chunk bytes fetched from store-gateways, by the gRPC compression requested.
+	fetchedChunkBytes *facadeCounterVec
+
+	// Chunk bytes fetched from store-gateways, by chunk encoding.
+	fetchedChunkBytesByEncoding *facadeCounterVec
+
+	// Store-gateway responses slower than the configured threshold, by operation.
+	slowResponses *facadeCounterVec
 }
 
 func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQueryableMetrics {
-	return &blocksStoreQueryableMetrics{
-		storesHit: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+	return newBlocksStoreQueryableMetricsWithMeter(reg, nil)
+}
+
+// newBlocksStoreQueryableMetricsWithMeter creates the blocks storage querier metrics, registering them to the
+// Prometheus registerer, and also emitting them to the OpenTelemetry meter if not nil.
+func newBlocksStoreQueryableMetricsWithMeter(reg prometheus.Registerer, meter metric.Meter) *blocksStoreQueryableMetrics {
+	f := newMetricsFacade(reg, meter)
+	m := &blocksStoreQueryableMetrics{
+		storesHit: f.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "cortex",
 			Name:      "querier_storegateway_instances_hit_per_query",
 			Help:      "Number of store-gateway instances hit for a single query.",
 			Buckets:   []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
 		}),
-		refetches: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+		refetches: f.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "cortex",
 			Name:      "querier_storegateway_refetches_per_query",
 			Help:      "Number of re-fetches attempted while querying store-gateway instances due to missing blocks.",
-			Buckets:   []float64{0, 1, 2},
+			Buckets:   []float64{0, 1, 2, 3, 4, 5},
 		}),
 
-		blocksFound: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		blocksFound: f.NewCounter(prometheus.CounterOpts{
 			Name: "cortex_querier_blocks_found_total",
 			Help: "Number of blocks found based on query time range.",
 		}),
-		blocksQueried: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		blocksQueried: f.NewCounter(prometheus.CounterOpts{
 			Name: "cortex_querier_blocks_queried_total",
 			Help: "Number of blocks queried to satisfy query. Compared to blocks found, some blocks may have been filtered out thanks to query and compactor sharding.",
 		}),
-		blocksWithCompactorShardButIncompatibleQueryShard: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		blocksWithCompactorShardButIncompatibleQueryShard: f.NewCounter(prometheus.CounterOpts{
 			Name: "cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total",
 			Help: "Blocks that couldn't be checked for query and compactor sharding optimization due to incompatible shard counts.",
 		}),
+		blocksOverlapping: f.NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_overlapping_total",
+			Help: "Number of pairs of queried blocks whose time ranges overlap for more than 50% of the shortest one.",
+		}),
+		oldestQueriedBlockAge: f.NewHistogram(prometheus.HistogramOpts{
+			Name: "cortex_querier_oldest_queried_block_age_seconds",
+			Help: "Age of the oldest block queried by a query, computed as the time elapsed since the block max time.",
+			// From 1 minute to 2 years.
+			Buckets: []float64{
+				time.Minute.Seconds(), (10 * time.Minute).Seconds(), time.Hour.Seconds(), (6 * time.Hour).Seconds(),
+				(12 * time.Hour).Seconds(), (24 * time.Hour).Seconds(), (2 * 24 * time.Hour).Seconds(), (7 * 24 * time.Hour).Seconds(),
+				(14 * 24 * time.Hour).Seconds(), (30 * 24 * time.Hour).Seconds(), (90 * 24 * time.Hour).Seconds(),
+				(180 * 24 * time.Hour).Seconds(), (365 * 24 * time.Hour).Seconds(), (2 * 365 * 24 * time.Hour).Seconds(),
+			},
+		}),
+		storeGatewayRequestDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_querier_storegateway_instance_request_duration_seconds",
+			Help:    "Time spent by each store-gateway instance to serve a request issued by the querier, including the time spent consuming the response stream.",
+			Buckets: prometheus.ExponentialBuckets(0.008, 4, 7),
+		}, []string{"operation", "remote_address"}),
+		deadlineAbortedQueries: f.NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_deadline_aborted_queries_total",
+			Help: "Number of queries for which the querier didn't fetch series from store-gateways because the time left before the query deadline was lower than the configured slack.",
+		}),
+		limitRejections: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_limit_rejections_total",
+			Help: "Number of queries rejected because a limit has been reached while fetching series from store-gateways.",
+		}, []string{"limit"}),
+		perCallTimeouts: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_call_timeouts_total",
+			Help: "Number of store-gateway calls which hit the configured per-call timeout. Blocks not queried because of the timeout are fetched again from other store-gateways.",
+		}, []string{"operation"}),
+		fetchedChunkBytes: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_fetched_chunk_bytes_total",
+			Help: "Number of uncompressed chunk bytes fetched from store-gateways, by the gRPC compression requested for the series responses.",
+		}, []string{"compression"}),
+		fetchedChunkBytesByEncoding: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_fetched_chunk_bytes_by_encoding_total",
+			Help: "Number of uncompressed chunk bytes fetched from store-gateways, by chunk encoding.",
+		}, []string{"encoding"}),
+		slowResponses: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_slow_responses_total",
+			Help: "Number of store-gateway responses which took longer than the configured slow response threshold, by operation.",
+		}, []string{"operation"}),
+	}
+
+	// Initialise the counters so that they're exported even if no query has been rejected yet.
+	for _, limit := range []string{limitMaxChunksPerQuery, limitMaxChunkBytes, limitMaxSeries, limitMaxEstimatedChunks, limitMaxBlocks, limitMaxSeriesQueryLength} {
+		m.limitRejections.WithLabelValues(limit)
+	}
+	for _, operation := range []string{"Series", "LabelNames", "LabelValues"} {
+		m.perCallTimeouts.WithLabelValues(operation)
+		m.slowResponses.WithLabelValues(operation)
+	}
+	for _, name := range storepb.Chunk_Encoding_name {
+		m.fetchedChunkBytesByEncoding.WithLabelValues(strings.ToLower(name))
 	}
+
+	return m
 }
 
+// QueriedBlocksHook is a function invoked, once blocks to query have been found, with the tenant,
+// the queried time range and the IDs of the blocks actually queried from store-gateways. The hook
+// is invoked on failed queries too, with the blocks queried until the failure, and runs on the
+// query path, so it should be fast.
+type QueriedBlocksHook func(userID string, minT, maxT int64, queriedBlocks []ulid.ULID)
+
 // BlocksStoreQueryable is a queryable which queries blocks storage via
 // the store-gateway.
 type BlocksStoreQueryable struct {
@@ -158,22 +347,67 @@ type BlocksStoreQueryable struct {
 	consistency     *BlocksConsistencyChecker
 	logger          log.Logger
 	queryStoreAfter time.Duration
+	deadlineSlack   time.Duration
+	maxStreams      int
+	perCallTimeout  time.Duration
+	seriesCompress  string
+	labelsCompress  string
+	refetchBackoff  refetchBackoffConfig
+	slowThreshold   time.Duration
 	metrics         *blocksStoreQueryableMetrics
 	limits          BlocksStoreLimits
 
+	// Optional hook invoked with the blocks queried from store-gateways.
+	queriedBlocksHook QueriedBlocksHook
+
+	// Limiter of the tenant's queries concurrently fetching data from store-gateways.
+	tenantQueries *tenantQueriesLimiter
+
+	// Whether the series are sorted only when the caller requires sorted series.
+	unsortedSelect bool
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 }
 
+// BlocksStoreQueryableConfig holds the config of the BlocksStoreQueryable.
+type BlocksStoreQueryableConfig struct {
+	QueryStoreAfter                   time.Duration
+	StoreGatewayDeadlineSlack         time.Duration
+	StoreGatewayMaxConcurrentStreams  int
+	StoreGatewayClient                ClientConfig
+	StoreGatewayRefetchMinBackoff     time.Duration
+	StoreGatewayRefetchMaxBackoff     time.Duration
+	StoreGatewaySlowResponseThreshold time.Duration
+	MaxConcurrentQueriesPerTenantWait time.Duration
+	StoreGatewayUnsortedSelectEnabled bool
+}
+
+// NewBlocksStoreQueryableConfig returns the BlocksStoreQueryableConfig built from the querier config.
+func NewBlocksStoreQueryableConfig(querierCfg Config) BlocksStoreQueryableConfig {
+	return BlocksStoreQueryableConfig{
+		QueryStoreAfter:                   querierCfg.QueryStoreAfter,
+		StoreGatewayDeadlineSlack:         querierCfg.StoreGatewayDeadlineSlack,
+		StoreGatewayMaxConcurrentStreams:  querierCfg.StoreGatewayMaxConcurrentStreams,
+		StoreGatewayClient:                querierCfg.StoreGatewayClient,
+		StoreGatewayRefetchMinBackoff:     querierCfg.StoreGatewayRefetchMinBackoff,
+		StoreGatewayRefetchMaxBackoff:     querierCfg.StoreGatewayRefetchMaxBackoff,
+		StoreGatewaySlowResponseThreshold: querierCfg.StoreGatewaySlowResponseThreshold,
+		MaxConcurrentQueriesPerTenantWait: querierCfg.MaxConcurrentQueriesPerTenantWait,
+		StoreGatewayUnsortedSelectEnabled: querierCfg.StoreGatewayUnsortedSelectEnabled,
+	}
+}
+
 func NewBlocksStoreQueryable(
 	stores BlocksStoreSet,
 	finder BlocksFinder,
 	consistency *BlocksConsistencyChecker,
 	limits BlocksStoreLimits,
-	queryStoreAfter time.Duration,
+	cfg BlocksStoreQueryableConfig,
 	logger log.Logger,
 	reg prometheus.Registerer,
+	meter metric.Meter,
 ) (*BlocksStoreQueryable, error) {
 	manager, err := services.NewManager(stores, finder)
 	if err != nil {
@@ -184,12 +418,21 @@ func NewBlocksStoreQueryable(
 		stores:             stores,
 		finder:             finder,
 		consistency:        consistency,
-		queryStoreAfter:    queryStoreAfter,
+		queryStoreAfter:    cfg.QueryStoreAfter,
+		deadlineSlack:      cfg.StoreGatewayDeadlineSlack,
+		maxStreams:         cfg.StoreGatewayMaxConcurrentStreams,
+		perCallTimeout:     cfg.StoreGatewayClient.PerCallTimeout,
+		seriesCompress:     cfg.StoreGatewayClient.SeriesCompression,
+		labelsCompress:     cfg.StoreGatewayClient.LabelsCompression,
+		refetchBackoff:     refetchBackoffConfig{min: cfg.StoreGatewayRefetchMinBackoff, max: cfg.StoreGatewayRefetchMaxBackoff},
+		slowThreshold:      cfg.StoreGatewaySlowResponseThreshold,
 		logger:             logger,
 		subservices:        manager,
 		subservicesWatcher: services.NewFailureWatcher(),
-		metrics:            newBlocksStoreQueryableMetrics(reg),
+		metrics:            newBlocksStoreQueryableMetricsWithMeter(reg, meter),
 		limits:             limits,
+		tenantQueries:      newTenantQueriesLimiter(limits, cfg.MaxConcurrentQueriesPerTenantWait, reg),
+		unsortedSelect:     cfg.StoreGatewayUnsortedSelectEnabled,
 	}
 
 	q.Service = services.NewBasicService(q.starting, q.running, q.stopping)
@@ -218,7 +461,7 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 	// Create the blocks finder.
 	var finder BlocksFinder
 	if storageCfg.BucketStore.BucketIndex.Enabled {
-		finder = NewBucketIndexBlocksFinder(BucketIndexBlocksFinderConfig{
+		indexFinder := NewBucketIndexBlocksFinder(BucketIndexBlocksFinderConfig{
 			IndexLoader: bucketindex.LoaderConfig{
 				CheckInterval:         time.Minute,
 				UpdateOnStaleInterval: storageCfg.BucketStore.SyncInterval,
@@ -228,6 +471,14 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 			MaxStalePeriod:           storageCfg.BucketStore.BucketIndex.MaxStalePeriod,
 			IgnoreDeletionMarksDelay: storageCfg.BucketStore.IgnoreDeletionMarksDelay,
 		}, bucketClient, limits, logger, reg)
+
+		finder = indexFinder
+		if storageCfg.BucketStore.BucketIndex.StaleFallbackScanInterval > 0 {
+			finder = NewBucketIndexFallbackBlocksFinder(BucketIndexFallbackBlocksFinderConfig{
+				MinScanInterval:          storageCfg.BucketStore.BucketIndex.StaleFallbackScanInterval,
+				IgnoreDeletionMarksDelay: storageCfg.BucketStore.IgnoreDeletionMarksDelay,
+			}, indexFinder, bucketClient, limits, logger, reg)
+		}
 	} else {
 		finder = NewBucketScanBlocksFinder(BucketScanBlocksFinderConfig{
 			ScanInterval:             storageCfg.BucketStore.SyncInterval,
@@ -238,6 +489,39 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		}, bucketClient, limits, logger, reg)
 	}
 
+	if len(querierCfg.StoreGatewayAddresses) > 0 {
+		stores, err = newBlocksStoreStaticSet(querierCfg.StoreGatewayAddresses, querierCfg.StoreGatewayClient, logger, reg)
+	} else {
+		stores, err = newRingBlocksStoreSet(querierCfg, gatewayCfg, limits, logger, reg)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create store set")
+	}
+
+	consistency := NewBlocksConsistencyChecker(
+		// Exclude blocks which have been recently uploaded, in order to give enough time to store-gateways
+		// to discover and load them (3 times the sync interval).
+		storageCfg.BucketStore.ConsistencyDelay+(3*storageCfg.BucketStore.SyncInterval),
+		// To avoid any false positive in the consistency check, we do exclude blocks which have been
+		// recently marked for deletion, until the "ignore delay / 2". This means the consistency checker
+		// exclude such blocks about 50% of the time before querier and store-gateway stops querying them.
+		storageCfg.BucketStore.IgnoreDeletionMarksDelay/2,
+		logger,
+		reg,
+	)
+
+	// The OpenTelemetry metrics are emitted to the global meter provider, which is set by the application
+	// embedding Mimir, if any.
+	var meter metric.Meter
+	if querierCfg.OpenTelemetryMetricsEnabled {
+		meter = global.Meter("github.com/grafana/mimir/pkg/querier")
+	}
+
+	return NewBlocksStoreQueryable(stores, finder, consistency, limits, NewBlocksStoreQueryableConfig(querierCfg), logger, reg, meter)
+}
+
+// newRingBlocksStoreSet returns the BlocksStoreSet discovering the store-gateways via the ring.
+func newRingBlocksStoreSet(querierCfg Config, gatewayCfg storegateway.Config, limits BlocksStoreLimits, logger log.Logger, reg prometheus.Registerer) (BlocksStoreSet, error) {
 	storesRingCfg := gatewayCfg.ShardingRing.ToRingConfig()
 	storesRingBackend, err := kv.NewClient(
 		storesRingCfg.KVStore,
@@ -254,24 +538,18 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		return nil, errors.Wrap(err, "failed to create store-gateway ring client")
 	}
 
-	stores, err = newBlocksStoreReplicationSet(storesRing, randomLoadBalancing, limits, querierCfg.StoreGatewayClient, logger, reg)
+	balancingStrategy, err := parseLoadBalancingStrategy(querierCfg.StoreGatewayLoadBalancingStrategy)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create store set")
+		return nil, err
 	}
 
-	consistency := NewBlocksConsistencyChecker(
-		// Exclude blocks which have been recently uploaded, in order to give enough time to store-gateways
-		// to discover and load them (3 times the sync interval).
-		storageCfg.BucketStore.ConsistencyDelay+(3*storageCfg.BucketStore.SyncInterval),
-		// To avoid any false positive in the consistency check, we do exclude blocks which have been
-		// recently marked for deletion, until the "ignore delay / 2". This means the consistency checker
-		// exclude such blocks about 50% of the time before querier and store-gateway stops querying them.
-		storageCfg.BucketStore.IgnoreDeletionMarksDelay/2,
-		logger,
-		reg,
-	)
+	breakerCfg := storeGatewayCircuitBreakerConfig{
+		FailureThreshold: querierCfg.StoreGatewayCircuitBreakerFailureThreshold,
+		FailureWindow:    querierCfg.StoreGatewayCircuitBreakerFailureWindow,
+		OpenTimeout:      querierCfg.StoreGatewayCircuitBreakerOpenTimeout,
+	}
 
-	return NewBlocksStoreQueryable(stores, finder, consistency, limits, querierCfg.QueryStoreAfter, logger, reg)
+	return newBlocksStoreReplicationSet(storesRing, balancingStrategy, limits, querierCfg.StoreGatewayClient, querierCfg.InstanceZone, querierCfg.StoreGatewayPreferSameZone, breakerCfg, logger, reg)
 }
 
 func (q *BlocksStoreQueryable) starting(ctx context.Context) error {
@@ -299,6 +577,12 @@ func (q *BlocksStoreQueryable) stopping(_ error) error {
 	return services.StopManagerAndAwaitStopped(context.Background(), q.subservices)
 }
 
+// SetQueriedBlocksHook sets the hook invoked with the blocks queried for each query. It must be
+// set before the queryable is used to run queries.
+func (q *BlocksStoreQueryable) SetQueriedBlocksHook(hook QueriedBlocksHook) {
+	q.queriedBlocksHook = hook
+}
+
 // Querier returns a new Querier on the storage.
 func (q *BlocksStoreQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 	if s := q.State(); s != services.Running {
@@ -311,9 +595,56 @@ func (q *BlocksStoreQueryable) Querier(ctx context.Context, mint, maxt int64) (s
 	}
 
 	return &blocksStoreQuerier{
+		ctx:               ctx,
+		minT:              mint,
+		maxT:              maxt,
+		userID:            userID,
+		finder:            q.finder,
+		stores:            q.stores,
+		metrics:           q.metrics,
+		limits:            q.limits,
+		consistency:       q.consistency,
+		logger:            q.logger,
+		queryStoreAfter:   q.queryStoreAfter,
+		deadlineSlack:     q.deadlineSlack,
+		maxStreams:        q.maxStreams,
+		perCallTimeout:    q.perCallTimeout,
+		seriesCompress:    q.seriesCompress,
+		labelsCompress:    q.labelsCompress,
+		refetchBackoff:    q.refetchBackoff,
+		slowThreshold:     q.slowThreshold,
+		queriedBlocksHook: q.queriedBlocksHook,
+		tenantQueries:     q.tenantQueries,
+		unsortedSelect:    q.unsortedSelect,
+	}, nil
+}
+
+// QueryPlan describes how a query would be run against the blocks storage.
+type QueryPlan struct {
+	// The query time range, after the max time manipulation done when query store after is enabled.
+	MinT, MaxT int64
+
+	// The store-gateway address each block would be queried from, at the first attempt.
+	Blocks map[ulid.ULID]string
+}
+
+// Explain returns the blocks which would be queried for the input time range and matchers, along
+// with the store-gateways they would be fetched from, without actually querying the store-gateways.
+// The tenant is read from the context.
+func (q *BlocksStoreQueryable) Explain(ctx context.Context, minT, maxT int64, matchers ...*labels.Matcher) (*QueryPlan, error) {
+	if s := q.State(); s != services.Running {
+		return nil, errors.Errorf("BlocksStoreQueryable is not running: %v", s)
+	}
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	querier := &blocksStoreQuerier{
 		ctx:             ctx,
-		minT:            mint,
-		maxT:            maxt,
+		minT:            minT,
+		maxT:            maxT,
 		userID:          userID,
 		finder:          q.finder,
 		stores:          q.stores,
@@ -322,7 +653,9 @@ func (q *BlocksStoreQueryable) Querier(ctx context.Context, mint, maxt int64) (s
 		consistency:     q.consistency,
 		logger:          q.logger,
 		queryStoreAfter: q.queryStoreAfter,
-	}, nil
+	}
+
+	return querier.explain(matchers)
 }
 
 type blocksStoreQuerier struct {
@@ -339,18 +672,67 @@ type blocksStoreQuerier struct {
 	// If set, the querier manipulates the max time to not be greater than
 	// "now - queryStoreAfter" so that most recent blocks are not queried.
 	queryStoreAfter time.Duration
+
+	// If set, the querier doesn't fetch series from store-gateways when the time
+	// left before the query deadline is lower than deadlineSlack.
+	deadlineSlack time.Duration
+
+	// The maximum number of store-gateway series streams concurrently read
+	// for a single query (0 means unlimited).
+	maxStreams int
+
+	// The timeout applied to each single store-gateway call (0 means no timeout
+	// other than the query one).
+	perCallTimeout time.Duration
+
+	// The gRPC compression requested to store-gateways for series and
+	// labels responses (empty means no compression).
+	seriesCompress string
+	labelsCompress string
+
+	// The time to wait before fetching again the blocks missing after an attempt.
+	refetchBackoff refetchBackoffConfig
+
+	// Store-gateway responses taking longer than this threshold are logged (0 means disabled).
+	slowThreshold time.Duration
+
+	// Optional hook invoked with the blocks queried from store-gateways.
+	queriedBlocksHook QueriedBlocksHook
+
+	// Limiter of the tenant's queries concurrently fetching data from store-gateways (nil means unlimited).
+	tenantQueries *tenantQueriesLimiter
+
+	// If set, the series fetched from different store-gateways are concatenated instead
+	// of being merged, when the caller doesn't require sorted series and it's safe.
+	unsortedSelect bool
+}
+
+// refetchBackoffConfig holds the backoff applied between attempts to fetch missing blocks.
+type refetchBackoffConfig struct {
+	// The wait time before the first refetch (0 means disabled). It doubles at each attempt.
+	min time.Duration
+
+	// The maximum wait time between attempts.
+	max time.Duration
+
+	// Optional function used to wait for the backoff delay, returning an error if the ctx is done
+	// while waiting. If nil, a timer is used. It allows tests to not depend on the wall clock.
+	wait func(ctx context.Context, delay time.Duration) error
 }
 
 // Select implements storage.Querier interface.
-// The bool passed is ignored because the series is always sorted.
-func (q *blocksStoreQuerier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
-	return q.selectSorted(sp, matchers...)
+// The series are always sorted, unless the unsorted select is enabled and sorted series aren't required.
+func (q *blocksStoreQuerier) Select(sortSeries bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	return q.selectSeries(sortSeries || !q.unsortedSelect, sp, matchers...)
 }
 
 func (q *blocksStoreQuerier) LabelNames(matchers ...*labels.Matcher) ([]string, storage.Warnings, error) {
 	spanLog, spanCtx := spanlogger.NewWithLogger(q.ctx, q.logger, "blocksStoreQuerier.LabelNames")
 	defer spanLog.Span.Finish()
 
+	// Duplicated matchers don't change the result, but they inflate the store-gateway requests.
+	matchers = normalizeMatchers(matchers)
+
 	minT, maxT := q.minT, q.maxT
 
 	level.Debug(spanLog).Log("start", util.TimeFromMillis(minT).UTC().String(), "end",
@@ -363,6 +745,12 @@ func (q *blocksStoreQuerier) LabelNames(matchers ...*labels.Matcher) ([]string,
 		minT = int64(clampTime(spanCtx, startTime, maxQueryLength, endTime.Add(-maxQueryLength), true, "start", "max label query length", spanLog))
 	}
 
+	// Blocks split by the compactor which can't contain the query shard are not queried.
+	shard, _, err := sharding.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var (
 		resNameSets       = [][]string{}
 		resWarnings       = storage.Warnings(nil)
@@ -381,10 +769,11 @@ func (q *blocksStoreQuerier) LabelNames(matchers ...*labels.Matcher) ([]string,
 		return queriedBlocks, nil
 	}
 
-	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, nil, queryFunc)
+	warnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, shard, nil, nil, queryFunc)
 	if err != nil {
 		return nil, nil, err
 	}
+	resWarnings = append(resWarnings, warnings...)
 
 	return strutil.MergeSlices(resNameSets...), resWarnings, nil
 }
@@ -393,6 +782,9 @@ func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matche
 	spanLog, spanCtx := spanlogger.NewWithLogger(q.ctx, q.logger, "blocksStoreQuerier.LabelValues")
 	defer spanLog.Span.Finish()
 
+	// Duplicated matchers don't change the result, but they inflate the store-gateway requests.
+	matchers = normalizeMatchers(matchers)
+
 	minT, maxT := q.minT, q.maxT
 
 	level.Debug(spanLog).Log("start", util.TimeFromMillis(minT).UTC().String(), "end",
@@ -405,6 +797,12 @@ func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matche
 		minT = int64(clampTime(spanCtx, startTime, maxQueryLength, endTime.Add(-maxQueryLength), true, "start", "max label query length", spanLog))
 	}
 
+	// Blocks split by the compactor which can't contain the query shard are not queried.
+	shard, _, err := sharding.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	var (
 		resValueSets = [][]string{}
 		resWarnings  = storage.Warnings(nil)
@@ -422,10 +820,11 @@ func (q *blocksStoreQuerier) LabelValues(name string, matchers ...*labels.Matche
 		return queriedBlocks, nil
 	}
 
-	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, nil, queryFunc)
+	warnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, shard, nil, nil, queryFunc)
 	if err != nil {
 		return nil, nil, err
 	}
+	resWarnings = append(resWarnings, warnings...)
 
 	return strutil.MergeSlices(resValueSets...), resWarnings, nil
 }
@@ -434,12 +833,22 @@ func (q *blocksStoreQuerier) Close() error {
 	return nil
 }
 
-func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
-	spanLog, spanCtx := spanlogger.NewWithLogger(q.ctx, q.logger, "blocksStoreQuerier.selectSorted")
+// selectSeries fetches the series from store-gateways. If sortSeries is false, the series fetched from
+// multiple store-gateways are concatenated instead of being merged, when they're known to be different.
+func (q *blocksStoreQuerier) selectSeries(sortSeries bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	spanLog, spanCtx := spanlogger.NewWithLogger(q.ctx, q.logger, "blocksStoreQuerier.selectSeries")
 	defer spanLog.Span.Finish()
 
+	// Duplicated matchers don't change the result, but they inflate the store-gateway requests.
+	matchers = normalizeMatchers(matchers)
+
 	minT, maxT := sp.Start, sp.End
 
+	minT, err := q.applyMaxSeriesQueryLength(spanCtx, spanLog, minT, maxT)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
 	var (
 		convertedMatchers = convertMatchersToLabelMatcher(matchers)
 		resSeriesSets     = []storage.SeriesSet(nil)
@@ -472,30 +881,150 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		return queriedBlocks, nil
 	}
 
-	err = q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, shard, queryFunc)
+	// The estimate is only a cheap early rejection based on the blocks metadata. The exact
+	// limit is still enforced while fetching series from store-gateways.
+	var queriedBlocks bucketindex.Blocks
+	preCheckFunc := func(blocks bucketindex.Blocks, minT, maxT int64) error {
+		queriedBlocks = blocks
+		return q.checkEstimatedChunks(spanLog, blocks, minT, maxT, shard, matchers, maxChunksLimit)
+	}
+
+	var blockStats *blocksQueryStats
+	if blockDiagnosticsFromContext(spanCtx) {
+		blockStats = &blocksQueryStats{}
+	}
+
+	warnings, err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, shard, blockStats, preCheckFunc, queryFunc)
 	if err != nil {
 		return storage.ErrSeriesSet(err)
 	}
+	resWarnings = append(resWarnings, warnings...)
+	if blockStats != nil {
+		resWarnings = append(resWarnings, blockStats.warning())
+	}
 
 	if len(resSeriesSets) == 0 {
 		storage.EmptySeriesSet()
 	}
 
+	mergeFunc := storage.ChainedSeriesMerge
+	if q.limits.StoreGatewayChunksDeduplication(q.userID) {
+		mergeFunc = dedupChunksSeriesMerge
+	}
+
+	if !sortSeries && canConcatBlocksSeriesSets(resSeriesSets, queriedBlocks) {
+		return series.NewSeriesSetWithWarnings(newConcatSeriesSet(resSeriesSets), resWarnings)
+	}
+
 	return series.NewSeriesSetWithWarnings(
-		storage.NewMergeSeriesSet(resSeriesSets, storage.ChainedSeriesMerge),
+		storage.NewMergeSeriesSet(resSeriesSets, mergeFunc),
 		resWarnings)
 }
 
-func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT int64, shard *sharding.ShardSelector,
-	queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)) error {
+// explain plans the query for the input matchers, running the same blocks lookup, shard filtering
+// and store-gateways selection done by the first attempt of queryWithConsistencyCheck().
+func (q *blocksStoreQuerier) explain(matchers []*labels.Matcher) (*QueryPlan, error) {
+	spanLog, spanCtx := spanlogger.NewWithLogger(q.ctx, q.logger, "blocksStoreQuerier.explain")
+	defer spanLog.Span.Finish()
+
+	shard, _, err := sharding.ShardFromMatchers(matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	knownBlocks, _, _, maxT, ok, err := q.findBlocksToQuery(spanCtx, spanLog, q.minT, q.maxT, shard, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &QueryPlan{MinT: q.minT, MaxT: maxT, Blocks: map[ulid.ULID]string{}}
+	if !ok || len(knownBlocks) == 0 {
+		return plan, nil
+	}
+
+	// Store-gateways excluded by the caller are never queried.
+	blockIDs := knownBlocks.GetULIDs()
+	attemptedBlocks := map[ulid.ULID][]string{}
+	if excludedStores := excludedStoreGatewaysFromContext(spanCtx); len(excludedStores) > 0 {
+		for _, blockID := range blockIDs {
+			attemptedBlocks[blockID] = append([]string(nil), excludedStores...)
+		}
+	}
+
+	addrs, err := q.stores.GetAddressesFor(q.userID, blockIDs, attemptedBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	for addr, addrBlockIDs := range addrs {
+		for _, blockID := range addrBlockIDs {
+			plan.Blocks[blockID] = addr
+		}
+	}
+
+	return plan, nil
+}
+
+// queryStoreAfterFromContext returns the query store after setting, and whether it has been overridden
+// from the request context.
+func (q *blocksStoreQuerier) queryStoreAfterFromContext(ctx context.Context) (time.Duration, bool) {
+	if override, ok := queryStoreAfterOverrideFromContext(ctx); ok {
+		return override, true
+	}
+	return q.queryStoreAfter, false
+}
+
+// storeQueryMaxTime returns the max time of the query to the blocks storage, given the query store after setting.
+func storeQueryMaxTime(maxT int64, queryStoreAfter time.Duration, now time.Time) int64 {
+	if queryStoreAfter <= 0 {
+		return maxT
+	}
+	return math.Min64(maxT, util.TimeToMillis(now.Add(-queryStoreAfter)))
+}
+
+// applyMaxSeriesQueryLength enforces the max series query length limit on the time range of the query to the
+// blocks storage, which doesn't include the most recent time range not queried because of the query store after
+// setting. The time range exceeding the limit is clamped to the most recent one within the limit, and the clamped
+// min time is returned, unless the tenant is configured to reject the query.
+func (q *blocksStoreQuerier) applyMaxSeriesQueryLength(ctx context.Context, logger log.Logger, minT, maxT int64) (int64, error) {
+	maxQueryLength := q.limits.MaxSeriesQueryLength(q.userID)
+	if maxQueryLength <= 0 {
+		return minT, nil
+	}
+
+	queryStoreAfter, _ := q.queryStoreAfterFromContext(ctx)
+	storeMaxT := storeQueryMaxTime(maxT, queryStoreAfter, time.Now())
+	if storeMaxT < minT {
+		// Nothing is queried from the blocks storage.
+		return minT, nil
+	}
+
+	startTime, endTime := model.Time(minT), model.Time(storeMaxT)
+	if queryLength := endTime.Sub(startTime); queryLength > maxQueryLength && q.limits.MaxSeriesQueryLengthReject(q.userID) {
+		q.metrics.limitRejections.WithLabelValues(limitMaxSeriesQueryLength).Inc()
+		return minT, validation.LimitError(fmt.Sprintf(maxSeriesQueryLengthLimitMsgFormat, queryLength, maxQueryLength))
+	}
+
+	return int64(clampTime(ctx, startTime, maxQueryLength, endTime.Add(-maxQueryLength), true, "start", "max series query length", logger)), nil
+}
+
+// findBlocksToQuery returns the blocks to query for the input time range and shard, along with their
+// deletion marks, the warnings about the blocks skipped by the finder and the query max time, manipulated
+// according to the query store after setting. The returned bool is false if there's nothing to query from
+// the blocks storage. The number of blocks found and filtered are recorded in the input stats, if not nil.
+func (q *blocksStoreQuerier) findBlocksToQuery(ctx context.Context, logger log.Logger, minT, maxT int64, shard *sharding.ShardSelector, blockStats *blocksQueryStats) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, int64, bool, error) {
 	// If queryStoreAfter is enabled, we do manipulate the query maxt to query samples up until
 	// now - queryStoreAfter, because the most recent time range is covered by ingesters. This
 	// optimization is particularly important for the blocks storage because can be used to skip
 	// querying most recent not-compacted-yet blocks from the storage.
-	if q.queryStoreAfter > 0 {
-		now := time.Now()
+	queryStoreAfter, overridden := q.queryStoreAfterFromContext(ctx)
+	if overridden {
+		level.Debug(logger).Log("msg", "overriding query store after from the request context", "configured", q.queryStoreAfter, "override", queryStoreAfter)
+	}
+
+	if queryStoreAfter > 0 {
 		origMaxT := maxT
-		maxT = math.Min64(maxT, util.TimeToMillis(now.Add(-q.queryStoreAfter)))
+		maxT = storeQueryMaxTime(maxT, queryStoreAfter, time.Now())
 
 		if origMaxT != maxT {
 			level.Debug(logger).Log("msg", "the max time of the query to blocks storage has been manipulated", "original", origMaxT, "updated", maxT)
@@ -504,61 +1033,191 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		if maxT < minT {
 			q.metrics.storesHit.Observe(0)
 			level.Debug(logger).Log("msg", "empty query time range after max time manipulation")
-			return nil
+			return nil, nil, nil, maxT, false, nil
 		}
 	}
 
 	// Find the list of blocks we need to query given the time range.
-	knownBlocks, knownDeletionMarks, err := q.finder.GetBlocks(ctx, q.userID, minT, maxT)
+	knownBlocks, knownDeletionMarks, warnings, err := getBlocksWithWarnings(ctx, q.finder, q.userID, minT, maxT)
 	if err != nil {
-		return err
+		return nil, nil, nil, maxT, false, err
+	}
+
+	if len(warnings) > 0 {
+		level.Debug(logger).Log("msg", "some blocks have been skipped by the blocks finder", "warnings", len(warnings), "first", warnings[0])
 	}
 
 	if len(knownBlocks) == 0 {
 		q.metrics.storesHit.Observe(0)
 		level.Debug(logger).Log("msg", "no blocks found")
-		return nil
+		return nil, nil, warnings, maxT, false, nil
 	}
 
 	q.metrics.blocksFound.Add(float64(len(knownBlocks)))
+	if blockStats != nil {
+		blockStats.found = len(knownBlocks)
+	}
 
 	if shard != nil && shard.ShardCount > 0 {
 		level.Debug(logger).Log("msg", "filtering blocks due to sharding", "blocksBeforeFiltering", knownBlocks.String(), "shardID", shard.LabelValue())
 
+		numBlocks := len(knownBlocks)
 		result, incompatibleBlocks := filterBlocksByShard(knownBlocks, shard.ShardIndex, shard.ShardCount)
 
-		level.Debug(logger).Log("msg", "result of filtering blocks", "before", len(knownBlocks), "after", len(result), "filtered", len(knownBlocks)-len(result), "incompatible", incompatibleBlocks)
+		level.Debug(logger).Log("msg", "result of filtering blocks", "before", numBlocks, "after", len(result), "filtered", numBlocks-len(result), "incompatible", incompatibleBlocks)
 		q.metrics.blocksWithCompactorShardButIncompatibleQueryShard.Add(float64(incompatibleBlocks))
+		if blockStats != nil {
+			blockStats.filteredByShard = numBlocks - len(result)
+			blockStats.incompatibleShard = incompatibleBlocks
+		}
 
 		knownBlocks = result
 	}
 
+	// Blocks below the min compaction level requested are not queried, and so they're not expected
+	// by the consistency check either.
+	if minLevel, ok := minCompactionLevelFromContext(ctx); ok {
+		numBlocks := len(knownBlocks)
+		knownBlocks = filterBlocksByMinCompactionLevel(knownBlocks, minLevel)
+
+		level.Debug(logger).Log("msg", "filtered blocks by min compaction level", "min_level", minLevel, "before", numBlocks, "after", len(knownBlocks))
+	}
+
+	// Blocks from a source other than the requested one are not queried, and so they're not expected
+	// by the consistency check either.
+	if source, ok := blockSourceFilterFromContext(ctx); ok {
+		numBlocks := len(knownBlocks)
+		knownBlocks = filterBlocksBySource(knownBlocks, source)
+
+		level.Debug(logger).Log("msg", "filtered blocks by source", "source", source, "before", numBlocks, "after", len(knownBlocks))
+	}
+
+	// Blocks not in the requested allow-list are not queried, and so they're not expected
+	// by the consistency check either.
+	if allowList, ok := blockAllowListFromContext(ctx); ok {
+		numBlocks := len(knownBlocks)
+		knownBlocks = filterBlocksByAllowList(knownBlocks, allowList)
+
+		level.Debug(logger).Log("msg", "filtered blocks by allow-list", "allowed", len(allowList), "before", numBlocks, "after", len(knownBlocks))
+	}
+
+	if err := q.checkMaxBlocks(knownBlocks, knownDeletionMarks); err != nil {
+		return nil, nil, nil, maxT, false, err
+	}
+
 	q.metrics.blocksQueried.Add(float64(len(knownBlocks)))
+	if blockStats != nil {
+		blockStats.queried = len(knownBlocks)
+	}
+
+	return knownBlocks, knownDeletionMarks, warnings, maxT, true, nil
+}
+
+func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT int64, shard *sharding.ShardSelector, blockStats *blocksQueryStats,
+	preCheckFunc func(blocks bucketindex.Blocks, minT, maxT int64) error,
+	queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)) (storage.Warnings, error) {
+	knownBlocks, knownDeletionMarks, finderWarnings, maxT, ok, err := q.findBlocksToQuery(ctx, logger, minT, maxT, shard, blockStats)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return finderWarnings, nil
+	}
 
 	level.Debug(logger).Log("msg", "found blocks to query", "expected", knownBlocks.String())
 
+	// Overlapping blocks (eg. not compacted yet) inflate the number of fetched chunks. This is
+	// tracked for diagnostic purposes only, and doesn't change the blocks to query.
+	if overlapping := findOverlappingBlocks(knownBlocks); len(overlapping) > 0 {
+		q.metrics.blocksOverlapping.Add(float64(len(overlapping)))
+		level.Debug(logger).Log("msg", "querying overlapping blocks", "pairs", strings.Join(overlapping, " "))
+	}
+
+	if preCheckFunc != nil {
+		if err := preCheckFunc(knownBlocks, minT, maxT); err != nil {
+			return nil, err
+		}
+	}
+
+	// Limit the tenant's queries concurrently fetching data from store-gateways.
+	if q.tenantQueries != nil {
+		if err := q.tenantQueries.Acquire(ctx, q.userID); err != nil {
+			return nil, err
+		}
+		defer q.tenantQueries.Release(q.userID)
+	}
+
 	var (
 		// At the beginning the list of blocks to query are all known blocks.
 		remainingBlocks = knownBlocks.GetULIDs()
 		attemptedBlocks = map[ulid.ULID][]string{}
 		touchedStores   = map[string]struct{}{}
+		excludedStores  = excludedStoreGatewaysFromContext(ctx)
 
 		resQueriedBlocks = []ulid.ULID(nil)
+
+		// Per-tenant overrides are not validated, so we always run at least one attempt.
+		maxFetchAttempts = math.Max(q.limits.BlocksStoreMaxRefetches(q.userID), 1)
 	)
 
-	for attempt := 1; attempt <= maxFetchSeriesAttempts; attempt++ {
+	// Notify the blocks queried so far once done, even if the query failed.
+	if q.queriedBlocksHook != nil {
+		defer func() {
+			q.queriedBlocksHook(q.userID, minT, maxT, resQueriedBlocks)
+		}()
+	}
+
+	defer func() {
+		if oldestMaxTime, ok := oldestQueriedBlockMaxTime(knownBlocks, resQueriedBlocks); ok {
+			q.metrics.oldestQueriedBlockAge.Observe(time.Since(util.TimeFromMillis(oldestMaxTime)).Seconds())
+		}
+	}()
+
+	// The shard of the tenant is looked up once per query, because it's the same across all attempts.
+	shardInstances := q.stores.TenantShardInstancesCount(q.userID)
+	level.Debug(logger).Log("msg", "querying store-gateways tenant shard", "configured_shard_size", q.limits.StoreGatewayTenantShardSize(q.userID), "shard_instances", shardInstances)
+	stats.FromContext(ctx).SetStoreGatewayShardInstances(uint32(shardInstances))
+
+	// Track the blocks queried and the refetches in the query stats once done, even if the query failed.
+	// They're added to the stats, so that they sum up across the calls of the same query.
+	refetches := 0
+	defer func() {
+		queryStats := stats.FromContext(ctx)
+		queryStats.AddQueriedBlocks(uint64(countDistinctBlocks(resQueriedBlocks)))
+		queryStats.AddStoreGatewayRefetches(uint32(refetches))
+	}()
+
+	// Store-gateways excluded by the caller are never queried, as if they've already been attempted.
+	if len(excludedStores) > 0 {
+		level.Debug(logger).Log("msg", "excluding store-gateways from the query", "excluded", strings.Join(excludedStores, " "))
+
+		for _, blockID := range remainingBlocks {
+			attemptedBlocks[blockID] = append([]string(nil), excludedStores...)
+		}
+	}
+
+	for attempt := 1; attempt <= maxFetchAttempts; attempt++ {
+		// Give store-gateways some time to load the missing blocks (eg. if they're restarting).
+		if attempt > 1 {
+			if err := q.waitBeforeRefetch(ctx, logger, attempt); err != nil {
+				return nil, err
+			}
+			refetches = attempt - 1
+		}
+
 		// Find the set of store-gateway instances having the blocks. The exclude parameter is the
 		// map of blocks queried so far, with the list of store-gateway addresses for each block.
 		clients, err := q.stores.GetClientsFor(q.userID, remainingBlocks, attemptedBlocks)
 		if err != nil {
 			// If it's a retry and we get an error, it means there are no more store-gateways left
-			// from which running another attempt, so we're just stopping retrying.
-			if attempt > 1 {
+			// from which running another attempt, so we're just stopping retrying. The same applies
+			// if some store-gateways have been excluded, so that unqueryable blocks are reported as missing.
+			if attempt > 1 || len(excludedStores) > 0 {
 				level.Warn(logger).Log("msg", "unable to get store-gateway clients while retrying to fetch missing blocks", "err", err)
 				break
 			}
 
-			return err
+			return nil, err
 		}
 		level.Debug(logger).Log("msg", "found store-gateway instances to query", "num instances", len(clients), "attempt", attempt)
 
@@ -566,7 +1225,7 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		// are only meant to cover missing blocks.
 		queriedBlocks, err := queryFunc(clients, minT, maxT)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		level.Debug(logger).Log("msg", "received series from all store-gateways", "queried blocks", strings.Join(convertULIDsToString(queriedBlocks), " "))
 
@@ -587,7 +1246,7 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 			q.metrics.storesHit.Observe(float64(len(touchedStores)))
 			q.metrics.refetches.Observe(float64(attempt - 1))
 
-			return nil
+			return finderWarnings, nil
 		}
 
 		level.Debug(logger).Log("msg", "consistency check failed", "attempt", attempt, "missing blocks", strings.Join(convertULIDsToString(missingBlocks), " "))
@@ -597,14 +1256,311 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 	}
 
 	// We've not been able to query all expected blocks after all retries.
+	err = newStoreConsistencyCheckFailedError(remainingBlocks)
 	level.Warn(util_log.WithContext(ctx, logger)).Log("msg", "failed consistency check", "err", err)
-	return newStoreConsistencyCheckFailedError(remainingBlocks)
+
+	// If the tenant accepts partial data, we return what we've got so far along with a warning
+	// listing the non-queried blocks, instead of failing the whole query.
+	if q.limits.QueryPartialDataOnConsistencyFailure(q.userID) {
+		q.metrics.storesHit.Observe(float64(len(touchedStores)))
+		q.metrics.refetches.Observe(float64(refetches))
+
+		return append(finderWarnings, err), nil
+	}
+
+	return nil, err
+}
+
+// countDistinctBlocks returns the number of distinct blocks in the input list.
+func countDistinctBlocks(blocks []ulid.ULID) int {
+	distinct := make(map[ulid.ULID]struct{}, len(blocks))
+	for _, id := range blocks {
+		distinct[id] = struct{}{}
+	}
+	return len(distinct)
+}
+
+// oldestQueriedBlockMaxTime returns the lowest max time of the queried blocks, looked up in the known
+// blocks. Returns false if no known block has been queried.
+func oldestQueriedBlockMaxTime(knownBlocks bucketindex.Blocks, queriedBlocks []ulid.ULID) (int64, bool) {
+	if len(queriedBlocks) == 0 {
+		return 0, false
+	}
+
+	queried := make(map[ulid.ULID]struct{}, len(queriedBlocks))
+	for _, id := range queriedBlocks {
+		queried[id] = struct{}{}
+	}
+
+	oldest, found := int64(0), false
+	for _, b := range knownBlocks {
+		if _, ok := queried[b.ID]; !ok {
+			continue
+		}
+		if !found || b.MaxTime < oldest {
+			oldest, found = b.MaxTime, true
+		}
+	}
+
+	return oldest, found
+}
+
+type queryStoreAfterOverrideContextKey struct{}
+
+// WithQueryStoreAfterOverride returns a new context overriding, for the request, the configured
+// duration after which the blocks storage is queried. An override of 0 means the blocks storage
+// is queried for the whole time range. Returns an error if the override is negative.
+func WithQueryStoreAfterOverride(ctx context.Context, d time.Duration) (context.Context, error) {
+	if d < 0 {
+		return ctx, fmt.Errorf("the query store after override must be greater than or equal to 0 (got: %s)", d)
+	}
+
+	return context.WithValue(ctx, queryStoreAfterOverrideContextKey{}, d), nil
+}
+
+func queryStoreAfterOverrideFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(queryStoreAfterOverrideContextKey{}).(time.Duration)
+	return d, ok
+}
+
+// QueryPriority is the priority of a query, propagated to store-gateways so that they can serve the
+// interactive queries, eg. from dashboards, before the batch ones, eg. from recording rules. The querier
+// uses it to order the tenant's queries waiting for a slot of the per-tenant concurrent queries limit.
+// Higher values mean higher priority.
+type QueryPriority int
+
+type queryPriorityContextKey struct{}
+
+// WithQueryPriority returns a new context with the priority of the query, sent to store-gateways
+// along with the requests issued by the blocks storage querier, and used to order the queries
+// waiting for a slot in the querier.
+func WithQueryPriority(ctx context.Context, p QueryPriority) context.Context {
+	return context.WithValue(ctx, queryPriorityContextKey{}, p)
+}
+
+func queryPriorityFromContext(ctx context.Context) (QueryPriority, bool) {
+	p, ok := ctx.Value(queryPriorityContextKey{}).(QueryPriority)
+	return p, ok
+}
+
+// newStoreGatewayRequestContext returns the context of the requests to store-gateways, carrying the
+// tenant ID and, if set in the input context, the query priority in the gRPC metadata.
+func (q *blocksStoreQuerier) newStoreGatewayRequestContext(ctx context.Context) context.Context {
+	ctx = grpc_metadata.AppendToOutgoingContext(ctx, storegateway.GrpcContextMetadataTenantID, q.userID)
+
+	if p, ok := queryPriorityFromContext(ctx); ok {
+		ctx = grpc_metadata.AppendToOutgoingContext(ctx, storegateway.GrpcContextMetadataQueryPriority, strconv.Itoa(int(p)))
+	}
+
+	return ctx
+}
+
+type excludedStoreGatewaysContextKey struct{}
+
+// WithExcludedStoreGateways returns a new context with the addresses of the store-gateways
+// which should not be queried by the blocks storage querier. Blocks which are not held
+// by any other store-gateway are reported as missing by the consistency check.
+func WithExcludedStoreGateways(ctx context.Context, addrs []string) context.Context {
+	return context.WithValue(ctx, excludedStoreGatewaysContextKey{}, addrs)
+}
+
+func excludedStoreGatewaysFromContext(ctx context.Context) []string {
+	addrs, _ := ctx.Value(excludedStoreGatewaysContextKey{}).([]string)
+	return addrs
+}
+
+type minCompactionLevelContextKey struct{}
+
+// WithMinCompactionLevel returns a new context requesting the blocks storage querier to only query
+// blocks whose compaction level is greater than or equal to the input level. Blocks with an unknown
+// compaction level (eg. added to the bucket index before the level was tracked) are always queried.
+func WithMinCompactionLevel(ctx context.Context, level int) context.Context {
+	return context.WithValue(ctx, minCompactionLevelContextKey{}, level)
+}
+
+func minCompactionLevelFromContext(ctx context.Context) (int, bool) {
+	level, ok := ctx.Value(minCompactionLevelContextKey{}).(int)
+	return level, ok
+}
+
+// filterBlocksByMinCompactionLevel returns the blocks whose compaction level is unknown or greater than
+// or equal to minLevel. The input slice is not modified.
+func filterBlocksByMinCompactionLevel(blocks bucketindex.Blocks, minLevel int) bucketindex.Blocks {
+	result := make(bucketindex.Blocks, 0, len(blocks))
+	for _, b := range blocks {
+		if b.CompactionLevel == 0 || b.CompactionLevel >= minLevel {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+type blockSourceFilterContextKey struct{}
+
+// WithBlockSourceFilter returns a new context requesting the blocks storage querier to only query
+// blocks from the input source (eg. metadata.ReceiveSource for blocks shipped by ingesters, or
+// metadata.CompactorSource for compacted blocks). Blocks with an unknown source (eg. added to the
+// bucket index before the source was tracked) are never queried. It's meant for debugging only.
+func WithBlockSourceFilter(ctx context.Context, source metadata.SourceType) context.Context {
+	return context.WithValue(ctx, blockSourceFilterContextKey{}, source)
+}
+
+func blockSourceFilterFromContext(ctx context.Context) (metadata.SourceType, bool) {
+	source, ok := ctx.Value(blockSourceFilterContextKey{}).(metadata.SourceType)
+	return source, ok
+}
+
+// filterBlocksBySource returns the blocks whose source is the input one. The input slice is not modified.
+func filterBlocksBySource(blocks bucketindex.Blocks, source metadata.SourceType) bucketindex.Blocks {
+	result := make(bucketindex.Blocks, 0, len(blocks))
+	for _, b := range blocks {
+		if b.Source != "" && metadata.SourceType(b.Source) == source {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+type blockAllowListContextKey struct{}
+
+// WithBlockAllowList returns a new context requesting the blocks storage querier to only query the
+// blocks with the input IDs, among the blocks found for the query time range. It's meant for debugging
+// only, eg. to reproduce an issue against a specific set of blocks. An error is returned if any of the
+// input IDs isn't a valid ULID.
+func WithBlockAllowList(ctx context.Context, ids []string) (context.Context, error) {
+	allowList := make(map[ulid.ULID]struct{}, len(ids))
+	for _, id := range ids {
+		blockID, err := ulid.Parse(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid block ID %q", id)
+		}
+		allowList[blockID] = struct{}{}
+	}
+	return context.WithValue(ctx, blockAllowListContextKey{}, allowList), nil
+}
+
+func blockAllowListFromContext(ctx context.Context) (map[ulid.ULID]struct{}, bool) {
+	allowList, ok := ctx.Value(blockAllowListContextKey{}).(map[ulid.ULID]struct{})
+	return allowList, ok
+}
+
+// filterBlocksByAllowList returns the blocks whose ID is in the allow-list. The input slice is not modified.
+func filterBlocksByAllowList(blocks bucketindex.Blocks, allowList map[ulid.ULID]struct{}) bucketindex.Blocks {
+	result := make(bucketindex.Blocks, 0, len(blocks))
+	for _, b := range blocks {
+		if _, ok := allowList[b.ID]; ok {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+type blockDiagnosticsContextKey struct{}
+
+// WithBlockDiagnostics returns a new context enabling the block diagnostics for the request: the
+// series returned by the blocks storage querier have a warning with the number of blocks found
+// for the query time range, queried, and filtered out by query sharding.
+func WithBlockDiagnostics(ctx context.Context) context.Context {
+	return context.WithValue(ctx, blockDiagnosticsContextKey{}, true)
+}
+
+func blockDiagnosticsFromContext(ctx context.Context) bool {
+	enabled, _ := ctx.Value(blockDiagnosticsContextKey{}).(bool)
+	return enabled
+}
+
+// blocksQueryStats are the number of blocks looked up by a query, reported when block diagnostics are enabled.
+type blocksQueryStats struct {
+	found             int
+	queried           int
+	filteredByShard   int
+	incompatibleShard int
+}
+
+func (s *blocksQueryStats) warning() error {
+	return fmt.Errorf("blocks storage diagnostics: %d blocks found for the query time range, %d blocks queried, %d blocks filtered out by query sharding, %d blocks not filtered by query sharding because of incompatible compactor shard counts",
+		s.found, s.queried, s.filteredByShard, s.incompatibleShard)
 }
 
 func newStoreConsistencyCheckFailedError(remainingBlocks []ulid.ULID) error {
 	return fmt.Errorf("%v. The non-queried blocks are: %s", globalerror.StoreConsistencyCheckFailed.Message("the consistency check failed because some blocks were not queried"), strings.Join(convertULIDsToString(remainingBlocks), " "))
 }
 
+// findOverlappingBlocks returns the pairs of blocks whose time ranges overlap for more than
+// 50% of the shortest of the two ranges, formatted as "<ULID>:<ULID>". The input is not modified.
+func findOverlappingBlocks(blocks bucketindex.Blocks) []string {
+	sorted := make(bucketindex.Blocks, len(blocks))
+	copy(sorted, blocks)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].MinTime != sorted[j].MinTime {
+			return sorted[i].MinTime < sorted[j].MinTime
+		}
+		return sorted[i].ID.Compare(sorted[j].ID) < 0
+	})
+
+	var pairs []string
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			// Blocks are sorted by min time, so no further block can overlap with a.
+			if b.MinTime >= a.MaxTime {
+				break
+			}
+
+			overlap := math.Min64(a.MaxTime, b.MaxTime) - b.MinTime
+			shortest := math.Min64(a.MaxTime-a.MinTime, b.MaxTime-b.MinTime)
+			if shortest > 0 && overlap*2 > shortest {
+				pairs = append(pairs, a.ID.String()+":"+b.ID.String())
+			}
+		}
+	}
+
+	return pairs
+}
+
+// canConcatBlocksSeriesSets returns whether the input series sets, fetched from store-gateways, contain different
+// series, so that they can be concatenated instead of being merged. It's the case when all the input blocks have
+// been split by the split-and-merge compactor in the same number of shards, and each compactor shard has been
+// fetched in a single set: the split-and-merge compactor shards the series by their labels, so the blocks of
+// different compactor shards contain different series.
+func canConcatBlocksSeriesSets(sets []storage.SeriesSet, blocks bucketindex.Blocks) bool {
+	if len(sets) < 2 {
+		return true
+	}
+
+	compactorShardIDs := make(map[ulid.ULID]string, len(blocks))
+	for _, b := range blocks {
+		compactorShardIDs[b.ID] = b.CompactorShardID
+	}
+
+	var (
+		shardCount uint64
+		setByShard = map[uint64]int{}
+	)
+	for setIdx, set := range sets {
+		bset, ok := set.(*blockQuerierSeriesSet)
+		if !ok || (len(bset.blocks) == 0 && len(bset.series) > 0) {
+			return false
+		}
+
+		for _, id := range bset.blocks {
+			index, count, err := sharding.ParseShardIDLabelValue(compactorShardIDs[id])
+			if err != nil {
+				return false
+			}
+			if shardCount == 0 {
+				shardCount = count
+			} else if count != shardCount {
+				return false
+			}
+			if prev, ok := setByShard[index]; ok && prev != setIdx {
+				return false
+			}
+			setByShard[index] = setIdx
+		}
+	}
+	return true
+}
+
 // filterBlocksByShard removes blocks that can be safely ignored when using query sharding. We know that block can be safely
 // ignored, if it was compacted using split-and-merge compactor, and it has a valid compactor shard ID. We exploit the
 // fact that split-and-merge compactor and query-sharding use the same series-sharding algorithm.
@@ -690,7 +1646,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 	leftChunksLimit int,
 ) ([]storage.SeriesSet, []ulid.ULID, storage.Warnings, int, error) {
 	var (
-		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, storegateway.GrpcContextMetadataTenantID, q.userID)
+		reqCtx        = q.newStoreGatewayRequestContext(ctx)
 		g, gCtx       = errgroup.WithContext(reqCtx)
 		mtx           = sync.Mutex{}
 		seriesSets    = []storage.SeriesSet(nil)
@@ -702,26 +1658,60 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 		reqStats      = stats.FromContext(ctx)
 	)
 
+	// Give up early if the query is not expected to complete before its deadline, so that
+	// the failure is attributed to the querier instead of a slow store-gateway.
+	if err := q.checkDeadlineSlack(ctx); err != nil {
+		level.Warn(spanLog).Log("msg", "not fetching series from store-gateways because the query deadline is too close", "err", err)
+		return nil, nil, nil, 0, err
+	}
+
+	// Limit the number of streams concurrently read, to protect the querier from buffering
+	// series from many store-gateways at the same time.
+	var streamsSem *semaphore.Weighted
+	if q.maxStreams > 0 {
+		streamsSem = semaphore.NewWeighted(int64(q.maxStreams))
+	}
+
 	// Concurrently fetch series from all clients.
+	var dispatchErr error
 	for c, blockIDs := range clients {
 		// Change variables scope since it will be used in a goroutine.
 		c := c
 		blockIDs := blockIDs
 
+		// Wait for a free slot. The acquire fails only if the context has been canceled.
+		if streamsSem != nil {
+			if dispatchErr = streamsSem.Acquire(gCtx, 1); dispatchErr != nil {
+				break
+			}
+		}
+
 		g.Go(func() error {
+			if streamsSem != nil {
+				defer streamsSem.Release(1)
+			}
+
 			// See: https://github.com/prometheus/prometheus/pull/8050
 			// TODO(goutham): we should ideally be passing the hints down to the storage layer
 			// and let the TSDB return us data with no chunks as in prometheus#8050.
 			// But this is an acceptable workaround for now.
-			skipChunks := sp != nil && sp.Func == "series"
+			skipChunks := isSeriesOnlySelect(sp)
 
-			req, err := createSeriesRequest(minT, maxT, convertedMatchers, skipChunks, blockIDs)
+			req, err := createSeriesRequest(minT, maxT, convertedMatchers, skipChunks, blockIDs, q.partialResponseStrategy())
 			if err != nil {
 				return errors.Wrapf(err, "failed to create series request")
 			}
 
-			stream, err := c.Series(gCtx, req)
+			// The request duration includes the time spent consuming the whole stream.
+			startTime := time.Now()
+			defer q.observeStoreGatewayRequestDuration(gCtx, "Series", c, startTime)
+
+			callCtx, cancel := q.newStoreGatewayCallContext(gCtx)
+			defer cancel()
+
+			stream, err := c.Series(callCtx, req, storeGatewayCallOptions(q.seriesCompress)...)
 			if err != nil {
+				q.trackPerCallTimeout(gCtx, callCtx, "Series")
 				level.Warn(spanLog).Log("msg", "failed to fetch series", "remote", c.RemoteAddress(), "err", err)
 				return nil
 			}
@@ -729,6 +1719,8 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 			mySeries := []*storepb.Series(nil)
 			myWarnings := storage.Warnings(nil)
 			myQueriedBlocks := []ulid.ULID(nil)
+			myBlockSeriesCounts := []storegatewaypb.BlockSeriesCount(nil)
+			myChunkBytesFromCache := uint64(0)
 
 			for {
 				// Ensure the context hasn't been canceled in the meanwhile (eg. an error occurred
@@ -742,6 +1734,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					break
 				}
 				if err != nil {
+					q.trackPerCallTimeout(gCtx, callCtx, "Series")
 					level.Warn(spanLog).Log("msg", "failed to receive series", "remote", c.RemoteAddress(), "err", err)
 					return nil
 				}
@@ -753,6 +1746,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					// Add series fingerprint to query limiter; will return error if we are over the limit
 					limitErr := queryLimiter.AddSeries(mimirpb.FromLabelsToLabelAdapters(s.PromLabels()))
 					if limitErr != nil {
+						q.metrics.limitRejections.WithLabelValues(limitMaxSeries).Inc()
 						return validation.LimitError(limitErr.Error())
 					}
 
@@ -762,13 +1756,16 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					if maxChunksLimit > 0 {
 						actual := numChunks.Add(int32(chunksCount))
 						if actual > int32(leftChunksLimit) {
+							q.metrics.limitRejections.WithLabelValues(limitMaxChunksPerQuery).Inc()
 							return validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, util.LabelMatchersToString(matchers), maxChunksLimit))
 						}
 					}
 					if chunkBytesLimitErr := queryLimiter.AddChunkBytes(chunksSize); chunkBytesLimitErr != nil {
+						q.metrics.limitRejections.WithLabelValues(limitMaxChunkBytes).Inc()
 						return validation.LimitError(chunkBytesLimitErr.Error())
 					}
 					if chunkLimitErr := queryLimiter.AddChunks(len(s.Chunks)); chunkLimitErr != nil {
+						q.metrics.limitRejections.WithLabelValues(limitMaxChunksPerQuery).Inc()
 						return validation.LimitError(chunkLimitErr.Error())
 					}
 				}
@@ -778,6 +1775,19 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 				}
 
 				if h := resp.GetHints(); h != nil {
+					// Store-gateways may report the series touched in each block, and the chunk bytes
+					// read from the cache, in a separate hints response with its own type.
+					if types.Is(h, &storegatewaypb.SeriesResponseHintsExtension{}) {
+						ext := storegatewaypb.SeriesResponseHintsExtension{}
+						if err := types.UnmarshalAny(h, &ext); err != nil {
+							return errors.Wrapf(err, "failed to unmarshal series hints extension from %s", c.RemoteAddress())
+						}
+
+						myBlockSeriesCounts = append(myBlockSeriesCounts, ext.BlockSeriesCounts...)
+						myChunkBytesFromCache += ext.FetchedChunkBytesFromCache
+						continue
+					}
+
 					hints := hintspb.SeriesResponseHints{}
 					if err := types.UnmarshalAny(h, &hints); err != nil {
 						return errors.Wrapf(err, "failed to unmarshal series hints from %s", c.RemoteAddress())
@@ -797,7 +1807,19 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 			reqStats.AddFetchedSeries(uint64(numSeries))
 			reqStats.AddFetchedChunkBytes(uint64(chunkBytes))
+			fromCache, fromStorage := splitChunkBytesBySource(uint64(chunkBytes), myChunkBytesFromCache)
+			reqStats.AddFetchedChunkBytesFromCache(fromCache)
+			reqStats.AddFetchedChunkBytesFromStorage(fromStorage)
 			reqStats.AddFetchedChunks(uint64(chunksFetched))
+			q.metrics.fetchedChunkBytes.WithLabelValues(compressionLabelValue(q.seriesCompress)).Add(float64(chunkBytes))
+			for encoding, bytes := range countChunkBytesByEncoding(mySeries...) {
+				q.metrics.fetchedChunkBytesByEncoding.WithLabelValues(encoding).Add(float64(bytes))
+			}
+
+			q.checkSlowStoreGatewayResponse(spanLog, "Series", c, time.Since(startTime),
+				"fetched_series", numSeries,
+				"fetched_chunk_bytes", chunkBytes,
+				"fetched_chunks", chunksFetched)
 
 			level.Debug(spanLog).Log("msg", "received series from store-gateway",
 				"instance", c.RemoteAddress(),
@@ -806,10 +1828,24 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 				"fetched chunks", chunksFetched,
 				"requested blocks", strings.Join(convertULIDsToString(blockIDs), " "),
 				"queried blocks", strings.Join(convertULIDsToString(myQueriedBlocks), " "))
+			logQueriedBlocksToSpan(spanLog, c.RemoteAddress(), myQueriedBlocks)
+
+			if len(myBlockSeriesCounts) > 0 {
+				touchedSeries := uint64(0)
+				for _, count := range myBlockSeriesCounts {
+					touchedSeries += count.Series
+				}
+				reqStats.AddTouchedBlockSeries(touchedSeries)
+
+				level.Debug(spanLog).Log("msg", "received series touched per block from store-gateway",
+					"instance", c.RemoteAddress(),
+					"touched series", touchedSeries,
+					"blocks", formatBlockSeriesCounts(myBlockSeriesCounts))
+			}
 
 			// Store the result.
 			mtx.Lock()
-			seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries})
+			seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries, blocks: myQueriedBlocks})
 			warnings = append(warnings, myWarnings...)
 			queriedBlocks = append(queriedBlocks, myQueriedBlocks...)
 			mtx.Unlock()
@@ -822,6 +1858,9 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 	if err := g.Wait(); err != nil {
 		return nil, nil, nil, 0, err
 	}
+	if dispatchErr != nil {
+		return nil, nil, nil, 0, dispatchErr
+	}
 
 	return seriesSets, queriedBlocks, warnings, int(numChunks.Load()), nil
 }
@@ -834,7 +1873,7 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 	matchers []storepb.LabelMatcher,
 ) ([][]string, storage.Warnings, []ulid.ULID, error) {
 	var (
-		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, storegateway.GrpcContextMetadataTenantID, q.userID)
+		reqCtx        = q.newStoreGatewayRequestContext(ctx)
 		g, gCtx       = errgroup.WithContext(reqCtx)
 		mtx           = sync.Mutex{}
 		nameSets      = [][]string{}
@@ -850,13 +1889,20 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 		blockIDs := blockIDs
 
 		g.Go(func() error {
-			req, err := createLabelNamesRequest(minT, maxT, blockIDs, matchers)
+			req, err := createLabelNamesRequest(minT, maxT, blockIDs, matchers, q.partialResponseStrategy())
 			if err != nil {
 				return errors.Wrapf(err, "failed to create label names request")
 			}
 
-			namesResp, err := c.LabelNames(gCtx, req)
+			callCtx, cancel := q.newStoreGatewayCallContext(gCtx)
+			defer cancel()
+
+			startTime := time.Now()
+			namesResp, err := c.LabelNames(callCtx, req, storeGatewayCallOptions(q.labelsCompress)...)
+			q.observeStoreGatewayRequestDuration(gCtx, "LabelNames", c, startTime)
+			q.checkSlowStoreGatewayResponse(spanLog, "LabelNames", c, time.Since(startTime))
 			if err != nil {
+				q.trackPerCallTimeout(gCtx, callCtx, "LabelNames")
 				level.Warn(spanLog).Log("msg", "failed to fetch label names", "remote", c.RemoteAddress(), "err", err)
 				return nil
 			}
@@ -881,6 +1927,7 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 				"num labels", len(namesResp.Names),
 				"requested blocks", strings.Join(convertULIDsToString(blockIDs), " "),
 				"queried blocks", strings.Join(convertULIDsToString(myQueriedBlocks), " "))
+			logQueriedBlocksToSpan(spanLog, c.RemoteAddress(), myQueriedBlocks)
 
 			// Store the result.
 			mtx.Lock()
@@ -912,7 +1959,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	matchers ...*labels.Matcher,
 ) ([][]string, storage.Warnings, []ulid.ULID, error) {
 	var (
-		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, storegateway.GrpcContextMetadataTenantID, q.userID)
+		reqCtx        = q.newStoreGatewayRequestContext(ctx)
 		g, gCtx       = errgroup.WithContext(reqCtx)
 		mtx           = sync.Mutex{}
 		valueSets     = [][]string{}
@@ -928,13 +1975,20 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 		blockIDs := blockIDs
 
 		g.Go(func() error {
-			req, err := createLabelValuesRequest(minT, maxT, name, blockIDs, matchers...)
+			req, err := createLabelValuesRequest(minT, maxT, name, blockIDs, q.partialResponseStrategy(), matchers...)
 			if err != nil {
 				return errors.Wrapf(err, "failed to create label values request")
 			}
 
-			valuesResp, err := c.LabelValues(gCtx, req)
+			callCtx, cancel := q.newStoreGatewayCallContext(gCtx)
+			defer cancel()
+
+			startTime := time.Now()
+			valuesResp, err := c.LabelValues(callCtx, req, storeGatewayCallOptions(q.labelsCompress)...)
+			q.observeStoreGatewayRequestDuration(gCtx, "LabelValues", c, startTime)
+			q.checkSlowStoreGatewayResponse(spanLog, "LabelValues", c, time.Since(startTime))
 			if err != nil {
+				q.trackPerCallTimeout(gCtx, callCtx, "LabelValues")
 				level.Warn(spanLog).Log("msg", "failed to fetch label values", "remote", c.RemoteAddress(), "err", err)
 				return nil
 			}
@@ -959,6 +2013,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 				"num values", len(valuesResp.Values),
 				"requested blocks", strings.Join(convertULIDsToString(blockIDs), " "),
 				"queried blocks", strings.Join(convertULIDsToString(myQueriedBlocks), " "))
+			logQueriedBlocksToSpan(spanLog, c.RemoteAddress(), myQueriedBlocks)
 
 			// Values returned need not be sorted, but we need them to be sorted so we can merge.
 			sort.Strings(valuesResp.Values)
@@ -984,7 +2039,250 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	return valueSets, warnings, queriedBlocks, nil
 }
 
-func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID) (*storepb.SeriesRequest, error) {
+// checkMaxBlocks returns an error if the number of blocks to query exceeds the per-tenant limit.
+// Blocks marked for deletion are not counted, because they've been superseded (eg. compacted into
+// another block, which is counted too) and will stop being queried once the deletion delay expires.
+func (q *blocksStoreQuerier) checkMaxBlocks(blocks bucketindex.Blocks, deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark) error {
+	limit := q.limits.MaxBlocksPerQuery(q.userID)
+	if limit <= 0 {
+		return nil
+	}
+
+	numBlocks := 0
+	for _, b := range blocks {
+		if _, marked := deletionMarks[b.ID]; !marked {
+			numBlocks++
+		}
+	}
+
+	if numBlocks > limit {
+		q.metrics.limitRejections.WithLabelValues(limitMaxBlocks).Inc()
+		return validation.LimitError(fmt.Sprintf(maxBlocksPerQueryLimitMsgFormat, numBlocks, limit))
+	}
+
+	return nil
+}
+
+// checkEstimatedChunks returns an error if the number of chunks estimated from the blocks metadata
+// exceeds the max chunks per query limit multiplied by the per-tenant safety factor. Blocks without
+// stats in the bucket index are not accounted in the estimate.
+func (q *blocksStoreQuerier) checkEstimatedChunks(logger log.Logger, blocks bucketindex.Blocks, minT, maxT int64, shard *sharding.ShardSelector, matchers []*labels.Matcher, maxChunksLimit int) error {
+	multiplier := q.limits.MaxEstimatedChunksPerQueryMultiplier(q.userID)
+	if maxChunksLimit <= 0 || multiplier <= 0 {
+		return nil
+	}
+
+	estimated := estimateChunksFromBlocks(blocks, minT, maxT, shard)
+	limit := float64(maxChunksLimit) * multiplier
+	level.Debug(logger).Log("msg", "estimated number of chunks to fetch from store-gateways", "estimated", estimated, "limit", limit)
+
+	if estimated > limit {
+		q.metrics.limitRejections.WithLabelValues(limitMaxEstimatedChunks).Inc()
+		return validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, util.LabelMatchersToString(matchers), maxChunksLimit))
+	}
+
+	return nil
+}
+
+// estimateChunksFromBlocks estimates the number of chunks a query will fetch from the input blocks,
+// assuming chunks are evenly distributed over each block's time range and series are evenly
+// distributed across shards. Matchers are not taken into account, so the estimate is an upper bound.
+func estimateChunksFromBlocks(blocks bucketindex.Blocks, minT, maxT int64, shard *sharding.ShardSelector) float64 {
+	estimated := float64(0)
+
+	for _, b := range blocks {
+		if b.NumChunks == 0 {
+			continue
+		}
+
+		// The block max time is exclusive, while the query max time is inclusive.
+		ratio := float64(1)
+		if blockRange := b.MaxTime - b.MinTime; blockRange > 0 {
+			overlap := math.Min64(maxT+1, b.MaxTime) - math.Max64(minT, b.MinTime)
+			ratio = float64(math.Max64(math.Min64(overlap, blockRange), 0)) / float64(blockRange)
+		}
+
+		// A block split by the compactor only contains a portion of the series, so
+		// the query shard reads a smaller fraction of it.
+		if shard != nil && shard.ShardCount > 0 {
+			compactorShardCount := uint64(1)
+			if b.CompactorShardID != "" {
+				if _, count, err := sharding.ParseShardIDLabelValue(b.CompactorShardID); err == nil && count > 0 {
+					compactorShardCount = count
+				}
+			}
+
+			if compactorShardCount < shard.ShardCount {
+				ratio = ratio * float64(compactorShardCount) / float64(shard.ShardCount)
+			}
+		}
+
+		estimated += float64(b.NumChunks) * ratio
+	}
+
+	return estimated
+}
+
+// checkDeadlineSlack returns an error if the time left before the ctx deadline
+// is lower than the configured deadline slack.
+func (q *blocksStoreQuerier) checkDeadlineSlack(ctx context.Context) error {
+	if q.deadlineSlack <= 0 {
+		return nil
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+
+	if left := time.Until(deadline); left < q.deadlineSlack {
+		q.metrics.deadlineAbortedQueries.Inc()
+		return errors.Wrapf(context.DeadlineExceeded, "the querier gave up fetching series from store-gateways because the time left before the query deadline (%s) is lower than the configured slack (%s)", left, q.deadlineSlack)
+	}
+
+	return nil
+}
+
+// refetchBackoffDelay returns the time to wait before the input fetch attempt, which must be
+// greater than 1. The delay doubles at each attempt, up to the max backoff, and is jittered.
+func (q *blocksStoreQuerier) refetchBackoffDelay(attempt int) time.Duration {
+	if q.refetchBackoff.min <= 0 {
+		return 0
+	}
+
+	delay := q.refetchBackoff.min
+	for i := 2; i < attempt && delay < q.refetchBackoff.max; i++ {
+		delay *= 2
+	}
+	if delay > q.refetchBackoff.max {
+		delay = q.refetchBackoff.max
+	}
+
+	return util.DurationWithJitter(delay, 0.2)
+}
+
+// waitBeforeRefetch waits for the backoff delay before the input fetch attempt. It doesn't wait if
+// the delay would exceed the ctx deadline, and returns an error only if the ctx is done while waiting.
+func (q *blocksStoreQuerier) waitBeforeRefetch(ctx context.Context, logger log.Logger, attempt int) error {
+	delay := q.refetchBackoffDelay(attempt)
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		level.Debug(logger).Log("msg", "not waiting before fetching missing blocks because the query deadline is too close", "attempt", attempt, "backoff", delay)
+		return nil
+	}
+
+	level.Debug(logger).Log("msg", "waiting before fetching missing blocks", "attempt", attempt, "backoff", delay)
+
+	if q.refetchBackoff.wait != nil {
+		return q.refetchBackoff.wait(ctx, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// compressionLabelValue returns the value of the compression label for the input gRPC compression.
+func compressionLabelValue(compression string) string {
+	if compression == "" {
+		return "none"
+	}
+	return compression
+}
+
+// newStoreGatewayCallContext returns the context to use for a single store-gateway call, honoring
+// the configured per-call timeout. The returned cancel function must always be called.
+func (q *blocksStoreQuerier) newStoreGatewayCallContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if q.perCallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, q.perCallTimeout)
+	return context.WithValue(callCtx, storeGatewayCallParentContextKey{}, ctx), cancel
+}
+
+type storeGatewayCallParentContextKey struct{}
+
+// isStoreGatewayCallTimedOut returns whether the input store-gateway call context hit the per-call
+// timeout while the parent query context is still valid.
+func isStoreGatewayCallTimedOut(callCtx context.Context) bool {
+	parentCtx, ok := callCtx.Value(storeGatewayCallParentContextKey{}).(context.Context)
+	return ok && parentCtx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded)
+}
+
+// trackPerCallTimeout tracks the store-gateway call as timed out if the call context hit the per-call
+// timeout while the parent context is still valid. Like any other store-gateway failure, blocks not
+// queried because of the timeout are fetched again from other store-gateways by the consistency check.
+func (q *blocksStoreQuerier) trackPerCallTimeout(parentCtx, callCtx context.Context, operation string) {
+	if parentCtx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		q.metrics.perCallTimeouts.WithLabelValues(operation).Inc()
+	}
+}
+
+// observeStoreGatewayRequestDuration tracks the duration of a request issued to the input store-gateway
+// client. Requests failed because the context has been canceled in the meanwhile (eg. an error occurred
+// while querying another store-gateway) are not tracked, because they don't reflect the store-gateway latency.
+func (q *blocksStoreQuerier) observeStoreGatewayRequestDuration(ctx context.Context, operation string, c BlocksStoreClient, startTime time.Time) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return
+	}
+
+	q.metrics.storeGatewayRequestDuration.WithLabelValues(operation, c.RemoteAddress()).Observe(time.Since(startTime).Seconds())
+}
+
+// logQueriedBlocksToSpan logs to the span which store-gateway served each queried block,
+// using the block ID as field key and the store-gateway address as value.
+func logQueriedBlocksToSpan(spanLog *spanlogger.SpanLogger, remoteAddr string, blockIDs []ulid.ULID) {
+	if len(blockIDs) == 0 {
+		return
+	}
+
+	fields := make([]otlog.Field, 0, len(blockIDs)+1)
+	fields = append(fields, otlog.String("event", "queried blocks served by store-gateway"))
+	for _, blockID := range blockIDs {
+		fields = append(fields, otlog.String(blockID.String(), remoteAddr))
+	}
+
+	spanLog.Span.LogFields(fields...)
+}
+
+// checkSlowStoreGatewayResponse logs and tracks the response of the input store-gateway if it
+// took longer than the slow response threshold. The input key-value pairs are added to the log.
+func (q *blocksStoreQuerier) checkSlowStoreGatewayResponse(logger log.Logger, operation string, c BlocksStoreClient, duration time.Duration, keyvals ...interface{}) {
+	if q.slowThreshold <= 0 || duration < q.slowThreshold {
+		return
+	}
+
+	q.metrics.slowResponses.WithLabelValues(operation).Inc()
+	level.Warn(util_log.WithUserID(q.userID, logger)).Log(append([]interface{}{
+		"msg", "slow store-gateway response",
+		"operation", operation,
+		"remote", c.RemoteAddress(),
+		"duration", duration,
+		"threshold", q.slowThreshold,
+	}, keyvals...)...)
+}
+
+// partialResponseStrategy returns the partial response strategy store-gateways should honor
+// when failing to query some blocks. Blocks not queried because of the WARN strategy are not
+// reported in the response hints, so they're still refetched by the consistency check.
+func (q *blocksStoreQuerier) partialResponseStrategy() storepb.PartialResponseStrategy {
+	if q.limits.StoreGatewayPartialResponse(q.userID) {
+		return storepb.PartialResponseStrategy_WARN
+	}
+	return storepb.PartialResponseStrategy_ABORT
+}
+
+func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID, strategy storepb.PartialResponseStrategy) (*storepb.SeriesRequest, error) {
 	// Selectively query only specific blocks.
 	hints := &hintspb.SeriesRequestHints{
 		BlockMatchers: []storepb.LabelMatcher{
@@ -1005,17 +2303,22 @@ func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skip
 		MinTime:                 minT,
 		MaxTime:                 maxT,
 		Matchers:                matchers,
-		PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+		PartialResponseStrategy: strategy,
 		Hints:                   anyHints,
 		SkipChunks:              skipChunks,
 	}, nil
 }
 
-func createLabelNamesRequest(minT, maxT int64, blockIDs []ulid.ULID, matchers []storepb.LabelMatcher) (*storepb.LabelNamesRequest, error) {
+// createLabelNamesRequest returns the request to fetch the label names from the input blocks. The
+// label names request has no field to skip chunks, like the series one does, because the store-gateway
+// only looks up series metadata from the blocks index to serve it, even when matchers are specified.
+// For this reason, label names must never be fetched with a series request.
+func createLabelNamesRequest(minT, maxT int64, blockIDs []ulid.ULID, matchers []storepb.LabelMatcher, strategy storepb.PartialResponseStrategy) (*storepb.LabelNamesRequest, error) {
 	req := &storepb.LabelNamesRequest{
-		Start:    minT,
-		End:      maxT,
-		Matchers: matchers,
+		Start:                   minT,
+		End:                     maxT,
+		Matchers:                matchers,
+		PartialResponseStrategy: strategy,
 	}
 
 	// Selectively query only specific blocks.
@@ -1039,12 +2342,16 @@ func createLabelNamesRequest(minT, maxT int64, blockIDs []ulid.ULID, matchers []
 	return req, nil
 }
 
-func createLabelValuesRequest(minT, maxT int64, label string, blockIDs []ulid.ULID, matchers ...*labels.Matcher) (*storepb.LabelValuesRequest, error) {
+// createLabelValuesRequest returns the request to fetch the values of the label from the input blocks.
+// Like for label names, the store-gateway only looks up the blocks index to serve it, so no chunk is
+// ever fetched.
+func createLabelValuesRequest(minT, maxT int64, label string, blockIDs []ulid.ULID, strategy storepb.PartialResponseStrategy, matchers ...*labels.Matcher) (*storepb.LabelValuesRequest, error) {
 	req := &storepb.LabelValuesRequest{
-		Start:    minT,
-		End:      maxT,
-		Label:    label,
-		Matchers: convertMatchersToLabelMatcher(matchers),
+		Start:                   minT,
+		End:                     maxT,
+		Label:                   label,
+		Matchers:                convertMatchersToLabelMatcher(matchers),
+		PartialResponseStrategy: strategy,
 	}
 
 	// Selectively query only specific blocks.
@@ -1091,6 +2398,25 @@ func convertBlockHintsToULIDs(hints []hintspb.Block) ([]ulid.ULID, error) {
 	return res, nil
 }
 
+// splitChunkBytesBySource splits the chunk bytes fetched from a store-gateway between the ones it read
+// from the cache, as reported in the series response hints, and the ones it read from the object storage.
+// All bytes are attributed to the object storage if the store-gateway didn't report the cache ones.
+func splitChunkBytesBySource(chunkBytes, fromCache uint64) (uint64, uint64) {
+	if fromCache > chunkBytes {
+		fromCache = chunkBytes
+	}
+	return fromCache, chunkBytes - fromCache
+}
+
+// formatBlockSeriesCounts returns a human readable representation of the series and chunks touched in each block.
+func formatBlockSeriesCounts(counts []storegatewaypb.BlockSeriesCount) string {
+	formatted := make([]string, 0, len(counts))
+	for _, count := range counts {
+		formatted = append(formatted, fmt.Sprintf("%s:%d/%d", count.BlockId, count.Series, count.Chunks))
+	}
+	return strings.Join(formatted, " ")
+}
+
 // countChunksAndBytes returns the number of chunks and size of the chunks making up the provided series in bytes
 func countChunksAndBytes(series ...*storepb.Series) (chunks, bytes int) {
 	for _, s := range series {
@@ -1102,3 +2428,31 @@ func countChunksAndBytes(series ...*storepb.Series) (chunks, bytes int) {
 
 	return chunks, bytes
 }
+
+// countChunkBytesByEncoding returns the size in bytes of the chunks making up the provided series, by
+// the encoding of the chunks. The sizes add up to the one returned by countChunksAndBytes().
+func countChunkBytesByEncoding(series ...*storepb.Series) map[string]int {
+	bytes := map[string]int{}
+	for _, s := range series {
+		for _, c := range s.Chunks {
+			bytes[chunkEncodingLabelValue(c)] += c.Size()
+		}
+	}
+
+	return bytes
+}
+
+// chunkEncodingLabelValue returns the label value of the chunk encoding. Encodings unknown
+// to the querier are grouped together, to keep the metric cardinality bounded.
+func chunkEncodingLabelValue(c storepb.AggrChunk) string {
+	if c.Raw == nil {
+		return "unknown"
+	}
+
+	name, ok := storepb.Chunk_Encoding_name[int32(c.Raw.Type)]
+	if !ok {
+		return "unknown"
+	}
+
+	return strings.ToLower(name)
+}
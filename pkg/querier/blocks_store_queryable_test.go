@@ -6,6 +6,7 @@
 package querier
 
 import (
+	"bytes"
 	"context"
 	crand "crypto/rand"
 	"fmt"
@@ -20,23 +21,32 @@ import (
 	"github.com/gogo/protobuf/types"
 	"github.com/grafana/dskit/services"
 	"github.com/oklog/ulid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/store/hintspb"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
+	grpc_metadata "google.golang.org/grpc/metadata"
 
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/limiter"
@@ -78,9 +88,13 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 		limits            BlocksStoreLimits
 		queryLimiter      *limiter.QueryLimiter
 		expectedSeries    []seriesResult
+		expectedWarnings  storage.Warnings
 		expectedErr       error
 		expectedMetrics   string
 		queryShardID      string
+
+		// The limit expected to reject the query, as tracked by the limit rejections metric.
+		expectedLimitRejection string
 	}{
 		"no block in the storage matching the query time range": {
 			finderResult: nil,
@@ -223,6 +237,34 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				},
 			},
 		},
+		"multiple store-gateway instances hold the same block and the chunks deduplication is enabled (single returned series)": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponseWithSamples(labels.Labels{metricNameLabel}, promql.Point{T: minT, V: 1}, promql.Point{T: minT + 1, V: 2}),
+						mockHintsResponse(block1),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponseWithSamples(labels.Labels{metricNameLabel}, promql.Point{T: minT, V: 1}, promql.Point{T: minT + 1, V: 2}),
+						mockHintsResponse(block1),
+					}}: {block1},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{storeGatewayChunksDeduplication: true},
+			queryLimiter: noOpQueryLimiter,
+			expectedSeries: []seriesResult{
+				{
+					lbls: labels.New(metricNameLabel),
+					values: []valueResult{
+						{t: minT, v: 1},
+						{t: minT + 1, v: 2},
+					},
+				},
+			},
+		},
 		"multiple store-gateway instances holds the required blocks with overlapping series (multiple returned series)": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -287,6 +329,9 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 				cortex_querier_storegateway_refetches_per_query_sum 0
 				cortex_querier_storegateway_refetches_per_query_count 1
@@ -323,6 +368,76 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 			queryLimiter: noOpQueryLimiter,
 			expectedErr:  newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
 		},
+		"a single store-gateway instance has some missing blocks and the tenant accepts partial data (consistency check failed)": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				// First attempt returns a client whose response does not include all expected blocks.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT+1, 2),
+						mockHintsResponse(block1),
+					}}: {block1},
+				},
+				// Second attempt returns an error because there are no other store-gateways left.
+				errors.New("no store-gateway remaining after exclude"),
+			},
+			limits:       &blocksStoreLimitsMock{queryPartialDataOnConsistencyFailure: true},
+			queryLimiter: noOpQueryLimiter,
+			expectedSeries: []seriesResult{
+				{
+					lbls: labels.New(metricNameLabel, series1Label),
+					values: []valueResult{
+						{t: minT, v: 1},
+						{t: minT + 1, v: 2},
+					},
+				},
+			},
+			expectedWarnings: storage.Warnings{newStoreConsistencyCheckFailedError([]ulid.ULID{block2})},
+			expectedMetrics: `
+				# HELP cortex_querier_storegateway_instances_hit_per_query Number of store-gateway instances hit for a single query.
+				# TYPE cortex_querier_storegateway_instances_hit_per_query histogram
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="1"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="5"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="6"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="7"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="8"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="9"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="10"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_instances_hit_per_query_sum 1
+				cortex_querier_storegateway_instances_hit_per_query_count 1
+
+				# HELP cortex_querier_storegateway_refetches_per_query Number of re-fetches attempted while querying store-gateway instances due to missing blocks.
+				# TYPE cortex_querier_storegateway_refetches_per_query histogram
+				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_refetches_per_query_sum 1
+				cortex_querier_storegateway_refetches_per_query_count 1
+
+				# HELP cortex_querier_blocks_found_total Number of blocks found based on query time range.
+				# TYPE cortex_querier_blocks_found_total counter
+				cortex_querier_blocks_found_total 2
+				# HELP cortex_querier_blocks_queried_total Number of blocks queried to satisfy query. Compared to blocks found, some blocks may have been filtered out thanks to query and compactor sharding.
+				# TYPE cortex_querier_blocks_queried_total counter
+				cortex_querier_blocks_queried_total 2
+				# HELP cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total Blocks that couldn't be checked for query and compactor sharding optimization due to incompatible shard counts.
+				# TYPE cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total counter
+				cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total 0
+			`,
+		},
 		"multiple store-gateway instances have some missing blocks (consistency check failed)": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -423,6 +538,9 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
 				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 0
 				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 				cortex_querier_storegateway_refetches_per_query_sum 2
 				cortex_querier_storegateway_refetches_per_query_count 1
@@ -438,6 +556,103 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total 0
 			`,
 		},
+		"a single store-gateway instance has some missing blocks and the per-tenant max refetches doesn't allow retries (consistency check failed)": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				// The only attempt returns a client whose response does not include all expected blocks.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1, block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{blocksStoreMaxRefetches: 1},
+			queryLimiter: noOpQueryLimiter,
+			expectedErr:  newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
+		},
+		"multiple store-gateway instances have some missing blocks but queried from a replica during attempts beyond the default max refetches": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				// First three attempts return a client whose response does not include block2.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1, block2},
+				},
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{}}: {block2},
+				},
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{}}: {block2},
+				},
+				// Fourth attempt returns the missing block.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "4.4.4.4", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT+1, 2),
+						mockHintsResponse(block2),
+					}}: {block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{blocksStoreMaxRefetches: 4},
+			queryLimiter: noOpQueryLimiter,
+			expectedSeries: []seriesResult{
+				{
+					lbls: labels.New(metricNameLabel, series1Label),
+					values: []valueResult{
+						{t: minT, v: 1},
+						{t: minT + 1, v: 2},
+					},
+				},
+			},
+			expectedMetrics: `
+				# HELP cortex_querier_storegateway_refetches_per_query Number of re-fetches attempted while querying store-gateway instances due to missing blocks.
+				# TYPE cortex_querier_storegateway_refetches_per_query histogram
+				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 0
+				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 0
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_refetches_per_query_sum 3
+				cortex_querier_storegateway_refetches_per_query_count 1
+
+				# HELP cortex_querier_storegateway_instances_hit_per_query Number of store-gateway instances hit for a single query.
+				# TYPE cortex_querier_storegateway_instances_hit_per_query histogram
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="1"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="2"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="3"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="5"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="6"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="7"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="8"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="9"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="10"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_instances_hit_per_query_sum 4
+				cortex_querier_storegateway_instances_hit_per_query_count 1
+
+				# HELP cortex_querier_blocks_found_total Number of blocks found based on query time range.
+				# TYPE cortex_querier_blocks_found_total counter
+				cortex_querier_blocks_found_total 2
+				# HELP cortex_querier_blocks_queried_total Number of blocks queried to satisfy query. Compared to blocks found, some blocks may have been filtered out thanks to query and compactor sharding.
+				# TYPE cortex_querier_blocks_queried_total counter
+				cortex_querier_blocks_queried_total 2
+				# HELP cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total Blocks that couldn't be checked for query and compactor sharding optimization due to incompatible shard counts.
+				# TYPE cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total counter
+				cortex_querier_blocks_with_compactor_shard_but_incompatible_query_shard_total 0
+			`,
+		},
 		"max chunks per query limit greater then the number of chunks fetched": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -478,9 +693,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block1, block2},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{maxChunksPerQuery: 1},
-			queryLimiter: noOpQueryLimiter,
-			expectedErr:  validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, fmt.Sprintf("{__name__=%q}", metricName), 1)),
+			limits:                 &blocksStoreLimitsMock{maxChunksPerQuery: 1},
+			queryLimiter:           noOpQueryLimiter,
+			expectedErr:            validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, fmt.Sprintf("{__name__=%q}", metricName), 1)),
+			expectedLimitRejection: limitMaxChunksPerQuery,
 		},
 		"max chunks per query limit hit while fetching chunks at first attempt - global limit": {
 			finderResult: bucketindex.Blocks{
@@ -496,9 +712,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block1, block2},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{},
-			queryLimiter: limiter.NewQueryLimiter(0, 0, 1),
-			expectedErr:  validation.LimitError(fmt.Sprintf(limiter.MaxChunksPerQueryLimitMsgFormat, 1)),
+			limits:                 &blocksStoreLimitsMock{},
+			queryLimiter:           limiter.NewQueryLimiter(0, 0, 1),
+			expectedErr:            validation.LimitError(fmt.Sprintf(limiter.MaxChunksPerQueryLimitMsgFormat, 1)),
+			expectedLimitRejection: limitMaxChunksPerQuery,
 		},
 		"max chunks per query limit hit while fetching chunks during subsequent attempts": {
 			finderResult: bucketindex.Blocks{
@@ -534,9 +751,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block4},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{maxChunksPerQuery: 3},
-			queryLimiter: noOpQueryLimiter,
-			expectedErr:  validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, fmt.Sprintf("{__name__=%q}", metricName), 3)),
+			limits:                 &blocksStoreLimitsMock{maxChunksPerQuery: 3},
+			queryLimiter:           noOpQueryLimiter,
+			expectedErr:            validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, fmt.Sprintf("{__name__=%q}", metricName), 3)),
+			expectedLimitRejection: limitMaxChunksPerQuery,
 		},
 		"max chunks per query limit hit while fetching chunks during subsequent attempts - global": {
 			finderResult: bucketindex.Blocks{
@@ -572,9 +790,46 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block4},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{},
-			queryLimiter: limiter.NewQueryLimiter(0, 0, 3),
-			expectedErr:  validation.LimitError(fmt.Sprintf(limiter.MaxChunksPerQueryLimitMsgFormat, 3)),
+			limits:                 &blocksStoreLimitsMock{},
+			queryLimiter:           limiter.NewQueryLimiter(0, 0, 3),
+			expectedErr:            validation.LimitError(fmt.Sprintf(limiter.MaxChunksPerQueryLimitMsgFormat, 3)),
+			expectedLimitRejection: limitMaxChunksPerQuery,
+		},
+		"max chunks per query limit hit based on the chunks estimated from blocks metadata": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1, MinTime: minT, MaxTime: maxT + 1, NumChunks: 10},
+				{ID: block2, MinTime: minT, MaxTime: maxT + 1, NumChunks: 10},
+			},
+			limits:                 &blocksStoreLimitsMock{maxChunksPerQuery: 5, maxEstimatedChunksPerQueryMultiplier: 2},
+			queryLimiter:           noOpQueryLimiter,
+			expectedErr:            validation.LimitError(fmt.Sprintf(maxChunksPerQueryLimitMsgFormat, fmt.Sprintf("{__name__=%q}", metricName), 5)),
+			expectedLimitRejection: limitMaxEstimatedChunks,
+		},
+		"max chunks per query limit not hit when the chunks estimated from blocks metadata are within the safety factor": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1, MinTime: minT, MaxTime: maxT + 1, NumChunks: 10},
+				{ID: block2, MinTime: minT, MaxTime: maxT + 1, NumChunks: 10},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT+1, 2),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{maxChunksPerQuery: 5, maxEstimatedChunksPerQueryMultiplier: 4},
+			queryLimiter: noOpQueryLimiter,
+			expectedSeries: []seriesResult{
+				{
+					lbls: labels.New(metricNameLabel, series1Label),
+					values: []valueResult{
+						{t: minT, v: 1},
+						{t: minT + 1, v: 2},
+					},
+				},
+			},
 		},
 		"max series per query limit hit while fetching chunks": {
 			finderResult: bucketindex.Blocks{
@@ -590,9 +845,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block1, block2},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{},
-			queryLimiter: limiter.NewQueryLimiter(1, 0, 0),
-			expectedErr:  validation.LimitError(fmt.Sprintf(limiter.MaxSeriesHitMsgFormat, 1)),
+			limits:                 &blocksStoreLimitsMock{},
+			queryLimiter:           limiter.NewQueryLimiter(1, 0, 0),
+			expectedErr:            validation.LimitError(fmt.Sprintf(limiter.MaxSeriesHitMsgFormat, 1)),
+			expectedLimitRejection: limitMaxSeries,
 		},
 		"max chunk bytes per query limit hit while fetching chunks": {
 			finderResult: bucketindex.Blocks{
@@ -608,9 +864,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					}}: {block1, block2},
 				},
 			},
-			limits:       &blocksStoreLimitsMock{maxChunksPerQuery: 1},
-			queryLimiter: limiter.NewQueryLimiter(0, 8, 0),
-			expectedErr:  validation.LimitError(fmt.Sprintf(limiter.MaxChunkBytesHitMsgFormat, 8)),
+			limits:                 &blocksStoreLimitsMock{maxChunksPerQuery: 1},
+			queryLimiter:           limiter.NewQueryLimiter(0, 8, 0),
+			expectedErr:            validation.LimitError(fmt.Sprintf(limiter.MaxChunkBytesHitMsgFormat, 8)),
+			expectedLimitRejection: limitMaxChunkBytes,
 		},
 		"blocks with non-matching shard are filtered out": {
 			finderResult: bucketindex.Blocks{
@@ -674,6 +931,9 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 					cortex_querier_storegateway_refetches_per_query_sum 0
 					cortex_querier_storegateway_refetches_per_query_count 1
@@ -741,6 +1001,9 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 					cortex_querier_storegateway_refetches_per_query_sum 0
 					cortex_querier_storegateway_refetches_per_query_count 1
@@ -808,6 +1071,9 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 					cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
 					cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+					cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 					cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 					cortex_querier_storegateway_refetches_per_query_sum 1
 					cortex_querier_storegateway_refetches_per_query_count 1
@@ -850,11 +1116,15 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				assert.IsType(t, set.Err(), testData.expectedErr)
 				assert.False(t, set.Next())
 				assert.Nil(t, set.Warnings())
+
+				if testData.expectedLimitRejection != "" {
+					assert.Equal(t, float64(1), testutil.ToFloat64(q.metrics.limitRejections.WithLabelValues(testData.expectedLimitRejection)))
+				}
 				return
 			}
 
 			require.NoError(t, set.Err())
-			assert.Len(t, set.Warnings(), 0)
+			assert.ElementsMatch(t, testData.expectedWarnings, set.Warnings())
 
 			// Read all returned series and their values.
 			var actualSeries []seriesResult
@@ -917,9 +1187,12 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 	tests := map[string]struct {
 		finderResult        bucketindex.Blocks
 		finderErr           error
+		queryShardID        string
 		storeSetResponses   []interface{}
 		expectedLabelNames  []string
+		limits              BlocksStoreLimits
 		expectedLabelValues []string // For __name__
+		expectedWarnings    storage.Warnings
 		expectedErr         string
 		expectedMetrics     string
 	}{
@@ -1118,6 +1391,9 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 				cortex_querier_storegateway_refetches_per_query_sum 0
 				cortex_querier_storegateway_refetches_per_query_count 1
@@ -1150,6 +1426,36 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 			},
 			expectedErr: newStoreConsistencyCheckFailedError([]ulid.ULID{block2}).Error(),
 		},
+		"a single store-gateway instance has some missing blocks and the tenant accepts partial data (consistency check failed)": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				// First attempt returns a client whose response does not include all expected blocks.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{
+						remoteAddr: "1.1.1.1",
+						mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+							Names:    namesFromSeries(series1),
+							Warnings: []string{},
+							Hints:    mockNamesHints(block1),
+						},
+						mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+							Values:   valuesFromSeries(labels.MetricName, series1),
+							Warnings: []string{},
+							Hints:    mockValuesHints(block1),
+						},
+					}: {block1},
+				},
+				// Second attempt returns an error because there are no other store-gateways left.
+				errors.New("no store-gateway remaining after exclude"),
+			},
+			limits:              &blocksStoreLimitsMock{queryPartialDataOnConsistencyFailure: true},
+			expectedLabelNames:  namesFromSeries(series1),
+			expectedLabelValues: valuesFromSeries(labels.MetricName, series1),
+			expectedWarnings:    storage.Warnings{newStoreConsistencyCheckFailedError([]ulid.ULID{block2})},
+		},
 		"multiple store-gateway instances have some missing blocks (consistency check failed)": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -1291,6 +1597,9 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
 				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 0
 				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 				cortex_querier_storegateway_refetches_per_query_sum 2
 				cortex_querier_storegateway_refetches_per_query_count 1
@@ -1349,46 +1658,87 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
 				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="5"} 1
 				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
 				cortex_querier_storegateway_refetches_per_query_sum 1
 				cortex_querier_storegateway_refetches_per_query_count 1
 			`,
 		},
-	}
-
-	for testName, testData := range tests {
-		t.Run(testName, func(t *testing.T) {
-			// Splitting it because we need a new registry for names and values.
-			// And also the initial expectedErr checking needs to be done for both.
-			for _, testFunc := range []string{"LabelNames", "LabelValues"} {
-				ctx := user.InjectOrgID(context.Background(), "user-1")
-				reg := prometheus.NewPedanticRegistry()
-				stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
-				finder := &blocksFinderMock{}
-				finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), testData.finderErr)
-
-				q := &blocksStoreQuerier{
-					ctx:         ctx,
-					minT:        minT,
-					maxT:        maxT,
-					userID:      "user-1",
-					finder:      finder,
-					stores:      stores,
-					consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
-					logger:      log.NewNopLogger(),
-					metrics:     newBlocksStoreQueryableMetrics(reg),
-					limits:      &blocksStoreLimitsMock{},
-				}
-
-				if testFunc == "LabelNames" {
-					names, warnings, err := q.LabelNames()
+		"blocks with non-matching shard are filtered out": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1, CompactorShardID: "1_of_4"},
+				{ID: block2, CompactorShardID: "2_of_4"},
+				{ID: block3, CompactorShardID: "3_of_4"},
+				{ID: block4, CompactorShardID: "4_of_4"},
+			},
+			queryShardID: "2_of_4",
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{
+						remoteAddr: "1.1.1.1",
+						mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+							Names:    namesFromSeries(series1),
+							Warnings: []string{},
+							Hints:    mockNamesHints(block2),
+						},
+						mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+							Values:   valuesFromSeries(labels.MetricName, series1),
+							Warnings: []string{},
+							Hints:    mockValuesHints(block2),
+						},
+					}: {block2}, // Only block2 will be queried
+				},
+			},
+			expectedLabelNames:  namesFromSeries(series1),
+			expectedLabelValues: valuesFromSeries(labels.MetricName, series1),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var matchers []*labels.Matcher
+			if testData.queryShardID != "" {
+				matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, sharding.ShardLabel, testData.queryShardID))
+			}
+
+			// Splitting it because we need a new registry for names and values.
+			// And also the initial expectedErr checking needs to be done for both.
+			for _, testFunc := range []string{"LabelNames", "LabelValues"} {
+				ctx := user.InjectOrgID(context.Background(), "user-1")
+				reg := prometheus.NewPedanticRegistry()
+				stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
+				finder := &blocksFinderMock{}
+				finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), testData.finderErr)
+
+				limits := testData.limits
+				if limits == nil {
+					limits = &blocksStoreLimitsMock{}
+				}
+
+				q := &blocksStoreQuerier{
+					ctx:         ctx,
+					minT:        minT,
+					maxT:        maxT,
+					userID:      "user-1",
+					finder:      finder,
+					stores:      stores,
+					consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+					logger:      log.NewNopLogger(),
+					metrics:     newBlocksStoreQueryableMetrics(reg),
+					limits:      limits,
+				}
+
+				if testFunc == "LabelNames" {
+					names, warnings, err := q.LabelNames(matchers...)
 					if testData.expectedErr != "" {
 						require.Equal(t, testData.expectedErr, err.Error())
 						continue
 					}
 
 					require.NoError(t, err)
-					require.Equal(t, 0, len(warnings))
+					assert.ElementsMatch(t, testData.expectedWarnings, warnings)
 					require.Equal(t, testData.expectedLabelNames, names)
 
 					// Assert on metrics (optional, only for test cases defining it).
@@ -1398,14 +1748,14 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				}
 
 				if testFunc == "LabelValues" {
-					values, warnings, err := q.LabelValues(labels.MetricName)
+					values, warnings, err := q.LabelValues(labels.MetricName, matchers...)
 					if testData.expectedErr != "" {
 						require.Equal(t, testData.expectedErr, err.Error())
 						continue
 					}
 
 					require.NoError(t, err)
-					require.Equal(t, 0, len(warnings))
+					assert.ElementsMatch(t, testData.expectedWarnings, warnings)
 					require.Equal(t, testData.expectedLabelValues, values)
 
 					// Assert on metrics (optional, only for test cases defining it).
@@ -1416,84 +1766,2817 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 			}
 		})
 	}
-}
+}
+
+func TestBlocksStoreQuerier_ShouldTrackStoreGatewayRequestDuration(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	// The store-gateway 2.2.2.2 fails, so block2 is refetched from 3.3.3.3.
+	storeSetResponses := []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{
+				remoteAddr:                "1.1.1.1",
+				mockedSeriesResponses:     []*storepb.SeriesResponse{mockSeriesResponse(series, minT, 1), mockHintsResponse(block1)},
+				mockedLabelNamesResponse:  &storepb.LabelNamesResponse{Names: namesFromSeries(series), Hints: mockNamesHints(block1)},
+				mockedLabelValuesResponse: &storepb.LabelValuesResponse{Values: valuesFromSeries(labels.MetricName, series), Hints: mockValuesHints(block1)},
+			}: {block1},
+			&storeGatewayClientMock{
+				remoteAddr:           "2.2.2.2",
+				mockedSeriesErr:      errors.New("failed to fetch series"),
+				mockedLabelNamesErr:  errors.New("failed to fetch label names"),
+				mockedLabelValuesErr: errors.New("failed to fetch label values"),
+			}: {block2},
+		},
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{
+				remoteAddr:                "3.3.3.3",
+				mockedSeriesResponses:     []*storepb.SeriesResponse{mockSeriesResponse(series, minT, 1), mockHintsResponse(block2)},
+				mockedLabelNamesResponse:  &storepb.LabelNamesResponse{Names: namesFromSeries(series), Hints: mockNamesHints(block2)},
+				mockedLabelValuesResponse: &storepb.LabelValuesResponse{Values: valuesFromSeries(labels.MetricName, series), Hints: mockValuesHints(block2)},
+			}: {block2},
+		},
+	}
+
+	tests := map[string]struct {
+		operation string
+		query     func(q *blocksStoreQuerier) error
+	}{
+		"Series": {
+			operation: "Series",
+			query: func(q *blocksStoreQuerier) error {
+				set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+				for set.Next() {
+				}
+				return set.Err()
+			},
+		},
+		"LabelNames": {
+			operation: "LabelNames",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelNames()
+				return err
+			},
+		},
+		"LabelValues": {
+			operation: "LabelValues",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelValues(labels.MetricName)
+				return err
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      &blocksStoreSetMock{mockedResponses: storeSetResponses},
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			require.NoError(t, testData.query(q))
+
+			// The request duration should be tracked for each store-gateway, including the failed one.
+			assert.Equal(t, map[string]uint64{
+				testData.operation + "/1.1.1.1": 1,
+				testData.operation + "/2.2.2.2": 1,
+				testData.operation + "/3.3.3.3": 1,
+			}, getStoreGatewayRequestDurationSampleCounts(t, reg))
+		})
+	}
+
+	t.Run("should not track requests failed because of a canceled context", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		q := &blocksStoreQuerier{
+			ctx:     ctx,
+			userID:  "user-1",
+			logger:  log.NewNopLogger(),
+			metrics: newBlocksStoreQueryableMetrics(reg),
+			limits:  &blocksStoreLimitsMock{},
+		}
+
+		_, _, _, err := q.fetchLabelNamesFromStore(ctx, map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelNamesErr: context.Canceled}: {block1},
+		}, minT, maxT, nil)
+		require.NoError(t, err)
+		assert.Empty(t, getStoreGatewayRequestDurationSampleCounts(t, reg))
+	})
+}
+
+// getStoreGatewayRequestDurationSampleCounts returns the number of observations tracked by the store-gateway
+// request duration histogram, keyed by "<operation>/<remote address>".
+func getStoreGatewayRequestDurationSampleCounts(t *testing.T, reg prometheus.Gatherer) map[string]uint64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	counts := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "cortex_querier_storegateway_instance_request_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			lbls := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				lbls[pair.GetName()] = pair.GetValue()
+			}
+
+			counts[lbls["operation"]+"/"+lbls["remote_address"]] = metric.GetHistogram().GetSampleCount()
+		}
+	}
+
+	return counts
+}
+
+func TestBlocksStoreQuerier_ShouldAbortFetchingSeriesWhenDeadlineIsTooClose(t *testing.T) {
+	const (
+		minT = int64(10)
+		maxT = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		series = labels.FromStrings(labels.MetricName, "test_metric")
+	)
+
+	tests := map[string]struct {
+		deadlineSlack   time.Duration
+		timeout         time.Duration
+		expectedAborted bool
+	}{
+		"deadline slack disabled": {
+			deadlineSlack:   0,
+			timeout:         time.Second,
+			expectedAborted: false,
+		},
+		"time left before the deadline is greater than the slack": {
+			deadlineSlack:   time.Second,
+			timeout:         time.Minute,
+			expectedAborted: false,
+		},
+		"time left before the deadline is lower than the slack": {
+			deadlineSlack:   time.Minute,
+			timeout:         time.Second,
+			expectedAborted: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			ctx, cancel := context.WithTimeout(limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)), testData.timeout)
+			defer cancel()
+
+			q := &blocksStoreQuerier{
+				ctx:           ctx,
+				userID:        "user-1",
+				logger:        log.NewNopLogger(),
+				metrics:       newBlocksStoreQueryableMetrics(reg),
+				limits:        &blocksStoreLimitsMock{},
+				deadlineSlack: testData.deadlineSlack,
+			}
+
+			clients := map[BlocksStoreClient][]ulid.ULID{
+				&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+					mockSeriesResponse(series, minT, 1),
+					mockHintsResponse(block1),
+				}}: {block1},
+			}
+
+			_, queriedBlocks, _, _, err := q.fetchSeriesFromStores(ctx, nil, clients, minT, maxT, nil, nil, 0, 0)
+			if testData.expectedAborted {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+				assert.Empty(t, queriedBlocks)
+				assert.Equal(t, float64(1), testutil.ToFloat64(q.metrics.deadlineAbortedQueries))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []ulid.ULID{block1}, queriedBlocks)
+			assert.Equal(t, float64(0), testutil.ToFloat64(q.metrics.deadlineAbortedQueries))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldHonorStoreGatewayPartialResponse(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		partialResponse  bool
+		expectedStrategy storepb.PartialResponseStrategy
+	}{
+		"partial response disabled": {
+			partialResponse:  false,
+			expectedStrategy: storepb.PartialResponseStrategy_ABORT,
+		},
+		"partial response enabled": {
+			partialResponse:  true,
+			expectedStrategy: storepb.PartialResponseStrategy_WARN,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// The first store-gateway fails to query block2 and returns a warning, so block2 is refetched from the second one.
+			firstClient := &storeGatewayClientMock{
+				remoteAddr: "1.1.1.1",
+				mockedSeriesResponses: []*storepb.SeriesResponse{
+					mockSeriesResponse(series, minT, 1),
+					storepb.NewWarnSeriesResponse(errors.New("fetch series for block 2")),
+					mockHintsResponse(block1),
+				},
+				mockedLabelNamesResponse:  &storepb.LabelNamesResponse{Names: namesFromSeries(series), Warnings: []string{"block 2"}, Hints: mockNamesHints(block1)},
+				mockedLabelValuesResponse: &storepb.LabelValuesResponse{Values: valuesFromSeries(labels.MetricName, series), Warnings: []string{"block 2"}, Hints: mockValuesHints(block1)},
+			}
+			secondClient := &storeGatewayClientMock{
+				remoteAddr:                "2.2.2.2",
+				mockedSeriesResponses:     []*storepb.SeriesResponse{mockSeriesResponse(series, minT+1, 2), mockHintsResponse(block2)},
+				mockedLabelNamesResponse:  &storepb.LabelNamesResponse{Names: namesFromSeries(series), Hints: mockNamesHints(block2)},
+				mockedLabelValuesResponse: &storepb.LabelValuesResponse{Values: valuesFromSeries(labels.MetricName, series), Hints: mockValuesHints(block2)},
+			}
+			storeSetResponses := []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{firstClient: {block1, block2}},
+				map[BlocksStoreClient][]ulid.ULID{secondClient: {block2}},
+			}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			newQuerier := func() *blocksStoreQuerier {
+				return &blocksStoreQuerier{
+					ctx:         limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+					minT:        minT,
+					maxT:        maxT,
+					userID:      "user-1",
+					finder:      finder,
+					stores:      &blocksStoreSetMock{mockedResponses: storeSetResponses},
+					consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+					logger:      log.NewNopLogger(),
+					metrics:     newBlocksStoreQueryableMetrics(prometheus.NewPedanticRegistry()),
+					limits:      &blocksStoreLimitsMock{storeGatewayPartialResponse: testData.partialResponse},
+				}
+			}
+
+			t.Run("Series", func(t *testing.T) {
+				set := newQuerier().Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+				require.True(t, set.Next())
+				require.False(t, set.Next())
+				require.NoError(t, set.Err())
+				require.Len(t, set.Warnings(), 1)
+				assert.EqualError(t, set.Warnings()[0], "fetch series for block 2")
+				assert.Equal(t, testData.expectedStrategy, firstClient.receivedPartialResponseStrategy)
+				assert.Equal(t, testData.expectedStrategy, secondClient.receivedPartialResponseStrategy)
+			})
+
+			t.Run("LabelNames", func(t *testing.T) {
+				names, warnings, err := newQuerier().LabelNames()
+				require.NoError(t, err)
+				assert.Equal(t, namesFromSeries(series), names)
+				require.Len(t, warnings, 1)
+				assert.EqualError(t, warnings[0], "block 2")
+				assert.Equal(t, testData.expectedStrategy, firstClient.receivedPartialResponseStrategy)
+				assert.Equal(t, testData.expectedStrategy, secondClient.receivedPartialResponseStrategy)
+			})
+
+			t.Run("LabelValues", func(t *testing.T) {
+				values, warnings, err := newQuerier().LabelValues(labels.MetricName)
+				require.NoError(t, err)
+				assert.Equal(t, valuesFromSeries(labels.MetricName, series), values)
+				require.Len(t, warnings, 1)
+				assert.EqualError(t, warnings[0], "block 2")
+				assert.Equal(t, testData.expectedStrategy, firstClient.receivedPartialResponseStrategy)
+				assert.Equal(t, testData.expectedStrategy, secondClient.receivedPartialResponseStrategy)
+			})
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldLimitConcurrentStoreGatewayStreams(t *testing.T) {
+	const (
+		numClients = 10
+		maxStreams = 3
+	)
+
+	clients, expectedBlocks, concurrency := prepareConcurrencyTrackingStoreGatewayClients(numClients, 1)
+
+	q := &blocksStoreQuerier{
+		ctx:        context.Background(),
+		userID:     "user-1",
+		logger:     log.NewNopLogger(),
+		metrics:    newBlocksStoreQueryableMetrics(nil),
+		limits:     &blocksStoreLimitsMock{},
+		maxStreams: maxStreams,
+	}
+
+	ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+	seriesSets, queriedBlocks, _, _, err := q.fetchSeriesFromStores(ctx, nil, clients, 0, 10, nil, nil, 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, seriesSets, numClients)
+	assert.ElementsMatch(t, expectedBlocks, queriedBlocks)
+	assert.LessOrEqual(t, concurrency.max.Load(), int64(maxStreams))
+	assert.Equal(t, int64(0), concurrency.current.Load())
+}
+
+func BenchmarkBlocksStoreQuerier_FetchSeriesFromStores(b *testing.B) {
+	const numClients = 50
+
+	for _, maxStreams := range []int{0, 8} {
+		b.Run(fmt.Sprintf("max concurrent streams: %d", maxStreams), func(b *testing.B) {
+			clients, _, concurrency := prepareConcurrencyTrackingStoreGatewayClients(numClients, 10)
+
+			q := &blocksStoreQuerier{
+				ctx:        context.Background(),
+				userID:     "user-1",
+				logger:     log.NewNopLogger(),
+				metrics:    newBlocksStoreQueryableMetrics(nil),
+				limits:     &blocksStoreLimitsMock{},
+				maxStreams: maxStreams,
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+				_, _, _, _, err := q.fetchSeriesFromStores(ctx, nil, clients, 0, 10, nil, nil, 0, 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			// The number of streams concurrently buffering series is what drives the memory peak.
+			b.ReportMetric(float64(concurrency.max.Load()), "max-concurrent-streams")
+		})
+	}
+}
+
+// prepareConcurrencyTrackingStoreGatewayClients returns numClients store-gateway clients, each one
+// holding a different block with numSeries series, tracking the number of concurrently open series streams.
+func prepareConcurrencyTrackingStoreGatewayClients(numClients, numSeries int) (map[BlocksStoreClient][]ulid.ULID, []ulid.ULID, *streamsConcurrency) {
+	var (
+		clients     = map[BlocksStoreClient][]ulid.ULID{}
+		blockIDs    = make([]ulid.ULID, 0, numClients)
+		concurrency = &streamsConcurrency{}
+	)
+
+	for i := 0; i < numClients; i++ {
+		blockID := ulid.MustNew(uint64(i+1), nil)
+		blockIDs = append(blockIDs, blockID)
+
+		responses := make([]*storepb.SeriesResponse, 0, numSeries+1)
+		for s := 0; s < numSeries; s++ {
+			responses = append(responses, mockSeriesResponse(labels.FromStrings(labels.MetricName, "test_metric", "client", fmt.Sprint(i), "series", fmt.Sprint(s)), 1, float64(s)))
+		}
+		responses = append(responses, mockHintsResponse(blockID))
+
+		client := &concurrencyTrackingStoreGatewayClient{
+			BlocksStoreClient: &storeGatewayClientMock{remoteAddr: fmt.Sprintf("1.1.1.%d", i), mockedSeriesResponses: responses},
+			concurrency:       concurrency,
+		}
+		clients[client] = []ulid.ULID{blockID}
+	}
+
+	return clients, blockIDs, concurrency
+}
+
+type streamsConcurrency struct {
+	current atomic.Int64
+	max     atomic.Int64
+}
+
+// concurrencyTrackingStoreGatewayClient tracks the number of series streams concurrently open.
+type concurrencyTrackingStoreGatewayClient struct {
+	BlocksStoreClient
+
+	concurrency *streamsConcurrency
+}
+
+func (c *concurrencyTrackingStoreGatewayClient) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	stream, err := c.BlocksStoreClient.Series(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	current := c.concurrency.current.Inc()
+	for prev := c.concurrency.max.Load(); current > prev && !c.concurrency.max.CAS(prev, current); prev = c.concurrency.max.Load() {
+	}
+
+	return &concurrencyTrackingSeriesClient{StoreGateway_SeriesClient: stream, concurrency: c.concurrency}, nil
+}
+
+type concurrencyTrackingSeriesClient struct {
+	storegatewaypb.StoreGateway_SeriesClient
+
+	concurrency *streamsConcurrency
+}
+
+func (c *concurrencyTrackingSeriesClient) Recv() (*storepb.SeriesResponse, error) {
+	resp, err := c.StoreGateway_SeriesClient.Recv()
+	if err != nil {
+		c.concurrency.current.Dec()
+	}
+	return resp, err
+}
+
+func TestBlocksStoreQuerier_ShouldNotQueryExcludedStoreGateways(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		excluded          []string
+		storeSetResponses []interface{}
+		expectedExcludes  []map[ulid.ULID][]string
+		expectedErr       error
+	}{
+		"no store-gateway excluded": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			expectedExcludes: []map[ulid.ULID][]string{{}},
+		},
+		"store-gateways excluded at the first attempt": {
+			excluded: []string{"1.1.1.1", "2.2.2.2"},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			expectedExcludes: []map[ulid.ULID][]string{{
+				block1: {"1.1.1.1", "2.2.2.2"},
+				block2: {"1.1.1.1", "2.2.2.2"},
+			}},
+		},
+		"store-gateways excluded at the subsequent attempts too": {
+			excluded: []string{"1.1.1.1"},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1, block2},
+				},
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockHintsResponse(block2),
+					}}: {block2},
+				},
+			},
+			expectedExcludes: []map[ulid.ULID][]string{
+				{block1: {"1.1.1.1"}, block2: {"1.1.1.1"}},
+				{block1: {"1.1.1.1", "2.2.2.2"}, block2: {"1.1.1.1", "2.2.2.2"}},
+			},
+		},
+		"blocks unqueryable because of the excluded store-gateways are reported as missing": {
+			excluded: []string{"1.1.1.1"},
+			storeSetResponses: []interface{}{
+				errors.New("no store-gateway instance left after checking exclude for block"),
+			},
+			expectedExcludes: []map[ulid.ULID][]string{{block1: {"1.1.1.1"}, block2: {"1.1.1.1"}}},
+			expectedErr:      newStoreConsistencyCheckFailedError([]ulid.ULID{block1, block2}),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.excluded != nil {
+				ctx = WithExcludedStoreGateways(ctx, testData.excluded)
+			}
+
+			stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+
+			if testData.expectedErr != nil {
+				assert.EqualError(t, set.Err(), testData.expectedErr.Error())
+			} else {
+				assert.NoError(t, set.Err())
+			}
+			assert.Equal(t, testData.expectedExcludes, stores.receivedExcludes)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldReturnBlockDiagnostics(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		diagnostics      bool
+		expectedWarnings []string
+	}{
+		"block diagnostics disabled": {
+			expectedWarnings: nil,
+		},
+		"block diagnostics enabled": {
+			diagnostics: true,
+			expectedWarnings: []string{
+				"blocks storage diagnostics: 4 blocks found for the query time range, 3 blocks queried, 1 blocks filtered out by query sharding, 1 blocks not filtered by query sharding because of incompatible compactor shard counts",
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.diagnostics {
+				ctx = WithBlockDiagnostics(ctx)
+			}
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2, block4),
+					}}: {block1, block2, block4},
+				},
+			}}
+
+			// Block 3 can't contain the queried shard, while block 4 has a compactor shard count
+			// incompatible with the query shard count, so it's queried.
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2, CompactorShardID: sharding.FormatShardIDLabelValue(0, 2)},
+				{ID: block3, CompactorShardID: sharding.FormatShardIDLabelValue(1, 2)},
+				{ID: block4, CompactorShardID: sharding.FormatShardIDLabelValue(0, 3)},
+			}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT},
+				labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName),
+				sharding.ShardSelector{ShardIndex: 0, ShardCount: 2}.Matcher(),
+			)
+			for set.Next() {
+			}
+
+			require.NoError(t, set.Err())
+
+			var warnings []string
+			for _, w := range set.Warnings() {
+				warnings = append(warnings, w.Error())
+			}
+			assert.Equal(t, testData.expectedWarnings, warnings)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_LabelValues_ShouldFilterBlocksByQueryShard(t *testing.T) {
+	const (
+		minT = int64(10)
+		maxT = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		block5 = ulid.MustNew(5, nil)
+		block6 = ulid.MustNew(6, nil)
+		series = labels.FromStrings(labels.MetricName, "test_metric")
+
+		// Blocks split by the split-and-merge compactor with different shard counts,
+		// and a block not split yet.
+		blocks = bucketindex.Blocks{
+			{ID: block1},
+			{ID: block2, CompactorShardID: sharding.FormatShardIDLabelValue(0, 2)},
+			{ID: block3, CompactorShardID: sharding.FormatShardIDLabelValue(1, 2)},
+			{ID: block4, CompactorShardID: sharding.FormatShardIDLabelValue(0, 4)},
+			{ID: block5, CompactorShardID: sharding.FormatShardIDLabelValue(1, 4)},
+			{ID: block6, CompactorShardID: sharding.FormatShardIDLabelValue(0, 3)},
+		}
+	)
+
+	tests := map[string]struct {
+		shard                      *sharding.ShardSelector
+		expectedQueriedBlocks      []ulid.ULID
+		expectedIncompatibleBlocks int
+	}{
+		"should query all blocks if the request is not sharded": {
+			expectedQueriedBlocks: []ulid.ULID{block1, block2, block3, block4, block5, block6},
+		},
+		"should only query the blocks which can contain the query shard if the request is sharded": {
+			shard: &sharding.ShardSelector{ShardIndex: 1, ShardCount: 4},
+			// block6 is queried because its shard count is not compatible with the query one.
+			expectedQueriedBlocks:      []ulid.ULID{block1, block3, block5, block6},
+			expectedIncompatibleBlocks: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			reg := prometheus.NewPedanticRegistry()
+
+			// The store-gateway only returns the blocks expected to be queried, so the request fails
+			// the consistency check if any other block is expected too.
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+						Values: valuesFromSeries(labels.MetricName, series),
+						Hints:  mockValuesHints(testData.expectedQueriedBlocks...),
+					}}: testData.expectedQueriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(append(bucketindex.Blocks(nil), blocks...), map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			var matchers []*labels.Matcher
+			if testData.shard != nil {
+				matchers = append(matchers, testData.shard.Matcher())
+			}
+
+			values, _, err := q.LabelValues(labels.MetricName, matchers...)
+			require.NoError(t, err)
+			assert.Equal(t, valuesFromSeries(labels.MetricName, series), values)
+
+			assert.Equal(t, float64(len(blocks)), testutil.ToFloat64(q.metrics.blocksFound))
+			assert.Equal(t, float64(len(testData.expectedQueriedBlocks)), testutil.ToFloat64(q.metrics.blocksQueried))
+			assert.Equal(t, float64(testData.expectedIncompatibleBlocks), testutil.ToFloat64(q.metrics.blocksWithCompactorShardButIncompatibleQueryShard))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_MaxBlocksPerQuery(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		limit         int
+		blocks        bucketindex.Blocks
+		deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark
+		queriedBlocks []ulid.ULID
+		shard         *sharding.ShardSelector
+		expectedErr   error
+	}{
+		"should not reject the query if the limit is disabled": {
+			limit:         0,
+			blocks:        bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}},
+			queriedBlocks: []ulid.ULID{block1, block2, block3},
+		},
+		"should not reject the query if the number of blocks is equal to the limit": {
+			limit:         3,
+			blocks:        bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}},
+			queriedBlocks: []ulid.ULID{block1, block2, block3},
+		},
+		"should reject the query if the number of blocks exceeds the limit": {
+			limit:       2,
+			blocks:      bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}},
+			expectedErr: validation.LimitError(fmt.Sprintf(maxBlocksPerQueryLimitMsgFormat, 3, 2)),
+		},
+		"should not count blocks marked for deletion": {
+			limit:  2,
+			blocks: bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}},
+			deletionMarks: map[ulid.ULID]*bucketindex.BlockDeletionMark{
+				block3: {ID: block3, DeletionTime: time.Now().Unix()},
+			},
+			queriedBlocks: []ulid.ULID{block1, block2, block3},
+		},
+		"should not count blocks filtered out by query sharding": {
+			limit: 2,
+			blocks: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2, CompactorShardID: sharding.FormatShardIDLabelValue(0, 2)},
+				{ID: block3, CompactorShardID: sharding.FormatShardIDLabelValue(1, 2)},
+			},
+			queriedBlocks: []ulid.ULID{block1, block2},
+			shard:         &sharding.ShardSelector{ShardIndex: 0, ShardCount: 2},
+		},
+		"should reject the query if the number of blocks after query sharding exceeds the limit": {
+			limit: 2,
+			blocks: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2, CompactorShardID: sharding.FormatShardIDLabelValue(0, 2)},
+				{ID: block3, CompactorShardID: sharding.FormatShardIDLabelValue(1, 2)},
+				{ID: block4, CompactorShardID: sharding.FormatShardIDLabelValue(0, 3)},
+			},
+			shard:       &sharding.ShardSelector{ShardIndex: 0, ShardCount: 2},
+			expectedErr: validation.LimitError(fmt.Sprintf(maxBlocksPerQueryLimitMsgFormat, 3, 2)),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			reg := prometheus.NewPedanticRegistry()
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(testData.queriedBlocks...),
+					}}: testData.queriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.blocks, testData.deletionMarks, nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{maxBlocksPerQuery: testData.limit},
+			}
+
+			matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName)}
+			if testData.shard != nil {
+				matchers = append(matchers, testData.shard.Matcher())
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, matchers...)
+			for set.Next() {
+			}
+
+			expectedRejections := 0
+			if testData.expectedErr != nil {
+				expectedRejections = 1
+				require.Equal(t, testData.expectedErr, set.Err())
+			} else {
+				require.NoError(t, set.Err())
+			}
+			assert.Equal(t, float64(expectedRejections), testutil.ToFloat64(q.metrics.limitRejections.WithLabelValues(limitMaxBlocks)))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_MinCompactionLevel(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+
+		// Blocks with mixed compaction levels. The compaction level of block4 is unknown.
+		blocks = bucketindex.Blocks{
+			{ID: block1, CompactionLevel: 1},
+			{ID: block2, CompactionLevel: 2},
+			{ID: block3, CompactionLevel: 3},
+			{ID: block4},
+		}
+	)
+
+	tests := map[string]struct {
+		minCompactionLevel int // Not set if 0.
+		queriedBlocks      []ulid.ULID
+	}{
+		"should query all blocks if the min compaction level is not set": {
+			queriedBlocks: []ulid.ULID{block1, block2, block3, block4},
+		},
+		"should query all blocks if the min compaction level is lower than all blocks": {
+			minCompactionLevel: 1,
+			queriedBlocks:      []ulid.ULID{block1, block2, block3, block4},
+		},
+		"should not query blocks below the min compaction level": {
+			minCompactionLevel: 2,
+			queriedBlocks:      []ulid.ULID{block2, block3, block4},
+		},
+		"should only query blocks with unknown compaction level if the min compaction level is higher than all blocks": {
+			minCompactionLevel: 4,
+			queriedBlocks:      []ulid.ULID{block4},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.minCompactionLevel > 0 {
+				ctx = WithMinCompactionLevel(ctx, testData.minCompactionLevel)
+			}
+			reg := prometheus.NewPedanticRegistry()
+
+			// The store-gateway only returns the blocks expected to be queried, so the query fails the
+			// consistency check if the blocks below the min compaction level are expected too.
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(testData.queriedBlocks...),
+					}}: testData.queriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, float64(len(blocks)), testutil.ToFloat64(q.metrics.blocksFound))
+			assert.Equal(t, float64(len(testData.queriedBlocks)), testutil.ToFloat64(q.metrics.blocksQueried))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_BlockSourceFilter(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+
+		// Blocks from mixed sources. The source of block4 is unknown.
+		blocks = bucketindex.Blocks{
+			{ID: block1, Source: string(metadata.ReceiveSource)},
+			{ID: block2, Source: string(metadata.CompactorSource)},
+			{ID: block3, Source: string(metadata.ReceiveSource)},
+			{ID: block4},
+		}
+	)
+
+	tests := map[string]struct {
+		source        metadata.SourceType // Not set if empty.
+		queriedBlocks []ulid.ULID
+	}{
+		"should query all blocks if the source filter is not set": {
+			queriedBlocks: []ulid.ULID{block1, block2, block3, block4},
+		},
+		"should only query the blocks shipped by ingesters": {
+			source:        metadata.ReceiveSource,
+			queriedBlocks: []ulid.ULID{block1, block3},
+		},
+		"should only query the compacted blocks": {
+			source:        metadata.CompactorSource,
+			queriedBlocks: []ulid.ULID{block2},
+		},
+		"should query no blocks if there are no blocks from the source": {
+			source:        metadata.RulerSource,
+			queriedBlocks: nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.source != "" {
+				ctx = WithBlockSourceFilter(ctx, testData.source)
+			}
+			reg := prometheus.NewPedanticRegistry()
+
+			// The store-gateway only returns the blocks expected to be queried, so the query fails the
+			// consistency check if the blocks from the other sources are expected too.
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(testData.queriedBlocks...),
+					}}: testData.queriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, float64(len(blocks)), testutil.ToFloat64(q.metrics.blocksFound))
+			assert.Equal(t, float64(len(testData.queriedBlocks)), testutil.ToFloat64(q.metrics.blocksQueried))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_BlockAllowList(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+		blocks = bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}}
+	)
+
+	tests := map[string]struct {
+		allowList     []string // Not set if nil.
+		queriedBlocks []ulid.ULID
+	}{
+		"should query all blocks if the allow-list is not set": {
+			queriedBlocks: []ulid.ULID{block1, block2, block3},
+		},
+		"should only query the allow-listed blocks": {
+			allowList:     []string{block1.String(), block3.String()},
+			queriedBlocks: []ulid.ULID{block1, block3},
+		},
+		"should ignore the allow-listed blocks not found for the query time range": {
+			allowList:     []string{block2.String(), block4.String()},
+			queriedBlocks: []ulid.ULID{block2},
+		},
+		"should query no blocks if the allow-list is empty": {
+			allowList:     []string{},
+			queriedBlocks: nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.allowList != nil {
+				var err error
+				ctx, err = WithBlockAllowList(ctx, testData.allowList)
+				require.NoError(t, err)
+			}
+			reg := prometheus.NewPedanticRegistry()
+
+			// The store-gateway only returns the blocks expected to be queried, so the query fails the
+			// consistency check if the blocks not in the allow-list are expected too.
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(testData.queriedBlocks...),
+					}}: testData.queriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, float64(len(blocks)), testutil.ToFloat64(q.metrics.blocksFound))
+			assert.Equal(t, float64(len(testData.queriedBlocks)), testutil.ToFloat64(q.metrics.blocksQueried))
+		})
+	}
+}
+
+func TestWithBlockAllowList_ShouldFailOnInvalidBlockIDs(t *testing.T) {
+	_, err := WithBlockAllowList(context.Background(), []string{ulid.MustNew(1, nil).String(), "invalid"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid block ID "invalid"`)
+}
+
+func TestBlocksStoreQuerier_ShouldTrackTouchedBlockSeriesFromHints(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		storeSetResponses          []interface{}
+		expectedTouchedBlockSeries uint64
+	}{
+		"should track no touched series if the store-gateway doesn't report them": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			expectedTouchedBlockSeries: 0,
+		},
+		"should track the touched series reported by the store-gateways": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+						mockHintsExtensionResponse(&storegatewaypb.SeriesResponseHintsExtension{BlockSeriesCounts: []storegatewaypb.BlockSeriesCount{{BlockId: block1.String(), Series: 10, Chunks: 20}}}),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT+1, 2),
+						mockHintsResponse(block2),
+						mockHintsExtensionResponse(&storegatewaypb.SeriesResponseHintsExtension{BlockSeriesCounts: []storegatewaypb.BlockSeriesCount{{BlockId: block2.String(), Series: 5, Chunks: 8}}}),
+					}}: {block2},
+				},
+			},
+			expectedTouchedBlockSeries: 15,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			reqStats, ctx := stats.ContextWithEmptyStats(ctx)
+			reg := prometheus.NewPedanticRegistry()
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      &blocksStoreSetMock{mockedResponses: testData.storeSetResponses},
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(reg),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+
+			// The queried blocks are still parsed from the hints, so the consistency check succeeds.
+			require.NoError(t, set.Err())
+			assert.Equal(t, testData.expectedTouchedBlockSeries, reqStats.LoadTouchedBlockSeries())
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldTrackChunkBytesFromCacheAndStorageFromHints(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+
+		// Each store-gateway returns the same series, with chunks of the same size.
+		_, chunkBytes = countChunksAndBytes(mockSeriesResponse(series, minT, 1).GetSeries())
+	)
+
+	tests := map[string]struct {
+		storeSetResponses []interface{}
+		expectedFromCache uint64
+	}{
+		"should attribute all chunk bytes to the storage if the store-gateway doesn't report the cache ones": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			expectedFromCache: 0,
+		},
+		"should attribute the chunk bytes reported by the store-gateways to the cache": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+						mockHintsExtensionResponse(&storegatewaypb.SeriesResponseHintsExtension{FetchedChunkBytesFromCache: 5}),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block2),
+					}}: {block2},
+				},
+			},
+			expectedFromCache: 5,
+		},
+		"should not attribute to the cache more chunk bytes than the ones fetched": {
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+						mockHintsExtensionResponse(&storegatewaypb.SeriesResponseHintsExtension{FetchedChunkBytesFromCache: uint64(chunkBytes) + 100}),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block2),
+					}}: {block2},
+				},
+			},
+			expectedFromCache: uint64(chunkBytes),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			reqStats, ctx := stats.ContextWithEmptyStats(ctx)
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      &blocksStoreSetMock{mockedResponses: testData.storeSetResponses},
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			// The chunk bytes from the cache and the storage add up to all the fetched chunk bytes.
+			require.NotZero(t, reqStats.LoadFetchedChunkBytes())
+			assert.Equal(t, testData.expectedFromCache, reqStats.LoadFetchedChunkBytesFromCache())
+			assert.Equal(t, reqStats.LoadFetchedChunkBytes()-testData.expectedFromCache, reqStats.LoadFetchedChunkBytesFromStorage())
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldSendQueryPriorityToStoreGateways(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	block1 := ulid.MustNew(1, nil)
+
+	tests := map[string]struct {
+		priority         *QueryPriority
+		expectedPriority []string
+	}{
+		"should not send the query priority if not set in the context": {
+			priority:         nil,
+			expectedPriority: nil,
+		},
+		"should send the query priority set in the context": {
+			priority:         queryPriorityPtr(10),
+			expectedPriority: []string{"10"},
+		},
+		"should send a negative query priority set in the context": {
+			priority:         queryPriorityPtr(-1),
+			expectedPriority: []string{"-1"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.priority != nil {
+				ctx = WithQueryPriority(ctx, *testData.priority)
+			}
+
+			client := &storeGatewayClientMock{
+				remoteAddr: "1.1.1.1",
+				mockedSeriesResponses: []*storepb.SeriesResponse{
+					mockSeriesResponse(labels.FromStrings(labels.MetricName, metricName), minT, 1),
+					mockHintsResponse(block1),
+				},
+				mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+					Names: []string{labels.MetricName},
+					Hints: mockNamesHints(block1),
+				},
+				mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+					Values: []string{metricName},
+					Hints:  mockValuesHints(block1),
+				},
+			}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+				{ID: block1},
+			}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+
+				// The same store-gateway is queried by the series, label names and label values requests.
+				stores: &blocksStoreSetMock{mockedResponses: []interface{}{
+					map[BlocksStoreClient][]ulid.ULID{client: {block1}},
+					map[BlocksStoreClient][]ulid.ULID{client: {block1}},
+					map[BlocksStoreClient][]ulid.ULID{client: {block1}},
+				}},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+			assert.Equal(t, []string{"user-1"}, client.receivedMetadata.Get(storegateway.GrpcContextMetadataTenantID))
+			assert.Equal(t, testData.expectedPriority, client.receivedMetadata.Get(storegateway.GrpcContextMetadataQueryPriority))
+
+			_, _, err := q.LabelNames()
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedPriority, client.receivedMetadata.Get(storegateway.GrpcContextMetadataQueryPriority))
+
+			_, _, err = q.LabelValues(labels.MetricName)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedPriority, client.receivedMetadata.Get(storegateway.GrpcContextMetadataQueryPriority))
+		})
+	}
+}
+
+func queryPriorityPtr(p QueryPriority) *QueryPriority {
+	return &p
+}
+
+func TestBlocksStoreQuerier_ShouldTrackOldestQueriedBlockAge(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+		now    = time.Now()
+	)
+
+	tests := map[string]struct {
+		blocks              bucketindex.Blocks
+		queriedBlocks       []ulid.ULID
+		expectedObservation bool
+		expectedAge         time.Duration
+	}{
+		"should observe the age of the oldest queried block": {
+			blocks: bucketindex.Blocks{
+				{ID: block1, MaxTime: util.TimeToMillis(now.Add(-time.Hour))},
+				{ID: block2, MaxTime: util.TimeToMillis(now.Add(-10 * 24 * time.Hour))},
+				{ID: block3, MaxTime: util.TimeToMillis(now.Add(-2 * time.Hour))},
+			},
+			queriedBlocks:       []ulid.ULID{block1, block2, block3},
+			expectedObservation: true,
+			expectedAge:         10 * 24 * time.Hour,
+		},
+		"should not observe anything if no block has been queried": {
+			blocks: nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(testData.queriedBlocks...),
+					}}: testData.queriedBlocks,
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			// The age depends on the time the query ran, so we can't compare it with an exact value.
+			var m dto.Metric
+			require.NoError(t, q.metrics.oldestQueriedBlockAge.Write(&m))
+			if !testData.expectedObservation {
+				assert.Equal(t, uint64(0), m.GetHistogram().GetSampleCount())
+				return
+			}
+
+			assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+			assert.GreaterOrEqual(t, m.GetHistogram().GetSampleSum(), testData.expectedAge.Seconds())
+			assert.Less(t, m.GetHistogram().GetSampleSum(), (testData.expectedAge + time.Minute).Seconds())
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldTrackQueriedBlocksAndRefetchesInQueryStats(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	queryStats, ctx := stats.ContextWithEmptyStats(context.Background())
+	ctx = limiter.AddQueryLimiterToContext(ctx, limiter.NewQueryLimiter(0, 0, 0))
+
+	stores := &blocksStoreSetMock{shardInstances: 3, mockedResponses: []interface{}{
+		// The first attempt of the series request misses block2, which is fetched again.
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(series, minT, 1),
+				mockHintsResponse(block1),
+			}}: {block1, block2},
+		},
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(series, minT+1, 2),
+				mockHintsResponse(block2),
+			}}: {block2},
+		},
+		// The label names request gets all blocks at the first attempt.
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+				Names: namesFromSeries(series),
+				Hints: mockNamesHints(block1, block2),
+			}}: {block1, block2},
+		},
+	}}
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+		{ID: block1},
+		{ID: block2},
+	}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	q := &blocksStoreQuerier{
+		ctx:         ctx,
+		minT:        minT,
+		maxT:        maxT,
+		userID:      "user-1",
+		finder:      finder,
+		stores:      stores,
+		consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:      log.NewNopLogger(),
+		metrics:     newBlocksStoreQueryableMetrics(nil),
+		limits:      &blocksStoreLimitsMock{},
+	}
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	for set.Next() {
+	}
+	require.NoError(t, set.Err())
+
+	assert.Equal(t, uint64(2), queryStats.LoadQueriedBlocks())
+	assert.Equal(t, uint32(1), queryStats.LoadStoreGatewayRefetches())
+	assert.Equal(t, uint32(3), queryStats.LoadStoreGatewayShardInstances())
+
+	// The tenant shard is looked up once per query, regardless of the number of attempts.
+	assert.Equal(t, 1, stores.shardInstancesCalls)
+
+	// The stats of the following calls of the same query sum up.
+	_, _, err := q.LabelNames()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(4), queryStats.LoadQueriedBlocks())
+	assert.Equal(t, uint32(1), queryStats.LoadStoreGatewayRefetches())
+	assert.Equal(t, uint32(3), queryStats.LoadStoreGatewayShardInstances())
+}
+
+func TestBlocksStoreQuerier_ShouldReturnTheWarningsOfTheBlocksFinder(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1         = ulid.MustNew(1, nil)
+		skippedBlock   = ulid.MustNew(2, nil)
+		series         = labels.FromStrings(labels.MetricName, metricName)
+		skippedWarning = fmt.Errorf("1 blocks have been skipped because their meta.json is corrupted: %s", skippedBlock.String())
+	)
+
+	tests := map[string]struct {
+		finderResult bucketindex.Blocks
+		storeSet     *blocksStoreSetMock
+	}{
+		"some blocks found": {
+			finderResult: bucketindex.Blocks{{ID: block1}},
+			storeSet: &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1},
+				},
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+						Names: namesFromSeries(series),
+						Hints: mockNamesHints(block1),
+					}}: {block1},
+				},
+			}},
+		},
+		"no blocks found": {
+			storeSet: &blocksStoreSetMock{},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+
+			finder := &blocksFinderWithWarningsMock{blocksFinderMock: &blocksFinderMock{}, warnings: storage.Warnings{skippedWarning}}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      testData.storeSet,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+			assert.Contains(t, set.Warnings(), skippedWarning)
+
+			_, warnings, err := q.LabelNames()
+			require.NoError(t, err)
+			assert.Equal(t, storage.Warnings{skippedWarning}, warnings)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldInvokeQueriedBlocksHook(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		finderResult          bucketindex.Blocks
+		storeSetResponses     []interface{}
+		expectedHookCalls     int
+		expectedQueriedBlocks []ulid.ULID
+		expectedErr           bool
+	}{
+		"no blocks found": {
+			expectedHookCalls: 0,
+		},
+		"all blocks queried": {
+			finderResult: bucketindex.Blocks{{ID: block1}, {ID: block2}},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			expectedHookCalls:     1,
+			expectedQueriedBlocks: []ulid.ULID{block1, block2},
+		},
+		"consistency check failed": {
+			finderResult: bucketindex.Blocks{{ID: block1}, {ID: block2}},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1, block2},
+				},
+				errors.New("no store-gateway instance left after checking exclude for block"),
+			},
+			expectedHookCalls:     1,
+			expectedQueriedBlocks: []ulid.ULID{block1},
+			expectedErr:           true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			var (
+				hookCalls         int
+				hookQueriedBlocks []ulid.ULID
+			)
+
+			q := &blocksStoreQuerier{
+				ctx:         limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+				queriedBlocksHook: func(userID string, hookMinT, hookMaxT int64, queriedBlocks []ulid.ULID) {
+					assert.Equal(t, "user-1", userID)
+					assert.Equal(t, minT, hookMinT)
+					assert.Equal(t, maxT, hookMaxT)
+
+					hookCalls++
+					hookQueriedBlocks = queriedBlocks
+				},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+
+			if testData.expectedErr {
+				assert.Error(t, set.Err())
+			} else {
+				assert.NoError(t, set.Err())
+			}
+			assert.Equal(t, testData.expectedHookCalls, hookCalls)
+			assert.ElementsMatch(t, testData.expectedQueriedBlocks, hookQueriedBlocks)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldBackoffBetweenRefetches(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+	)
+
+	tests := map[string]struct {
+		refetchBackoff refetchBackoffConfig
+		queryTimeout   time.Duration
+		expectedErr    error
+		expectedWaits  []time.Duration
+	}{
+		"should fail if the missing block is loaded after all attempts and backoff is disabled": {
+			expectedErr: newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
+		},
+		"should succeed if the missing block is loaded while backing off": {
+			refetchBackoff: refetchBackoffConfig{min: 200 * time.Millisecond, max: time.Second},
+			expectedWaits:  []time.Duration{200 * time.Millisecond, 400 * time.Millisecond},
+		},
+		"should not backoff past the query deadline": {
+			refetchBackoff: refetchBackoffConfig{min: 10 * time.Second, max: 10 * time.Second},
+			queryTimeout:   5 * time.Second,
+			expectedErr:    newStoreConsistencyCheckFailedError([]ulid.ULID{block2}),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+			if testData.queryTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, testData.queryTimeout)
+				defer cancel()
+			}
+
+			// The store-gateways load block2 only after a while, like when they're restarting.
+			stores := &delayedBlocksStoreSet{
+				metricName:     metricName,
+				minT:           minT,
+				delayed:        block2,
+				availableAfter: 400 * time.Millisecond,
+			}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			// Record the backoff waits and advance the store-gateways clock instead of sleeping.
+			var waits []time.Duration
+			refetchBackoff := testData.refetchBackoff
+			refetchBackoff.wait = func(_ context.Context, delay time.Duration) error {
+				waits = append(waits, delay)
+				stores.elapsed += delay
+				return nil
+			}
+
+			q := &blocksStoreQuerier{
+				ctx:            ctx,
+				minT:           minT,
+				maxT:           maxT,
+				userID:         "user-1",
+				finder:         finder,
+				stores:         stores,
+				consistency:    NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:         log.NewNopLogger(),
+				metrics:        newBlocksStoreQueryableMetrics(nil),
+				limits:         &blocksStoreLimitsMock{blocksStoreMaxRefetches: 3},
+				refetchBackoff: refetchBackoff,
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+
+			if testData.expectedErr != nil {
+				assert.EqualError(t, set.Err(), testData.expectedErr.Error())
+			} else {
+				assert.NoError(t, set.Err())
+			}
+			assert.Equal(t, 3, stores.calls)
+
+			// The waits are jittered by 20%.
+			require.Len(t, waits, len(testData.expectedWaits))
+			for i, expected := range testData.expectedWaits {
+				assert.InDelta(t, expected, waits[i], float64(expected)*0.2)
+			}
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_RefetchBackoffDelay(t *testing.T) {
+	q := &blocksStoreQuerier{refetchBackoff: refetchBackoffConfig{min: 100 * time.Millisecond, max: time.Second}}
+
+	for attempt, expected := range map[int]time.Duration{
+		2: 100 * time.Millisecond,
+		3: 200 * time.Millisecond,
+		4: 400 * time.Millisecond,
+		5: 800 * time.Millisecond,
+		6: time.Second,
+		7: time.Second,
+	} {
+		actual := q.refetchBackoffDelay(attempt)
+		assert.GreaterOrEqual(t, actual, time.Duration(float64(expected)*0.8), "attempt: %d", attempt)
+		assert.LessOrEqual(t, actual, time.Duration(float64(expected)*1.2), "attempt: %d", attempt)
+	}
+
+	// Backoff disabled.
+	q = &blocksStoreQuerier{}
+	assert.Equal(t, time.Duration(0), q.refetchBackoffDelay(2))
+}
+
+func TestBlocksStoreQuerier_ShouldRequestConfiguredCompression(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		seriesCompress              string
+		labelsCompress              string
+		expectedSeriesOptions       []grpc.CallOption
+		expectedLabelsOptions       []grpc.CallOption
+		expectedCompressionLabelVal string
+	}{
+		"no compression": {
+			expectedCompressionLabelVal: "none",
+		},
+		"compression for series only": {
+			seriesCompress:              "snappy",
+			expectedSeriesOptions:       []grpc.CallOption{grpc.UseCompressor("snappy")},
+			expectedCompressionLabelVal: "snappy",
+		},
+		"compression for both series and labels": {
+			seriesCompress:              "gzip",
+			labelsCompress:              "gzip",
+			expectedSeriesOptions:       []grpc.CallOption{grpc.UseCompressor("gzip")},
+			expectedLabelsOptions:       []grpc.CallOption{grpc.UseCompressor("gzip")},
+			expectedCompressionLabelVal: "gzip",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			newClient := func() *storeGatewayClientMock {
+				return &storeGatewayClientMock{
+					remoteAddr:                "1.1.1.1",
+					mockedSeriesResponses:     []*storepb.SeriesResponse{mockSeriesResponse(series, minT, 1), mockHintsResponse(block1)},
+					mockedLabelNamesResponse:  &storepb.LabelNamesResponse{Names: namesFromSeries(series), Hints: mockNamesHints(block1)},
+					mockedLabelValuesResponse: &storepb.LabelValuesResponse{Values: valuesFromSeries(labels.MetricName, series), Hints: mockValuesHints(block1)},
+				}
+			}
+			seriesClient, namesClient, valuesClient := newClient(), newClient(), newClient()
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{seriesClient: {block1}},
+				map[BlocksStoreClient][]ulid.ULID{namesClient: {block1}},
+				map[BlocksStoreClient][]ulid.ULID{valuesClient: {block1}},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:            limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:           minT,
+				maxT:           maxT,
+				userID:         "user-1",
+				finder:         finder,
+				stores:         stores,
+				consistency:    NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:         log.NewNopLogger(),
+				metrics:        newBlocksStoreQueryableMetrics(nil),
+				limits:         &blocksStoreLimitsMock{},
+				seriesCompress: testData.seriesCompress,
+				labelsCompress: testData.labelsCompress,
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			_, _, err := q.LabelNames()
+			require.NoError(t, err)
+
+			_, _, err = q.LabelValues(labels.MetricName)
+			require.NoError(t, err)
+
+			assert.Equal(t, testData.expectedSeriesOptions, seriesClient.receivedCallOptions)
+			assert.Equal(t, testData.expectedLabelsOptions, namesClient.receivedCallOptions)
+			assert.Equal(t, testData.expectedLabelsOptions, valuesClient.receivedCallOptions)
+
+			_, expectedChunkBytes := countChunksAndBytes(mockSeriesResponse(series, minT, 1).GetSeries())
+			assert.Equal(t, float64(expectedChunkBytes), testutil.ToFloat64(q.metrics.fetchedChunkBytes.WithLabelValues(testData.expectedCompressionLabelVal)))
+			assert.Equal(t, float64(expectedChunkBytes), testutil.ToFloat64(q.metrics.fetchedChunkBytesByEncoding.WithLabelValues("xor")))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldLogSlowStoreGatewayResponses(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		slowThreshold time.Duration
+		expectedSlow  bool
+	}{
+		"should not log responses if the threshold is disabled": {
+			slowThreshold: 0,
+			expectedSlow:  false,
+		},
+		"should not log responses faster than the threshold": {
+			slowThreshold: time.Hour,
+			expectedSlow:  false,
+		},
+		"should log responses slower than the threshold": {
+			// The mocked series stream waits 10ms before returning each response.
+			slowThreshold: time.Millisecond,
+			expectedSlow:  true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := log.NewLogfmtLogger(log.NewSyncWriter(&logs))
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1},
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:           limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:          minT,
+				maxT:          maxT,
+				userID:        "user-1",
+				finder:        finder,
+				stores:        stores,
+				consistency:   NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:        logger,
+				metrics:       newBlocksStoreQueryableMetrics(prometheus.NewPedanticRegistry()),
+				limits:        &blocksStoreLimitsMock{},
+				slowThreshold: testData.slowThreshold,
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			if testData.expectedSlow {
+				assert.Equal(t, float64(1), testutil.ToFloat64(q.metrics.slowResponses.WithLabelValues("Series")))
+				assert.Contains(t, logs.String(), `msg="slow store-gateway response" operation=Series remote=1.1.1.1`)
+				assert.Contains(t, logs.String(), `user=user-1`)
+				assert.Contains(t, logs.String(), `fetched_series=1 fetched_chunk_bytes=`)
+			} else {
+				assert.Equal(t, float64(0), testutil.ToFloat64(q.metrics.slowResponses.WithLabelValues("Series")))
+				assert.NotContains(t, logs.String(), "slow store-gateway response")
+			}
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldNormalizeMatchers(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		series = labels.FromStrings(labels.MetricName, metricName, "job", "test")
+
+		// Duplicated matchers, in non canonical order.
+		matchers = []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, "job", "test"),
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName),
+			labels.MustNewMatcher(labels.MatchEqual, "job", "test"),
+			labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName),
+		}
+
+		expectedMatchers = []storepb.LabelMatcher{
+			{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: metricName},
+			{Type: storepb.LabelMatcher_EQ, Name: "job", Value: "test"},
+		}
+	)
+
+	seriesClient := &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+		mockSeriesResponse(series, minT, 1),
+		mockHintsResponse(block1),
+	}}
+	namesClient := &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+		Names: namesFromSeries(series),
+		Hints: mockNamesHints(block1),
+	}}
+	valuesClient := &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+		Values: valuesFromSeries("job", series),
+		Hints:  mockValuesHints(block1),
+	}}
+
+	stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{seriesClient: {block1}},
+		map[BlocksStoreClient][]ulid.ULID{namesClient: {block1}},
+		map[BlocksStoreClient][]ulid.ULID{valuesClient: {block1}},
+	}}
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	q := &blocksStoreQuerier{
+		ctx:         limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+		minT:        minT,
+		maxT:        maxT,
+		userID:      "user-1",
+		finder:      finder,
+		stores:      stores,
+		consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:      log.NewNopLogger(),
+		metrics:     newBlocksStoreQueryableMetrics(nil),
+		limits:      &blocksStoreLimitsMock{},
+	}
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, matchers...)
+	require.True(t, set.Next())
+	assert.Equal(t, series, set.At().Labels())
+	require.False(t, set.Next())
+	require.NoError(t, set.Err())
+	assert.Equal(t, expectedMatchers, seriesClient.receivedMatchers)
+
+	names, _, err := q.LabelNames(matchers...)
+	require.NoError(t, err)
+	assert.Equal(t, namesFromSeries(series), names)
+	assert.Equal(t, expectedMatchers, namesClient.receivedMatchers)
+
+	values, _, err := q.LabelValues("job", matchers...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test"}, values)
+	assert.Equal(t, expectedMatchers, valuesClient.receivedMatchers)
+}
+
+func TestCountChunkBytesByEncoding(t *testing.T) {
+	xorChunk := createAggrChunkWithSamples(promql.Point{T: 10, V: 1}, promql.Point{T: 20, V: 2})
+
+	// Simulate a chunk with an encoding unknown to the querier (eg. a native histogram chunk).
+	unknownChunk := createAggrChunkWithSamples(promql.Point{T: 30, V: 3})
+	unknownChunk.Raw.Type = storepb.Chunk_Encoding(100)
+
+	series := []*storepb.Series{
+		mockSeriesResponseWithChunks(labels.FromStrings(labels.MetricName, "series_1"), xorChunk, unknownChunk).GetSeries(),
+		mockSeriesResponseWithChunks(labels.FromStrings(labels.MetricName, "series_2"), xorChunk).GetSeries(),
+		mockSeriesResponseWithChunks(labels.FromStrings(labels.MetricName, "series_3"), storepb.AggrChunk{MinTime: 40, MaxTime: 50}).GetSeries(),
+	}
+
+	actual := countChunkBytesByEncoding(series...)
+	assert.Equal(t, map[string]int{
+		"xor":     2 * xorChunk.Size(),
+		"unknown": unknownChunk.Size() + (&storepb.AggrChunk{MinTime: 40, MaxTime: 50}).Size(),
+	}, actual)
+
+	// The bytes by encoding must add up to the total.
+	_, expectedTotal := countChunksAndBytes(series...)
+	actualTotal := 0
+	for _, bytes := range actual {
+		actualTotal += bytes
+	}
+	assert.Equal(t, expectedTotal, actualTotal)
+}
+
+func TestBlocksStoreQuerier_Explain(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+	)
+
+	tests := map[string]struct {
+		finderResult      bucketindex.Blocks
+		queryShardID      string
+		excluded          []string
+		storeSetResponses []interface{}
+		expectedPlan      *QueryPlan
+		expectedExcludes  []map[ulid.ULID][]string
+		expectedErr       string
+	}{
+		"no blocks found": {
+			expectedPlan: &QueryPlan{MinT: minT, MaxT: maxT, Blocks: map[ulid.ULID]string{}},
+		},
+		"blocks assigned to multiple store-gateways": {
+			finderResult: bucketindex.Blocks{{ID: block1}, {ID: block2}, {ID: block3}},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1"}: {block1, block3},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2"}: {block2},
+				},
+			},
+			expectedPlan: &QueryPlan{MinT: minT, MaxT: maxT, Blocks: map[ulid.ULID]string{
+				block1: "1.1.1.1",
+				block2: "2.2.2.2",
+				block3: "1.1.1.1",
+			}},
+			expectedExcludes: []map[ulid.ULID][]string{{}},
+		},
+		"blocks with non-matching shard are not planned": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1, CompactorShardID: "1_of_2"},
+				{ID: block2, CompactorShardID: "2_of_2"},
+				{ID: block3},
+			},
+			queryShardID: "2_of_4",
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1"}: {block2, block3},
+				},
+			},
+			expectedPlan: &QueryPlan{MinT: minT, MaxT: maxT, Blocks: map[ulid.ULID]string{
+				block2: "1.1.1.1",
+				block3: "1.1.1.1",
+			}},
+			expectedExcludes: []map[ulid.ULID][]string{{}},
+		},
+		"excluded store-gateways are not planned": {
+			finderResult: bucketindex.Blocks{{ID: block1}},
+			excluded:     []string{"1.1.1.1"},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2"}: {block1},
+				},
+			},
+			expectedPlan:     &QueryPlan{MinT: minT, MaxT: maxT, Blocks: map[ulid.ULID]string{block1: "2.2.2.2"}},
+			expectedExcludes: []map[ulid.ULID][]string{{block1: {"1.1.1.1"}}},
+		},
+		"no store-gateway holding the blocks": {
+			finderResult: bucketindex.Blocks{{ID: block1}},
+			storeSetResponses: []interface{}{
+				errors.New("no store-gateway instance left after checking exclude for block"),
+			},
+			expectedExcludes: []map[ulid.ULID][]string{{}},
+			expectedErr:      "no store-gateway instance left after checking exclude for block",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := context.Background()
+			if testData.excluded != nil {
+				ctx = WithExcludedStoreGateways(ctx, testData.excluded)
+			}
+
+			stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName)}
+			if testData.queryShardID != "" {
+				matchers = append(matchers, labels.MustNewMatcher(labels.MatchEqual, sharding.ShardLabel, testData.queryShardID))
+			}
+
+			plan, err := q.explain(matchers)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testData.expectedPlan, plan)
+			}
+			assert.Equal(t, testData.expectedExcludes, stores.receivedExcludes)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldLogQueriedBlocksToSpan(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		spanName string
+		query    func(q *blocksStoreQuerier) error
+	}{
+		"Select()": {
+			spanName: "blocksStoreQuerier.selectSeries",
+			query: func(q *blocksStoreQuerier) error {
+				set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+				for set.Next() {
+				}
+				return set.Err()
+			},
+		},
+		"LabelNames()": {
+			spanName: "blocksStoreQuerier.LabelNames",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelNames()
+				return err
+			},
+		},
+		"LabelValues()": {
+			spanName: "blocksStoreQuerier.LabelValues",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelValues(labels.MetricName)
+				return err
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			tracer := mocktracer.New()
+			prevTracer := opentracing.GlobalTracer()
+			opentracing.SetGlobalTracer(tracer)
+			t.Cleanup(func() { opentracing.SetGlobalTracer(prevTracer) })
+
+			newClient := func(remoteAddr string, blockID ulid.ULID) *storeGatewayClientMock {
+				return &storeGatewayClientMock{
+					remoteAddr: remoteAddr,
+					mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(blockID),
+					},
+					mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+						Names: namesFromSeries(series),
+						Hints: mockNamesHints(blockID),
+					},
+					mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+						Values: valuesFromSeries(labels.MetricName, series),
+						Hints:  mockValuesHints(blockID),
+					},
+				}
+			}
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					newClient("1.1.1.1", block1): {block1},
+					newClient("2.2.2.2", block2): {block2},
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			require.NoError(t, testData.query(q))
+
+			// Collect the mapping between queried blocks and store-gateways from the span logs.
+			actual := map[string]string{}
+			for _, span := range tracer.FinishedSpans() {
+				if span.OperationName != testData.spanName {
+					continue
+				}
+
+				for _, record := range span.Logs() {
+					if len(record.Fields) == 0 || record.Fields[0].ValueString != "queried blocks served by store-gateway" {
+						continue
+					}
+
+					for _, field := range record.Fields[1:] {
+						actual[field.Key] = field.ValueString
+					}
+				}
+			}
+
+			assert.Equal(t, map[string]string{
+				block1.String(): "1.1.1.1",
+				block2.String(): "2.2.2.2",
+			}, actual)
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_ShouldRefetchBlocksOnPerCallTimeout(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	tests := map[string]struct {
+		operation string
+		query     func(q *blocksStoreQuerier) error
+	}{
+		"Select()": {
+			operation: "Series",
+			query: func(q *blocksStoreQuerier) error {
+				set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+				for set.Next() {
+				}
+				return set.Err()
+			},
+		},
+		"LabelNames()": {
+			operation: "LabelNames",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelNames()
+				return err
+			},
+		},
+		"LabelValues()": {
+			operation: "LabelValues",
+			query: func(q *blocksStoreQuerier) error {
+				_, _, err := q.LabelValues(labels.MetricName)
+				return err
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			newClient := func(remoteAddr string, blockID ulid.ULID) *storeGatewayClientMock {
+				return &storeGatewayClientMock{
+					remoteAddr: remoteAddr,
+					mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(series, minT, 1),
+						mockHintsResponse(blockID),
+					},
+					mockedLabelNamesResponse: &storepb.LabelNamesResponse{
+						Names: namesFromSeries(series),
+						Hints: mockNamesHints(blockID),
+					},
+					mockedLabelValuesResponse: &storepb.LabelValuesResponse{
+						Values: valuesFromSeries(labels.MetricName, series),
+						Hints:  mockValuesHints(blockID),
+					},
+				}
+			}
+
+			// The first attempt hits the per-call timeout on the store-gateway holding block1,
+			// so block1 is fetched again from another store-gateway.
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&unresponsiveStoreGatewayClient{newClient("1.1.1.1", block1)}: {block1},
+					newClient("2.2.2.2", block2):                                  {block2},
+				},
+				map[BlocksStoreClient][]ulid.ULID{
+					newClient("3.3.3.3", block1): {block1},
+				},
+			}}
+
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:            limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+				minT:           minT,
+				maxT:           maxT,
+				userID:         "user-1",
+				finder:         finder,
+				stores:         stores,
+				consistency:    NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:         log.NewNopLogger(),
+				metrics:        newBlocksStoreQueryableMetrics(nil),
+				limits:         &blocksStoreLimitsMock{},
+				perCallTimeout: 100 * time.Millisecond,
+			}
+
+			require.NoError(t, testData.query(q))
+			assert.Equal(t, []map[ulid.ULID][]string{{}, {block1: {"1.1.1.1"}, block2: {"2.2.2.2"}}}, stores.receivedExcludes)
+			assert.Equal(t, float64(1), testutil.ToFloat64(q.metrics.perCallTimeouts.WithLabelValues(testData.operation)))
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		queryStoreAfter         time.Duration
+		queryStoreAfterOverride *time.Duration
+		queryMinT               int64
+		queryMaxT               int64
+		expectedMinT            int64
+		expectedMaxT            int64
+	}{
+		"should not manipulate query time range if queryStoreAfter is disabled": {
+			queryStoreAfter: 0,
+			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:       util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:    util.TimeToMillis(now.Add(-30 * time.Minute)),
+		},
+		"should not manipulate query time range if queryStoreAfter is enabled but query max time is older": {
+			queryStoreAfter: time.Hour,
+			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:       util.TimeToMillis(now.Add(-70 * time.Minute)),
+			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:    util.TimeToMillis(now.Add(-70 * time.Minute)),
+		},
+		"should manipulate query time range if queryStoreAfter is enabled and query max time is recent": {
+			queryStoreAfter: time.Hour,
+			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:       util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:    util.TimeToMillis(now.Add(-60 * time.Minute)),
+		},
+		"should skip the query if the query min time is more recent than queryStoreAfter": {
+			queryStoreAfter: time.Hour,
+			queryMinT:       util.TimeToMillis(now.Add(-50 * time.Minute)),
+			queryMaxT:       util.TimeToMillis(now.Add(-20 * time.Minute)),
+			expectedMinT:    0,
+			expectedMaxT:    0,
+		},
+		"should not manipulate query time range if queryStoreAfter is enabled but overridden to 0 in the context": {
+			queryStoreAfter:         time.Hour,
+			queryStoreAfterOverride: durationPtr(0),
+			queryMinT:               util.TimeToMillis(now.Add(-50 * time.Minute)),
+			queryMaxT:               util.TimeToMillis(now.Add(-20 * time.Minute)),
+			expectedMinT:            util.TimeToMillis(now.Add(-50 * time.Minute)),
+			expectedMaxT:            util.TimeToMillis(now.Add(-20 * time.Minute)),
+		},
+		"should manipulate query time range using the override in the context if queryStoreAfter is disabled": {
+			queryStoreAfter:         0,
+			queryStoreAfterOverride: durationPtr(time.Hour),
+			queryMinT:               util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:               util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:            util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:            util.TimeToMillis(now.Add(-60 * time.Minute)),
+		},
+		"should manipulate query time range using the override in the context instead of queryStoreAfter": {
+			queryStoreAfter:         time.Hour,
+			queryStoreAfterOverride: durationPtr(10 * time.Minute),
+			queryMinT:               util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:               util.TimeToMillis(now.Add(-5 * time.Minute)),
+			expectedMinT:            util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:            util.TimeToMillis(now.Add(-10 * time.Minute)),
+		},
+		"should skip the query if the query min time is more recent than the override in the context": {
+			queryStoreAfter:         0,
+			queryStoreAfterOverride: durationPtr(time.Hour),
+			queryMinT:               util.TimeToMillis(now.Add(-50 * time.Minute)),
+			queryMaxT:               util.TimeToMillis(now.Add(-20 * time.Minute)),
+			expectedMinT:            0,
+			expectedMaxT:            0,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", mock.Anything, mock.Anything).Return(bucketindex.Blocks(nil), map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), error(nil))
+
+			ctx := context.Background()
+			if testData.queryStoreAfterOverride != nil {
+				var err error
+				ctx, err = WithQueryStoreAfterOverride(ctx, *testData.queryStoreAfterOverride)
+				require.NoError(t, err)
+			}
+
+			q := &blocksStoreQuerier{
+				ctx:             ctx,
+				minT:            testData.queryMinT,
+				maxT:            testData.queryMaxT,
+				userID:          "user-1",
+				finder:          finder,
+				stores:          &blocksStoreSetMock{},
+				consistency:     NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:          log.NewNopLogger(),
+				metrics:         newBlocksStoreQueryableMetrics(nil),
+				limits:          &blocksStoreLimitsMock{},
+				queryStoreAfter: testData.queryStoreAfter,
+			}
+
+			sp := &storage.SelectHints{
+				Start: testData.queryMinT,
+				End:   testData.queryMaxT,
+			}
+
+			set := q.selectSeries(true, sp)
+			require.NoError(t, set.Err())
+
+			if testData.expectedMinT == 0 && testData.expectedMaxT == 0 {
+				assert.Len(t, finder.Calls, 0)
+			} else {
+				require.Len(t, finder.Calls, 1)
+				assert.Equal(t, testData.expectedMinT, finder.Calls[0].Arguments.Get(2))
+				assert.InDelta(t, testData.expectedMaxT, finder.Calls[0].Arguments.Get(3), float64(5*time.Second.Milliseconds()))
+			}
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_UnsortedSelect(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1  = ulid.MustNew(1, nil)
+		block2  = ulid.MustNew(2, nil)
+		series1 = labels.FromStrings(labels.MetricName, metricName, "series", "1")
+		series2 = labels.FromStrings(labels.MetricName, metricName, "series", "2")
+		series3 = labels.FromStrings(labels.MetricName, metricName, "series", "3")
+	)
+
+	// Each block is queried from a different store-gateway.
+	newQuerier := func(unsortedSelect bool, blocks bucketindex.Blocks, block1Series, block2Series []labels.Labels) *blocksStoreQuerier {
+		finder := &blocksFinderMock{}
+		finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+		var block1Responses, block2Responses []*storepb.SeriesResponse
+		for _, s := range block1Series {
+			block1Responses = append(block1Responses, mockSeriesResponse(s, minT, 1))
+		}
+		for _, s := range block2Series {
+			block2Responses = append(block2Responses, mockSeriesResponse(s, minT+1, 2))
+		}
+
+		return &blocksStoreQuerier{
+			ctx:    limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0)),
+			minT:   minT,
+			maxT:   maxT,
+			userID: "user-1",
+			finder: finder,
+			stores: &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: append(block1Responses, mockHintsResponse(block1))}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: append(block2Responses, mockHintsResponse(block2))}: {block2},
+				},
+			}},
+			consistency:    NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+			logger:         log.NewNopLogger(),
+			metrics:        newBlocksStoreQueryableMetrics(nil),
+			limits:         &blocksStoreLimitsMock{},
+			unsortedSelect: unsortedSelect,
+		}
+	}
+
+	readSeries := func(t *testing.T, set storage.SeriesSet) (lbls []labels.Labels, samples map[string][]int64) {
+		samples = map[string][]int64{}
+		for set.Next() {
+			s := set.At()
+			lbls = append(lbls, s.Labels())
+
+			it := s.Iterator()
+			for it.Next() {
+				ts, _ := it.At()
+				samples[s.Labels().String()] = append(samples[s.Labels().String()], ts)
+			}
+			require.NoError(t, it.Err())
+		}
+		require.NoError(t, set.Err())
+		return lbls, samples
+	}
+
+	tests := map[string]struct {
+		blocks          bucketindex.Blocks
+		block1Series    []labels.Labels
+		block2Series    []labels.Labels
+		expectedSamples map[string][]int64
+	}{
+		"blocks not split by the compactor, with the same series": {
+			blocks:       bucketindex.Blocks{{ID: block1}, {ID: block2}},
+			block1Series: []labels.Labels{series1, series3},
+			block2Series: []labels.Labels{series2, series3},
+			expectedSamples: map[string][]int64{
+				series1.String(): {minT},
+				series2.String(): {minT + 1},
+				series3.String(): {minT, minT + 1},
+			},
+		},
+		"blocks of different compactor shards, with different series": {
+			blocks:       bucketindex.Blocks{{ID: block1, CompactorShardID: "1_of_2"}, {ID: block2, CompactorShardID: "2_of_2"}},
+			block1Series: []labels.Labels{series1, series3},
+			block2Series: []labels.Labels{series2},
+			expectedSamples: map[string][]int64{
+				series1.String(): {minT},
+				series2.String(): {minT + 1},
+				series3.String(): {minT},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			for _, unsortedSelect := range []bool{false, true} {
+				t.Run(fmt.Sprintf("should sort the series if required, unsorted select enabled: %t", unsortedSelect), func(t *testing.T) {
+					q := newQuerier(unsortedSelect, testData.blocks, testData.block1Series, testData.block2Series)
+					set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+
+					lbls, samples := readSeries(t, set)
+					assert.Equal(t, []labels.Labels{series1, series2, series3}, lbls)
+					assert.Equal(t, testData.expectedSamples, samples)
+				})
+			}
+
+			t.Run("should return each series once if sorted series are not required", func(t *testing.T) {
+				q := newQuerier(true, testData.blocks, testData.block1Series, testData.block2Series)
+				set := q.Select(false, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+
+				lbls, samples := readSeries(t, set)
+				assert.ElementsMatch(t, []labels.Labels{series1, series2, series3}, lbls)
+				assert.Equal(t, testData.expectedSamples, samples)
+			})
+		})
+	}
+}
+
+func TestCanConcatBlocksSeriesSets(t *testing.T) {
+	var (
+		block1 = ulid.MustNew(1, nil)
+		block2 = ulid.MustNew(2, nil)
+		block3 = ulid.MustNew(3, nil)
+		block4 = ulid.MustNew(4, nil)
+	)
+
+	newSet := func(blockIDs ...ulid.ULID) storage.SeriesSet {
+		return &blockQuerierSeriesSet{
+			series: []*storepb.Series{{Labels: labelpb.ZLabelsFromPromLabels(labels.FromStrings(labels.MetricName, "test"))}},
+			blocks: blockIDs,
+		}
+	}
 
-func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T) {
-	now := time.Now()
+	// The blocks 1 and 3 belong to the same compactor shard, at different time ranges.
+	shardedBlocks := bucketindex.Blocks{
+		{ID: block1, CompactorShardID: "1_of_2"},
+		{ID: block2, CompactorShardID: "2_of_2"},
+		{ID: block3, CompactorShardID: "1_of_2"},
+		{ID: block4, CompactorShardID: "2_of_2"},
+	}
 
 	tests := map[string]struct {
-		queryStoreAfter time.Duration
-		queryMinT       int64
-		queryMaxT       int64
-		expectedMinT    int64
-		expectedMaxT    int64
+		sets     []storage.SeriesSet
+		blocks   bucketindex.Blocks
+		expected bool
 	}{
-		"should not manipulate query time range if queryStoreAfter is disabled": {
-			queryStoreAfter: 0,
-			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
-			queryMaxT:       util.TimeToMillis(now.Add(-30 * time.Minute)),
-			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
-			expectedMaxT:    util.TimeToMillis(now.Add(-30 * time.Minute)),
+		"no sets": {
+			expected: true,
 		},
-		"should not manipulate query time range if queryStoreAfter is enabled but query max time is older": {
-			queryStoreAfter: time.Hour,
-			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
-			queryMaxT:       util.TimeToMillis(now.Add(-70 * time.Minute)),
-			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
-			expectedMaxT:    util.TimeToMillis(now.Add(-70 * time.Minute)),
+		"single set": {
+			sets:     []storage.SeriesSet{newSet(block1, block2)},
+			blocks:   bucketindex.Blocks{{ID: block1}, {ID: block2}},
+			expected: true,
 		},
-		"should manipulate query time range if queryStoreAfter is enabled and query max time is recent": {
-			queryStoreAfter: time.Hour,
-			queryMinT:       util.TimeToMillis(now.Add(-100 * time.Minute)),
-			queryMaxT:       util.TimeToMillis(now.Add(-30 * time.Minute)),
-			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
-			expectedMaxT:    util.TimeToMillis(now.Add(-60 * time.Minute)),
+		"blocks not split by the compactor": {
+			sets:     []storage.SeriesSet{newSet(block1), newSet(block2)},
+			blocks:   bucketindex.Blocks{{ID: block1}, {ID: block2}},
+			expected: false,
 		},
-		"should skip the query if the query min time is more recent than queryStoreAfter": {
-			queryStoreAfter: time.Hour,
-			queryMinT:       util.TimeToMillis(now.Add(-50 * time.Minute)),
-			queryMaxT:       util.TimeToMillis(now.Add(-20 * time.Minute)),
-			expectedMinT:    0,
-			expectedMaxT:    0,
+		"each compactor shard fetched in a single set": {
+			sets:     []storage.SeriesSet{newSet(block1, block3), newSet(block2, block4)},
+			blocks:   shardedBlocks,
+			expected: true,
+		},
+		"compactor shard fetched in multiple sets": {
+			sets:     []storage.SeriesSet{newSet(block1, block2), newSet(block3, block4)},
+			blocks:   shardedBlocks,
+			expected: false,
+		},
+		"blocks split in a different number of shards": {
+			sets:     []storage.SeriesSet{newSet(block1), newSet(block2)},
+			blocks:   bucketindex.Blocks{{ID: block1, CompactorShardID: "1_of_2"}, {ID: block2, CompactorShardID: "2_of_4"}},
+			expected: false,
+		},
+		"block with an invalid compactor shard ID": {
+			sets:     []storage.SeriesSet{newSet(block1), newSet(block2)},
+			blocks:   bucketindex.Blocks{{ID: block1, CompactorShardID: "1_of_2"}, {ID: block2, CompactorShardID: "invalid"}},
+			expected: false,
+		},
+		"set with series from unknown blocks": {
+			sets:     []storage.SeriesSet{newSet(block1), newSet()},
+			blocks:   shardedBlocks,
+			expected: false,
+		},
+		"set not fetched from store-gateways": {
+			sets:     []storage.SeriesSet{newSet(block1), storage.EmptySeriesSet()},
+			blocks:   shardedBlocks,
+			expected: false,
 		},
 	}
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			finder := &blocksFinderMock{}
-			finder.On("GetBlocks", mock.Anything, "user-1", mock.Anything, mock.Anything).Return(bucketindex.Blocks(nil), map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), error(nil))
+			assert.Equal(t, testData.expected, canConcatBlocksSeriesSets(testData.sets, testData.blocks))
+		})
+	}
+}
 
-			q := &blocksStoreQuerier{
-				ctx:             context.Background(),
-				minT:            testData.queryMinT,
-				maxT:            testData.queryMaxT,
-				userID:          "user-1",
-				finder:          finder,
-				stores:          &blocksStoreSetMock{},
-				consistency:     NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
-				logger:          log.NewNopLogger(),
-				metrics:         newBlocksStoreQueryableMetrics(nil),
-				limits:          &blocksStoreLimitsMock{},
-				queryStoreAfter: testData.queryStoreAfter,
-			}
+func TestWithQueryStoreAfterOverride(t *testing.T) {
+	ctx, err := WithQueryStoreAfterOverride(context.Background(), -time.Minute)
+	require.EqualError(t, err, "the query store after override must be greater than or equal to 0 (got: -1m0s)")
 
-			sp := &storage.SelectHints{
-				Start: testData.queryMinT,
-				End:   testData.queryMaxT,
-			}
+	_, ok := queryStoreAfterOverrideFromContext(ctx)
+	assert.False(t, ok)
 
-			set := q.selectSorted(sp)
-			require.NoError(t, set.Err())
+	ctx, err = WithQueryStoreAfterOverride(context.Background(), time.Minute)
+	require.NoError(t, err)
 
-			if testData.expectedMinT == 0 && testData.expectedMaxT == 0 {
-				assert.Len(t, finder.Calls, 0)
-			} else {
-				require.Len(t, finder.Calls, 1)
-				assert.Equal(t, testData.expectedMinT, finder.Calls[0].Arguments.Get(2))
-				assert.InDelta(t, testData.expectedMaxT, finder.Calls[0].Arguments.Get(3), float64(5*time.Second.Milliseconds()))
-			}
-		})
-	}
+	override, ok := queryStoreAfterOverrideFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, override)
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
 }
 
 func TestBlocksStoreQuerier_MaxLabelsQueryRange(t *testing.T) {
@@ -1569,6 +4652,119 @@ func TestBlocksStoreQuerier_MaxLabelsQueryRange(t *testing.T) {
 	}
 }
 
+func TestBlocksStoreQuerier_MaxSeriesQueryLength(t *testing.T) {
+	const (
+		thirtyDays = 30 * 24 * time.Hour
+		sevenDays  = 7 * 24 * time.Hour
+	)
+	now := time.Now()
+
+	tests := map[string]struct {
+		maxSeriesQueryLength       time.Duration
+		maxSeriesQueryLengthReject bool
+		queryStoreAfter            time.Duration
+		queryMinT                  int64
+		queryMaxT                  int64
+		expectedMinT               int64
+		expectedMaxT               int64
+		expectedErr                string
+	}{
+		"should not manipulate query time range if maxSeriesQueryLength is disabled": {
+			maxSeriesQueryLength: 0,
+			queryMinT:            util.TimeToMillis(now.Add(-thirtyDays)),
+			queryMaxT:            util.TimeToMillis(now),
+			expectedMinT:         util.TimeToMillis(now.Add(-thirtyDays)),
+			expectedMaxT:         util.TimeToMillis(now),
+		},
+		"should not manipulate query time range if maxSeriesQueryLength is enabled but query fits within": {
+			maxSeriesQueryLength: sevenDays,
+			queryMinT:            util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:            util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:         util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:         util.TimeToMillis(now.Add(-30 * time.Minute)),
+		},
+		"should clamp query time range if maxSeriesQueryLength is enabled and query exceeds it": {
+			maxSeriesQueryLength: sevenDays,
+			queryMinT:            util.TimeToMillis(now.Add(-thirtyDays)),
+			queryMaxT:            util.TimeToMillis(now),
+			expectedMinT:         util.TimeToMillis(now.Add(-sevenDays)),
+			expectedMaxT:         util.TimeToMillis(now),
+		},
+		"should reject the query if maxSeriesQueryLength is enabled, query exceeds it and rejection is enabled": {
+			maxSeriesQueryLength:       sevenDays,
+			maxSeriesQueryLengthReject: true,
+			queryMinT:                  util.TimeToMillis(now.Add(-thirtyDays)),
+			queryMaxT:                  util.TimeToMillis(now),
+			expectedErr:                "the time range of the query to the blocks storage exceeds the limit (query length: 720h0m0s, limit: 168h0m0s)",
+		},
+		"should not reject the query if maxSeriesQueryLength is enabled, query fits within and rejection is enabled": {
+			maxSeriesQueryLength:       sevenDays,
+			maxSeriesQueryLengthReject: true,
+			queryMinT:                  util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:                  util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:               util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:               util.TimeToMillis(now.Add(-30 * time.Minute)),
+		},
+		"should clamp query time range relative to the max time manipulated by queryStoreAfter": {
+			maxSeriesQueryLength: time.Hour,
+			queryStoreAfter:      time.Hour,
+			queryMinT:            util.TimeToMillis(now.Add(-3 * time.Hour)),
+			queryMaxT:            util.TimeToMillis(now),
+			expectedMinT:         util.TimeToMillis(now.Add(-2 * time.Hour)),
+			expectedMaxT:         util.TimeToMillis(now.Add(-time.Hour)),
+		},
+		"should not reject the query if the time range queried from the blocks storage, after queryStoreAfter, fits within maxSeriesQueryLength": {
+			maxSeriesQueryLength:       time.Hour,
+			maxSeriesQueryLengthReject: true,
+			queryStoreAfter:            time.Hour,
+			queryMinT:                  util.TimeToMillis(now.Add(-90 * time.Minute)),
+			queryMaxT:                  util.TimeToMillis(now),
+			expectedMinT:               util.TimeToMillis(now.Add(-90 * time.Minute)),
+			expectedMaxT:               util.TimeToMillis(now.Add(-time.Hour)),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", mock.Anything, mock.Anything).Return(bucketindex.Blocks(nil), map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), error(nil))
+
+			q := &blocksStoreQuerier{
+				ctx:         user.InjectOrgID(context.Background(), "user-1"),
+				minT:        testData.queryMinT,
+				maxT:        testData.queryMaxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      &blocksStoreSetMock{},
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits: &blocksStoreLimitsMock{
+					maxSeriesQueryLength:       testData.maxSeriesQueryLength,
+					maxSeriesQueryLengthReject: testData.maxSeriesQueryLengthReject,
+				},
+				queryStoreAfter: testData.queryStoreAfter,
+			}
+
+			set := q.selectSeries(true, &storage.SelectHints{Start: testData.queryMinT, End: testData.queryMaxT})
+
+			if testData.expectedErr != "" {
+				require.Error(t, set.Err())
+				assert.IsType(t, validation.LimitError(""), set.Err())
+				assert.Contains(t, set.Err().Error(), testData.expectedErr)
+				assert.Len(t, finder.Calls, 0)
+				assert.Equal(t, float64(1), testutil.ToFloat64(q.metrics.limitRejections.WithLabelValues(limitMaxSeriesQueryLength)))
+				return
+			}
+
+			require.NoError(t, set.Err())
+			require.Len(t, finder.Calls, 1)
+			assert.InDelta(t, testData.expectedMinT, finder.Calls[0].Arguments.Get(2), float64(5*time.Second.Milliseconds()))
+			assert.InDelta(t, testData.expectedMaxT, finder.Calls[0].Arguments.Get(3), float64(5*time.Second.Milliseconds()))
+		})
+	}
+}
+
 func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 	// Prepare series fixtures.
 	series1 := labels.Labels{{Name: "__name__", Value: "metric_1"}}
@@ -1668,7 +4864,7 @@ func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 
 			// Instantiate the querier that will be executed to run the query.
 			logger := log.NewNopLogger()
-			queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, 0, logger, nil)
+			queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, BlocksStoreQueryableConfig{}, logger, nil, nil)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(context.Background(), queryable))
 			defer services.StopAndAwaitTerminated(context.Background(), queryable) // nolint:errcheck
@@ -1695,6 +4891,57 @@ func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 	}
 }
 
+func TestCreateLabelNamesRequest(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	matchers := []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: "test_metric"}}
+
+	req, err := createLabelNamesRequest(10, 20, []ulid.ULID{block1, block2}, matchers, storepb.PartialResponseStrategy_ABORT)
+	require.NoError(t, err)
+
+	expectedHints, err := types.MarshalAny(&hintspb.LabelNamesRequestHints{
+		BlockMatchers: []storepb.LabelMatcher{
+			{Type: storepb.LabelMatcher_RE, Name: block.BlockIDLabel, Value: block1.String() + "|" + block2.String()},
+		},
+	})
+	require.NoError(t, err)
+
+	// The request must only carry the time range, the matchers and the blocks to query.
+	assert.Equal(t, &storepb.LabelNamesRequest{
+		Start:                   10,
+		End:                     20,
+		Matchers:                matchers,
+		PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+		Hints:                   expectedHints,
+	}, req)
+}
+
+func TestCreateLabelValuesRequest(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	matcher := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "test_metric")
+
+	req, err := createLabelValuesRequest(10, 20, "job", []ulid.ULID{block1, block2}, storepb.PartialResponseStrategy_WARN, matcher)
+	require.NoError(t, err)
+
+	expectedHints, err := types.MarshalAny(&hintspb.LabelValuesRequestHints{
+		BlockMatchers: []storepb.LabelMatcher{
+			{Type: storepb.LabelMatcher_RE, Name: block.BlockIDLabel, Value: block1.String() + "|" + block2.String()},
+		},
+	})
+	require.NoError(t, err)
+
+	// The request must only carry the time range, the label, the matchers and the blocks to query.
+	assert.Equal(t, &storepb.LabelValuesRequest{
+		Start:                   10,
+		End:                     20,
+		Label:                   "job",
+		Matchers:                []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: "test_metric"}},
+		PartialResponseStrategy: storepb.PartialResponseStrategy_WARN,
+		Hints:                   expectedHints,
+	}, req)
+}
+
 func TestCanBlockWithCompactorShardIdContainQueryShard(t *testing.T) {
 	const numSeries = 1000
 	const maxShards = 512
@@ -1724,6 +4971,130 @@ func TestCanBlockWithCompactorShardIdContainQueryShard(t *testing.T) {
 	}
 }
 
+func TestEstimateChunksFromBlocks(t *testing.T) {
+	tests := map[string]struct {
+		blocks   bucketindex.Blocks
+		minT     int64
+		maxT     int64
+		shard    *sharding.ShardSelector
+		expected float64
+	}{
+		"no blocks": {
+			minT:     0,
+			maxT:     99,
+			expected: 0,
+		},
+		"blocks without stats are not accounted": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100},
+				{ID: ulid.MustNew(2, nil), MinTime: 0, MaxTime: 100, NumChunks: 10},
+			},
+			minT:     0,
+			maxT:     99,
+			expected: 10,
+		},
+		"blocks fully within the query time range": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, NumChunks: 10},
+				{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 200, NumChunks: 20},
+			},
+			minT:     0,
+			maxT:     199,
+			expected: 30,
+		},
+		"blocks partially within the query time range": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, NumChunks: 10},
+				{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 200, NumChunks: 20},
+			},
+			minT:     50,
+			maxT:     149,
+			expected: 15,
+		},
+		"sharded query on non-split blocks": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, NumChunks: 40},
+			},
+			minT:     0,
+			maxT:     99,
+			shard:    &sharding.ShardSelector{ShardIndex: 0, ShardCount: 4},
+			expected: 10,
+		},
+		"sharded query on split blocks": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, NumChunks: 40, CompactorShardID: "1_of_2"},
+			},
+			minT:     0,
+			maxT:     99,
+			shard:    &sharding.ShardSelector{ShardIndex: 0, ShardCount: 4},
+			expected: 20,
+		},
+		"sharded query on blocks split in more shards than the query": {
+			blocks: bucketindex.Blocks{
+				{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100, NumChunks: 40, CompactorShardID: "1_of_8"},
+			},
+			minT:     0,
+			maxT:     99,
+			shard:    &sharding.ShardSelector{ShardIndex: 0, ShardCount: 4},
+			expected: 40,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, estimateChunksFromBlocks(testData.blocks, testData.minT, testData.maxT, testData.shard))
+		})
+	}
+}
+
+func TestFindOverlappingBlocks(t *testing.T) {
+	block1 := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 100}
+	block2 := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 100, MaxTime: 200}
+	block3 := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 40, MaxTime: 140}  // Overlaps 60% with block1 and 40% with block2.
+	block4 := &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: 0, MaxTime: 200}   // Fully covers block1, block2 and block3.
+	block5 := &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: 150, MaxTime: 250} // Overlaps 50% with block2.
+
+	for name, testcase := range map[string]struct {
+		blocks   bucketindex.Blocks
+		expected []string
+	}{
+		"no blocks": {
+			blocks:   nil,
+			expected: nil,
+		},
+		"adjacent blocks": {
+			blocks:   bucketindex.Blocks{block1, block2},
+			expected: nil,
+		},
+		"blocks overlapping for more than 50%": {
+			blocks:   bucketindex.Blocks{block2, block3, block1},
+			expected: []string{block1.ID.String() + ":" + block3.ID.String()},
+		},
+		"blocks overlapping for exactly 50%": {
+			blocks:   bucketindex.Blocks{block2, block5},
+			expected: nil,
+		},
+		"block fully covering other blocks": {
+			blocks: bucketindex.Blocks{block1, block2, block3, block4},
+			expected: []string{
+				block1.ID.String() + ":" + block4.ID.String(),
+				block1.ID.String() + ":" + block3.ID.String(),
+				block4.ID.String() + ":" + block3.ID.String(),
+				block4.ID.String() + ":" + block2.ID.String(),
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			input := append(bucketindex.Blocks(nil), testcase.blocks...)
+
+			assert.ElementsMatch(t, testcase.expected, findOverlappingBlocks(input))
+
+			// The input blocks should not be modified.
+			assert.Equal(t, testcase.blocks, input)
+		})
+	}
+}
+
 func TestFilterBlocksByShard(t *testing.T) {
 	block1 := &bucketindex.Block{ID: ulid.MustNew(ulid.Now(), crand.Reader), MinTime: 0, MaxTime: 100, CompactorShardID: "1_of_4"}
 	block2 := &bucketindex.Block{ID: ulid.MustNew(ulid.Now(), crand.Reader), MinTime: 0, MaxTime: 100, CompactorShardID: "2_of_4"}
@@ -1795,18 +5166,76 @@ func TestFilterBlocksByShard(t *testing.T) {
 	}
 }
 
+// delayedBlocksStoreSet is a BlocksStoreSet whose store-gateways serve the delayed block
+// only once the elapsed time reaches availableAfter, while all other blocks are always served.
+// The elapsed time is advanced by the caller, so that it doesn't depend on the wall clock.
+type delayedBlocksStoreSet struct {
+	services.Service
+
+	metricName     string
+	minT           int64
+	delayed        ulid.ULID
+	availableAfter time.Duration
+	elapsed        time.Duration
+
+	// The number of GetClientsFor() calls.
+	calls int
+}
+
+func (s *delayedBlocksStoreSet) GetClientsFor(_ string, blockIDs []ulid.ULID, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+	s.calls++
+
+	queried := make([]ulid.ULID, 0, len(blockIDs))
+	for _, blockID := range blockIDs {
+		if blockID != s.delayed || s.elapsed >= s.availableAfter {
+			queried = append(queried, blockID)
+		}
+	}
+
+	client := &storeGatewayClientMock{
+		remoteAddr: fmt.Sprintf("%d.%d.%d.%d", s.calls, s.calls, s.calls, s.calls),
+		mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.FromStrings(labels.MetricName, s.metricName), s.minT, 1),
+			mockHintsResponse(queried...),
+		},
+	}
+
+	return map[BlocksStoreClient][]ulid.ULID{client: blockIDs}, nil
+}
+
+func (s *delayedBlocksStoreSet) GetAddressesFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[string][]ulid.ULID, error) {
+	return clientsToAddresses(s.GetClientsFor(userID, blockIDs, exclude))
+}
+
+func (s *delayedBlocksStoreSet) TenantShardInstancesCount(_ string) int {
+	return 0
+}
+
 type blocksStoreSetMock struct {
 	services.Service
 
 	mockedResponses []interface{}
 	nextResult      int
+
+	// The exclude maps received by each GetClientsFor() call.
+	receivedExcludes []map[ulid.ULID][]string
+
+	// The number of instances in the tenant shard, and the number of times it has been looked up.
+	shardInstances      int
+	shardInstancesCalls int
 }
 
-func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
 	if m.nextResult >= len(m.mockedResponses) {
 		panic("not enough mocked results")
 	}
 
+	excludeCopy := make(map[ulid.ULID][]string, len(exclude))
+	for blockID, addrs := range exclude {
+		excludeCopy[blockID] = append([]string(nil), addrs...)
+	}
+	m.receivedExcludes = append(m.receivedExcludes, excludeCopy)
+
 	res := m.mockedResponses[m.nextResult]
 	m.nextResult++
 
@@ -1820,6 +5249,28 @@ func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, _ map[ulid.U
 	return nil, errors.New("unknown data type in the mocked result")
 }
 
+// GetAddressesFor returns the addresses of the next mocked result, like GetClientsFor.
+func (m *blocksStoreSetMock) GetAddressesFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[string][]ulid.ULID, error) {
+	return clientsToAddresses(m.GetClientsFor(userID, blockIDs, exclude))
+}
+
+func clientsToAddresses(clients map[BlocksStoreClient][]ulid.ULID, err error) (map[string][]ulid.ULID, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make(map[string][]ulid.ULID, len(clients))
+	for c, blockIDs := range clients {
+		addrs[c.RemoteAddress()] = append(addrs[c.RemoteAddress()], blockIDs...)
+	}
+	return addrs, nil
+}
+
+func (m *blocksStoreSetMock) TenantShardInstancesCount(_ string) int {
+	m.shardInstancesCalls++
+	return m.shardInstances
+}
+
 type blocksFinderMock struct {
 	services.Service
 	mock.Mock
@@ -1830,6 +5281,17 @@ func (m *blocksFinderMock) GetBlocks(ctx context.Context, userID string, minT, m
 	return args.Get(0).(bucketindex.Blocks), args.Get(1).(map[ulid.ULID]*bucketindex.BlockDeletionMark), args.Error(2)
 }
 
+// blocksFinderWithWarningsMock is a blocksFinderMock which reports the input warnings about skipped blocks.
+type blocksFinderWithWarningsMock struct {
+	*blocksFinderMock
+	warnings storage.Warnings
+}
+
+func (m *blocksFinderWithWarningsMock) GetBlocksWithWarnings(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, storage.Warnings, error) {
+	blocks, deletionMarks, err := m.GetBlocks(ctx, userID, minT, maxT)
+	return blocks, deletionMarks, m.warnings, err
+}
+
 type storeGatewayClientMock struct {
 	remoteAddr                string
 	mockedSeriesResponses     []*storepb.SeriesResponse
@@ -1838,9 +5300,26 @@ type storeGatewayClientMock struct {
 	mockedLabelNamesErr       error
 	mockedLabelValuesResponse *storepb.LabelValuesResponse
 	mockedLabelValuesErr      error
+
+	// The partial response strategy of the last received request.
+	receivedPartialResponseStrategy storepb.PartialResponseStrategy
+
+	// The call options of the last received request.
+	receivedCallOptions []grpc.CallOption
+
+	// The matchers of the last received request.
+	receivedMatchers []storepb.LabelMatcher
+
+	// The gRPC metadata of the last received request.
+	receivedMetadata grpc_metadata.MD
 }
 
 func (m *storeGatewayClientMock) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	m.receivedPartialResponseStrategy = in.PartialResponseStrategy
+	m.receivedCallOptions = opts
+	m.receivedMatchers = in.Matchers
+	m.receivedMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
+
 	seriesClient := &storeGatewaySeriesClientMock{
 		mockedResponses: m.mockedSeriesResponses,
 	}
@@ -1848,11 +5327,19 @@ func (m *storeGatewayClientMock) Series(ctx context.Context, in *storepb.SeriesR
 	return seriesClient, m.mockedSeriesErr
 }
 
-func (m *storeGatewayClientMock) LabelNames(context.Context, *storepb.LabelNamesRequest, ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+func (m *storeGatewayClientMock) LabelNames(ctx context.Context, in *storepb.LabelNamesRequest, opts ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	m.receivedPartialResponseStrategy = in.PartialResponseStrategy
+	m.receivedCallOptions = opts
+	m.receivedMatchers = in.Matchers
+	m.receivedMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
 	return m.mockedLabelNamesResponse, m.mockedLabelNamesErr
 }
 
-func (m *storeGatewayClientMock) LabelValues(context.Context, *storepb.LabelValuesRequest, ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+func (m *storeGatewayClientMock) LabelValues(ctx context.Context, in *storepb.LabelValuesRequest, opts ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+	m.receivedPartialResponseStrategy = in.PartialResponseStrategy
+	m.receivedCallOptions = opts
+	m.receivedMatchers = in.Matchers
+	m.receivedMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
 	return m.mockedLabelValuesResponse, m.mockedLabelValuesErr
 }
 
@@ -1860,6 +5347,27 @@ func (m *storeGatewayClientMock) RemoteAddress() string {
 	return m.remoteAddr
 }
 
+// unresponsiveStoreGatewayClient is a store-gateway client whose calls never complete
+// until the input context is done.
+type unresponsiveStoreGatewayClient struct {
+	*storeGatewayClientMock
+}
+
+func (m *unresponsiveStoreGatewayClient) Series(ctx context.Context, _ *storepb.SeriesRequest, _ ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *unresponsiveStoreGatewayClient) LabelNames(ctx context.Context, _ *storepb.LabelNamesRequest, _ ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *unresponsiveStoreGatewayClient) LabelValues(ctx context.Context, _ *storepb.LabelValuesRequest, _ ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
 type storeGatewaySeriesClientMock struct {
 	grpc.ClientStream
 
@@ -1881,22 +5389,71 @@ func (m *storeGatewaySeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
 
 type blocksStoreLimitsMock struct {
 	maxLabelsQueryLength        time.Duration
+	maxSeriesQueryLength        time.Duration
+	maxSeriesQueryLengthReject  bool
 	maxChunksPerQuery           int
+	maxBlocksPerQuery           int
+	maxConcurrentQueries        int
 	storeGatewayTenantShardSize int
+	blocksStoreMaxRefetches     int
+
+	queryPartialDataOnConsistencyFailure bool
+	storeGatewayPartialResponse          bool
+	maxEstimatedChunksPerQueryMultiplier float64
+	storeGatewayChunksDeduplication      bool
 }
 
 func (m *blocksStoreLimitsMock) MaxLabelsQueryLength(_ string) time.Duration {
 	return m.maxLabelsQueryLength
 }
 
+func (m *blocksStoreLimitsMock) MaxSeriesQueryLength(_ string) time.Duration {
+	return m.maxSeriesQueryLength
+}
+
+func (m *blocksStoreLimitsMock) MaxSeriesQueryLengthReject(_ string) bool {
+	return m.maxSeriesQueryLengthReject
+}
+
 func (m *blocksStoreLimitsMock) MaxChunksPerQuery(_ string) int {
 	return m.maxChunksPerQuery
 }
 
+func (m *blocksStoreLimitsMock) MaxBlocksPerQuery(_ string) int {
+	return m.maxBlocksPerQuery
+}
+
+func (m *blocksStoreLimitsMock) MaxConcurrentQueriesPerTenant(_ string) int {
+	return m.maxConcurrentQueries
+}
+
 func (m *blocksStoreLimitsMock) StoreGatewayTenantShardSize(_ string) int {
 	return m.storeGatewayTenantShardSize
 }
 
+func (m *blocksStoreLimitsMock) BlocksStoreMaxRefetches(_ string) int {
+	if m.blocksStoreMaxRefetches == 0 {
+		return 3
+	}
+	return m.blocksStoreMaxRefetches
+}
+
+func (m *blocksStoreLimitsMock) QueryPartialDataOnConsistencyFailure(_ string) bool {
+	return m.queryPartialDataOnConsistencyFailure
+}
+
+func (m *blocksStoreLimitsMock) StoreGatewayPartialResponse(_ string) bool {
+	return m.storeGatewayPartialResponse
+}
+
+func (m *blocksStoreLimitsMock) MaxEstimatedChunksPerQueryMultiplier(_ string) float64 {
+	return m.maxEstimatedChunksPerQueryMultiplier
+}
+
+func (m *blocksStoreLimitsMock) StoreGatewayChunksDeduplication(_ string) bool {
+	return m.storeGatewayChunksDeduplication
+}
+
 func (m *blocksStoreLimitsMock) S3SSEType(_ string) string {
 	return ""
 }
@@ -1946,6 +5503,21 @@ func mockHintsResponse(ids ...ulid.ULID) *storepb.SeriesResponse {
 	}
 }
 
+// mockHintsExtensionResponse returns a response with the input additional hints, like the one sent by
+// store-gateways reporting them along with the queried blocks ones.
+func mockHintsExtensionResponse(ext *storegatewaypb.SeriesResponseHintsExtension) *storepb.SeriesResponse {
+	any, err := types.MarshalAny(ext)
+	if err != nil {
+		panic(err)
+	}
+
+	return &storepb.SeriesResponse{
+		Result: &storepb.SeriesResponse_Hints{
+			Hints: any,
+		},
+	}
+}
+
 func mockNamesHints(ids ...ulid.ULID) *types.Any {
 	hints := &hintspb.LabelNamesResponseHints{}
 	for _, id := range ids {
@@ -9,6 +9,9 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/ring"
@@ -17,9 +20,14 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
 
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storegateway"
+	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
 	"github.com/grafana/mimir/pkg/util"
 )
 
@@ -28,8 +36,37 @@ type loadBalancingStrategy int
 const (
 	noLoadBalancing = loadBalancingStrategy(iota)
 	randomLoadBalancing
+	roundRobinLoadBalancing
+	leastPendingRequestsLoadBalancing
 )
 
+const (
+	LoadBalancingStrategyRandom               = "random"
+	LoadBalancingStrategyRoundRobin           = "round-robin"
+	LoadBalancingStrategyLeastPendingRequests = "least-pending-requests"
+)
+
+var (
+	LoadBalancingStrategies = []string{LoadBalancingStrategyRandom, LoadBalancingStrategyRoundRobin, LoadBalancingStrategyLeastPendingRequests}
+
+	errInvalidLoadBalancingStrategy = fmt.Errorf("unsupported store-gateway load balancing strategy (supported values: %s)", strings.Join(LoadBalancingStrategies, ", "))
+)
+
+// parseLoadBalancingStrategy returns the load balancing strategy for the input name. An empty
+// name means the default random strategy, eg. when the config is built without the flag defaults.
+func parseLoadBalancingStrategy(name string) (loadBalancingStrategy, error) {
+	switch name {
+	case "", LoadBalancingStrategyRandom:
+		return randomLoadBalancing, nil
+	case LoadBalancingStrategyRoundRobin:
+		return roundRobinLoadBalancing, nil
+	case LoadBalancingStrategyLeastPendingRequests:
+		return leastPendingRequestsLoadBalancing, nil
+	default:
+		return noLoadBalancing, errInvalidLoadBalancingStrategy
+	}
+}
+
 // BlocksStoreSet implementation used when the blocks are sharded and replicated across
 // a set of store-gateway instances.
 type blocksStoreReplicationSet struct {
@@ -40,24 +77,71 @@ type blocksStoreReplicationSet struct {
 	balancingStrategy loadBalancingStrategy
 	limits            BlocksStoreLimits
 
+	// The availability zone of the querier. If preferSameZone is enabled, store-gateways in the
+	// same zone are picked over the ones in other zones, when they hold a replica of the block.
+	zone           string
+	preferSameZone bool
+
+	// Blocks fetched from store-gateways by zone ("same" or "cross"), tracked only if the zone is configured.
+	zoneBlockFetches *prometheus.CounterVec
+
+	// Circuit breaker of the store-gateways failing requests. Nil if disabled.
+	breaker *storeGatewayCircuitBreaker
+
+	// Offset of the next instance picked among the candidates by the round-robin strategy. It's
+	// shared by all the blocks, so that each request moves to the next replica.
+	roundRobinOffset atomic.Uint64
+
+	// Number of in-flight requests per store-gateway address, used by the least pending requests
+	// strategy. The addresses of the store-gateways which left the ring are periodically removed.
+	storesDiscovery client.PoolServiceDiscovery
+	balancingMx     sync.Mutex
+	pendingRequests map[string]*atomic.Int64
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 }
 
+// pendingRequestsCleanupInterval is how frequently the in-flight requests counters of the
+// store-gateways which left the ring are removed.
+const pendingRequestsCleanupInterval = time.Minute
+
 func newBlocksStoreReplicationSet(
 	storesRing *ring.Ring,
 	balancingStrategy loadBalancingStrategy,
 	limits BlocksStoreLimits,
 	clientConfig ClientConfig,
+	zone string,
+	preferSameZone bool,
+	breakerCfg storeGatewayCircuitBreakerConfig,
 	logger log.Logger,
 	reg prometheus.Registerer,
 ) (*blocksStoreReplicationSet, error) {
+	storesDiscovery := client.NewRingServiceDiscovery(storesRing)
+
 	s := &blocksStoreReplicationSet{
 		storesRing:        storesRing,
-		clientsPool:       newStoreGatewayClientPool(client.NewRingServiceDiscovery(storesRing), clientConfig, logger, reg),
+		clientsPool:       newStoreGatewayClientPool(storesDiscovery, clientConfig, logger, reg),
 		balancingStrategy: balancingStrategy,
 		limits:            limits,
+		zone:              zone,
+		preferSameZone:    preferSameZone,
+		storesDiscovery:   storesDiscovery,
+		pendingRequests:   map[string]*atomic.Int64{},
+		zoneBlockFetches: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_zone_block_fetches_total",
+			Help: "Number of blocks fetched from store-gateways running in the same availability zone of the querier or in a different one. Tracked only if the querier availability zone is configured.",
+		}, []string{"zone"}),
+	}
+
+	if zone != "" {
+		s.zoneBlockFetches.WithLabelValues("same")
+		s.zoneBlockFetches.WithLabelValues("cross")
+	}
+
+	if breakerCfg.enabled() {
+		s.breaker = newStoreGatewayCircuitBreaker(breakerCfg, logger, reg)
 	}
 
 	var err error
@@ -82,10 +166,15 @@ func (s *blocksStoreReplicationSet) starting(ctx context.Context) error {
 }
 
 func (s *blocksStoreReplicationSet) running(ctx context.Context) error {
+	cleanupTicker := time.NewTicker(pendingRequestsCleanupInterval)
+	defer cleanupTicker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-cleanupTicker.C:
+			s.removeStalePendingRequests()
 		case err := <-s.subservicesWatcher.Chan():
 			return errors.Wrap(err, "blocks store set subservice failed")
 		}
@@ -97,6 +186,43 @@ func (s *blocksStoreReplicationSet) stopping(_ error) error {
 }
 
 func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+	shards, err := s.getShards(userID, blockIDs, exclude, true)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := map[BlocksStoreClient][]ulid.ULID{}
+
+	// Get the client for each store-gateway.
+	for addr, blockIDs := range shards {
+		c, err := s.clientsPool.GetClientFor(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get store-gateway client for %s", addr)
+		}
+
+		storeClient := c.(BlocksStoreClient)
+		if s.breaker != nil {
+			// Wrap the client to record the failed requests in the circuit breaker.
+			storeClient = &circuitBreakerTrackingClient{BlocksStoreClient: storeClient, breaker: s.breaker}
+		}
+		if s.balancingStrategy == leastPendingRequestsLoadBalancing {
+			// Wrap the client to keep track of the number of in-flight requests.
+			storeClient = &pendingRequestsTrackingClient{BlocksStoreClient: storeClient, pending: s.getPendingRequests(addr)}
+		}
+
+		clients[storeClient] = blockIDs
+	}
+
+	return clients, nil
+}
+
+func (s *blocksStoreReplicationSet) GetAddressesFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[string][]ulid.ULID, error) {
+	return s.getShards(userID, blockIDs, exclude, false)
+}
+
+// getShards returns the blocks to query from each store-gateway address. If track is false, the selection
+// doesn't advance the round-robin offset, transition the circuit breakers and update the metrics.
+func (s *blocksStoreReplicationSet) getShards(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string, track bool) (map[string][]ulid.ULID, error) {
 	shards := map[string][]ulid.ULID{}
 
 	userRing := storegateway.GetShuffleShardingSubring(s.storesRing, userID, s.limits)
@@ -113,42 +239,247 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 		}
 
 		// Pick a non excluded store-gateway instance.
-		addr := getNonExcludedInstanceAddr(set, exclude[blockID], s.balancingStrategy)
+		addr := s.getNonExcludedInstanceAddr(set, exclude[blockID], track)
 		if addr == "" {
 			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
 		}
 
 		shards[addr] = append(shards[addr], blockID)
-	}
 
-	clients := map[BlocksStoreClient][]ulid.ULID{}
+		if !track {
+			continue
+		}
 
-	// Get the client for each store-gateway.
-	for addr, blockIDs := range shards {
-		c, err := s.clientsPool.GetClientFor(addr)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get store-gateway client for %s", addr)
+		if s.breaker != nil {
+			s.breaker.onSelected(addr)
 		}
 
-		clients[c.(BlocksStoreClient)] = blockIDs
+		if s.zone != "" {
+			if instanceZone(set, addr) == s.zone {
+				s.zoneBlockFetches.WithLabelValues("same").Inc()
+			} else {
+				s.zoneBlockFetches.WithLabelValues("cross").Inc()
+			}
+		}
 	}
 
-	return clients, nil
+	return shards, nil
 }
 
-func getNonExcludedInstanceAddr(set ring.ReplicationSet, exclude []string, balancingStrategy loadBalancingStrategy) string {
-	if balancingStrategy == randomLoadBalancing {
-		// Randomize the list of instances to not always query the same one.
-		rand.Shuffle(len(set.Instances), func(i, j int) {
-			set.Instances[i], set.Instances[j] = set.Instances[j], set.Instances[i]
-		})
+func (s *blocksStoreReplicationSet) TenantShardInstancesCount(userID string) int {
+	return storegateway.GetShuffleShardingSubring(s.storesRing, userID, s.limits).InstancesCount()
+}
+
+// getNonExcludedInstanceAddr picks a non excluded store-gateway instance from the input set,
+// based on the configured load balancing strategy. If the same zone is preferred, instances
+// in other zones are picked only if there's no non excluded instance in the querier zone.
+// Instances whose circuit breaker is open are picked only if there's no other non excluded
+// instance, so that a block held by failing instances only is still queried.
+// If track is false, the round-robin offset is not advanced. Returns an empty string if all
+// instances have been excluded.
+func (s *blocksStoreReplicationSet) getNonExcludedInstanceAddr(set ring.ReplicationSet, exclude []string, track bool) string {
+	candidates := make([]string, 0, len(set.Instances))
+	sameZoneCandidates := make([]string, 0, len(set.Instances))
+	addCandidate := func(instance ring.InstanceDesc) {
+		candidates = append(candidates, instance.Addr)
+		if instance.Zone == s.zone {
+			sameZoneCandidates = append(sameZoneCandidates, instance.Addr)
+		}
 	}
 
+	var unavailable []ring.InstanceDesc
 	for _, instance := range set.Instances {
-		if !util.StringsContain(exclude, instance.Addr) {
-			return instance.Addr
+		if util.StringsContain(exclude, instance.Addr) {
+			continue
+		}
+
+		if s.breaker != nil && !s.breaker.isAvailable(instance.Addr) {
+			unavailable = append(unavailable, instance)
+			continue
 		}
+
+		addCandidate(instance)
+	}
+
+	if len(candidates) == 0 {
+		for _, instance := range unavailable {
+			addCandidate(instance)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	if s.preferSameZone && len(sameZoneCandidates) > 0 {
+		candidates = sameZoneCandidates
 	}
 
+	switch s.balancingStrategy {
+	case randomLoadBalancing:
+		// Randomize the instance to not always query the same one.
+		return candidates[rand.Intn(len(candidates))]
+
+	case roundRobinLoadBalancing:
+		// Pick the candidates in turn. The offset isn't tracked per instance, so a store-gateway
+		// joining the ring gets its share of the requests, not all of them until it catches up.
+		offset := s.roundRobinOffset.Load()
+		if track {
+			offset = s.roundRobinOffset.Inc() - 1
+		}
+		return candidates[offset%uint64(len(candidates))]
+
+	case leastPendingRequestsLoadBalancing:
+		s.balancingMx.Lock()
+		defer s.balancingMx.Unlock()
+
+		// Pick the instance with the lowest number of in-flight requests. In case of a tie
+		// a random one is picked, to not always query the same one.
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+
+		selected := candidates[0]
+		for _, addr := range candidates[1:] {
+			if s.pendingRequestsLocked(addr) < s.pendingRequestsLocked(selected) {
+				selected = addr
+			}
+		}
+
+		return selected
+
+	default:
+		return candidates[0]
+	}
+}
+
+// instanceZone returns the zone of the instance with the input address in the replication set.
+func instanceZone(set ring.ReplicationSet, addr string) string {
+	for _, instance := range set.Instances {
+		if instance.Addr == addr {
+			return instance.Zone
+		}
+	}
 	return ""
 }
+
+// getPendingRequests returns the counter of in-flight requests for the input store-gateway address.
+func (s *blocksStoreReplicationSet) getPendingRequests(addr string) *atomic.Int64 {
+	s.balancingMx.Lock()
+	defer s.balancingMx.Unlock()
+
+	pending, ok := s.pendingRequests[addr]
+	if !ok {
+		pending = atomic.NewInt64(0)
+		s.pendingRequests[addr] = pending
+	}
+
+	return pending
+}
+
+// removeStalePendingRequests removes the in-flight requests counters of the store-gateways which
+// left the ring, unless they still have in-flight requests.
+func (s *blocksStoreReplicationSet) removeStalePendingRequests() {
+	addrs, err := s.storesDiscovery()
+	if err != nil {
+		return
+	}
+
+	s.balancingMx.Lock()
+	defer s.balancingMx.Unlock()
+
+	for addr, pending := range s.pendingRequests {
+		if pending.Load() == 0 && !util.StringsContain(addrs, addr) {
+			delete(s.pendingRequests, addr)
+		}
+	}
+}
+
+// pendingRequestsLocked returns the number of in-flight requests for the input store-gateway address.
+// This function must be called while holding the balancingMx lock.
+func (s *blocksStoreReplicationSet) pendingRequestsLocked(addr string) int64 {
+	if pending, ok := s.pendingRequests[addr]; ok {
+		return pending.Load()
+	}
+	return 0
+}
+
+// pendingRequestsTrackingClient is a BlocksStoreClient which keeps track of the number
+// of in-flight Series, LabelNames and LabelValues requests.
+type pendingRequestsTrackingClient struct {
+	BlocksStoreClient
+
+	pending *atomic.Int64
+}
+
+func (c *pendingRequestsTrackingClient) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	c.pending.Inc()
+
+	stream, err := c.BlocksStoreClient.Series(ctx, in, opts...)
+	if err != nil {
+		c.pending.Dec()
+		return nil, err
+	}
+
+	return newPendingRequestsTrackingSeriesClient(ctx, stream, c.pending), nil
+}
+
+func (c *pendingRequestsTrackingClient) LabelNames(ctx context.Context, in *storepb.LabelNamesRequest, opts ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	c.pending.Inc()
+	defer c.pending.Dec()
+
+	return c.BlocksStoreClient.LabelNames(ctx, in, opts...)
+}
+
+func (c *pendingRequestsTrackingClient) LabelValues(ctx context.Context, in *storepb.LabelValuesRequest, opts ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+	c.pending.Inc()
+	defer c.pending.Dec()
+
+	return c.BlocksStoreClient.LabelValues(ctx, in, opts...)
+}
+
+func (c *pendingRequestsTrackingClient) String() string {
+	return c.RemoteAddress()
+}
+
+// pendingRequestsTrackingSeriesClient decreases the number of in-flight requests once the
+// stream has been fully consumed, failed or the request context has been canceled.
+type pendingRequestsTrackingSeriesClient struct {
+	storegatewaypb.StoreGateway_SeriesClient
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func newPendingRequestsTrackingSeriesClient(ctx context.Context, stream storegatewaypb.StoreGateway_SeriesClient, pending *atomic.Int64) *pendingRequestsTrackingSeriesClient {
+	c := &pendingRequestsTrackingSeriesClient{
+		StoreGateway_SeriesClient: stream,
+		done:                      make(chan struct{}),
+	}
+
+	// The caller may stop consuming the stream before the end (eg. on error), so
+	// we also release the pending request once the context is done.
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.release()
+		case <-c.done:
+		}
+
+		pending.Dec()
+	}()
+
+	return c
+}
+
+func (c *pendingRequestsTrackingSeriesClient) Recv() (*storepb.SeriesResponse, error) {
+	resp, err := c.StoreGateway_SeriesClient.Recv()
+	if err != nil {
+		c.release()
+	}
+	return resp, err
+}
+
+func (c *pendingRequestsTrackingSeriesClient) release() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
@@ -21,8 +21,11 @@ import (
 	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"go.uber.org/atomic"
 
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 )
@@ -338,7 +341,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			}
 
 			reg := prometheus.NewPedanticRegistry()
-			s, err := newBlocksStoreReplicationSet(r, noLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg)
+			s, err := newBlocksStoreReplicationSet(r, noLoadBalancing, limits, ClientConfig{}, "", false, storeGatewayCircuitBreakerConfig{}, log.NewNopLogger(), reg)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
@@ -371,10 +374,337 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 		numInstances = 3
 	)
 
+	userID := "user-A"
+	block1 := ulid.MustNew(1, nil)
+
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, numInstances, randomLoadBalancing)
+
+	// Request the same block multiple times and ensure the distribution of
+	// requests across store-gateways is balanced.
+	distribution := map[string]int{}
+
+	for n := 0; n < numRuns; n++ {
+		clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+		require.NoError(t, err)
+		require.Len(t, clients, 1)
+
+		for addr := range getStoreGatewayClientAddrs(clients) {
+			distribution[addr]++
+		}
+	}
+
+	assert.Len(t, distribution, numInstances)
+	for addr, count := range distribution {
+		// Ensure that the number of times each client is returned is above
+		// the 80% of the perfect even distribution.
+		assert.Greaterf(t, float64(count), (float64(numRuns)/float64(numInstances))*0.8, "store-gateway address: %s", addr)
+	}
+}
+
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRoundRobinLoadBalancingStrategy(t *testing.T) {
+	const (
+		numRuns      = 30
+		numInstances = 3
+	)
+
+	userID := "user-A"
+	block1 := ulid.MustNew(1, nil)
+
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, numInstances, roundRobinLoadBalancing)
+
+	t.Run("should evenly distribute requests across store-gateways", func(t *testing.T) {
+		distribution := map[string]int{}
+
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+			require.NoError(t, err)
+			require.Len(t, clients, 1)
+
+			for addr := range getStoreGatewayClientAddrs(clients) {
+				distribution[addr]++
+			}
+		}
+
+		assert.Equal(t, map[string]int{
+			"127.0.0.1": numRuns / numInstances,
+			"127.0.0.2": numRuns / numInstances,
+			"127.0.0.3": numRuns / numInstances,
+		}, distribution)
+	})
+
+	t.Run("should never pick an excluded store-gateway", func(t *testing.T) {
+		exclude := map[ulid.ULID][]string{block1: {"127.0.0.1", "127.0.0.2"}}
+
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, exclude)
+			require.NoError(t, err)
+			assert.Equal(t, map[string][]ulid.ULID{"127.0.0.3": {block1}}, getStoreGatewayClientAddrs(clients))
+		}
+	})
+
+	t.Run("should not send all requests to a store-gateway which hasn't been picked for a while", func(t *testing.T) {
+		// The store-gateway is not picked for a while, like when it has just joined the ring.
+		exclude := map[ulid.ULID][]string{block1: {"127.0.0.3"}}
+		for n := 0; n < numRuns; n++ {
+			_, err := s.GetClientsFor(userID, []ulid.ULID{block1}, exclude)
+			require.NoError(t, err)
+		}
+
+		distribution := map[string]int{}
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+			require.NoError(t, err)
+
+			for addr := range getStoreGatewayClientAddrs(clients) {
+				distribution[addr]++
+			}
+		}
+
+		assert.Equal(t, map[string]int{
+			"127.0.0.1": numRuns / numInstances,
+			"127.0.0.2": numRuns / numInstances,
+			"127.0.0.3": numRuns / numInstances,
+		}, distribution)
+	})
+}
+
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportLeastPendingRequestsLoadBalancingStrategy(t *testing.T) {
+	const numInstances = 3
+
+	userID := "user-A"
+	block1 := ulid.MustNew(1, nil)
+
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, numInstances, leastPendingRequestsLoadBalancing)
+
+	// Simulate in-flight requests to the store-gateways.
+	s.getPendingRequests("127.0.0.1").Add(2)
+	s.getPendingRequests("127.0.0.2").Add(1)
+	s.getPendingRequests("127.0.0.3").Add(3)
+
+	clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]ulid.ULID{"127.0.0.2": {block1}}, getStoreGatewayClientAddrs(clients))
+
+	// The store-gateway with the least pending requests should be skipped if excluded.
+	clients, err = s.GetClientsFor(userID, []ulid.ULID{block1}, map[ulid.ULID][]string{block1: {"127.0.0.2"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]ulid.ULID{"127.0.0.1": {block1}}, getStoreGatewayClientAddrs(clients))
+}
+
+func TestBlocksStoreReplicationSet_ShouldRemovePendingRequestsOfStoreGatewaysLeftTheRing(t *testing.T) {
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, 3, leastPendingRequestsLoadBalancing)
+
+	s.getPendingRequests("127.0.0.1")
+	s.getPendingRequests("127.0.0.2").Add(1)
+	s.getPendingRequests("10.0.0.1")
+	s.getPendingRequests("10.0.0.2").Add(1)
+
+	s.removeStalePendingRequests()
+
+	// The store-gateway which left the ring is kept until its in-flight requests complete.
+	s.balancingMx.Lock()
+	defer s.balancingMx.Unlock()
+
+	var addrs []string
+	for addr := range s.pendingRequests {
+		addrs = append(addrs, addr)
+	}
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.2", "10.0.0.2"}, addrs)
+}
+
+func TestBlocksStoreReplicationSet_GetAddressesFor_ShouldNotChangeTheRoutingState(t *testing.T) {
+	const userID = "user-A"
+	block1 := ulid.MustNew(1, nil)
+
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, 3, roundRobinLoadBalancing)
+	s.breaker = newStoreGatewayCircuitBreaker(storeGatewayCircuitBreakerConfig{
+		FailureThreshold: 1,
+		FailureWindow:    time.Minute,
+		OpenTimeout:      0,
+	}, log.NewNopLogger(), nil)
+
+	// The breaker of all the store-gateways but one is open, and would half-open once selected.
+	s.breaker.onFailure("127.0.0.1")
+	s.breaker.onFailure("127.0.0.2")
+
+	// Looking up the addresses should always return the same store-gateway, without advancing
+	// the round-robin offset or half-opening the circuit breakers.
+	exclude := map[ulid.ULID][]string{block1: {"127.0.0.3"}}
+	first, err := s.GetAddressesFor(userID, []ulid.ULID{block1}, exclude)
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+
+	for n := 0; n < 10; n++ {
+		addrs, err := s.GetAddressesFor(userID, []ulid.ULID{block1}, exclude)
+		require.NoError(t, err)
+		assert.Equal(t, first, addrs)
+	}
+
+	assert.Equal(t, uint64(0), s.roundRobinOffset.Load())
+	assert.Equal(t, float64(0), testutil.ToFloat64(s.breaker.transitions.WithLabelValues("half-open")))
+
+	// Getting the clients should be tracked instead.
+	_, err = s.GetClientsFor(userID, []ulid.ULID{block1}, exclude)
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), s.roundRobinOffset.Load())
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.breaker.transitions.WithLabelValues("half-open")))
+}
+
+func TestPendingRequestsTrackingClient(t *testing.T) {
+	pending := atomic.NewInt64(0)
+	c := &pendingRequestsTrackingClient{
+		BlocksStoreClient: &storeGatewayClientMock{
+			remoteAddr:                "1.1.1.1",
+			mockedSeriesResponses:     []*storepb.SeriesResponse{mockSeriesResponse(labels.Labels{{Name: "a", Value: "b"}}, 1, 1)},
+			mockedLabelNamesResponse:  &storepb.LabelNamesResponse{},
+			mockedLabelValuesResponse: &storepb.LabelValuesResponse{},
+		},
+		pending: pending,
+	}
+
+	t.Run("Series() should release the pending request once the stream has been consumed", func(t *testing.T) {
+		stream, err := c.Series(context.Background(), &storepb.SeriesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), pending.Load())
+
+		for {
+			if _, err := stream.Recv(); err != nil {
+				break
+			}
+		}
+
+		test.Poll(t, time.Second, int64(0), func() interface{} {
+			return pending.Load()
+		})
+	})
+
+	t.Run("Series() should release the pending request once the context has been canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		_, err := c.Series(ctx, &storepb.SeriesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), pending.Load())
+
+		cancel()
+
+		test.Poll(t, time.Second, int64(0), func() interface{} {
+			return pending.Load()
+		})
+	})
+
+	t.Run("LabelNames() and LabelValues() should release the pending request once completed", func(t *testing.T) {
+		_, err := c.LabelNames(context.Background(), &storepb.LabelNamesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Load())
+
+		_, err = c.LabelValues(context.Background(), &storepb.LabelValuesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending.Load())
+	})
+}
+
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldPreferStoreGatewaysInTheSameZone(t *testing.T) {
+	const numRuns = 100
+
 	ctx := context.Background()
 	userID := "user-A"
-	registeredAt := time.Now()
 	block1 := ulid.MustNew(1, nil)
+	registeredAt := time.Now()
+
+	tests := map[string]struct {
+		preferSameZone        bool
+		exclude               map[ulid.ULID][]string
+		expectedAddrs         []string
+		expectedSameZoneTotal int
+	}{
+		"should query a store-gateway in the same zone": {
+			preferSameZone:        true,
+			expectedAddrs:         []string{"127.0.0.2"},
+			expectedSameZoneTotal: numRuns,
+		},
+		"should fallback to store-gateways in other zones if the one in the same zone is excluded": {
+			preferSameZone: true,
+			exclude: map[ulid.ULID][]string{
+				block1: {"127.0.0.2"},
+			},
+			expectedAddrs:         []string{"127.0.0.1", "127.0.0.3"},
+			expectedSameZoneTotal: 0,
+		},
+		"should query store-gateways in any zone if the same zone is not preferred": {
+			preferSameZone: false,
+			expectedAddrs:  []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+			t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+			require.NoError(t, ringStore.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+				d := ring.NewDesc()
+				for n := 1; n <= 3; n++ {
+					d.AddIngester(fmt.Sprintf("instance-%d", n), fmt.Sprintf("127.0.0.%d", n), fmt.Sprintf("zone-%d", n), []uint32{uint32(n)}, ring.ACTIVE, registeredAt)
+				}
+				return d, true, nil
+			}))
+
+			ringCfg := ring.Config{}
+			flagext.DefaultValues(&ringCfg)
+			ringCfg.ReplicationFactor = 3
+
+			r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "test", "test", ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+			require.NoError(t, err)
+
+			limits := &blocksStoreLimitsMock{}
+			reg := prometheus.NewPedanticRegistry()
+			s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, limits, ClientConfig{}, "zone-2", testData.preferSameZone, storeGatewayCircuitBreakerConfig{}, log.NewNopLogger(), reg)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+			t.Cleanup(func() {
+				assert.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+			})
+
+			// Wait until the ring client has initialised the state.
+			test.Poll(t, time.Second, true, func() interface{} {
+				all, err := r.GetAllHealthy(ring.Read)
+				return err == nil && len(all.Instances) > 0
+			})
+
+			queried := map[string]struct{}{}
+			for n := 0; n < numRuns; n++ {
+				clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, testData.exclude)
+				require.NoError(t, err)
+				require.Len(t, clients, 1)
+
+				for addr := range getStoreGatewayClientAddrs(clients) {
+					queried[addr] = struct{}{}
+				}
+			}
+
+			var actualAddrs []string
+			for addr := range queried {
+				actualAddrs = append(actualAddrs, addr)
+			}
+			assert.ElementsMatch(t, testData.expectedAddrs, actualAddrs)
+
+			sameZone := testutil.ToFloat64(s.zoneBlockFetches.WithLabelValues("same"))
+			crossZone := testutil.ToFloat64(s.zoneBlockFetches.WithLabelValues("cross"))
+			assert.Equal(t, float64(numRuns), sameZone+crossZone)
+			if testData.preferSameZone {
+				assert.Equal(t, float64(testData.expectedSameZoneTotal), sameZone)
+			}
+		})
+	}
+}
+
+// prepareBlocksStoreReplicationSetWithFullReplication creates a running blocksStoreReplicationSet backed
+// by a ring with the input number of instances, configured with a replication factor equal to the number
+// of instances, so that every store-gateway gets all blocks.
+func prepareBlocksStoreReplicationSetWithFullReplication(t *testing.T, numInstances int, balancingStrategy loadBalancingStrategy) *blocksStoreReplicationSet {
+	ctx := context.Background()
+	registeredAt := time.Now()
 
 	// Create a ring.
 	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
@@ -388,7 +718,6 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 		return d, true, nil
 	}))
 
-	// Configure a replication factor equal to the number of instances, so that every store-gateway gets all blocks.
 	ringCfg := ring.Config{}
 	flagext.DefaultValues(&ringCfg)
 	ringCfg.ReplicationFactor = numInstances
@@ -398,10 +727,12 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 
 	limits := &blocksStoreLimitsMock{storeGatewayTenantShardSize: 0}
 	reg := prometheus.NewPedanticRegistry()
-	s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), reg)
+	s, err := newBlocksStoreReplicationSet(r, balancingStrategy, limits, ClientConfig{}, "", false, storeGatewayCircuitBreakerConfig{}, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
-	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+	t.Cleanup(func() {
+		assert.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+	})
 
 	// Wait until the ring client has initialised the state.
 	test.Poll(t, time.Second, true, func() interface{} {
@@ -409,26 +740,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 		return err == nil && len(all.Instances) > 0
 	})
 
-	// Request the same block multiple times and ensure the distribution of
-	// requests across store-gateways is balanced.
-	distribution := map[string]int{}
-
-	for n := 0; n < numRuns; n++ {
-		clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
-		require.NoError(t, err)
-		require.Len(t, clients, 1)
-
-		for addr := range getStoreGatewayClientAddrs(clients) {
-			distribution[addr]++
-		}
-	}
-
-	assert.Len(t, distribution, numInstances)
-	for addr, count := range distribution {
-		// Ensure that the number of times each client is returned is above
-		// the 80% of the perfect even distribution.
-		assert.Greaterf(t, float64(count), (float64(numRuns)/float64(numInstances))*0.8, "store-gateway address: %s", addr)
-	}
+	return s
 }
 
 func getStoreGatewayClientAddrs(clients map[BlocksStoreClient][]ulid.ULID) map[string][]ulid.ULID {
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// BlocksStoreSet implementation used when the store-gateways are addressed directly, bypassing
+// the ring. Every store-gateway is expected to hold all blocks, so each block can be queried from
+// any of the configured addresses.
+type blocksStoreStaticSet struct {
+	services.Service
+
+	addresses   []string
+	clientsPool *client.Pool
+}
+
+func newBlocksStoreStaticSet(addresses []string, clientConfig ClientConfig, logger log.Logger, reg prometheus.Registerer) (*blocksStoreStaticSet, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("no store-gateway address configured")
+	}
+
+	s := &blocksStoreStaticSet{
+		addresses: addresses,
+	}
+	s.clientsPool = newStoreGatewayClientPool(func() ([]string, error) { return s.addresses, nil }, clientConfig, logger, reg)
+	s.Service = s.clientsPool
+
+	return s, nil
+}
+
+func (s *blocksStoreStaticSet) GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+	shards, err := s.GetAddressesFor(userID, blockIDs, exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := map[BlocksStoreClient][]ulid.ULID{}
+
+	// Get the client for each store-gateway.
+	for addr, blockIDs := range shards {
+		c, err := s.clientsPool.GetClientFor(addr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get store-gateway client for %s", addr)
+		}
+
+		clients[c.(BlocksStoreClient)] = blockIDs
+	}
+
+	return clients, nil
+}
+
+func (s *blocksStoreStaticSet) GetAddressesFor(_ string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[string][]ulid.ULID, error) {
+	shards := map[string][]ulid.ULID{}
+
+	for _, blockID := range blockIDs {
+		addr := s.getNonExcludedAddr(blockID, exclude[blockID])
+		if addr == "" {
+			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
+		}
+
+		shards[addr] = append(shards[addr], blockID)
+	}
+
+	return shards, nil
+}
+
+func (s *blocksStoreStaticSet) TenantShardInstancesCount(_ string) int {
+	return len(s.addresses)
+}
+
+// getNonExcludedAddr picks the address to query the input block from. The blocks are spread across
+// the addresses based on their hash, and the next address is picked if the preferred one has been
+// excluded, so that the same block is always queried from the same store-gateway on the first attempt.
+// Returns an empty string if all addresses have been excluded.
+func (s *blocksStoreStaticSet) getNonExcludedAddr(blockID ulid.ULID, exclude []string) string {
+	first := int(mimir_tsdb.HashBlockID(blockID) % uint32(len(s.addresses)))
+
+	for i := 0; i < len(s.addresses); i++ {
+		addr := s.addresses[(first+i)%len(s.addresses)]
+		if !util.StringsContain(exclude, addr) {
+			return addr
+		}
+	}
+
+	return ""
+}
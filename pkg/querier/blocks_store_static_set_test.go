@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocksStoreStaticSet_GetClientsFor(t *testing.T) {
+	// The following block IDs have been picked to have the hash values:
+	// - block1: 283204220 (mod 2 = 0, mod 3 = 2)
+	// - block2: 444110359 (mod 2 = 1, mod 3 = 1)
+	// - block3: 2931974232 (mod 2 = 0, mod 3 = 0)
+	// - block4: 3092880371 (mod 2 = 1, mod 3 = 2)
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(5, nil)
+	block4 := ulid.MustNew(6, nil)
+
+	tests := map[string]struct {
+		addresses       []string
+		queryBlocks     []ulid.ULID
+		exclude         map[ulid.ULID][]string
+		expectedClients map[string][]ulid.ULID
+		expectedErr     error
+	}{
+		"single address": {
+			addresses:   []string{"127.0.0.1"},
+			queryBlocks: []ulid.ULID{block1, block2},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block1, block2},
+			},
+		},
+		"single address but excluded": {
+			addresses:   []string{"127.0.0.1"},
+			queryBlocks: []ulid.ULID{block1, block2},
+			exclude: map[ulid.ULID][]string{
+				block1: {"127.0.0.1"},
+			},
+			expectedErr: fmt.Errorf("no store-gateway instance left after checking exclude for block %s", block1.String()),
+		},
+		"multiple addresses": {
+			addresses:   []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+			queryBlocks: []ulid.ULID{block1, block2, block3, block4},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block3},
+				"127.0.0.2": {block2},
+				"127.0.0.3": {block1, block4},
+			},
+		},
+		"multiple addresses and some excluded": {
+			addresses:   []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"},
+			queryBlocks: []ulid.ULID{block1, block2, block3, block4},
+			exclude: map[ulid.ULID][]string{
+				block1: {"127.0.0.3"},
+				block2: {"127.0.0.2", "127.0.0.3"},
+			},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block1, block2, block3},
+				"127.0.0.3": {block4},
+			},
+		},
+		"multiple addresses and all excluded for a block": {
+			addresses:   []string{"127.0.0.1", "127.0.0.2"},
+			queryBlocks: []ulid.ULID{block1, block2},
+			exclude: map[ulid.ULID][]string{
+				block2: {"127.0.0.1", "127.0.0.2"},
+			},
+			expectedErr: fmt.Errorf("no store-gateway instance left after checking exclude for block %s", block2.String()),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := context.Background()
+
+			s, err := newBlocksStoreStaticSet(testData.addresses, ClientConfig{}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+
+			assert.Equal(t, len(testData.addresses), s.TenantShardInstancesCount("user-1"))
+
+			clients, err := s.GetClientsFor("user-1", testData.queryBlocks, testData.exclude)
+			assert.Equal(t, testData.expectedErr, err)
+
+			if testData.expectedErr == nil {
+				assert.Equal(t, testData.expectedClients, getStoreGatewayClientAddrs(clients))
+			}
+		})
+	}
+}
+
+func TestBlocksStoreStaticSet_GetClientsFor_ShouldFetchAgainTheBlocksFromTheNextAddress(t *testing.T) {
+	ctx := context.Background()
+	block := ulid.MustNew(1, nil)
+
+	s, err := newBlocksStoreStaticSet([]string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, ClientConfig{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+
+	// Simulate the refetch of the block after each attempt failed, excluding the store-gateways
+	// already queried like the querier does, until no store-gateway is left.
+	exclude := map[ulid.ULID][]string{}
+	var queried []string
+
+	for attempt := 0; attempt < 3; attempt++ {
+		clients, err := s.GetClientsFor("user-1", []ulid.ULID{block}, exclude)
+		require.NoError(t, err)
+		require.Len(t, clients, 1)
+
+		for addr := range getStoreGatewayClientAddrs(clients) {
+			queried = append(queried, addr)
+			exclude[block] = append(exclude[block], addr)
+		}
+	}
+
+	// Each store-gateway has been queried once, starting from the one the block hash maps to.
+	assert.Equal(t, []string{"127.0.0.3", "127.0.0.1", "127.0.0.2"}, queried)
+
+	_, err = s.GetClientsFor("user-1", []ulid.ULID{block}, exclude)
+	assert.Equal(t, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", block.String()), err)
+}
+
+func TestNewBlocksStoreStaticSet_ShouldFailWithoutAddresses(t *testing.T) {
+	_, err := newBlocksStoreStaticSet(nil, ClientConfig{}, log.NewNopLogger(), nil)
+	assert.Error(t, err)
+}
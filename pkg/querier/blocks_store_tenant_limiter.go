@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/util/globalerror"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// tenantQueriesLimiter limits the number of queries of each tenant concurrently fetching data from
+// the store-gateways, so that a single tenant can't saturate them. The tenant's queries waiting for
+// a slot get it in priority order.
+type tenantQueriesLimiter struct {
+	limits  BlocksStoreLimits
+	maxWait time.Duration
+
+	inFlight *prometheus.GaugeVec
+	rejected *prometheus.CounterVec
+
+	mtx     sync.Mutex
+	running map[string]int
+	// The number of queries of each tenant waiting for a slot, by priority.
+	waiting map[string]map[QueryPriority]int
+	// Closed, and replaced, whenever a query completes or stops waiting, to wake up the waiting ones.
+	released chan struct{}
+}
+
+func newTenantQueriesLimiter(limits BlocksStoreLimits, maxWait time.Duration, reg prometheus.Registerer) *tenantQueriesLimiter {
+	return &tenantQueriesLimiter{
+		limits:   limits,
+		maxWait:  maxWait,
+		running:  map[string]int{},
+		waiting:  map[string]map[QueryPriority]int{},
+		released: make(chan struct{}),
+		inFlight: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_querier_blocks_store_tenant_queries_in_flight",
+			Help: "Number of queries of the tenant currently fetching data from the store-gateways.",
+		}, []string{"user"}),
+		rejected: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_store_tenant_queries_rejected_total",
+			Help: "Number of queries rejected because the tenant had too many queries fetching data from the store-gateways.",
+		}, []string{"user"}),
+	}
+}
+
+// Acquire waits until the number of queries of the tenant running is below the tenant's limit, and no
+// query of the tenant with a higher priority, read from the context, is waiting. It fails if the query
+// can't start after the max wait time, or the context is done. The limit is read at each attempt, so
+// that changes to it are applied without restarting.
+func (l *tenantQueriesLimiter) Acquire(ctx context.Context, userID string) error {
+	var (
+		priority, _ = queryPriorityFromContext(ctx)
+		timeout     <-chan time.Time
+		waiting     bool
+	)
+
+	for {
+		l.mtx.Lock()
+		limit := l.limits.MaxConcurrentQueriesPerTenant(userID)
+		if limit <= 0 || (l.running[userID] < limit && !l.isHigherPriorityWaiting(userID, priority)) {
+			if waiting {
+				l.removeWaiting(userID, priority)
+			}
+			l.running[userID]++
+			l.inFlight.WithLabelValues(userID).Inc()
+			l.mtx.Unlock()
+			return nil
+		}
+		if !waiting {
+			l.addWaiting(userID, priority)
+			waiting = true
+		}
+		released := l.released
+		l.mtx.Unlock()
+
+		if timeout == nil {
+			timer := time.NewTimer(l.maxWait)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			l.stopWaiting(userID, priority)
+			return ctx.Err()
+		case <-timeout:
+			l.stopWaiting(userID, priority)
+			l.rejected.WithLabelValues(userID).Inc()
+			return newTooManyConcurrentQueriesError(limit)
+		case <-released:
+		}
+	}
+}
+
+// stopWaiting removes a query which gave up waiting, and wakes up the waiting queries with a lower
+// priority, which may now start.
+func (l *tenantQueriesLimiter) stopWaiting(userID string, priority QueryPriority) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.removeWaiting(userID, priority)
+	l.notifyWaiting()
+}
+
+// isHigherPriorityWaiting returns whether a query of the tenant with a priority higher than the input
+// one is waiting. Must be called with the lock held.
+func (l *tenantQueriesLimiter) isHigherPriorityWaiting(userID string, priority QueryPriority) bool {
+	for p := range l.waiting[userID] {
+		if p > priority {
+			return true
+		}
+	}
+	return false
+}
+
+// addWaiting must be called with the lock held.
+func (l *tenantQueriesLimiter) addWaiting(userID string, priority QueryPriority) {
+	if l.waiting[userID] == nil {
+		l.waiting[userID] = map[QueryPriority]int{}
+	}
+	l.waiting[userID][priority]++
+}
+
+// removeWaiting must be called with the lock held.
+func (l *tenantQueriesLimiter) removeWaiting(userID string, priority QueryPriority) {
+	if l.waiting[userID][priority]--; l.waiting[userID][priority] <= 0 {
+		delete(l.waiting[userID], priority)
+	}
+	if len(l.waiting[userID]) == 0 {
+		delete(l.waiting, userID)
+	}
+}
+
+// notifyWaiting wakes up the waiting queries. Must be called with the lock held.
+func (l *tenantQueriesLimiter) notifyWaiting() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// Release marks a query started by a successful Acquire as completed.
+func (l *tenantQueriesLimiter) Release(userID string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// The tenant's series are removed once it has no query running, so that they don't accumulate.
+	if l.running[userID]--; l.running[userID] <= 0 {
+		delete(l.running, userID)
+		l.inFlight.DeleteLabelValues(userID)
+	} else {
+		l.inFlight.WithLabelValues(userID).Dec()
+	}
+
+	l.notifyWaiting()
+}
+
+// newTooManyConcurrentQueriesError returns the error of a query rejected because the tenant has too many
+// queries running. The error is not a limit error, so that the query is retried by the query-frontend.
+func newTooManyConcurrentQueriesError(limit int) error {
+	return errors.New(globalerror.MaxConcurrentQueriesPerTenant.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the query has been rejected because the tenant has too many queries concurrently fetching data from the store-gateways (limit: %d), please retry later", limit),
+		validation.MaxConcurrentQueriesPerTenantFlag))
+}
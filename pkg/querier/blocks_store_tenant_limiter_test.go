@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util/limiter"
+)
+
+func TestTenantQueriesLimiter(t *testing.T) {
+	t.Run("should reject the query exceeding the limit once the max wait time has elapsed", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{maxConcurrentQueries: 2}, 50*time.Millisecond, reg)
+		ctx := context.Background()
+
+		require.NoError(t, l.Acquire(ctx, "user-1"))
+		require.NoError(t, l.Acquire(ctx, "user-1"))
+
+		// The limit applies to each tenant separately.
+		require.NoError(t, l.Acquire(ctx, "user-2"))
+
+		err := l.Acquire(ctx, "user-1")
+		require.Error(t, err)
+		assert.Equal(t, newTooManyConcurrentQueriesError(2), err)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_querier_blocks_store_tenant_queries_in_flight Number of queries of the tenant currently fetching data from the store-gateways.
+			# TYPE cortex_querier_blocks_store_tenant_queries_in_flight gauge
+			cortex_querier_blocks_store_tenant_queries_in_flight{user="user-1"} 2
+			cortex_querier_blocks_store_tenant_queries_in_flight{user="user-2"} 1
+
+			# HELP cortex_querier_blocks_store_tenant_queries_rejected_total Number of queries rejected because the tenant had too many queries fetching data from the store-gateways.
+			# TYPE cortex_querier_blocks_store_tenant_queries_rejected_total counter
+			cortex_querier_blocks_store_tenant_queries_rejected_total{user="user-1"} 1
+		`)))
+
+		// The tenant's series is removed once it has no query running.
+		l.Release("user-1")
+		l.Release("user-1")
+		l.Release("user-2")
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_querier_blocks_store_tenant_queries_rejected_total Number of queries rejected because the tenant had too many queries fetching data from the store-gateways.
+			# TYPE cortex_querier_blocks_store_tenant_queries_rejected_total counter
+			cortex_querier_blocks_store_tenant_queries_rejected_total{user="user-1"} 1
+		`), "cortex_querier_blocks_store_tenant_queries_in_flight", "cortex_querier_blocks_store_tenant_queries_rejected_total"))
+	})
+
+	t.Run("should queue the query exceeding the limit until a running query completes", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{maxConcurrentQueries: 2}, time.Minute, reg)
+		ctx := context.Background()
+
+		require.NoError(t, l.Acquire(ctx, "user-1"))
+		require.NoError(t, l.Acquire(ctx, "user-1"))
+
+		acquired := make(chan error, 1)
+		go func() {
+			acquired <- l.Acquire(ctx, "user-1")
+		}()
+
+		select {
+		case <-acquired:
+			require.FailNow(t, "the query exceeding the limit has not been queued")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		l.Release("user-1")
+
+		select {
+		case err := <-acquired:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the queued query has not been started after a running query completed")
+		}
+
+		assert.Equal(t, float64(2), testutil.ToFloat64(l.inFlight.WithLabelValues("user-1")))
+		assert.Equal(t, 0, testutil.CollectAndCount(l.rejected))
+	})
+
+	t.Run("should start the queued queries in priority order", func(t *testing.T) {
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{maxConcurrentQueries: 1}, time.Minute, nil)
+		require.NoError(t, l.Acquire(context.Background(), "user-1"))
+
+		acquire := func(priority QueryPriority) <-chan error {
+			acquired := make(chan error, 1)
+			go func() {
+				acquired <- l.Acquire(WithQueryPriority(context.Background(), priority), "user-1")
+			}()
+			return acquired
+		}
+
+		// Queue a low priority query, and then a high priority one.
+		lowAcquired := acquire(1)
+		test.Poll(t, time.Second, 1, func() interface{} { return l.waitingCount("user-1") })
+		highAcquired := acquire(2)
+		test.Poll(t, time.Second, 2, func() interface{} { return l.waitingCount("user-1") })
+
+		// The high priority query should start first, even if queued later.
+		l.Release("user-1")
+
+		select {
+		case err := <-highAcquired:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the high priority query has not been started after a running query completed")
+		}
+
+		select {
+		case <-lowAcquired:
+			require.FailNow(t, "the low priority query has been started before the high priority one completed")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		l.Release("user-1")
+
+		select {
+		case err := <-lowAcquired:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the low priority query has not been started after the high priority one completed")
+		}
+
+		assert.Equal(t, 0, l.waitingCount("user-1"))
+	})
+
+	t.Run("should not hold back the lower priority queries once a higher priority one stops waiting", func(t *testing.T) {
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{maxConcurrentQueries: 1}, time.Minute, nil)
+		require.NoError(t, l.Acquire(context.Background(), "user-1"))
+
+		highCtx, cancelHigh := context.WithCancel(WithQueryPriority(context.Background(), 2))
+		highAcquired := make(chan error, 1)
+		go func() {
+			highAcquired <- l.Acquire(highCtx, "user-1")
+		}()
+		test.Poll(t, time.Second, 1, func() interface{} { return l.waitingCount("user-1") })
+
+		cancelHigh()
+		require.ErrorIs(t, <-highAcquired, context.Canceled)
+		assert.Equal(t, 0, l.waitingCount("user-1"))
+
+		// The canceled high priority query should not prevent a lower priority one from starting.
+		l.Release("user-1")
+		require.NoError(t, l.Acquire(WithQueryPriority(context.Background(), 1), "user-1"))
+	})
+
+	t.Run("should stop waiting if the context is canceled", func(t *testing.T) {
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{maxConcurrentQueries: 1}, time.Minute, nil)
+		require.NoError(t, l.Acquire(context.Background(), "user-1"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		require.ErrorIs(t, l.Acquire(ctx, "user-1"), context.Canceled)
+		assert.Equal(t, 0, testutil.CollectAndCount(l.rejected))
+	})
+
+	t.Run("should not limit the queries if the limit is disabled", func(t *testing.T) {
+		l := newTenantQueriesLimiter(&blocksStoreLimitsMock{}, 0, nil)
+
+		for i := 0; i < 100; i++ {
+			require.NoError(t, l.Acquire(context.Background(), "user-1"))
+		}
+		assert.Equal(t, float64(100), testutil.ToFloat64(l.inFlight.WithLabelValues("user-1")))
+	})
+}
+
+func TestBlocksStoreQuerier_ShouldRejectQueriesExceedingTheTenantConcurrentQueriesLimit(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1 = ulid.MustNew(1, nil)
+		series = labels.FromStrings(labels.MetricName, metricName)
+		ctx    = limiter.AddQueryLimiterToContext(context.Background(), limiter.NewQueryLimiter(0, 0, 0))
+		limits = &blocksStoreLimitsMock{maxConcurrentQueries: 1}
+	)
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(series, minT, 1),
+				mockHintsResponse(block1),
+			}}: {block1},
+		},
+	}}
+
+	tenantQueries := newTenantQueriesLimiter(limits, 10*time.Millisecond, nil)
+	q := &blocksStoreQuerier{
+		ctx:           ctx,
+		minT:          minT,
+		maxT:          maxT,
+		userID:        "user-1",
+		finder:        finder,
+		stores:        stores,
+		consistency:   NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:        log.NewNopLogger(),
+		metrics:       newBlocksStoreQueryableMetrics(nil),
+		limits:        limits,
+		tenantQueries: tenantQueries,
+	}
+
+	// Simulate another query of the tenant running.
+	require.NoError(t, tenantQueries.Acquire(ctx, "user-1"))
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.False(t, set.Next())
+	assert.Equal(t, newTooManyConcurrentQueriesError(1), set.Err())
+
+	// The rejected query is retried once the other query has completed.
+	tenantQueries.Release("user-1")
+
+	set = q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.True(t, set.Next())
+	assert.Equal(t, series, set.At().Labels())
+	for set.Next() {
+	}
+	require.NoError(t, set.Err())
+
+	// The query has released its slot once done.
+	assert.Equal(t, 0, testutil.CollectAndCount(tenantQueries.inFlight))
+}
+
+// waitingCount returns the number of queries of the tenant waiting for a slot.
+func (l *tenantQueriesLimiter) waitingCount(userID string) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	count := 0
+	for _, n := range l.waiting[userID] {
+		count += n
+	}
+	return count
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"github.com/prometheus/prometheus/storage"
+)
+
+// concatSeriesSet returns the series of the input sets one set after the other. Unlike
+// storage.NewMergeSeriesSet(), the series aren't sorted, and the series with the same
+// labels in different sets aren't merged, so the input sets must contain different series.
+type concatSeriesSet struct {
+	sets []storage.SeriesSet
+	curr int
+}
+
+func newConcatSeriesSet(sets []storage.SeriesSet) storage.SeriesSet {
+	if len(sets) == 0 {
+		return storage.EmptySeriesSet()
+	}
+	if len(sets) == 1 {
+		return sets[0]
+	}
+	return &concatSeriesSet{sets: sets}
+}
+
+func (s *concatSeriesSet) Next() bool {
+	for ; s.curr < len(s.sets); s.curr++ {
+		if s.sets[s.curr].Next() {
+			return true
+		}
+		if s.sets[s.curr].Err() != nil {
+			return false
+		}
+	}
+	return false
+}
+
+func (s *concatSeriesSet) At() storage.Series {
+	if s.curr >= len(s.sets) {
+		return nil
+	}
+	return s.sets[s.curr].At()
+}
+
+func (s *concatSeriesSet) Err() error {
+	for _, set := range s.sets {
+		if err := set.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *concatSeriesSet) Warnings() storage.Warnings {
+	var warnings storage.Warnings
+	for _, set := range s.sets {
+		warnings = append(warnings, set.Warnings()...)
+	}
+	return warnings
+}
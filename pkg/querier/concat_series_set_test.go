@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/store/labelpb"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+
+	"github.com/grafana/mimir/pkg/storage/series"
+)
+
+func TestConcatSeriesSet(t *testing.T) {
+	var (
+		series1 = labels.FromStrings(labels.MetricName, "test", "series", "1")
+		series2 = labels.FromStrings(labels.MetricName, "test", "series", "2")
+		series3 = labels.FromStrings(labels.MetricName, "test", "series", "3")
+	)
+
+	newSet := func(lbls ...labels.Labels) storage.SeriesSet {
+		s := make([]storage.Series, 0, len(lbls))
+		for _, l := range lbls {
+			s = append(s, series.NewConcreteSeries(l, []model.SamplePair{{Timestamp: 1, Value: 1}}))
+		}
+		return series.NewConcreteSeriesSet(s)
+	}
+
+	readAll := func(t *testing.T, set storage.SeriesSet) []labels.Labels {
+		var out []labels.Labels
+		for set.Next() {
+			out = append(out, set.At().Labels())
+		}
+		require.NoError(t, set.Err())
+		return out
+	}
+
+	t.Run("should return the series of each set, one set after the other", func(t *testing.T) {
+		set := newConcatSeriesSet([]storage.SeriesSet{newSet(series3), storage.EmptySeriesSet(), newSet(series1, series2)})
+		assert.Equal(t, []labels.Labels{series3, series1, series2}, readAll(t, set))
+	})
+
+	t.Run("should return the input set if there's only one", func(t *testing.T) {
+		input := newSet(series1, series2)
+		assert.Same(t, input, newConcatSeriesSet([]storage.SeriesSet{input}))
+	})
+
+	t.Run("should return an empty set if there are no sets", func(t *testing.T) {
+		assert.Empty(t, readAll(t, newConcatSeriesSet(nil)))
+	})
+
+	t.Run("should stop at the first failed set", func(t *testing.T) {
+		err := errors.New("failed")
+		set := newConcatSeriesSet([]storage.SeriesSet{newSet(series1), storage.ErrSeriesSet(err), newSet(series2)})
+
+		require.True(t, set.Next())
+		assert.Equal(t, series1, set.At().Labels())
+		assert.False(t, set.Next())
+		assert.Equal(t, err, set.Err())
+	})
+
+	t.Run("should return the warnings of all the input sets", func(t *testing.T) {
+		set := newConcatSeriesSet([]storage.SeriesSet{
+			series.NewSeriesSetWithWarnings(newSet(series1), storage.Warnings{errors.New("warning 1")}),
+			series.NewSeriesSetWithWarnings(newSet(series2), storage.Warnings{errors.New("warning 2")}),
+		})
+
+		assert.Len(t, readAll(t, set), 2)
+		assert.Equal(t, storage.Warnings{errors.New("warning 1"), errors.New("warning 2")}, set.Warnings())
+	})
+}
+
+func BenchmarkBlocksSeriesSets_SortedVsUnsorted(b *testing.B) {
+	const numSeriesPerSet = 10000
+
+	chunk := createAggrChunkWithSamples(promql.Point{T: 1, V: 1})
+
+	for _, numSets := range []int{2, 3, 10} {
+		// Each store-gateway returns different series, like when each of them has been queried
+		// for the blocks of different compactor shards.
+		sets := make([][]*storepb.Series, numSets)
+		for i := range sets {
+			for j := 0; j < numSeriesPerSet; j++ {
+				sets[i] = append(sets[i], &storepb.Series{
+					Labels: labelpb.ZLabelsFromPromLabels(labels.FromStrings(labels.MetricName, "test", "series", fmt.Sprintf("%06d", j), "shard", fmt.Sprintf("%d", i))),
+					Chunks: []storepb.AggrChunk{chunk},
+				})
+			}
+		}
+
+		newSets := func() []storage.SeriesSet {
+			out := make([]storage.SeriesSet, 0, len(sets))
+			for _, s := range sets {
+				out = append(out, &blockQuerierSeriesSet{series: s})
+			}
+			return out
+		}
+
+		for name, newSeriesSet := range map[string]func() storage.SeriesSet{
+			"sorted":   func() storage.SeriesSet { return storage.NewMergeSeriesSet(newSets(), dedupChunksSeriesMerge) },
+			"unsorted": func() storage.SeriesSet { return newConcatSeriesSet(newSets()) },
+		} {
+			b.Run(fmt.Sprintf("sets=%d,%s", numSets, name), func(b *testing.B) {
+				b.ReportAllocs()
+
+				for n := 0; n < b.N; n++ {
+					set := newSeriesSet()
+					for set.Next() {
+						set.At()
+					}
+					if err := set.Err(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
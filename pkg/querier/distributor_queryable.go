@@ -102,7 +102,7 @@ func (q *distributorQuerier) Select(_ bool, sp *storage.SelectHints, matchers ..
 		return storage.EmptySeriesSet()
 	}
 
-	if sp != nil && sp.Func == "series" {
+	if isSeriesOnlySelect(sp) {
 		ms, err := q.distributor.MetricsForLabelMatchers(ctx, model.Time(minT), model.Time(maxT), matchers...)
 		if err != nil {
 			return storage.ErrSeriesSet(err)
@@ -0,0 +1,196 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+)
+
+// metricsFacade creates the querier metrics, registering them to the Prometheus registerer and,
+// if an OpenTelemetry meter is configured, also creating the equivalent OpenTelemetry instruments.
+// The returned metrics implement the Prometheus interfaces, and fan out each update to both backends,
+// so the code updating them doesn't have to care about the backends in use.
+type metricsFacade struct {
+	reg   prometheus.Registerer
+	meter metric.Meter // Nil if the metrics are exported to Prometheus only.
+}
+
+func newMetricsFacade(reg prometheus.Registerer, meter metric.Meter) *metricsFacade {
+	return &metricsFacade{reg: reg, meter: meter}
+}
+
+func (f *metricsFacade) NewCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	return &facadeCounter{
+		Counter: promauto.With(f.reg).NewCounter(opts),
+		otel:    f.otelCounter(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help),
+		attrs:   constLabelsAttributes(opts.ConstLabels),
+	}
+}
+
+func (f *metricsFacade) NewCounterVec(opts prometheus.CounterOpts, labelNames []string) *facadeCounterVec {
+	return &facadeCounterVec{
+		CounterVec: promauto.With(f.reg).NewCounterVec(opts, labelNames),
+		otel:       f.otelCounter(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help),
+		attrs:      constLabelsAttributes(opts.ConstLabels),
+		labelNames: labelNames,
+	}
+}
+
+func (f *metricsFacade) NewHistogram(opts prometheus.HistogramOpts) prometheus.Histogram {
+	return &facadeHistogram{
+		Histogram: promauto.With(f.reg).NewHistogram(opts),
+		otel:      f.otelHistogram(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help),
+		attrs:     constLabelsAttributes(opts.ConstLabels),
+	}
+}
+
+func (f *metricsFacade) NewHistogramVec(opts prometheus.HistogramOpts, labelNames []string) *facadeHistogramVec {
+	return &facadeHistogramVec{
+		HistogramVec: promauto.With(f.reg).NewHistogramVec(opts, labelNames),
+		otel:         f.otelHistogram(prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help),
+		attrs:        constLabelsAttributes(opts.ConstLabels),
+		labelNames:   labelNames,
+	}
+}
+
+// otelCounter returns the OpenTelemetry counter with the input name, or nil if no meter is configured
+// or the counter can't be created. Failing to create the counter doesn't prevent the metric from being
+// exported to Prometheus, so the error is passed to the OpenTelemetry error handler.
+func (f *metricsFacade) otelCounter(name, help string) syncfloat64.Counter {
+	if f.meter == nil {
+		return nil
+	}
+
+	c, err := f.meter.SyncFloat64().Counter(name, instrument.WithDescription(help))
+	if err != nil {
+		otel.Handle(errors.Wrapf(err, "create OpenTelemetry counter %s", name))
+		return nil
+	}
+	return c
+}
+
+// otelHistogram is like otelCounter, but for histograms.
+func (f *metricsFacade) otelHistogram(name, help string) syncfloat64.Histogram {
+	if f.meter == nil {
+		return nil
+	}
+
+	h, err := f.meter.SyncFloat64().Histogram(name, instrument.WithDescription(help))
+	if err != nil {
+		otel.Handle(errors.Wrapf(err, "create OpenTelemetry histogram %s", name))
+		return nil
+	}
+	return h
+}
+
+type facadeCounter struct {
+	prometheus.Counter
+
+	otel  syncfloat64.Counter
+	attrs []attribute.KeyValue
+}
+
+func (c *facadeCounter) Inc() {
+	c.Add(1)
+}
+
+func (c *facadeCounter) Add(v float64) {
+	c.Counter.Add(v)
+
+	if c.otel != nil {
+		c.otel.Add(context.Background(), v, c.attrs...)
+	}
+}
+
+type facadeCounterVec struct {
+	*prometheus.CounterVec
+
+	otel       syncfloat64.Counter
+	attrs      []attribute.KeyValue
+	labelNames []string
+}
+
+// WithLabelValues returns the counter for the input label values, in the same order as the label names.
+func (v *facadeCounterVec) WithLabelValues(lvs ...string) prometheus.Counter {
+	return &facadeCounter{
+		Counter: v.CounterVec.WithLabelValues(lvs...),
+		otel:    v.otel,
+		attrs:   labelValuesAttributes(v.attrs, v.labelNames, lvs),
+	}
+}
+
+type facadeHistogram struct {
+	prometheus.Histogram
+
+	otel  syncfloat64.Histogram
+	attrs []attribute.KeyValue
+}
+
+func (h *facadeHistogram) Observe(v float64) {
+	h.Histogram.Observe(v)
+
+	if h.otel != nil {
+		h.otel.Record(context.Background(), v, h.attrs...)
+	}
+}
+
+type facadeHistogramVec struct {
+	*prometheus.HistogramVec
+
+	otel       syncfloat64.Histogram
+	attrs      []attribute.KeyValue
+	labelNames []string
+}
+
+// facadeObserver is a prometheus.Observer also recording the observations to an OpenTelemetry histogram.
+type facadeObserver struct {
+	prometheus.Observer
+
+	otel  syncfloat64.Histogram
+	attrs []attribute.KeyValue
+}
+
+func (o *facadeObserver) Observe(v float64) {
+	o.Observer.Observe(v)
+
+	if o.otel != nil {
+		o.otel.Record(context.Background(), v, o.attrs...)
+	}
+}
+
+// WithLabelValues returns the observer for the input label values, in the same order as the label names.
+func (v *facadeHistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	return &facadeObserver{
+		Observer: v.HistogramVec.WithLabelValues(lvs...),
+		otel:     v.otel,
+		attrs:    labelValuesAttributes(v.attrs, v.labelNames, lvs),
+	}
+}
+
+func constLabelsAttributes(constLabels prometheus.Labels) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(constLabels))
+	for name, value := range constLabels {
+		attrs = append(attrs, attribute.String(name, value))
+	}
+	return attrs
+}
+
+// labelValuesAttributes returns the input attributes, followed by an attribute for each label value.
+// The input attributes slice is not modified.
+func labelValuesAttributes(attrs []attribute.KeyValue, labelNames, labelValues []string) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attrs)+len(labelNames))
+	result = append(result, attrs...)
+	for i := 0; i < len(labelNames) && i < len(labelValues); i++ {
+		result = append(result, attribute.String(labelNames[i], labelValues[i]))
+	}
+	return result
+}
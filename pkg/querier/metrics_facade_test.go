@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+)
+
+func TestMetricsFacade(t *testing.T) {
+	t.Run("should update both backends", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		meter := newMeterMock()
+		f := newMetricsFacade(reg, meter)
+
+		counter := f.NewCounter(prometheus.CounterOpts{Name: "test_counter_total", Help: "Test.", ConstLabels: prometheus.Labels{"component": "querier"}})
+		counter.Inc()
+		counter.Add(2)
+
+		counterVec := f.NewCounterVec(prometheus.CounterOpts{Name: "test_counter_vec_total", Help: "Test."}, []string{"operation"})
+		counterVec.WithLabelValues("Series").Inc()
+
+		histogram := f.NewHistogram(prometheus.HistogramOpts{Namespace: "test", Name: "histogram", Help: "Test."})
+		histogram.Observe(5)
+
+		histogramVec := f.NewHistogramVec(prometheus.HistogramOpts{Name: "test_histogram_vec", Help: "Test."}, []string{"operation"})
+		histogramVec.WithLabelValues("LabelNames").Observe(7)
+
+		assert.Equal(t, float64(3), testutil.ToFloat64(counter))
+		assert.Equal(t, float64(1), testutil.ToFloat64(counterVec.WithLabelValues("Series")))
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		assert.Len(t, families, 4)
+
+		assert.Equal(t, []meterMockRecord{
+			{value: 1, attrs: []attribute.KeyValue{attribute.String("component", "querier")}},
+			{value: 2, attrs: []attribute.KeyValue{attribute.String("component", "querier")}},
+		}, meter.records("test_counter_total"))
+		assert.Equal(t, []meterMockRecord{
+			{value: 1, attrs: []attribute.KeyValue{attribute.String("operation", "Series")}},
+		}, meter.records("test_counter_vec_total"))
+		assert.Equal(t, []meterMockRecord{
+			{value: 5, attrs: []attribute.KeyValue{}},
+		}, meter.records("test_histogram"))
+		assert.Equal(t, []meterMockRecord{
+			{value: 7, attrs: []attribute.KeyValue{attribute.String("operation", "LabelNames")}},
+		}, meter.records("test_histogram_vec"))
+	})
+
+	t.Run("should only update Prometheus if the meter is not configured", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		f := newMetricsFacade(reg, nil)
+
+		counter := f.NewCounter(prometheus.CounterOpts{Name: "test_counter_total", Help: "Test."})
+		counter.Inc()
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(counter))
+	})
+}
+
+func TestBlocksStoreQueryableMetrics_ShouldEmitToPrometheusAndOpenTelemetry(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	meter := newMeterMock()
+	m := newBlocksStoreQueryableMetricsWithMeter(reg, meter)
+
+	m.blocksFound.Add(3)
+	m.limitRejections.WithLabelValues(limitMaxSeries).Inc()
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.blocksFound))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.limitRejections.WithLabelValues(limitMaxSeries)))
+
+	assert.Equal(t, []meterMockRecord{{value: 3, attrs: []attribute.KeyValue{}}}, meter.records("cortex_querier_blocks_found_total"))
+	assert.Equal(t, []meterMockRecord{
+		{value: 1, attrs: []attribute.KeyValue{attribute.String("limit", limitMaxSeries)}},
+	}, meter.records("cortex_querier_storegateway_limit_rejections_total"))
+
+	// The Prometheus metric names are used as the OpenTelemetry instrument names.
+	require.Contains(t, meter.descriptions, "cortex_querier_storegateway_instances_hit_per_query")
+	assert.Equal(t, "Number of store-gateway instances hit for a single query.", meter.descriptions["cortex_querier_storegateway_instances_hit_per_query"])
+}
+
+type meterMockRecord struct {
+	value float64
+	attrs []attribute.KeyValue
+}
+
+// meterMock is an OpenTelemetry meter recording the values added to its synchronous float instruments.
+type meterMock struct {
+	metric.Meter
+
+	mx           sync.Mutex
+	descriptions map[string]string
+	recorded     map[string][]meterMockRecord
+}
+
+func newMeterMock() *meterMock {
+	return &meterMock{
+		descriptions: map[string]string{},
+		recorded:     map[string][]meterMockRecord{},
+	}
+}
+
+func (m *meterMock) SyncFloat64() syncfloat64.InstrumentProvider {
+	return m
+}
+
+func (m *meterMock) Counter(name string, opts ...instrument.Option) (syncfloat64.Counter, error) {
+	m.register(name, opts)
+	return &instrumentMock{meter: m, name: name}, nil
+}
+
+func (m *meterMock) UpDownCounter(name string, opts ...instrument.Option) (syncfloat64.UpDownCounter, error) {
+	m.register(name, opts)
+	return &instrumentMock{meter: m, name: name}, nil
+}
+
+func (m *meterMock) Histogram(name string, opts ...instrument.Option) (syncfloat64.Histogram, error) {
+	m.register(name, opts)
+	return &instrumentMock{meter: m, name: name}, nil
+}
+
+func (m *meterMock) register(name string, opts []instrument.Option) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.descriptions[name] = instrument.NewConfig(opts...).Description()
+}
+
+func (m *meterMock) record(name string, value float64, attrs []attribute.KeyValue) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.recorded[name] = append(m.recorded[name], meterMockRecord{value: value, attrs: attrs})
+}
+
+func (m *meterMock) records(name string) []meterMockRecord {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.recorded[name]
+}
+
+type instrumentMock struct {
+	instrument.Synchronous
+
+	meter *meterMock
+	name  string
+}
+
+func (i *instrumentMock) Add(_ context.Context, incr float64, attrs ...attribute.KeyValue) {
+	i.meter.record(i.name, incr, attrs)
+}
+
+func (i *instrumentMock) Record(_ context.Context, incr float64, attrs ...attribute.KeyValue) {
+	i.meter.record(i.name, incr, attrs)
+}
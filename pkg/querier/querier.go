@@ -10,6 +10,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -48,7 +49,28 @@ type Config struct {
 	QueryStoreAfter    time.Duration `yaml:"query_store_after" category:"advanced"`
 	MaxQueryIntoFuture time.Duration `yaml:"max_query_into_future" category:"advanced"`
 
-	StoreGatewayClient ClientConfig `yaml:"store_gateway_client"`
+	StoreGatewayClient                ClientConfig  `yaml:"store_gateway_client"`
+	StoreGatewayLoadBalancingStrategy string        `yaml:"store_gateway_load_balancing_strategy" category:"advanced"`
+	StoreGatewayDeadlineSlack         time.Duration `yaml:"store_gateway_deadline_slack" category:"advanced"`
+	StoreGatewayMaxConcurrentStreams  int           `yaml:"store_gateway_max_concurrent_streams_per_query" category:"advanced"`
+	StoreGatewayRefetchMinBackoff     time.Duration `yaml:"store_gateway_refetch_min_backoff" category:"advanced"`
+	StoreGatewayRefetchMaxBackoff     time.Duration `yaml:"store_gateway_refetch_max_backoff" category:"advanced"`
+	StoreGatewayPreferSameZone        bool          `yaml:"store_gateway_prefer_same_zone" category:"experimental"`
+	StoreGatewaySlowResponseThreshold time.Duration `yaml:"store_gateway_slow_response_threshold" category:"advanced"`
+
+	StoreGatewayCircuitBreakerFailureThreshold int           `yaml:"store_gateway_circuit_breaker_failure_threshold" category:"experimental"`
+	StoreGatewayCircuitBreakerFailureWindow    time.Duration `yaml:"store_gateway_circuit_breaker_failure_window" category:"experimental"`
+	StoreGatewayCircuitBreakerOpenTimeout      time.Duration `yaml:"store_gateway_circuit_breaker_open_timeout" category:"experimental"`
+
+	StoreGatewayAddresses flagext.StringSliceCSV `yaml:"store_gateway_addresses" category:"experimental"`
+
+	MaxConcurrentQueriesPerTenantWait time.Duration `yaml:"max_concurrent_queries_per_tenant_wait" category:"experimental"`
+
+	StoreGatewayUnsortedSelectEnabled bool `yaml:"store_gateway_unsorted_select_enabled" category:"experimental"`
+
+	OpenTelemetryMetricsEnabled bool `yaml:"opentelemetry_metrics_enabled" category:"experimental"`
+
+	InstanceZone string `yaml:"instance_availability_zone" category:"advanced"`
 
 	ShuffleShardingIngestersEnabled bool `yaml:"shuffle_sharding_ingesters_enabled" category:"advanced"`
 
@@ -60,16 +82,44 @@ const (
 	queryIngestersWithinFlag                   = "querier.query-ingesters-within"
 	queryStoreAfterFlag                        = "querier.query-store-after"
 	shuffleShardingIngestersLookbackPeriodFlag = "querier.shuffle-sharding-ingesters-lookback-period"
+	storeGatewayRefetchMinBackoffFlag          = "querier.store-gateway-refetch-min-backoff"
+	storeGatewayRefetchMaxBackoffFlag          = "querier.store-gateway-refetch-max-backoff"
+	storeGatewayPreferSameZoneFlag             = "querier.store-gateway-prefer-same-zone"
+	instanceZoneFlag                           = "querier.instance-availability-zone"
+	storeGatewayCircuitBreakerThresholdFlag    = "querier.store-gateway-circuit-breaker-failure-threshold"
+	storeGatewayCircuitBreakerWindowFlag       = "querier.store-gateway-circuit-breaker-failure-window"
+	storeGatewayCircuitBreakerOpenTimeoutFlag  = "querier.store-gateway-circuit-breaker-open-timeout"
+	storeGatewayAddressesFlag                  = "querier.store-gateway-addresses"
 )
 
 var (
-	errBadLookbackConfigs = fmt.Errorf("the -%s setting must be greater than -%s otherwise queries might return partial results", queryIngestersWithinFlag, queryStoreAfterFlag)
-	errEmptyTimeRange     = errors.New("empty time range")
+	errBadLookbackConfigs    = fmt.Errorf("the -%s setting must be greater than -%s otherwise queries might return partial results", queryIngestersWithinFlag, queryStoreAfterFlag)
+	errInvalidMaxRefetches   = fmt.Errorf("the -%s setting must be greater than or equal to 1", validation.BlocksStoreMaxRefetchesFlag)
+	errEmptyTimeRange        = errors.New("empty time range")
+	errInvalidRefetchBackoff = fmt.Errorf("the -%s setting must be greater than or equal to -%s", storeGatewayRefetchMaxBackoffFlag, storeGatewayRefetchMinBackoffFlag)
+	errPreferSameZoneNoZone  = fmt.Errorf("the -%s setting requires -%s to be set", storeGatewayPreferSameZoneFlag, instanceZoneFlag)
+	errEmptyStoreGatewayAddr = fmt.Errorf("the -%s setting must not contain empty addresses", storeGatewayAddressesFlag)
+	errInvalidCircuitBreaker = fmt.Errorf("the -%s and -%s settings must be greater than 0 if -%s is enabled", storeGatewayCircuitBreakerWindowFlag, storeGatewayCircuitBreakerOpenTimeoutFlag, storeGatewayCircuitBreakerThresholdFlag)
 )
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.StoreGatewayClient.RegisterFlagsWithPrefix("querier.store-gateway-client", f)
+	f.Var(&cfg.StoreGatewayAddresses, storeGatewayAddressesFlag, "Comma-separated list of store-gateway addresses (host:port) to query directly, bypassing the store-gateway ring. Every store-gateway must hold all blocks, because each block is queried from any of the addresses. The blocks are spread across the addresses based on their hash, and fetched again from the next address if the request fails. The load balancing strategy, the zone awareness and the circuit breaker don't apply to the store-gateways configured via this option. Empty to discover the store-gateways via the ring.")
+	f.StringVar(&cfg.StoreGatewayLoadBalancingStrategy, "querier.store-gateway-load-balancing-strategy", LoadBalancingStrategyRandom, fmt.Sprintf("The strategy used to pick the store-gateway instance to query among the ones holding a replica of a block. Supported values are: %s.", strings.Join(LoadBalancingStrategies, ", ")))
+	f.DurationVar(&cfg.StoreGatewayDeadlineSlack, "querier.store-gateway-deadline-slack", 0, "If the time left before the query deadline is lower than this value, the querier gives up fetching series from store-gateways and fails the query with a deadline exceeded error, instead of sending requests which are unlikely to complete in time. This value should be set to the expected round trip time of a store-gateway request. 0 to disable.")
+	f.IntVar(&cfg.StoreGatewayMaxConcurrentStreams, "querier.store-gateway-max-concurrent-streams-per-query", 0, "Maximum number of store-gateway series streams concurrently read by the querier for a single query. Requests to the remaining store-gateways wait until a stream has been fully read. 0 means unlimited.")
+	f.DurationVar(&cfg.StoreGatewayRefetchMinBackoff, storeGatewayRefetchMinBackoffFlag, 0, "Minimum time to wait before fetching again the blocks missing after an attempt from store-gateways. The wait time doubles at each attempt, with jitter, up to the max backoff. The querier doesn't wait if the query deadline would be exceeded. 0 to disable.")
+	f.DurationVar(&cfg.StoreGatewayRefetchMaxBackoff, storeGatewayRefetchMaxBackoffFlag, time.Second, "Maximum time to wait before fetching again the blocks missing after an attempt from store-gateways.")
+	f.DurationVar(&cfg.StoreGatewaySlowResponseThreshold, "querier.store-gateway-slow-response-threshold", 0, "If a store-gateway takes longer than this threshold to respond to a request, including the time spent reading the whole series stream, the querier logs a warning with the request details. 0 to disable.")
+	f.BoolVar(&cfg.StoreGatewayPreferSameZone, storeGatewayPreferSameZoneFlag, false, fmt.Sprintf("Prefer querying the store-gateways running in the same availability zone of the querier, configured with -%s, among the ones holding a replica of a block. Store-gateways in other zones are queried only if no store-gateway in the same zone holds the block, or it failed to return it.", instanceZoneFlag))
+	f.IntVar(&cfg.StoreGatewayCircuitBreakerFailureThreshold, storeGatewayCircuitBreakerThresholdFlag, 0, fmt.Sprintf("Number of consecutive requests failed by a store-gateway, within -%s, after which the querier stops querying it for -%s. After that, a single request is sent to check whether the store-gateway recovered. A store-gateway is still queried if no other store-gateway holds the block. 0 to disable.", storeGatewayCircuitBreakerWindowFlag, storeGatewayCircuitBreakerOpenTimeoutFlag))
+	f.DurationVar(&cfg.StoreGatewayCircuitBreakerFailureWindow, storeGatewayCircuitBreakerWindowFlag, time.Minute, fmt.Sprintf("The time window within which the requests failed by a store-gateway count as consecutive failures for -%s.", storeGatewayCircuitBreakerThresholdFlag))
+	f.DurationVar(&cfg.StoreGatewayCircuitBreakerOpenTimeout, storeGatewayCircuitBreakerOpenTimeoutFlag, 30*time.Second, "How long the querier stops querying a store-gateway after the circuit breaker opened, before sending a request to check whether it recovered.")
+	f.DurationVar(&cfg.MaxConcurrentQueriesPerTenantWait, "querier.max-concurrent-queries-per-tenant-wait", time.Second, fmt.Sprintf("How long a query waits for a running query of the same tenant to complete when the tenant reached -%s, before being rejected.", validation.MaxConcurrentQueriesPerTenantFlag))
+	f.BoolVar(&cfg.StoreGatewayUnsortedSelectEnabled, "querier.store-gateway-unsorted-select-enabled", false, "If enabled, the querier concatenates the series fetched from different store-gateways, instead of merging them in sorted order, when the query engine doesn't require sorted series and the store-gateways returned different series. This is the case when the queried blocks have been split by the split-and-merge compactor in the same number of shards, and each shard has been fetched from a single store-gateway. The series are always sorted when the query fetches series from ingesters too.")
+	f.BoolVar(&cfg.OpenTelemetryMetricsEnabled, "querier.opentelemetry-metrics-enabled", false, "If enabled, the blocks storage querier metrics are also emitted to the OpenTelemetry global meter provider, in addition to Prometheus. The meter provider must be set by the application embedding Mimir, otherwise the OpenTelemetry metrics are discarded.")
+	f.StringVar(&cfg.InstanceZone, instanceZoneFlag, "", "The availability zone where this querier is running. Used to track the blocks fetched from store-gateways in the same and other zones, and to prefer store-gateways in the same zone if enabled.")
 	f.BoolVar(&cfg.Iterators, "querier.iterators", false, "Use iterators to execute query, as opposed to fully materialising the series in memory.")
 	f.BoolVar(&cfg.BatchIterators, "querier.batch-iterators", true, "Use batch iterators to execute query, as opposed to fully materialising the series in memory.  Takes precedent over the -querier.iterators flag.")
 	f.DurationVar(&cfg.QueryIngestersWithin, queryIngestersWithinFlag, 13*time.Hour, "Maximum lookback beyond which queries are not sent to ingester. 0 means all queries are sent to ingester.")
@@ -83,7 +133,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 }
 
 // Validate the config
-func (cfg *Config) Validate() error {
+func (cfg *Config) Validate(limits validation.Limits) error {
 	// Ensure the config wont create a situation where no queriers are returned.
 	if cfg.QueryIngestersWithin != 0 && cfg.QueryStoreAfter != 0 {
 		if cfg.QueryStoreAfter >= cfg.QueryIngestersWithin {
@@ -91,6 +141,34 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if _, err := parseLoadBalancingStrategy(cfg.StoreGatewayLoadBalancingStrategy); err != nil {
+		return err
+	}
+
+	if err := cfg.StoreGatewayClient.Validate(); err != nil {
+		return err
+	}
+
+	if limits.BlocksStoreMaxRefetches < 1 {
+		return errInvalidMaxRefetches
+	}
+
+	if cfg.StoreGatewayRefetchMinBackoff > 0 && cfg.StoreGatewayRefetchMaxBackoff < cfg.StoreGatewayRefetchMinBackoff {
+		return errInvalidRefetchBackoff
+	}
+
+	if cfg.StoreGatewayPreferSameZone && cfg.InstanceZone == "" {
+		return errPreferSameZoneNoZone
+	}
+
+	if util.StringsContain(cfg.StoreGatewayAddresses, "") {
+		return errEmptyStoreGatewayAddr
+	}
+
+	if cfg.StoreGatewayCircuitBreakerFailureThreshold > 0 && (cfg.StoreGatewayCircuitBreakerFailureWindow <= 0 || cfg.StoreGatewayCircuitBreakerOpenTimeout <= 0) {
+		return errInvalidCircuitBreaker
+	}
+
 	return nil
 }
 
@@ -233,8 +311,9 @@ type querier struct {
 }
 
 // Select implements storage.Querier interface.
-// The bool passed is ignored because the series is always sorted.
-func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+// The series are always sorted when querying multiple queriers, because they're merged. Otherwise the
+// bool passed is forwarded to the single querier, which may skip sorting the series.
+func (q querier) Select(sortSeries bool, sp *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
 	log, ctx := spanlogger.NewWithLogger(q.ctx, q.logger, "querier.Select")
 	defer log.Span.Finish()
 
@@ -262,7 +341,7 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 	} else if err != nil {
 		return storage.ErrSeriesSet(err)
 	}
-	if sp.Func == "series" { // Clamp max time range for series-only queries, before we check max length.
+	if isSeriesOnlySelect(sp) { // Clamp max time range for series-only queries, before we check max length.
 		maxQueryLength := q.limits.MaxLabelsQueryLength(userID)
 		startMs = int64(clampTime(ctx, model.Time(startMs), maxQueryLength, model.Time(endMs).Add(-maxQueryLength), true, "start", "max label query length", log))
 	}
@@ -281,7 +360,7 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 	}
 
 	if len(q.queriers) == 1 {
-		return q.queriers[0].Select(true, sp, matchers...)
+		return q.queriers[0].Select(sortSeries, sp, matchers...)
 	}
 
 	sets := make(chan storage.SeriesSet, len(q.queriers))
@@ -511,6 +590,17 @@ func UseBeforeTimestampQueryable(queryable storage.Queryable, ts time.Time) Quer
 	}
 }
 
+// isSeriesOnlySelect returns whether the select with the input hints only needs the labels of the series,
+// and not their samples, so that the chunks don't need to be fetched.
+//
+// Only the lookups of the series API are recognized. The PromQL functions and aggregations, including
+// the count-style ones like count(), group(), count_over_time() or absent(), can't skip the chunks: their
+// result depends on which series have samples at each step, which is unknown without the chunks.
+func isSeriesOnlySelect(sp *storage.SelectHints) bool {
+	// There is no series function, this token is used for lookups that don't need samples.
+	return sp != nil && sp.Func == "series"
+}
+
 func validateQueryTimeRange(ctx context.Context, userID string, startMs, endMs int64, limits *validation.Overrides, maxQueryIntoFuture time.Duration, logger log.Logger) (int64, int64, error) {
 	now := model.Now()
 	startTime := model.Time(startMs)
@@ -970,7 +970,11 @@ func TestQuerier_QueryStoreAfterConfig(t *testing.T) {
 			// it was hit or not).
 			expectedMatchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric")}
 			querier := &mockBlocksStorageQuerier{}
-			querier.On("Select", true, mock.Anything, expectedMatchers).Return(storage.EmptySeriesSet())
+			querier.On("Select", mock.Anything, mock.Anything, expectedMatchers).Return(storage.EmptySeriesSet())
+
+			// The series are sorted only if they're merged with the series from ingesters, because
+			// the query engine doesn't require sorted series.
+			expectedSortSeries := c.expectedHitIngester
 
 			queryable, _, _ := New(cfg, overrides, distributor, []QueryableWithFilter{UseAlwaysQueryable(newMockBlocksStorageQueryable(querier))}, nil, log.NewNopLogger(), nil)
 			query, err := engine.NewRangeQuery(queryable, nil, "metric", c.mint, c.maxt, 1*time.Minute)
@@ -994,7 +998,7 @@ func TestQuerier_QueryStoreAfterConfig(t *testing.T) {
 			time.Sleep(30 * time.Millisecond) // NOTE: Since this is a lazy querier there is a race condition between the response and chunk store being called
 
 			if c.expectedHitStorage {
-				querier.AssertCalled(t, "Select", true, mock.Anything, expectedMatchers)
+				querier.AssertCalled(t, "Select", expectedSortSeries, mock.Anything, expectedMatchers)
 			} else {
 				querier.AssertNotCalled(t, "Select", mock.Anything, mock.Anything, mock.Anything)
 			}
@@ -1040,41 +1044,203 @@ func TestStoreQueryable(t *testing.T) {
 	require.True(t, m.useQueryableCalled) // storeQueryable wraps QueryableWithFilter, so it must call its UseQueryable method.
 }
 
+func TestIsSeriesOnlySelect(t *testing.T) {
+	tests := map[string]struct {
+		hints    *storage.SelectHints
+		expected bool
+	}{
+		"no hints": {
+			hints:    nil,
+			expected: false,
+		},
+		"no function": {
+			hints:    &storage.SelectHints{},
+			expected: false,
+		},
+		"series API lookup": {
+			hints:    &storage.SelectHints{Func: "series"},
+			expected: true,
+		},
+		"rate()": {
+			hints:    &storage.SelectHints{Func: "rate", Range: time.Minute.Milliseconds()},
+			expected: false,
+		},
+		// The count-style functions and aggregations need to know which series have samples at each step.
+		"count()": {
+			hints:    &storage.SelectHints{Func: "count", Grouping: []string{"job"}, By: true},
+			expected: false,
+		},
+		"group()": {
+			hints:    &storage.SelectHints{Func: "group"},
+			expected: false,
+		},
+		"count_over_time()": {
+			hints:    &storage.SelectHints{Func: "count_over_time", Range: time.Minute.Milliseconds()},
+			expected: false,
+		},
+		"present_over_time()": {
+			hints:    &storage.SelectHints{Func: "present_over_time", Range: time.Minute.Milliseconds()},
+			expected: false,
+		},
+		"absent()": {
+			hints:    &storage.SelectHints{Func: "absent"},
+			expected: false,
+		},
+		"absent_over_time()": {
+			hints:    &storage.SelectHints{Func: "absent_over_time", Range: time.Minute.Milliseconds()},
+			expected: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, isSeriesOnlySelect(testData.hints))
+		})
+	}
+}
+
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
-		setup    func(cfg *Config)
+		setup    func(cfg *Config, limits *validation.Limits)
 		expected error
 	}{
 		"should pass with default config": {
-			setup: func(cfg *Config) {},
+			setup: func(cfg *Config, limits *validation.Limits) {},
 		},
 		"should pass if 'query store after' is enabled and shuffle-sharding is disabled": {
-			setup: func(cfg *Config) {
+			setup: func(cfg *Config, limits *validation.Limits) {
 				cfg.QueryStoreAfter = time.Hour
 			},
 		},
 		"should pass if both 'query store after' and 'query ingesters within' are set and 'query store after' < 'query ingesters within'": {
-			setup: func(cfg *Config) {
+			setup: func(cfg *Config, limits *validation.Limits) {
 				cfg.QueryStoreAfter = time.Hour
 				cfg.QueryIngestersWithin = 2 * time.Hour
 			},
 		},
 		"should fail if both 'query store after' and 'query ingesters within' are set and 'query store after' > 'query ingesters within'": {
-			setup: func(cfg *Config) {
+			setup: func(cfg *Config, limits *validation.Limits) {
 				cfg.QueryStoreAfter = 3 * time.Hour
 				cfg.QueryIngestersWithin = 2 * time.Hour
 			},
 			expected: errBadLookbackConfigs,
 		},
+		"should pass if the store-gateway load balancing strategy is empty": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayLoadBalancingStrategy = ""
+			},
+		},
+		"should pass if the store-gateway load balancing strategy is round-robin": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayLoadBalancingStrategy = LoadBalancingStrategyRoundRobin
+			},
+		},
+		"should pass if the store-gateway load balancing strategy is least pending requests": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayLoadBalancingStrategy = LoadBalancingStrategyLeastPendingRequests
+			},
+		},
+		"should fail if the store-gateway load balancing strategy is unknown": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayLoadBalancingStrategy = "unknown"
+			},
+			expected: errInvalidLoadBalancingStrategy,
+		},
+		"should pass if the max refetches is 1": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				limits.BlocksStoreMaxRefetches = 1
+			},
+		},
+		"should fail if the max refetches is 0": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				limits.BlocksStoreMaxRefetches = 0
+			},
+			expected: errInvalidMaxRefetches,
+		},
+		"should pass if the refetch max backoff is greater than the min backoff": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayRefetchMinBackoff = 100 * time.Millisecond
+				cfg.StoreGatewayRefetchMaxBackoff = time.Second
+			},
+		},
+		"should fail if the refetch max backoff is lower than the min backoff": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayRefetchMinBackoff = time.Second
+				cfg.StoreGatewayRefetchMaxBackoff = 100 * time.Millisecond
+			},
+			expected: errInvalidRefetchBackoff,
+		},
+		"should pass if the same zone is preferred and the querier zone is set": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayPreferSameZone = true
+				cfg.InstanceZone = "zone-a"
+			},
+		},
+		"should fail if the same zone is preferred but the querier zone is not set": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayPreferSameZone = true
+			},
+			expected: errPreferSameZoneNoZone,
+		},
+		"should pass if the store-gateway circuit breaker is enabled": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayCircuitBreakerFailureThreshold = 5
+			},
+		},
+		"should fail if the store-gateway circuit breaker is enabled but the open timeout is 0": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayCircuitBreakerFailureThreshold = 5
+				cfg.StoreGatewayCircuitBreakerOpenTimeout = 0
+			},
+			expected: errInvalidCircuitBreaker,
+		},
+		"should pass if the store-gateway addresses are set": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayAddresses = []string{"store-gateway-1:9095", "store-gateway-2:9095"}
+			},
+		},
+		"should fail if the store-gateway addresses contain an empty address": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayAddresses = []string{"store-gateway-1:9095", ""}
+			},
+			expected: errEmptyStoreGatewayAddr,
+		},
+		"should pass if the store-gateway client compressions are supported": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayClient.SeriesCompression = "snappy"
+				cfg.StoreGatewayClient.LabelsCompression = "gzip"
+			},
+		},
+		"should fail if the store-gateway client series compression is unsupported": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayClient.SeriesCompression = "unknown"
+			},
+			expected: fmt.Errorf("unsupported store-gateway client gRPC compression: unknown"),
+		},
+		"should fail if the store-gateway client labels max retries is negative": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayClient.LabelsMaxRetries = -1
+			},
+			expected: errInvalidLabelsMaxRetries,
+		},
+		"should fail if the store-gateway client labels retry max backoff is lower than the min backoff": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.StoreGatewayClient.LabelsMaxRetries = 3
+				cfg.StoreGatewayClient.LabelsRetryMinBackoff = time.Second
+				cfg.StoreGatewayClient.LabelsRetryMaxBackoff = time.Millisecond
+			},
+			expected: errInvalidLabelsRetryBackoff,
+		},
 	}
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			cfg := &Config{}
-			flagext.DefaultValues(cfg)
-			testData.setup(cfg)
+			limits := validation.Limits{}
+			flagext.DefaultValues(cfg, &limits)
+			testData.setup(cfg, &limits)
 
-			assert.Equal(t, testData.expected, cfg.Validate())
+			assert.Equal(t, testData.expected, cfg.Validate(limits))
 		})
 	}
 }
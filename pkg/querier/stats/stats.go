@@ -139,6 +139,110 @@ func (s *Stats) LoadSplitQueries() uint32 {
 	return atomic.LoadUint32(&s.SplitQueries)
 }
 
+func (s *Stats) AddQueriedBlocks(blocks uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.QueriedBlocks, blocks)
+}
+
+func (s *Stats) LoadQueriedBlocks() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.QueriedBlocks)
+}
+
+func (s *Stats) AddStoreGatewayRefetches(refetches uint32) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint32(&s.StoreGatewayRefetches, refetches)
+}
+
+func (s *Stats) LoadStoreGatewayRefetches() uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint32(&s.StoreGatewayRefetches)
+}
+
+func (s *Stats) AddTouchedBlockSeries(series uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.TouchedBlockSeries, series)
+}
+
+func (s *Stats) LoadTouchedBlockSeries() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.TouchedBlockSeries)
+}
+
+// SetStoreGatewayShardInstances records the number of store-gateway instances in the tenant's shard.
+// The same query may be executed by multiple queriers (eg. when split or sharded), so the highest
+// number of instances is kept.
+func (s *Stats) SetStoreGatewayShardInstances(instances uint32) {
+	if s == nil {
+		return
+	}
+
+	for {
+		prev := atomic.LoadUint32(&s.StoreGatewayShardInstances)
+		if instances <= prev || atomic.CompareAndSwapUint32(&s.StoreGatewayShardInstances, prev, instances) {
+			return
+		}
+	}
+}
+
+func (s *Stats) LoadStoreGatewayShardInstances() uint32 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint32(&s.StoreGatewayShardInstances)
+}
+
+func (s *Stats) AddFetchedChunkBytesFromCache(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.FetchedChunkBytesFromCache, bytes)
+}
+
+func (s *Stats) LoadFetchedChunkBytesFromCache() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.FetchedChunkBytesFromCache)
+}
+
+func (s *Stats) AddFetchedChunkBytesFromStorage(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.FetchedChunkBytesFromStorage, bytes)
+}
+
+func (s *Stats) LoadFetchedChunkBytesFromStorage() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.FetchedChunkBytesFromStorage)
+}
+
 // Merge the provided Stats into this one.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
@@ -151,6 +255,12 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddFetchedChunks(other.LoadFetchedChunks())
 	s.AddShardedQueries(other.LoadShardedQueries())
 	s.AddSplitQueries(other.LoadSplitQueries())
+	s.AddQueriedBlocks(other.LoadQueriedBlocks())
+	s.AddStoreGatewayRefetches(other.LoadStoreGatewayRefetches())
+	s.AddTouchedBlockSeries(other.LoadTouchedBlockSeries())
+	s.SetStoreGatewayShardInstances(other.LoadStoreGatewayShardInstances())
+	s.AddFetchedChunkBytesFromCache(other.LoadFetchedChunkBytesFromCache())
+	s.AddFetchedChunkBytesFromStorage(other.LoadFetchedChunkBytesFromStorage())
 }
 
 func ShouldTrackHTTPGRPCResponse(r *httpgrpc.HTTPResponse) bool {
@@ -42,6 +42,18 @@ type Stats struct {
 	ShardedQueries uint32 `protobuf:"varint,5,opt,name=sharded_queries,json=shardedQueries,proto3" json:"sharded_queries,omitempty"`
 	// The number of split partial queries executed. 0 if splitting is disabled or the query can't be split.
 	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
+	// The number of distinct blocks queried from store-gateways for the query.
+	QueriedBlocks uint64 `protobuf:"varint,7,opt,name=queried_blocks,json=queriedBlocks,proto3" json:"queried_blocks,omitempty"`
+	// The number of times missing blocks have been fetched again from store-gateways for the query.
+	StoreGatewayRefetches uint32 `protobuf:"varint,8,opt,name=store_gateway_refetches,json=storeGatewayRefetches,proto3" json:"store_gateway_refetches,omitempty"`
+	// The number of series touched in the queried blocks, as reported by store-gateways in the series response hints.
+	TouchedBlockSeries uint64 `protobuf:"varint,9,opt,name=touched_block_series,json=touchedBlockSeries,proto3" json:"touched_block_series,omitempty"`
+	// The number of store-gateway instances in the tenant's shard at the time the query was executed.
+	StoreGatewayShardInstances uint32 `protobuf:"varint,10,opt,name=store_gateway_shard_instances,json=storeGatewayShardInstances,proto3" json:"store_gateway_shard_instances,omitempty"`
+	// The number of bytes of the chunks fetched from store-gateways for the query, which store-gateways read from the cache.
+	FetchedChunkBytesFromCache uint64 `protobuf:"varint,11,opt,name=fetched_chunk_bytes_from_cache,json=fetchedChunkBytesFromCache,proto3" json:"fetched_chunk_bytes_from_cache,omitempty"`
+	// The number of bytes of the chunks fetched from store-gateways for the query, which store-gateways read from the object storage.
+	FetchedChunkBytesFromStorage uint64 `protobuf:"varint,12,opt,name=fetched_chunk_bytes_from_storage,json=fetchedChunkBytesFromStorage,proto3" json:"fetched_chunk_bytes_from_storage,omitempty"`
 }
 
 func (m *Stats) Reset()      { *m = Stats{} }
@@ -118,6 +130,48 @@ func (m *Stats) GetSplitQueries() uint32 {
 	return 0
 }
 
+func (m *Stats) GetQueriedBlocks() uint64 {
+	if m != nil {
+		return m.QueriedBlocks
+	}
+	return 0
+}
+
+func (m *Stats) GetStoreGatewayRefetches() uint32 {
+	if m != nil {
+		return m.StoreGatewayRefetches
+	}
+	return 0
+}
+
+func (m *Stats) GetTouchedBlockSeries() uint64 {
+	if m != nil {
+		return m.TouchedBlockSeries
+	}
+	return 0
+}
+
+func (m *Stats) GetStoreGatewayShardInstances() uint32 {
+	if m != nil {
+		return m.StoreGatewayShardInstances
+	}
+	return 0
+}
+
+func (m *Stats) GetFetchedChunkBytesFromCache() uint64 {
+	if m != nil {
+		return m.FetchedChunkBytesFromCache
+	}
+	return 0
+}
+
+func (m *Stats) GetFetchedChunkBytesFromStorage() uint64 {
+	if m != nil {
+		return m.FetchedChunkBytesFromStorage
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Stats)(nil), "stats.Stats")
 }
@@ -125,29 +179,37 @@ func init() {
 func init() { proto.RegisterFile("stats.proto", fileDescriptor_b4756a0aec8b9d44) }
 
 var fileDescriptor_b4756a0aec8b9d44 = []byte{
-	// 340 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x91, 0xbd, 0x52, 0xea, 0x40,
-	0x14, 0xc7, 0x77, 0xb9, 0xc0, 0x70, 0x97, 0xcb, 0x75, 0x8c, 0x16, 0x91, 0xe2, 0xc0, 0x68, 0x21,
-	0x8d, 0xc1, 0xd1, 0xd2, 0xc6, 0x01, 0x5f, 0x40, 0xb0, 0xb2, 0xc9, 0xe4, 0x63, 0x49, 0x32, 0x06,
-	0x16, 0xb3, 0x9b, 0x71, 0xec, 0x7c, 0x04, 0x4b, 0x1f, 0xc1, 0x19, 0x5f, 0x84, 0x92, 0x92, 0x4a,
-	0x65, 0x69, 0x2c, 0x79, 0x04, 0x27, 0x27, 0xc1, 0x8f, 0x6e, 0xcf, 0xf9, 0xfd, 0x7f, 0xfb, 0x9f,
-	0xd9, 0x65, 0x75, 0xa9, 0x1c, 0x25, 0xad, 0x69, 0x22, 0x94, 0x30, 0x2a, 0x38, 0x34, 0x8f, 0x82,
-	0x48, 0x85, 0xa9, 0x6b, 0x79, 0x62, 0xdc, 0x0d, 0x44, 0x20, 0xba, 0x48, 0xdd, 0x74, 0x84, 0x13,
-	0x0e, 0x78, 0xca, 0xad, 0x26, 0x04, 0x42, 0x04, 0x31, 0xff, 0x4e, 0xf9, 0x69, 0xe2, 0xa8, 0x48,
-	0x4c, 0x72, 0xbe, 0xff, 0x52, 0x62, 0x95, 0x61, 0x76, 0xb1, 0x71, 0xce, 0xfe, 0xde, 0x39, 0x71,
-	0x6c, 0xab, 0x68, 0xcc, 0x4d, 0xda, 0xa6, 0x9d, 0xfa, 0xc9, 0x9e, 0x95, 0xdb, 0xd6, 0xc6, 0xb6,
-	0x2e, 0x0a, 0xbb, 0x57, 0x9b, 0xbd, 0xb6, 0xc8, 0xd3, 0x5b, 0x8b, 0x0e, 0x6a, 0x99, 0x75, 0x15,
-	0x8d, 0xb9, 0x71, 0xcc, 0x76, 0x47, 0x5c, 0x79, 0x21, 0xf7, 0x6d, 0xc9, 0x93, 0x88, 0x4b, 0xdb,
-	0x13, 0xe9, 0x44, 0x99, 0xa5, 0x36, 0xed, 0x94, 0x07, 0x46, 0xc1, 0x86, 0x88, 0xfa, 0x19, 0x31,
-	0x2c, 0xb6, 0xb3, 0x31, 0xbc, 0x30, 0x9d, 0xdc, 0xd8, 0xee, 0xbd, 0xe2, 0xd2, 0xfc, 0x83, 0xc2,
-	0x76, 0x81, 0xfa, 0x19, 0xe9, 0x65, 0xe0, 0x67, 0x03, 0xe6, 0x37, 0x0d, 0xe5, 0x5f, 0x0d, 0x28,
-	0x14, 0x0d, 0x87, 0x6c, 0x4b, 0x86, 0x4e, 0xe2, 0x73, 0xdf, 0xbe, 0x4d, 0xb1, 0xd9, 0xac, 0xb4,
-	0x69, 0xa7, 0x31, 0xf8, 0x5f, 0xac, 0x2f, 0xf3, 0xad, 0x71, 0xc0, 0x1a, 0x72, 0x1a, 0x47, 0xea,
-	0x2b, 0x56, 0xc5, 0xd8, 0x3f, 0x5c, 0x16, 0xa1, 0xde, 0xd9, 0x7c, 0x09, 0x64, 0xb1, 0x04, 0xb2,
-	0x5e, 0x02, 0x7d, 0xd0, 0x40, 0x9f, 0x35, 0xd0, 0x99, 0x06, 0x3a, 0xd7, 0x40, 0xdf, 0x35, 0xd0,
-	0x0f, 0x0d, 0x64, 0xad, 0x81, 0x3e, 0xae, 0x80, 0xcc, 0x57, 0x40, 0x16, 0x2b, 0x20, 0xd7, 0xf9,
-	0xcf, 0xb9, 0x55, 0x7c, 0xc5, 0xd3, 0xcf, 0x00, 0x00, 0x00, 0xff, 0xff, 0x9d, 0x30, 0xbc, 0x87,
-	0xd6, 0x01, 0x00, 0x00,
+	// 479 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x93, 0x3f, 0x6f, 0xd4, 0x3c,
+	0x1c, 0xc7, 0xe3, 0xe7, 0xe9, 0x95, 0xab, 0xaf, 0x57, 0x84, 0x01, 0x61, 0x4e, 0xe0, 0x9e, 0x40,
+	0x88, 0x5b, 0x48, 0x11, 0x48, 0x2c, 0x2c, 0x90, 0x43, 0x45, 0x8c, 0xdc, 0x31, 0xb1, 0x58, 0x89,
+	0xe3, 0x4b, 0xa2, 0x26, 0x71, 0x89, 0x1d, 0x55, 0xdd, 0xd8, 0x59, 0x18, 0x79, 0x09, 0xbc, 0x94,
+	0x8e, 0x37, 0x76, 0x02, 0x2e, 0xb7, 0x30, 0xf6, 0x25, 0x20, 0xff, 0x9c, 0x40, 0x2b, 0xca, 0x16,
+	0xff, 0x3e, 0xdf, 0x3f, 0xb1, 0x9d, 0xe0, 0x81, 0x36, 0xa1, 0xd1, 0xfe, 0x61, 0xa5, 0x8c, 0x22,
+	0x3d, 0x58, 0x8c, 0x1e, 0x25, 0x99, 0x49, 0xeb, 0xc8, 0x17, 0xaa, 0xd8, 0x4b, 0x54, 0xa2, 0xf6,
+	0x80, 0x46, 0xf5, 0x02, 0x56, 0xb0, 0x80, 0x27, 0xe7, 0x1a, 0xb1, 0x44, 0xa9, 0x24, 0x97, 0x7f,
+	0x54, 0x71, 0x5d, 0x85, 0x26, 0x53, 0xa5, 0xe3, 0xf7, 0x3e, 0xf5, 0x70, 0x6f, 0x6e, 0x83, 0xc9,
+	0x0b, 0xbc, 0x75, 0x14, 0xe6, 0x39, 0x37, 0x59, 0x21, 0x29, 0x1a, 0xa3, 0xc9, 0xe0, 0xc9, 0x6d,
+	0xdf, 0xb9, 0xfd, 0xce, 0xed, 0xbf, 0x6a, 0xdd, 0x41, 0xff, 0xe4, 0xdb, 0xae, 0xf7, 0xe5, 0xfb,
+	0x2e, 0x9a, 0xf5, 0xad, 0xeb, 0x5d, 0x56, 0x48, 0xf2, 0x18, 0xdf, 0x58, 0x48, 0x23, 0x52, 0x19,
+	0x73, 0x2d, 0xab, 0x4c, 0x6a, 0x2e, 0x54, 0x5d, 0x1a, 0xfa, 0xdf, 0x18, 0x4d, 0x36, 0x66, 0xa4,
+	0x65, 0x73, 0x40, 0x53, 0x4b, 0x88, 0x8f, 0xaf, 0x77, 0x0e, 0x91, 0xd6, 0xe5, 0x01, 0x8f, 0x8e,
+	0x8d, 0xd4, 0xf4, 0x7f, 0x30, 0x5c, 0x6b, 0xd1, 0xd4, 0x92, 0xc0, 0x82, 0xf3, 0x0d, 0xa0, 0xef,
+	0x1a, 0x36, 0x2e, 0x34, 0x80, 0xa1, 0x6d, 0x78, 0x88, 0xaf, 0xea, 0x34, 0xac, 0x62, 0x19, 0xf3,
+	0x0f, 0x35, 0x34, 0xd3, 0xde, 0x18, 0x4d, 0x86, 0xb3, 0x9d, 0x76, 0xfc, 0xd6, 0x4d, 0xc9, 0x7d,
+	0x3c, 0xd4, 0x87, 0x79, 0x66, 0x7e, 0xcb, 0x36, 0x41, 0xb6, 0x0d, 0xc3, 0x4e, 0xf4, 0x00, 0xef,
+	0x38, 0x1c, 0xf3, 0x28, 0x57, 0xe2, 0x40, 0xd3, 0x2b, 0xd0, 0x3c, 0x6c, 0xa7, 0x01, 0x0c, 0xc9,
+	0x33, 0x7c, 0x4b, 0x1b, 0x55, 0x49, 0x9e, 0x84, 0x46, 0x1e, 0x85, 0xc7, 0xbc, 0x92, 0xee, 0xd5,
+	0x34, 0xed, 0x43, 0xea, 0x4d, 0xc0, 0xaf, 0x1d, 0x9d, 0x75, 0xd0, 0x6e, 0xcf, 0xa8, 0x5a, 0xa4,
+	0x5d, 0x7c, 0x7b, 0x8c, 0x74, 0xcb, 0x6d, 0xaf, 0x65, 0x50, 0xe2, 0x4e, 0x91, 0xbc, 0xc4, 0x77,
+	0x2f, 0x36, 0xc1, 0xae, 0x78, 0x56, 0x6a, 0x13, 0x96, 0x42, 0x6a, 0x8a, 0xa1, 0x6f, 0x74, 0xbe,
+	0x6f, 0x6e, 0x25, 0x6f, 0x3a, 0x05, 0x09, 0x30, 0xbb, 0xe4, 0x0e, 0xf8, 0xa2, 0x52, 0x05, 0x17,
+	0xa1, 0x48, 0x25, 0x1d, 0x40, 0xfd, 0xe8, 0xaf, 0xeb, 0xd8, 0xaf, 0x54, 0x31, 0xb5, 0x0a, 0xb2,
+	0x8f, 0xc7, 0xff, 0xcc, 0xb0, 0xd5, 0x61, 0x22, 0xe9, 0x36, 0xa4, 0xdc, 0xb9, 0x34, 0x65, 0xee,
+	0x34, 0xc1, 0xf3, 0xe5, 0x8a, 0x79, 0xa7, 0x2b, 0xe6, 0x9d, 0xad, 0x18, 0xfa, 0xd8, 0x30, 0xf4,
+	0xb5, 0x61, 0xe8, 0xa4, 0x61, 0x68, 0xd9, 0x30, 0xf4, 0xa3, 0x61, 0xe8, 0x67, 0xc3, 0xbc, 0xb3,
+	0x86, 0xa1, 0xcf, 0x6b, 0xe6, 0x2d, 0xd7, 0xcc, 0x3b, 0x5d, 0x33, 0xef, 0xbd, 0xfb, 0x33, 0xa2,
+	0x4d, 0xf8, 0x4a, 0x9f, 0xfe, 0x1a, 0x00, 0x49, 0xae, 0x6a, 0x98, 0x36, 0x03, 0x00, 0x00,
 }
 
 func (this *Stats) Equal(that interface{}) bool {
@@ -187,13 +249,31 @@ func (this *Stats) Equal(that interface{}) bool {
 	if this.SplitQueries != that1.SplitQueries {
 		return false
 	}
+	if this.QueriedBlocks != that1.QueriedBlocks {
+		return false
+	}
+	if this.StoreGatewayRefetches != that1.StoreGatewayRefetches {
+		return false
+	}
+	if this.TouchedBlockSeries != that1.TouchedBlockSeries {
+		return false
+	}
+	if this.StoreGatewayShardInstances != that1.StoreGatewayShardInstances {
+		return false
+	}
+	if this.FetchedChunkBytesFromCache != that1.FetchedChunkBytesFromCache {
+		return false
+	}
+	if this.FetchedChunkBytesFromStorage != that1.FetchedChunkBytesFromStorage {
+		return false
+	}
 	return true
 }
 func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 10)
+	s := make([]string, 0, 16)
 	s = append(s, "&stats.Stats{")
 	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
 	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
@@ -201,6 +281,12 @@ func (this *Stats) GoString() string {
 	s = append(s, "FetchedChunksCount: "+fmt.Sprintf("%#v", this.FetchedChunksCount)+",\n")
 	s = append(s, "ShardedQueries: "+fmt.Sprintf("%#v", this.ShardedQueries)+",\n")
 	s = append(s, "SplitQueries: "+fmt.Sprintf("%#v", this.SplitQueries)+",\n")
+	s = append(s, "QueriedBlocks: "+fmt.Sprintf("%#v", this.QueriedBlocks)+",\n")
+	s = append(s, "StoreGatewayRefetches: "+fmt.Sprintf("%#v", this.StoreGatewayRefetches)+",\n")
+	s = append(s, "TouchedBlockSeries: "+fmt.Sprintf("%#v", this.TouchedBlockSeries)+",\n")
+	s = append(s, "StoreGatewayShardInstances: "+fmt.Sprintf("%#v", this.StoreGatewayShardInstances)+",\n")
+	s = append(s, "FetchedChunkBytesFromCache: "+fmt.Sprintf("%#v", this.FetchedChunkBytesFromCache)+",\n")
+	s = append(s, "FetchedChunkBytesFromStorage: "+fmt.Sprintf("%#v", this.FetchedChunkBytesFromStorage)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -232,6 +318,36 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.FetchedChunkBytesFromStorage != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.FetchedChunkBytesFromStorage))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.FetchedChunkBytesFromCache != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.FetchedChunkBytesFromCache))
+		i--
+		dAtA[i] = 0x58
+	}
+	if m.StoreGatewayShardInstances != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.StoreGatewayShardInstances))
+		i--
+		dAtA[i] = 0x50
+	}
+	if m.TouchedBlockSeries != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.TouchedBlockSeries))
+		i--
+		dAtA[i] = 0x48
+	}
+	if m.StoreGatewayRefetches != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.StoreGatewayRefetches))
+		i--
+		dAtA[i] = 0x40
+	}
+	if m.QueriedBlocks != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.QueriedBlocks))
+		i--
+		dAtA[i] = 0x38
+	}
 	if m.SplitQueries != 0 {
 		i = encodeVarintStats(dAtA, i, uint64(m.SplitQueries))
 		i--
@@ -302,6 +418,24 @@ func (m *Stats) Size() (n int) {
 	if m.SplitQueries != 0 {
 		n += 1 + sovStats(uint64(m.SplitQueries))
 	}
+	if m.QueriedBlocks != 0 {
+		n += 1 + sovStats(uint64(m.QueriedBlocks))
+	}
+	if m.StoreGatewayRefetches != 0 {
+		n += 1 + sovStats(uint64(m.StoreGatewayRefetches))
+	}
+	if m.TouchedBlockSeries != 0 {
+		n += 1 + sovStats(uint64(m.TouchedBlockSeries))
+	}
+	if m.StoreGatewayShardInstances != 0 {
+		n += 1 + sovStats(uint64(m.StoreGatewayShardInstances))
+	}
+	if m.FetchedChunkBytesFromCache != 0 {
+		n += 1 + sovStats(uint64(m.FetchedChunkBytesFromCache))
+	}
+	if m.FetchedChunkBytesFromStorage != 0 {
+		n += 1 + sovStats(uint64(m.FetchedChunkBytesFromStorage))
+	}
 	return n
 }
 
@@ -322,6 +456,12 @@ func (this *Stats) String() string {
 		`FetchedChunksCount:` + fmt.Sprintf("%v", this.FetchedChunksCount) + `,`,
 		`ShardedQueries:` + fmt.Sprintf("%v", this.ShardedQueries) + `,`,
 		`SplitQueries:` + fmt.Sprintf("%v", this.SplitQueries) + `,`,
+		`QueriedBlocks:` + fmt.Sprintf("%v", this.QueriedBlocks) + `,`,
+		`StoreGatewayRefetches:` + fmt.Sprintf("%v", this.StoreGatewayRefetches) + `,`,
+		`TouchedBlockSeries:` + fmt.Sprintf("%v", this.TouchedBlockSeries) + `,`,
+		`StoreGatewayShardInstances:` + fmt.Sprintf("%v", this.StoreGatewayShardInstances) + `,`,
+		`FetchedChunkBytesFromCache:` + fmt.Sprintf("%v", this.FetchedChunkBytesFromCache) + `,`,
+		`FetchedChunkBytesFromStorage:` + fmt.Sprintf("%v", this.FetchedChunkBytesFromStorage) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -491,6 +631,120 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueriedBlocks", wireType)
+			}
+			m.QueriedBlocks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.QueriedBlocks |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StoreGatewayRefetches", wireType)
+			}
+			m.StoreGatewayRefetches = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StoreGatewayRefetches |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TouchedBlockSeries", wireType)
+			}
+			m.TouchedBlockSeries = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TouchedBlockSeries |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 10:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StoreGatewayShardInstances", wireType)
+			}
+			m.StoreGatewayShardInstances = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StoreGatewayShardInstances |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunkBytesFromCache", wireType)
+			}
+			m.FetchedChunkBytesFromCache = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunkBytesFromCache |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunkBytesFromStorage", wireType)
+			}
+			m.FetchedChunkBytesFromStorage = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunkBytesFromStorage |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -115,6 +115,96 @@ func TestStats_AddSplitQueries(t *testing.T) {
 	})
 }
 
+func TestStats_AddQueriedBlocks(t *testing.T) {
+	t.Run("add and load queried blocks", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddQueriedBlocks(10)
+		stats.AddQueriedBlocks(11)
+
+		assert.Equal(t, uint64(21), stats.LoadQueriedBlocks())
+	})
+
+	t.Run("add and load queried blocks nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddQueriedBlocks(1)
+
+		assert.Equal(t, uint64(0), stats.LoadQueriedBlocks())
+	})
+}
+
+func TestStats_AddStoreGatewayRefetches(t *testing.T) {
+	t.Run("add and load store-gateway refetches", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddStoreGatewayRefetches(1)
+		stats.AddStoreGatewayRefetches(2)
+
+		assert.Equal(t, uint32(3), stats.LoadStoreGatewayRefetches())
+	})
+
+	t.Run("add and load store-gateway refetches nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddStoreGatewayRefetches(1)
+
+		assert.Equal(t, uint32(0), stats.LoadStoreGatewayRefetches())
+	})
+}
+
+func TestStats_AddTouchedBlockSeries(t *testing.T) {
+	t.Run("add and load touched block series", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddTouchedBlockSeries(10)
+		stats.AddTouchedBlockSeries(20)
+
+		assert.Equal(t, uint64(30), stats.LoadTouchedBlockSeries())
+	})
+
+	t.Run("add and load touched block series nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddTouchedBlockSeries(10)
+
+		assert.Equal(t, uint64(0), stats.LoadTouchedBlockSeries())
+	})
+}
+
+func TestStats_SetStoreGatewayShardInstances(t *testing.T) {
+	t.Run("set and load store-gateway shard instances", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.SetStoreGatewayShardInstances(3)
+		stats.SetStoreGatewayShardInstances(5)
+		stats.SetStoreGatewayShardInstances(4)
+
+		assert.Equal(t, uint32(5), stats.LoadStoreGatewayShardInstances())
+	})
+
+	t.Run("set and load store-gateway shard instances nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.SetStoreGatewayShardInstances(3)
+
+		assert.Equal(t, uint32(0), stats.LoadStoreGatewayShardInstances())
+	})
+}
+
+func TestStats_AddFetchedChunkBytesFromCacheAndStorage(t *testing.T) {
+	t.Run("add and load fetched chunk bytes from cache and storage", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddFetchedChunkBytesFromCache(10)
+		stats.AddFetchedChunkBytesFromCache(20)
+		stats.AddFetchedChunkBytesFromStorage(100)
+
+		assert.Equal(t, uint64(30), stats.LoadFetchedChunkBytesFromCache())
+		assert.Equal(t, uint64(100), stats.LoadFetchedChunkBytesFromStorage())
+	})
+
+	t.Run("add and load fetched chunk bytes from cache and storage nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddFetchedChunkBytesFromCache(10)
+		stats.AddFetchedChunkBytesFromStorage(100)
+
+		assert.Equal(t, uint64(0), stats.LoadFetchedChunkBytesFromCache())
+		assert.Equal(t, uint64(0), stats.LoadFetchedChunkBytesFromStorage())
+	})
+}
+
 func TestStats_Merge(t *testing.T) {
 	t.Run("merge two stats objects", func(t *testing.T) {
 		stats1 := &Stats{}
@@ -124,6 +214,12 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddFetchedChunks(10)
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
+		stats1.AddQueriedBlocks(5)
+		stats1.AddStoreGatewayRefetches(1)
+		stats1.AddTouchedBlockSeries(100)
+		stats1.SetStoreGatewayShardInstances(3)
+		stats1.AddFetchedChunkBytesFromCache(12)
+		stats1.AddFetchedChunkBytesFromStorage(30)
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -132,6 +228,12 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddFetchedChunks(11)
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
+		stats2.AddQueriedBlocks(6)
+		stats2.AddStoreGatewayRefetches(2)
+		stats2.AddTouchedBlockSeries(200)
+		stats2.SetStoreGatewayShardInstances(6)
+		stats2.AddFetchedChunkBytesFromCache(60)
+		stats2.AddFetchedChunkBytesFromStorage(40)
 
 		stats1.Merge(stats2)
 
@@ -141,6 +243,12 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(21), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(11), stats1.LoadQueriedBlocks())
+		assert.Equal(t, uint32(3), stats1.LoadStoreGatewayRefetches())
+		assert.Equal(t, uint64(300), stats1.LoadTouchedBlockSeries())
+		assert.Equal(t, uint32(6), stats1.LoadStoreGatewayShardInstances())
+		assert.Equal(t, uint64(72), stats1.LoadFetchedChunkBytesFromCache())
+		assert.Equal(t, uint64(70), stats1.LoadFetchedChunkBytesFromStorage())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
@@ -155,5 +263,11 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(0), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(0), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(0), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(0), stats1.LoadQueriedBlocks())
+		assert.Equal(t, uint32(0), stats1.LoadStoreGatewayRefetches())
+		assert.Equal(t, uint64(0), stats1.LoadTouchedBlockSeries())
+		assert.Equal(t, uint32(0), stats1.LoadStoreGatewayShardInstances())
+		assert.Equal(t, uint64(0), stats1.LoadFetchedChunkBytesFromCache())
+		assert.Equal(t, uint64(0), stats1.LoadFetchedChunkBytesFromStorage())
 	})
 }
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
+)
+
+type circuitBreakerState int
+
+const (
+	circuitBreakerClosed circuitBreakerState = iota
+	circuitBreakerOpen
+	circuitBreakerHalfOpen
+)
+
+func (s circuitBreakerState) String() string {
+	switch s {
+	case circuitBreakerOpen:
+		return "open"
+	case circuitBreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type storeGatewayCircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests, within the FailureWindow,
+	// after which the breaker opens. 0 disables the circuit breaker.
+	FailureThreshold int
+	FailureWindow    time.Duration
+
+	// OpenTimeout is how long the breaker stays open before a probe request is allowed.
+	OpenTimeout time.Duration
+}
+
+func (cfg storeGatewayCircuitBreakerConfig) enabled() bool {
+	return cfg.FailureThreshold > 0
+}
+
+// storeGatewayCircuitBreaker keeps track of the requests failed by each store-gateway. Once a store-gateway
+// failed the configured number of consecutive requests within the failure window, the breaker opens and
+// the store-gateway is not queried anymore. After the open timeout, the breaker half-opens and a single
+// probe request is allowed: the breaker closes if it succeeds, or opens again if it fails.
+//
+// Only the store-gateways whose breaker is not closed are tracked, so the tracked state is bounded
+// by the number of failing store-gateways.
+type storeGatewayCircuitBreaker struct {
+	cfg    storeGatewayCircuitBreakerConfig
+	logger log.Logger
+	now    func() time.Time
+
+	mx     sync.Mutex
+	states map[string]*storeGatewayCircuitBreakerState
+
+	state       *prometheus.GaugeVec
+	transitions *prometheus.CounterVec
+}
+
+type storeGatewayCircuitBreakerState struct {
+	state               circuitBreakerState
+	consecutiveFailures int
+	firstFailureAt      time.Time
+
+	// When the breaker has been opened, or the last probe request has been allowed while half-open.
+	changedAt time.Time
+}
+
+func newStoreGatewayCircuitBreaker(cfg storeGatewayCircuitBreakerConfig, logger log.Logger, reg prometheus.Registerer) *storeGatewayCircuitBreaker {
+	b := &storeGatewayCircuitBreaker{
+		cfg:    cfg,
+		logger: logger,
+		now:    time.Now,
+		states: map[string]*storeGatewayCircuitBreakerState{},
+		state: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_querier_storegateway_circuit_breaker_state",
+			Help: "State of the circuit breaker of store-gateways which are failing requests: 1 if open, 2 if half-open. Store-gateways whose circuit breaker is closed are not tracked.",
+		}, []string{"addr"}),
+		transitions: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_storegateway_circuit_breaker_transitions_total",
+			Help: "Number of times the circuit breaker of a store-gateway transitioned to a state.",
+		}, []string{"state"}),
+	}
+
+	for _, s := range []circuitBreakerState{circuitBreakerClosed, circuitBreakerOpen, circuitBreakerHalfOpen} {
+		b.transitions.WithLabelValues(s.String())
+	}
+
+	return b
+}
+
+// isAvailable returns whether the store-gateway at the input address can be queried: its breaker is closed, or
+// the breaker is open (or half-open) but the open timeout expired since it has been opened (or the last probe).
+func (b *storeGatewayCircuitBreaker) isAvailable(addr string) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	s, ok := b.states[addr]
+	if !ok || s.state == circuitBreakerClosed {
+		return true
+	}
+
+	return b.now().Sub(s.changedAt) >= b.cfg.OpenTimeout
+}
+
+// onSelected must be called when the store-gateway at the input address has been picked to be queried. If its
+// breaker is open (or half-open), the breaker half-opens and the request is the probe to check whether the
+// store-gateway recovered. Further requests are not allowed until the probe completes or the open timeout expires.
+func (b *storeGatewayCircuitBreaker) onSelected(addr string) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	s, ok := b.states[addr]
+	if !ok || s.state == circuitBreakerClosed {
+		return
+	}
+
+	s.changedAt = b.now()
+	b.setStateLocked(addr, s, circuitBreakerHalfOpen)
+}
+
+// onSuccess records a request successfully completed by the store-gateway at the input address.
+func (b *storeGatewayCircuitBreaker) onSuccess(addr string) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	s, ok := b.states[addr]
+	if !ok {
+		return
+	}
+
+	if s.state != circuitBreakerClosed {
+		level.Info(b.logger).Log("msg", "store-gateway circuit breaker closed because the store-gateway recovered", "addr", addr)
+		b.setStateLocked(addr, s, circuitBreakerClosed)
+	}
+
+	delete(b.states, addr)
+}
+
+// onFailure records a request failed by the store-gateway at the input address.
+func (b *storeGatewayCircuitBreaker) onFailure(addr string) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := b.now()
+
+	s, ok := b.states[addr]
+	if !ok {
+		s = &storeGatewayCircuitBreakerState{}
+		b.states[addr] = s
+	}
+
+	switch s.state {
+	case circuitBreakerHalfOpen:
+		// The probe request failed, so the store-gateway has not recovered yet.
+		level.Warn(b.logger).Log("msg", "store-gateway circuit breaker opened again because the probe request failed", "addr", addr)
+		s.changedAt = now
+		b.setStateLocked(addr, s, circuitBreakerOpen)
+
+	case circuitBreakerClosed:
+		// Failures are consecutive only if within the failure window since the first one.
+		if s.consecutiveFailures == 0 || now.Sub(s.firstFailureAt) > b.cfg.FailureWindow {
+			s.consecutiveFailures = 0
+			s.firstFailureAt = now
+		}
+
+		s.consecutiveFailures++
+		if s.consecutiveFailures >= b.cfg.FailureThreshold {
+			level.Warn(b.logger).Log("msg", "store-gateway circuit breaker opened because of consecutive failures", "addr", addr, "failures", s.consecutiveFailures)
+			s.changedAt = now
+			b.setStateLocked(addr, s, circuitBreakerOpen)
+		}
+	}
+}
+
+// setStateLocked transitions the breaker of the input address to the input state.
+// This function must be called while holding the mx lock.
+func (b *storeGatewayCircuitBreaker) setStateLocked(addr string, s *storeGatewayCircuitBreakerState, state circuitBreakerState) {
+	if s.state == state {
+		return
+	}
+
+	s.state = state
+	b.transitions.WithLabelValues(state.String()).Inc()
+
+	if state == circuitBreakerClosed {
+		b.state.DeleteLabelValues(addr)
+	} else {
+		b.state.WithLabelValues(addr).Set(float64(state))
+	}
+}
+
+// circuitBreakerTrackingClient is a BlocksStoreClient which records the outcome
+// of the Series, LabelNames and LabelValues requests in the circuit breaker.
+type circuitBreakerTrackingClient struct {
+	BlocksStoreClient
+
+	breaker *storeGatewayCircuitBreaker
+}
+
+func (c *circuitBreakerTrackingClient) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	stream, err := c.BlocksStoreClient.Series(ctx, in, opts...)
+	if err != nil {
+		c.record(ctx, err)
+		return nil, err
+	}
+
+	return &circuitBreakerTrackingSeriesClient{StoreGateway_SeriesClient: stream, ctx: ctx, client: c}, nil
+}
+
+func (c *circuitBreakerTrackingClient) LabelNames(ctx context.Context, in *storepb.LabelNamesRequest, opts ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	res, err := c.BlocksStoreClient.LabelNames(ctx, in, opts...)
+	c.record(ctx, err)
+	return res, err
+}
+
+func (c *circuitBreakerTrackingClient) LabelValues(ctx context.Context, in *storepb.LabelValuesRequest, opts ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+	res, err := c.BlocksStoreClient.LabelValues(ctx, in, opts...)
+	c.record(ctx, err)
+	return res, err
+}
+
+func (c *circuitBreakerTrackingClient) String() string {
+	return c.RemoteAddress()
+}
+
+// record records the outcome of a request. Requests failed because the query has been canceled, or
+// its deadline exceeded, are not recorded, because they're not a signal of a failing store-gateway.
+// Requests which hit the per-call timeout while the query is still running are recorded as failed,
+// because the store-gateway didn't respond in time.
+func (c *circuitBreakerTrackingClient) record(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		c.breaker.onSuccess(c.RemoteAddress())
+	case ctx.Err() == nil, isStoreGatewayCallTimedOut(ctx):
+		c.breaker.onFailure(c.RemoteAddress())
+	}
+}
+
+// circuitBreakerTrackingSeriesClient records the outcome of the Series request once the stream has
+// been fully consumed or failed. If the caller stops consuming the stream before the end, the outcome
+// is not recorded.
+type circuitBreakerTrackingSeriesClient struct {
+	storegatewaypb.StoreGateway_SeriesClient
+
+	ctx        context.Context
+	client     *circuitBreakerTrackingClient
+	recordOnce sync.Once
+}
+
+func (c *circuitBreakerTrackingSeriesClient) Recv() (*storepb.SeriesResponse, error) {
+	resp, err := c.StoreGateway_SeriesClient.Recv()
+	if err != nil {
+		c.recordOnce.Do(func() {
+			if err == io.EOF {
+				c.client.record(c.ctx, nil)
+			} else {
+				c.client.record(c.ctx, err)
+			}
+		})
+	}
+	return resp, err
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+)
+
+func TestStoreGatewayCircuitBreaker(t *testing.T) {
+	const addr = "1.1.1.1"
+
+	cfg := storeGatewayCircuitBreakerConfig{
+		FailureThreshold: 3,
+		FailureWindow:    time.Minute,
+		OpenTimeout:      30 * time.Second,
+	}
+
+	t.Run("should open after consecutive failures and close once the probe request succeeds", func(t *testing.T) {
+		b, now := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+
+		// The breaker doesn't open until the failure threshold is reached.
+		for i := 0; i < cfg.FailureThreshold-1; i++ {
+			b.onFailure(addr)
+			require.True(t, b.isAvailable(addr))
+		}
+		assert.Equal(t, 0, testutil.CollectAndCount(b.state))
+
+		b.onFailure(addr)
+		assert.False(t, b.isAvailable(addr))
+		assert.Equal(t, float64(circuitBreakerOpen), testutil.ToFloat64(b.state.WithLabelValues(addr)))
+
+		// The breaker half-opens once the open timeout expires.
+		*now = now.Add(cfg.OpenTimeout)
+		require.True(t, b.isAvailable(addr))
+
+		b.onSelected(addr)
+		assert.Equal(t, float64(circuitBreakerHalfOpen), testutil.ToFloat64(b.state.WithLabelValues(addr)))
+
+		// Only the probe request is allowed while half-open.
+		assert.False(t, b.isAvailable(addr))
+
+		b.onSuccess(addr)
+		assert.True(t, b.isAvailable(addr))
+		assert.Equal(t, 0, testutil.CollectAndCount(b.state))
+		assert.Empty(t, b.states)
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(b.transitions.WithLabelValues("open")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(b.transitions.WithLabelValues("half-open")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(b.transitions.WithLabelValues("closed")))
+	})
+
+	t.Run("should open again if the probe request fails", func(t *testing.T) {
+		b, now := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+
+		for i := 0; i < cfg.FailureThreshold; i++ {
+			b.onFailure(addr)
+		}
+
+		*now = now.Add(cfg.OpenTimeout)
+		b.onSelected(addr)
+		b.onFailure(addr)
+
+		assert.False(t, b.isAvailable(addr))
+		assert.Equal(t, float64(circuitBreakerOpen), testutil.ToFloat64(b.state.WithLabelValues(addr)))
+		assert.Equal(t, float64(2), testutil.ToFloat64(b.transitions.WithLabelValues("open")))
+
+		// A new probe request is allowed once the open timeout expires again.
+		*now = now.Add(cfg.OpenTimeout)
+		assert.True(t, b.isAvailable(addr))
+	})
+
+	t.Run("should allow a new probe request if the previous one didn't complete within the open timeout", func(t *testing.T) {
+		b, now := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+
+		for i := 0; i < cfg.FailureThreshold; i++ {
+			b.onFailure(addr)
+		}
+
+		*now = now.Add(cfg.OpenTimeout)
+		b.onSelected(addr)
+		assert.False(t, b.isAvailable(addr))
+
+		*now = now.Add(cfg.OpenTimeout)
+		assert.True(t, b.isAvailable(addr))
+	})
+
+	t.Run("should not open if the failures are interleaved with successes", func(t *testing.T) {
+		b, _ := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+
+		for i := 0; i < cfg.FailureThreshold*2; i++ {
+			b.onFailure(addr)
+			if i%2 == 1 {
+				b.onSuccess(addr)
+			}
+		}
+
+		assert.True(t, b.isAvailable(addr))
+		assert.Equal(t, float64(0), testutil.ToFloat64(b.transitions.WithLabelValues("open")))
+	})
+
+	t.Run("should not open if the failures are not within the failure window", func(t *testing.T) {
+		b, now := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+
+		for i := 0; i < cfg.FailureThreshold*2; i++ {
+			b.onFailure(addr)
+			*now = now.Add(cfg.FailureWindow/2 + time.Second)
+		}
+
+		assert.True(t, b.isAvailable(addr))
+		assert.Equal(t, float64(0), testutil.ToFloat64(b.transitions.WithLabelValues("open")))
+	})
+}
+
+func TestCircuitBreakerTrackingClient(t *testing.T) {
+	const addr = "1.1.1.1"
+
+	cfg := storeGatewayCircuitBreakerConfig{
+		FailureThreshold: 1,
+		FailureWindow:    time.Minute,
+		OpenTimeout:      time.Minute,
+	}
+
+	t.Run("should record a failed request", func(t *testing.T) {
+		b, _ := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+		c := &circuitBreakerTrackingClient{
+			BlocksStoreClient: &storeGatewayClientMock{remoteAddr: addr, mockedSeriesErr: errors.New("failed")},
+			breaker:           b,
+		}
+
+		_, err := c.Series(context.Background(), &storepb.SeriesRequest{})
+		require.Error(t, err)
+		assert.False(t, b.isAvailable(addr))
+	})
+
+	t.Run("should record a successful request once the stream has been consumed", func(t *testing.T) {
+		b, now := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+		b.onFailure(addr)
+		*now = now.Add(cfg.OpenTimeout)
+		b.onSelected(addr)
+
+		c := &circuitBreakerTrackingClient{
+			BlocksStoreClient: &storeGatewayClientMock{
+				remoteAddr:            addr,
+				mockedSeriesResponses: []*storepb.SeriesResponse{mockSeriesResponse(labels.FromStrings("a", "b"), 1, 1)},
+			},
+			breaker: b,
+		}
+
+		stream, err := c.Series(context.Background(), &storepb.SeriesRequest{})
+		require.NoError(t, err)
+		assert.False(t, b.isAvailable(addr))
+
+		for {
+			if _, err := stream.Recv(); err != nil {
+				break
+			}
+		}
+		assert.True(t, b.isAvailable(addr))
+		assert.Empty(t, b.states)
+	})
+
+	t.Run("should not record a request failed because the context has been canceled", func(t *testing.T) {
+		b, _ := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+		c := &circuitBreakerTrackingClient{
+			BlocksStoreClient: &storeGatewayClientMock{remoteAddr: addr, mockedLabelNamesErr: context.Canceled},
+			breaker:           b,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.LabelNames(ctx, &storepb.LabelNamesRequest{})
+		require.Error(t, err)
+		assert.True(t, b.isAvailable(addr))
+	})
+
+	t.Run("should record a request failed because the store-gateway hangs until the per-call timeout", func(t *testing.T) {
+		b, _ := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+		c := &circuitBreakerTrackingClient{
+			BlocksStoreClient: &unresponsiveStoreGatewayClient{&storeGatewayClientMock{remoteAddr: addr}},
+			breaker:           b,
+		}
+		q := &blocksStoreQuerier{perCallTimeout: 10 * time.Millisecond}
+
+		callCtx, cancel := q.newStoreGatewayCallContext(context.Background())
+		defer cancel()
+
+		_, err := c.LabelNames(callCtx, &storepb.LabelNamesRequest{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, b.isAvailable(addr))
+	})
+
+	t.Run("should not record a request failed because the query deadline exceeded while the store-gateway hangs", func(t *testing.T) {
+		b, _ := newStoreGatewayCircuitBreakerWithMockedClock(cfg)
+		c := &circuitBreakerTrackingClient{
+			BlocksStoreClient: &unresponsiveStoreGatewayClient{&storeGatewayClientMock{remoteAddr: addr}},
+			breaker:           b,
+		}
+		q := &blocksStoreQuerier{perCallTimeout: time.Minute}
+
+		queryCtx, cancelQuery := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancelQuery()
+		callCtx, cancel := q.newStoreGatewayCallContext(queryCtx)
+		defer cancel()
+
+		_, err := c.Series(callCtx, &storepb.SeriesRequest{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, b.isAvailable(addr))
+	})
+}
+
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSkipStoreGatewaysWithOpenCircuitBreaker(t *testing.T) {
+	const numRuns = 30
+
+	userID := "user-A"
+	block1 := ulid.MustNew(1, nil)
+
+	s := prepareBlocksStoreReplicationSetWithFullReplication(t, 3, roundRobinLoadBalancing)
+	s.breaker = newStoreGatewayCircuitBreaker(storeGatewayCircuitBreakerConfig{
+		FailureThreshold: 1,
+		FailureWindow:    time.Minute,
+		OpenTimeout:      time.Hour,
+	}, log.NewNopLogger(), nil)
+
+	s.breaker.onFailure("127.0.0.1")
+
+	t.Run("should not query a store-gateway whose circuit breaker is open", func(t *testing.T) {
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+			require.NoError(t, err)
+			require.Len(t, clients, 1)
+			assert.NotContains(t, getStoreGatewayClientAddrs(clients), "127.0.0.1")
+		}
+	})
+
+	t.Run("should query a store-gateway whose circuit breaker is open if no other store-gateway is left for the block", func(t *testing.T) {
+		exclude := map[ulid.ULID][]string{block1: {"127.0.0.2", "127.0.0.3"}}
+
+		clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, exclude)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]ulid.ULID{"127.0.0.1": {block1}}, getStoreGatewayClientAddrs(clients))
+	})
+}
+
+func newStoreGatewayCircuitBreakerWithMockedClock(cfg storeGatewayCircuitBreakerConfig) (*storeGatewayCircuitBreaker, *time.Time) {
+	now := time.Now()
+
+	b := newStoreGatewayCircuitBreaker(cfg, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	b.now = func() time.Time { return now }
+
+	return b, &now
+}
@@ -6,23 +6,51 @@
 package querier
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/crypto/tls"
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/grpcencoding/snappy"
 	"github.com/grafana/dskit/ring/client"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding/gzip"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
+	"github.com/grafana/mimir/pkg/util"
 )
 
-func newStoreGatewayClientFactory(clientCfg grpcclient.Config, reg prometheus.Registerer) client.PoolFactory {
+var (
+	errInvalidLabelsMaxRetries   = errors.New("the store-gateway client labels max retries must be greater than or equal to 0")
+	errInvalidLabelsRetryBackoff = errors.New("the store-gateway client labels retry max backoff must be greater than or equal to the min backoff")
+)
+
+// supportedStoreGatewayCompressions is the list of gRPC compressions which can be requested to
+// store-gateways. The compressors are registered by the grpcclient package.
+var supportedStoreGatewayCompressions = []string{gzip.Name, snappy.Name}
+
+// storeGatewayRetryableMethods are the store-gateway gRPC methods retried on transient errors by the client.
+// They're idempotent unary calls, while the Series call isn't retried because it's a stream which could fail
+// after some series have already been received, and retrying it would return them again.
+var storeGatewayRetryableMethods = map[string]struct{}{
+	"/gatewaypb.StoreGateway/LabelNames":  {},
+	"/gatewaypb.StoreGateway/LabelValues": {},
+}
+
+// newStoreGatewayClientFactory returns a factory of store-gateway clients. The label names and values calls
+// are retried according to labelsRetryCfg, unless its max retries is 0.
+func newStoreGatewayClientFactory(clientCfg grpcclient.Config, labelsRetryCfg backoff.Config, reg prometheus.Registerer) client.PoolFactory {
 	requestDuration := promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "cortex",
 		Name:        "storegateway_client_request_duration_seconds",
@@ -32,12 +60,18 @@ func newStoreGatewayClientFactory(clientCfg grpcclient.Config, reg prometheus.Re
 	}, []string{"operation", "status_code"})
 
 	return func(addr string) (client.PoolClient, error) {
-		return dialStoreGatewayClient(clientCfg, addr, requestDuration)
+		return dialStoreGatewayClient(clientCfg, labelsRetryCfg, addr, requestDuration)
 	}
 }
 
-func dialStoreGatewayClient(clientCfg grpcclient.Config, addr string, requestDuration *prometheus.HistogramVec) (*storeGatewayClient, error) {
-	opts, err := clientCfg.DialOption(grpcclient.Instrument(requestDuration))
+func dialStoreGatewayClient(clientCfg grpcclient.Config, labelsRetryCfg backoff.Config, addr string, requestDuration *prometheus.HistogramVec) (*storeGatewayClient, error) {
+	unary, stream := grpcclient.Instrument(requestDuration)
+	if labelsRetryCfg.MaxRetries > 0 {
+		// The retries are the outermost interceptor, so that each attempt is traced and instrumented.
+		unary = append([]grpc.UnaryClientInterceptor{newStoreGatewayRetryInterceptor(labelsRetryCfg)}, unary...)
+	}
+
+	opts, err := clientCfg.DialOption(unary, stream)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +94,42 @@ type storeGatewayClient struct {
 	conn *grpc.ClientConn
 }
 
+// newStoreGatewayRetryInterceptor returns a gRPC interceptor retrying the calls of the storeGatewayRetryableMethods
+// failed because the store-gateway is unavailable or has exhausted its resources, which is usually transient (eg.
+// during a rollout). The call is retried up to cfg.MaxRetries times, and the last error is returned if all fail.
+func newStoreGatewayRetryInterceptor(cfg backoff.Config) grpc.UnaryClientInterceptor {
+	// The backoff max retries is the max number of attempts, including the first one.
+	cfg.MaxRetries++
+
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := storeGatewayRetryableMethods[method]; !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		retries := backoff.New(ctx, cfg)
+		for {
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || !isRetryableStoreGatewayError(err) {
+				return err
+			}
+
+			retries.Wait()
+			if !retries.Ongoing() {
+				return err
+			}
+		}
+	}
+}
+
+func isRetryableStoreGatewayError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *storeGatewayClient) Close() error {
 	return c.conn.Close()
 }
@@ -73,6 +143,17 @@ func (c *storeGatewayClient) RemoteAddress() string {
 }
 
 func newStoreGatewayClientPool(discovery client.PoolServiceDiscovery, clientConfig ClientConfig, logger log.Logger, reg prometheus.Registerer) *client.Pool {
+	// We prefer sane defaults instead of exposing further config options.
+	poolCfg := client.PoolConfig{
+		CheckInterval:      time.Minute,
+		HealthCheckEnabled: true,
+		HealthCheckTimeout: 10 * time.Second,
+	}
+
+	return newStoreGatewayClientPoolWithConfig(discovery, poolCfg, clientConfig, logger, reg)
+}
+
+func newStoreGatewayClientPoolWithConfig(discovery client.PoolServiceDiscovery, poolCfg client.PoolConfig, clientConfig ClientConfig, logger log.Logger, reg prometheus.Registerer) *client.Pool {
 	// We prefer sane defaults instead of exposing further config options.
 	clientCfg := grpcclient.Config{
 		MaxRecvMsgSize:      100 << 20,
@@ -84,28 +165,83 @@ func newStoreGatewayClientPool(discovery client.PoolServiceDiscovery, clientConf
 		TLSEnabled:          clientConfig.TLSEnabled,
 		TLS:                 clientConfig.TLS,
 	}
-	poolCfg := client.PoolConfig{
-		CheckInterval:      time.Minute,
-		HealthCheckEnabled: true,
-		HealthCheckTimeout: 10 * time.Second,
-	}
 
-	clientsCount := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
-		Namespace:   "cortex",
-		Name:        "storegateway_clients",
-		Help:        "The current number of store-gateway clients in the pool.",
-		ConstLabels: map[string]string{"client": "querier"},
-	})
+	clientsCount := util.NewPoolClientsGauge(
+		promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace:   "cortex",
+			Name:        "storegateway_clients",
+			Help:        "The current number of store-gateway clients in the pool.",
+			ConstLabels: map[string]string{"client": "querier"},
+		}),
+		promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "storegateway_clients_created_total",
+			Help:        "Total number of store-gateway clients created by the pool.",
+			ConstLabels: map[string]string{"client": "querier"},
+		}),
+		promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "storegateway_clients_evicted_total",
+			Help:        "Total number of store-gateway clients evicted from the pool, because the store-gateway left the ring or failed the health check.",
+			ConstLabels: map[string]string{"client": "querier"},
+		}),
+	)
+
+	labelsRetryCfg := backoff.Config{
+		MinBackoff: clientConfig.LabelsRetryMinBackoff,
+		MaxBackoff: clientConfig.LabelsRetryMaxBackoff,
+		MaxRetries: clientConfig.LabelsMaxRetries,
+	}
 
-	return client.NewPool("store-gateway", poolCfg, discovery, newStoreGatewayClientFactory(clientCfg, reg), clientsCount, logger)
+	return client.NewPool("store-gateway", poolCfg, discovery, newStoreGatewayClientFactory(clientCfg, labelsRetryCfg, reg), clientsCount, logger)
 }
 
 type ClientConfig struct {
-	TLSEnabled bool             `yaml:"tls_enabled" category:"advanced"`
-	TLS        tls.ClientConfig `yaml:",inline"`
+	TLSEnabled        bool             `yaml:"tls_enabled" category:"advanced"`
+	TLS               tls.ClientConfig `yaml:",inline"`
+	PerCallTimeout    time.Duration    `yaml:"per_call_timeout" category:"advanced"`
+	SeriesCompression string           `yaml:"series_grpc_compression" category:"advanced"`
+	LabelsCompression string           `yaml:"labels_grpc_compression" category:"advanced"`
+
+	LabelsMaxRetries      int           `yaml:"labels_max_retries" category:"experimental"`
+	LabelsRetryMinBackoff time.Duration `yaml:"labels_retry_min_backoff" category:"experimental"`
+	LabelsRetryMaxBackoff time.Duration `yaml:"labels_retry_max_backoff" category:"experimental"`
 }
 
 func (cfg *ClientConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.BoolVar(&cfg.TLSEnabled, prefix+".tls-enabled", cfg.TLSEnabled, "Enable TLS for gRPC client connecting to store-gateway.")
 	cfg.TLS.RegisterFlagsWithPrefix(prefix, f)
+	f.DurationVar(&cfg.PerCallTimeout, prefix+".per-call-timeout", 0, "Timeout applied to each single call to a store-gateway. When a call times out, the blocks not queried are fetched again from other store-gateways. 0 to disable.")
+	f.StringVar(&cfg.SeriesCompression, prefix+".series-grpc-compression", "", fmt.Sprintf("Compression requested to store-gateways for the series responses. Compression reduces the bytes transferred from store-gateways, at the cost of higher CPU utilization in both the querier and the store-gateway. Supported values are: %s and '' (disable compression).", strings.Join(supportedStoreGatewayCompressions, ", ")))
+	f.StringVar(&cfg.LabelsCompression, prefix+".labels-grpc-compression", "", fmt.Sprintf("Compression requested to store-gateways for the label names and values responses. These responses are usually small, so compression is rarely beneficial. Supported values are: %s and '' (disable compression).", strings.Join(supportedStoreGatewayCompressions, ", ")))
+	f.IntVar(&cfg.LabelsMaxRetries, prefix+".labels-max-retries", 0, "Maximum number of times a label names or values request is retried to the same store-gateway, when the store-gateway is unavailable or has exhausted its resources. Series requests are never retried this way, because they could fail after some series have already been received. 0 to disable.")
+	f.DurationVar(&cfg.LabelsRetryMinBackoff, prefix+".labels-retry-min-backoff", 10*time.Millisecond, "Minimum time to wait before retrying a label names or values request to a store-gateway. The wait time doubles at each retry, with jitter, up to the max backoff.")
+	f.DurationVar(&cfg.LabelsRetryMaxBackoff, prefix+".labels-retry-max-backoff", 100*time.Millisecond, "Maximum time to wait before retrying a label names or values request to a store-gateway.")
+}
+
+func (cfg *ClientConfig) Validate() error {
+	for _, compression := range []string{cfg.SeriesCompression, cfg.LabelsCompression} {
+		if compression != "" && !util.StringsContain(supportedStoreGatewayCompressions, compression) {
+			return fmt.Errorf("unsupported store-gateway client gRPC compression: %s", compression)
+		}
+	}
+
+	if cfg.LabelsMaxRetries < 0 {
+		return errInvalidLabelsMaxRetries
+	}
+	if cfg.LabelsMaxRetries > 0 && cfg.LabelsRetryMaxBackoff < cfg.LabelsRetryMinBackoff {
+		return errInvalidLabelsRetryBackoff
+	}
+
+	return nil
+}
+
+// storeGatewayCallOptions returns the gRPC call options to use for a store-gateway request with the
+// input compression. The store-gateway compresses the response with the same compressor of the request.
+func storeGatewayCallOptions(compression string) []grpc.CallOption {
+	if compression == "" {
+		return nil
+	}
+
+	return []grpc.CallOption{grpc.UseCompressor(compression)}
 }
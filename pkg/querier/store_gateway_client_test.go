@@ -7,18 +7,30 @@ package querier
 
 import (
 	"context"
+	"flag"
 	"net"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
 )
@@ -44,7 +56,7 @@ func Test_newStoreGatewayClientFactory(t *testing.T) {
 	flagext.DefaultValues(&cfg)
 
 	reg := prometheus.NewPedanticRegistry()
-	factory := newStoreGatewayClientFactory(cfg, reg)
+	factory := newStoreGatewayClientFactory(cfg, backoff.Config{}, reg)
 
 	for i := 0; i < 2; i++ {
 		client, err := factory(listener.Addr().String())
@@ -72,6 +84,166 @@ func Test_newStoreGatewayClientFactory(t *testing.T) {
 	assert.Equal(t, uint64(2), metrics[0].GetMetric()[0].GetHistogram().GetSampleCount())
 }
 
+func Test_newStoreGatewayClientFactory_ShouldRetryLabelsRequestsOnTransientErrors(t *testing.T) {
+	tests := map[string]struct {
+		failures         int
+		failureCode      codes.Code
+		maxRetries       int
+		expectedAttempts int
+		expectedCode     codes.Code
+	}{
+		"should not retry if retries are disabled": {
+			failures:         1,
+			failureCode:      codes.Unavailable,
+			maxRetries:       0,
+			expectedAttempts: 1,
+			expectedCode:     codes.Unavailable,
+		},
+		"should succeed if the store-gateway is unavailable for less than the max retries": {
+			failures:         2,
+			failureCode:      codes.Unavailable,
+			maxRetries:       3,
+			expectedAttempts: 3,
+			expectedCode:     codes.OK,
+		},
+		"should succeed if the store-gateway has exhausted its resources for less than the max retries": {
+			failures:         1,
+			failureCode:      codes.ResourceExhausted,
+			maxRetries:       3,
+			expectedAttempts: 2,
+			expectedCode:     codes.OK,
+		},
+		"should fail with the last error if the store-gateway is unavailable for more than the max retries": {
+			failures:         10,
+			failureCode:      codes.Unavailable,
+			maxRetries:       3,
+			expectedAttempts: 4,
+			expectedCode:     codes.Unavailable,
+		},
+		"should not retry on non-transient errors": {
+			failures:         1,
+			failureCode:      codes.Internal,
+			maxRetries:       3,
+			expectedAttempts: 1,
+			expectedCode:     codes.Internal,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			srv := &flakyStoreGatewayServer{failures: testData.failures, failureCode: testData.failureCode}
+			addr := startMockStoreGatewayServer(t, srv)
+
+			cfg := grpcclient.Config{}
+			flagext.DefaultValues(&cfg)
+			retryCfg := backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, MaxRetries: testData.maxRetries}
+
+			client, err := newStoreGatewayClientFactory(cfg, retryCfg, nil)(addr)
+			require.NoError(t, err)
+			defer client.Close() //nolint:errcheck
+
+			ctx := user.InjectOrgID(context.Background(), "test")
+
+			t.Run("label names", func(t *testing.T) {
+				srv.reset()
+				_, err := client.(*storeGatewayClient).LabelNames(ctx, &storepb.LabelNamesRequest{})
+				assert.Equal(t, testData.expectedCode, status.Code(err))
+				assert.Equal(t, testData.expectedAttempts, srv.attempts())
+			})
+
+			t.Run("label values", func(t *testing.T) {
+				srv.reset()
+				_, err := client.(*storeGatewayClient).LabelValues(ctx, &storepb.LabelValuesRequest{Label: "foo"})
+				assert.Equal(t, testData.expectedCode, status.Code(err))
+				assert.Equal(t, testData.expectedAttempts, srv.attempts())
+			})
+
+			t.Run("series are never retried", func(t *testing.T) {
+				srv.reset()
+				stream, err := client.(*storeGatewayClient).Series(ctx, &storepb.SeriesRequest{})
+				require.NoError(t, err)
+
+				// The first series is received before the failure.
+				_, err = stream.Recv()
+				require.NoError(t, err)
+				_, err = stream.Recv()
+				assert.Equal(t, testData.failureCode, status.Code(err))
+				assert.Equal(t, 1, srv.attempts())
+			})
+		})
+	}
+}
+
+func startMockStoreGatewayServer(t *testing.T, srv storegatewaypb.StoreGatewayServer) string {
+	grpcServer := grpc.NewServer()
+	t.Cleanup(grpcServer.GracefulStop)
+
+	storegatewaypb.RegisterStoreGatewayServer(grpcServer, srv)
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	return listener.Addr().String()
+}
+
+// flakyStoreGatewayServer fails the first requests it receives with the failure code, and then succeeds.
+type flakyStoreGatewayServer struct {
+	failures    int
+	failureCode codes.Code
+
+	mtx   sync.Mutex
+	count int
+}
+
+func (m *flakyStoreGatewayServer) reset() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.count = 0
+}
+
+func (m *flakyStoreGatewayServer) attempts() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.count
+}
+
+func (m *flakyStoreGatewayServer) attempt() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.count++
+	if m.count <= m.failures {
+		return status.Error(m.failureCode, "store-gateway failure")
+	}
+	return nil
+}
+
+func (m *flakyStoreGatewayServer) Series(_ *storepb.SeriesRequest, srv storegatewaypb.StoreGateway_SeriesServer) error {
+	// Simulate a failure after some series have already been sent.
+	if err := srv.Send(storepb.NewSeriesResponse(&storepb.Series{})); err != nil {
+		return err
+	}
+	return m.attempt()
+}
+
+func (m *flakyStoreGatewayServer) LabelNames(context.Context, *storepb.LabelNamesRequest) (*storepb.LabelNamesResponse, error) {
+	if err := m.attempt(); err != nil {
+		return nil, err
+	}
+	return &storepb.LabelNamesResponse{Names: []string{"foo"}}, nil
+}
+
+func (m *flakyStoreGatewayServer) LabelValues(context.Context, *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
+	if err := m.attempt(); err != nil {
+		return nil, err
+	}
+	return &storepb.LabelValuesResponse{Values: []string{"bar"}}, nil
+}
+
 type mockStoreGatewayServer struct{}
 
 func (m *mockStoreGatewayServer) Series(_ *storepb.SeriesRequest, srv storegatewaypb.StoreGateway_SeriesServer) error {
@@ -85,3 +257,68 @@ func (m *mockStoreGatewayServer) LabelNames(context.Context, *storepb.LabelNames
 func (m *mockStoreGatewayServer) LabelValues(context.Context, *storepb.LabelValuesRequest) (*storepb.LabelValuesResponse, error) {
 	return nil, nil
 }
+
+func Test_newStoreGatewayClientPool_ShouldTrackCreatedAndEvictedClients(t *testing.T) {
+	var (
+		addrsMx sync.Mutex
+		addrs   = []string{"127.0.0.1:1", "127.0.0.1:2"}
+	)
+
+	// Mock the store-gateways in the ring.
+	discovery := func() ([]string, error) {
+		addrsMx.Lock()
+		defer addrsMx.Unlock()
+		return append([]string(nil), addrs...), nil
+	}
+
+	clientCfg := ClientConfig{}
+	clientCfg.RegisterFlagsWithPrefix("test", flag.NewFlagSet("test", flag.PanicOnError))
+
+	reg := prometheus.NewPedanticRegistry()
+	poolCfg := client.PoolConfig{CheckInterval: 100 * time.Millisecond, HealthCheckEnabled: false}
+	pool := newStoreGatewayClientPoolWithConfig(discovery, poolCfg, clientCfg, log.NewNopLogger(), reg)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), pool))
+	defer services.StopAndAwaitTerminated(context.Background(), pool) //nolint:errcheck
+
+	// The clients are created once, and reused afterwards.
+	for _, addr := range []string{"127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:1"} {
+		_, err := pool.GetClientFor(addr)
+		require.NoError(t, err)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_storegateway_clients The current number of store-gateway clients in the pool.
+		# TYPE cortex_storegateway_clients gauge
+		cortex_storegateway_clients{client="querier"} 2
+
+		# HELP cortex_storegateway_clients_created_total Total number of store-gateway clients created by the pool.
+		# TYPE cortex_storegateway_clients_created_total counter
+		cortex_storegateway_clients_created_total{client="querier"} 2
+
+		# HELP cortex_storegateway_clients_evicted_total Total number of store-gateway clients evicted from the pool, because the store-gateway left the ring or failed the health check.
+		# TYPE cortex_storegateway_clients_evicted_total counter
+		cortex_storegateway_clients_evicted_total{client="querier"} 0
+	`), "cortex_storegateway_clients", "cortex_storegateway_clients_created_total", "cortex_storegateway_clients_evicted_total"))
+
+	// The client of the store-gateway leaving the ring is evicted.
+	addrsMx.Lock()
+	addrs = []string{"127.0.0.1:1"}
+	addrsMx.Unlock()
+
+	test.Poll(t, 5*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_storegateway_clients The current number of store-gateway clients in the pool.
+			# TYPE cortex_storegateway_clients gauge
+			cortex_storegateway_clients{client="querier"} 1
+
+			# HELP cortex_storegateway_clients_created_total Total number of store-gateway clients created by the pool.
+			# TYPE cortex_storegateway_clients_created_total counter
+			cortex_storegateway_clients_created_total{client="querier"} 2
+
+			# HELP cortex_storegateway_clients_evicted_total Total number of store-gateway clients evicted from the pool, because the store-gateway left the ring or failed the health check.
+			# TYPE cortex_storegateway_clients_evicted_total counter
+			cortex_storegateway_clients_evicted_total{client="querier"} 1
+		`), "cortex_storegateway_clients", "cortex_storegateway_clients_created_total", "cortex_storegateway_clients_evicted_total")
+	})
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	// The alert name of the previewed alerting rules, when not specified in the request.
+	defaultPreviewAlertName = "preview"
+
+	// Max number of times a previewed alerting rule is evaluated, to detect whether its alerts
+	// have been active for the whole "for" duration.
+	maxAlertRulePreviewEvaluations = 100
+)
+
+var (
+	errPreviewMissingExpr = errors.New("missing the alerting rule expression")
+	errPreviewNoQueryFunc = errors.New("previewing alerting rules is not supported")
+)
+
+// AlertRulePreview is the result of the preview of an alerting rule.
+type AlertRulePreview struct {
+	Alerts []*AlertRulePreviewAlert `json:"alerts"`
+}
+
+// AlertRulePreviewAlert is an alert of a previewed alerting rule.
+type AlertRulePreviewAlert struct {
+	Labels   labels.Labels `json:"labels"`
+	Value    string        `json:"value"`
+	State    string        `json:"state"`
+	ActiveAt *time.Time    `json:"activeAt"`
+
+	// Whether the alert would fire now, because it's been active for the whole "for" duration.
+	Firing bool `json:"firing"`
+}
+
+// PreviewAlertRule evaluates an alerting rule of the tenant against the live data, and returns the alerts
+// it would generate. The rule is not stored, its state is discarded, and no notification is sent.
+func (r *Ruler) PreviewAlertRule(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	expr := req.FormValue("expr")
+	if expr == "" {
+		http.Error(w, errPreviewMissingExpr.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		http.Error(w, errors.Wrap(err, "invalid alerting rule expression").Error(), http.StatusBadRequest)
+		return
+	}
+
+	var hold time.Duration
+	if value := req.FormValue("for"); value != "" {
+		d, err := model.ParseDuration(value)
+		if err != nil {
+			http.Error(w, errors.Wrap(err, "invalid alerting rule for duration").Error(), http.StatusBadRequest)
+			return
+		}
+		hold = time.Duration(d)
+	}
+
+	name := req.FormValue("alert")
+	if name == "" {
+		name = defaultPreviewAlertName
+	}
+
+	preview, err := r.previewAlertRule(req.Context(), userID, name, parsed, hold, time.Now())
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to preview alerting rule", "user", userID, "expr", expr, "err", err)
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   preview,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// previewAlertRule evaluates an ephemeral alerting rule at the input time. When the rule has a "for" duration,
+// the rule is evaluated since the "for" duration before the input time too, so that the state of the alerts
+// at the input time is the same the rule would have if it had been running.
+func (r *Ruler) previewAlertRule(ctx context.Context, userID, name string, expr parser.Expr, hold time.Duration, now time.Time) (*AlertRulePreview, error) {
+	if r.queryFunc == nil {
+		return nil, errPreviewNoQueryFunc
+	}
+
+	rule := promRules.NewAlertingRule(name, expr, hold, nil, nil, nil, "", false, log.NewNopLogger())
+
+	step := r.limits.RulerEvaluationInterval(userID)
+	if step <= 0 {
+		step = r.cfg.EvaluationInterval
+	}
+	if minStep := hold / maxAlertRulePreviewEvaluations; step < minStep {
+		step = minStep
+	}
+
+	// Start evaluating at least the "for" duration before the input time, like a running rule would have.
+	evaluations := 0
+	if step > 0 {
+		evaluations = int((hold + step - 1) / step)
+	}
+
+	evalDelay := r.limits.EvaluationDelay(userID)
+	for i := evaluations; i >= 0; i-- {
+		if _, err := rule.Eval(ctx, evalDelay, now.Add(-time.Duration(i)*step), r.queryFunc, r.cfg.ExternalURL.URL, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	preview := &AlertRulePreview{Alerts: []*AlertRulePreviewAlert{}}
+	for _, a := range rule.ActiveAlerts() {
+		activeAt := a.ActiveAt
+		preview.Alerts = append(preview.Alerts, &AlertRulePreviewAlert{
+			Labels:   a.Labels,
+			Value:    strconv.FormatFloat(a.Value, 'e', -1, 64),
+			State:    a.State.String(),
+			ActiveAt: &activeAt,
+			Firing:   a.State == promRules.StateFiring,
+		})
+	}
+	return preview, nil
+}
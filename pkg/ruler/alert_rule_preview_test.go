@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuler_PreviewAlertRule(t *testing.T) {
+	// The alerting expression is true for the series of job "api" since 3 minutes ago,
+	// while it has been true for the series of job "db" only at the latest evaluation.
+	now := time.Now()
+	queryFunc := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		vector := promql.Vector{}
+		if !ts.Before(now.Add(-3 * time.Minute)) {
+			vector = append(vector, promql.Sample{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("job", "api")})
+		}
+		if !ts.Before(now.Add(-time.Second)) {
+			vector = append(vector, promql.Sample{Point: promql.Point{T: ts.UnixMilli(), V: 2}, Metric: labels.FromStrings("job", "db")})
+		}
+		return vector, nil
+	}
+
+	cfg := defaultRulerConfig(t)
+	cfg.EvaluationInterval = time.Minute
+	r := buildRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{}), nil)
+	r.queryFunc = queryFunc
+
+	tests := map[string]struct {
+		params         url.Values
+		expectedStatus int
+		expectedBody   string
+		expectedAlerts map[string]bool
+	}{
+		"should return the alerts firing when the rule has no for duration": {
+			params:         url.Values{"expr": {"up == 0"}},
+			expectedStatus: http.StatusOK,
+			expectedAlerts: map[string]bool{"api": true, "db": true},
+		},
+		"should return the alerts active for the whole for duration as firing": {
+			params:         url.Values{"expr": {"up == 0"}, "for": {"2m"}},
+			expectedStatus: http.StatusOK,
+			expectedAlerts: map[string]bool{"api": true, "db": false},
+		},
+		"should return the alerts not active for the whole for duration as pending": {
+			params:         url.Values{"expr": {"up == 0"}, "for": {"5m"}},
+			expectedStatus: http.StatusOK,
+			expectedAlerts: map[string]bool{"api": false, "db": false},
+		},
+		"should fail when the expression is missing": {
+			params:         url.Values{},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   errPreviewMissingExpr.Error() + "\n",
+		},
+		"should fail when the expression is invalid": {
+			params:         url.Values{"expr": {"up =="}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		"should fail when the for duration is invalid": {
+			params:         url.Values{"expr": {"up == 0"}, "for": {"five minutes"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/ruler/preview_alert_rule", strings.NewReader(testData.params.Encode()), "user1")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			r.PreviewAlertRule(w, req)
+
+			resp := w.Result()
+			body, _ := io.ReadAll(resp.Body)
+			require.Equal(t, testData.expectedStatus, resp.StatusCode, string(body))
+			if testData.expectedBody != "" {
+				assert.Equal(t, testData.expectedBody, string(body))
+			}
+			if testData.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var preview struct {
+				Status string           `json:"status"`
+				Data   AlertRulePreview `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(body, &preview))
+			require.Equal(t, "success", preview.Status)
+
+			actualAlerts := map[string]bool{}
+			for _, a := range preview.Data.Alerts {
+				assert.Equal(t, "preview", a.Labels.Get(labels.AlertName))
+				assert.Equal(t, a.Firing, a.State == "firing")
+				actualAlerts[a.Labels.Get("job")] = a.Firing
+			}
+			assert.Equal(t, testData.expectedAlerts, actualAlerts)
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/pkg/errors"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+const alertStateHandoffConcurrency = 10
+
+type AlertStateHandoffConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (cfg *AlertStateHandoffConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.alert-state-handoff.enabled", false, "Store the state of the active alerts in the rule storage when the ruler shuts down, so that the rulers which take over its rule groups restore it instead of having the alerts enter the pending state again. Requires an object storage backend for the rule storage.")
+	f.DurationVar(&cfg.Timeout, "ruler.alert-state-handoff.timeout", 10*time.Second, "Max time to spend storing the alert states when the ruler shuts down, and fetching the alert states of the rule groups the ruler takes over on each rules sync.")
+}
+
+// alertStateKey identifies the alert state of a rule group of a tenant.
+type alertStateKey struct {
+	namespace, group string
+}
+
+// handoffAlertStates stores the state of the active alerts of the rule groups run by this ruler,
+// so that the rulers taking over the rule groups can restore it. The handoff is best-effort and
+// bounded by the configured timeout.
+func (r *Ruler) handoffAlertStates() {
+	store, ok := r.store.(rulestore.AlertStateStore)
+	if !ok {
+		level.Warn(r.logger).Log("msg", "the rule storage doesn't support storing alert states, skipping alert states handoff")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.AlertStateHandoff.Timeout)
+	defer cancel()
+
+	r.ownedRuleGroupsMtx.Lock()
+	userIDs := make([]string, 0, len(r.ownedRuleGroups))
+	for userID := range r.ownedRuleGroups {
+		userIDs = append(userIDs, userID)
+	}
+	r.ownedRuleGroupsMtx.Unlock()
+
+	var groups []*GroupStateDesc
+	for _, userID := range userIDs {
+		userGroups, err := r.getLocalRules(userID)
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "unable to get the alert states to hand off", "user", userID, "err", err)
+			continue
+		}
+
+		for _, g := range userGroups {
+			if hasAlerts(g) {
+				groups = append(groups, g)
+			}
+		}
+	}
+
+	_ = concurrency.ForEachJob(ctx, len(groups), alertStateHandoffConcurrency, func(ctx context.Context, idx int) error {
+		g := groups[idx]
+
+		data, err := g.Marshal()
+		if err == nil {
+			err = store.SetAlertState(ctx, g.Group.User, g.Group.Namespace, g.Group.Name, data)
+		}
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "unable to hand off the alert state", "user", g.Group.User, "namespace", g.Group.Namespace, "group", g.Group.Name, "err", err)
+		}
+
+		// The handoff is best-effort, so we don't stop on the first failure.
+		return nil
+	})
+
+	level.Info(r.logger).Log("msg", "handed off alert states", "groups", len(groups))
+}
+
+// restoreHandedOffAlertStates fetches the alert states handed off by other rulers for the rule groups
+// this ruler didn't own at the previous sync, and passes them to the manager to be restored.
+func (r *Ruler) restoreHandedOffAlertStates(ctx context.Context, configs map[string]rulespb.RuleGroupList) {
+	r.ownedRuleGroupsMtx.Lock()
+	previous := r.ownedRuleGroups
+	r.ownedRuleGroups = configs
+	r.ownedRuleGroupsMtx.Unlock()
+
+	store, ok := r.store.(rulestore.AlertStateStore)
+	if !ok {
+		return
+	}
+
+	var newGroups []*rulespb.RuleGroupDesc
+	for userID, groups := range configs {
+		owned := make(map[alertStateKey]struct{}, len(previous[userID]))
+		for _, g := range previous[userID] {
+			owned[alertStateKey{namespace: g.Namespace, group: g.Name}] = struct{}{}
+		}
+
+		for _, g := range groups {
+			if _, ok := owned[alertStateKey{namespace: g.Namespace, group: g.Name}]; !ok {
+				newGroups = append(newGroups, g)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.AlertStateHandoff.Timeout)
+	defer cancel()
+
+	var (
+		statesMx sync.Mutex
+		states   = map[string][]*GroupStateDesc{}
+	)
+
+	_ = concurrency.ForEachJob(ctx, len(newGroups), alertStateHandoffConcurrency, func(ctx context.Context, idx int) error {
+		g := newGroups[idx]
+
+		state, err := r.fetchHandedOffAlertState(ctx, store, g)
+		if err != nil {
+			level.Warn(r.logger).Log("msg", "unable to fetch the handed off alert state", "user", g.User, "namespace", g.Namespace, "group", g.Name, "err", err)
+			return nil
+		}
+		if state == nil {
+			return nil
+		}
+
+		statesMx.Lock()
+		states[g.User] = append(states[g.User], state)
+		statesMx.Unlock()
+		return nil
+	})
+
+	for userID, userStates := range states {
+		r.manager.RestoreAlertStates(userID, userStates)
+	}
+}
+
+// fetchHandedOffAlertState returns the alert state handed off for the input rule group, or nil if there's
+// no alert state or it's too old to be restored. The alert state is deleted once fetched, so that it's
+// restored only once.
+func (r *Ruler) fetchHandedOffAlertState(ctx context.Context, store rulestore.AlertStateStore, g *rulespb.RuleGroupDesc) (*GroupStateDesc, error) {
+	data, err := store.GetAlertState(ctx, g.User, g.Namespace, g.Name)
+	if errors.Is(err, rulestore.ErrAlertStateNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.DeleteAlertState(ctx, g.User, g.Namespace, g.Name); err != nil {
+		level.Warn(r.logger).Log("msg", "unable to delete the handed off alert state", "user", g.User, "namespace", g.Namespace, "group", g.Name, "err", err)
+	}
+
+	state := &GroupStateDesc{}
+	if err := state.Unmarshal(data); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal alert state")
+	}
+
+	// Honor the same outage tolerance used when restoring the alerts "for" state from the series.
+	if time.Since(state.EvaluationTimestamp) > r.cfg.OutageTolerance {
+		level.Debug(r.logger).Log("msg", "ignoring handed off alert state because too old", "user", g.User, "namespace", g.Namespace, "group", g.Name, "evaluation_timestamp", state.EvaluationTimestamp)
+		return nil, nil
+	}
+
+	return state, nil
+}
+
+func hasAlerts(g *GroupStateDesc) bool {
+	for _, rule := range g.ActiveRules {
+		if len(rule.Alerts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreAlertStates sets the active time of the active alerts of the rule group to the one of the
+// same alerts in the handed off alert state, if older. Returns the number of restored alerts.
+func restoreAlertStates(g *promRules.Group, state *GroupStateDesc) int {
+	activeAt := map[uint64]time.Time{}
+	for _, rule := range state.ActiveRules {
+		for _, a := range rule.Alerts {
+			activeAt[mimirpb.FromLabelAdaptersToLabels(a.Labels).Hash()] = a.ActiveAt
+		}
+	}
+
+	restored := 0
+	for _, rule := range g.Rules() {
+		alertingRule, ok := rule.(*promRules.AlertingRule)
+		if !ok {
+			continue
+		}
+
+		alertingRule.ForEachActiveAlert(func(a *promRules.Alert) {
+			if at, ok := activeAt[a.Labels.Hash()]; ok && at.Before(a.ActiveAt) {
+				a.ActiveAt = at
+				restored++
+			}
+		})
+	}
+
+	return restored
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
+)
+
+func TestRuler_AlertStateHandoff(t *testing.T) {
+	const (
+		userID    = "user1"
+		namespace = "namespace"
+		groupName = "group"
+	)
+
+	bkt := objstore.NewInMemBucket()
+	store := bucketclient.NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+	require.NoError(t, store.SetRuleGroup(context.Background(), userID, namespace, &rulespb.RuleGroupDesc{
+		Name:      groupName,
+		Namespace: namespace,
+		User:      userID,
+		Interval:  100 * time.Millisecond,
+		Rules:     []*rulespb.RuleDesc{{Alert: "UpAlert", Expr: "up", For: time.Hour}},
+	}))
+
+	// The query always returns a sample, so that the alert is pending.
+	queryFunc := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+		return promql.Vector{{Point: promql.Point{T: ts.UnixMilli(), V: 1}, Metric: labels.FromStrings("job", "test")}}, nil
+	}
+
+	newHandoffRuler := func() *Ruler {
+		// Each ruler has its own ring, so that it owns all rule groups.
+		cfg := defaultRulerConfig(t)
+		cfg.AlertStateHandoff.Enabled = true
+
+		noopQueryable, _, pusher, logger, overrides := testSetup()
+		reg := prometheus.NewRegistry()
+		managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, queryFunc, overrides, reg)
+		manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, overrides, reg, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+
+		r, err := newRuler(cfg, manager, reg, logger, store, overrides, queryFunc, newMockClientsPool(cfg, logger, reg, nil))
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+		r.syncRules(context.Background(), rulerSyncReasonInitial)
+		return r
+	}
+
+	alertActiveAt := func(r *Ruler) time.Time {
+		groups, err := r.getLocalRules(userID)
+		require.NoError(t, err)
+		for _, g := range groups {
+			for _, rule := range g.ActiveRules {
+				for _, a := range rule.Alerts {
+					return a.ActiveAt
+				}
+			}
+		}
+		return time.Time{}
+	}
+
+	// Wait until the alert is pending on the first ruler.
+	ruler1 := newHandoffRuler()
+	require.Eventually(t, func() bool {
+		return !alertActiveAt(ruler1).IsZero()
+	}, 5*time.Second, 50*time.Millisecond)
+	activeAt := alertActiveAt(ruler1)
+
+	// The first ruler leaves, handing off the alert state.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ruler1))
+	_, err := store.GetAlertState(context.Background(), userID, namespace, groupName)
+	require.NoError(t, err)
+
+	// Make sure the second ruler evaluates the alert later than the first one.
+	time.Sleep(200 * time.Millisecond)
+
+	// The second ruler takes over the rule group and restores the alert state.
+	ruler2 := newHandoffRuler()
+	defer services.StopAndAwaitTerminated(context.Background(), ruler2) //nolint:errcheck
+
+	require.Eventually(t, func() bool {
+		return alertActiveAt(ruler2).Equal(activeAt)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// The alert state is restored only once.
+	_, err = store.GetAlertState(context.Background(), userID, namespace, groupName)
+	require.ErrorIs(t, err, rulestore.ErrAlertStateNotFound)
+}
+
+func TestRuler_AlertStateHandoff_ShouldIgnoreStatesOlderThanOutageTolerance(t *testing.T) {
+	const userID = "user1"
+
+	bkt := objstore.NewInMemBucket()
+	store := bucketclient.NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	cfg := defaultRulerConfig(t)
+	cfg.AlertStateHandoff.Enabled = true
+	cfg.OutageTolerance = time.Minute
+	r := buildRuler(t, cfg, store, nil)
+
+	group := &rulespb.RuleGroupDesc{User: userID, Namespace: "namespace", Name: "group"}
+	state := &GroupStateDesc{Group: group, EvaluationTimestamp: time.Now().Add(-2 * time.Minute)}
+	data, err := state.Marshal()
+	require.NoError(t, err)
+	require.NoError(t, store.SetAlertState(context.Background(), userID, group.Namespace, group.Name, data))
+
+	restored, err := r.fetchHandedOffAlertState(context.Background(), store, group)
+	require.NoError(t, err)
+	require.Nil(t, restored)
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// Endpoints which haven't been sent any notification for this long (e.g. because they've
+	// been removed from the DNS service discovery) are not tracked anymore.
+	alertmanagerEndpointStaleTimeout = time.Hour
+)
+
+var errAlertmanagerUnhealthy = errors.New("skipped sending notifications to unhealthy Alertmanager")
+
+type notifierDoFunc func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error)
+
+type alertmanagerEndpointHealth struct {
+	healthy bool
+
+	// Time of the last failed notification, used to back off the endpoint while unhealthy.
+	lastFailure time.Time
+
+	// Whether a notification is in flight to check if the endpoint has recovered.
+	probing bool
+
+	// Time of the last notification sent (or skipped) to the endpoint.
+	lastSeen time.Time
+}
+
+// alertmanagerHealthTracker tracks the health of the Alertmanager endpoints based on the outcome of the
+// recent notifications sent to them. An endpoint is unhealthy after a failed notification and, while
+// unhealthy, notifications are not sent to it, except for one every backoff period to check if it has
+// recovered. This avoids that the notifier queue of each tenant waits for the notification timeout
+// of failing Alertmanagers, and drops notifications, during a partial Alertmanager outage.
+type alertmanagerHealthTracker struct {
+	backoff time.Duration
+
+	mtx       sync.Mutex
+	endpoints map[string]*alertmanagerEndpointHealth
+
+	healthyDesc *prometheus.Desc
+}
+
+func newAlertmanagerHealthTracker(backoff time.Duration) *alertmanagerHealthTracker {
+	return &alertmanagerHealthTracker{
+		backoff:   backoff,
+		endpoints: map[string]*alertmanagerEndpointHealth{},
+		healthyDesc: prometheus.NewDesc(
+			"cortex_ruler_alertmanager_endpoint_healthy",
+			"Whether the Alertmanager endpoint is healthy, based on the outcome of the recent notifications sent to it.",
+			[]string{"alertmanager"}, nil),
+	}
+}
+
+// wrap returns a notifierDoFunc which skips the unhealthy Alertmanager endpoints and
+// tracks the health of the endpoints based on the outcome of the notifications.
+func (t *alertmanagerHealthTracker) wrap(do notifierDoFunc) notifierDoFunc {
+	return func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+		endpoint := req.URL.Host
+
+		if !t.allow(endpoint, time.Now()) {
+			return nil, errors.Wrap(errAlertmanagerUnhealthy, endpoint)
+		}
+
+		resp, err := do(ctx, client, req)
+		t.record(endpoint, notificationError(resp, err), time.Now())
+		return resp, err
+	}
+}
+
+// allow returns whether a notification should be sent to the endpoint.
+func (t *alertmanagerHealthTracker) allow(endpoint string, now time.Time) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	e, ok := t.endpoints[endpoint]
+	if !ok {
+		e = &alertmanagerEndpointHealth{healthy: true}
+		t.endpoints[endpoint] = e
+	}
+	e.lastSeen = now
+
+	if e.healthy {
+		return true
+	}
+
+	// Never skip the endpoint if there's no healthy one to fail over to.
+	if !t.anyHealthy() {
+		return true
+	}
+
+	// Once the backoff period is elapsed, let a notification through to check if the endpoint has recovered.
+	if !e.probing && now.Sub(e.lastFailure) >= t.backoff {
+		e.probing = true
+		return true
+	}
+
+	return false
+}
+
+// record updates the endpoint health based on the outcome of a notification sent to it.
+func (t *alertmanagerHealthTracker) record(endpoint string, err error, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	e, ok := t.endpoints[endpoint]
+	if !ok {
+		return
+	}
+
+	e.probing = false
+	if err == nil {
+		e.healthy = true
+		return
+	}
+
+	e.healthy = false
+	e.lastFailure = now
+}
+
+// anyHealthy must be called with the lock held.
+func (t *alertmanagerHealthTracker) anyHealthy() bool {
+	for _, e := range t.endpoints {
+		if e.healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// Describe implements prometheus.Collector.
+func (t *alertmanagerHealthTracker) Describe(out chan<- *prometheus.Desc) {
+	out <- t.healthyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (t *alertmanagerHealthTracker) Collect(out chan<- prometheus.Metric) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := time.Now()
+	for endpoint, e := range t.endpoints {
+		if now.Sub(e.lastSeen) > alertmanagerEndpointStaleTimeout {
+			delete(t.endpoints, endpoint)
+			continue
+		}
+
+		healthy := 0.0
+		if e.healthy {
+			healthy = 1
+		}
+		out <- prometheus.MustNewConstMetric(t.healthyDesc, prometheus.GaugeValue, healthy, endpoint)
+	}
+}
+
+// notificationError returns the error, if any, signaling that the Alertmanager failed to receive
+// the notification. Client errors are not accounted, because they're not caused by the Alertmanager.
+func notificationError(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 == 5 {
+		return fmt.Errorf("bad response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+func TestAlertmanagerHealthTracker_FlappingAlertmanager(t *testing.T) {
+	var (
+		flappingUp       = atomic.NewBool(true)
+		flappingRequests = atomic.NewInt64(0)
+	)
+
+	flapping := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		flappingRequests.Inc()
+		if !flappingUp.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer flapping.Close()
+
+	stable := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer stable.Close()
+
+	const backoff = 200 * time.Millisecond
+	tracker := newAlertmanagerHealthTracker(backoff)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(tracker)
+
+	do := tracker.wrap(func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+		return ctxhttp.Do(ctx, client, req)
+	})
+
+	send := func(server *httptest.Server) error {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/api/v2/alerts", nil)
+		require.NoError(t, err)
+
+		resp, err := do(context.Background(), http.DefaultClient, req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return notificationError(resp, nil)
+	}
+
+	assertHealth := func(flappingHealthy, stableHealthy int) {
+		t.Helper()
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+			# HELP cortex_ruler_alertmanager_endpoint_healthy Whether the Alertmanager endpoint is healthy, based on the outcome of the recent notifications sent to it.
+			# TYPE cortex_ruler_alertmanager_endpoint_healthy gauge
+			cortex_ruler_alertmanager_endpoint_healthy{alertmanager="%s"} %d
+			cortex_ruler_alertmanager_endpoint_healthy{alertmanager="%s"} %d
+		`, hostOf(t, flapping), flappingHealthy, hostOf(t, stable), stableHealthy)), "cortex_ruler_alertmanager_endpoint_healthy"))
+	}
+
+	// Both Alertmanagers are healthy.
+	require.NoError(t, send(flapping))
+	require.NoError(t, send(stable))
+	assertHealth(1, 1)
+
+	// The flapping Alertmanager fails and becomes unhealthy.
+	flappingUp.Store(false)
+	require.Error(t, send(flapping))
+	assertHealth(0, 1)
+
+	// Notifications to the unhealthy Alertmanager are skipped while in backoff.
+	require.Equal(t, int64(2), flappingRequests.Load())
+	require.ErrorIs(t, send(flapping), errAlertmanagerUnhealthy)
+	require.Equal(t, int64(2), flappingRequests.Load())
+	require.NoError(t, send(stable))
+
+	// Once the backoff is elapsed, a notification is sent to check if the Alertmanager has recovered. It hasn't.
+	time.Sleep(backoff)
+	require.Error(t, send(flapping))
+	require.Equal(t, int64(3), flappingRequests.Load())
+	require.ErrorIs(t, send(flapping), errAlertmanagerUnhealthy)
+	assertHealth(0, 1)
+
+	// The Alertmanager recovers, and it's healthy again after the backoff.
+	flappingUp.Store(true)
+	time.Sleep(backoff)
+	require.NoError(t, send(flapping))
+	require.Equal(t, int64(4), flappingRequests.Load())
+	assertHealth(1, 1)
+
+	require.NoError(t, send(flapping))
+	require.Equal(t, int64(5), flappingRequests.Load())
+}
+
+func TestAlertmanagerHealthTracker_ShouldNotSkipWhenNoHealthyAlertmanager(t *testing.T) {
+	tracker := newAlertmanagerHealthTracker(time.Hour)
+	now := time.Now()
+
+	require.True(t, tracker.allow("am-1", now))
+	require.True(t, tracker.allow("am-2", now))
+	tracker.record("am-1", errAlertmanagerUnhealthy, now)
+
+	// There's another healthy Alertmanager, so the unhealthy one is skipped.
+	require.False(t, tracker.allow("am-1", now))
+
+	// No Alertmanager is healthy, so none of them is skipped.
+	tracker.record("am-2", errAlertmanagerUnhealthy, now)
+	require.True(t, tracker.allow("am-1", now))
+	require.True(t, tracker.allow("am-2", now))
+}
+
+func TestNotificationError(t *testing.T) {
+	assert.NoError(t, notificationError(&http.Response{StatusCode: http.StatusOK}, nil))
+	assert.NoError(t, notificationError(&http.Response{StatusCode: http.StatusBadRequest}, nil))
+	assert.Error(t, notificationError(&http.Response{StatusCode: http.StatusInternalServerError, Status: "500 Internal Server Error"}, nil))
+	assert.Error(t, notificationError(nil, context.DeadlineExceeded))
+}
+
+func hostOf(t *testing.T, server *httptest.Server) string {
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return u.Host
+}
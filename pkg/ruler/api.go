@@ -21,7 +21,6 @@ import (
 	"github.com/pkg/errors"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/prometheus/model/labels"
-	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/weaveworks/common/user"
 	"gopkg.in/yaml.v3"
 
@@ -70,11 +69,12 @@ type RuleGroup struct {
 	// In order to preserve rule ordering, while exposing type (alerting or recording)
 	// specific properties, both alerting and recording rules are exposed in the
 	// same array.
-	Rules          []rule    `json:"rules"`
-	Interval       float64   `json:"interval"`
-	LastEvaluation time.Time `json:"lastEvaluation"`
-	EvaluationTime float64   `json:"evaluationTime"`
-	SourceTenants  []string  `json:"sourceTenants"`
+	Rules           []rule    `json:"rules"`
+	Interval        float64   `json:"interval"`
+	EvaluationDelay float64   `json:"evaluationDelay"`
+	LastEvaluation  time.Time `json:"lastEvaluation"`
+	EvaluationTime  float64   `json:"evaluationTime"`
+	SourceTenants   []string  `json:"sourceTenants"`
 }
 
 type rule interface{}
@@ -106,6 +106,29 @@ type recordingRule struct {
 	EvaluationTime float64       `json:"evaluationTime"`
 }
 
+// FailingRulesDiscovery has info for all failing rules.
+type FailingRulesDiscovery struct {
+	Rules []*FailingRule `json:"rules"`
+}
+
+// FailingRule has info for a rule whose health is not ok or whose last evaluation failed.
+type FailingRule struct {
+	Namespace      string      `json:"namespace"`
+	Group          string      `json:"group"`
+	Name           string      `json:"name"`
+	Query          string      `json:"query"`
+	Type           v1.RuleType `json:"type"`
+	Health         string      `json:"health"`
+	LastError      string      `json:"lastError"`
+	LastEvaluation time.Time   `json:"lastEvaluation"`
+}
+
+// RulesSyncResult has info about the rules loaded by a rules sync.
+type RulesSyncResult struct {
+	Tenants    int `json:"tenants"`
+	RuleGroups int `json:"ruleGroups"`
+}
+
 func respondError(logger log.Logger, w http.ResponseWriter, msg string) {
 	b, err := json.Marshal(&response{
 		Status:    "error",
@@ -172,6 +195,9 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 			EvaluationTime: g.GetEvaluationDuration().Seconds(),
 			SourceTenants:  g.Group.GetSourceTenants(),
 		}
+		if delay := g.Group.GetEvaluationDelay(); delay != nil {
+			grp.EvaluationDelay = delay.Seconds()
+		}
 
 		for i, rl := range g.ActiveRules {
 			if g.ActiveRules[i].Rule.Alert != "" {
@@ -422,7 +448,7 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "retrieved rule groups from rule store", "userID", userID, "num_namespaces", len(rgs))
 
-	formatted := rgs.Formatted()
+	formatted := rgs.FormattedWithOptions()
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -444,7 +470,7 @@ func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	formatted := rulespb.FromProto(rg)
+	formatted := rulespb.FromProtoWithOptions(rg)
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -465,7 +491,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
 
-	rg := rulefmt.RuleGroup{}
+	rg := rulespb.RuleGroup{}
 	err = yaml.Unmarshal(payload, &rg)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
@@ -473,7 +499,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	errs := a.ruler.manager.ValidateRuleGroup(rg)
+	errs := a.ruler.manager.ValidateRuleGroup(rg.RuleGroup)
 	if len(errs) > 0 {
 		e := []string{}
 		for _, err := range errs {
@@ -485,12 +511,24 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := validateRuleGroupLabels(rg.Name, rg.Labels); err != nil {
+		level.Error(logger).Log("msg", "unable to validate rule group payload", "err", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
 		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	if err := a.ruler.AssertAllowedSourceTenants(userID, rg.SourceTenants); err != nil {
+		level.Error(logger).Log("msg", "source tenants validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
@@ -504,7 +542,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	rgProto := rulespb.ToProto(userID, namespace, rg)
+	rgProto := rulespb.ToProtoWithOptions(userID, namespace, rg)
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
 	err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
@@ -295,6 +295,75 @@ rules:
 `,
 			output: "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up{}\n    - alert: up_alert\n      expr: sum(up{}) > 1\n      for: 30s\n      labels:\n        test: test\n      annotations:\n        test: test\n",
 		},
+		{
+			name:   "with a rule group overriding the evaluation delay",
+			status: 202,
+			input: `
+name: test
+interval: 15s
+evaluation_delay: 5m
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\ninterval: 15s\nevaluation_delay: 5m\nrules:\n    - record: up_rule\n      expr: up{}\n",
+		},
+		{
+			name:   "with a disabled rule group",
+			status: 202,
+			input: `
+name: test
+interval: 15s
+disabled: true
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up{}\ndisabled: true\n",
+		},
+		{
+			name:   "with a rule group with labels",
+			status: 202,
+			input: `
+name: test
+interval: 15s
+labels:
+  team: payments
+rules:
+- record: up_rule
+  expr: up{}
+  labels:
+    team: billing
+`,
+			output: "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up{}\n      labels:\n        team: billing\nlabels:\n    team: payments\n",
+		},
+		{
+			name:   "with a rule group with evaluation timeout",
+			status: 202,
+			input: `
+name: test
+interval: 15s
+evaluation_timeout: 30s
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "name: test\ninterval: 15s\nrules:\n    - record: up_rule\n      expr: up{}\nevaluation_timeout: 30s\n",
+		},
+		{
+			name:   "with a rule group with invalid labels",
+			status: 400,
+			input: `
+name: test
+interval: 15s
+labels:
+  __name__: renamed
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			err: errors.New("invalid rules config: rule group 'test' labels must not set the metric name"),
+		},
 	}
 
 	for _, tt := range tc {
@@ -513,6 +582,75 @@ rules:
 	}
 }
 
+func TestRuler_AllowedSourceTenants(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.TenantFederation.Enabled = true
+
+	r := newTestRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	r.limits = &ruleLimits{maxRuleGroups: 10, maxRulesPerRuleGroup: 10, allowedSourceTenants: []string{"tenant-2", "tenant-3"}}
+
+	a := NewAPI(r, r.store, log.NewNopLogger())
+
+	tc := []struct {
+		name   string
+		input  string
+		output string
+		status int
+	}{
+		{
+			name:   "when the rule group has no source tenants",
+			status: 202,
+			input: `
+name: non_federated
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "{\"status\":\"success\",\"data\":null,\"errorType\":\"\",\"error\":\"\"}",
+		},
+		{
+			name:   "when all the source tenants are allowed",
+			status: 202,
+			input: `
+name: federated_allowed
+source_tenants: [user1, tenant-2, tenant-3]
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "{\"status\":\"success\",\"data\":null,\"errorType\":\"\",\"error\":\"\"}",
+		},
+		{
+			name:   "when a source tenant is not allowed",
+			status: 400,
+			input: `
+name: federated_disallowed
+source_tenants: [tenant-2, tenant-4]
+rules:
+- record: up_rule
+  expr: up{}
+`,
+			output: "source tenant tenant-4 is not allowed for the tenant's federated rule groups (allowed: tenant-2,tenant-3)\n",
+		},
+	}
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
 func requestFor(t *testing.T, method string, url string, body io.Reader, userID string) *http.Request {
 	t.Helper()
 
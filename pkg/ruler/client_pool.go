@@ -17,6 +17,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/grafana/mimir/pkg/util"
 )
 
 // ClientsPool is the interface used to get the client from the pool for a specified address.
@@ -46,10 +48,24 @@ func newRulerClientPool(clientCfg grpcclient.Config, logger log.Logger, reg prom
 		HealthCheckTimeout: 10 * time.Second,
 	}
 
-	clientsCount := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
-		Name: "cortex_ruler_clients",
-		Help: "The current number of ruler clients in the pool.",
-	})
+	return newRulerClientPoolWithConfig(poolCfg, clientCfg, logger, reg)
+}
+
+func newRulerClientPoolWithConfig(poolCfg client.PoolConfig, clientCfg grpcclient.Config, logger log.Logger, reg prometheus.Registerer) ClientsPool {
+	clientsCount := util.NewPoolClientsGauge(
+		promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_clients",
+			Help: "The current number of ruler clients in the pool.",
+		}),
+		promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_clients_created_total",
+			Help: "Total number of ruler clients created by the pool.",
+		}),
+		promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_clients_evicted_total",
+			Help: "Total number of ruler clients evicted from the pool, because the ruler failed the health check.",
+		}),
+	)
 
 	return &rulerClientsPool{
 		client.NewPool("ruler", poolCfg, nil, newRulerClientFactory(clientCfg, reg), clientsCount, logger),
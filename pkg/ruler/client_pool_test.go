@@ -8,16 +8,25 @@ package ruler
 import (
 	"context"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/grpcclient"
+	"github.com/grafana/dskit/grpcutil"
+	"github.com/grafana/dskit/ring/client"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 func Test_newRulerClientFactory(t *testing.T) {
@@ -70,3 +79,77 @@ type mockRulerServer struct{}
 func (m *mockRulerServer) Rules(context.Context, *RulesRequest) (*RulesResponse, error) {
 	return &RulesResponse{}, nil
 }
+
+func Test_newRulerClientPool_ShouldTrackCreatedAndEvictedClients(t *testing.T) {
+	// Create a GRPC server passing the health check.
+	grpcServer := grpc.NewServer()
+	defer grpcServer.GracefulStop()
+
+	sm, err := services.NewManager(services.NewIdleService(nil, nil))
+	require.NoError(t, err)
+	require.NoError(t, services.StartManagerAndAwaitHealthy(context.Background(), sm))
+	defer services.StopManagerAndAwaitStopped(context.Background(), sm) //nolint:errcheck
+	grpc_health_v1.RegisterHealthServer(grpcServer, grpcutil.NewHealthCheck(sm))
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	go func() {
+		require.NoError(t, grpcServer.Serve(listener))
+	}()
+
+	// The client to an address nobody listens on fails the health check.
+	unhealthyListener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	unhealthyAddr := unhealthyListener.Addr().String()
+	require.NoError(t, unhealthyListener.Close())
+
+	cfg := grpcclient.Config{}
+	flagext.DefaultValues(&cfg)
+
+	reg := prometheus.NewPedanticRegistry()
+	poolCfg := client.PoolConfig{CheckInterval: 100 * time.Millisecond, HealthCheckEnabled: true, HealthCheckTimeout: time.Second}
+	pool := newRulerClientPoolWithConfig(poolCfg, cfg, log.NewNopLogger(), reg)
+
+	// The clients are created once, and reused afterwards.
+	for i := 0; i < 2; i++ {
+		_, err = pool.GetClientFor(listener.Addr().String())
+		require.NoError(t, err)
+		_, err = pool.GetClientFor(unhealthyAddr)
+		require.NoError(t, err)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_clients The current number of ruler clients in the pool.
+		# TYPE cortex_ruler_clients gauge
+		cortex_ruler_clients 2
+
+		# HELP cortex_ruler_clients_created_total Total number of ruler clients created by the pool.
+		# TYPE cortex_ruler_clients_created_total counter
+		cortex_ruler_clients_created_total 2
+
+		# HELP cortex_ruler_clients_evicted_total Total number of ruler clients evicted from the pool, because the ruler failed the health check.
+		# TYPE cortex_ruler_clients_evicted_total counter
+		cortex_ruler_clients_evicted_total 0
+	`), "cortex_ruler_clients", "cortex_ruler_clients_created_total", "cortex_ruler_clients_evicted_total"))
+
+	// The client failing the health check is evicted.
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), pool))
+	defer services.StopAndAwaitTerminated(context.Background(), pool) //nolint:errcheck
+
+	test.Poll(t, 5*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_ruler_clients The current number of ruler clients in the pool.
+			# TYPE cortex_ruler_clients gauge
+			cortex_ruler_clients 1
+
+			# HELP cortex_ruler_clients_created_total Total number of ruler clients created by the pool.
+			# TYPE cortex_ruler_clients_created_total counter
+			cortex_ruler_clients_created_total 2
+
+			# HELP cortex_ruler_clients_evicted_total Total number of ruler clients evicted from the pool, because the ruler failed the health check.
+			# TYPE cortex_ruler_clients_evicted_total counter
+			cortex_ruler_clients_evicted_total 1
+		`), "cortex_ruler_clients", "cortex_ruler_clients_created_total", "cortex_ruler_clients_evicted_total")
+	})
+}
@@ -8,6 +8,9 @@ package ruler
 import (
 	"context"
 	"errors"
+	"math"
+	"net/url"
+	"path/filepath"
 	"time"
 
 	"github.com/go-kit/log"
@@ -27,6 +30,7 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/querier"
 	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
@@ -36,17 +40,27 @@ type Pusher interface {
 }
 
 type PusherAppender struct {
-	failedWrites prometheus.Counter
-	totalWrites  prometheus.Counter
+	failedWrites    prometheus.Counter
+	totalWrites     prometheus.Counter
+	adjustedSamples prometheus.Counter
 
 	ctx     context.Context
 	pusher  Pusher
 	labels  []labels.Labels
 	samples []mimirpb.Sample
 	userID  string
+
+	// minTimestamp is the oldest timestamp samples are written with, when the out-of-order tolerance
+	// is enabled. Older samples are written with this timestamp instead. 0 if disabled.
+	minTimestamp int64
 }
 
 func (a *PusherAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	if a.minTimestamp > 0 && t < a.minTimestamp {
+		t = a.minTimestamp
+		a.adjustedSamples.Inc()
+	}
+
 	a.labels = append(a.labels, l)
 	a.samples = append(a.samples, mimirpb.Sample{
 		TimestampMs: t,
@@ -88,38 +102,73 @@ func (a *PusherAppender) Rollback() error {
 type PusherAppendable struct {
 	pusher Pusher
 	userID string
+	limits RulesLimits
+	now    func() time.Time
 
-	totalWrites  prometheus.Counter
-	failedWrites prometheus.Counter
+	totalWrites     prometheus.Counter
+	failedWrites    prometheus.Counter
+	adjustedSamples prometheus.Counter
 }
 
-func NewPusherAppendable(pusher Pusher, userID string, limits RulesLimits, totalWrites, failedWrites prometheus.Counter) *PusherAppendable {
+func NewPusherAppendable(pusher Pusher, userID string, limits RulesLimits, totalWrites, failedWrites, adjustedSamples prometheus.Counter) *PusherAppendable {
 	return &PusherAppendable{
-		pusher:       pusher,
-		userID:       userID,
-		totalWrites:  totalWrites,
-		failedWrites: failedWrites,
+		pusher:          pusher,
+		userID:          userID,
+		limits:          limits,
+		now:             time.Now,
+		totalWrites:     totalWrites,
+		failedWrites:    failedWrites,
+		adjustedSamples: adjustedSamples,
 	}
 }
 
 // Appender returns a storage.Appender
 func (t *PusherAppendable) Appender(ctx context.Context) storage.Appender {
 	return &PusherAppender{
-		failedWrites: t.failedWrites,
-		totalWrites:  t.totalWrites,
+		failedWrites:    t.failedWrites,
+		totalWrites:     t.totalWrites,
+		adjustedSamples: t.adjustedSamples,
+
+		ctx:          ctx,
+		pusher:       t.pusher,
+		userID:       t.userID,
+		minTimestamp: t.minTimestamp(ctx),
+	}
+}
+
+// minTimestamp returns the oldest timestamp the samples of the rule evaluation running with the input
+// context can be written with, or 0 if the out-of-order tolerance is disabled for the tenant.
+//
+// Rule evaluations running late (eg. catching up after the ruler has been unable to evaluate them in time)
+// produce samples older than the ones ingested in the meanwhile, which would be rejected by ingesters as
+// out-of-order. Their timestamp is clamped to the oldest one within the tolerance instead, taking into
+// account the evaluation delay the samples are expected to be written with.
+func (t *PusherAppendable) minTimestamp(ctx context.Context) int64 {
+	tolerance := t.limits.RulerOutOfOrderTolerance(t.userID)
+	if tolerance <= 0 {
+		return 0
+	}
 
-		ctx:    ctx,
-		pusher: t.pusher,
-		userID: t.userID,
+	evaluationDelay := t.limits.EvaluationDelay(t.userID)
+	if g, ok := ctx.Value(evaluatedGroupKey).(*rules.Group); ok {
+		evaluationDelay = g.EvaluationDelay()
 	}
+
+	return t.now().Add(-evaluationDelay).Add(-tolerance).UnixMilli()
 }
 
 // RulesLimits defines limits used by Ruler.
 type RulesLimits interface {
 	EvaluationDelay(userID string) time.Duration
+	RulerEvaluationInterval(userID string) time.Duration
 	RulerTenantShardSize(userID string) int
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
+	RulerAllowedSourceTenants(userID string) []string
+	RulerMaxNotificationsPerSecond(userID string) float64
+	RulerMaxConcurrentEvaluations(userID string) int
+	RulerOutOfOrderTolerance(userID string) time.Duration
+	RulerEvaluationTimeout(userID string) time.Duration
 }
 
 func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Counter) rules.QueryFunc {
@@ -203,6 +252,122 @@ func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Co
 	}
 }
 
+const (
+	groupEvaluationJitterKey contextKey = 2
+	evaluatedGroupKey        contextKey = 3
+)
+
+// EvaluatedGroupContextFunc injects in the context the rule group being evaluated, so that
+// the rule group evaluation delay is honored when writing the evaluation results.
+func EvaluatedGroupContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	return context.WithValue(ctx, evaluatedGroupKey, g)
+}
+
+type groupEvaluationJitter struct {
+	group  *rules.Group
+	offset time.Duration
+}
+
+// EvaluationJitterGroupContextFunc returns a rules.ContextWrapFunc injecting in the context the offset
+// by which the evaluation of the rule group should be delayed. The offset is honored by EvaluationJitterQueryFunc.
+func EvaluationJitterGroupContextFunc(userID string, maxJitter time.Duration) rules.ContextWrapFunc {
+	return func(ctx context.Context, g *rules.Group) context.Context {
+		offset := evaluationJitterForGroup(ruleGroupDescFromGroup(userID, g), maxJitter, g.Interval())
+		if offset <= 0 {
+			return ctx
+		}
+		return context.WithValue(ctx, groupEvaluationJitterKey, groupEvaluationJitter{group: g, offset: offset})
+	}
+}
+
+// EvaluationJitterQueryFunc delays the first query of each rule group evaluation by the offset injected
+// in the context by EvaluationJitterGroupContextFunc. The evaluation timestamp is left untouched, so the
+// results (and the alerts "for" state) are the same as if the rule group was evaluated without jitter.
+func EvaluationJitterQueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if jitter, ok := ctx.Value(groupEvaluationJitterKey).(groupEvaluationJitter); ok {
+			// The query timestamp is the group evaluation timestamp shifted back by the evaluation delay.
+			wait := time.Until(t.Add(jitter.group.EvaluationDelay()).Add(jitter.offset))
+			if wait > jitter.offset {
+				wait = jitter.offset
+			}
+
+			// Only the first query of an evaluation waits, the following ones are already past the offset.
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				defer timer.Stop()
+
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}
+
+		return qf(ctx, qs, t)
+	}
+}
+
+// maxEvaluationJitterIntervalRatio is the max ratio of the rule group evaluation interval by which
+// its evaluation can be delayed, so that the delayed evaluation doesn't miss the next one.
+const maxEvaluationJitterIntervalRatio = 0.25
+
+// evaluationJitterForGroup returns the offset by which the evaluation of the input rule group should be delayed.
+// The offset is derived from the group token, so that it's stable across restarts and rulers, and is spread
+// across the max jitter, which is capped to a quarter of the group evaluation interval.
+func evaluationJitterForGroup(g *rulespb.RuleGroupDesc, maxJitter, interval time.Duration) time.Duration {
+	if maxInterval := time.Duration(float64(interval) * maxEvaluationJitterIntervalRatio); interval > 0 && maxJitter > maxInterval {
+		maxJitter = maxInterval
+	}
+	if maxJitter <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(maxJitter) * float64(tokenForGroup(g)) / float64(math.MaxUint32+1))
+}
+
+// jitteredRuleGroup is the subset of the rules.Group API used to exclude the evaluation jitter from its evaluation time.
+type jitteredRuleGroup interface {
+	GetLastEvaluation() time.Time
+	GetEvaluationTime() time.Duration
+	EvalTimestamp(startTime int64) time.Time
+}
+
+// evaluationTimeWithoutJitter returns the duration of the last evaluation of the input rule group, excluding the
+// time its first query waited for the evaluation jitter offset in EvaluationJitterQueryFunc.
+func evaluationTimeWithoutJitter(g jitteredRuleGroup, offset time.Duration) time.Duration {
+	evaluationTime := g.GetEvaluationTime()
+	lastEvaluation := g.GetLastEvaluation()
+	if offset <= 0 || lastEvaluation.IsZero() {
+		return evaluationTime
+	}
+
+	// The first query waits until the offset past the evaluation timestamp, which is the scheduled
+	// evaluation time preceding the evaluation start.
+	waited := g.EvalTimestamp(lastEvaluation.UnixNano()).Add(offset).Sub(lastEvaluation)
+	if waited > offset {
+		waited = offset
+	}
+	if waited <= 0 {
+		return evaluationTime
+	}
+	if waited > evaluationTime {
+		return 0
+	}
+	return evaluationTime - waited
+}
+
+func ruleGroupDescFromGroup(userID string, g *rules.Group) *rulespb.RuleGroupDesc {
+	// The mapped filename is url path escaped encoded to make handling `/` characters easier.
+	namespace := filepath.Base(g.File())
+	if decoded, err := url.PathUnescape(namespace); err == nil {
+		namespace = decoded
+	}
+
+	return &rulespb.RuleGroupDesc{User: userID, Namespace: namespace, Name: g.Name()}
+}
+
 // RulesManager mimics rules.Manager API. Interface is used to simplify tests.
 type RulesManager interface {
 	// Run starts the rules manager. Blocks until Stop is called.
@@ -237,6 +402,10 @@ func DefaultTenantManagerFactory(
 		Name: "cortex_ruler_write_requests_failed_total",
 		Help: "Number of failed write requests to ingesters.",
 	})
+	adjustedSamples := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_out_of_order_adjusted_samples_total",
+		Help: "Number of samples written by rule evaluations whose timestamp has been clamped to the out-of-order tolerance, because the evaluation ran late.",
+	}, []string{"user"})
 
 	totalQueries := promauto.With(reg).NewCounter(prometheus.CounterOpts{
 		Name: "cortex_ruler_queries_total",
@@ -246,6 +415,19 @@ func DefaultTenantManagerFactory(
 		Name: "cortex_ruler_queries_failed_total",
 		Help: "Number of failed queries by ruler.",
 	})
+	droppedNotifications := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_notifications_rate_limited_total",
+		Help: "Number of alert notifications dropped because the tenant exceeded the notifications rate limit.",
+	}, []string{"user"})
+	heldNotifications := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_notifications_held_total",
+		Help: "Number of alert notifications held because the alert was already active before the tenant's rules started being evaluated, and the startup notification hold period hasn't elapsed yet.",
+	}, []string{"user"})
+	timedOutEvaluations := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_ruler_evaluations_timed_out_total",
+		Help: "Number of rule evaluations canceled because they exceeded the evaluation timeout.",
+	}, []string{"user"})
+
 	var rulerQuerySeconds *prometheus.CounterVec
 	if cfg.EnableQueryStats {
 		rulerQuerySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
@@ -263,14 +445,44 @@ func DefaultTenantManagerFactory(
 		wrappedQueryFunc = MetricsQueryFunc(queryFunc, totalQueries, failedQueries)
 		wrappedQueryFunc = RecordAndReportRuleQueryMetrics(wrappedQueryFunc, queryTime, logger)
 
+		// Wrap before the evaluations limiter, so that the time spent waiting doesn't count towards the timeout.
+		wrappedQueryFunc = EvaluationTimeoutQueryFunc(wrappedQueryFunc, userID, overrides, timedOutEvaluations.WithLabelValues(userID))
+
+		// Wrap after the query metrics, so that the time spent waiting isn't tracked as query time.
+		inFlight := promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_rule_evaluations_in_flight",
+			Help: "Number of rule evaluations currently running.",
+		})
+		wrappedQueryFunc = evaluationLimitedQueryFunc(wrappedQueryFunc, newEvaluationLimiter(userID, overrides, inFlight))
+
+		groupEvaluationContextFunc := func(ctx context.Context, g *rules.Group) context.Context {
+			return EvaluatedGroupContextFunc(FederatedGroupContextFunc(ctx, g), g)
+		}
+		if cfg.EvaluationJitter > 0 {
+			jitterContextFunc := EvaluationJitterGroupContextFunc(userID, cfg.EvaluationJitter)
+			groupContextFunc := groupEvaluationContextFunc
+			groupEvaluationContextFunc = func(ctx context.Context, g *rules.Group) context.Context {
+				return jitterContextFunc(groupContextFunc(ctx, g), g)
+			}
+
+			// Wrap last, so that the time spent waiting isn't tracked as query time.
+			wrappedQueryFunc = EvaluationJitterQueryFunc(wrappedQueryFunc)
+		}
+
+		notifyFunc := SendAlerts(newRateLimitedSender(notifier, userID, overrides, droppedNotifications.WithLabelValues(userID)), cfg.ExternalURL.String(), cfg.NotificationDeduplication.KeyAnnotation)
+		if cfg.StartupNotificationHoldPeriod > 0 {
+			// The manager is created when the ruler starts evaluating the tenant's rules.
+			notifyFunc = newStartupNotificationHold(notifyFunc, time.Now(), cfg.StartupNotificationHoldPeriod, heldNotifications.WithLabelValues(userID)).Notify
+		}
+
 		return rules.NewManager(&rules.ManagerOptions{
-			Appendable:                 NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
+			Appendable:                 NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites, adjustedSamples.WithLabelValues(userID)),
 			Queryable:                  embeddedQueryable,
 			QueryFunc:                  wrappedQueryFunc,
 			Context:                    user.InjectOrgID(ctx, userID),
-			GroupEvaluationContextFunc: FederatedGroupContextFunc,
+			GroupEvaluationContextFunc: groupEvaluationContextFunc,
 			ExternalURL:                cfg.ExternalURL.URL,
-			NotifyFunc:                 SendAlerts(notifier, cfg.ExternalURL.String()),
+			NotifyFunc:                 notifyFunc,
 			Logger:                     log.With(logger, "user", userID),
 			Registerer:                 reg,
 			OutageTolerance:            cfg.OutageTolerance,
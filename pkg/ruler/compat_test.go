@@ -10,6 +10,8 @@ import (
 	"errors"
 	"math"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -45,7 +47,7 @@ func (p *fakePusher) Push(ctx context.Context, r *mimirpb.WriteRequest) (*mimirp
 
 func TestPusherAppendable(t *testing.T) {
 	pusher := &fakePusher{}
-	pa := NewPusherAppendable(pusher, "user-1", nil, promauto.With(nil).NewCounter(prometheus.CounterOpts{}), promauto.With(nil).NewCounter(prometheus.CounterOpts{}))
+	pa := NewPusherAppendable(pusher, "user-1", ruleLimits{}, promauto.With(nil).NewCounter(prometheus.CounterOpts{}), promauto.With(nil).NewCounter(prometheus.CounterOpts{}), promauto.With(nil).NewCounter(prometheus.CounterOpts{}))
 
 	for _, tc := range []struct {
 		name       string
@@ -97,6 +99,77 @@ func TestPusherAppendable(t *testing.T) {
 	}
 }
 
+func TestPusherAppendable_OutOfOrderTolerance(t *testing.T) {
+	const evalDelay = time.Minute
+
+	now := time.Now()
+
+	tests := map[string]struct {
+		tolerance           time.Duration
+		groupEvalDelay      time.Duration // Not set if 0.
+		sampleTimestamp     time.Time
+		expectedTimestamp   time.Time
+		expectedAdjustments int
+	}{
+		"should not adjust samples if the tolerance is disabled": {
+			sampleTimestamp:   now.Add(-time.Hour),
+			expectedTimestamp: now.Add(-time.Hour),
+		},
+		"should not adjust samples within the tolerance": {
+			tolerance:         5 * time.Minute,
+			sampleTimestamp:   now.Add(-evalDelay).Add(-4 * time.Minute),
+			expectedTimestamp: now.Add(-evalDelay).Add(-4 * time.Minute),
+		},
+		"should clamp the timestamp of samples written by a late evaluation": {
+			tolerance:           5 * time.Minute,
+			sampleTimestamp:     now.Add(-time.Hour),
+			expectedTimestamp:   now.Add(-evalDelay).Add(-5 * time.Minute),
+			expectedAdjustments: 1,
+		},
+		"should honor the evaluation delay of the rule group": {
+			tolerance:         5 * time.Minute,
+			groupEvalDelay:    time.Hour,
+			sampleTimestamp:   now.Add(-time.Hour).Add(-4 * time.Minute),
+			expectedTimestamp: now.Add(-time.Hour).Add(-4 * time.Minute),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			pusher := &fakePusher{response: &mimirpb.WriteResponse{}}
+			adjusted := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+
+			limits := ruleLimits{evalDelay: evalDelay, outOfOrderTolerance: testData.tolerance}
+			pa := NewPusherAppendable(pusher, "user-1", limits, promauto.With(nil).NewCounter(prometheus.CounterOpts{}), promauto.With(nil).NewCounter(prometheus.CounterOpts{}), adjusted)
+			pa.now = func() time.Time { return now }
+
+			ctx := context.Background()
+			if testData.groupEvalDelay > 0 {
+				g := rules.NewGroup(rules.GroupOptions{
+					Name:            "group",
+					File:            "namespace",
+					Interval:        time.Minute,
+					EvaluationDelay: &testData.groupEvalDelay,
+					Opts:            &rules.ManagerOptions{},
+				})
+				ctx = EvaluatedGroupContextFunc(ctx, g)
+			}
+
+			lbls, err := parser.ParseMetric("foo_bar")
+			require.NoError(t, err)
+
+			// Simulate the evaluation of a recording rule running late.
+			a := pa.Appender(ctx)
+			_, err = a.Append(0, lbls, testData.sampleTimestamp.UnixMilli(), 1)
+			require.NoError(t, err)
+			require.NoError(t, a.Commit())
+
+			require.Equal(t, testData.expectedTimestamp.UnixMilli(), pusher.request.Timeseries[0].Samples[0].TimestampMs)
+			require.Equal(t, testData.expectedAdjustments, int(testutil.ToFloat64(adjusted)))
+		})
+	}
+}
+
 func TestPusherErrors(t *testing.T) {
 	for name, tc := range map[string]struct {
 		returnedError    error
@@ -134,7 +207,7 @@ func TestPusherErrors(t *testing.T) {
 			writes := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
 			failures := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
 
-			pa := NewPusherAppendable(pusher, "user-1", ruleLimits{evalDelay: 10 * time.Second}, writes, failures)
+			pa := NewPusherAppendable(pusher, "user-1", ruleLimits{evalDelay: 10 * time.Second}, writes, failures, promauto.With(nil).NewCounter(prometheus.CounterOpts{}))
 
 			lbls, err := parser.ParseMetric("foo_bar")
 			require.NoError(t, err)
@@ -250,6 +323,133 @@ func TestRecordAndReportRuleQueryMetrics(t *testing.T) {
 	require.GreaterOrEqual(t, testutil.ToFloat64(queryTime.WithLabelValues("userID")), float64(1))
 }
 
+func TestEvaluationJitterForGroup(t *testing.T) {
+	group1 := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-1"}
+	group2 := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-2"}
+
+	offset1 := evaluationJitterForGroup(group1, time.Minute, time.Minute)
+	offset2 := evaluationJitterForGroup(group2, time.Minute, time.Minute)
+
+	// Offsets should be different for different groups and within the max jitter.
+	require.NotEqual(t, offset1, offset2)
+	for _, offset := range []time.Duration{offset1, offset2} {
+		require.GreaterOrEqual(t, offset, time.Duration(0))
+		require.Less(t, offset, time.Minute)
+	}
+
+	// Offsets should be stable.
+	require.Equal(t, offset1, evaluationJitterForGroup(&rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-1"}, time.Minute, time.Minute))
+	require.Equal(t, offset2, evaluationJitterForGroup(&rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-2"}, time.Minute, time.Minute))
+
+	// The max jitter should be capped to a quarter of the evaluation interval.
+	require.Less(t, evaluationJitterForGroup(group1, time.Hour, time.Minute), 15*time.Second)
+	require.Less(t, evaluationJitterForGroup(group2, time.Hour, time.Minute), 15*time.Second)
+
+	// The jitter should be disabled if the max jitter is 0.
+	require.Equal(t, time.Duration(0), evaluationJitterForGroup(group1, 0, time.Minute))
+}
+
+func TestEvaluationJitterQueryFunc(t *testing.T) {
+	const (
+		userID    = "user-1"
+		maxJitter = 500 * time.Millisecond
+	)
+
+	group := rules.NewGroup(rules.GroupOptions{
+		Name:     "group-1",
+		File:     filepath.Join(t.TempDir(), userID, url.PathEscape("namespace/1")),
+		Interval: time.Minute,
+		Opts:     &rules.ManagerOptions{Logger: log.NewNopLogger()},
+	})
+
+	expectedOffset := evaluationJitterForGroup(&rulespb.RuleGroupDesc{User: userID, Namespace: "namespace/1", Name: "group-1"}, maxJitter, time.Minute)
+	require.Greater(t, expectedOffset, time.Duration(0))
+
+	ctx := EvaluationJitterGroupContextFunc(userID, maxJitter)(context.Background(), group)
+	require.Equal(t, groupEvaluationJitter{group: group, offset: expectedOffset}, ctx.Value(groupEvaluationJitterKey))
+
+	var queryTimes []time.Time
+	qf := EvaluationJitterQueryFunc(func(ctx context.Context, q string, ts time.Time) (promql.Vector, error) {
+		queryTimes = append(queryTimes, time.Now())
+		return promql.Vector{}, nil
+	})
+
+	// The first query of the evaluation should be delayed by the offset.
+	evalTime := time.Now()
+	_, err := qf(ctx, "test", evalTime)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, queryTimes[0].Sub(evalTime), expectedOffset)
+
+	// The following queries of the same evaluation are already past the offset.
+	_, err = qf(ctx, "test", evalTime)
+	require.NoError(t, err)
+	require.Less(t, queryTimes[1].Sub(queryTimes[0]), expectedOffset)
+
+	// The query should not be delayed if the group has no offset.
+	start := time.Now()
+	_, err = qf(context.Background(), "test", start)
+	require.NoError(t, err)
+	require.Less(t, queryTimes[2].Sub(start), maxJitter)
+}
+
+type jitteredRuleGroupMock struct {
+	interval       time.Duration
+	lastEvaluation time.Time
+	evaluationTime time.Duration
+}
+
+func (g *jitteredRuleGroupMock) GetLastEvaluation() time.Time     { return g.lastEvaluation }
+func (g *jitteredRuleGroupMock) GetEvaluationTime() time.Duration { return g.evaluationTime }
+func (g *jitteredRuleGroupMock) EvalTimestamp(startTime int64) time.Time {
+	return time.Unix(0, startTime-startTime%int64(g.interval))
+}
+
+func TestEvaluationTimeWithoutJitter(t *testing.T) {
+	scheduledAt := time.Unix(0, 0).Add(time.Hour)
+
+	tests := map[string]struct {
+		group    *jitteredRuleGroupMock
+		offset   time.Duration
+		expected time.Duration
+	}{
+		"should exclude the offset if the evaluation started when scheduled": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute, lastEvaluation: scheduledAt, evaluationTime: 15 * time.Second},
+			offset:   10 * time.Second,
+			expected: 5 * time.Second,
+		},
+		"should exclude only the remaining offset if the evaluation started after being scheduled": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute, lastEvaluation: scheduledAt.Add(4 * time.Second), evaluationTime: 15 * time.Second},
+			offset:   10 * time.Second,
+			expected: 9 * time.Second,
+		},
+		"should not exclude anything if the evaluation started after the offset": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute, lastEvaluation: scheduledAt.Add(20 * time.Second), evaluationTime: 15 * time.Second},
+			offset:   10 * time.Second,
+			expected: 15 * time.Second,
+		},
+		"should not return a negative evaluation time": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute, lastEvaluation: scheduledAt, evaluationTime: time.Second},
+			offset:   10 * time.Second,
+			expected: 0,
+		},
+		"should not exclude anything if the group has no offset": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute, lastEvaluation: scheduledAt, evaluationTime: 15 * time.Second},
+			expected: 15 * time.Second,
+		},
+		"should not exclude anything if the group has not been evaluated yet": {
+			group:    &jitteredRuleGroupMock{interval: time.Minute},
+			offset:   10 * time.Second,
+			expected: 0,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			require.Equal(t, testData.expected, evaluationTimeWithoutJitter(testData.group, testData.offset))
+		})
+	}
+}
+
 // TestManagerFactory_CorrectQueryableUsed ensures that when evaluating a group with non-empty SourceTenants
 // the federated queryable is called. If SourceTenants are empty, then the regular queryable should be used.
 // This is to ensure that the `__tenant_id__` label is present for all rules evaluating within a federated rule group.
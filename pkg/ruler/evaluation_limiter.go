@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// evaluationLimiter limits the number of rule evaluations of a tenant running concurrently,
+// so that a tenant with many rule groups can't monopolize the query path of the ruler.
+type evaluationLimiter struct {
+	userID   string
+	limits   RulesLimits
+	inFlight prometheus.Gauge
+
+	mtx     sync.Mutex
+	running int
+	// Closed, and replaced, whenever an evaluation completes, to wake up the waiting ones.
+	released chan struct{}
+}
+
+func newEvaluationLimiter(userID string, limits RulesLimits, inFlight prometheus.Gauge) *evaluationLimiter {
+	return &evaluationLimiter{
+		userID:   userID,
+		limits:   limits,
+		inFlight: inFlight,
+		released: make(chan struct{}),
+	}
+}
+
+// Acquire waits until the number of evaluations running is below the tenant's limit, or the context
+// is done. The limit is read at each attempt, so that changes to it are applied without restarting.
+func (l *evaluationLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mtx.Lock()
+		if limit := l.limits.RulerMaxConcurrentEvaluations(l.userID); limit <= 0 || l.running < limit {
+			l.running++
+			l.mtx.Unlock()
+			l.inFlight.Inc()
+			return nil
+		}
+		released := l.released
+		l.mtx.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// Release marks an evaluation started by a successful Acquire as completed.
+func (l *evaluationLimiter) Release() {
+	l.mtx.Lock()
+	l.running--
+	close(l.released)
+	l.released = make(chan struct{})
+	l.mtx.Unlock()
+	l.inFlight.Dec()
+}
+
+// evaluationLimitedQueryFunc bounds the number of queries running concurrently with the limiter.
+// Rules in the same group are evaluated sequentially, so this bounds the number of rule groups
+// evaluating concurrently too.
+func evaluationLimitedQueryFunc(qf rules.QueryFunc, l *evaluationLimiter) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if err := l.Acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer l.Release()
+
+		return qf(ctx, qs, t)
+	}
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestEvaluationLimitedQueryFunc(t *testing.T) {
+	const numEvaluations = 10
+
+	for name, tc := range map[string]struct {
+		limit               int
+		expectedMaxInFlight int
+	}{
+		"should limit the concurrent evaluations": {
+			limit:               3,
+			expectedMaxInFlight: 3,
+		},
+		"should not limit the concurrent evaluations if the limit is 0": {
+			limit:               0,
+			expectedMaxInFlight: numEvaluations,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				running     = atomic.NewInt32(0)
+				maxRunning  = atomic.NewInt32(0)
+				unblock     = make(chan struct{})
+				inFlight    = prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight"})
+				limiter     = newEvaluationLimiter("user-1", ruleLimits{maxConcurrentEvals: tc.limit}, inFlight)
+				wg          sync.WaitGroup
+				blockedFunc = func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+					current := running.Inc()
+					for {
+						prev := maxRunning.Load()
+						if current <= prev || maxRunning.CAS(prev, current) {
+							break
+						}
+					}
+					<-unblock
+					running.Dec()
+					return nil, nil
+				}
+			)
+			qf := evaluationLimitedQueryFunc(blockedFunc, limiter)
+
+			for i := 0; i < numEvaluations; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := qf(context.Background(), "up", time.Now())
+					assert.NoError(t, err)
+				}()
+			}
+
+			// Wait until the evaluations allowed by the limit are running.
+			require.Eventually(t, func() bool {
+				return running.Load() == int32(tc.expectedMaxInFlight)
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, float64(tc.expectedMaxInFlight), testutil.ToFloat64(inFlight))
+
+			// Give the waiting evaluations the chance to exceed the limit, if they could.
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, int32(tc.expectedMaxInFlight), running.Load())
+
+			close(unblock)
+			wg.Wait()
+
+			assert.Equal(t, int32(tc.expectedMaxInFlight), maxRunning.Load())
+			assert.Equal(t, float64(0), testutil.ToFloat64(inFlight))
+		})
+	}
+
+	t.Run("should stop waiting when the context is canceled", func(t *testing.T) {
+		inFlight := prometheus.NewGauge(prometheus.GaugeOpts{Name: "in_flight"})
+		limiter := newEvaluationLimiter("user-1", ruleLimits{maxConcurrentEvals: 1}, inFlight)
+		require.NoError(t, limiter.Acquire(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, limiter.Acquire(ctx), context.DeadlineExceeded)
+
+		limiter.Release()
+		require.NoError(t, limiter.Acquire(context.Background()))
+		assert.Equal(t, float64(1), testutil.ToFloat64(inFlight))
+	})
+}
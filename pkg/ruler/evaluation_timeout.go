@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const groupEvaluationTimeoutsKey contextKey = 5
+
+// groupEvaluationTimeoutKey identifies a rule group of a tenant.
+type groupEvaluationTimeoutKey struct {
+	namespace, group string
+}
+
+// groupEvaluationTimeoutFunc returns the evaluation timeout of a rule group of the tenant, and whether
+// the rule group overrides the tenant's evaluation timeout.
+type groupEvaluationTimeoutFunc func(namespace, group string) (time.Duration, bool)
+
+// withGroupEvaluationTimeouts injects in the context the function returning the evaluation timeout of the
+// tenant's rule groups. The context is the one the tenant's rules manager is created with.
+func withGroupEvaluationTimeouts(ctx context.Context, timeouts groupEvaluationTimeoutFunc) context.Context {
+	return context.WithValue(ctx, groupEvaluationTimeoutsKey, timeouts)
+}
+
+// groupEvaluationTimeouts returns the evaluation timeouts of the input rule groups overriding the
+// tenant's one, or nil if no rule group overrides it.
+func groupEvaluationTimeouts(groups rulespb.RuleGroupList) map[groupEvaluationTimeoutKey]time.Duration {
+	var timeouts map[groupEvaluationTimeoutKey]time.Duration
+	for _, g := range groups {
+		timeout := g.GetEvaluationTimeout()
+		if timeout == nil {
+			continue
+		}
+
+		if timeouts == nil {
+			timeouts = map[groupEvaluationTimeoutKey]time.Duration{}
+		}
+		timeouts[groupEvaluationTimeoutKey{namespace: g.Namespace, group: g.Name}] = *timeout
+	}
+	return timeouts
+}
+
+// EvaluationTimeoutQueryFunc returns a promRules.QueryFunc canceling the query of each rule when it runs
+// longer than the evaluation timeout of its rule group, or the tenant's one if the rule group doesn't
+// override it. The rule evaluation fails, while the other rules of the rule group are still evaluated.
+func EvaluationTimeoutQueryFunc(qf promRules.QueryFunc, userID string, limits RulesLimits, timedOut prometheus.Counter) promRules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		timeout := limits.RulerEvaluationTimeout(userID)
+		if g, ok := ctx.Value(evaluatedGroupKey).(*promRules.Group); ok {
+			if timeouts, ok := ctx.Value(groupEvaluationTimeoutsKey).(groupEvaluationTimeoutFunc); ok {
+				desc := ruleGroupDescFromGroup(userID, g)
+				if groupTimeout, ok := timeouts(desc.Namespace, desc.Name); ok {
+					timeout = groupTimeout
+				}
+			}
+		}
+
+		if timeout <= 0 {
+			return qf(ctx, qs, t)
+		}
+
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		result, err := qf(queryCtx, qs, t)
+		if err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			timedOut.Inc()
+			return nil, fmt.Errorf("the rule evaluation exceeded the timeout of %s: %w", timeout, err)
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// slowQueryable is a queryable whose queries of the slow_metric series don't return until they're canceled.
+type slowQueryable struct{}
+
+func (slowQueryable) Querier(ctx context.Context, _, _ int64) (storage.Querier, error) {
+	return slowQuerier{ctx: ctx}, nil
+}
+
+type slowQuerier struct {
+	storage.Querier
+	ctx context.Context
+}
+
+func (q slowQuerier) Select(_ bool, _ *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	for _, m := range matchers {
+		if m.Name == labels.MetricName && m.Matches("slow_metric") {
+			<-q.ctx.Done()
+			return storage.ErrSeriesSet(q.ctx.Err())
+		}
+	}
+	return storage.EmptySeriesSet()
+}
+
+func (slowQuerier) Close() error {
+	return nil
+}
+
+func TestEvaluationTimeoutQueryFunc(t *testing.T) {
+	slowQueryFunc := func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	t.Run("should not cancel the query if the timeout is disabled", func(t *testing.T) {
+		timedOut := prometheus.NewCounter(prometheus.CounterOpts{})
+		qf := EvaluationTimeoutQueryFunc(func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+			_, hasDeadline := ctx.Deadline()
+			assert.False(t, hasDeadline)
+			return promql.Vector{}, nil
+		}, "user-1", ruleLimits{}, timedOut)
+
+		_, err := qf(context.Background(), "up", time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, float64(0), testutil.ToFloat64(timedOut))
+	})
+
+	t.Run("should cancel the query exceeding the timeout", func(t *testing.T) {
+		timedOut := prometheus.NewCounter(prometheus.CounterOpts{})
+		qf := EvaluationTimeoutQueryFunc(slowQueryFunc, "user-1", ruleLimits{evalTimeout: 10 * time.Millisecond}, timedOut)
+
+		_, err := qf(context.Background(), "up", time.Now())
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "the rule evaluation exceeded the timeout of 10ms")
+		assert.Equal(t, float64(1), testutil.ToFloat64(timedOut))
+	})
+
+	t.Run("should not track the query as timed out if the evaluation has been canceled", func(t *testing.T) {
+		timedOut := prometheus.NewCounter(prometheus.CounterOpts{})
+		qf := EvaluationTimeoutQueryFunc(slowQueryFunc, "user-1", ruleLimits{evalTimeout: time.Hour}, timedOut)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := qf(ctx, "up", time.Now())
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, float64(0), testutil.ToFloat64(timedOut))
+	})
+}
+
+func TestDefaultMultiTenantManager_EvaluationTimeout(t *testing.T) {
+	const userID = "user-1"
+
+	tests := map[string]struct {
+		tenantTimeout   time.Duration
+		groupTimeout    *time.Duration
+		expectedTimeout string
+	}{
+		"should cancel the rule evaluation exceeding the tenant's timeout": {
+			tenantTimeout:   100 * time.Millisecond,
+			expectedTimeout: "100ms",
+		},
+		"should cancel the rule evaluation exceeding the rule group's timeout overriding the tenant's one": {
+			tenantTimeout:   time.Hour,
+			groupTimeout:    func() *time.Duration { d := 100 * time.Millisecond; return &d }(),
+			expectedTimeout: "100ms",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := defaultRulerConfig(t)
+			_, _, pusher, logger, _ := testSetup()
+			limits := ruleLimits{evalTimeout: testData.tenantTimeout, maxRuleGroups: 20, maxRulesPerRuleGroup: 15}
+
+			eng := promql.NewEngine(promql.EngineOpts{
+				MaxSamples: 1e6,
+				Timeout:    2 * time.Minute,
+			})
+			queryable := slowQueryable{}
+
+			reg := prometheus.NewPedanticRegistry()
+			managerFactory := DefaultTenantManagerFactory(cfg, pusher, queryable, promRules.EngineQueryFunc(eng, queryable), limits, reg)
+			m, err := NewDefaultMultiTenantManager(cfg, managerFactory, limits, nil, logger, nil)
+			require.NoError(t, err)
+			t.Cleanup(m.Stop)
+
+			group := &rulespb.RuleGroupDesc{
+				Name:      "group-1",
+				Namespace: "namespace",
+				User:      userID,
+				Interval:  time.Second,
+				Rules: []*rulespb.RuleDesc{
+					{Record: "slow:rule", Expr: "slow_metric"},
+					{Record: "fast:rule", Expr: "fast_metric"},
+				},
+			}
+			group.SetEvaluationTimeout(testData.groupTimeout)
+			m.SyncRuleGroups(context.Background(), map[string]rulespb.RuleGroupList{userID: {group}})
+
+			// Wait until both rules have been evaluated.
+			var rules []promRules.Rule
+			test.Poll(t, 5*time.Second, true, func() interface{} {
+				groups := m.GetRules(userID)
+				if len(groups) != 1 {
+					return false
+				}
+				rules = groups[0].Rules()
+				for _, r := range rules {
+					if r.GetEvaluationTimestamp().IsZero() {
+						return false
+					}
+				}
+				return true
+			})
+
+			// The slow rule has failed, while the fast rule of the same rule group has been evaluated successfully.
+			require.Len(t, rules, 2)
+			require.Error(t, rules[0].LastError())
+			assert.Contains(t, rules[0].LastError().Error(), "the rule evaluation exceeded the timeout of "+testData.expectedTimeout)
+			assert.Equal(t, promRules.HealthBad, rules[0].Health())
+			assert.NoError(t, rules[1].LastError())
+			assert.Equal(t, promRules.HealthGood, rules[1].Health())
+
+			// The rule group may have been evaluated more than once in the meanwhile.
+			families, err := reg.Gather()
+			require.NoError(t, err)
+			metrics, err := util.NewMetricFamilyMap(families)
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, metrics.SumCounters("cortex_ruler_evaluations_timed_out_total"), 1.0)
+		})
+	}
+}
+
+func TestGroupEvaluationTimeouts_RoundTrip(t *testing.T) {
+	timeout := 30 * time.Second
+
+	group := &rulespb.RuleGroupDesc{Name: "group-1", Namespace: "namespace", User: "user-1"}
+	group.SetEvaluationTimeout(&timeout)
+
+	// The rule group's timeout is preserved when the rule group is stored and loaded back.
+	formatted := rulespb.FromProtoWithOptions(group)
+	require.NotNil(t, formatted.EvaluationTimeout)
+	assert.Equal(t, "30s", formatted.EvaluationTimeout.String())
+
+	loaded := rulespb.ToProtoWithOptions("user-1", "namespace", formatted)
+	require.NotNil(t, loaded.GetEvaluationTimeout())
+	assert.Equal(t, timeout, *loaded.GetEvaluationTimeout())
+
+	assert.Equal(t, map[groupEvaluationTimeoutKey]time.Duration{{namespace: "namespace", group: "group-1"}: timeout}, groupEvaluationTimeouts(rulespb.RuleGroupList{
+		loaded,
+		{Name: "group-2", Namespace: "namespace", User: "user-1"},
+	}))
+
+	// A rule group without timeout doesn't override the tenant's one.
+	assert.Nil(t, rulespb.FromProtoWithOptions(&rulespb.RuleGroupDesc{Name: "group-2"}).EvaluationTimeout)
+}
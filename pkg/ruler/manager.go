@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -17,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
@@ -32,6 +34,7 @@ type DefaultMultiTenantManager struct {
 	cfg            Config
 	notifierCfg    *config.Config
 	managerFactory ManagerFactory
+	limits         RulesLimits
 
 	mapper *mapper
 
@@ -39,6 +42,18 @@ type DefaultMultiTenantManager struct {
 	userManagerMtx sync.RWMutex
 	userManagers   map[string]RulesManager
 
+	// Per-user evaluation interval the Prometheus rules Managers have been last updated with.
+	// Protected by userManagerMtx.
+	userEvaluationIntervals map[string]time.Duration
+
+	// Per-user alert states handed off by other rulers, to be restored on the next evaluation of the rule groups.
+	handedOffAlertStatesMtx sync.Mutex
+	handedOffAlertStates    map[string]map[alertStateKey]*GroupStateDesc
+
+	// Per-user evaluation timeouts of the rule groups overriding the tenant's evaluation timeout.
+	groupEvaluationTimeoutsMtx sync.RWMutex
+	groupEvaluationTimeouts    map[string]map[groupEvaluationTimeoutKey]time.Duration
+
 	// Prometheus rules managers metrics.
 	userManagerMetrics *ManagerMetrics
 
@@ -46,6 +61,9 @@ type DefaultMultiTenantManager struct {
 	notifiersMtx sync.Mutex
 	notifiers    map[string]*rulerNotifier
 
+	// Health of the Alertmanagers, shared by all notifiers. Nil if the health tracking is disabled.
+	alertmanagerHealth *alertmanagerHealthTracker
+
 	managersTotal                 prometheus.Gauge
 	lastReloadSuccessful          *prometheus.GaugeVec
 	lastReloadSuccessfulTimestamp *prometheus.GaugeVec
@@ -54,7 +72,7 @@ type DefaultMultiTenantManager struct {
 	logger                        log.Logger
 }
 
-func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
+func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, limits RulesLimits, reg prometheus.Registerer, logger log.Logger, dnsResolver cacheutil.AddressProvider) (*DefaultMultiTenantManager, error) {
 	ncfg, err := buildNotifierConfig(&cfg, dnsResolver)
 	if err != nil {
 		return nil, err
@@ -65,14 +83,27 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 		reg.MustRegister(userManagerMetrics)
 	}
 
+	var alertmanagerHealth *alertmanagerHealthTracker
+	if cfg.AlertmanagerHealthTrackingEnabled {
+		alertmanagerHealth = newAlertmanagerHealthTracker(cfg.AlertmanagerUnhealthyBackoff)
+		if reg != nil {
+			reg.MustRegister(alertmanagerHealth)
+		}
+	}
+
 	return &DefaultMultiTenantManager{
-		cfg:                cfg,
-		notifierCfg:        ncfg,
-		managerFactory:     managerFactory,
-		notifiers:          map[string]*rulerNotifier{},
-		mapper:             newMapper(cfg.RulePath, logger),
-		userManagers:       map[string]RulesManager{},
-		userManagerMetrics: userManagerMetrics,
+		cfg:                     cfg,
+		notifierCfg:             ncfg,
+		managerFactory:          managerFactory,
+		limits:                  limits,
+		notifiers:               map[string]*rulerNotifier{},
+		alertmanagerHealth:      alertmanagerHealth,
+		mapper:                  newMapper(cfg.RulePath, logger),
+		userManagers:            map[string]RulesManager{},
+		userEvaluationIntervals: map[string]time.Duration{},
+		handedOffAlertStates:    map[string]map[alertStateKey]*GroupStateDesc{},
+		groupEvaluationTimeouts: map[string]map[groupEvaluationTimeoutKey]time.Duration{},
+		userManagerMetrics:      userManagerMetrics,
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Namespace: "cortex",
 			Name:      "ruler_managers_total",
@@ -101,9 +132,13 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGroups map[string]rulespb.RuleGroupList) {
 	if !r.cfg.TenantFederation.Enabled {
 		RemoveFederatedRuleGroups(ruleGroups)
+	} else {
+		RemoveDisallowedFederatedRuleGroups(ruleGroups, r.limits, r.logger)
 	}
+	removeDisabledRuleGroups(ruleGroups)
 
 	for userID, ruleGroup := range ruleGroups {
+		r.setGroupEvaluationTimeouts(userID, ruleGroup)
 		r.syncRulesToManager(ctx, userID, ruleGroup)
 	}
 
@@ -115,6 +150,15 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 		if _, exists := ruleGroups[userID]; !exists {
 			go mngr.Stop()
 			delete(r.userManagers, userID)
+			delete(r.userEvaluationIntervals, userID)
+
+			r.handedOffAlertStatesMtx.Lock()
+			delete(r.handedOffAlertStates, userID)
+			r.handedOffAlertStatesMtx.Unlock()
+
+			r.groupEvaluationTimeoutsMtx.Lock()
+			delete(r.groupEvaluationTimeouts, userID)
+			r.groupEvaluationTimeoutsMtx.Unlock()
 
 			r.mapper.cleanupUser(userID)
 			r.lastReloadSuccessful.DeleteLabelValues(userID)
@@ -128,6 +172,46 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 	r.managersTotal.Set(float64(len(r.userManagers)))
 }
 
+// setGroupEvaluationTimeouts updates the evaluation timeouts of the user's rule groups, which are looked up
+// on each rule evaluation so that they're applied without having to recreate the user's manager.
+func (r *DefaultMultiTenantManager) setGroupEvaluationTimeouts(user string, groups rulespb.RuleGroupList) {
+	timeouts := groupEvaluationTimeouts(groups)
+
+	r.groupEvaluationTimeoutsMtx.Lock()
+	defer r.groupEvaluationTimeoutsMtx.Unlock()
+
+	if len(timeouts) == 0 {
+		delete(r.groupEvaluationTimeouts, user)
+		return
+	}
+	r.groupEvaluationTimeouts[user] = timeouts
+}
+
+// groupEvaluationTimeout returns the evaluation timeout of the user's rule group, and whether the rule group
+// overrides the tenant's evaluation timeout.
+func (r *DefaultMultiTenantManager) groupEvaluationTimeout(user, namespace, group string) (time.Duration, bool) {
+	r.groupEvaluationTimeoutsMtx.RLock()
+	defer r.groupEvaluationTimeoutsMtx.RUnlock()
+
+	timeout, ok := r.groupEvaluationTimeouts[user][groupEvaluationTimeoutKey{namespace: namespace, group: group}]
+	return timeout, ok
+}
+
+// removeDisabledRuleGroups removes the disabled rule groups, so that they're not evaluated.
+// Disabled rule groups are still owned by the ruler, so they're listed by the ruler.
+func removeDisabledRuleGroups(groups map[string]rulespb.RuleGroupList) {
+	for userID, groupList := range groups {
+		amended := make(rulespb.RuleGroupList, 0, len(groupList))
+		for _, group := range groupList {
+			if group.IsDisabled() {
+				continue
+			}
+			amended = append(amended, group)
+		}
+		groups[userID] = amended
+	}
+}
+
 // syncRulesToManager maps the rule files to disk, detects any changes and will create/update
 // the user's Prometheus Rules Manager. Since this method writes to disk it is not safe to call
 // concurrently for the same user.
@@ -148,26 +232,48 @@ func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user
 		return
 	}
 
-	// We need to update the manager only if it was just created or rules on disk have changed.
-	if !(created || update) {
+	// The tenant's evaluation interval can be changed at runtime, so we need to update the manager
+	// whenever it differs from the one the manager has been last updated with.
+	interval := r.evaluationInterval(user)
+
+	r.userManagerMtx.RLock()
+	lastInterval, ok := r.userEvaluationIntervals[user]
+	r.userManagerMtx.RUnlock()
+	intervalChanged := !ok || lastInterval != interval
+
+	// We need to update the manager only if it was just created, rules on disk or the evaluation interval have changed.
+	if !(created || update || intervalChanged) {
 		level.Debug(r.logger).Log("msg", "rules have not changed, skipping rule manager update", "user", user)
 		return
 	}
 
-	level.Debug(r.logger).Log("msg", "updating rules", "user", user)
+	level.Debug(r.logger).Log("msg", "updating rules", "user", user, "evaluation_interval", interval)
 	r.configUpdatesTotal.WithLabelValues(user).Inc()
 
-	err = manager.Update(r.cfg.EvaluationInterval, files, nil, r.cfg.ExternalURL.String(), nil)
+	err = manager.Update(interval, files, nil, r.cfg.ExternalURL.String(), r.restoreAlertStatesFunc(user))
 	if err != nil {
 		r.lastReloadSuccessful.WithLabelValues(user).Set(0)
 		level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 		return
 	}
 
+	r.userManagerMtx.Lock()
+	r.userEvaluationIntervals[user] = interval
+	r.userManagerMtx.Unlock()
+
 	r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 	r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
 }
 
+// evaluationInterval returns the interval at which the user's rule groups not specifying their
+// own interval are evaluated.
+func (r *DefaultMultiTenantManager) evaluationInterval(user string) time.Duration {
+	if interval := r.limits.RulerEvaluationInterval(user); interval > 0 {
+		return interval
+	}
+	return r.cfg.EvaluationInterval
+}
+
 // getOrCreateManager retrieves the user manager. If it doesn't exist, it will create and start it first.
 func (r *DefaultMultiTenantManager) getOrCreateManager(ctx context.Context, user string) (RulesManager, bool, error) {
 	// Check if it already exists. Since rules are synched frequently, we expect to already exist
@@ -216,6 +322,10 @@ func (r *DefaultMultiTenantManager) newManager(ctx context.Context, userID strin
 	reg := prometheus.NewRegistry()
 	r.userManagerMetrics.AddUserRegistry(userID, reg)
 
+	ctx = withGroupEvaluationTimeouts(ctx, func(namespace, group string) (time.Duration, bool) {
+		return r.groupEvaluationTimeout(userID, namespace, group)
+	})
+
 	return r.managerFactory(ctx, userID, notifier, r.logger, reg), nil
 }
 
@@ -230,24 +340,29 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 
 	reg := prometheus.WrapRegistererWith(prometheus.Labels{"user": userID}, r.registry)
 	reg = prometheus.WrapRegistererWithPrefix("cortex_", reg)
+	var do notifierDoFunc = func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+		// Note: The passed-in context comes from the Prometheus notifier
+		// and does *not* contain the userID. So it needs to be added to the context
+		// here before using the context to inject the userID into the HTTP request.
+		ctx = user.InjectOrgID(ctx, userID)
+		if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
+			return nil, err
+		}
+		// Jaeger complains the passed-in context has an invalid span ID, so start a new root span
+		sp := ot.GlobalTracer().StartSpan("notify", ot.Tag{Key: "organization", Value: userID})
+		defer sp.Finish()
+		ctx = ot.ContextWithSpan(ctx, sp)
+		_ = ot.GlobalTracer().Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(req.Header))
+		return ctxhttp.Do(ctx, client, req)
+	}
+	if r.alertmanagerHealth != nil {
+		do = r.alertmanagerHealth.wrap(do)
+	}
+
 	n = newRulerNotifier(&notifier.Options{
 		QueueCapacity: r.cfg.NotificationQueueCapacity,
 		Registerer:    reg,
-		Do: func(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
-			// Note: The passed-in context comes from the Prometheus notifier
-			// and does *not* contain the userID. So it needs to be added to the context
-			// here before using the context to inject the userID into the HTTP request.
-			ctx = user.InjectOrgID(ctx, userID)
-			if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
-				return nil, err
-			}
-			// Jaeger complains the passed-in context has an invalid span ID, so start a new root span
-			sp := ot.GlobalTracer().StartSpan("notify", ot.Tag{Key: "organization", Value: userID})
-			defer sp.Finish()
-			ctx = ot.ContextWithSpan(ctx, sp)
-			_ = ot.GlobalTracer().Inject(sp.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(req.Header))
-			return ctxhttp.Do(ctx, client, req)
-		},
+		Do:            do,
 	}, log.With(r.logger, "user", userID))
 
 	n.run()
@@ -272,6 +387,46 @@ func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	return nil
 }
 
+// RestoreAlertStates implements MultiTenantManager.
+func (r *DefaultMultiTenantManager) RestoreAlertStates(userID string, groups []*GroupStateDesc) {
+	r.handedOffAlertStatesMtx.Lock()
+	defer r.handedOffAlertStatesMtx.Unlock()
+
+	states, ok := r.handedOffAlertStates[userID]
+	if !ok {
+		states = map[alertStateKey]*GroupStateDesc{}
+		r.handedOffAlertStates[userID] = states
+	}
+
+	for _, g := range groups {
+		states[alertStateKey{namespace: g.Group.Namespace, group: g.Group.Name}] = g
+	}
+}
+
+// restoreAlertStatesFunc returns a rules.RuleGroupPostProcessFunc restoring, before the next evaluation
+// of a rule group, the alert states handed off by other rulers. Returns nil if the handoff is disabled.
+func (r *DefaultMultiTenantManager) restoreAlertStatesFunc(userID string) promRules.RuleGroupPostProcessFunc {
+	if !r.cfg.AlertStateHandoff.Enabled {
+		return nil
+	}
+
+	return func(g *promRules.Group, _ time.Time, logger log.Logger) error {
+		desc := ruleGroupDescFromGroup(userID, g)
+		key := alertStateKey{namespace: desc.Namespace, group: desc.Name}
+
+		r.handedOffAlertStatesMtx.Lock()
+		state, ok := r.handedOffAlertStates[userID][key]
+		delete(r.handedOffAlertStates[userID], key)
+		r.handedOffAlertStatesMtx.Unlock()
+
+		if ok {
+			restored := restoreAlertStates(g, state)
+			level.Info(logger).Log("msg", "restored alert states handed off by another ruler", "alerts", restored)
+		}
+		return nil
+	}
+}
+
 func (r *DefaultMultiTenantManager) Stop() {
 	r.notifiersMtx.Lock()
 	for _, n := range r.notifiers {
@@ -331,3 +486,16 @@ func (*DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error
 
 	return errs
 }
+
+// validateRuleGroupLabels validates the labels added to all the rules of a rule group.
+func validateRuleGroupLabels(group string, lbls map[string]string) error {
+	for name := range lbls {
+		if name == model.MetricNameLabel {
+			return fmt.Errorf("invalid rules config: rule group '%s' labels must not set the metric name", group)
+		}
+		if !model.LabelName(name).IsValid() {
+			return fmt.Errorf("invalid rules config: rule group '%s' has invalid label name: %s", group, name)
+		}
+	}
+	return nil
+}
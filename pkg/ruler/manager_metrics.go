@@ -27,6 +27,7 @@ type ManagerMetrics struct {
 	GroupLastDuration    *prometheus.Desc
 	GroupRules           *prometheus.Desc
 	GroupLastEvalSamples *prometheus.Desc
+	EvaluationsInFlight  *prometheus.Desc
 }
 
 // NewManagerMetrics returns a ManagerMetrics struct
@@ -100,6 +101,12 @@ func NewManagerMetrics() *ManagerMetrics {
 			[]string{"user", "rule_group"},
 			nil,
 		),
+		EvaluationsInFlight: prometheus.NewDesc(
+			"cortex_ruler_rule_evaluations_in_flight",
+			"Number of rule evaluations currently running.",
+			[]string{"user"},
+			nil,
+		),
 	}
 }
 
@@ -126,6 +133,7 @@ func (m *ManagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.GroupLastDuration
 	out <- m.GroupRules
 	out <- m.GroupLastEvalSamples
+	out <- m.EvaluationsInFlight
 }
 
 // Collect implements the Collector interface
@@ -148,4 +156,5 @@ func (m *ManagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastDuration, "prometheus_rule_group_last_duration_seconds", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupRules, "prometheus_rule_group_rules", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastEvalSamples, "prometheus_rule_group_last_evaluation_samples", "rule_group")
+	data.SendSumOfGaugesPerUser(out, m.EvaluationsInFlight, "cortex_ruler_rule_evaluations_in_flight")
 }
@@ -26,7 +26,7 @@ import (
 func TestSyncRuleGroups(t *testing.T) {
 	dir := t.TempDir()
 
-	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, nil, log.NewNopLogger(), nil)
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: dir}, factory, ruleLimits{}, nil, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	const user = "testUser"
@@ -98,6 +98,47 @@ func TestSyncRuleGroups(t *testing.T) {
 	})
 }
 
+func TestSyncRuleGroups_ShouldHonorTenantEvaluationInterval(t *testing.T) {
+	const user = "testUser"
+
+	limits := &ruleLimits{}
+	m, err := NewDefaultMultiTenantManager(Config{RulePath: t.TempDir(), EvaluationInterval: time.Minute}, factory, limits, nil, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(m.Stop)
+
+	userRules := map[string]rulespb.RuleGroupList{
+		user: {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "ns",
+				User:      user,
+			},
+		},
+	}
+
+	// The manager should be updated with the default evaluation interval when the limit is not set.
+	m.SyncRuleGroups(context.Background(), userRules)
+	mgr := getManager(m, user).(*mockRulesManager)
+	require.Equal(t, []time.Duration{time.Minute}, mgr.updatedIntervals)
+
+	// The manager should not be updated if neither the rules nor the evaluation interval have changed.
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, []time.Duration{time.Minute}, mgr.updatedIntervals)
+
+	// The manager should be updated when the tenant's evaluation interval changes.
+	limits.evalInterval = 5 * time.Minute
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, []time.Duration{time.Minute, 5 * time.Minute}, mgr.updatedIntervals)
+
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, []time.Duration{time.Minute, 5 * time.Minute}, mgr.updatedIntervals)
+
+	// The manager should fall back to the default evaluation interval when the limit is unset.
+	limits.evalInterval = 0
+	m.SyncRuleGroups(context.Background(), userRules)
+	require.Equal(t, []time.Duration{time.Minute, 5 * time.Minute, time.Minute}, mgr.updatedIntervals)
+}
+
 func getManager(m *DefaultMultiTenantManager, user string) RulesManager {
 	m.userManagerMtx.RLock()
 	defer m.userManagerMtx.RUnlock()
@@ -112,6 +153,9 @@ func factory(_ context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ p
 type mockRulesManager struct {
 	running atomic.Bool
 	done    chan struct{}
+
+	// The evaluation intervals the manager has been updated with.
+	updatedIntervals []time.Duration
 }
 
 func (m *mockRulesManager) Run() {
@@ -125,6 +169,7 @@ func (m *mockRulesManager) Stop() {
 }
 
 func (m *mockRulesManager) Update(interval time.Duration, files []string, externalLabels labels.Labels, externalURL string, ruleGroupPostProcessFunc rules.RuleGroupPostProcessFunc) error {
+	m.updatedIntervals = append(m.updatedIntervals, interval)
 	return nil
 }
 
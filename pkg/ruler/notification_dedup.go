@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"flag"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+const notificationSuppressionKey contextKey = 4
+
+var errInvalidNotificationDedupKeyAnnotation = errors.New("invalid notification deduplication key annotation, the value must be a valid label name")
+
+type NotificationDeduplicationConfig struct {
+	KeyAnnotation        string `yaml:"key_annotation"`
+	SuppressWhenNotOwner bool   `yaml:"suppress_when_not_owner"`
+}
+
+func (cfg *NotificationDeduplicationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.KeyAnnotation, "ruler.notification-deduplication.key-annotation", "", "Name of the annotation attached to the alert notifications, whose value is a key derived from the tenant, the rule group and the alert labels. The key is the same on every ruler, so that the notifications sent by multiple rulers for the same alert, eg. while rule groups are moved between rulers, can be deduplicated. Empty to disable.")
+	f.BoolVar(&cfg.SuppressWhenNotOwner, "ruler.notification-deduplication.suppress-when-not-owner", false, "Don't send the alert notifications of a rule group while the ring shows that another ACTIVE ruler owns it, eg. until the rulers sync their rules after a ring change. The notifications are always sent if the owner of the rule group can't be determined, or when cost-aware sharding is enabled.")
+}
+
+func (cfg *NotificationDeduplicationConfig) Validate() error {
+	if cfg.KeyAnnotation != "" && !model.LabelName(cfg.KeyAnnotation).IsValid() {
+		return errInvalidNotificationDedupKeyAnnotation
+	}
+	return nil
+}
+
+// notificationDedupKey returns the key of the notifications of an alert of the input rule group.
+// The key is derived from the tenant, the rule group and the alert labels, so that it's stable
+// across rulers and restarts.
+func notificationDedupKey(g *rulespb.RuleGroupDesc, alertLabels labels.Labels) string {
+	hasher := fnv.New64a()
+
+	// Hasher never returns err.
+	_, _ = hasher.Write([]byte(g.User))
+	_, _ = hasher.Write(sep)
+	_, _ = hasher.Write([]byte(g.Namespace))
+	_, _ = hasher.Write(sep)
+	_, _ = hasher.Write([]byte(g.Name))
+	for _, l := range alertLabels {
+		_, _ = hasher.Write(sep)
+		_, _ = hasher.Write([]byte(l.Name))
+		_, _ = hasher.Write(sep)
+		_, _ = hasher.Write([]byte(l.Value))
+	}
+
+	return strconv.FormatUint(hasher.Sum64(), 16)
+}
+
+// notifiedRuleGroup returns the rule group whose alerts are notified, or nil if it's not in the context.
+func notifiedRuleGroup(ctx context.Context) *rulespb.RuleGroupDesc {
+	g, ok := ctx.Value(evaluatedGroupKey).(*promRules.Group)
+	if !ok {
+		return nil
+	}
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil
+	}
+
+	return ruleGroupDescFromGroup(userID, g)
+}
+
+// suppressNotificationsFunc returns whether the alert notifications of the input rule group should not be sent.
+type suppressNotificationsFunc func(g *rulespb.RuleGroupDesc) bool
+
+// withNotificationSuppression injects in the context the function deciding whether the alert notifications
+// of a rule group should not be sent. The context is the one the tenant's rules managers are created with.
+func withNotificationSuppression(ctx context.Context, suppress suppressNotificationsFunc) context.Context {
+	return context.WithValue(ctx, notificationSuppressionKey, suppress)
+}
+
+func notificationsSuppressed(ctx context.Context, g *rulespb.RuleGroupDesc) bool {
+	suppress, ok := ctx.Value(notificationSuppressionKey).(suppressNotificationsFunc)
+	return ok && suppress(g)
+}
+
+// ruleGroupOwnedByAnotherInstance returns whether the ring shows that the input rule group is owned by an
+// ACTIVE instance other than the input one. The check is conservative: false is returned whenever the owner
+// can't be determined, so that notifications are never suppressed because of a ring error.
+func ruleGroupOwnedByAnotherInstance(r ring.ReadRing, g *rulespb.RuleGroupDesc, instanceAddr string) bool {
+	owner, err := ruleGroupOwner(r, g)
+	if err != nil {
+		return false
+	}
+
+	return owner.Addr != instanceAddr && owner.State == ring.ACTIVE
+}
+
+// notificationContext returns the context the tenant's rules managers are created with, which
+// suppresses the alert notifications of the rule groups owned by another ruler if configured.
+func (r *Ruler) notificationContext(ctx context.Context) context.Context {
+	// The ownership of the rule groups can't be checked on a single rule group when balancing their cost.
+	if !r.cfg.NotificationDeduplication.SuppressWhenNotOwner || r.cfg.CostAwareShardingEnabled {
+		return ctx
+	}
+
+	return withNotificationSuppression(ctx, r.ruleGroupOwnedByAnotherRuler)
+}
+
+// ruleGroupOwnedByAnotherRuler returns whether the input rule group is owned by another ruler, eg. because
+// the ring has changed and this ruler hasn't synced its rules yet.
+func (r *Ruler) ruleGroupOwnedByAnotherRuler(g *rulespb.RuleGroupDesc) bool {
+	var userRing ring.ReadRing = r.ring
+	if shardSize := r.limits.RulerTenantShardSize(g.User); shardSize > 0 {
+		userRing = r.ring.ShuffleShard(g.User, shardSize)
+	}
+
+	if !ruleGroupOwnedByAnotherInstance(userRing, g, r.lifecycler.GetInstanceAddr()) {
+		return false
+	}
+
+	r.metrics.notificationsSuppressed.Inc()
+	level.Debug(r.logger).Log("msg", "not sending alert notifications of a rule group owned by another ruler", "user", g.User, "namespace", g.Namespace, "group", g.Name)
+	return true
+}
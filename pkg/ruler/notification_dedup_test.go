@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/ring"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// ownerReadRing is a ring whose rule groups are all owned by the same instance.
+type ownerReadRing struct {
+	ring.ReadRing
+	owner ring.InstanceDesc
+	err   error
+}
+
+func (r ownerReadRing) Get(_ uint32, _ ring.Operation, _ []ring.InstanceDesc, _, _ []string) (ring.ReplicationSet, error) {
+	if r.err != nil {
+		return ring.ReplicationSet{}, r.err
+	}
+	return ring.ReplicationSet{Instances: []ring.InstanceDesc{r.owner}}, nil
+}
+
+func TestNotificationDeduplicationConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&NotificationDeduplicationConfig{}).Validate())
+	assert.NoError(t, (&NotificationDeduplicationConfig{KeyAnnotation: "dedup_key"}).Validate())
+	assert.Equal(t, errInvalidNotificationDedupKeyAnnotation, (&NotificationDeduplicationConfig{KeyAnnotation: "dedup-key"}).Validate())
+}
+
+func TestNotificationDedupKey(t *testing.T) {
+	group := &rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-1"}
+	alert := labels.FromStrings(labels.AlertName, "Down", "job", "api")
+
+	key := notificationDedupKey(group, alert)
+	assert.Equal(t, key, notificationDedupKey(&rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-1"}, alert.Copy()))
+
+	// The key is different for a different tenant, rule group or alert.
+	assert.NotEqual(t, key, notificationDedupKey(&rulespb.RuleGroupDesc{User: "user-2", Namespace: "namespace", Name: "group-1"}, alert))
+	assert.NotEqual(t, key, notificationDedupKey(&rulespb.RuleGroupDesc{User: "user-1", Namespace: "namespace", Name: "group-2"}, alert))
+	assert.NotEqual(t, key, notificationDedupKey(group, labels.FromStrings(labels.AlertName, "Down", "job", "db")))
+}
+
+func TestSendAlerts_TransientDualOwnership(t *testing.T) {
+	const (
+		userID     = "user-1"
+		annotation = "dedup_key"
+		rulerAddr1 = "ruler-1:9095"
+		rulerAddr2 = "ruler-2:9095"
+	)
+
+	// The same rule group is run by both rulers, eg. because ruler-1 hasn't synced its rules yet
+	// after the rule group has moved to ruler-2.
+	group := promRules.NewGroup(promRules.GroupOptions{
+		Name:     "group-1",
+		File:     filepath.Join(t.TempDir(), userID, url.PathEscape("namespace/1")),
+		Interval: time.Minute,
+		Opts:     &promRules.ManagerOptions{Logger: log.NewNopLogger()},
+	})
+
+	alerts := []*promRules.Alert{{
+		Labels:      labels.FromStrings(labels.AlertName, "Down", "job", "api"),
+		Annotations: labels.FromStrings("summary", "the job is down"),
+		FiredAt:     time.Unix(2, 0),
+		ValidUntil:  time.Unix(3, 0),
+	}}
+	expectedKey := notificationDedupKey(&rulespb.RuleGroupDesc{User: userID, Namespace: "namespace/1", Name: "group-1"}, alerts[0].Labels)
+
+	tests := map[string]struct {
+		ring          ring.ReadRing
+		expectedSent1 bool
+		expectedSent2 bool
+	}{
+		"should send the notifications from the ruler owning the rule group only": {
+			ring:          ownerReadRing{owner: ring.InstanceDesc{Addr: rulerAddr2, State: ring.ACTIVE}},
+			expectedSent1: false,
+			expectedSent2: true,
+		},
+		"should send the notifications from both rulers if the owner is not ACTIVE": {
+			ring:          ownerReadRing{owner: ring.InstanceDesc{Addr: rulerAddr2, State: ring.JOINING}},
+			expectedSent1: true,
+			expectedSent2: true,
+		},
+		"should send the notifications from both rulers if the owner can't be determined": {
+			ring:          ownerReadRing{err: errors.New("too many unhealthy instances in the ring")},
+			expectedSent1: true,
+			expectedSent2: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			send := func(rulerAddr string) []*notifier.Alert {
+				var sent []*notifier.Alert
+				sender := senderFunc(func(alerts ...*notifier.Alert) {
+					sent = append(sent, alerts...)
+				})
+
+				ctx := withNotificationSuppression(user.InjectOrgID(context.Background(), userID), func(g *rulespb.RuleGroupDesc) bool {
+					return ruleGroupOwnedByAnotherInstance(testData.ring, g, rulerAddr)
+				})
+				ctx = EvaluatedGroupContextFunc(ctx, group)
+
+				SendAlerts(sender, "http://localhost:9090", annotation)(ctx, "up == 0", alerts...)
+				return sent
+			}
+
+			for rulerAddr, expectedSent := range map[string]bool{rulerAddr1: testData.expectedSent1, rulerAddr2: testData.expectedSent2} {
+				sent := send(rulerAddr)
+				if !expectedSent {
+					assert.Empty(t, sent, "ruler: %s", rulerAddr)
+					continue
+				}
+
+				// The notifications sent by both rulers have the same dedup key.
+				require.Len(t, sent, 1, "ruler: %s", rulerAddr)
+				assert.Equal(t, labels.FromStrings(annotation, expectedKey, "summary", "the job is down"), sent[0].Annotations, "ruler: %s", rulerAddr)
+			}
+
+			// The annotations of the alerts are not modified.
+			assert.Equal(t, labels.FromStrings("summary", "the job is down"), alerts[0].Annotations)
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 	gklog "github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/crypto/tls"
+	"github.com/grafana/dskit/flagext"
 	config_util "github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/config"
@@ -35,6 +36,42 @@ func (cfg *NotifierConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.BasicAuth.RegisterFlagsWithPrefix("ruler.alertmanager-client.", f)
 }
 
+// AlertmanagerURLGroupConfig configures a group of Alertmanager URLs with its own client config.
+type AlertmanagerURLGroupConfig struct {
+	URL string `yaml:"url" doc:"nocli|description=Comma-separated list of URL(s) of the Alertmanager(s) in the group, in the same format as -ruler.alertmanager-url."`
+
+	TLSCertPath           string         `yaml:"tls_cert_path" doc:"nocli|description=Path to the client certificate file, which will be used for authenticating with the Alertmanagers in the group."`
+	TLSKeyPath            string         `yaml:"tls_key_path" doc:"nocli|description=Path to the key file for the client certificate."`
+	TLSCAPath             string         `yaml:"tls_ca_path" doc:"nocli|description=Path to the CA certificates file to validate the Alertmanagers certificate. If not set, the host's root CA certificates are used."`
+	TLSServerName         string         `yaml:"tls_server_name" doc:"nocli|description=Override the expected name on the Alertmanagers certificate."`
+	TLSInsecureSkipVerify bool           `yaml:"tls_insecure_skip_verify" doc:"nocli|default=false|description=Skip validating the Alertmanagers certificate."`
+	BasicAuthUsername     string         `yaml:"basic_auth_username" doc:"nocli|description=HTTP Basic authentication username. It overrides the username set in the URL (if any)."`
+	BasicAuthPassword     flagext.Secret `yaml:"basic_auth_password" doc:"nocli|description=HTTP Basic authentication password. It overrides the password set in the URL (if any)."`
+}
+
+func (cfg *AlertmanagerURLGroupConfig) Validate() error {
+	if cfg.URL == "" {
+		return errMissingAlertmanagerURLGroupURL
+	}
+	return nil
+}
+
+func (cfg *AlertmanagerURLGroupConfig) notifierConfig() NotifierConfig {
+	return NotifierConfig{
+		TLS: tls.ClientConfig{
+			CertPath:           cfg.TLSCertPath,
+			KeyPath:            cfg.TLSKeyPath,
+			CAPath:             cfg.TLSCAPath,
+			ServerName:         cfg.TLSServerName,
+			InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+		},
+		BasicAuth: util.BasicAuth{
+			Username: cfg.BasicAuthUsername,
+			Password: cfg.BasicAuthPassword,
+		},
+	}
+}
+
 // rulerNotifier bundles a notifier.Manager together with an associated
 // Alertmanager service discovery manager and handles the lifecycle
 // of both actors.
@@ -92,28 +129,44 @@ func (rn *rulerNotifier) stop() {
 // Builds a Prometheus config.Config from a ruler.Config with just the required
 // options to configure notifications to Alertmanager.
 func buildNotifierConfig(rulerConfig *Config, resolver cacheutil.AddressProvider) (*config.Config, error) {
-	if rulerConfig.AlertmanagerURL == "" {
+	type urlGroup struct {
+		urls     string
+		notifier NotifierConfig
+	}
+
+	// The URLs configured with -ruler.alertmanager-url share the top-level client config,
+	// while each URL group uses its own client config.
+	groups := make([]urlGroup, 0, len(rulerConfig.AlertmanagerURLGroups)+1)
+	if rulerConfig.AlertmanagerURL != "" {
+		groups = append(groups, urlGroup{urls: rulerConfig.AlertmanagerURL, notifier: rulerConfig.Notifier})
+	}
+	for _, group := range rulerConfig.AlertmanagerURLGroups {
+		groups = append(groups, urlGroup{urls: group.URL, notifier: group.notifierConfig()})
+	}
+
+	if len(groups) == 0 {
 		// no AM URLs were provided, so we can just return a default config without errors
 		return &config.Config{}, nil
 	}
 
-	amURLs := strings.Split(rulerConfig.AlertmanagerURL, ",")
-	amConfigs := make([]*config.AlertmanagerConfig, 0, len(amURLs))
+	var amConfigs []*config.AlertmanagerConfig
 
-	for _, rawURL := range amURLs {
-		isSD, qType, url, err := sanitizedAlertmanagerURL(rawURL)
-		if err != nil {
-			return nil, err
-		}
+	for _, group := range groups {
+		for _, rawURL := range strings.Split(group.urls, ",") {
+			isSD, qType, url, err := sanitizedAlertmanagerURL(rawURL)
+			if err != nil {
+				return nil, err
+			}
 
-		var sdConfig discovery.Config
-		if isSD {
-			sdConfig = dnsSD(rulerConfig, resolver, qType, url)
-		} else {
-			sdConfig = staticTarget(url)
-		}
+			var sdConfig discovery.Config
+			if isSD {
+				sdConfig = dnsSD(rulerConfig, resolver, qType, url)
+			} else {
+				sdConfig = staticTarget(url)
+			}
 
-		amConfigs = append(amConfigs, amConfigWithSD(rulerConfig, url, sdConfig))
+			amConfigs = append(amConfigs, amConfigWithSD(rulerConfig, group.notifier, url, sdConfig))
+		}
 	}
 
 	promConfig := &config.Config{
@@ -125,7 +178,7 @@ func buildNotifierConfig(rulerConfig *Config, resolver cacheutil.AddressProvider
 	return promConfig, nil
 }
 
-func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config) *config.AlertmanagerConfig {
+func amConfigWithSD(rulerConfig *Config, notifierConfig NotifierConfig, url *url.URL, sdConfig discovery.Config) *config.AlertmanagerConfig {
 	amConfig := &config.AlertmanagerConfig{
 		APIVersion:              config.AlertmanagerAPIVersionV2,
 		Scheme:                  url.Scheme,
@@ -134,11 +187,11 @@ func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config
 		ServiceDiscoveryConfigs: discovery.Configs{sdConfig},
 		HTTPClientConfig: config_util.HTTPClientConfig{
 			TLSConfig: config_util.TLSConfig{
-				CAFile:             rulerConfig.Notifier.TLS.CAPath,
-				CertFile:           rulerConfig.Notifier.TLS.CertPath,
-				KeyFile:            rulerConfig.Notifier.TLS.KeyPath,
-				InsecureSkipVerify: rulerConfig.Notifier.TLS.InsecureSkipVerify,
-				ServerName:         rulerConfig.Notifier.TLS.ServerName,
+				CAFile:             notifierConfig.TLS.CAPath,
+				CertFile:           notifierConfig.TLS.CertPath,
+				KeyFile:            notifierConfig.TLS.KeyPath,
+				InsecureSkipVerify: notifierConfig.TLS.InsecureSkipVerify,
+				ServerName:         notifierConfig.TLS.ServerName,
 			},
 		},
 	}
@@ -155,10 +208,10 @@ func amConfigWithSD(rulerConfig *Config, url *url.URL, sdConfig discovery.Config
 	}
 
 	// Override URL basic authentication configs with hard coded config values if present
-	if rulerConfig.Notifier.BasicAuth.IsEnabled() {
+	if notifierConfig.BasicAuth.IsEnabled() {
 		amConfig.HTTPClientConfig.BasicAuth = &config_util.BasicAuth{
-			Username: rulerConfig.Notifier.BasicAuth.Username,
-			Password: config_util.Secret(rulerConfig.Notifier.BasicAuth.Password.String()),
+			Username: notifierConfig.BasicAuth.Username,
+			Password: config_util.Secret(notifierConfig.BasicAuth.Password.String()),
 		}
 	}
 
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/notifier"
+	"golang.org/x/time/rate"
+)
+
+// rateLimitedSender limits the rate of the notifications of a tenant sent to the Alertmanager.
+// Firing alerts exceeding the limit are dropped, while resolved alerts are always sent, so that
+// resolutions are never lost. Resolved alerts consume the rate limit too, when available.
+type rateLimitedSender struct {
+	upstream sender
+	userID   string
+	limits   RulesLimits
+	dropped  prometheus.Counter
+
+	// Created on the first rate limited notification, so that it starts with a full burst.
+	limiterMtx sync.Mutex
+	limiter    *rate.Limiter
+}
+
+func newRateLimitedSender(upstream sender, userID string, limits RulesLimits, dropped prometheus.Counter) *rateLimitedSender {
+	return &rateLimitedSender{
+		upstream: upstream,
+		userID:   userID,
+		limits:   limits,
+		dropped:  dropped,
+	}
+}
+
+func (s *rateLimitedSender) Send(alerts ...*notifier.Alert) {
+	now := time.Now()
+
+	limit := s.limits.RulerMaxNotificationsPerSecond(s.userID)
+	if limit <= 0 {
+		s.upstream.Send(alerts...)
+		return
+	}
+	limiter := s.getLimiter(limit, now)
+
+	allowed := make([]*notifier.Alert, 0, len(alerts))
+
+	// Resolved alerts come first, so that the firing ones are dropped in their favour.
+	for _, a := range alerts {
+		if a.Resolved() {
+			limiter.AllowN(now, 1)
+			allowed = append(allowed, a)
+		}
+	}
+
+	for _, a := range alerts {
+		if a.Resolved() {
+			continue
+		}
+		if !limiter.AllowN(now, 1) {
+			s.dropped.Inc()
+			continue
+		}
+		allowed = append(allowed, a)
+	}
+
+	if len(allowed) > 0 {
+		s.upstream.Send(allowed...)
+	}
+}
+
+// getLimiter returns the limiter, updated to the input limit.
+func (s *rateLimitedSender) getLimiter(limit float64, now time.Time) *rate.Limiter {
+	// Allow a burst of one second worth of notifications, and at least one notification.
+	burst := int(limit)
+	if burst < 1 {
+		burst = 1
+	}
+
+	s.limiterMtx.Lock()
+	defer s.limiterMtx.Unlock()
+
+	if s.limiter == nil {
+		s.limiter = rate.NewLimiter(rate.Limit(limit), burst)
+		return s.limiter
+	}
+
+	if s.limiter.Limit() != rate.Limit(limit) {
+		s.limiter.SetLimitAt(now, rate.Limit(limit))
+	}
+	if s.limiter.Burst() != burst {
+		s.limiter.SetBurstAt(now, burst)
+	}
+	return s.limiter
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedSender(t *testing.T) {
+	firing := func(name string) *notifier.Alert {
+		return &notifier.Alert{Labels: labels.FromStrings("alertname", name), EndsAt: time.Now().Add(time.Hour)}
+	}
+	resolved := func(name string) *notifier.Alert {
+		return &notifier.Alert{Labels: labels.FromStrings("alertname", name), EndsAt: time.Now().Add(-time.Minute)}
+	}
+	names := func(alerts []*notifier.Alert) []string {
+		var out []string
+		for _, a := range alerts {
+			out = append(out, a.Name())
+		}
+		return out
+	}
+
+	t.Run("should send all notifications when the rate limit is disabled", func(t *testing.T) {
+		var sent []*notifier.Alert
+		dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+		s := newRateLimitedSender(senderFunc(func(alerts ...*notifier.Alert) { sent = append(sent, alerts...) }), "user-1", ruleLimits{}, dropped)
+
+		s.Send(firing("a"), firing("b"), firing("c"), resolved("d"))
+		assert.Equal(t, []string{"a", "b", "c", "d"}, names(sent))
+		assert.Equal(t, float64(0), testutil.ToFloat64(dropped))
+	})
+
+	t.Run("should drop the firing alerts exceeding the rate limit, but not the resolved ones", func(t *testing.T) {
+		var sent []*notifier.Alert
+		dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+		s := newRateLimitedSender(senderFunc(func(alerts ...*notifier.Alert) { sent = append(sent, alerts...) }), "user-1", ruleLimits{notificationsRate: 2}, dropped)
+
+		// The burst allows 2 notifications, the first of which is taken by the resolved alert.
+		s.Send(firing("a"), firing("b"), firing("c"), resolved("d"))
+		assert.Equal(t, []string{"d", "a"}, names(sent))
+		assert.Equal(t, float64(2), testutil.ToFloat64(dropped))
+
+		// The rate limit is exhausted, but resolved alerts are still sent.
+		sent = nil
+		s.Send(firing("e"), resolved("f"))
+		assert.Equal(t, []string{"f"}, names(sent))
+		assert.Equal(t, float64(3), testutil.ToFloat64(dropped))
+	})
+
+	t.Run("should allow notifications again once the rate limit refills", func(t *testing.T) {
+		var sent []*notifier.Alert
+		dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+		s := newRateLimitedSender(senderFunc(func(alerts ...*notifier.Alert) { sent = append(sent, alerts...) }), "user-1", ruleLimits{notificationsRate: 20}, dropped)
+
+		alerts := make([]*notifier.Alert, 0, 30)
+		for i := 0; i < 30; i++ {
+			alerts = append(alerts, firing("a"))
+		}
+		s.Send(alerts...)
+		require.Len(t, sent, 20)
+
+		// At 20 notifications per second, a notification is allowed every 50ms.
+		time.Sleep(100 * time.Millisecond)
+		sent = nil
+		s.Send(firing("b"))
+		require.Equal(t, []string{"b"}, names(sent))
+	})
+}
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promRules "github.com/prometheus/prometheus/rules"
+)
+
+// startupNotificationHold holds the notifications of the alerts which were already active before the
+// tenant's rules started being evaluated by the ruler, until the hold period has elapsed since then. These
+// alerts had their "for" state restored (eg. after a ruler restart or a rule group moved between rulers
+// during a rollout), so they've likely already been notified by the previous ruler. The alerts becoming
+// active afterwards, and the ones whose state wasn't restored because the outage exceeded the outage
+// tolerance, are notified as usual.
+//
+// Only the latest notification of each held alert is kept, and the held notifications are released at the
+// first notification after the hold period, which is the next evaluation of any alerting rule of the tenant.
+type startupNotificationHold struct {
+	next      promRules.NotifyFunc
+	startedAt time.Time
+	releaseAt time.Time
+	heldTotal prometheus.Counter
+
+	mtx      sync.Mutex
+	released bool
+	held     map[heldNotificationKey]heldNotification
+}
+
+type heldNotificationKey struct {
+	group  string
+	expr   string
+	labels string
+}
+
+type heldNotification struct {
+	ctx   context.Context
+	expr  string
+	alert *promRules.Alert
+}
+
+func newStartupNotificationHold(next promRules.NotifyFunc, startedAt time.Time, period time.Duration, heldTotal prometheus.Counter) *startupNotificationHold {
+	return &startupNotificationHold{
+		next:      next,
+		startedAt: startedAt,
+		releaseAt: startedAt.Add(period),
+		heldTotal: heldTotal,
+		held:      map[heldNotificationKey]heldNotification{},
+	}
+}
+
+// Notify implements promRules.NotifyFunc.
+func (h *startupNotificationHold) Notify(ctx context.Context, expr string, alerts ...*promRules.Alert) {
+	if !time.Now().Before(h.releaseAt) {
+		h.release()
+		h.next(ctx, expr, alerts...)
+		return
+	}
+
+	group := ""
+	if g := notifiedRuleGroup(ctx); g != nil {
+		group = g.Namespace + string(sep) + g.Name
+	}
+
+	notify := make([]*promRules.Alert, 0, len(alerts))
+	h.mtx.Lock()
+	for _, alert := range alerts {
+		// The notifications may have been released concurrently, in which case they're not held anymore.
+		if h.released || !alert.ActiveAt.Before(h.startedAt) {
+			notify = append(notify, alert)
+			continue
+		}
+
+		// The alerts are copied by the rule before being notified, so they can be retained.
+		h.held[heldNotificationKey{group: group, expr: expr, labels: alert.Labels.String()}] = heldNotification{ctx: ctx, expr: expr, alert: alert}
+		h.heldTotal.Inc()
+	}
+	h.mtx.Unlock()
+
+	// The rules always call the notify function, even without alerts to notify, so we do the same.
+	h.next(ctx, expr, notify...)
+}
+
+// release sends the held notifications, once.
+func (h *startupNotificationHold) release() {
+	h.mtx.Lock()
+	if h.released {
+		h.mtx.Unlock()
+		return
+	}
+	h.released = true
+	held := h.held
+	h.held = nil
+	h.mtx.Unlock()
+
+	for _, n := range held {
+		h.next(n.ctx, n.expr, n.alert)
+	}
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartupNotificationHold(t *testing.T) {
+	const holdPeriod = 200 * time.Millisecond
+
+	var (
+		mtx  sync.Mutex
+		sent []string
+	)
+	notify := func(_ context.Context, _ string, alerts ...*promRules.Alert) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		for _, a := range alerts {
+			state := "firing"
+			if !a.ResolvedAt.IsZero() {
+				state = "resolved"
+			}
+			sent = append(sent, a.Labels.Get("alertname")+":"+state)
+		}
+	}
+	sentAndReset := func() []string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		out := sent
+		sent = nil
+		sort.Strings(out)
+		return out
+	}
+
+	// Simulate a ruler restart: the alerts active before the start have their state restored.
+	startedAt := time.Now()
+	restored := func(name string) *promRules.Alert {
+		return &promRules.Alert{Labels: labels.FromStrings("alertname", name), State: promRules.StateFiring, ActiveAt: startedAt.Add(-time.Hour)}
+	}
+	newlyFiring := func(name string) *promRules.Alert {
+		return &promRules.Alert{Labels: labels.FromStrings("alertname", name), State: promRules.StateFiring, ActiveAt: startedAt.Add(time.Second)}
+	}
+
+	held := prometheus.NewCounter(prometheus.CounterOpts{Name: "held"})
+	h := newStartupNotificationHold(notify, startedAt, holdPeriod, held)
+
+	// The restored alerts are held, while the newly firing ones are notified.
+	h.Notify(context.Background(), "up == 0", restored("a"), restored("b"), newlyFiring("c"))
+	assert.Equal(t, []string{"c:firing"}, sentAndReset())
+	assert.Equal(t, float64(2), testutil.ToFloat64(held))
+
+	// Only the latest notification of each held alert is kept.
+	resolvedB := restored("b")
+	resolvedB.State = promRules.StateInactive
+	resolvedB.ResolvedAt = time.Now()
+	h.Notify(context.Background(), "up == 0", restored("a"), resolvedB)
+	assert.Empty(t, sentAndReset())
+	assert.Equal(t, float64(4), testutil.ToFloat64(held))
+
+	// The held notifications are released at the first notification after the hold period.
+	time.Sleep(holdPeriod)
+	h.Notify(context.Background(), "up == 0", restored("a"), newlyFiring("d"))
+	assert.Equal(t, []string{"a:firing", "a:firing", "b:resolved", "d:firing"}, sentAndReset())
+
+	// Once released, the notifications aren't held anymore.
+	h.Notify(context.Background(), "up == 0", restored("a"))
+	assert.Equal(t, []string{"a:firing"}, sentAndReset())
+	assert.Equal(t, float64(4), testutil.ToFloat64(held))
+}
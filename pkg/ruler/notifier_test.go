@@ -264,6 +264,122 @@ func TestBuildNotifierConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "with URL groups, each one with its own client config",
+			cfg: &Config{
+				AlertmanagerURL: "http://alertmanager-0.default.svc.cluster.local/alertmanager",
+				Notifier: NotifierConfig{
+					BasicAuth: util.BasicAuth{Username: "jacob", Password: flagext.SecretWithValue("test")},
+				},
+				AlertmanagerURLGroups: []AlertmanagerURLGroupConfig{
+					{
+						URL:               "http://alertmanager-prod-0.default.svc.cluster.local/alertmanager,http://alertmanager-prod-1.default.svc.cluster.local/alertmanager",
+						BasicAuthUsername: "prod",
+						BasicAuthPassword: flagext.SecretWithValue("prod-secret"),
+					},
+					{
+						URL:           "https://alertmanager-dev.default.svc.cluster.local/am",
+						TLSCAPath:     "/path/to/ca.crt",
+						TLSServerName: "alertmanager-dev",
+					},
+				},
+			},
+			ncfg: &config.Config{
+				AlertingConfig: config.AlertingConfig{
+					AlertmanagerConfigs: []*config.AlertmanagerConfig{
+						{
+							HTTPClientConfig: config_util.HTTPClientConfig{
+								BasicAuth: &config_util.BasicAuth{Username: "jacob", Password: "test"},
+							},
+							APIVersion: "v2",
+							Scheme:     "http",
+							PathPrefix: "/alertmanager",
+							ServiceDiscoveryConfigs: discovery.Configs{
+								discovery.StaticConfig{
+									{
+										Targets: []model.LabelSet{{"__address__": "alertmanager-0.default.svc.cluster.local"}},
+									},
+								},
+							},
+						},
+						{
+							HTTPClientConfig: config_util.HTTPClientConfig{
+								BasicAuth: &config_util.BasicAuth{Username: "prod", Password: "prod-secret"},
+							},
+							APIVersion: "v2",
+							Scheme:     "http",
+							PathPrefix: "/alertmanager",
+							ServiceDiscoveryConfigs: discovery.Configs{
+								discovery.StaticConfig{
+									{
+										Targets: []model.LabelSet{{"__address__": "alertmanager-prod-0.default.svc.cluster.local"}},
+									},
+								},
+							},
+						},
+						{
+							HTTPClientConfig: config_util.HTTPClientConfig{
+								BasicAuth: &config_util.BasicAuth{Username: "prod", Password: "prod-secret"},
+							},
+							APIVersion: "v2",
+							Scheme:     "http",
+							PathPrefix: "/alertmanager",
+							ServiceDiscoveryConfigs: discovery.Configs{
+								discovery.StaticConfig{
+									{
+										Targets: []model.LabelSet{{"__address__": "alertmanager-prod-1.default.svc.cluster.local"}},
+									},
+								},
+							},
+						},
+						{
+							HTTPClientConfig: config_util.HTTPClientConfig{
+								TLSConfig: config_util.TLSConfig{CAFile: "/path/to/ca.crt", ServerName: "alertmanager-dev"},
+							},
+							APIVersion: "v2",
+							Scheme:     "https",
+							PathPrefix: "/am",
+							ServiceDiscoveryConfigs: discovery.Configs{
+								discovery.StaticConfig{
+									{
+										Targets: []model.LabelSet{{"__address__": "alertmanager-dev.default.svc.cluster.local"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "with only URL groups",
+			cfg: &Config{
+				AlertmanagerURLGroups: []AlertmanagerURLGroupConfig{
+					{URL: "http://alertmanager.default.svc.cluster.local/alertmanager", BasicAuthUsername: "prod"},
+				},
+			},
+			ncfg: &config.Config{
+				AlertingConfig: config.AlertingConfig{
+					AlertmanagerConfigs: []*config.AlertmanagerConfig{
+						{
+							HTTPClientConfig: config_util.HTTPClientConfig{
+								BasicAuth: &config_util.BasicAuth{Username: "prod"},
+							},
+							APIVersion: "v2",
+							Scheme:     "http",
+							PathPrefix: "/alertmanager",
+							ServiceDiscoveryConfigs: discovery.Configs{
+								discovery.StaticConfig{
+									{
+										Targets: []model.LabelSet{{"__address__": "alertmanager.default.svc.cluster.local"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "with DNS service discovery and missing scheme",
 			cfg: &Config{
@@ -17,11 +17,15 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/status"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/grpcclient"
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	prommodel "github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -31,6 +35,7 @@ import (
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/version"
@@ -56,6 +61,14 @@ type QueryFrontendConfig struct {
 
 	// GRPCClientConfig contains gRPC specific config options.
 	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config"`
+
+	// Timeout is the timeout of a rule evaluation, including retries.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// MaxRetries is the max number of times a rule evaluation failed with a retriable error is retried.
+	MaxRetries      int           `yaml:"max_retries"`
+	RetryMinBackoff time.Duration `yaml:"retry_min_backoff"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff"`
 }
 
 func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
@@ -66,6 +79,29 @@ func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 			"to enable client side load balancing.")
 
 	c.GRPCClientConfig.RegisterFlagsWithPrefix("ruler.query-frontend.grpc-client-config", f)
+
+	f.DurationVar(&c.Timeout, "ruler.query-frontend.timeout", 0, "Timeout of a rule evaluation against the query-frontend, including retries. 0 to use the querier query timeout.")
+	f.IntVar(&c.MaxRetries, "ruler.query-frontend.max-retries", 0, "Maximum number of times a rule evaluation against the query-frontend is retried when it fails with a retriable error, such as the query-frontend being unavailable. 0 to disable retries.")
+	f.DurationVar(&c.RetryMinBackoff, "ruler.query-frontend.retry-min-backoff", 100*time.Millisecond, "Minimum backoff delay between the retries of a rule evaluation against the query-frontend.")
+	f.DurationVar(&c.RetryMaxBackoff, "ruler.query-frontend.retry-max-backoff", 2*time.Second, "Maximum backoff delay between the retries of a rule evaluation against the query-frontend.")
+}
+
+// EvaluationTimeout returns the timeout of a rule evaluation against the query-frontend,
+// falling back to the input default timeout when not configured.
+func (c QueryFrontendConfig) EvaluationTimeout(defaultTimeout time.Duration) time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultTimeout
+}
+
+// RetryConfig returns the backoff configuration of the retries of a rule evaluation against the query-frontend.
+func (c QueryFrontendConfig) RetryConfig() backoff.Config {
+	return backoff.Config{
+		MinBackoff: c.RetryMinBackoff,
+		MaxBackoff: c.RetryMaxBackoff,
+		MaxRetries: c.MaxRetries,
+	}
 }
 
 // DialQueryFrontend creates and initializes a new httpgrpc.HTTPClient taking a QueryFrontendConfig configuration.
@@ -93,25 +129,36 @@ type Middleware func(ctx context.Context, req *httpgrpc.HTTPRequest) error
 type RemoteQuerier struct {
 	client         httpgrpc.HTTPClient
 	timeout        time.Duration
+	retryConfig    backoff.Config
 	middlewares    []Middleware
 	promHTTPPrefix string
 	logger         log.Logger
+
+	retries prometheus.Counter
 }
 
-// NewRemoteQuerier creates and initializes a new RemoteQuerier instance.
+// NewRemoteQuerier creates and initializes a new RemoteQuerier instance. The MaxRetries of the
+// input retry config is the max number of retries of a failed request, where 0 disables retries.
 func NewRemoteQuerier(
 	client httpgrpc.HTTPClient,
 	timeout time.Duration,
+	retryConfig backoff.Config,
 	prometheusHTTPPrefix string,
 	logger log.Logger,
+	reg prometheus.Registerer,
 	middlewares ...Middleware,
 ) *RemoteQuerier {
 	return &RemoteQuerier{
 		client:         client,
 		timeout:        timeout,
+		retryConfig:    retryConfig,
 		middlewares:    middlewares,
 		promHTTPPrefix: prometheusHTTPPrefix,
 		logger:         logger,
+		retries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_remote_evaluation_retries_total",
+			Help: "Total number of retries of the requests to the query-frontend failed with a retriable error.",
+		}),
 	}
 }
 
@@ -150,17 +197,11 @@ func (q *RemoteQuerier) Read(ctx context.Context, query *prompb.Query) (*prompb.
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, q.timeout)
-	defer cancel()
-
-	resp, err := q.client.Handle(ctx, &req)
+	resp, err := q.sendRequest(ctx, &req, log)
 	if err != nil {
 		level.Warn(log).Log("msg", "failed to perform remote read", "err", err, "qs", query)
 		return nil, err
 	}
-	if resp.Code/100 != 2 {
-		return nil, httpgrpc.Errorf(int(resp.Code), "unexpected response status code %d: %s", resp.Code, string(resp.Body))
-	}
 	level.Debug(log).Log("msg", "remote read successfully performed", "qs", query)
 
 	uncompressed, err := snappy.Decode(nil, resp.Body)
@@ -217,17 +258,11 @@ func (q *RemoteQuerier) query(ctx context.Context, query string, ts time.Time, l
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, q.timeout)
-	defer cancel()
-
-	resp, err := q.client.Handle(ctx, &req)
+	resp, err := q.sendRequest(ctx, &req, logger)
 	if err != nil {
 		level.Warn(logger).Log("msg", "failed to remotely evaluate query expression", "err", err, "qs", query, "tm", ts)
 		return model.ValNone, nil, err
 	}
-	if resp.Code/100 != 2 {
-		return model.ValNone, nil, httpgrpc.Errorf(int(resp.Code), "unexpected response status code %d: %s", resp.Code, string(resp.Body))
-	}
 	level.Debug(logger).Log("msg", "query expression successfully evaluated", "qs", query, "tm", ts)
 
 	var apiResp struct {
@@ -253,6 +288,67 @@ func (q *RemoteQuerier) query(ctx context.Context, query string, ts time.Time, l
 	return v.Type, v.Result, nil
 }
 
+// sendRequest sends the request to the query-frontend, retrying it with backoff when it fails with a retriable
+// error. The timeout applies to the request including its retries. A response with a non-2xx status code
+// is returned as error.
+func (q *RemoteQuerier) sendRequest(ctx context.Context, req *httpgrpc.HTTPRequest, logger log.Logger) (*httpgrpc.HTTPResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	retries := backoff.New(ctx, q.retryConfig)
+	for {
+		resp, err := q.client.Handle(ctx, req)
+		if err == nil && resp.Code/100 != 2 {
+			err = httpgrpc.Errorf(int(resp.Code), "unexpected response status code %d: %s", resp.Code, string(resp.Body))
+		}
+		if err == nil {
+			return resp, nil
+		}
+
+		if !isRetriableRemoteQuerierError(err) || retries.NumRetries() >= q.retryConfig.MaxRetries {
+			return nil, err
+		}
+
+		level.Warn(logger).Log("msg", "failed to send request to the query-frontend, retrying", "err", err, "retry", retries.NumRetries()+1)
+		retries.Wait()
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		q.retries.Inc()
+	}
+}
+
+// isRetriableRemoteQuerierError returns whether the error returned by the query-frontend is transient, so that
+// the request can be retried. Errors caused by the request itself (e.g. an invalid query) are not retriable.
+func isRetriableRemoteQuerierError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+
+	code := st.Code()
+	switch {
+	// Errors from the query-frontend HTTP handler have the HTTP status code as gRPC code.
+	case int(code) == http.StatusTooManyRequests:
+		return true
+	case int(code)/100 == 5:
+		return true
+	case int(code)/100 == 4:
+		return false
+	}
+
+	switch code {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
 func decodeQueryResponse(valTyp model.ValueType, result json.RawMessage) (promql.Vector, error) {
 	switch valTyp {
 	case model.ValScalar:
@@ -13,6 +13,9 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/status"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
@@ -46,7 +49,7 @@ func TestRemoteQuerier_ReadReq(t *testing.T) {
 			Body: snappy.Encode(nil, b),
 		}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, backoff.Config{}, "/prometheus", log.NewNopLogger(), nil)
 
 	_, err := q.Read(context.Background(), &prompb.Query{})
 	require.NoError(t, err)
@@ -62,7 +65,7 @@ func TestRemoteQuerier_ReadReqTimeout(t *testing.T) {
 		<-ctx.Done()
 		return nil, ctx.Err()
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, backoff.Config{}, "/prometheus", log.NewNopLogger(), nil)
 
 	_, err := q.Read(context.Background(), &prompb.Query{})
 	require.Error(t, err)
@@ -76,7 +79,7 @@ func TestRemoteQuerier_QueryReq(t *testing.T) {
 							"status": "success","data": {"resultType":"vector","result":[]}
 						}`)}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, backoff.Config{}, "/prometheus", log.NewNopLogger(), nil)
 
 	tm := time.Unix(1649092025, 515834)
 	_, err := q.Query(context.Background(), "qs", tm)
@@ -93,7 +96,7 @@ func TestRemoteQuerier_QueryReqTimeout(t *testing.T) {
 		<-ctx.Done()
 		return nil, ctx.Err()
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, backoff.Config{}, "/prometheus", log.NewNopLogger(), nil)
 
 	tm := time.Unix(1649092025, 515834)
 	_, err := q.Query(context.Background(), "qs", tm)
@@ -106,7 +109,7 @@ func TestRemoteQuerier_StatusErrorResponses(t *testing.T) {
 							"status": "error","errorType": "execution"
 						}`)}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, backoff.Config{}, "/prometheus", log.NewNopLogger(), nil)
 
 	tm := time.Unix(1649092025, 515834)
 
@@ -119,3 +122,95 @@ func TestRemoteQuerier_StatusErrorResponses(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, codes.Code(http.StatusUnprocessableEntity), st.Code())
 }
+
+func TestRemoteQuerier_QueryRetries(t *testing.T) {
+	successfulResponse := &httpgrpc.HTTPResponse{Code: http.StatusOK, Body: []byte(`{
+		"status": "success","data": {"resultType":"vector","result":[]}
+	}`)}
+	retryConfig := backoff.Config{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxRetries: 2}
+
+	tests := map[string]struct {
+		failures          []error
+		retryConfig       backoff.Config
+		expectedErr       bool
+		expectedAttempts  int
+		expectedRetries   float64
+		expectedErrorCode codes.Code
+	}{
+		"should retry a request failed because the query-frontend is unavailable": {
+			failures:         []error{status.Error(codes.Unavailable, "connection refused")},
+			retryConfig:      retryConfig,
+			expectedAttempts: 2,
+			expectedRetries:  1,
+		},
+		"should retry a request failed with a 5xx status code": {
+			failures:         []error{httpgrpc.Errorf(http.StatusServiceUnavailable, "unavailable")},
+			retryConfig:      retryConfig,
+			expectedAttempts: 2,
+			expectedRetries:  1,
+		},
+		"should not retry a request failed with a 4xx status code": {
+			failures:          []error{httpgrpc.Errorf(http.StatusBadRequest, "parse error")},
+			retryConfig:       retryConfig,
+			expectedErr:       true,
+			expectedAttempts:  1,
+			expectedErrorCode: codes.Code(http.StatusBadRequest),
+		},
+		"should fail once the max number of retries is reached": {
+			failures:          []error{httpgrpc.Errorf(http.StatusInternalServerError, "1"), httpgrpc.Errorf(http.StatusInternalServerError, "2"), httpgrpc.Errorf(http.StatusInternalServerError, "3")},
+			retryConfig:       retryConfig,
+			expectedErr:       true,
+			expectedAttempts:  3,
+			expectedRetries:   2,
+			expectedErrorCode: codes.Code(http.StatusInternalServerError),
+		},
+		"should not retry when retries are disabled": {
+			failures:          []error{status.Error(codes.Unavailable, "connection refused")},
+			expectedErr:       true,
+			expectedAttempts:  1,
+			expectedErrorCode: codes.Unavailable,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			attempts := 0
+			mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+				attempts++
+				if attempts <= len(testData.failures) {
+					return nil, testData.failures[attempts-1]
+				}
+				return successfulResponse, nil
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testData.retryConfig, "/prometheus", log.NewNopLogger(), reg)
+
+			_, err := q.Query(context.Background(), "qs", time.Now())
+			if testData.expectedErr {
+				require.Error(t, err)
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, testData.expectedErrorCode, st.Code())
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, testData.expectedAttempts, attempts)
+			require.Equal(t, testData.expectedRetries, testutil.ToFloat64(q.retries))
+		})
+	}
+}
+
+func TestRemoteQuerier_QueryTimeoutIncludesRetries(t *testing.T) {
+	mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	retryConfig := backoff.Config{MinBackoff: 50 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, MaxRetries: 100}
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), 200*time.Millisecond, retryConfig, "/prometheus", log.NewNopLogger(), nil)
+
+	start := time.Now()
+	_, err := q.Query(context.Background(), "qs", time.Now())
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+// alertingRulesMetrics are the metrics written by the alerting rules.
+var alertingRulesMetrics = []string{"ALERTS", "ALERTS_FOR_STATE"}
+
+// ruleEvaluationBatches returns the batches in which the rules of a rule group can be evaluated, as indexes
+// of the input rules, such that each rule is evaluated after the rules it depends on: the rules of the same
+// batch don't depend on each other, so they could be evaluated concurrently, while the batches have to be
+// evaluated in order.
+//
+// A rule depends on the recording rules whose metric it reads. A recording rule reading the metrics written by
+// the alerting rules depends on the alerting rules matching the alertname matcher, or on all of them if there's
+// no alertname matcher. The alerting rules don't depend on each other when reading the metrics written by the
+// alerting rules, because every alerting rule writes them: they read the result of the previous evaluation,
+// like a rule reading its own metric, which doesn't depend on itself. If the metrics read by any rule can't be
+// determined, eg. because it reads series selected by a regular expression on the metric name, each rule is
+// evaluated in its own batch, in the rule group order, like when the rules are evaluated sequentially.
+//
+// An error is returned if the rules depend on each other in a cycle, or if any rule expression is invalid.
+//
+// The batches are not used yet: the rules of a group are evaluated sequentially by the Prometheus rules
+// manager, which has no hook to evaluate them concurrently, and the rules depending on each other in a
+// cycle are accepted, like in Prometheus, because each of them reads the result of the previous evaluation.
+func ruleEvaluationBatches(rules []*rulespb.RuleDesc) ([][]int, error) {
+	// The indexes of the recording rules writing each metric, and of the alerting rules.
+	var (
+		writers       = map[string][]int{}
+		alertingRules []int
+	)
+	for i, r := range rules {
+		if r.GetRecord() != "" {
+			writers[r.GetRecord()] = append(writers[r.GetRecord()], i)
+		} else {
+			alertingRules = append(alertingRules, i)
+		}
+	}
+
+	// The indexes of the rules each rule depends on.
+	var (
+		dependencies = make([]map[int]struct{}, len(rules))
+		sequential   = false
+	)
+	for i, r := range rules {
+		metrics, ok, err := readMetrics(r.GetExpr())
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %d", i)
+		}
+		if !ok {
+			sequential = true
+			continue
+		}
+
+		dependencies[i] = map[int]struct{}{}
+		for _, m := range metrics {
+			for _, dep := range writers[m.name] {
+				if dep != i {
+					dependencies[i][dep] = struct{}{}
+				}
+			}
+
+			if r.GetRecord() == "" || !isAlertingRulesMetric(m.name) {
+				continue
+			}
+			for _, dep := range alertingRules {
+				if m.alertName == "" || rules[dep].GetAlert() == m.alertName {
+					dependencies[i][dep] = struct{}{}
+				}
+			}
+		}
+	}
+
+	if sequential {
+		return sequentialEvaluationBatches(len(rules)), nil
+	}
+
+	// Sort the rules topologically, grouping the rules whose dependencies have all been evaluated in the
+	// previous batches. The rules left once no more rule can be evaluated depend on each other in a cycle.
+	var (
+		batches   [][]int
+		evaluated = make([]bool, len(rules))
+		remaining = len(rules)
+	)
+	for remaining > 0 {
+		var batch []int
+		for i := range rules {
+			if !evaluated[i] && dependenciesEvaluated(dependencies[i], evaluated) {
+				batch = append(batch, i)
+			}
+		}
+		if len(batch) == 0 {
+			return nil, dependencyCycleError(rules, evaluated)
+		}
+
+		for _, i := range batch {
+			evaluated[i] = true
+		}
+		remaining -= len(batch)
+		batches = append(batches, batch)
+	}
+
+	return batches, nil
+}
+
+// readMetric is a metric read by a rule expression.
+type readMetric struct {
+	name string
+
+	// The value of the alertname equal matcher, if any.
+	alertName string
+}
+
+// readMetrics returns the metrics read by the input expression, and false if the expression reads series
+// whose metric name can't be determined.
+func readMetrics(expr string) ([]readMetric, bool, error) {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var (
+		metrics []readMetric
+		ok      = true
+	)
+	parser.Inspect(parsed, func(node parser.Node, _ []parser.Node) error {
+		vs, isSelector := node.(*parser.VectorSelector)
+		if !isSelector || !ok {
+			return nil
+		}
+
+		m := readMetric{name: vs.Name}
+		for _, matcher := range vs.LabelMatchers {
+			if matcher.Type != labels.MatchEqual {
+				continue
+			}
+			switch matcher.Name {
+			case labels.MetricName:
+				m.name = matcher.Value
+			case labels.AlertName:
+				m.alertName = matcher.Value
+			}
+		}
+		if m.name == "" {
+			ok = false
+			return nil
+		}
+
+		metrics = append(metrics, m)
+		return nil
+	})
+
+	return metrics, ok, nil
+}
+
+func isAlertingRulesMetric(name string) bool {
+	for _, n := range alertingRulesMetrics {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func dependenciesEvaluated(dependencies map[int]struct{}, evaluated []bool) bool {
+	for dep := range dependencies {
+		if !evaluated[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// sequentialEvaluationBatches returns the batches evaluating each rule in its own batch, in order.
+func sequentialEvaluationBatches(numRules int) [][]int {
+	batches := make([][]int, 0, numRules)
+	for i := 0; i < numRules; i++ {
+		batches = append(batches, []int{i})
+	}
+	return batches
+}
+
+// dependencyCycleError returns the error listing the rules which couldn't be evaluated because of a dependency
+// cycle, including the rules depending on the cycle.
+func dependencyCycleError(rules []*rulespb.RuleDesc, evaluated []bool) error {
+	var names []string
+	for i, r := range rules {
+		if evaluated[i] {
+			continue
+		}
+		name := r.GetRecord()
+		if name == "" {
+			name = r.GetAlert()
+		}
+		names = append(names, fmt.Sprintf("%d (%s)", i, name))
+	}
+	return fmt.Errorf("the rules depend on each other in a cycle, or on rules in a cycle: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuleEvaluationBatches(t *testing.T) {
+	tests := map[string]struct {
+		rules           []*rulespb.RuleDesc
+		expectedBatches [][]int
+		expectedErr     string
+	}{
+		"independent rules": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+				{Alert: "InstanceDown", Expr: "up == 0"},
+			},
+			expectedBatches: [][]int{{0, 1, 2}},
+		},
+		"dependency chain": {
+			rules: []*rulespb.RuleDesc{
+				{Alert: "TooManyRequests", Expr: "job:requests:rate5m:sum > 100"},
+				{Record: "job:requests:rate5m:sum", Expr: "sum(job:requests:rate5m)"},
+				{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+			},
+			expectedBatches: [][]int{{2}, {1}, {0}},
+		},
+		"rules depending on multiple rules": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+				{Record: "job:errors:rate5m", Expr: "sum by (job) (rate(errors_total[5m]))"},
+				{Record: "job:errors:ratio5m", Expr: "job:errors:rate5m / job:requests:rate5m"},
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Alert: "HighErrorRatio", Expr: `job:errors:ratio5m{job="api"} > 0.1`},
+			},
+			expectedBatches: [][]int{{0, 1, 3}, {2}, {4}},
+		},
+		"rules reading the metrics of the alerting rules": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "alerts:firing:count", Expr: `count(ALERTS{alertstate="firing"})`},
+				{Alert: "InstanceDown", Expr: "up == 0"},
+				{Alert: "TooManyRequests", Expr: "job:requests:rate5m > 100"},
+				{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+			},
+			expectedBatches: [][]int{{1, 3}, {2}, {0}},
+		},
+		"recording rules reading the metrics of the alerting rules with the alertname matcher": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "alerts:instance_down:count", Expr: `count(ALERTS{alertname="InstanceDown"})`},
+				{Alert: "InstanceDown", Expr: "up == 0"},
+				{Alert: "TooManyRequests", Expr: "job:requests:rate5m > 100"},
+				{Record: "job:requests:rate5m", Expr: "sum by (job) (rate(requests_total[5m]))"},
+			},
+			expectedBatches: [][]int{{1, 3}, {0, 2}},
+		},
+		"alerting rules reading the metrics of the alerting rules": {
+			rules: []*rulespb.RuleDesc{
+				{Alert: "InstanceDown", Expr: "up == 0"},
+				{Alert: "TooManyAlerts", Expr: `count(ALERTS{alertstate="firing"}) > 10`},
+				{Alert: "AlertsFlapping", Expr: `changes(ALERTS_FOR_STATE[1h]) > 5`},
+			},
+			expectedBatches: [][]int{{0, 1, 2}},
+		},
+		"rule reading its own metric": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:up:max_over_time", Expr: "max(job:up:max_over_time or up)"},
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			},
+			expectedBatches: [][]int{{0, 1}},
+		},
+		"rule with the metric name selected by a regular expression": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Record: "job:requests:count", Expr: `count by (job) ({__name__=~"requests_.+"})`},
+				{Record: "job:errors:rate5m", Expr: "sum by (job) (rate(errors_total[5m]))"},
+			},
+			expectedBatches: [][]int{{0}, {1}, {2}},
+		},
+		"dependency cycle": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Record: "a", Expr: "b + 1"},
+				{Record: "b", Expr: "c + 1"},
+				{Record: "c", Expr: "a + 1"},
+				{Alert: "Broken", Expr: "c > 10"},
+			},
+			expectedErr: "the rules depend on each other in a cycle, or on rules in a cycle: 1 (a), 2 (b), 3 (c), 4 (Broken)",
+		},
+		"invalid expression": {
+			rules: []*rulespb.RuleDesc{
+				{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				{Record: "invalid", Expr: "sum("},
+			},
+			expectedErr: "rule 1",
+		},
+		"no rules": {
+			rules: nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			batches, err := ruleEvaluationBatches(testData.rules)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedBatches, batches)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// InvalidRulesDiscovery has info for the rules whose expression can't be parsed.
+type InvalidRulesDiscovery struct {
+	Rules []*InvalidRule `json:"rules"`
+}
+
+// InvalidRule has info about a rule whose expression can't be parsed.
+type InvalidRule struct {
+	Namespace string      `json:"namespace"`
+	Group     string      `json:"group"`
+	Name      string      `json:"name"`
+	Type      v1.RuleType `json:"type"`
+	Query     string      `json:"query"`
+	Error     string      `json:"error"`
+}
+
+// ValidateRuleExpressions loads the tenant's rule groups from the rule store, and returns the rules whose
+// expression can't be parsed by the PromQL parser of this ruler, eg. because they use a syntax supported by
+// an older PromQL version only. The rule groups can be limited to a namespace with the "namespace" parameter.
+func (r *Ruler) ValidateRuleExpressions(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		// When Mimir is running, it uses Auth Middleware for checking X-Scope-OrgID and injecting tenant into context.
+		// Auth Middleware sends http.StatusUnauthorized if X-Scope-OrgID is missing, so we do too here, for consistency.
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := r.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, req.FormValue("namespace"))
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+	if err := r.store.LoadRuleGroups(req.Context(), map[string]rulespb.RuleGroupList{userID: groups}); err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   &InvalidRulesDiscovery{Rules: invalidRules(groups)},
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// invalidRules returns the rules whose expression can't be parsed, sorted by namespace and group.
+// Rules within the same group keep their order.
+func invalidRules(groups rulespb.RuleGroupList) []*InvalidRule {
+	sorted := make(rulespb.RuleGroupList, len(groups))
+	copy(sorted, groups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].GetNamespace() != sorted[j].GetNamespace() {
+			return sorted[i].GetNamespace() < sorted[j].GetNamespace()
+		}
+		return sorted[i].GetName() < sorted[j].GetName()
+	})
+
+	invalid := []*InvalidRule{}
+	for _, g := range sorted {
+		for _, rl := range g.GetRules() {
+			_, err := parser.ParseExpr(rl.GetExpr())
+			if err == nil {
+				continue
+			}
+
+			ir := &InvalidRule{
+				Namespace: g.GetNamespace(),
+				Group:     g.GetName(),
+				Query:     rl.GetExpr(),
+				Error:     err.Error(),
+			}
+			if rl.GetAlert() != "" {
+				ir.Name = rl.GetAlert()
+				ir.Type = v1.RuleTypeAlerting
+			} else {
+				ir.Name = rl.GetRecord()
+				ir.Type = v1.RuleTypeRecording
+			}
+			invalid = append(invalid, ir)
+		}
+	}
+
+	return invalid
+}
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuler_ValidateRuleExpressions(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	// The rules using functions and modifiers removed in Prometheus 2.0 can't be parsed anymore.
+	store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {
+			&rulespb.RuleGroupDesc{
+				Name:      "group2",
+				Namespace: "namespace2",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{Record: "job:up:count", Expr: "count_scalar(up)"},
+				},
+				Interval: interval,
+			},
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "namespace1",
+				User:      "user1",
+				Rules: []*rulespb.RuleDesc{
+					{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+					{Alert: "InstanceDown", Expr: "drop_common_labels(up) == 0"},
+					{Record: "job:requests:sum", Expr: "sum(requests_total) keep_common"},
+				},
+				Interval: interval,
+			},
+		},
+		"user2": {
+			&rulespb.RuleGroupDesc{
+				Name:      "group1",
+				Namespace: "namespace1",
+				User:      "user2",
+				Rules: []*rulespb.RuleDesc{
+					{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+				},
+				Interval: interval,
+			},
+		},
+	})
+
+	// The rules are loaded from the store, so the ruler doesn't need to be running.
+	r := buildRuler(t, cfg, store, nil)
+
+	router := mux.NewRouter()
+	router.Path("/ruler/validate_rule_expressions").Methods(http.MethodGet).HandlerFunc(r.ValidateRuleExpressions)
+
+	tests := map[string]struct {
+		url            string
+		userID         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"should return the rules of the tenant whose expression can't be parsed": {
+			url:            "https://localhost:8080/ruler/validate_rule_expressions",
+			userID:         "user1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{
+				"status": "success",
+				"data": {
+					"rules": [{
+						"namespace": "namespace1",
+						"group": "group1",
+						"name": "InstanceDown",
+						"type": "alerting",
+						"query": "drop_common_labels(up) == 0",
+						"error": "1:1: parse error: unknown function with name \"drop_common_labels\""
+					}, {
+						"namespace": "namespace1",
+						"group": "group1",
+						"name": "job:requests:sum",
+						"type": "recording",
+						"query": "sum(requests_total) keep_common",
+						"error": "1:21: parse error: unexpected identifier \"keep_common\""
+					}, {
+						"namespace": "namespace2",
+						"group": "group2",
+						"name": "job:up:count",
+						"type": "recording",
+						"query": "count_scalar(up)",
+						"error": "1:1: parse error: unknown function with name \"count_scalar\""
+					}]
+				},
+				"errorType": "",
+				"error": ""
+			}`,
+		},
+		"should only validate the rules of the requested namespace": {
+			url:            "https://localhost:8080/ruler/validate_rule_expressions?namespace=namespace2",
+			userID:         "user1",
+			expectedStatus: http.StatusOK,
+			expectedBody: `{
+				"status": "success",
+				"data": {
+					"rules": [{
+						"namespace": "namespace2",
+						"group": "group2",
+						"name": "job:up:count",
+						"type": "recording",
+						"query": "count_scalar(up)",
+						"error": "1:1: parse error: unknown function with name \"count_scalar\""
+					}]
+				},
+				"errorType": "",
+				"error": ""
+			}`,
+		},
+		"should return no rules if all the rules of the tenant can be parsed": {
+			url:            "https://localhost:8080/ruler/validate_rule_expressions",
+			userID:         "user2",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status": "success", "data": {"rules": []}, "errorType": "", "error": ""}`,
+		},
+		"should return no rules if the tenant has no rules": {
+			url:            "https://localhost:8080/ruler/validate_rule_expressions",
+			userID:         "user3",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status": "success", "data": {"rules": []}, "errorType": "", "error": ""}`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := requestFor(t, http.MethodGet, testData.url, nil, testData.userID)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp := w.Result()
+			body, _ := io.ReadAll(resp.Body)
+
+			require.Equal(t, testData.expectedStatus, resp.StatusCode)
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			require.JSONEq(t, testData.expectedBody, string(body))
+		})
+	}
+
+	t.Run("should fail if the tenant is missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://localhost:8080/ruler/validate_rule_expressions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
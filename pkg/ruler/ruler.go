@@ -7,12 +7,14 @@ package ruler
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"hash/fnv"
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -27,8 +29,10 @@ import (
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/tenant"
 	"github.com/pkg/errors"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
 	promRules "github.com/prometheus/prometheus/rules"
@@ -45,7 +49,9 @@ import (
 )
 
 var (
-	errInvalidTenantShardSize = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidTenantShardSize         = errors.New("invalid tenant shard size, the value must be greater or equal to 0")
+	errInvalidEvaluationJitter        = errors.New("invalid evaluation delay jitter, the value must be greater or equal to 0")
+	errMissingAlertmanagerURLGroupURL = errors.New("the URL of an Alertmanager URL group must be set")
 )
 
 const (
@@ -61,10 +67,16 @@ const (
 	rulerSyncReasonInitial    = "initial"
 	rulerSyncReasonPeriodic   = "periodic"
 	rulerSyncReasonRingChange = "ring-change"
+	rulerSyncReasonManual     = "manual"
 
 	// Limit errors
 	errMaxRuleGroupsPerUserLimitExceeded        = "per-user rule groups limit (limit: %d actual: %d) exceeded"
 	errMaxRulesPerRuleGroupPerUserLimitExceeded = "per-user rules per rule group limit (limit: %d actual: %d) exceeded"
+	errSourceTenantNotAllowed                   = "source tenant %s is not allowed for the tenant's federated rule groups (allowed: %s)"
+
+	// Reasons for rule groups skipped when loaded by the ruler.
+	ruleGroupSkippedReasonMaxRuleGroups    = "max_rule_groups"
+	ruleGroupSkippedReasonMaxRulesPerGroup = "max_rules_per_group"
 
 	// errors
 	errListAllUser = "unable to list the ruler users"
@@ -78,8 +90,12 @@ type Config struct {
 	ClientTLSConfig grpcclient.Config `yaml:"ruler_client"`
 	// How frequently to evaluate rules by default.
 	EvaluationInterval time.Duration `yaml:"evaluation_interval" category:"advanced"`
+	// Max offset by which the evaluation of each rule group is delayed.
+	EvaluationJitter time.Duration `yaml:"evaluation_delay_jitter" category:"experimental"`
 	// How frequently to poll for updated rules.
 	PollInterval time.Duration `yaml:"poll_interval" category:"advanced"`
+	// Minimum time between two rules syncs triggered via the HTTP API.
+	ManualSyncMinInterval time.Duration `yaml:"manual_sync_min_interval" category:"experimental"`
 	// Path to store rule files for prom manager.
 	RulePath string `yaml:"rule_path"`
 
@@ -91,8 +107,14 @@ type Config struct {
 	NotificationQueueCapacity int `yaml:"notification_queue_capacity" category:"advanced"`
 	// HTTP timeout duration when sending notifications to the Alertmanager.
 	NotificationTimeout time.Duration `yaml:"notification_timeout" category:"advanced"`
+	// Skip the Alertmanagers failing to receive notifications, until they recover.
+	AlertmanagerHealthTrackingEnabled bool `yaml:"alertmanager_health_tracking_enabled" category:"experimental"`
+	// How long to wait before sending a notification to an unhealthy Alertmanager, to check if it has recovered.
+	AlertmanagerUnhealthyBackoff time.Duration `yaml:"alertmanager_unhealthy_backoff" category:"experimental"`
 	// Client configs for interacting with the Alertmanager
 	Notifier NotifierConfig `yaml:"alertmanager_client"`
+	// Additional groups of Alertmanager URLs, each one with its own client configs.
+	AlertmanagerURLGroups []AlertmanagerURLGroupConfig `yaml:"alertmanager_url_groups" doc:"nocli|description=Additional groups of Alertmanager URLs to send notifications to, each one with its own client configuration. The notifications are sent to both the Alertmanagers configured with -ruler.alertmanager-url and the ones configured in these groups." category:"advanced"`
 
 	// Max time to tolerate outage for restoring "for" state of alert.
 	OutageTolerance time.Duration `yaml:"for_outage_tolerance" category:"advanced"`
@@ -100,9 +122,17 @@ type Config struct {
 	ForGracePeriod time.Duration `yaml:"for_grace_period" category:"advanced"`
 	// Minimum amount of time to wait before resending an alert to Alertmanager.
 	ResendDelay time.Duration `yaml:"resend_delay" category:"advanced"`
+	// How long to hold the notifications of the alerts restored when the ruler starts evaluating a tenant's rules.
+	StartupNotificationHoldPeriod time.Duration `yaml:"startup_notification_hold_period" category:"experimental"`
 
 	// Enable sharding rule groups.
 	Ring RingConfig `yaml:"ring"`
+	// Balance the estimated cost of the rule groups across rulers, instead of sharding them by hash.
+	CostAwareShardingEnabled bool `yaml:"cost_aware_sharding_enabled" category:"experimental"`
+	// Keep syncing the other tenants' rules when the rule groups of a tenant fail to be listed.
+	TolerateTenantListErrors bool `yaml:"tolerate_tenant_list_errors" category:"experimental"`
+	// Track the cumulative time spent evaluating the rule groups of each tenant.
+	TenantEvaluationTimeTrackingEnabled bool `yaml:"tenant_evaluation_time_tracking_enabled" category:"experimental"`
 
 	EnableAPI bool `yaml:"enable_api"`
 
@@ -116,6 +146,10 @@ type Config struct {
 	QueryFrontend QueryFrontendConfig `yaml:"query_frontend" category:"experimental"`
 
 	TenantFederation TenantFederationConfig `yaml:"tenant_federation"`
+
+	AlertStateHandoff AlertStateHandoffConfig `yaml:"alert_state_handoff" category:"experimental"`
+
+	NotificationDeduplication NotificationDeduplicationConfig `yaml:"notification_deduplication" category:"experimental"`
 }
 
 // Validate config and returns error on failure
@@ -124,6 +158,20 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 		return errInvalidTenantShardSize
 	}
 
+	if cfg.EvaluationJitter < 0 {
+		return errInvalidEvaluationJitter
+	}
+
+	for _, group := range cfg.AlertmanagerURLGroups {
+		if err := group.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := cfg.NotificationDeduplication.Validate(); err != nil {
+		return err
+	}
+
 	if err := cfg.ClientTLSConfig.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ruler gRPC client config")
 	}
@@ -137,36 +185,51 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.Notifier.RegisterFlags(f)
 	cfg.TenantFederation.RegisterFlags(f)
 	cfg.QueryFrontend.RegisterFlags(f)
+	cfg.AlertStateHandoff.RegisterFlags(f)
+	cfg.NotificationDeduplication.RegisterFlags(f)
 
 	cfg.ExternalURL.URL, _ = url.Parse("") // Must be non-nil
 	f.Var(&cfg.ExternalURL, "ruler.external.url", "URL of alerts return path.")
 	f.DurationVar(&cfg.EvaluationInterval, "ruler.evaluation-interval", 1*time.Minute, "How frequently to evaluate rules")
+	f.DurationVar(&cfg.EvaluationJitter, "ruler.evaluation-delay-jitter", 0, "Max offset by which the evaluation of each rule group is delayed, to spread the load of rule groups evaluated at the same interval on the query path. The offset is derived from the tenant, namespace and name of the rule group, so it's stable across restarts, and is capped to a quarter of the rule group evaluation interval. The evaluation timestamp of the rules is not affected, and the offset is excluded from the rule group evaluation time reported by the ruler APIs and the cortex_ruler_tenant_evaluation_seconds_total metric, but not from the prometheus_rule_group_last_duration_seconds one. 0 to disable.")
 	f.DurationVar(&cfg.PollInterval, "ruler.poll-interval", 1*time.Minute, "How frequently to poll for rule changes")
+	f.DurationVar(&cfg.ManualSyncMinInterval, "ruler.manual-sync-min-interval", 10*time.Second, "Minimum time between two rules syncs triggered via the POST /ruler/sync endpoint. The requests received within this time since the last completed sync are rejected. 0 to disable.")
 
 	f.StringVar(&cfg.AlertmanagerURL, "ruler.alertmanager-url", "", "Comma-separated list of URL(s) of the Alertmanager(s) to send notifications to. Each URL is treated as a separate group. Multiple Alertmanagers in HA per group can be supported by using DNS service discovery format. Basic auth is supported as part of the URL.")
 	f.DurationVar(&cfg.AlertmanagerRefreshInterval, "ruler.alertmanager-refresh-interval", 1*time.Minute, "How long to wait between refreshing DNS resolutions of Alertmanager hosts.")
 	f.IntVar(&cfg.NotificationQueueCapacity, "ruler.notification-queue-capacity", 10000, "Capacity of the queue for notifications to be sent to the Alertmanager.")
 	f.DurationVar(&cfg.NotificationTimeout, "ruler.notification-timeout", 10*time.Second, "HTTP timeout duration when sending notifications to the Alertmanager.")
+	f.BoolVar(&cfg.AlertmanagerHealthTrackingEnabled, "ruler.alertmanager-health-tracking-enabled", false, "Track the health of each Alertmanager based on the outcome of the recent notifications sent to it, and skip sending notifications to the unhealthy ones as long as there's at least one healthy Alertmanager.")
+	f.DurationVar(&cfg.AlertmanagerUnhealthyBackoff, "ruler.alertmanager-unhealthy-backoff", 30*time.Second, "How long to wait before sending a notification to an unhealthy Alertmanager, to check if it has recovered. Applies only when the Alertmanager health tracking is enabled.")
 
 	f.StringVar(&cfg.RulePath, "ruler.rule-path", "./data-ruler/", "Directory to store temporary rule files loaded by the Prometheus rule managers. This directory is not required to be persisted between restarts.")
 	f.BoolVar(&cfg.EnableAPI, "ruler.enable-api", true, "Enable the ruler config API.")
 	f.DurationVar(&cfg.OutageTolerance, "ruler.for-outage-tolerance", time.Hour, `Max time to tolerate outage for restoring "for" state of alert.`)
 	f.DurationVar(&cfg.ForGracePeriod, "ruler.for-grace-period", 10*time.Minute, `Minimum duration between alert and restored "for" state. This is maintained only for alerts with configured "for" time greater than grace period.`)
 	f.DurationVar(&cfg.ResendDelay, "ruler.resend-delay", time.Minute, `Minimum amount of time to wait before resending an alert to Alertmanager.`)
+	f.DurationVar(&cfg.StartupNotificationHoldPeriod, "ruler.startup-notification-hold-period", 0, `How long to hold the notifications of the alerts which were already active before the ruler started evaluating the tenant's rules, and whose "for" state has been restored, to avoid a burst of notifications for alerts likely already notified, eg. during a rollout. The held notifications are sent after this period, while the alerts becoming active in the meantime are notified as usual. 0 to disable.`)
 
 	f.Var(&cfg.EnabledTenants, "ruler.enabled-tenants", "Comma separated list of tenants whose rules this ruler can evaluate. If specified, only these tenants will be handled by ruler, otherwise this ruler can process rules from all tenants. Subject to sharding.")
 	f.Var(&cfg.DisabledTenants, "ruler.disabled-tenants", "Comma separated list of tenants whose rules this ruler cannot evaluate. If specified, a ruler that would normally pick the specified tenant(s) for processing will ignore them instead. Subject to sharding.")
 
+	f.BoolVar(&cfg.CostAwareShardingEnabled, "ruler.cost-aware-sharding-enabled", false, "Balance the estimated cost, based on the number of rules, of each tenant's rule groups across rulers instead of distributing rule groups by hash. The rule groups of all tenants are loaded by each ruler, to estimate their cost, on every rules sync.")
+	f.BoolVar(&cfg.TolerateTenantListErrors, "ruler.tolerate-tenant-list-errors", false, "Keep syncing the rules of the other tenants when the rule groups of a tenant fail to be listed from the storage, instead of failing the whole rules sync. The rule groups of the failed tenant run by the ruler at the previous sync are kept running.")
+	f.BoolVar(&cfg.TenantEvaluationTimeTrackingEnabled, "ruler.tenant-evaluation-time-tracking-enabled", false, "Track the cumulative time spent evaluating the rule groups of each tenant run by the ruler as a per-tenant metric, eg. for billing and chargeback. The time is approximated from the duration of the last evaluation of each rule group, on every rules sync.")
 	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per-tenant metric and as an info level log message.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
 
 type rulerMetrics struct {
-	listRules       prometheus.Histogram
-	loadRuleGroups  prometheus.Histogram
-	ringCheckErrors prometheus.Counter
-	rulerSync       *prometheus.CounterVec
+	listRules                prometheus.Histogram
+	loadRuleGroups           prometheus.Histogram
+	ringCheckErrors          prometheus.Counter
+	rulerSync                *prometheus.CounterVec
+	ruleGroupsBehindSchedule prometheus.Gauge
+	ruleGroupsSkipped        *prometheus.CounterVec
+	listRulesTenantFailures  prometheus.Counter
+	notificationsSuppressed  prometheus.Counter
+	ownedRuleGroups          *prometheus.GaugeVec
 }
 
 func newRulerMetrics(reg prometheus.Registerer) *rulerMetrics {
@@ -189,6 +252,26 @@ func newRulerMetrics(reg prometheus.Registerer) *rulerMetrics {
 			Name: "cortex_ruler_sync_rules_total",
 			Help: "Total number of times the ruler sync operation triggered.",
 		}, []string{"reason"}),
+		ruleGroupsBehindSchedule: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ruler_rule_groups_behind_schedule",
+			Help: "Number of rule groups, run by this ruler, whose last evaluation is older than their evaluation interval plus a slack. Updated on every rules sync.",
+		}),
+		ruleGroupsSkipped: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_rule_groups_skipped_total",
+			Help: "Total number of rule groups, owned by this ruler, skipped when loaded because the tenant exceeds its limits.",
+		}, []string{"reason"}),
+		listRulesTenantFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_list_rules_tenant_failures_total",
+			Help: "Total number of times the rule groups of a tenant failed to be listed, while syncing the rules tolerating the tenants' listing errors.",
+		}),
+		ownedRuleGroups: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ruler_owned_rule_groups",
+			Help: "Number of rule groups owned by this ruler for each tenant. Updated on every rules sync.",
+		}, []string{"user"}),
+		notificationsSuppressed: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_notifications_suppressed_total",
+			Help: "Total number of times the alert notifications of a rule group have not been sent because the ring shows that another ruler owns the rule group.",
+		}),
 	}
 }
 
@@ -199,6 +282,8 @@ type MultiTenantManager interface {
 	SyncRuleGroups(ctx context.Context, ruleGroups map[string]rulespb.RuleGroupList)
 	// GetRules fetches rules for a particular tenant (userID).
 	GetRules(userID string) []*promRules.Group
+	// RestoreAlertStates sets the alert states, handed off by other rulers, to restore in the tenant's rule groups.
+	RestoreAlertStates(userID string, groups []*GroupStateDesc)
 	// Stop stops all Manager components.
 	Stop()
 	// ValidateRuleGroup validates a rulegroup
@@ -242,6 +327,9 @@ type Ruler struct {
 	manager    MultiTenantManager
 	limits     RulesLimits
 
+	// Used to evaluate the alerting rules previewed by the tenants.
+	queryFunc promRules.QueryFunc
+
 	metrics *rulerMetrics
 
 	subservices        *services.Manager
@@ -250,28 +338,62 @@ type Ruler struct {
 	// Pool of clients used to connect to other ruler replicas.
 	clientsPool ClientsPool
 
+	// The rules sync manually triggered, if any is in progress, and when the last one completed.
+	// Manual syncs are run by the main loop, so that they're never run concurrently with the other syncs.
+	manualSyncMtx      sync.Mutex
+	manualSync         *manualRulesSync
+	lastManualSyncDone time.Time
+	manualSyncs        chan *manualRulesSync
+
+	// Rule groups owned by this ruler at the last sync. Only tracked when the alert states handoff is enabled.
+	ownedRuleGroupsMtx sync.Mutex
+	ownedRuleGroups    map[string]rulespb.RuleGroupList
+
+	// Rule groups synced at the last sync. Only tracked when tolerating the tenants' listing errors.
+	syncedRuleGroupsMtx sync.Mutex
+	syncedRuleGroups    map[string]rulespb.RuleGroupList
+
+	// Whether the rules have been loaded into the manager by a sync, and the number of tenants and
+	// rule groups loaded by the last sync. Reported by the readiness handler.
+	rulesSyncStatusMtx sync.Mutex
+	rulesSynced        bool
+	rulesSyncResult    RulesSyncResult
+
+	// Tenants whose number of owned rule groups is exported, as of the last sync.
+	ownedRuleGroupsTenantsMtx sync.Mutex
+	ownedRuleGroupsTenants    map[string]struct{}
+
 	allowedTenants *util.AllowedTenants
 
+	// Cumulative time spent evaluating the rule groups of each tenant. Nil if the tracking is disabled.
+	tenantEvaluationTime *tenantEvaluationTimeTracker
+
 	registry prometheus.Registerer
 	logger   log.Logger
 }
 
 // NewRuler creates a new ruler from a distributor and chunk store.
-func NewRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits) (*Ruler, error) {
-	return newRuler(cfg, manager, reg, logger, ruleStore, limits, newRulerClientPool(cfg.ClientTLSConfig, logger, reg))
+func NewRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits, queryFunc promRules.QueryFunc) (*Ruler, error) {
+	return newRuler(cfg, manager, reg, logger, ruleStore, limits, queryFunc, newRulerClientPool(cfg.ClientTLSConfig, logger, reg))
 }
 
-func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits, clientPool ClientsPool) (*Ruler, error) {
+func newRuler(cfg Config, manager MultiTenantManager, reg prometheus.Registerer, logger log.Logger, ruleStore rulestore.RuleStore, limits RulesLimits, queryFunc promRules.QueryFunc, clientPool ClientsPool) (*Ruler, error) {
 	ruler := &Ruler{
 		cfg:            cfg,
 		store:          ruleStore,
 		manager:        manager,
+		queryFunc:      queryFunc,
 		registry:       reg,
 		logger:         logger,
 		limits:         limits,
 		clientsPool:    clientPool,
 		allowedTenants: util.NewAllowedTenants(cfg.EnabledTenants, cfg.DisabledTenants),
 		metrics:        newRulerMetrics(reg),
+		manualSyncs:    make(chan *manualRulesSync, 1),
+	}
+
+	if cfg.TenantEvaluationTimeTrackingEnabled {
+		ruler.tenantEvaluationTime = newTenantEvaluationTimeTracker(reg)
 	}
 
 	if len(cfg.EnabledTenants) > 0 {
@@ -355,6 +477,10 @@ func (r *Ruler) starting(ctx context.Context) error {
 // Stop stops the Ruler.
 // Each function of the ruler is terminated before leaving the ring
 func (r *Ruler) stopping(_ error) error {
+	if r.cfg.AlertStateHandoff.Enabled {
+		r.handoffAlertStates()
+	}
+
 	r.manager.Stop()
 
 	if r.subservices != nil {
@@ -368,13 +494,20 @@ type sender interface {
 }
 
 // SendAlerts implements a rules.NotifyFunc for a Notifier.
-// It filters any non-firing alerts from the input.
+// It filters any non-firing alerts from the input. If the dedup key annotation is set, it's attached to
+// the notifications with a key which is stable across rulers. The notifications of a rule group are not
+// sent if the context suppresses them, eg. because the rule group is owned by another ruler.
 //
 // Copied from Prometheus's main.go.
-func SendAlerts(n sender, externalURL string) promRules.NotifyFunc {
+func SendAlerts(n sender, externalURL, dedupKeyAnnotation string) promRules.NotifyFunc {
 	return func(ctx context.Context, expr string, alerts ...*promRules.Alert) {
 		var res []*notifier.Alert
 
+		group := notifiedRuleGroup(ctx)
+		if group != nil && notificationsSuppressed(ctx, group) {
+			return
+		}
+
 		for _, alert := range alerts {
 			a := &notifier.Alert{
 				StartsAt:     alert.FiredAt,
@@ -382,6 +515,9 @@ func SendAlerts(n sender, externalURL string) promRules.NotifyFunc {
 				Annotations:  alert.Annotations,
 				GeneratorURL: externalURL + strutil.TableLinkForExpression(expr),
 			}
+			if dedupKeyAnnotation != "" && group != nil {
+				a.Annotations = labels.NewBuilder(alert.Annotations).Set(dedupKeyAnnotation, notificationDedupKey(group, alert.Labels)).Labels()
+			}
 			if !alert.ResolvedAt.IsZero() {
 				a.EndsAt = alert.ResolvedAt
 			} else {
@@ -412,14 +548,24 @@ func tokenForGroup(g *rulespb.RuleGroupDesc) uint32 {
 }
 
 func instanceOwnsRuleGroup(r ring.ReadRing, g *rulespb.RuleGroupDesc, instanceAddr string) (bool, error) {
+	owner, err := ruleGroupOwner(r, g)
+	if err != nil {
+		return false, err
+	}
+
+	return owner.Addr == instanceAddr, nil
+}
+
+// ruleGroupOwner returns the ruler instance owning the rule group according to the input ring.
+func ruleGroupOwner(r ring.ReadRing, g *rulespb.RuleGroupDesc) (ring.InstanceDesc, error) {
 	hash := tokenForGroup(g)
 
 	rlrs, err := r.Get(hash, RingOp, nil, nil, nil)
 	if err != nil {
-		return false, errors.Wrap(err, "error reading ring to verify rule group ownership")
+		return ring.InstanceDesc{}, errors.Wrap(err, "error reading ring to verify rule group ownership")
 	}
 
-	return rlrs.Instances[0].Addr == instanceAddr, nil
+	return rlrs.Instances[0], nil
 }
 
 func (r *Ruler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -443,6 +589,8 @@ func (r *Ruler) run(ctx context.Context) error {
 			return nil
 		case <-tick.C:
 			r.syncRules(ctx, rulerSyncReasonPeriodic)
+		case inflight := <-r.manualSyncs:
+			r.runManualSync(ctx, inflight)
 		case <-ringTicker.C:
 			// We ignore the error because in case of error it will return an empty
 			// replication set which we use to compare with the previous state.
@@ -458,24 +606,150 @@ func (r *Ruler) run(ctx context.Context) error {
 	}
 }
 
+// runManualSync syncs the rules on behalf of the requests waiting for the input sync, and notifies them once completed.
+func (r *Ruler) runManualSync(ctx context.Context, inflight *manualRulesSync) {
+	inflight.configs, inflight.err = r.syncRulesAndReport(ctx, rulerSyncReasonManual)
+
+	r.manualSyncMtx.Lock()
+	r.manualSync = nil
+	r.lastManualSyncDone = time.Now()
+	r.manualSyncMtx.Unlock()
+	close(inflight.done)
+}
+
 func (r *Ruler) syncRules(ctx context.Context, reason string) {
+	_, _ = r.syncRulesAndReport(ctx, reason)
+}
+
+// syncRulesAndReport syncs the rules and returns the rule groups owned by this ruler.
+// Errors are logged too, so the caller doesn't need to.
+func (r *Ruler) syncRulesAndReport(ctx context.Context, reason string) (map[string]rulespb.RuleGroupList, error) {
 	level.Debug(r.logger).Log("msg", "syncing rules", "reason", reason)
 	r.metrics.rulerSync.WithLabelValues(reason).Inc()
 
 	configs, err := r.listRules(ctx)
 	if err != nil {
 		level.Error(r.logger).Log("msg", "unable to list rules", "err", err)
-		return
+		return nil, errors.Wrap(err, "unable to list rules")
 	}
 
-	err = r.loadRuleGroups(ctx, configs)
-	if err != nil {
-		level.Error(r.logger).Log("msg", "unable to load rules owned by this ruler", "err", err)
-		return
+	// When sharding by cost, rule groups have already been loaded to estimate their cost.
+	if !r.cfg.CostAwareShardingEnabled {
+		err = r.loadRuleGroups(ctx, configs)
+		if err != nil {
+			level.Error(r.logger).Log("msg", "unable to load rules owned by this ruler", "err", err)
+			return nil, errors.Wrap(err, "unable to load rules owned by this ruler")
+		}
+	}
+
+	r.removeRuleGroupsExceedingMaxRulesPerRuleGroup(configs)
+
+	if r.cfg.AlertStateHandoff.Enabled {
+		r.restoreHandedOffAlertStates(ctx, configs)
 	}
 
 	// This will also delete local group files for users that are no longer in 'configs' map.
-	r.manager.SyncRuleGroups(ctx, configs)
+	r.manager.SyncRuleGroups(r.notificationContext(ctx), configs)
+
+	if r.cfg.TolerateTenantListErrors {
+		r.syncedRuleGroupsMtx.Lock()
+		r.syncedRuleGroups = configs
+		r.syncedRuleGroupsMtx.Unlock()
+	}
+
+	r.updateRuleGroupsBehindSchedule(configs, time.Now())
+	r.updateTenantEvaluationTime(configs)
+	r.updateRulesSyncStatus(configs)
+	return configs, nil
+}
+
+// updateRulesSyncStatus records that the rules have been loaded into the manager, so that the ruler is
+// reported as ready. The initial sync usually sets it, but if it fails the next successful sync does.
+func (r *Ruler) updateRulesSyncStatus(configs map[string]rulespb.RuleGroupList) {
+	result := RulesSyncResult{Tenants: len(configs)}
+	for _, groups := range configs {
+		result.RuleGroups += len(groups)
+	}
+
+	r.rulesSyncStatusMtx.Lock()
+	defer r.rulesSyncStatusMtx.Unlock()
+
+	r.rulesSynced = true
+	r.rulesSyncResult = result
+}
+
+// updateRuleGroupsBehindSchedule updates the number of rule groups, run by this ruler, which are falling
+// behind their evaluation schedule.
+func (r *Ruler) updateRuleGroupsBehindSchedule(configs map[string]rulespb.RuleGroupList, now time.Time) {
+	behind := 0
+	for userID := range configs {
+		for _, group := range r.manager.GetRules(userID) {
+			if isRuleGroupBehindSchedule(group.GetLastEvaluation(), group.Interval(), now) {
+				behind++
+			}
+		}
+	}
+
+	r.metrics.ruleGroupsBehindSchedule.Set(float64(behind))
+}
+
+// updateTenantEvaluationTime accounts the time spent evaluating the rule groups of each tenant, run by this
+// ruler, since the previous rules sync. Tenants whose rule groups are not run by this ruler anymore are not
+// tracked anymore, so that their series don't leak.
+func (r *Ruler) updateTenantEvaluationTime(configs map[string]rulespb.RuleGroupList) {
+	if r.tenantEvaluationTime == nil {
+		return
+	}
+
+	userIDs := make(map[string]struct{}, len(configs))
+	for userID := range configs {
+		userIDs[userID] = struct{}{}
+
+		rules := r.manager.GetRules(userID)
+		groups := make([]evaluatedRuleGroup, 0, len(rules))
+		for _, group := range rules {
+			groups = append(groups, ruleGroupWithoutJitter{Group: group, evaluationTime: r.groupEvaluationTime(userID, group)})
+		}
+
+		r.tenantEvaluationTime.update(userID, groups)
+	}
+
+	r.tenantEvaluationTime.removeMissingUsers(userIDs)
+}
+
+// groupEvaluationTime returns the duration of the last evaluation of the input rule group, excluding
+// the time it has been delayed by the evaluation jitter, which is not spent evaluating it.
+func (r *Ruler) groupEvaluationTime(userID string, g *promRules.Group) time.Duration {
+	if r.cfg.EvaluationJitter <= 0 {
+		return g.GetEvaluationTime()
+	}
+
+	offset := evaluationJitterForGroup(ruleGroupDescFromGroup(userID, g), r.cfg.EvaluationJitter, g.Interval())
+	return evaluationTimeWithoutJitter(g, offset)
+}
+
+// ruleGroupWithoutJitter is a rule group whose evaluation time excludes the evaluation jitter.
+type ruleGroupWithoutJitter struct {
+	*promRules.Group
+	evaluationTime time.Duration
+}
+
+func (g ruleGroupWithoutJitter) GetEvaluationTime() time.Duration {
+	return g.evaluationTime
+}
+
+// isRuleGroupBehindSchedule returns whether a rule group, last evaluated at the input time, has missed
+// at least one evaluation. Rule groups never evaluated are not considered behind schedule, because
+// they may have just been loaded.
+func isRuleGroupBehindSchedule(lastEvaluation time.Time, interval time.Duration, now time.Time) bool {
+	if lastEvaluation.IsZero() || interval <= 0 {
+		return false
+	}
+
+	// The slack is a whole interval, so that a rule group evaluation taking a bit longer than usual
+	// isn't considered a scheduling lag.
+	slack := interval
+	return now.Sub(lastEvaluation) > interval+slack
 }
 
 func (r *Ruler) loadRuleGroups(ctx context.Context, configs map[string]rulespb.RuleGroupList) error {
@@ -530,6 +804,7 @@ func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGr
 	}
 
 	if len(userRings) == 0 {
+		r.updateOwnedRuleGroups(users, nil)
 		return nil, nil
 	}
 
@@ -553,10 +828,36 @@ func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGr
 			for userID := range userCh {
 				groups, err := r.store.ListRuleGroupsForUserAndNamespace(gctx, userID, "")
 				if err != nil {
-					return errors.Wrapf(err, "failed to fetch rule groups for user %s", userID)
+					if !r.cfg.TolerateTenantListErrors {
+						return errors.Wrapf(err, "failed to fetch rule groups for user %s", userID)
+					}
+
+					// Keep running the rule groups previously synced, instead of stopping them because of a
+					// (likely transient) storage error. They're not filtered again, so they could be run until
+					// the next successful sync even if they're not owned by this ruler anymore.
+					r.metrics.listRulesTenantFailures.Inc()
+					previous := r.getSyncedRuleGroups(userID)
+					level.Warn(r.logger).Log("msg", "failed to fetch rule groups for user, keeping the rule groups previously synced", "user", userID, "rule_groups", len(previous), "err", err)
+
+					if len(previous) > 0 {
+						mu.Lock()
+						result[userID] = previous
+						mu.Unlock()
+					}
+					continue
 				}
 
-				filtered := filterRuleGroups(userID, groups, userRings[userID], r.lifecycler.GetInstanceAddr(), r.logger, r.metrics.ringCheckErrors)
+				var filtered []*rulespb.RuleGroupDesc
+				if r.cfg.CostAwareShardingEnabled {
+					// The cost of a rule group is estimated from its rules, so they need to be loaded before filtering.
+					if err := r.store.LoadRuleGroups(gctx, map[string]rulespb.RuleGroupList{userID: groups}); err != nil {
+						return errors.Wrapf(err, "failed to load rule groups for user %s", userID)
+					}
+					filtered = filterRuleGroupsByCost(userID, groups, userRings[userID], r.lifecycler.GetInstanceAddr(), r.logger, r.metrics.ringCheckErrors)
+				} else {
+					filtered = filterRuleGroups(userID, groups, userRings[userID], r.lifecycler.GetInstanceAddr(), r.logger, r.metrics.ringCheckErrors)
+				}
+				filtered = r.removeRuleGroupsExceedingMaxRuleGroups(userID, groups, filtered)
 				if len(filtered) == 0 {
 					continue
 				}
@@ -570,11 +871,48 @@ func (r *Ruler) listRulesSharded(ctx context.Context) (map[string]rulespb.RuleGr
 	}
 
 	err = g.Wait()
+	if err == nil {
+		r.updateOwnedRuleGroups(users, result)
+	}
 	return result, err
 }
 
+// updateOwnedRuleGroups updates the number of rule groups owned by this ruler for each allowed tenant.
+// Tenants whose rule groups are all owned by other rulers are tracked as 0, while the tenants not
+// listed anymore are removed.
+func (r *Ruler) updateOwnedRuleGroups(users []string, owned map[string]rulespb.RuleGroupList) {
+	r.ownedRuleGroupsTenantsMtx.Lock()
+	defer r.ownedRuleGroupsTenantsMtx.Unlock()
+
+	tenants := make(map[string]struct{}, len(users))
+	for _, userID := range users {
+		if !r.allowedTenants.IsAllowed(userID) {
+			continue
+		}
+
+		tenants[userID] = struct{}{}
+		r.metrics.ownedRuleGroups.WithLabelValues(userID).Set(float64(len(owned[userID])))
+	}
+
+	for userID := range r.ownedRuleGroupsTenants {
+		if _, ok := tenants[userID]; !ok {
+			r.metrics.ownedRuleGroups.DeleteLabelValues(userID)
+		}
+	}
+	r.ownedRuleGroupsTenants = tenants
+}
+
+// getSyncedRuleGroups returns the rule groups of the user synced at the last sync.
+func (r *Ruler) getSyncedRuleGroups(userID string) rulespb.RuleGroupList {
+	r.syncedRuleGroupsMtx.Lock()
+	defer r.syncedRuleGroupsMtx.Unlock()
+
+	return r.syncedRuleGroups[userID]
+}
+
 // filterRuleGroups returns map of rule groups that given instance "owns" based on supplied ring.
-// This function only uses User, Namespace, and Name fields of individual RuleGroups.
+// This function only uses User, Namespace, and Name fields of individual RuleGroups. Disabled rule groups
+// are owned like any other rule group, so that they are listed, but they are not evaluated.
 //
 // Reason why this function is not a method on Ruler is to make sure we don't accidentally use r.ring,
 // but only ring passed as parameter.
@@ -600,6 +938,111 @@ func filterRuleGroups(userID string, ruleGroups []*rulespb.RuleGroupDesc, ring r
 	return result
 }
 
+// filterRuleGroupsByCost returns the rule groups that given instance "owns" when balancing the estimated
+// cost of the tenant's rule groups across the instances of the supplied ring. The rule groups are expected
+// to be loaded, since their cost is estimated from their rules.
+//
+// Reason why this function is not a method on Ruler is to make sure we don't accidentally use r.ring,
+// but only ring passed as parameter.
+func filterRuleGroupsByCost(userID string, ruleGroups []*rulespb.RuleGroupDesc, ring ring.ReadRing, instanceAddr string, log log.Logger, ringCheckErrors prometheus.Counter) []*rulespb.RuleGroupDesc {
+	owners, err := ruleGroupOwnersByCost(userID, ruleGroups, ring)
+	if err != nil {
+		ringCheckErrors.Inc()
+		level.Error(log).Log("msg", "failed to check which rule groups the ruler replica owns", "user", userID, "err", err)
+		return nil
+	}
+
+	var result []*rulespb.RuleGroupDesc
+	for i, g := range ruleGroups {
+		if owners[i] == instanceAddr {
+			level.Debug(log).Log("msg", "rule group owned", "user", g.User, "namespace", g.Namespace, "name", g.Name)
+			result = append(result, g)
+		} else {
+			level.Debug(log).Log("msg", "rule group not owned, ignoring", "user", g.User, "namespace", g.Namespace, "name", g.Name)
+		}
+	}
+
+	return result
+}
+
+// ruleGroupOwnersByCost returns the address of the instance owning each of the input rule groups, balancing
+// the estimated cost of the rule groups across the instances of the supplied ring. The assignment is
+// deterministic: given the same rule groups and the same ring, each rule group is owned by the same instance.
+func ruleGroupOwnersByCost(userID string, ruleGroups []*rulespb.RuleGroupDesc, r ring.ReadRing) ([]string, error) {
+	rs, err := r.GetReplicationSetForOperation(RingOp)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading ring to verify rule group ownership")
+	}
+	if len(rs.Instances) == 0 {
+		return nil, errors.New("no ruler instances available in the ring")
+	}
+
+	// Order the instances by a per-tenant hash, so that the most expensive rule groups of different
+	// tenants don't all land on the same instance.
+	instances := make([]string, 0, len(rs.Instances))
+	for _, instance := range rs.Instances {
+		instances = append(instances, instance.Addr)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		hi, hj := tokenForTenantInstance(userID, instances[i]), tokenForTenantInstance(userID, instances[j])
+		if hi != hj {
+			return hi < hj
+		}
+		return instances[i] < instances[j]
+	})
+
+	// Assign the most expensive rule groups first, each one to the instance with the lowest cost so far.
+	order := make([]int, len(ruleGroups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		gi, gj := ruleGroups[order[i]], ruleGroups[order[j]]
+		if ci, cj := ruleGroupCost(gi), ruleGroupCost(gj); ci != cj {
+			return ci > cj
+		}
+		if gi.Namespace != gj.Namespace {
+			return gi.Namespace < gj.Namespace
+		}
+		return gi.Name < gj.Name
+	})
+
+	costs := make([]int, len(instances))
+	owners := make([]string, len(ruleGroups))
+	for _, idx := range order {
+		target := 0
+		for i := range costs {
+			if costs[i] < costs[target] {
+				target = i
+			}
+		}
+
+		costs[target] += ruleGroupCost(ruleGroups[idx])
+		owners[idx] = instances[target]
+	}
+
+	return owners, nil
+}
+
+// ruleGroupCost returns the estimated cost of evaluating the rule group.
+func ruleGroupCost(g *rulespb.RuleGroupDesc) int {
+	if n := len(g.Rules); n > 0 {
+		return n
+	}
+	return 1
+}
+
+func tokenForTenantInstance(userID, instanceAddr string) uint32 {
+	hasher := fnv.New32a()
+
+	// Hasher never returns err.
+	_, _ = hasher.Write([]byte(userID))
+	_, _ = hasher.Write(sep)
+	_, _ = hasher.Write([]byte(instanceAddr))
+
+	return hasher.Sum32()
+}
+
 // GetRules retrieves the running rules from this ruler and all running rulers in the ring.
 func (r *Ruler) GetRules(ctx context.Context) ([]*GroupStateDesc, error) {
 	userID, err := tenant.TenantID(ctx)
@@ -694,8 +1137,13 @@ func (r *Ruler) getLocalRules(userID string) ([]*GroupStateDesc, error) {
 			},
 
 			EvaluationTimestamp: group.GetLastEvaluation(),
-			EvaluationDuration:  group.GetEvaluationTime(),
+			EvaluationDuration:  r.groupEvaluationTime(userID, group),
 		}
+
+		// The evaluation delay is the rule group's one, if any, or the tenant's one otherwise.
+		evaluationDelay := group.EvaluationDelay()
+		groupDesc.Group.SetEvaluationDelay(&evaluationDelay)
+
 		for _, r := range group.Rules() {
 			lastError := ""
 			if r.LastError() != nil {
@@ -773,6 +1221,12 @@ func (r *Ruler) AssertMaxRuleGroups(userID string, rg int) error {
 	return fmt.Errorf(errMaxRuleGroupsPerUserLimitExceeded, limit, rg)
 }
 
+// AssertAllowedSourceTenants checks that the source tenants of a federated rule group of the user
+// are allowed and returns an error if not.
+func (r *Ruler) AssertAllowedSourceTenants(userID string, sourceTenants []string) error {
+	return validateSourceTenants(userID, sourceTenants, r.limits.RulerAllowedSourceTenants(userID))
+}
+
 // AssertMaxRulesPerRuleGroup limit has not been reached compared to the current
 // number of rules in a rule group in input and returns an error if so.
 func (r *Ruler) AssertMaxRulesPerRuleGroup(userID string, rules int) error {
@@ -788,6 +1242,68 @@ func (r *Ruler) AssertMaxRulesPerRuleGroup(userID string, rules int) error {
 	return fmt.Errorf(errMaxRulesPerRuleGroupPerUserLimitExceeded, limit, rules)
 }
 
+// removeRuleGroupsExceedingMaxRuleGroups returns the owned rule groups of the user, removing the ones exceeding
+// the max number of rule groups per tenant. The rule groups to keep are picked from all the tenant's rule groups,
+// ordered by namespace and name, so that all rulers agree on them. Only the removed rule groups owned by this ruler
+// are accounted as skipped, so that each of them is accounted once across rulers.
+func (r *Ruler) removeRuleGroupsExceedingMaxRuleGroups(userID string, all, owned []*rulespb.RuleGroupDesc) []*rulespb.RuleGroupDesc {
+	err := r.AssertMaxRuleGroups(userID, len(all))
+	if err == nil {
+		return owned
+	}
+
+	sorted := append([]*rulespb.RuleGroupDesc(nil), all...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Namespace != sorted[j].Namespace {
+			return sorted[i].Namespace < sorted[j].Namespace
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	exceeding := make(map[*rulespb.RuleGroupDesc]struct{}, len(sorted))
+	for _, g := range sorted[r.limits.RulerMaxRuleGroupsPerTenant(userID):] {
+		exceeding[g] = struct{}{}
+	}
+
+	kept := make([]*rulespb.RuleGroupDesc, 0, len(owned))
+	for _, g := range owned {
+		if _, ok := exceeding[g]; !ok {
+			kept = append(kept, g)
+		}
+	}
+
+	if skipped := len(owned) - len(kept); skipped > 0 {
+		r.metrics.ruleGroupsSkipped.WithLabelValues(ruleGroupSkippedReasonMaxRuleGroups).Add(float64(skipped))
+		level.Warn(r.logger).Log("msg", "skipped rule groups exceeding the tenant's limit", "user", userID, "reason", ruleGroupSkippedReasonMaxRuleGroups, "rule_groups", len(all), "skipped", skipped, "err", err)
+	}
+	return kept
+}
+
+// removeRuleGroupsExceedingMaxRulesPerRuleGroup removes from the input loaded rule groups the ones with more rules
+// than the max number of rules per rule group of their tenant. Tenants left with no rule groups are removed too.
+func (r *Ruler) removeRuleGroupsExceedingMaxRulesPerRuleGroup(configs map[string]rulespb.RuleGroupList) {
+	for userID, groups := range configs {
+		kept := make(rulespb.RuleGroupList, 0, len(groups))
+		for _, g := range groups {
+			if err := r.AssertMaxRulesPerRuleGroup(userID, len(g.Rules)); err != nil {
+				level.Warn(r.logger).Log("msg", "skipped rule group exceeding the tenant's limit", "user", userID, "reason", ruleGroupSkippedReasonMaxRulesPerGroup, "namespace", g.Namespace, "group", g.Name, "rules", len(g.Rules), "err", err)
+				continue
+			}
+			kept = append(kept, g)
+		}
+
+		if skipped := len(groups) - len(kept); skipped > 0 {
+			r.metrics.ruleGroupsSkipped.WithLabelValues(ruleGroupSkippedReasonMaxRulesPerGroup).Add(float64(skipped))
+		}
+
+		if len(kept) == 0 {
+			delete(configs, userID)
+		} else {
+			configs[userID] = kept
+		}
+	}
+}
+
 func (r *Ruler) DeleteTenantConfiguration(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), r.logger)
 
@@ -823,7 +1339,7 @@ func (r *Ruler) ListAllRules(w http.ResponseWriter, req *http.Request) {
 	iter := make(chan interface{})
 
 	go func() {
-		util.StreamWriteYAMLResponse(w, iter, logger)
+		util.StreamWriteYAMLResponseWithCompression(w, req, iter, logger)
 		close(done)
 	}()
 
@@ -851,3 +1367,177 @@ func (r *Ruler) ListAllRules(w http.ResponseWriter, req *http.Request) {
 	close(iter)
 	<-done
 }
+
+// ListFailingRules returns the tenant's rules, running on all rulers in the ring, whose health
+// is not ok or whose last evaluation failed.
+func (r *Ruler) ListFailingRules(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	if _, err := tenant.TenantID(req.Context()); err != nil {
+		// When Mimir is running, it uses Auth Middleware for checking X-Scope-OrgID and injecting tenant into context.
+		// Auth Middleware sends http.StatusUnauthorized if X-Scope-OrgID is missing, so we do too here, for consistency.
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	groups, err := r.GetRules(req.Context())
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   &FailingRulesDiscovery{Rules: filterFailingRules(groups)},
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// filterFailingRules returns the rules whose health is not ok or whose last evaluation failed,
+// sorted by namespace and group. Rules within the same group keep their order.
+func filterFailingRules(groups []*GroupStateDesc) []*FailingRule {
+	sorted := make([]*GroupStateDesc, len(groups))
+	copy(sorted, groups)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Group.Namespace != sorted[j].Group.Namespace {
+			return sorted[i].Group.Namespace < sorted[j].Group.Namespace
+		}
+		return sorted[i].Group.Name < sorted[j].Group.Name
+	})
+
+	failing := []*FailingRule{}
+	for _, g := range sorted {
+		for _, rl := range g.ActiveRules {
+			if rl.GetHealth() == string(promRules.HealthGood) && rl.GetLastError() == "" {
+				continue
+			}
+
+			fr := &FailingRule{
+				Namespace:      g.Group.Namespace,
+				Group:          g.Group.Name,
+				Query:          rl.Rule.GetExpr(),
+				Health:         rl.GetHealth(),
+				LastError:      rl.GetLastError(),
+				LastEvaluation: rl.GetEvaluationTimestamp(),
+			}
+			if rl.Rule.GetAlert() != "" {
+				fr.Name = rl.Rule.GetAlert()
+				fr.Type = v1.RuleTypeAlerting
+			} else {
+				fr.Name = rl.Rule.GetRecord()
+				fr.Type = v1.RuleTypeRecording
+			}
+			failing = append(failing, fr)
+		}
+	}
+
+	return failing
+}
+
+// manualRulesSync is a rules sync triggered via the HTTP API, shared by all concurrent requests.
+type manualRulesSync struct {
+	done    chan struct{}
+	configs map[string]rulespb.RuleGroupList
+	err     error
+}
+
+// SyncRules triggers a sync of the rules and responds once the sync is completed. Concurrent
+// requests are coalesced into a single sync, to avoid overloading the rule storage, and the
+// requests received within the min interval since the last completed sync are rejected.
+func (r *Ruler) SyncRules(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	if r.State() != services.Running {
+		http.Error(w, "the ruler is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.manualSyncMtx.Lock()
+	inflight := r.manualSync
+	if inflight == nil {
+		if elapsed := time.Since(r.lastManualSyncDone); r.cfg.ManualSyncMinInterval > 0 && elapsed < r.cfg.ManualSyncMinInterval {
+			r.manualSyncMtx.Unlock()
+			http.Error(w, fmt.Sprintf("the rules have been synced %s ago, which is less than the min interval between syncs of %s", elapsed.Round(time.Millisecond), r.cfg.ManualSyncMinInterval), http.StatusTooManyRequests)
+			return
+		}
+
+		// The sync is run by the main loop, and shared by all the requests waiting for it, so it's
+		// not canceled when the request which triggered it is. The channel never blocks, because
+		// there's at most one sync in progress.
+		inflight = &manualRulesSync{done: make(chan struct{})}
+		r.manualSync = inflight
+		r.manualSyncs <- inflight
+	}
+	r.manualSyncMtx.Unlock()
+
+	select {
+	case <-inflight.done:
+	case <-req.Context().Done():
+		http.Error(w, req.Context().Err().Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if inflight.err != nil {
+		respondError(logger, w, inflight.err.Error())
+		return
+	}
+
+	result := &RulesSyncResult{Tenants: len(inflight.configs)}
+	for _, groups := range inflight.configs {
+		result.RuleGroups += len(groups)
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   result,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// Ready responds with 200 once the rules have been loaded into the manager by the initial sync, and with 503
+// before, so that the requests to list the rules aren't routed to a ruler which would return empty results,
+// eg. during rollouts. The response includes the number of tenants and rule groups loaded by the last sync.
+func (r *Ruler) Ready(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	r.rulesSyncStatusMtx.Lock()
+	synced, result := r.rulesSynced, r.rulesSyncResult
+	r.rulesSyncStatusMtx.Unlock()
+
+	resp := &response{Status: "success", Data: &result}
+	status := http.StatusOK
+	if !synced {
+		resp = &response{Status: "error", ErrorType: v1.ErrServer, Error: "the initial rules sync has not completed yet", Data: &result}
+		status = http.StatusServiceUnavailable
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
@@ -6,6 +6,7 @@
 package ruler
 
 import (
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -27,8 +28,10 @@ import (
 	"github.com/grafana/dskit/kv/consul"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
 	"github.com/prometheus/client_golang/prometheus"
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
 	"github.com/prometheus/prometheus/notifier"
@@ -77,15 +80,25 @@ func defaultRulerConfig(t testing.TB) Config {
 
 type ruleLimits struct {
 	evalDelay            time.Duration
+	evalInterval         time.Duration
 	tenantShard          int
 	maxRulesPerRuleGroup int
 	maxRuleGroups        int
+	allowedSourceTenants []string
+	notificationsRate    float64
+	maxConcurrentEvals   int
+	outOfOrderTolerance  time.Duration
+	evalTimeout          time.Duration
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
 	return r.evalDelay
 }
 
+func (r ruleLimits) RulerEvaluationInterval(_ string) time.Duration {
+	return r.evalInterval
+}
+
 func (r ruleLimits) RulerTenantShardSize(_ string) int {
 	return r.tenantShard
 }
@@ -98,6 +111,26 @@ func (r ruleLimits) RulerMaxRulesPerRuleGroup(_ string) int {
 	return r.maxRulesPerRuleGroup
 }
 
+func (r ruleLimits) RulerAllowedSourceTenants(_ string) []string {
+	return r.allowedSourceTenants
+}
+
+func (r ruleLimits) RulerMaxNotificationsPerSecond(_ string) float64 {
+	return r.notificationsRate
+}
+
+func (r ruleLimits) RulerMaxConcurrentEvaluations(_ string) int {
+	return r.maxConcurrentEvals
+}
+
+func (r ruleLimits) RulerOutOfOrderTolerance(_ string) time.Duration {
+	return r.outOfOrderTolerance
+}
+
+func (r ruleLimits) RulerEvaluationTimeout(_ string) time.Duration {
+	return r.evalTimeout
+}
+
 func testSetup() (storage.QueryableFunc, promRules.QueryFunc, Pusher, log.Logger, RulesLimits) {
 	noopQueryable := storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 		return storage.NoopQuerier(), nil
@@ -120,7 +153,7 @@ func newManager(t *testing.T, cfg Config) *DefaultMultiTenantManager {
 	noopQueryable, noopQueryFunc, pusher, logger, overrides := testSetup()
 
 	mngFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, overrides, nil)
-	manager, err := NewDefaultMultiTenantManager(cfg, mngFactory, prometheus.NewRegistry(), logger, nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, mngFactory, overrides, prometheus.NewRegistry(), logger, nil)
 	require.NoError(t, err)
 
 	return manager
@@ -169,10 +202,10 @@ func buildRuler(t *testing.T, cfg Config, storage rulestore.RuleStore, rulerAddr
 
 	reg := prometheus.NewRegistry()
 	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, overrides, reg)
-	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, reg, log.NewNopLogger(), nil)
+	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, overrides, reg, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
-	ruler, err := newRuler(cfg, manager, reg, logger, storage, overrides, newMockClientsPool(cfg, logger, reg, rulerAddrMap))
+	ruler, err := newRuler(cfg, manager, reg, logger, storage, overrides, noopQueryFunc, newMockClientsPool(cfg, logger, reg, rulerAddrMap))
 	require.NoError(t, err)
 	return ruler
 }
@@ -880,6 +913,192 @@ func TestSharding(t *testing.T) {
 	}
 }
 
+// staticReadRing is a ring.ReadRing returning a static set of instances for any operation.
+type staticReadRing struct {
+	ring.ReadRing
+	instances []ring.InstanceDesc
+}
+
+func (r staticReadRing) GetReplicationSetForOperation(_ ring.Operation) (ring.ReplicationSet, error) {
+	return ring.ReplicationSet{Instances: r.instances}, nil
+}
+
+func TestFilterRuleGroupsByCost(t *testing.T) {
+	const user = "user1"
+
+	newGroup := func(name string, numRules int) *rulespb.RuleGroupDesc {
+		g := &rulespb.RuleGroupDesc{User: user, Namespace: "namespace", Name: name}
+		for i := 0; i < numRules; i++ {
+			g.Rules = append(g.Rules, &rulespb.RuleDesc{Record: fmt.Sprintf("rule_%d", i), Expr: "up"})
+		}
+		return g
+	}
+
+	// Two very expensive rule groups and many cheap ones.
+	groups := []*rulespb.RuleGroupDesc{newGroup("heavy-1", 20), newGroup("heavy-2", 20)}
+	for i := 0; i < 20; i++ {
+		groups = append(groups, newGroup(fmt.Sprintf("light-%d", i), 1))
+	}
+
+	addrs := []string{"ruler-1:9095", "ruler-2:9095", "ruler-3:9095"}
+	rulerRing := staticReadRing{instances: []ring.InstanceDesc{{Addr: addrs[0]}, {Addr: addrs[1]}, {Addr: addrs[2]}}}
+
+	owned := map[string][]*rulespb.RuleGroupDesc{}
+	for _, addr := range addrs {
+		owned[addr] = filterRuleGroupsByCost(user, groups, rulerRing, addr, log.NewNopLogger(), prometheus.NewCounter(prometheus.CounterOpts{}))
+	}
+
+	// Each rule group should be owned by exactly one ruler.
+	owners := map[string]string{}
+	for addr, ownedGroups := range owned {
+		for _, g := range ownedGroups {
+			require.NotContains(t, owners, g.Name)
+			owners[g.Name] = addr
+		}
+	}
+	require.Len(t, owners, len(groups))
+
+	// The expensive rule groups should be owned by different rulers.
+	assert.NotEqual(t, owners["heavy-1"], owners["heavy-2"])
+
+	// The total cost should be balanced across rulers: 60 rules, 20 per ruler.
+	for addr, ownedGroups := range owned {
+		cost := 0
+		for _, g := range ownedGroups {
+			cost += ruleGroupCost(g)
+		}
+		assert.Equal(t, 20, cost, "ruler: %s", addr)
+	}
+
+	// The ownership should be stable regardless of the order of the rule groups and ring instances.
+	reversedGroups := make([]*rulespb.RuleGroupDesc, 0, len(groups))
+	for i := len(groups) - 1; i >= 0; i-- {
+		reversedGroups = append(reversedGroups, groups[i])
+	}
+	reversedRing := staticReadRing{instances: []ring.InstanceDesc{{Addr: addrs[2]}, {Addr: addrs[1]}, {Addr: addrs[0]}}}
+
+	for _, addr := range addrs {
+		for _, g := range filterRuleGroupsByCost(user, reversedGroups, reversedRing, addr, log.NewNopLogger(), prometheus.NewCounter(prometheus.CounterOpts{})) {
+			assert.Equal(t, owners[g.Name], addr, "group: %s", g.Name)
+		}
+	}
+}
+
+func TestRuler_OwnedRuleGroupsMetric(t *testing.T) {
+	const (
+		user1 = "user1"
+		user2 = "user2"
+
+		ruler1     = "ruler-1"
+		ruler1Addr = "1.1.1.1:9999"
+		ruler2     = "ruler-2"
+		ruler2Addr = "2.2.2.2:9999"
+	)
+
+	user1Group1 := &rulespb.RuleGroupDesc{User: user1, Namespace: "namespace", Name: "first"}
+	user1Group2 := &rulespb.RuleGroupDesc{User: user1, Namespace: "namespace", Name: "second"}
+	user2Group1 := &rulespb.RuleGroupDesc{User: user2, Namespace: "namespace", Name: "first"}
+
+	kvStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	cfg := Config{
+		Ring: RingConfig{
+			InstanceID:       ruler1,
+			InstanceAddr:     "1.1.1.1",
+			InstancePort:     9999,
+			KVStore:          kv.Config{Mock: kvStore},
+			HeartbeatTimeout: time.Minute,
+		},
+	}
+
+	r := buildRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{
+		user1: {user1Group1, user1Group2},
+		user2: {user2Group1},
+	}), nil)
+	r.limits = ruleLimits{}
+
+	// We start ruler's ring, but nothing else (not even lifecycler).
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r.ring))
+	t.Cleanup(r.ring.StopAsync)
+
+	setupRing := func(setup func(desc *ring.Desc)) {
+		require.NoError(t, kvStore.CAS(context.Background(), RulerRingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+			d, _ := in.(*ring.Desc)
+			if d == nil {
+				d = ring.NewDesc()
+			}
+			setup(d)
+			return d, true, nil
+		}))
+
+		// Wait a bit to make sure ruler's ring is updated.
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The first sync: ruler-1 owns all rule groups.
+	setupRing(func(desc *ring.Desc) {
+		desc.AddIngester(ruler1, ruler1Addr, "", sortTokens([]uint32{tokenForGroup(user1Group1) + 1, tokenForGroup(user1Group2) + 1, tokenForGroup(user2Group1) + 1}), ring.ACTIVE, time.Now())
+	})
+
+	_, err := r.listRules(context.Background())
+	require.NoError(t, err)
+
+	assert.NoError(t, prom_testutil.CollectAndCompare(r.metrics.ownedRuleGroups, strings.NewReader(`
+		# HELP cortex_ruler_owned_rule_groups Number of rule groups owned by this ruler for each tenant. Updated on every rules sync.
+		# TYPE cortex_ruler_owned_rule_groups gauge
+		cortex_ruler_owned_rule_groups{user="user1"} 2
+		cortex_ruler_owned_rule_groups{user="user2"} 1
+	`)))
+
+	// The second sync: ruler-2 has joined the ring and owns some of the rule groups,
+	// including all the rule groups of user2.
+	setupRing(func(desc *ring.Desc) {
+		desc.AddIngester(ruler1, ruler1Addr, "", sortTokens([]uint32{tokenForGroup(user1Group1) + 1}), ring.ACTIVE, time.Now())
+		desc.AddIngester(ruler2, ruler2Addr, "", sortTokens([]uint32{tokenForGroup(user1Group2) + 1, tokenForGroup(user2Group1) + 1}), ring.ACTIVE, time.Now())
+	})
+
+	_, err = r.listRules(context.Background())
+	require.NoError(t, err)
+
+	assert.NoError(t, prom_testutil.CollectAndCompare(r.metrics.ownedRuleGroups, strings.NewReader(`
+		# HELP cortex_ruler_owned_rule_groups Number of rule groups owned by this ruler for each tenant. Updated on every rules sync.
+		# TYPE cortex_ruler_owned_rule_groups gauge
+		cortex_ruler_owned_rule_groups{user="user1"} 1
+		cortex_ruler_owned_rule_groups{user="user2"} 0
+	`)))
+}
+
+func TestRuler_CostAwareSharding(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.CostAwareShardingEnabled = true
+
+	r := newTestRuler(t, cfg, newMockRuleStore(mockRules))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// The only ruler in the ring owns all rule groups, which are loaded to estimate their cost.
+	loaded, err := r.listRules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, loaded, len(mockRules))
+	for userID, groups := range loaded {
+		require.Len(t, groups, len(mockRules[userID]))
+		for i, g := range groups {
+			require.Equal(t, mockRules[userID][i].Name, g.Name)
+			require.Equal(t, mockRules[userID][i].Rules, g.Rules)
+		}
+	}
+
+	// The rule groups are running, even if they're not loaded again by the sync.
+	for userID := range mockRules {
+		require.Len(t, r.manager.GetRules(userID), len(mockRules[userID]))
+	}
+}
+
+func TestRuleGroupCost(t *testing.T) {
+	assert.Equal(t, 1, ruleGroupCost(&rulespb.RuleGroupDesc{}))
+	assert.Equal(t, 2, ruleGroupCost(&rulespb.RuleGroupDesc{Rules: []*rulespb.RuleDesc{{Record: "a"}, {Record: "b"}}}))
+}
+
 // User shuffle shard token.
 func userToken(user string, skip int) uint32 {
 	r := rand.New(rand.NewSource(util.ShuffleShardSeed(user, "")))
@@ -916,7 +1135,7 @@ func TestDeleteTenantRuleGroups(t *testing.T) {
 	require.Equal(t, 3, len(obj.Objects()))
 
 	cfg := defaultRulerConfig(t)
-	api, err := NewRuler(cfg, nil, nil, log.NewNopLogger(), rs, nil)
+	api, err := NewRuler(cfg, nil, nil, log.NewNopLogger(), rs, nil, nil)
 	require.NoError(t, err)
 
 	{
@@ -1038,6 +1257,167 @@ user2:
               expr: up`
 
 	require.YAMLEq(t, expectedResponseYaml, string(body))
+
+	t.Run("should compress the response with gzip if the client accepts it", func(t *testing.T) {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/rule_groups", nil, "")
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/yaml", resp.Header.Get("Content-Type"))
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+		gr, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gr)
+		require.NoError(t, err)
+
+		require.YAMLEq(t, expectedResponseYaml, string(body))
+	})
+}
+
+func TestRuler_ListFailingRules(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	rulerAddrMap := map[string]*Ruler{}
+
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+	// Ensure all rules are loaded before usage
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	router := mux.NewRouter()
+	router.Path("/ruler/failing_rules").Methods(http.MethodGet).HandlerFunc(r.ListFailingRules)
+
+	t.Run("should return the rules not evaluated yet, which have unknown health", func(t *testing.T) {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/failing_rules", nil, "user2")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.JSONEq(t, `{
+			"status": "success",
+			"data": {
+				"rules": [{
+					"namespace": "namespace1",
+					"group": "group1",
+					"name": "UP_RULE",
+					"query": "up",
+					"type": "recording",
+					"health": "unknown",
+					"lastError": "",
+					"lastEvaluation": "0001-01-01T00:00:00Z"
+				}]
+			},
+			"errorType": "",
+			"error": ""
+		}`, string(body))
+	})
+
+	t.Run("should fail if the tenant is missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://localhost:8080/ruler/failing_rules", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestFilterFailingRules(t *testing.T) {
+	lastEvaluation := time.Now()
+
+	groups := []*GroupStateDesc{
+		{
+			Group: &rulespb.RuleGroupDesc{Namespace: "namespace2", Name: "group1"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "healthy", Expr: "up"}, Health: "ok", EvaluationTimestamp: lastEvaluation},
+				{Rule: &rulespb.RuleDesc{Alert: "failing", Expr: "up < 1"}, Health: "err", LastError: "query timed out", EvaluationTimestamp: lastEvaluation},
+			},
+		},
+		{
+			Group: &rulespb.RuleGroupDesc{Namespace: "namespace1", Name: "group2"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "not_evaluated", Expr: "up"}, Health: "unknown"},
+			},
+		},
+		{
+			Group: &rulespb.RuleGroupDesc{Namespace: "namespace1", Name: "group1"},
+			ActiveRules: []*RuleStateDesc{
+				{Rule: &rulespb.RuleDesc{Record: "healthy_with_error", Expr: "up"}, Health: "ok", LastError: "some error", EvaluationTimestamp: lastEvaluation},
+				{Rule: &rulespb.RuleDesc{Alert: "healthy", Expr: "up < 1"}, Health: "ok", EvaluationTimestamp: lastEvaluation},
+			},
+		},
+	}
+
+	assert.Equal(t, []*FailingRule{
+		{Namespace: "namespace1", Group: "group1", Name: "healthy_with_error", Query: "up", Type: "recording", Health: "ok", LastError: "some error", LastEvaluation: lastEvaluation},
+		{Namespace: "namespace1", Group: "group2", Name: "not_evaluated", Query: "up", Type: "recording", Health: "unknown"},
+		{Namespace: "namespace2", Group: "group1", Name: "failing", Query: "up < 1", Type: "alerting", Health: "err", LastError: "query timed out", LastEvaluation: lastEvaluation},
+	}, filterFailingRules(groups))
+
+	// The input groups should not be reordered.
+	assert.Equal(t, "namespace2", groups[0].Group.Namespace)
+
+	// No failing rules.
+	assert.Equal(t, []*FailingRule{}, filterFailingRules(groups[:0]))
+}
+
+func TestRuler_ShouldTrackRuleGroupsBehindSchedule(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(mockRules))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// The rule groups have just been loaded, so none of them should be behind schedule.
+	require.Len(t, r.manager.GetRules("user1"), 1)
+	assert.Equal(t, float64(0), prom_testutil.ToFloat64(r.metrics.ruleGroupsBehindSchedule))
+}
+
+func TestIsRuleGroupBehindSchedule(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		lastEvaluation time.Time
+		interval       time.Duration
+		expected       bool
+	}{
+		"never evaluated": {
+			interval: time.Minute,
+		},
+		"evaluated within the interval": {
+			lastEvaluation: now.Add(-30 * time.Second),
+			interval:       time.Minute,
+		},
+		"evaluated within the interval plus slack": {
+			lastEvaluation: now.Add(-90 * time.Second),
+			interval:       time.Minute,
+		},
+		"evaluated before the interval plus slack": {
+			lastEvaluation: now.Add(-3 * time.Minute),
+			interval:       time.Minute,
+			expected:       true,
+		},
+		"zero interval": {
+			lastEvaluation: now.Add(-time.Hour),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, isRuleGroupBehindSchedule(testData.lastEvaluation, testData.interval, now))
+		})
+	}
 }
 
 type senderFunc func(alerts ...*notifier.Alert)
@@ -1105,7 +1485,495 @@ func TestSendAlerts(t *testing.T) {
 				}
 				require.Equal(t, tc.exp, alerts)
 			})
-			SendAlerts(senderFunc, "http://localhost:9090")(context.TODO(), "up", tc.in...)
+			SendAlerts(senderFunc, "http://localhost:9090", "")(context.TODO(), "up", tc.in...)
+		})
+	}
+}
+
+// blockingListRuleStore is a rule store whose ListAllUsers() blocks until released.
+type blockingListRuleStore struct {
+	rulestore.RuleStore
+
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (s *blockingListRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
+	s.calls.Inc()
+	<-s.release
+	return s.RuleStore.ListAllUsers(ctx)
+}
+
+// failingListRuleStore is a rule store whose ListRuleGroupsForUserAndNamespace() fails for the failing users.
+type failingListRuleStore struct {
+	rulestore.RuleStore
+
+	failingMtx sync.Mutex
+	failing    map[string]bool
+}
+
+func (s *failingListRuleStore) setFailing(userID string, failing bool) {
+	s.failingMtx.Lock()
+	defer s.failingMtx.Unlock()
+	s.failing[userID] = failing
+}
+
+func (s *failingListRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID, namespace string) (rulespb.RuleGroupList, error) {
+	s.failingMtx.Lock()
+	failing := s.failing[userID]
+	s.failingMtx.Unlock()
+
+	if failing {
+		return nil, fmt.Errorf("failed to list rule groups")
+	}
+	return s.RuleStore.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+}
+
+func TestRuler_TolerateTenantListErrors(t *testing.T) {
+	// Copy the mocked rules, because the store is modified by the test.
+	newStore := func() *failingListRuleStore {
+		rules := map[string]rulespb.RuleGroupList{}
+		for userID, groups := range mockRules {
+			rules[userID] = append(rulespb.RuleGroupList{}, groups...)
+		}
+		return &failingListRuleStore{RuleStore: newMockRuleStore(rules), failing: map[string]bool{"user2": true}}
+	}
+
+	t.Run("should fail the whole rules sync when the listing errors are not tolerated", func(t *testing.T) {
+		cfg := defaultRulerConfig(t)
+
+		r := newTestRuler(t, cfg, newStore())
+		defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+		_, err := r.syncRulesAndReport(context.Background(), rulerSyncReasonPeriodic)
+		require.Error(t, err)
+		require.Empty(t, r.manager.GetRules("user1"))
+		require.Empty(t, r.manager.GetRules("user2"))
+	})
+
+	t.Run("should sync the rules of the other tenants when the listing errors are tolerated", func(t *testing.T) {
+		cfg := defaultRulerConfig(t)
+		cfg.TolerateTenantListErrors = true
+
+		store := newStore()
+		r := newTestRuler(t, cfg, store)
+		defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+		_, err := r.syncRulesAndReport(context.Background(), rulerSyncReasonPeriodic)
+		require.NoError(t, err)
+		require.Len(t, r.manager.GetRules("user1"), len(mockRules["user1"]))
+		require.Empty(t, r.manager.GetRules("user2"))
+		require.Greater(t, prom_testutil.ToFloat64(r.metrics.listRulesTenantFailures), float64(0))
+
+		// The tenant's rule groups are synced once they can be listed.
+		store.setFailing("user2", false)
+		_, err = r.syncRulesAndReport(context.Background(), rulerSyncReasonPeriodic)
+		require.NoError(t, err)
+		require.Len(t, r.manager.GetRules("user1"), len(mockRules["user1"]))
+		require.Len(t, r.manager.GetRules("user2"), len(mockRules["user2"]))
+
+		// The tenant's rule groups previously synced keep running when they fail to be listed again.
+		store.setFailing("user2", true)
+		failures := prom_testutil.ToFloat64(r.metrics.listRulesTenantFailures)
+		_, err = r.syncRulesAndReport(context.Background(), rulerSyncReasonPeriodic)
+		require.NoError(t, err)
+		require.Len(t, r.manager.GetRules("user1"), len(mockRules["user1"]))
+		require.Len(t, r.manager.GetRules("user2"), len(mockRules["user2"]))
+		require.Greater(t, prom_testutil.ToFloat64(r.metrics.listRulesTenantFailures), failures)
+	})
+}
+
+func TestRuler_SyncRules(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.ManualSyncMinInterval = 0
+
+	// Copy the mocked rules, because the store is modified by the test.
+	rules := map[string]rulespb.RuleGroupList{}
+	for userID, groups := range mockRules {
+		rules[userID] = append(rulespb.RuleGroupList{}, groups...)
+	}
+	store := newMockRuleStore(rules)
+
+	r := newTestRuler(t, cfg, store)
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	router := mux.NewRouter()
+	router.Path("/ruler/sync").Methods(http.MethodPost).HandlerFunc(r.SyncRules)
+
+	t.Run("should sync the rules and return the number of loaded rule groups", func(t *testing.T) {
+		// Add a rule group after the initial sync.
+		newGroup := &rulespb.RuleGroupDesc{Name: "new-group", Namespace: "namespace1", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}}}
+		require.NoError(t, store.SetRuleGroup(context.Background(), "user1", "namespace1", newGroup))
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ruler/sync", nil))
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.JSONEq(t, fmt.Sprintf(`{
+			"status": "success",
+			"data": {"tenants": %d, "ruleGroups": %d},
+			"errorType": "",
+			"error": ""
+		}`, len(mockRules), len(mockRules["user1"])+len(mockRules["user2"])+1), string(body))
+
+		require.Len(t, r.manager.GetRules("user1"), len(mockRules["user1"])+1)
+		require.Equal(t, float64(1), prom_testutil.ToFloat64(r.metrics.rulerSync.WithLabelValues(rulerSyncReasonManual)))
+	})
+
+	t.Run("should coalesce concurrent requests into a single sync", func(t *testing.T) {
+		blocking := &blockingListRuleStore{RuleStore: store, release: make(chan struct{})}
+		r.store = blocking
+		defer func() { r.store = store }()
+
+		const numRequests = 5
+		recorders := make([]*httptest.ResponseRecorder, numRequests)
+		wg := sync.WaitGroup{}
+		wg.Add(numRequests)
+
+		for i := 0; i < numRequests; i++ {
+			recorders[i] = httptest.NewRecorder()
+			go func(w *httptest.ResponseRecorder) {
+				defer wg.Done()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ruler/sync", nil))
+			}(recorders[i])
+		}
+
+		// Wait until the sync is in progress and all requests are waiting for it.
+		require.Eventually(t, func() bool {
+			return blocking.calls.Load() == 1
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(100 * time.Millisecond)
+
+		close(blocking.release)
+		wg.Wait()
+
+		for _, w := range recorders {
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+		require.Equal(t, int32(1), blocking.calls.Load())
+		require.Equal(t, float64(2), prom_testutil.ToFloat64(r.metrics.rulerSync.WithLabelValues(rulerSyncReasonManual)))
+	})
+
+	t.Run("should reject the requests received within the min interval since the last sync", func(t *testing.T) {
+		r.cfg.ManualSyncMinInterval = time.Hour
+		defer func() { r.cfg.ManualSyncMinInterval = 0 }()
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ruler/sync", nil))
+
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Contains(t, w.Body.String(), "less than the min interval between syncs of 1h0m0s")
+		require.Equal(t, float64(2), prom_testutil.ToFloat64(r.metrics.rulerSync.WithLabelValues(rulerSyncReasonManual)))
+	})
+}
+
+func TestRuler_SyncRules_ShouldFailIfTheRulerIsNotRunning(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), nil)
+
+	w := httptest.NewRecorder()
+	r.SyncRules(w, httptest.NewRequest(http.MethodPost, "/ruler/sync", nil))
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, float64(0), prom_testutil.ToFloat64(r.metrics.rulerSync.WithLabelValues(rulerSyncReasonManual)))
+}
+
+func TestRuler_Ready(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), nil)
+
+	router := mux.NewRouter()
+	router.Path("/ruler/ready").Methods(http.MethodGet).HandlerFunc(r.Ready)
+
+	ready := func() (int, string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ruler/ready", nil))
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		return resp.StatusCode, string(body)
+	}
+
+	// The ruler is not ready until the initial sync has loaded the rules.
+	status, body := ready()
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.JSONEq(t, `{
+		"status": "error",
+		"data": {"tenants": 0, "ruleGroups": 0},
+		"errorType": "server_error",
+		"error": "the initial rules sync has not completed yet"
+	}`, body)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	status, body = ready()
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, fmt.Sprintf(`{
+		"status": "success",
+		"data": {"tenants": %d, "ruleGroups": %d},
+		"errorType": "",
+		"error": ""
+	}`, len(mockRules), len(mockRules["user1"])+len(mockRules["user2"])), body)
+}
+
+func TestRuler_DisabledRuleGroups(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	enabled := &rulespb.RuleGroupDesc{Name: "enabled", Namespace: "namespace", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}}}
+	disabled := &rulespb.RuleGroupDesc{Name: "disabled", Namespace: "namespace", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}}}
+	disabled.SetDisabled(true)
+
+	r := newTestRuler(t, cfg, newMockRuleStore(map[string]rulespb.RuleGroupList{
+		"user1": {enabled, disabled},
+	}))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// The disabled rule group is not evaluated.
+	groups := r.manager.GetRules("user1")
+	require.Len(t, groups, 1)
+	require.Equal(t, "enabled", groups[0].Name())
+
+	rules, err := r.getLocalRules("user1")
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, "enabled", rules[0].Group.Name)
+
+	// The disabled rule group is still listed by the configuration API.
+	listed, err := r.store.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "namespace")
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	// Enabling the rule group back schedules it for evaluation.
+	disabled.SetDisabled(false)
+	r.syncRules(context.Background(), rulerSyncReasonPeriodic)
+	require.Len(t, r.manager.GetRules("user1"), 2)
+}
+
+func TestRuler_ShouldSkipRuleGroupsExceedingLimits(t *testing.T) {
+	newGroup := func(namespace, name string, rules int) *rulespb.RuleGroupDesc {
+		g := &rulespb.RuleGroupDesc{Name: name, Namespace: namespace, User: "user1", Interval: time.Minute}
+		for i := 0; i < rules; i++ {
+			g.Rules = append(g.Rules, &rulespb.RuleDesc{Record: fmt.Sprintf("rule_%d", i), Expr: "up"})
+		}
+		return g
+	}
+
+	tests := map[string]struct {
+		limits          ruleLimits
+		expectedGroups  []string
+		expectedMetrics string
+	}{
+		"should load all rule groups when limits are not exceeded": {
+			limits:         ruleLimits{maxRuleGroups: 3, maxRulesPerRuleGroup: 2},
+			expectedGroups: []string{"group-a", "group-b", "group-c"},
+			expectedMetrics: `
+				# HELP cortex_ruler_rule_groups_skipped_total Total number of rule groups, owned by this ruler, skipped when loaded because the tenant exceeds its limits.
+				# TYPE cortex_ruler_rule_groups_skipped_total counter
+			`,
+		},
+		"should skip the rule groups exceeding the max number of rule groups": {
+			limits:         ruleLimits{maxRuleGroups: 2, maxRulesPerRuleGroup: 2},
+			expectedGroups: []string{"group-a", "group-b"},
+			expectedMetrics: `
+				# HELP cortex_ruler_rule_groups_skipped_total Total number of rule groups, owned by this ruler, skipped when loaded because the tenant exceeds its limits.
+				# TYPE cortex_ruler_rule_groups_skipped_total counter
+				cortex_ruler_rule_groups_skipped_total{reason="max_rule_groups"} 1
+			`,
+		},
+		"should skip the rule groups exceeding the max number of rules per rule group": {
+			limits:         ruleLimits{maxRuleGroups: 3, maxRulesPerRuleGroup: 1},
+			expectedGroups: []string{"group-a", "group-c"},
+			expectedMetrics: `
+				# HELP cortex_ruler_rule_groups_skipped_total Total number of rule groups, owned by this ruler, skipped when loaded because the tenant exceeds its limits.
+				# TYPE cortex_ruler_rule_groups_skipped_total counter
+				cortex_ruler_rule_groups_skipped_total{reason="max_rules_per_group"} 1
+			`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store := newMockRuleStore(map[string]rulespb.RuleGroupList{
+				"user1": {newGroup("namespace", "group-c", 1), newGroup("namespace", "group-b", 2), newGroup("namespace", "group-a", 1)},
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			r := buildRuler(t, defaultRulerConfig(t), store, nil)
+			r.metrics = newRulerMetrics(reg)
+			r.limits = testData.limits
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			// Wait until the ruler has synced the rules once started.
+			test.Poll(t, 5*time.Second, testData.expectedGroups, func() interface{} {
+				var actualGroups []string
+				for _, g := range r.manager.GetRules("user1") {
+					actualGroups = append(actualGroups, g.Name())
+				}
+				sort.Strings(actualGroups)
+				return actualGroups
+			})
+
+			assert.NoError(t, prom_testutil.GatherAndCompare(reg, strings.NewReader(testData.expectedMetrics), "cortex_ruler_rule_groups_skipped_total"))
 		})
 	}
 }
+
+func TestRuleGroupDesc_Disabled(t *testing.T) {
+	group := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}
+	require.False(t, group.IsDisabled())
+
+	// The disabled flag round-trips through the protobuf encoding used to store rule groups.
+	group.SetDisabled(true)
+	data, err := group.Marshal()
+	require.NoError(t, err)
+	decoded := &rulespb.RuleGroupDesc{}
+	require.NoError(t, decoded.Unmarshal(data))
+	require.True(t, decoded.IsDisabled())
+
+	decoded.SetDisabled(false)
+	require.False(t, decoded.IsDisabled())
+	require.Empty(t, decoded.Options)
+}
+
+func TestRuleGroupDesc_EvaluationDelay(t *testing.T) {
+	group := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1"}
+	require.Nil(t, group.GetEvaluationDelay())
+
+	// The evaluation delay round-trips through the protobuf encoding used to store rule groups.
+	delay := 5 * time.Minute
+	group.SetEvaluationDelay(&delay)
+	group.SetDisabled(true)
+	data, err := group.Marshal()
+	require.NoError(t, err)
+	decoded := &rulespb.RuleGroupDesc{}
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Equal(t, &delay, decoded.GetEvaluationDelay())
+	require.True(t, decoded.IsDisabled())
+
+	// The evaluation delay round-trips through the Prometheus rule group format.
+	formatted := rulespb.FromProto(decoded)
+	require.NotNil(t, formatted.EvaluationDelay)
+	require.Equal(t, model.Duration(delay), *formatted.EvaluationDelay)
+	require.Equal(t, &delay, rulespb.ToProto("user1", "namespace", formatted).GetEvaluationDelay())
+
+	decoded.SetEvaluationDelay(nil)
+	require.Nil(t, decoded.GetEvaluationDelay())
+	require.True(t, decoded.IsDisabled())
+}
+
+func TestRuleGroupDesc_Labels(t *testing.T) {
+	group := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1", Rules: []*rulespb.RuleDesc{
+		{Record: "UP_RULE", Expr: "up", Labels: mimirpb.FromLabelsToLabelAdapters(labels.FromStrings("env", "dev"))},
+		{Alert: "UP_ALERT", Expr: "up < 1"},
+	}}
+	require.Nil(t, group.GetLabels())
+
+	// The labels round-trip through the protobuf encoding used to store rule groups.
+	groupLabels := mimirpb.FromLabelsToLabelAdapters(labels.FromStrings("env", "prod", "team", "payments"))
+	group.SetLabels(groupLabels)
+	group.SetDisabled(true)
+	data, err := group.Marshal()
+	require.NoError(t, err)
+	decoded := &rulespb.RuleGroupDesc{}
+	require.NoError(t, decoded.Unmarshal(data))
+	require.Equal(t, groupLabels, decoded.GetLabels())
+	require.True(t, decoded.IsDisabled())
+
+	// The labels round-trip through the rule group format used by the configuration API.
+	formatted := rulespb.FromProtoWithOptions(decoded)
+	require.Equal(t, map[string]string{"env": "prod", "team": "payments"}, formatted.Labels)
+	require.Equal(t, map[string]string{"env": "dev"}, formatted.Rules[0].Labels)
+	require.Equal(t, groupLabels, rulespb.ToProtoWithOptions("user1", "namespace", formatted).GetLabels())
+
+	// The labels are added to the rules in the Prometheus rule group format, and the labels
+	// of each rule take precedence over the rule group labels.
+	formattedRules := rulespb.RuleGroupList{decoded}.Formatted()["namespace"][0].Rules
+	require.Equal(t, map[string]string{"env": "dev", "team": "payments"}, formattedRules[0].Labels)
+	require.Equal(t, map[string]string{"env": "prod", "team": "payments"}, formattedRules[1].Labels)
+
+	decoded.SetLabels(nil)
+	require.Nil(t, decoded.GetLabels())
+	require.True(t, decoded.IsDisabled())
+}
+
+func TestRuler_GroupLabels(t *testing.T) {
+	group := &rulespb.RuleGroupDesc{Name: "group", Namespace: "namespace", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{
+		{Record: "UP_RULE", Expr: "up", Labels: mimirpb.FromLabelsToLabelAdapters(labels.FromStrings("env", "dev"))},
+		{Alert: "UP_ALERT", Expr: "up < 1"},
+	}}
+	group.SetLabels(mimirpb.FromLabelsToLabelAdapters(labels.FromStrings("env", "prod", "team", "payments")))
+
+	cfg := defaultRulerConfig(t)
+	noopQueryable, noopQueryFunc, pusher, logger, limits := testSetup()
+
+	reg := prometheus.NewRegistry()
+	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, limits, reg)
+	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, limits, reg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	r, err := newRuler(cfg, manager, reg, logger, newMockRuleStore(map[string]rulespb.RuleGroupList{"user1": {group}}), limits, noopQueryFunc, newMockClientsPool(cfg, logger, reg, nil))
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	// The rule group labels are added to the labels of the recording and alerting rules evaluated,
+	// unless overridden by the rule.
+	actual := map[string]labels.Labels{}
+	for _, g := range r.manager.GetRules("user1") {
+		for _, rule := range g.Rules() {
+			actual[rule.Name()] = rule.Labels()
+		}
+	}
+	assert.Equal(t, map[string]labels.Labels{
+		"UP_RULE":  labels.FromStrings("env", "dev", "team", "payments"),
+		"UP_ALERT": labels.FromStrings("env", "prod", "team", "payments"),
+	}, actual)
+}
+
+func TestRuler_GroupEvaluationDelay(t *testing.T) {
+	groupDelay := 5 * time.Minute
+	withDelay := &rulespb.RuleGroupDesc{Name: "with-delay", Namespace: "namespace", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}}}
+	withDelay.SetEvaluationDelay(&groupDelay)
+	withoutDelay := &rulespb.RuleGroupDesc{Name: "without-delay", Namespace: "namespace", User: "user1", Interval: time.Minute, Rules: []*rulespb.RuleDesc{{Record: "UP_RULE", Expr: "up"}}}
+
+	// The tenant's evaluation delay is the default for the rule groups not overriding it.
+	cfg := defaultRulerConfig(t)
+	noopQueryable, noopQueryFunc, pusher, logger, _ := testSetup()
+	limits := ruleLimits{evalDelay: time.Minute, maxRuleGroups: 20, maxRulesPerRuleGroup: 15}
+
+	reg := prometheus.NewRegistry()
+	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, noopQueryFunc, limits, reg)
+	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, limits, reg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	r, err := newRuler(cfg, manager, reg, logger, newMockRuleStore(map[string]rulespb.RuleGroupList{"user1": {withDelay, withoutDelay}}), limits, noopQueryFunc, newMockClientsPool(cfg, logger, reg, nil))
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	actual := map[string]time.Duration{}
+	for _, g := range r.manager.GetRules("user1") {
+		actual[g.Name()] = g.EvaluationDelay()
+	}
+	assert.Equal(t, map[string]time.Duration{"with-delay": groupDelay, "without-delay": time.Minute}, actual)
+
+	groups, err := r.getLocalRules("user1")
+	require.NoError(t, err)
+	actual = map[string]time.Duration{}
+	for _, g := range groups {
+		actual[g.Group.Name] = *g.Group.GetEvaluationDelay()
+	}
+	assert.Equal(t, map[string]time.Duration{"with-delay": groupDelay, "without-delay": time.Minute}, actual)
+}
@@ -26,6 +26,10 @@ func ToProto(user string, namespace string, rl rulefmt.RuleGroup) *RuleGroupDesc
 		User:          user,
 		SourceTenants: rl.SourceTenants,
 	}
+	if rl.EvaluationDelay != nil {
+		delay := time.Duration(*rl.EvaluationDelay)
+		rg.SetEvaluationDelay(&delay)
+	}
 	return &rg
 }
 
@@ -45,6 +49,36 @@ func formattedRuleToProto(rls []rulefmt.RuleNode) []*RuleDesc {
 	return rules
 }
 
+// ToProtoWithOptions transforms a formatted rule group, including the fields which are not part
+// of the Prometheus rule group format, to a rule group protobuf.
+func ToProtoWithOptions(user string, namespace string, rl RuleGroup) *RuleGroupDesc {
+	rg := ToProto(user, namespace, rl.RuleGroup)
+	rg.SetDisabled(rl.Disabled)
+	rg.SetLabels(mimirpb.FromLabelsToLabelAdapters(labels.FromMap(rl.Labels)))
+	if rl.EvaluationTimeout != nil {
+		timeout := time.Duration(*rl.EvaluationTimeout)
+		rg.SetEvaluationTimeout(&timeout)
+	}
+	return rg
+}
+
+// FromProtoWithOptions generates a formatted rule group, including the fields which are not part
+// of the Prometheus rule group format.
+func FromProtoWithOptions(rg *RuleGroupDesc) RuleGroup {
+	formatted := RuleGroup{
+		RuleGroup: FromProto(rg),
+		Disabled:  rg.IsDisabled(),
+	}
+	if lbls := rg.GetLabels(); len(lbls) > 0 {
+		formatted.Labels = mimirpb.FromLabelAdaptersToLabels(lbls).Map()
+	}
+	if timeout := rg.GetEvaluationTimeout(); timeout != nil {
+		evaluationTimeout := model.Duration(*timeout)
+		formatted.EvaluationTimeout = &evaluationTimeout
+	}
+	return formatted
+}
+
 // FromProto generates a rulefmt RuleGroup
 func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
 	formattedRuleGroup := rulefmt.RuleGroup{
@@ -53,6 +87,10 @@ func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
 		Rules:         make([]rulefmt.RuleNode, len(rg.GetRules())),
 		SourceTenants: rg.GetSourceTenants(),
 	}
+	if delay := rg.GetEvaluationDelay(); delay != nil {
+		evaluationDelay := model.Duration(*delay)
+		formattedRuleGroup.EvaluationDelay = &evaluationDelay
+	}
 
 	for i, rl := range rg.GetRules() {
 		exprNode := yaml.Node{}
@@ -5,22 +5,227 @@
 
 package rulespb
 
-import "github.com/prometheus/prometheus/model/rulefmt"
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+
+	"github.com/grafana/mimir/pkg/mimirpb" //lint:ignore faillint allowed to import other protobuf
+)
+
+const (
+	// disabledOptionTypeURL is the type URL of the option marking a rule group as disabled.
+	// The option has no value: its presence is enough to disable the rule group.
+	disabledOptionTypeURL = "mimir.ruler/rule_group_disabled"
+
+	// evaluationDelayOptionTypeURL is the type URL of the option overriding the evaluation delay
+	// of a rule group. The option value is the delay, encoded as a protobuf Duration.
+	evaluationDelayOptionTypeURL = "mimir.ruler/rule_group_evaluation_delay"
+
+	// evaluationTimeoutOptionTypeURL is the type URL of the option overriding the evaluation timeout
+	// of a rule group. The option value is the timeout, encoded as a protobuf Duration.
+	evaluationTimeoutOptionTypeURL = "mimir.ruler/rule_group_evaluation_timeout"
+
+	// labelsOptionTypeURL is the type URL of the option setting the labels added to all the rules
+	// of a rule group. The option value is the labels, encoded as a mimirpb.Metric.
+	labelsOptionTypeURL = "mimir.ruler/rule_group_labels"
+)
 
 // RuleGroupList contains a set of rule groups
 type RuleGroupList []*RuleGroupDesc
 
 // Formatted returns the rule group list as a set of formatted rule groups mapped
-// by namespace
+// by namespace. The rule group labels are not part of the Prometheus rule group format,
+// so they're added to the labels of each rule of the group instead.
 func (l RuleGroupList) Formatted() map[string][]rulefmt.RuleGroup {
 	ruleMap := map[string][]rulefmt.RuleGroup{}
 	for _, g := range l {
+		formatted := FromProto(g)
+		addGroupLabelsToRules(formatted.Rules, g.GetLabels())
+
 		if _, exists := ruleMap[g.Namespace]; !exists {
-			ruleMap[g.Namespace] = []rulefmt.RuleGroup{FromProto(g)}
+			ruleMap[g.Namespace] = []rulefmt.RuleGroup{formatted}
 			continue
 		}
-		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], FromProto(g))
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], formatted)
 
 	}
 	return ruleMap
 }
+
+// addGroupLabelsToRules adds the rule group labels to the labels of each rule. The labels of
+// the rule take precedence over the rule group labels with the same name.
+func addGroupLabelsToRules(rules []rulefmt.RuleNode, groupLabels []mimirpb.LabelAdapter) {
+	if len(groupLabels) == 0 {
+		return
+	}
+
+	for i := range rules {
+		merged := make(map[string]string, len(groupLabels)+len(rules[i].Labels))
+		for _, l := range groupLabels {
+			merged[l.Name] = l.Value
+		}
+		for name, value := range rules[i].Labels {
+			merged[name] = value
+		}
+		rules[i].Labels = merged
+	}
+}
+
+// RuleGroup is a formatted rule group, including the fields which are not part of
+// the Prometheus rule group format.
+type RuleGroup struct {
+	rulefmt.RuleGroup `yaml:",inline"`
+
+	// Disabled rule groups are stored, but not evaluated.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// Labels added to the series and alerts produced by all the rules of the group.
+	// The labels of each rule take precedence over the rule group labels.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
+	// Max time the query of each rule of the group can run for, overriding the tenant's one.
+	EvaluationTimeout *model.Duration `yaml:"evaluation_timeout,omitempty"`
+}
+
+// FormattedWithOptions returns the rule group list as a set of formatted rule groups,
+// including the fields which are not part of the Prometheus rule group format, mapped by namespace.
+func (l RuleGroupList) FormattedWithOptions() map[string][]RuleGroup {
+	ruleMap := map[string][]RuleGroup{}
+	for _, g := range l {
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], FromProtoWithOptions(g))
+	}
+	return ruleMap
+}
+
+// IsDisabled returns whether the rule group is disabled.
+func (m *RuleGroupDesc) IsDisabled() bool {
+	for _, opt := range m.GetOptions() {
+		if opt.GetTypeUrl() == disabledOptionTypeURL {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDisabled disables or enables the rule group.
+func (m *RuleGroupDesc) SetDisabled(disabled bool) {
+	if disabled {
+		m.setOption(disabledOptionTypeURL, &types.Any{TypeUrl: disabledOptionTypeURL})
+	} else {
+		m.setOption(disabledOptionTypeURL, nil)
+	}
+}
+
+// GetEvaluationDelay returns the evaluation delay of the rule group, or nil if the rule group
+// doesn't override the tenant's evaluation delay.
+func (m *RuleGroupDesc) GetEvaluationDelay() *time.Duration {
+	return m.getDurationOption(evaluationDelayOptionTypeURL)
+}
+
+// SetEvaluationDelay overrides the evaluation delay of the rule group. A nil delay
+// removes the override, so that the tenant's evaluation delay is used.
+func (m *RuleGroupDesc) SetEvaluationDelay(delay *time.Duration) {
+	m.setDurationOption(evaluationDelayOptionTypeURL, delay)
+}
+
+// GetEvaluationTimeout returns the evaluation timeout of the rule group, or nil if the rule group
+// doesn't override the tenant's evaluation timeout.
+func (m *RuleGroupDesc) GetEvaluationTimeout() *time.Duration {
+	return m.getDurationOption(evaluationTimeoutOptionTypeURL)
+}
+
+// SetEvaluationTimeout overrides the evaluation timeout of the rule group. A nil timeout
+// removes the override, so that the tenant's evaluation timeout is used.
+func (m *RuleGroupDesc) SetEvaluationTimeout(timeout *time.Duration) {
+	m.setDurationOption(evaluationTimeoutOptionTypeURL, timeout)
+}
+
+// getDurationOption returns the duration of the option with the input type URL, or nil if
+// the option is not set.
+func (m *RuleGroupDesc) getDurationOption(typeURL string) *time.Duration {
+	for _, opt := range m.GetOptions() {
+		if opt.GetTypeUrl() != typeURL {
+			continue
+		}
+
+		value := &types.Duration{}
+		if err := proto.Unmarshal(opt.GetValue(), value); err != nil {
+			return nil
+		}
+		d, err := types.DurationFromProto(value)
+		if err != nil {
+			return nil
+		}
+		return &d
+	}
+	return nil
+}
+
+// setDurationOption sets the duration of the option with the input type URL. A nil duration removes the option.
+func (m *RuleGroupDesc) setDurationOption(typeURL string, d *time.Duration) {
+	if d == nil {
+		m.setOption(typeURL, nil)
+		return
+	}
+
+	value, err := proto.Marshal(types.DurationProto(*d))
+	if err != nil {
+		// Marshalling a Duration never fails.
+		panic(err)
+	}
+	m.setOption(typeURL, &types.Any{TypeUrl: typeURL, Value: value})
+}
+
+// GetLabels returns the labels added to all the rules of the rule group, sorted by name,
+// or nil if the rule group has no labels.
+func (m *RuleGroupDesc) GetLabels() []mimirpb.LabelAdapter {
+	for _, opt := range m.GetOptions() {
+		if opt.GetTypeUrl() != labelsOptionTypeURL {
+			continue
+		}
+
+		value := &mimirpb.Metric{}
+		if err := value.Unmarshal(opt.GetValue()); err != nil {
+			return nil
+		}
+		return value.Labels
+	}
+	return nil
+}
+
+// SetLabels sets the labels added to all the rules of the rule group. Empty labels
+// remove the option.
+func (m *RuleGroupDesc) SetLabels(lbls []mimirpb.LabelAdapter) {
+	if len(lbls) == 0 {
+		m.setOption(labelsOptionTypeURL, nil)
+		return
+	}
+
+	value, err := (&mimirpb.Metric{Labels: lbls}).Marshal()
+	if err != nil {
+		// Marshalling labels never fails.
+		panic(err)
+	}
+	m.setOption(labelsOptionTypeURL, &types.Any{TypeUrl: labelsOptionTypeURL, Value: value})
+}
+
+// setOption replaces the option with the input type URL. A nil option removes it.
+func (m *RuleGroupDesc) setOption(typeURL string, option *types.Any) {
+	options := make([]*types.Any, 0, len(m.Options)+1)
+	for _, opt := range m.Options {
+		if opt.GetTypeUrl() != typeURL {
+			options = append(options, opt)
+		}
+	}
+	if option != nil {
+		options = append(options, option)
+	}
+	if len(options) == 0 {
+		options = nil
+	}
+	m.Options = options
+}
@@ -29,6 +29,9 @@ const (
 	// RulesPrefix is the bucket prefix under which all tenants rule groups are stored.
 	RulesPrefix = "rules"
 
+	// AlertStatesPrefix is the bucket prefix under which the alert states of all tenants rule groups are stored.
+	AlertStatesPrefix = "alert-states"
+
 	loadConcurrency = 10
 )
 
@@ -42,16 +45,18 @@ var (
 // BucketRuleStore is used to support the RuleStore interface against an object storage backend. It is implemented
 // using the Thanos objstore.Bucket interface
 type BucketRuleStore struct {
-	bucket      objstore.Bucket
-	cfgProvider bucket.TenantConfigProvider
-	logger      log.Logger
+	bucket            objstore.Bucket
+	alertStatesBucket objstore.Bucket
+	cfgProvider       bucket.TenantConfigProvider
+	logger            log.Logger
 }
 
 func NewBucketRuleStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketRuleStore {
 	return &BucketRuleStore{
-		bucket:      bucket.NewPrefixedBucketClient(bkt, RulesPrefix),
-		cfgProvider: cfgProvider,
-		logger:      logger,
+		bucket:            bucket.NewPrefixedBucketClient(bkt, RulesPrefix),
+		alertStatesBucket: bucket.NewPrefixedBucketClient(bkt, AlertStatesPrefix),
+		cfgProvider:       cfgProvider,
+		logger:            logger,
 	}
 }
 
@@ -205,14 +210,20 @@ func (b *BucketRuleStore) SetRuleGroup(ctx context.Context, userID string, names
 	return userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group.Name), bytes.NewBuffer(data))
 }
 
-// DeleteRuleGroup implements rules.RuleStore.
+// DeleteRuleGroup implements rules.RuleStore. The alert state handed off for the rule group, if any,
+// is deleted too, because no ruler will ever fetch it.
 func (b *BucketRuleStore) DeleteRuleGroup(ctx context.Context, userID string, namespace string, group string) error {
 	userBucket := bucket.NewUserBucketClient(userID, b.bucket, b.cfgProvider)
 	err := userBucket.Delete(ctx, getRuleGroupObjectKey(namespace, group))
 	if b.bucket.IsObjNotFoundErr(err) {
 		return rulestore.ErrGroupNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+
+	b.deleteAlertStateOfDeletedRuleGroup(ctx, userID, namespace, group)
+	return nil
 }
 
 // DeleteNamespace implements rules.RuleStore.
@@ -238,11 +249,59 @@ func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, na
 			level.Error(b.logger).Log("msg", "unable to delete rule group from namespace", "user", userID, "namespace", namespace, "key", objectKey, "err", err)
 			return err
 		}
+
+		b.deleteAlertStateOfDeletedRuleGroup(ctx, userID, rg.Namespace, rg.Name)
 	}
 
 	return nil
 }
 
+// deleteAlertStateOfDeletedRuleGroup deletes the alert state of a deleted rule group. The rule group
+// has already been deleted, so a failure is logged but not returned.
+func (b *BucketRuleStore) deleteAlertStateOfDeletedRuleGroup(ctx context.Context, userID, namespace, group string) {
+	if err := b.DeleteAlertState(ctx, userID, namespace, group); err != nil {
+		level.Warn(b.logger).Log("msg", "unable to delete the alert state of the deleted rule group", "user", userID, "namespace", namespace, "group", group, "err", err)
+	}
+}
+
+// GetAlertState implements rulestore.AlertStateStore.
+func (b *BucketRuleStore) GetAlertState(ctx context.Context, userID, namespace, group string) ([]byte, error) {
+	userBucket := bucket.NewUserBucketClient(userID, b.alertStatesBucket, b.cfgProvider)
+	objectKey := getRuleGroupObjectKey(namespace, group)
+
+	reader, err := userBucket.Get(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return nil, rulestore.ErrAlertStateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get alert state %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read alert state %s", objectKey)
+	}
+
+	return buf, nil
+}
+
+// SetAlertState implements rulestore.AlertStateStore.
+func (b *BucketRuleStore) SetAlertState(ctx context.Context, userID, namespace, group string, state []byte) error {
+	userBucket := bucket.NewUserBucketClient(userID, b.alertStatesBucket, b.cfgProvider)
+	return userBucket.Upload(ctx, getRuleGroupObjectKey(namespace, group), bytes.NewReader(state))
+}
+
+// DeleteAlertState implements rulestore.AlertStateStore.
+func (b *BucketRuleStore) DeleteAlertState(ctx context.Context, userID, namespace, group string) error {
+	userBucket := bucket.NewUserBucketClient(userID, b.alertStatesBucket, b.cfgProvider)
+	err := userBucket.Delete(ctx, getRuleGroupObjectKey(namespace, group))
+	if userBucket.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
 func getNamespacePrefix(namespace string) string {
 	return base64.URLEncoding.EncodeToString([]byte(namespace)) + objstore.DirDelim
 }
@@ -242,6 +242,76 @@ func getSortedObjectKeys(bucketClient interface{}) []string {
 	return nil
 }
 
+func TestAlertState(t *testing.T) {
+	bucketClient := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bucketClient, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	require.NoError(t, rs.SetRuleGroup(ctx, "user1", "namespace", rulespb.ToProto("user1", "namespace", rulefmt.RuleGroup{Name: "group"})))
+
+	_, err := rs.GetAlertState(ctx, "user1", "namespace", "group")
+	require.Equal(t, rulestore.ErrAlertStateNotFound, err)
+
+	require.NoError(t, rs.SetAlertState(ctx, "user1", "namespace", "group", []byte("state-1")))
+	require.NoError(t, rs.SetAlertState(ctx, "user1", "namespace", "group", []byte("state-2")))
+
+	state, err := rs.GetAlertState(ctx, "user1", "namespace", "group")
+	require.NoError(t, err)
+	require.Equal(t, []byte("state-2"), state)
+
+	require.Equal(t, []string{
+		"alert-states/user1/" + getRuleGroupObjectKey("namespace", "group"),
+		"rules/user1/" + getRuleGroupObjectKey("namespace", "group"),
+	}, getSortedObjectKeys(bucketClient))
+
+	// Alert states should not be listed as rule groups.
+	users, err := rs.ListAllUsers(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"user1"}, users)
+
+	groups, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+
+	// Deleting should be idempotent.
+	require.NoError(t, rs.DeleteAlertState(ctx, "user1", "namespace", "group"))
+	require.NoError(t, rs.DeleteAlertState(ctx, "user1", "namespace", "group"))
+
+	_, err = rs.GetAlertState(ctx, "user1", "namespace", "group")
+	require.Equal(t, rulestore.ErrAlertStateNotFound, err)
+}
+
+func TestDelete_ShouldDeleteAlertStates(t *testing.T) {
+	bucketClient := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bucketClient, nil, log.NewNopLogger())
+	ctx := context.Background()
+
+	groups := []testGroup{
+		{user: "user1", namespace: "A", ruleGroup: rulefmt.RuleGroup{Name: "1"}},
+		{user: "user1", namespace: "A", ruleGroup: rulefmt.RuleGroup{Name: "2"}},
+		{user: "user1", namespace: "B", ruleGroup: rulefmt.RuleGroup{Name: "3"}},
+		{user: "user2", namespace: "second", ruleGroup: rulefmt.RuleGroup{Name: "group"}},
+	}
+
+	for _, g := range groups {
+		require.NoError(t, rs.SetRuleGroup(ctx, g.user, g.namespace, rulespb.ToProto(g.user, g.namespace, g.ruleGroup)))
+		require.NoError(t, rs.SetAlertState(ctx, g.user, g.namespace, g.ruleGroup.Name, []byte("state")))
+	}
+
+	// Deleting a rule group or a namespace should delete their alert states.
+	require.NoError(t, rs.DeleteRuleGroup(ctx, "user2", "second", "group"))
+	require.NoError(t, rs.DeleteNamespace(ctx, "user1", "A"))
+
+	require.Equal(t, []string{
+		"alert-states/user1/" + getRuleGroupObjectKey("B", "3"),
+		"rules/user1/" + getRuleGroupObjectKey("B", "3"),
+	}, getSortedObjectKeys(bucketClient))
+
+	// Deleting all the namespaces of a tenant should delete all its alert states.
+	require.NoError(t, rs.DeleteNamespace(ctx, "user1", ""))
+	require.Empty(t, getSortedObjectKeys(bucketClient))
+}
+
 func TestParseRuleGroupObjectKey(t *testing.T) {
 	decodedNamespace := "my-namespace"
 	encodedNamespace := base64.URLEncoding.EncodeToString([]byte(decodedNamespace))
@@ -19,6 +19,8 @@ var (
 	ErrGroupNamespaceNotFound = errors.New("group namespace does not exist")
 	// ErrUserNotFound is returned if the user does not currently exist
 	ErrUserNotFound = errors.New("no rule groups found for user")
+	// ErrAlertStateNotFound is returned if the alert state of a rule group does not exist
+	ErrAlertStateNotFound = errors.New("alert state does not exist")
 )
 
 // RuleStore is used to store and retrieve rules.
@@ -51,3 +53,16 @@ type RuleStore interface {
 	// If namespace is empty, deletes all rule groups for user.
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
 }
+
+// AlertStateStore is implemented by the rule stores which can store the state of the alerts of the rule groups,
+// so that it can be handed off between rulers. The state is opaque to the store.
+type AlertStateStore interface {
+	// GetAlertState returns the alert state of a rule group, or ErrAlertStateNotFound if it does not exist.
+	GetAlertState(ctx context.Context, userID, namespace, group string) ([]byte, error)
+
+	// SetAlertState stores the alert state of a rule group, replacing the existing one.
+	SetAlertState(ctx context.Context, userID, namespace, group string, state []byte) error
+
+	// DeleteAlertState deletes the alert state of a rule group. It's not an error if it does not exist.
+	DeleteAlertState(ctx context.Context, userID, namespace, group string) error
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+var errInvalidSlowestRuleGroupsLimit = errors.New("the limit must be a positive integer")
+
+// SlowestRuleGroupsDiscovery has info for the rule groups taking the longest to evaluate.
+type SlowestRuleGroupsDiscovery struct {
+	Groups []*RuleGroupEvaluationCost `json:"groups"`
+}
+
+// RuleGroupEvaluationCost has info about the cost of the last evaluation of a rule group.
+type RuleGroupEvaluationCost struct {
+	Namespace      string    `json:"namespace"`
+	Group          string    `json:"group"`
+	Rules          int       `json:"rules"`
+	Interval       float64   `json:"interval"`
+	LastEvaluation time.Time `json:"lastEvaluation"`
+	EvaluationTime float64   `json:"evaluationTime"`
+}
+
+// ListSlowestRuleGroups returns the tenant's rule groups, running on all rulers in the ring, sorted
+// by the duration of their last evaluation, the slowest first. The number of rule groups returned
+// can be limited with the "limit" parameter.
+func (r *Ruler) ListSlowestRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), r.logger)
+
+	if _, err := tenant.TenantID(req.Context()); err != nil {
+		// When Mimir is running, it uses Auth Middleware for checking X-Scope-OrgID and injecting tenant into context.
+		// Auth Middleware sends http.StatusUnauthorized if X-Scope-OrgID is missing, so we do too here, for consistency.
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	limit := 0
+	if v := req.FormValue("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, errInvalidSlowestRuleGroupsLimit.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	groups, err := r.GetRules(req.Context())
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   &SlowestRuleGroupsDiscovery{Groups: slowestRuleGroups(groups, limit)},
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
+// slowestRuleGroups returns the cost of the input rule groups sorted by their last evaluation duration,
+// the slowest first, and the rule groups with the same duration sorted by namespace and group. At most
+// limit rule groups are returned, unless limit is 0.
+func slowestRuleGroups(groups []*GroupStateDesc, limit int) []*RuleGroupEvaluationCost {
+	costs := make([]*RuleGroupEvaluationCost, 0, len(groups))
+	for _, g := range groups {
+		costs = append(costs, &RuleGroupEvaluationCost{
+			Namespace:      g.Group.Namespace,
+			Group:          g.Group.Name,
+			Rules:          len(g.ActiveRules),
+			Interval:       g.Group.Interval.Seconds(),
+			LastEvaluation: g.GetEvaluationTimestamp(),
+			EvaluationTime: g.GetEvaluationDuration().Seconds(),
+		})
+	}
+
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].EvaluationTime != costs[j].EvaluationTime {
+			return costs[i].EvaluationTime > costs[j].EvaluationTime
+		}
+		if costs[i].Namespace != costs[j].Namespace {
+			return costs[i].Namespace < costs[j].Namespace
+		}
+		return costs[i].Group < costs[j].Group
+	})
+
+	if limit > 0 && len(costs) > limit {
+		costs = costs[:limit]
+	}
+	return costs
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuler_ListSlowestRuleGroups(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	rulerAddrMap := map[string]*Ruler{}
+
+	r := buildRuler(t, cfg, newMockRuleStore(mockRules), rulerAddrMap)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// Make sure mock grpc client can find this instance, based on instance address registered in the ring.
+	rulerAddrMap[r.lifecycler.GetInstanceAddr()] = r
+
+	// Ensure all rules are loaded before usage
+	r.syncRules(context.Background(), rulerSyncReasonInitial)
+
+	router := mux.NewRouter()
+	router.Path("/ruler/slowest_rule_groups").Methods(http.MethodGet).HandlerFunc(r.ListSlowestRuleGroups)
+
+	t.Run("should return the rule groups of the tenant", func(t *testing.T) {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/slowest_rule_groups?limit=10", nil, "user1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		resp := w.Result()
+		body, _ := io.ReadAll(resp.Body)
+
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		require.JSONEq(t, `{
+			"status": "success",
+			"data": {
+				"groups": [{
+					"namespace": "namespace1",
+					"group": "group1",
+					"rules": 2,
+					"interval": 60,
+					"lastEvaluation": "0001-01-01T00:00:00Z",
+					"evaluationTime": 0
+				}]
+			},
+			"errorType": "",
+			"error": ""
+		}`, string(body))
+	})
+
+	t.Run("should fail if the limit is invalid", func(t *testing.T) {
+		for _, limit := range []string{"0", "-1", "foo"} {
+			req := requestFor(t, http.MethodGet, "https://localhost:8080/ruler/slowest_rule_groups?limit="+limit, nil, "user1")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "limit: %s", limit)
+		}
+	})
+
+	t.Run("should fail if the tenant is missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "https://localhost:8080/ruler/slowest_rule_groups", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusUnauthorized, w.Result().StatusCode)
+	})
+}
+
+func TestSlowestRuleGroups(t *testing.T) {
+	lastEvaluation := time.Now()
+
+	groups := []*GroupStateDesc{
+		{
+			Group:               &rulespb.RuleGroupDesc{Namespace: "namespace1", Name: "fast", Interval: time.Minute},
+			ActiveRules:         []*RuleStateDesc{{}},
+			EvaluationTimestamp: lastEvaluation,
+			EvaluationDuration:  100 * time.Millisecond,
+		},
+		{
+			Group:               &rulespb.RuleGroupDesc{Namespace: "namespace2", Name: "slowest", Interval: time.Minute},
+			ActiveRules:         []*RuleStateDesc{{}, {}, {}},
+			EvaluationTimestamp: lastEvaluation,
+			EvaluationDuration:  30 * time.Second,
+		},
+		{
+			Group:       &rulespb.RuleGroupDesc{Namespace: "namespace1", Name: "not_evaluated", Interval: time.Minute},
+			ActiveRules: []*RuleStateDesc{{}},
+		},
+		{
+			Group:               &rulespb.RuleGroupDesc{Namespace: "namespace2", Name: "slow-2", Interval: 10 * time.Second},
+			ActiveRules:         []*RuleStateDesc{{}, {}},
+			EvaluationTimestamp: lastEvaluation,
+			EvaluationDuration:  5 * time.Second,
+		},
+		{
+			Group:               &rulespb.RuleGroupDesc{Namespace: "namespace1", Name: "slow-1", Interval: time.Minute},
+			ActiveRules:         []*RuleStateDesc{{}},
+			EvaluationTimestamp: lastEvaluation,
+			EvaluationDuration:  5 * time.Second,
+		},
+	}
+
+	t.Run("should sort the rule groups by evaluation duration, the slowest first", func(t *testing.T) {
+		assert.Equal(t, []*RuleGroupEvaluationCost{
+			{Namespace: "namespace2", Group: "slowest", Rules: 3, Interval: 60, LastEvaluation: lastEvaluation, EvaluationTime: 30},
+			{Namespace: "namespace1", Group: "slow-1", Rules: 1, Interval: 60, LastEvaluation: lastEvaluation, EvaluationTime: 5},
+			{Namespace: "namespace2", Group: "slow-2", Rules: 2, Interval: 10, LastEvaluation: lastEvaluation, EvaluationTime: 5},
+			{Namespace: "namespace1", Group: "fast", Rules: 1, Interval: 60, LastEvaluation: lastEvaluation, EvaluationTime: 0.1},
+			{Namespace: "namespace1", Group: "not_evaluated", Rules: 1, Interval: 60},
+		}, slowestRuleGroups(groups, 0))
+	})
+
+	t.Run("should return at most limit rule groups", func(t *testing.T) {
+		assert.Equal(t, []*RuleGroupEvaluationCost{
+			{Namespace: "namespace2", Group: "slowest", Rules: 3, Interval: 60, LastEvaluation: lastEvaluation, EvaluationTime: 30},
+			{Namespace: "namespace1", Group: "slow-1", Rules: 1, Interval: 60, LastEvaluation: lastEvaluation, EvaluationTime: 5},
+		}, slowestRuleGroups(groups, 2))
+
+		assert.Len(t, slowestRuleGroups(groups, 10), len(groups))
+	})
+
+	t.Run("should return an empty list if there are no rule groups", func(t *testing.T) {
+		assert.Equal(t, []*RuleGroupEvaluationCost{}, slowestRuleGroups(nil, 2))
+	})
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	promRules "github.com/prometheus/prometheus/rules"
+)
+
+// evaluatedRuleGroup is the subset of the rules.Group API used to track the time spent evaluating it.
+type evaluatedRuleGroup interface {
+	File() string
+	Name() string
+	Interval() time.Duration
+	GetLastEvaluation() time.Time
+	GetEvaluationTime() time.Duration
+}
+
+// tenantEvaluationTimeTracker tracks the cumulative time spent evaluating the rule groups of each tenant,
+// eg. for billing and chargeback. The rule groups only expose the duration of their last evaluation, so the
+// cumulative time is approximated from the last evaluation of each rule group on every update: if a rule group
+// has been evaluated multiple times since the previous update, its last evaluation duration is accounted for
+// each missed evaluation interval.
+type tenantEvaluationTimeTracker struct {
+	mtx sync.Mutex
+
+	// Last evaluation of each rule group, by tenant, accounted at the previous update.
+	lastEvaluations map[string]map[string]time.Time
+
+	evaluationSeconds *prometheus.CounterVec
+}
+
+func newTenantEvaluationTimeTracker(reg prometheus.Registerer) *tenantEvaluationTimeTracker {
+	return &tenantEvaluationTimeTracker{
+		lastEvaluations: map[string]map[string]time.Time{},
+		evaluationSeconds: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_tenant_evaluation_seconds_total",
+			Help: "Cumulative time spent evaluating the tenant's rule groups, approximated from the duration of the last evaluation of each rule group. Updated on every rules sync.",
+		}, []string{"user"}),
+	}
+}
+
+// update accounts the evaluations of the input rule groups of a tenant run since the previous update.
+func (t *tenantEvaluationTimeTracker) update(userID string, groups []evaluatedRuleGroup) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	prevEvaluations := t.lastEvaluations[userID]
+	lastEvaluations := make(map[string]time.Time, len(groups))
+	seconds := 0.0
+
+	for _, g := range groups {
+		lastEvaluation := g.GetLastEvaluation()
+		if lastEvaluation.IsZero() {
+			// The rule group has not been evaluated yet.
+			continue
+		}
+
+		key := promRules.GroupKey(g.File(), g.Name())
+		lastEvaluations[key] = lastEvaluation
+
+		prevEvaluation, ok := prevEvaluations[key]
+		if ok && !lastEvaluation.After(prevEvaluation) {
+			// No evaluation since the previous update.
+			continue
+		}
+
+		evaluations := 1
+		if ok && g.Interval() > 0 {
+			if missed := int(lastEvaluation.Sub(prevEvaluation) / g.Interval()); missed > 1 {
+				evaluations = missed
+			}
+		}
+
+		seconds += float64(evaluations) * g.GetEvaluationTime().Seconds()
+	}
+
+	t.lastEvaluations[userID] = lastEvaluations
+	t.evaluationSeconds.WithLabelValues(userID).Add(seconds)
+}
+
+// removeMissingUsers stops tracking the tenants not in the input set, eg. because their
+// rule groups have been deleted or are not run by this ruler anymore.
+func (t *tenantEvaluationTimeTracker) removeMissingUsers(userIDs map[string]struct{}) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for userID := range t.lastEvaluations {
+		if _, ok := userIDs[userID]; !ok {
+			delete(t.lastEvaluations, userID)
+			t.evaluationSeconds.DeleteLabelValues(userID)
+		}
+	}
+}
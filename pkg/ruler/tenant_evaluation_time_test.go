@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type evaluatedRuleGroupMock struct {
+	name           string
+	interval       time.Duration
+	lastEvaluation time.Time
+	evaluationTime time.Duration
+}
+
+func (g *evaluatedRuleGroupMock) File() string                     { return "namespace" }
+func (g *evaluatedRuleGroupMock) Name() string                     { return g.name }
+func (g *evaluatedRuleGroupMock) Interval() time.Duration          { return g.interval }
+func (g *evaluatedRuleGroupMock) GetLastEvaluation() time.Time     { return g.lastEvaluation }
+func (g *evaluatedRuleGroupMock) GetEvaluationTime() time.Duration { return g.evaluationTime }
+
+func TestTenantEvaluationTimeTracker(t *testing.T) {
+	now := time.Now()
+
+	group1 := &evaluatedRuleGroupMock{name: "group-1", interval: time.Minute, lastEvaluation: now, evaluationTime: 2 * time.Second}
+	group2 := &evaluatedRuleGroupMock{name: "group-2", interval: time.Minute, lastEvaluation: now, evaluationTime: 3 * time.Second}
+	group3 := &evaluatedRuleGroupMock{name: "group-3", interval: time.Minute, evaluationTime: time.Second}
+
+	reg := prometheus.NewPedanticRegistry()
+	tracker := newTenantEvaluationTimeTracker(reg)
+
+	// First sync: the last evaluation of each rule group is accounted, while rule groups never evaluated are skipped.
+	tracker.update("user-1", []evaluatedRuleGroup{group1, group2})
+	tracker.update("user-2", []evaluatedRuleGroup{group3})
+	tracker.removeMissingUsers(map[string]struct{}{"user-1": {}, "user-2": {}})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_tenant_evaluation_seconds_total Cumulative time spent evaluating the tenant's rule groups, approximated from the duration of the last evaluation of each rule group. Updated on every rules sync.
+		# TYPE cortex_ruler_tenant_evaluation_seconds_total counter
+		cortex_ruler_tenant_evaluation_seconds_total{user="user-1"} 5
+		cortex_ruler_tenant_evaluation_seconds_total{user="user-2"} 0
+	`), "cortex_ruler_tenant_evaluation_seconds_total"))
+
+	// Second sync: group-1 has been evaluated twice since the previous sync, group-2 has not been evaluated,
+	// and group-3 has been evaluated for the first time. The tenant user-2 is gone from the ruler.
+	group1.lastEvaluation = now.Add(2 * time.Minute)
+	group1.evaluationTime = 4 * time.Second
+	group3.lastEvaluation = now
+
+	tracker.update("user-1", []evaluatedRuleGroup{group1, group2, group3})
+	tracker.removeMissingUsers(map[string]struct{}{"user-1": {}})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_ruler_tenant_evaluation_seconds_total Cumulative time spent evaluating the tenant's rule groups, approximated from the duration of the last evaluation of each rule group. Updated on every rules sync.
+		# TYPE cortex_ruler_tenant_evaluation_seconds_total counter
+		cortex_ruler_tenant_evaluation_seconds_total{user="user-1"} 14
+	`), "cortex_ruler_tenant_evaluation_seconds_total"))
+
+	require.NotContains(t, tracker.lastEvaluations, "user-2")
+	assert.Len(t, tracker.lastEvaluations["user-1"], 3)
+}
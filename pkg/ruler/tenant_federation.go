@@ -5,8 +5,12 @@ package ruler
 import (
 	"context"
 	"flag"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
 	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/rules"
 	"github.com/weaveworks/common/user"
@@ -14,6 +18,7 @@ import (
 	"github.com/grafana/dskit/tenant"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 type TenantFederationConfig struct {
@@ -67,3 +72,39 @@ func RemoveFederatedRuleGroups(groups map[string]rulespb.RuleGroupList) {
 		groups[userID] = amended
 	}
 }
+
+// validateSourceTenants returns an error if any of the source tenants of a rule group of the user isn't
+// in the allowed ones. The user itself is always allowed, and an empty allow-list allows any source tenant.
+func validateSourceTenants(userID string, sourceTenants, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	for _, sourceTenant := range sourceTenants {
+		if sourceTenant != userID && !util.StringsContain(allowed, sourceTenant) {
+			return fmt.Errorf(errSourceTenantNotAllowed, sourceTenant, strings.Join(allowed, ","))
+		}
+	}
+	return nil
+}
+
+// RemoveDisallowedFederatedRuleGroups removes the federated rule groups whose source tenants are not allowed,
+// which may have been stored before the allowed source tenants were restricted.
+func RemoveDisallowedFederatedRuleGroups(groups map[string]rulespb.RuleGroupList, limits RulesLimits, logger log.Logger) {
+	for userID, groupList := range groups {
+		allowed := limits.RulerAllowedSourceTenants(userID)
+		if len(allowed) == 0 {
+			continue
+		}
+
+		amended := make(rulespb.RuleGroupList, 0, len(groupList))
+		for _, group := range groupList {
+			if err := validateSourceTenants(userID, group.GetSourceTenants(), allowed); err != nil {
+				level.Warn(logger).Log("msg", "skipping federated rule group", "user", userID, "namespace", group.GetNamespace(), "group", group.GetName(), "err", err)
+				continue
+			}
+			amended = append(amended, group)
+		}
+		groups[userID] = amended
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/go-kit/log"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
@@ -90,3 +91,61 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateSourceTenants(t *testing.T) {
+	const userID = "tenant-1"
+
+	testCases := map[string]struct {
+		sourceTenants []string
+		allowed       []string
+		expectedErr   string
+	}{
+		"no source tenants": {
+			allowed: []string{"tenant-2"},
+		},
+		"no allowed source tenants configured": {
+			sourceTenants: []string{"tenant-2", "tenant-3"},
+		},
+		"all source tenants allowed": {
+			sourceTenants: []string{"tenant-2", "tenant-3"},
+			allowed:       []string{"tenant-2", "tenant-3", "tenant-4"},
+		},
+		"the tenant itself is always allowed": {
+			sourceTenants: []string{userID, "tenant-2"},
+			allowed:       []string{"tenant-2"},
+		},
+		"a source tenant is not allowed": {
+			sourceTenants: []string{"tenant-2", "tenant-3"},
+			allowed:       []string{"tenant-2"},
+			expectedErr:   "source tenant tenant-3 is not allowed for the tenant's federated rule groups (allowed: tenant-2)",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := validateSourceTenants(userID, tc.sourceTenants, tc.allowed)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestRemoveDisallowedFederatedRuleGroups(t *testing.T) {
+	const userID = "tenant-1"
+
+	regularGroup := &rulespb.RuleGroupDesc{Namespace: "ns", Name: "non-federated", User: userID}
+	allowedGroup := &rulespb.RuleGroupDesc{Namespace: "ns", Name: "federated-allowed", User: userID, SourceTenants: []string{"tenant-2"}}
+	disallowedGroup := &rulespb.RuleGroupDesc{Namespace: "ns", Name: "federated-disallowed", User: userID, SourceTenants: []string{"tenant-2", "tenant-3"}}
+
+	groups := map[string]rulespb.RuleGroupList{userID: {regularGroup, allowedGroup, disallowedGroup}}
+	RemoveDisallowedFederatedRuleGroups(groups, ruleLimits{allowedSourceTenants: []string{"tenant-2"}}, log.NewNopLogger())
+	require.Equal(t, rulespb.RuleGroupList{regularGroup, allowedGroup}, groups[userID])
+
+	// No group is removed if any source tenant is allowed.
+	groups = map[string]rulespb.RuleGroupList{userID: {regularGroup, allowedGroup, disallowedGroup}}
+	RemoveDisallowedFederatedRuleGroups(groups, ruleLimits{}, log.NewNopLogger())
+	require.Equal(t, rulespb.RuleGroupList{regularGroup, allowedGroup, disallowedGroup}, groups[userID])
+}
@@ -91,6 +91,19 @@ type Block struct {
 
 	// Block's compactor shard ID, copied from tsdb.CompactorShardIDExternalLabel label.
 	CompactorShardID string `json:"compactor_shard_id,omitempty"`
+
+	// NumChunks is the number of chunks in the block, copied from the block stats. It's
+	// zero if the stats were not available when the block has been added to the index.
+	NumChunks uint64 `json:"num_chunks,omitempty"`
+
+	// CompactionLevel is the compaction level of the block, copied from the block meta. It's
+	// zero if the block has been added to the index before the level was tracked.
+	CompactionLevel int `json:"compaction_level,omitempty"`
+
+	// Source is the source of the block (eg. "receive" for blocks shipped by ingesters, "compactor"
+	// for compacted blocks), copied from the block meta. It's empty if the block has been added to
+	// the index before the source was tracked.
+	Source string `json:"source,omitempty"`
 }
 
 // Within returns whether the block contains samples within the provided range.
@@ -154,6 +167,9 @@ func BlockFromThanosMeta(meta metadata.Meta) *Block {
 		SegmentsFormat:   segmentsFormat,
 		SegmentsNum:      segmentsNum,
 		CompactorShardID: meta.Thanos.Labels[mimir_tsdb.CompactorShardIDExternalLabel],
+		NumChunks:        meta.Stats.NumChunks,
+		CompactionLevel:  meta.Compaction.Level,
+		Source:           string(meta.Thanos.Source),
 	}
 }
 
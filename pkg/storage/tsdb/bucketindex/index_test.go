@@ -272,6 +272,40 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				CompactorShardID: "some weird value",
 			},
 		},
+		"meta.json with compaction level": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:       blockID,
+					MinTime:    10,
+					MaxTime:    20,
+					Compaction: tsdb.BlockMetaCompaction{Level: 3},
+				},
+			},
+			expected: Block{
+				ID:              blockID,
+				MinTime:         10,
+				MaxTime:         20,
+				CompactionLevel: 3,
+			},
+		},
+		"meta.json with source": {
+			meta: metadata.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: metadata.Thanos{
+					Source: metadata.ReceiveSource,
+				},
+			},
+			expected: Block{
+				ID:      blockID,
+				MinTime: 10,
+				MaxTime: 20,
+				Source:  "receive",
+			},
+		},
 	}
 
 	for testName, testData := range tests {
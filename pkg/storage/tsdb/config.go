@@ -352,6 +352,8 @@ type BucketIndexConfig struct {
 	UpdateOnErrorInterval time.Duration `yaml:"update_on_error_interval" category:"advanced"`
 	IdleTimeout           time.Duration `yaml:"idle_timeout" category:"advanced"`
 	MaxStalePeriod        time.Duration `yaml:"max_stale_period" category:"advanced"`
+
+	StaleFallbackScanInterval time.Duration `yaml:"stale_fallback_scan_interval" category:"experimental"`
 }
 
 func (cfg *BucketIndexConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
@@ -359,4 +361,5 @@ func (cfg *BucketIndexConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix st
 	f.DurationVar(&cfg.UpdateOnErrorInterval, prefix+"update-on-error-interval", time.Minute, "How frequently a bucket index, which previously failed to load, should be tried to load again. This option is used only by querier.")
 	f.DurationVar(&cfg.IdleTimeout, prefix+"idle-timeout", time.Hour, "How long a unused bucket index should be cached. Once this timeout expires, the unused bucket index is removed from the in-memory cache. This option is used only by querier.")
 	f.DurationVar(&cfg.MaxStalePeriod, prefix+"max-stale-period", time.Hour, "The maximum allowed age of a bucket index (last updated) before queries start failing because the bucket index is too old. The bucket index is periodically updated by the compactor, and this check is enforced in the querier (at query time).")
+	f.DurationVar(&cfg.StaleFallbackScanInterval, prefix+"stale-fallback-scan-interval", 0, "If greater than 0, when the bucket index of a tenant is older than the max stale period, the querier finds the tenant blocks scanning the bucket, instead of failing the query. The bucket is scanned at most once per tenant within this interval, and the blocks found by the last scan are used in between. Store-gateways keep loading the blocks from the too old bucket index, so the blocks found scanning the bucket which are not in the bucket index are not queried, and a warning is returned for them. 0 to disable.")
 }
@@ -934,6 +934,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		reqBlockMatchers []*labels.Matcher
 		chunksLimiter    = s.chunksLimiterFactory(s.metrics.queriesDropped.WithLabelValues("chunks"))
 		seriesLimiter    = s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
+		failedBlocks     = blockErrors{}
 	)
 
 	if req.Hints != nil {
@@ -1000,7 +1001,15 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 				s.logger,
 			)
 			if err != nil {
-				return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
+				err = errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
+				if !isTolerableBlockError(req.PartialResponseStrategy, err) {
+					return err
+				}
+
+				mtx.Lock()
+				failedBlocks[b.meta.ULID] = err
+				mtx.Unlock()
+				return nil
 			}
 
 			mtx.Lock()
@@ -1055,6 +1064,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 			}
 			return status.Error(code, err.Error())
 		}
+		resHints.QueriedBlocks = failedBlocks.removeFrom(resHints.QueriedBlocks)
 		stats.blocksQueried = len(res)
 		stats.getAllDuration = time.Since(begin)
 		s.metrics.seriesGetAllDuration.Observe(stats.getAllDuration.Seconds())
@@ -1097,6 +1107,15 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		err = nil
 	})
 
+	if err == nil {
+		for _, w := range failedBlocks.warnings() {
+			if err = srv.Send(storepb.NewWarnSeriesResponse(errors.New(w))); err != nil {
+				err = status.Error(codes.Unknown, errors.Wrap(err, "send series warning response").Error())
+				return
+			}
+		}
+	}
+
 	if s.enableSeriesResponseHints {
 		var anyHints *types.Any
 
@@ -1114,6 +1133,59 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 	return err
 }
 
+// isTolerableBlockError returns whether an error occurred while querying a single block can be
+// returned as a warning, instead of failing the whole request, given the request partial response
+// strategy. Errors carrying a gRPC status (eg. a limit has been reached) are never tolerated.
+func isTolerableBlockError(strategy storepb.PartialResponseStrategy, err error) bool {
+	if strategy != storepb.PartialResponseStrategy_WARN {
+		return false
+	}
+
+	_, isStatus := status.FromError(errors.Cause(err))
+	return !isStatus
+}
+
+// blockErrors holds the errors occurred while querying blocks, keyed by block ID.
+type blockErrors map[ulid.ULID]error
+
+// removeFrom returns the input queried blocks without the failed ones, so that the
+// querier doesn't consider them queried and fetches them from another store-gateway.
+func (e blockErrors) removeFrom(queried []hintspb.Block) []hintspb.Block {
+	if len(e) == 0 {
+		return queried
+	}
+
+	filtered := make([]hintspb.Block, 0, len(queried))
+	for _, b := range queried {
+		if id, err := ulid.Parse(b.Id); err == nil {
+			if _, failed := e[id]; failed {
+				continue
+			}
+		}
+		filtered = append(filtered, b)
+	}
+	return filtered
+}
+
+// warnings returns the errors as warning messages, sorted by block ID.
+func (e blockErrors) warnings() []string {
+	if len(e) == 0 {
+		return nil
+	}
+
+	ids := make([]ulid.ULID, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+
+	warnings := make([]string, 0, len(ids))
+	for _, id := range ids {
+		warnings = append(warnings, e[id].Error())
+	}
+	return warnings
+}
+
 func chunksSize(chks []storepb.AggrChunk) (size int) {
 	for _, chk := range chks {
 		size += chk.Size() // This gets the encoded proto size.
@@ -1150,6 +1222,7 @@ func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesReq
 
 	var mtx sync.Mutex
 	var sets [][]string
+	failedBlocks := blockErrors{}
 	seriesLimiter := s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
 
 	for _, b := range s.blocks {
@@ -1170,7 +1243,15 @@ func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesReq
 
 			result, err := blockLabelNames(gctx, indexr, reqSeriesMatchers, seriesLimiter, s.logger)
 			if err != nil {
-				return errors.Wrapf(err, "block %s", b.meta.ULID)
+				err = errors.Wrapf(err, "block %s", b.meta.ULID)
+				if !isTolerableBlockError(req.PartialResponseStrategy, err) {
+					return err
+				}
+
+				mtx.Lock()
+				failedBlocks[b.meta.ULID] = err
+				mtx.Unlock()
+				return nil
 			}
 
 			if len(result) > 0 {
@@ -1189,14 +1270,17 @@ func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesReq
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	resHints.QueriedBlocks = failedBlocks.removeFrom(resHints.QueriedBlocks)
+
 	anyHints, err := types.MarshalAny(resHints)
 	if err != nil {
 		return nil, status.Error(codes.Unknown, errors.Wrap(err, "marshal label names response hints").Error())
 	}
 
 	return &storepb.LabelNamesResponse{
-		Names: strutil.MergeSlices(sets...),
-		Hints: anyHints,
+		Names:    strutil.MergeSlices(sets...),
+		Warnings: failedBlocks.warnings(),
+		Hints:    anyHints,
 	}, nil
 }
 
@@ -1218,6 +1302,8 @@ func blockLabelNames(ctx context.Context, indexr *bucketIndexReader, matchers []
 	}
 
 	// We ignore request's min/max time and query the entire block to make the result cacheable.
+	// Only the series labels are needed, so chunks are skipped and no chunk reader is passed, to
+	// guarantee no chunk is ever fetched to serve label names.
 	minTime, maxTime := indexr.block.meta.MinTime, indexr.block.meta.MaxTime
 	seriesSet, _, err := blockSeries(ctx, indexr, nil, matchers, nil, nil, nil, seriesLimiter, true, minTime, maxTime, nil, logger)
 	if err != nil {
@@ -1312,6 +1398,7 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 
 	var mtx sync.Mutex
 	var sets [][]string
+	failedBlocks := blockErrors{}
 	for _, b := range s.blocks {
 		b := b
 
@@ -1331,7 +1418,15 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 
 			result, err := blockLabelValues(gctx, indexr, req.Label, reqSeriesMatchers, s.logger)
 			if err != nil {
-				return errors.Wrapf(err, "block %s", b.meta.ULID)
+				err = errors.Wrapf(err, "block %s", b.meta.ULID)
+				if !isTolerableBlockError(req.PartialResponseStrategy, err) {
+					return err
+				}
+
+				mtx.Lock()
+				failedBlocks[b.meta.ULID] = err
+				mtx.Unlock()
+				return nil
 			}
 
 			if len(result) > 0 {
@@ -1350,14 +1445,17 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 		return nil, status.Error(codes.Aborted, err.Error())
 	}
 
+	resHints.QueriedBlocks = failedBlocks.removeFrom(resHints.QueriedBlocks)
+
 	anyHints, err := types.MarshalAny(resHints)
 	if err != nil {
 		return nil, status.Error(codes.Unknown, errors.Wrap(err, "marshal label values response hints").Error())
 	}
 
 	return &storepb.LabelValuesResponse{
-		Values: strutil.MergeSlices(sets...),
-		Hints:  anyHints,
+		Values:   strutil.MergeSlices(sets...),
+		Warnings: failedBlocks.warnings(),
+		Hints:    anyHints,
 	}, nil
 }
 
@@ -44,6 +44,10 @@ import (
 // (This is now separate from DeprecatedTenantIDExternalLabel to signify different use case.)
 const GrpcContextMetadataTenantID = "__org_id__"
 
+// GrpcContextMetadataQueryPriority is a key for GRPC Metadata used to pass the priority of the query to
+// store-gateway process, so that interactive queries can be prioritized over batch ones.
+const GrpcContextMetadataQueryPriority = "__query_priority__"
+
 // BucketStores is a multi-tenant wrapper of Thanos BucketStore.
 type BucketStores struct {
 	logger             log.Logger
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -33,6 +34,7 @@ import (
 	"github.com/leanovate/gopter/gen"
 	"github.com/leanovate/gopter/prop"
 	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
@@ -56,6 +58,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/store/hintspb"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
+	"github.com/weaveworks/common/httpgrpc"
 	"go.uber.org/atomic"
 
 	"github.com/grafana/mimir/pkg/compactor"
@@ -1680,6 +1683,54 @@ func TestSeries_ErrorUnmarshallingRequestHints(t *testing.T) {
 	assert.Equal(t, true, regexp.MustCompile(".*unmarshal series request hints.*").MatchString(err.Error()))
 }
 
+func TestSeries_PartialResponseStrategy(t *testing.T) {
+	_, store, seriesSet1, _, block1, block2, close := setupStoreForHintsTest(t)
+	defer close()
+
+	// Remove the chunks of block2 from the bucket (created by setupStoreForHintsTest
+	// in the "bkt" subdirectory of the store dir), so that querying it fails.
+	require.NoError(t, os.RemoveAll(filepath.Join(store.dir, "bkt", block2.String(), "chunks")))
+
+	newRequest := func(strategy storepb.PartialResponseStrategy) *storepb.SeriesRequest {
+		return &storepb.SeriesRequest{
+			MinTime: 0,
+			MaxTime: 3,
+			Matchers: []storepb.LabelMatcher{
+				{Type: storepb.LabelMatcher_EQ, Name: "foo", Value: "bar"},
+			},
+			PartialResponseStrategy: strategy,
+		}
+	}
+
+	t.Run("should fail the request with the ABORT strategy", func(t *testing.T) {
+		srv := newBucketStoreSeriesServer(context.Background())
+		err := store.Series(newRequest(storepb.PartialResponseStrategy_ABORT), srv)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), block2.String())
+	})
+
+	t.Run("should return the series of the other blocks along with a warning with the WARN strategy", func(t *testing.T) {
+		srv := newBucketStoreSeriesServer(context.Background())
+		require.NoError(t, store.Series(newRequest(storepb.PartialResponseStrategy_WARN), srv))
+		assert.Equal(t, seriesSet1, srv.SeriesSet)
+		require.Len(t, srv.Warnings, 1)
+		assert.Contains(t, srv.Warnings[0].Error(), block2.String())
+
+		// The failed block should not be reported as queried, so that the querier fetches it again.
+		assert.Equal(t, hintspb.SeriesResponseHints{QueriedBlocks: []hintspb.Block{{Id: block1.String()}}}, srv.Hints)
+	})
+}
+
+func TestIsTolerableBlockError(t *testing.T) {
+	genericErr := errors.Wrap(errors.New("failed to read chunks"), "fetch series for block")
+	limitErr := errors.Wrap(httpgrpc.Errorf(http.StatusUnprocessableEntity, "limit exceeded"), "fetch series for block")
+
+	assert.False(t, isTolerableBlockError(storepb.PartialResponseStrategy_ABORT, genericErr))
+	assert.False(t, isTolerableBlockError(storepb.PartialResponseStrategy_ABORT, limitErr))
+	assert.True(t, isTolerableBlockError(storepb.PartialResponseStrategy_WARN, genericErr))
+	assert.False(t, isTolerableBlockError(storepb.PartialResponseStrategy_WARN, limitErr))
+}
+
 func TestSeries_BlockWithMultipleChunks(t *testing.T) {
 	tmpDir := t.TempDir()
 
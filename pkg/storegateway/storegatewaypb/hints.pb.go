@@ -0,0 +1,760 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: hints.proto
+
+package storegatewaypb
+
+import (
+	fmt "fmt"
+	_ "github.com/gogo/protobuf/gogoproto"
+	proto "github.com/gogo/protobuf/proto"
+	io "io"
+	math "math"
+	math_bits "math/bits"
+	reflect "reflect"
+	strings "strings"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// SeriesResponseHintsExtension holds the hints returned by store-gateways in the series response
+// in addition to the thanos hintspb.SeriesResponseHints ones. Its fields are encoded in the same
+// hints payload, with field numbers not used by hintspb.SeriesResponseHints, so that both messages
+// can be unmarshalled from the payload, whether the additional fields are present or not.
+type SeriesResponseHintsExtension struct {
+	// The number of series and chunks touched in each queried block.
+	BlockSeriesCounts []BlockSeriesCount `protobuf:"bytes,100,rep,name=block_series_counts,json=blockSeriesCounts,proto3" json:"block_series_counts"`
+	// The number of bytes of the returned chunks which have been read from the cache, instead of
+	// the object storage.
+	FetchedChunkBytesFromCache uint64 `protobuf:"varint,101,opt,name=fetched_chunk_bytes_from_cache,json=fetchedChunkBytesFromCache,proto3" json:"fetched_chunk_bytes_from_cache,omitempty"`
+}
+
+func (m *SeriesResponseHintsExtension) Reset()      { *m = SeriesResponseHintsExtension{} }
+func (*SeriesResponseHintsExtension) ProtoMessage() {}
+func (*SeriesResponseHintsExtension) Descriptor() ([]byte, []int) {
+	return fileDescriptor_522be8e0d2634375, []int{0}
+}
+func (m *SeriesResponseHintsExtension) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *SeriesResponseHintsExtension) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_SeriesResponseHintsExtension.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *SeriesResponseHintsExtension) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SeriesResponseHintsExtension.Merge(m, src)
+}
+func (m *SeriesResponseHintsExtension) XXX_Size() int {
+	return m.Size()
+}
+func (m *SeriesResponseHintsExtension) XXX_DiscardUnknown() {
+	xxx_messageInfo_SeriesResponseHintsExtension.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SeriesResponseHintsExtension proto.InternalMessageInfo
+
+func (m *SeriesResponseHintsExtension) GetBlockSeriesCounts() []BlockSeriesCount {
+	if m != nil {
+		return m.BlockSeriesCounts
+	}
+	return nil
+}
+
+func (m *SeriesResponseHintsExtension) GetFetchedChunkBytesFromCache() uint64 {
+	if m != nil {
+		return m.FetchedChunkBytesFromCache
+	}
+	return 0
+}
+
+type BlockSeriesCount struct {
+	BlockId string `protobuf:"bytes,1,opt,name=block_id,json=blockId,proto3" json:"block_id,omitempty"`
+	// The number of series touched in the block.
+	Series uint64 `protobuf:"varint,2,opt,name=series,proto3" json:"series,omitempty"`
+	// The number of chunks touched in the block.
+	Chunks uint64 `protobuf:"varint,3,opt,name=chunks,proto3" json:"chunks,omitempty"`
+}
+
+func (m *BlockSeriesCount) Reset()      { *m = BlockSeriesCount{} }
+func (*BlockSeriesCount) ProtoMessage() {}
+func (*BlockSeriesCount) Descriptor() ([]byte, []int) {
+	return fileDescriptor_522be8e0d2634375, []int{1}
+}
+func (m *BlockSeriesCount) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *BlockSeriesCount) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_BlockSeriesCount.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *BlockSeriesCount) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BlockSeriesCount.Merge(m, src)
+}
+func (m *BlockSeriesCount) XXX_Size() int {
+	return m.Size()
+}
+func (m *BlockSeriesCount) XXX_DiscardUnknown() {
+	xxx_messageInfo_BlockSeriesCount.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BlockSeriesCount proto.InternalMessageInfo
+
+func (m *BlockSeriesCount) GetBlockId() string {
+	if m != nil {
+		return m.BlockId
+	}
+	return ""
+}
+
+func (m *BlockSeriesCount) GetSeries() uint64 {
+	if m != nil {
+		return m.Series
+	}
+	return 0
+}
+
+func (m *BlockSeriesCount) GetChunks() uint64 {
+	if m != nil {
+		return m.Chunks
+	}
+	return 0
+}
+
+func init() {
+	proto.RegisterType((*SeriesResponseHintsExtension)(nil), "gatewaypb.SeriesResponseHintsExtension")
+	proto.RegisterType((*BlockSeriesCount)(nil), "gatewaypb.BlockSeriesCount")
+}
+
+func init() { proto.RegisterFile("hints.proto", fileDescriptor_522be8e0d2634375) }
+
+var fileDescriptor_522be8e0d2634375 = []byte{
+	// 328 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x90, 0x31, 0x4e, 0xf3, 0x30,
+	0x14, 0xc7, 0xed, 0xaf, 0x55, 0x3f, 0xea, 0x4a, 0x08, 0x82, 0x84, 0x42, 0x41, 0x8f, 0xaa, 0x53,
+	0x17, 0x52, 0x09, 0x6e, 0x90, 0x02, 0x82, 0x91, 0xb0, 0x21, 0xa1, 0xa8, 0x76, 0xdc, 0x24, 0x2a,
+	0x8d, 0xab, 0xd8, 0x11, 0x74, 0xe3, 0x08, 0x1c, 0x83, 0x0b, 0x70, 0x87, 0x8e, 0x1d, 0x3b, 0x21,
+	0xea, 0x2c, 0x8c, 0x3d, 0x02, 0xb2, 0x53, 0x75, 0xe8, 0xe6, 0xdf, 0xd3, 0xcf, 0xff, 0xf7, 0xd7,
+	0x23, 0xad, 0x24, 0xcd, 0x94, 0xf4, 0xa6, 0xb9, 0x50, 0xc2, 0x69, 0xc6, 0x43, 0xc5, 0x5f, 0x87,
+	0xb3, 0x29, 0x6d, 0x5f, 0xc4, 0xa9, 0x4a, 0x0a, 0xea, 0x31, 0x31, 0xe9, 0xc7, 0x22, 0x16, 0x7d,
+	0x6b, 0xd0, 0x62, 0x64, 0xc9, 0x82, 0x7d, 0x55, 0x3f, 0xbb, 0x5f, 0x98, 0x9c, 0x3d, 0xf2, 0x3c,
+	0xe5, 0x32, 0xe0, 0x72, 0x2a, 0x32, 0xc9, 0xef, 0x4c, 0xee, 0xcd, 0x9b, 0xe2, 0x99, 0x4c, 0x45,
+	0xe6, 0x3c, 0x90, 0x23, 0xfa, 0x22, 0xd8, 0x38, 0x94, 0xd6, 0x0a, 0x99, 0x28, 0x32, 0x25, 0xdd,
+	0xa8, 0x53, 0xeb, 0xb5, 0x2e, 0x4f, 0xbd, 0xed, 0x62, 0xcf, 0x37, 0x56, 0x15, 0x35, 0x30, 0x8e,
+	0x5f, 0x9f, 0x7f, 0x9f, 0xa3, 0xe0, 0x90, 0xee, 0xcc, 0xa5, 0xe3, 0x13, 0x18, 0x71, 0xc5, 0x12,
+	0x1e, 0x85, 0x2c, 0x29, 0xb2, 0x71, 0x48, 0x67, 0x8a, 0xcb, 0x70, 0x94, 0x8b, 0x49, 0xc8, 0x86,
+	0x2c, 0xe1, 0x2e, 0xef, 0xe0, 0x5e, 0x3d, 0x68, 0x6f, 0xac, 0x81, 0x91, 0x7c, 0xe3, 0xdc, 0xe6,
+	0x62, 0x32, 0x30, 0x46, 0xf7, 0x99, 0x1c, 0xec, 0x2e, 0x74, 0x4e, 0xc8, 0x5e, 0x55, 0x35, 0x8d,
+	0x5c, 0xdc, 0xc1, 0xbd, 0x66, 0xf0, 0xdf, 0xf2, 0x7d, 0xe4, 0x1c, 0x93, 0x46, 0xd5, 0xdf, 0xfd,
+	0x67, 0xa3, 0x37, 0x64, 0xe6, 0xb6, 0x82, 0x74, 0x6b, 0xd5, 0xbc, 0x22, 0xff, 0x7a, 0xb1, 0x02,
+	0xb4, 0x5c, 0x01, 0x5a, 0xaf, 0x00, 0xbf, 0x6b, 0xc0, 0x9f, 0x1a, 0xf0, 0x5c, 0x03, 0x5e, 0x68,
+	0xc0, 0x3f, 0x1a, 0xf0, 0xaf, 0x06, 0xb4, 0xd6, 0x80, 0x3f, 0x4a, 0x40, 0x8b, 0x12, 0xd0, 0xb2,
+	0x04, 0xf4, 0xb4, 0x2f, 0x95, 0xc8, 0xf9, 0xf6, 0x24, 0xb4, 0x61, 0x6f, 0x7c, 0xf5, 0x37, 0x00,
+	0x70, 0x1e, 0xba, 0xc9, 0xac, 0x01, 0x00, 0x00,
+}
+
+func (this *SeriesResponseHintsExtension) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*SeriesResponseHintsExtension)
+	if !ok {
+		that2, ok := that.(SeriesResponseHintsExtension)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if len(this.BlockSeriesCounts) != len(that1.BlockSeriesCounts) {
+		return false
+	}
+	for i := range this.BlockSeriesCounts {
+		if !this.BlockSeriesCounts[i].Equal(&that1.BlockSeriesCounts[i]) {
+			return false
+		}
+	}
+	if this.FetchedChunkBytesFromCache != that1.FetchedChunkBytesFromCache {
+		return false
+	}
+	return true
+}
+func (this *BlockSeriesCount) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*BlockSeriesCount)
+	if !ok {
+		that2, ok := that.(BlockSeriesCount)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.BlockId != that1.BlockId {
+		return false
+	}
+	if this.Series != that1.Series {
+		return false
+	}
+	if this.Chunks != that1.Chunks {
+		return false
+	}
+	return true
+}
+func (this *SeriesResponseHintsExtension) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&storegatewaypb.SeriesResponseHintsExtension{")
+	if this.BlockSeriesCounts != nil {
+		vs := make([]BlockSeriesCount, len(this.BlockSeriesCounts))
+		for i := range vs {
+			vs[i] = this.BlockSeriesCounts[i]
+		}
+		s = append(s, "BlockSeriesCounts: "+fmt.Sprintf("%#v", vs)+",\n")
+	}
+	s = append(s, "FetchedChunkBytesFromCache: "+fmt.Sprintf("%#v", this.FetchedChunkBytesFromCache)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *BlockSeriesCount) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&storegatewaypb.BlockSeriesCount{")
+	s = append(s, "BlockId: "+fmt.Sprintf("%#v", this.BlockId)+",\n")
+	s = append(s, "Series: "+fmt.Sprintf("%#v", this.Series)+",\n")
+	s = append(s, "Chunks: "+fmt.Sprintf("%#v", this.Chunks)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func valueToGoStringHints(v interface{}, typ string) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("func(v %v) *%v { return &v } ( %#v )", typ, typ, pv)
+}
+func (m *SeriesResponseHintsExtension) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *SeriesResponseHintsExtension) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SeriesResponseHintsExtension) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.FetchedChunkBytesFromCache != 0 {
+		i = encodeVarintHints(dAtA, i, uint64(m.FetchedChunkBytesFromCache))
+		i--
+		dAtA[i] = 0x6
+		i--
+		dAtA[i] = 0xa8
+	}
+	if len(m.BlockSeriesCounts) > 0 {
+		for iNdEx := len(m.BlockSeriesCounts) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.BlockSeriesCounts[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintHints(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x6
+			i--
+			dAtA[i] = 0xa2
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *BlockSeriesCount) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *BlockSeriesCount) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *BlockSeriesCount) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Chunks != 0 {
+		i = encodeVarintHints(dAtA, i, uint64(m.Chunks))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.Series != 0 {
+		i = encodeVarintHints(dAtA, i, uint64(m.Series))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.BlockId) > 0 {
+		i -= len(m.BlockId)
+		copy(dAtA[i:], m.BlockId)
+		i = encodeVarintHints(dAtA, i, uint64(len(m.BlockId)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintHints(dAtA []byte, offset int, v uint64) int {
+	offset -= sovHints(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *SeriesResponseHintsExtension) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.BlockSeriesCounts) > 0 {
+		for _, e := range m.BlockSeriesCounts {
+			l = e.Size()
+			n += 2 + l + sovHints(uint64(l))
+		}
+	}
+	if m.FetchedChunkBytesFromCache != 0 {
+		n += 2 + sovHints(uint64(m.FetchedChunkBytesFromCache))
+	}
+	return n
+}
+
+func (m *BlockSeriesCount) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.BlockId)
+	if l > 0 {
+		n += 1 + l + sovHints(uint64(l))
+	}
+	if m.Series != 0 {
+		n += 1 + sovHints(uint64(m.Series))
+	}
+	if m.Chunks != 0 {
+		n += 1 + sovHints(uint64(m.Chunks))
+	}
+	return n
+}
+
+func sovHints(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozHints(x uint64) (n int) {
+	return sovHints(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *SeriesResponseHintsExtension) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForBlockSeriesCounts := "[]BlockSeriesCount{"
+	for _, f := range this.BlockSeriesCounts {
+		repeatedStringForBlockSeriesCounts += strings.Replace(strings.Replace(f.String(), "BlockSeriesCount", "BlockSeriesCount", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForBlockSeriesCounts += "}"
+	s := strings.Join([]string{`&SeriesResponseHintsExtension{`,
+		`BlockSeriesCounts:` + repeatedStringForBlockSeriesCounts + `,`,
+		`FetchedChunkBytesFromCache:` + fmt.Sprintf("%v", this.FetchedChunkBytesFromCache) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *BlockSeriesCount) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&BlockSeriesCount{`,
+		`BlockId:` + fmt.Sprintf("%v", this.BlockId) + `,`,
+		`Series:` + fmt.Sprintf("%v", this.Series) + `,`,
+		`Chunks:` + fmt.Sprintf("%v", this.Chunks) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func valueToStringHints(v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.IsNil() {
+		return "nil"
+	}
+	pv := reflect.Indirect(rv).Interface()
+	return fmt.Sprintf("*%v", pv)
+}
+func (m *SeriesResponseHintsExtension) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHints
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SeriesResponseHintsExtension: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SeriesResponseHintsExtension: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 100:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockSeriesCounts", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthHints
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthHints
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BlockSeriesCounts = append(m.BlockSeriesCounts, BlockSeriesCount{})
+			if err := m.BlockSeriesCounts[len(m.BlockSeriesCounts)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 101:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunkBytesFromCache", wireType)
+			}
+			m.FetchedChunkBytesFromCache = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunkBytesFromCache |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHints(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthHints
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *BlockSeriesCount) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowHints
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: BlockSeriesCount: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: BlockSeriesCount: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthHints
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthHints
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BlockId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Series", wireType)
+			}
+			m.Series = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Series |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Chunks", wireType)
+			}
+			m.Chunks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Chunks |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipHints(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthHints
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func skipHints(dAtA []byte) (n int, err error) {
+	l := len(dAtA)
+	iNdEx := 0
+	depth := 0
+	for iNdEx < l {
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return 0, ErrIntOverflowHints
+			}
+			if iNdEx >= l {
+				return 0, io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		wireType := int(wire & 0x7)
+		switch wireType {
+		case 0:
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				iNdEx++
+				if dAtA[iNdEx-1] < 0x80 {
+					break
+				}
+			}
+		case 1:
+			iNdEx += 8
+		case 2:
+			var length int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return 0, ErrIntOverflowHints
+				}
+				if iNdEx >= l {
+					return 0, io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				length |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if length < 0 {
+				return 0, ErrInvalidLengthHints
+			}
+			iNdEx += length
+		case 3:
+			depth++
+		case 4:
+			if depth == 0 {
+				return 0, ErrUnexpectedEndOfGroupHints
+			}
+			depth--
+		case 5:
+			iNdEx += 4
+		default:
+			return 0, fmt.Errorf("proto: illegal wireType %d", wireType)
+		}
+		if iNdEx < 0 {
+			return 0, ErrInvalidLengthHints
+		}
+		if depth == 0 {
+			return iNdEx, nil
+		}
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+var (
+	ErrInvalidLengthHints        = fmt.Errorf("proto: negative length found during unmarshaling")
+	ErrIntOverflowHints          = fmt.Errorf("proto: integer overflow")
+	ErrUnexpectedEndOfGroupHints = fmt.Errorf("proto: unexpected end of group")
+)
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package util
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolClientsGauge is a prometheus.Gauge tracking the clients of a ring client pool, which also counts the
+// clients created and evicted by the pool.
+type poolClientsGauge struct {
+	prometheus.Gauge
+
+	created prometheus.Counter
+	evicted prometheus.Counter
+}
+
+// NewPoolClientsGauge returns the gauge to pass to a ring client pool to track the current number of clients
+// in the pool, which also counts the clients created and evicted by the pool, because the pool updates the
+// gauge each time it creates or evicts a client. The pool evicts a client when its address isn't returned by
+// the pool service discovery anymore, or when the client fails the health check.
+func NewPoolClientsGauge(clients prometheus.Gauge, created, evicted prometheus.Counter) prometheus.Gauge {
+	return &poolClientsGauge{Gauge: clients, created: created, evicted: evicted}
+}
+
+func (g *poolClientsGauge) Inc() {
+	g.Add(1)
+}
+
+func (g *poolClientsGauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *poolClientsGauge) Add(v float64) {
+	g.Gauge.Add(v)
+
+	if v > 0 {
+		g.created.Add(v)
+	} else if v < 0 {
+		g.evicted.Add(-v)
+	}
+}
+
+func (g *poolClientsGauge) Sub(v float64) {
+	g.Add(-v)
+}
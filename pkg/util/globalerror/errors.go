@@ -30,6 +30,9 @@ const (
 	MaxChunksPerQuery             ID = "max-chunks-per-query"
 	MaxSeriesPerQuery             ID = "max-series-per-query"
 	MaxChunkBytesPerQuery         ID = "max-chunks-bytes-per-query"
+	MaxBlocksPerQuery             ID = "max-blocks-per-query"
+	MaxSeriesQueryLength          ID = "max-series-query-length"
+	MaxConcurrentQueriesPerTenant ID = "max-concurrent-queries-per-tenant"
 
 	DistributorMaxIngestionRate             ID = "distributor-max-ingestion-rate"
 	DistributorMaxInflightPushRequests      ID = "distributor-max-inflight-push-requests"
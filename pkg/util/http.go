@@ -7,6 +7,7 @@ package util
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"flag"
@@ -14,6 +15,7 @@ import (
 	"html/template"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-kit/log"
@@ -130,6 +132,53 @@ func StreamWriteYAMLResponse(w http.ResponseWriter, iter chan interface{}, logge
 	}
 }
 
+// StreamWriteYAMLResponseWithCompression stream writes data as http response like StreamWriteYAMLResponse,
+// compressing it with gzip if the client accepts it. The response is compressed while it's written, so
+// it's not buffered in memory.
+func StreamWriteYAMLResponseWithCompression(w http.ResponseWriter, r *http.Request, iter chan interface{}, logger log.Logger) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		StreamWriteYAMLResponse(w, iter, logger)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	gw := gzip.NewWriter(w)
+	StreamWriteYAMLResponse(&gzipResponseWriter{ResponseWriter: w, writer: gw}, iter, logger)
+
+	if err := gw.Close(); err != nil {
+		level.Error(logger).Log("msg", "write http response failed", "err", err)
+	}
+}
+
+// acceptsGzip returns whether the Accept-Encoding header of the request accepts the gzip encoding.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		// The encoding is rejected if its quality value is 0.
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			q, err := strconv.ParseFloat(params[len("q="):], 64)
+			return err != nil || q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter is a http.ResponseWriter writing the response body to a gzip writer.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b)
+}
+
 // CompressionType for encoding and decoding requests and responses.
 type CompressionType int
 
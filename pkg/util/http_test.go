@@ -7,6 +7,7 @@ package util_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"html/template"
 	"io"
@@ -143,6 +144,74 @@ func TestStreamWriteYAMLResponse(t *testing.T) {
 	assert.YAMLEq(t, tt.expectedOutput, w.Body.String())
 }
 
+func TestStreamWriteYAMLResponseWithCompression(t *testing.T) {
+	data := []map[string]int{{"a": 1}, {"b": 2}, {"c": 3}}
+
+	tests := map[string]struct {
+		acceptEncoding string
+		expectedGzip   bool
+	}{
+		"no Accept-Encoding": {
+			expectedGzip: false,
+		},
+		"gzip accepted": {
+			acceptEncoding: "gzip",
+			expectedGzip:   true,
+		},
+		"gzip accepted among other encodings": {
+			acceptEncoding: "deflate, gzip;q=0.8, br",
+			expectedGzip:   true,
+		},
+		"gzip rejected": {
+			acceptEncoding: "gzip;q=0, deflate",
+			expectedGzip:   false,
+		},
+		"other encodings only": {
+			acceptEncoding: "deflate, br",
+			expectedGzip:   false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if testData.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", testData.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			done := make(chan struct{})
+			iter := make(chan interface{})
+			go func() {
+				util.StreamWriteYAMLResponseWithCompression(w, req, iter, util_log.Logger)
+				close(done)
+			}()
+			for _, d := range data {
+				iter <- d
+			}
+			close(iter)
+			<-done
+
+			assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+			body := w.Body.Bytes()
+			if testData.expectedGzip {
+				assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+				gr, err := gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				body, err = io.ReadAll(gr)
+				require.NoError(t, err)
+			} else {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+			}
+
+			assert.YAMLEq(t, "a: 1\nb: 2\nc: 3\n", string(body))
+		})
+	}
+}
+
 func TestParseProtoReader(t *testing.T) {
 	// 47 bytes compressed and 53 uncompressed
 	req := &mimirpb.PreallocWriteRequest{
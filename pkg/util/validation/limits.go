@@ -25,24 +25,29 @@ import (
 )
 
 const (
-	MaxSeriesPerMetricFlag     = "ingester.max-global-series-per-metric"
-	MaxMetadataPerMetricFlag   = "ingester.max-global-metadata-per-metric"
-	MaxSeriesPerUserFlag       = "ingester.max-global-series-per-user"
-	MaxMetadataPerUserFlag     = "ingester.max-global-metadata-per-user"
-	MaxChunksPerQueryFlag      = "querier.max-fetched-chunks-per-query"
-	MaxChunkBytesPerQueryFlag  = "querier.max-fetched-chunk-bytes-per-query"
-	MaxSeriesPerQueryFlag      = "querier.max-fetched-series-per-query"
-	maxLabelNamesPerSeriesFlag = "validation.max-label-names-per-series"
-	maxLabelNameLengthFlag     = "validation.max-length-label-name"
-	maxLabelValueLengthFlag    = "validation.max-length-label-value"
-	maxMetadataLengthFlag      = "validation.max-metadata-length"
-	creationGracePeriodFlag    = "validation.create-grace-period"
-	maxQueryLengthFlag         = "store.max-query-length"
-	requestRateFlag            = "distributor.request-rate-limit"
-	requestBurstSizeFlag       = "distributor.request-burst-size"
-	ingestionRateFlag          = "distributor.ingestion-rate-limit"
-	ingestionBurstSizeFlag     = "distributor.ingestion-burst-size"
-	HATrackerMaxClustersFlag   = "distributor.ha-tracker.max-clusters"
+	MaxSeriesPerMetricFlag      = "ingester.max-global-series-per-metric"
+	MaxMetadataPerMetricFlag    = "ingester.max-global-metadata-per-metric"
+	MaxSeriesPerUserFlag        = "ingester.max-global-series-per-user"
+	MaxMetadataPerUserFlag      = "ingester.max-global-metadata-per-user"
+	MaxChunksPerQueryFlag       = "querier.max-fetched-chunks-per-query"
+	MaxChunkBytesPerQueryFlag   = "querier.max-fetched-chunk-bytes-per-query"
+	MaxSeriesPerQueryFlag       = "querier.max-fetched-series-per-query"
+	MaxBlocksPerQueryFlag       = "querier.max-blocks-per-query"
+	BlocksStoreMaxRefetchesFlag = "querier.blocks-store-max-refetches"
+	MaxSeriesQueryLengthFlag    = "querier.max-series-query-length"
+	maxLabelNamesPerSeriesFlag  = "validation.max-label-names-per-series"
+	maxLabelNameLengthFlag      = "validation.max-length-label-name"
+	maxLabelValueLengthFlag     = "validation.max-length-label-value"
+	maxMetadataLengthFlag       = "validation.max-metadata-length"
+	creationGracePeriodFlag     = "validation.create-grace-period"
+	maxQueryLengthFlag          = "store.max-query-length"
+	requestRateFlag             = "distributor.request-rate-limit"
+	requestBurstSizeFlag        = "distributor.request-burst-size"
+	ingestionRateFlag           = "distributor.ingestion-rate-limit"
+	ingestionBurstSizeFlag      = "distributor.ingestion-burst-size"
+	HATrackerMaxClustersFlag    = "distributor.ha-tracker.max-clusters"
+
+	MaxConcurrentQueriesPerTenantFlag = "querier.max-concurrent-queries-per-tenant"
 
 	// MinCompactorPartialBlockDeletionDelay is the minimum partial blocks deletion delay that can be configured in Mimir.
 	MinCompactorPartialBlockDeletionDelay = 4 * time.Hour
@@ -106,28 +111,43 @@ type Limits struct {
 	OutOfOrderTimeWindow model.Duration `yaml:"out_of_order_time_window" json:"out_of_order_time_window" category:"experimental"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery              int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
-	MaxFetchedSeriesPerQuery       int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
-	MaxFetchedChunkBytesPerQuery   int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
-	MaxQueryLookback               model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength                 model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism            int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	MaxLabelsQueryLength           model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
-	MaxCacheFreshness              model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
-	MaxQueriersPerTenant           int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryShardingTotalShards       int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
-	QueryShardingMaxShardedQueries int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
-	SplitInstantQueriesByInterval  model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
+	MaxChunksPerQuery                    int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
+	BlocksStoreMaxRefetches              int            `yaml:"blocks_store_max_refetches" json:"blocks_store_max_refetches" category:"advanced"`
+	QueryPartialDataOnConsistencyFailure bool           `yaml:"query_partial_data_on_consistency_failure" json:"query_partial_data_on_consistency_failure" category:"advanced"`
+	StoreGatewayPartialResponse          bool           `yaml:"store_gateway_partial_response" json:"store_gateway_partial_response" category:"advanced"`
+	MaxEstimatedChunksPerQueryMultiplier float64        `yaml:"max_estimated_chunks_per_query_multiplier" json:"max_estimated_chunks_per_query_multiplier" category:"advanced"`
+	StoreGatewayChunksDeduplication      bool           `yaml:"store_gateway_chunks_deduplication" json:"store_gateway_chunks_deduplication" category:"experimental"`
+	MaxFetchedSeriesPerQuery             int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery         int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
+	MaxBlocksPerQuery                    int            `yaml:"max_blocks_per_query" json:"max_blocks_per_query" category:"advanced"`
+	MaxConcurrentQueriesPerTenant        int            `yaml:"max_concurrent_queries_per_tenant" json:"max_concurrent_queries_per_tenant" category:"experimental"`
+	MaxQueryLookback                     model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength                       model.Duration `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism                  int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	MaxLabelsQueryLength                 model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
+	MaxSeriesQueryLength                 model.Duration `yaml:"max_series_query_length" json:"max_series_query_length" category:"advanced"`
+	MaxSeriesQueryLengthReject           bool           `yaml:"max_series_query_length_reject" json:"max_series_query_length_reject" category:"advanced"`
+	MaxCacheFreshness                    model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
+	MaxQueriersPerTenant                 int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QueryShardingTotalShards             int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+	QueryShardingMaxShardedQueries       int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
+	SplitInstantQueriesByInterval        model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
 	LabelNamesAndValuesResultsMaxSizeBytes        int  `yaml:"label_names_and_values_results_max_size_bytes" json:"label_names_and_values_results_max_size_bytes"`
 	LabelValuesMaxCardinalityLabelNamesPerRequest int  `yaml:"label_values_max_cardinality_label_names_per_request" json:"label_values_max_cardinality_label_names_per_request"`
 
 	// Ruler defaults and limits.
-	RulerEvaluationDelay        model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
-	RulerTenantShardSize        int            `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
-	RulerMaxRulesPerRuleGroup   int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
-	RulerMaxRuleGroupsPerTenant int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	RulerEvaluationDelay           model.Duration         `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
+	RulerEvaluationInterval        model.Duration         `yaml:"ruler_evaluation_interval" json:"ruler_evaluation_interval"`
+	RulerTenantShardSize           int                    `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
+	RulerMaxRulesPerRuleGroup      int                    `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
+	RulerMaxRuleGroupsPerTenant    int                    `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	RulerAllowedSourceTenants      flagext.StringSliceCSV `yaml:"ruler_allowed_source_tenants" json:"ruler_allowed_source_tenants" category:"experimental"`
+	RulerMaxNotificationsPerSecond float64                `yaml:"ruler_max_notifications_per_second" json:"ruler_max_notifications_per_second"`
+	RulerMaxConcurrentEvaluations  int                    `yaml:"ruler_max_concurrent_evaluations" json:"ruler_max_concurrent_evaluations" category:"experimental"`
+	RulerOutOfOrderTolerance       model.Duration         `yaml:"ruler_out_of_order_tolerance" json:"ruler_out_of_order_tolerance" category:"experimental"`
+	RulerEvaluationTimeout         model.Duration         `yaml:"ruler_evaluation_timeout" json:"ruler_evaluation_timeout" category:"experimental"`
 
 	// Store-gateway.
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
@@ -194,11 +214,20 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.OutOfOrderTimeWindow, "ingester.out-of-order-time-window", "Non-zero value enables out-of-order support for most recent samples that are within the time window in relation to the following two conditions: (1) The newest sample for that time series, if it exists. For example, within [series.maxTime-timeWindow, series.maxTime]). (2) The TSDB's maximum time, if the series does not exist. For example, within [db.maxTime-timeWindow, db.maxTime]). The ingester will need more memory as a factor of rate of out-of-order samples being ingested and the number of series that are getting out-of-order samples.")
 
 	f.IntVar(&l.MaxChunksPerQuery, MaxChunksPerQueryFlag, 2e6, "Maximum number of chunks that can be fetched in a single query from ingesters and long-term storage. This limit is enforced in the querier, ruler and store-gateway. 0 to disable.")
+	f.IntVar(&l.BlocksStoreMaxRefetches, BlocksStoreMaxRefetchesFlag, 3, "Maximum number of attempts the querier does to fetch the blocks required by a query from store-gateways, including the initial one. Blocks missing after an attempt are fetched again from other store-gateway replicas. Must be greater than or equal to 1.")
+	f.BoolVar(&l.QueryPartialDataOnConsistencyFailure, "querier.query-partial-data-on-consistency-failure", false, "If enabled, when the querier fails to fetch some blocks from store-gateways after all attempts, the query returns the partial results along with a warning listing the non-queried blocks instead of failing.")
+	f.BoolVar(&l.StoreGatewayPartialResponse, "querier.store-gateway-partial-response", false, "If enabled, store-gateways return the data they could fetch, along with a warning, instead of failing the request when some blocks can't be queried. Blocks which haven't been queried are fetched again from other store-gateways.")
+	f.Float64Var(&l.MaxEstimatedChunksPerQueryMultiplier, "querier.max-estimated-chunks-per-query-multiplier", 0, "Maximum number of chunks estimated to be fetched in a single query from store-gateways, based on the blocks metadata, expressed as a multiplier of the -"+MaxChunksPerQueryFlag+" limit. If the estimate exceeds the limit, the query is rejected before querying store-gateways. 0 to disable.")
+	f.BoolVar(&l.StoreGatewayChunksDeduplication, "querier.store-gateway-chunks-deduplication", false, "If enabled, when the same series is fetched from multiple store-gateways, eg. because a block has been queried from different store-gateways, the querier drops the chunks identical to other chunks of the series when merging them, instead of passing the duplicated chunks to the query engine.")
 	f.IntVar(&l.MaxFetchedSeriesPerQuery, MaxSeriesPerQueryFlag, 0, "The maximum number of unique series for which a query can fetch samples from each ingesters and storage. This limit is enforced in the querier and ruler. 0 to disable")
 	f.IntVar(&l.MaxFetchedChunkBytesPerQuery, MaxChunkBytesPerQueryFlag, 0, "The maximum size of all chunks in bytes that a query can fetch from each ingester and storage. This limit is enforced in the querier and ruler. 0 to disable.")
+	f.IntVar(&l.MaxBlocksPerQuery, MaxBlocksPerQueryFlag, 0, "Maximum number of blocks that can be queried from the long-term storage in a single query, after query sharding has been applied. Blocks marked for deletion are not counted. This limit is enforced in the querier and ruler. 0 to disable.")
+	f.IntVar(&l.MaxConcurrentQueriesPerTenant, MaxConcurrentQueriesPerTenantFlag, 0, "Maximum number of queries of the tenant that can concurrently fetch data from the store-gateways in each querier. The queries exceeding the limit wait up to -querier.max-concurrent-queries-per-tenant-wait for a running query to complete, and then are rejected with a retriable error. This limit is enforced in the querier and ruler. 0 to disable.")
 	f.Var(&l.MaxQueryLength, maxQueryLengthFlag, "Limit the query time range (end - start time). This limit is enforced in the query-frontend (on the received query), in the querier (on the query possibly split by the query-frontend) and ruler. 0 to disable.")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split (by time) or partial (by shard) queries that will be scheduled in parallel by the query-frontend for a single input query. This limit is introduced to have a fairer query scheduling and avoid a single query over a large time range saturating all available queriers.")
+	f.Var(&l.MaxSeriesQueryLength, MaxSeriesQueryLengthFlag, "Limit the time range (end - start time) of the series queried from the long-term storage. The time range not queried from the long-term storage because of -querier.query-store-after is not counted. This limit is enforced in the querier and ruler. If the time range exceeds the limit, it's manipulated to only query the most recent data within the allowed time range, unless -querier.max-series-query-length-reject is enabled. 0 to disable.")
+	f.BoolVar(&l.MaxSeriesQueryLengthReject, "querier.max-series-query-length-reject", false, "If enabled, the queries whose time range exceeds -"+MaxSeriesQueryLengthFlag+" are rejected, instead of being manipulated to only query the data within the allowed time range.")
 	f.Var(&l.MaxLabelsQueryLength, "store.max-labels-query-length", "Limit the time range (end - start time) of series, label names and values queries. This limit is enforced in the querier. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.LabelNamesAndValuesResultsMaxSizeBytes, "querier.label-names-and-values-results-max-size-bytes", 400*1024*1024, "Maximum size in bytes of distinct label names and values. When querier receives response from ingester, it merges the response with responses from other ingesters. This maximum size limit is applied to the merged(distinct) results. If the limit is reached, an error is returned.")
 	f.BoolVar(&l.CardinalityAnalysisEnabled, "querier.cardinality-analysis-enabled", false, "Enables endpoints used for cardinality analysis.")
@@ -211,9 +240,15 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.SplitInstantQueriesByInterval, "query-frontend.split-instant-queries-by-interval", "Split instant queries by an interval and execute in parallel. 0 to disable it.")
 
 	f.Var(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed.")
+	f.Var(&l.RulerEvaluationInterval, "ruler.tenant-evaluation-interval", "How frequently to evaluate the tenant's rule groups which don't specify their own evaluation interval. 0 to use -ruler.evaluation-interval.")
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The tenant's shard size when sharding is used by ruler. Value of 0 disables shuffle sharding for the tenant, and tenant rules will be sharded across all ruler replicas.")
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 20, "Maximum number of rules per rule group per-tenant. 0 to disable.")
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 70, "Maximum number of rule groups per-tenant. 0 to disable.")
+	f.Var(&l.RulerAllowedSourceTenants, "ruler.allowed-source-tenants", "Comma-separated list of the tenants which the tenant's federated rule groups are allowed to query, in addition to the tenant itself. Federated rule groups querying other tenants are rejected by the ruler configuration API and skipped during evaluations. If empty, any source tenant is allowed.")
+	f.Float64Var(&l.RulerMaxNotificationsPerSecond, "ruler.max-notifications-per-second", 0, "Per-tenant rate limit, in alerts per second, of the notifications sent by the ruler to the Alertmanager. Firing alerts exceeding the limit are dropped, and sent again after the resend delay if still firing, while resolved alerts are never dropped. 0 to disable.")
+	f.IntVar(&l.RulerMaxConcurrentEvaluations, "ruler.max-concurrent-evaluations", 0, "Maximum number of the tenant's rule evaluations running concurrently in each ruler. Rules in the same rule group are evaluated sequentially, so this bounds the number of the tenant's rule groups evaluating concurrently too. 0 to disable.")
+	f.Var(&l.RulerEvaluationTimeout, "ruler.evaluation-timeout", "Max time the query of each of the tenant's rules can run for. The queries exceeding it are canceled, and the rule evaluation fails, without affecting the other rules of the rule group. Rule groups can override it with their own evaluation timeout. 0 to disable.")
+	f.Var(&l.RulerOutOfOrderTolerance, "ruler.out-of-order-tolerance", "When greater than 0, the samples written by the tenant's rule evaluations running late, whose timestamp is older than the tolerance (in addition to the evaluation delay), are written with the timestamp clamped to the oldest one within the tolerance, so that they're not rejected by ingesters as out-of-order. The tolerance should be lower than the tenant's -ingester.out-of-order-time-window. 0 to disable.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. 0 to disable.")
 	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards to use when splitting blocks. 0 to disable splitting.")
@@ -436,6 +471,36 @@ func (o *Overrides) MaxChunksPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxChunksPerQuery
 }
 
+// BlocksStoreMaxRefetches returns the maximum number of attempts to fetch the blocks required by a query
+// from store-gateways, including the initial one.
+func (o *Overrides) BlocksStoreMaxRefetches(userID string) int {
+	return o.getOverridesForUser(userID).BlocksStoreMaxRefetches
+}
+
+// QueryPartialDataOnConsistencyFailure returns whether a query should return partial results, instead of failing,
+// when some blocks can't be fetched from store-gateways.
+func (o *Overrides) QueryPartialDataOnConsistencyFailure(userID string) bool {
+	return o.getOverridesForUser(userID).QueryPartialDataOnConsistencyFailure
+}
+
+// StoreGatewayPartialResponse returns whether store-gateways should return partial results, instead of failing,
+// when some blocks can't be queried.
+func (o *Overrides) StoreGatewayPartialResponse(userID string) bool {
+	return o.getOverridesForUser(userID).StoreGatewayPartialResponse
+}
+
+// MaxEstimatedChunksPerQueryMultiplier returns the multiplier of the max chunks per query limit above which
+// a query is rejected, based on the number of chunks estimated from the blocks metadata. 0 means disabled.
+func (o *Overrides) MaxEstimatedChunksPerQueryMultiplier(userID string) float64 {
+	return o.getOverridesForUser(userID).MaxEstimatedChunksPerQueryMultiplier
+}
+
+// StoreGatewayChunksDeduplication returns whether the querier should drop the duplicated chunks when
+// merging the same series fetched from multiple store-gateways.
+func (o *Overrides) StoreGatewayChunksDeduplication(userID string) bool {
+	return o.getOverridesForUser(userID).StoreGatewayChunksDeduplication
+}
+
 // MaxFetchedSeriesPerQuery returns the maximum number of series allowed per query when fetching
 // chunks from ingesters and blocks storage.
 func (o *Overrides) MaxFetchedSeriesPerQuery(userID string) int {
@@ -448,6 +513,18 @@ func (o *Overrides) MaxFetchedChunkBytesPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxFetchedChunkBytesPerQuery
 }
 
+// MaxBlocksPerQuery returns the maximum number of blocks allowed to be queried from the long-term storage
+// in a single query. 0 means disabled.
+func (o *Overrides) MaxBlocksPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxBlocksPerQuery
+}
+
+// MaxConcurrentQueriesPerTenant returns the maximum number of queries of the tenant concurrently fetching
+// data from the store-gateways in each querier. 0 means disabled.
+func (o *Overrides) MaxConcurrentQueriesPerTenant(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentQueriesPerTenant
+}
+
 // MaxQueryLookback returns the max lookback period of queries.
 func (o *Overrides) MaxQueryLookback(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLookback)
@@ -458,6 +535,17 @@ func (o *Overrides) MaxQueryLength(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLength)
 }
 
+// MaxSeriesQueryLength returns the limit of the length (in time) of the series queried from the long-term storage.
+func (o *Overrides) MaxSeriesQueryLength(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxSeriesQueryLength)
+}
+
+// MaxSeriesQueryLengthReject returns whether the queries exceeding the max series query length are rejected,
+// instead of being clamped.
+func (o *Overrides) MaxSeriesQueryLengthReject(userID string) bool {
+	return o.getOverridesForUser(userID).MaxSeriesQueryLengthReject
+}
+
 // MaxLabelsQueryLength returns the limit of the length (in time) of a label names or values request.
 func (o *Overrides) MaxLabelsQueryLength(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxLabelsQueryLength)
@@ -581,6 +669,12 @@ func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	return o.getOverridesForUser(userID).MetricRelabelConfigs
 }
 
+// RulerEvaluationInterval returns the evaluation interval of the rule groups which don't specify their own interval
+// for a given user. 0 means the default evaluation interval is used.
+func (o *Overrides) RulerEvaluationInterval(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerEvaluationInterval)
+}
+
 // RulerTenantShardSize returns shard size (number of rulers) used by this tenant when using shuffle-sharding strategy.
 func (o *Overrides) RulerTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).RulerTenantShardSize
@@ -596,6 +690,33 @@ func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerAllowedSourceTenants returns the source tenants the federated rule groups of a given user are allowed to query.
+// An empty list means any source tenant is allowed.
+func (o *Overrides) RulerAllowedSourceTenants(userID string) []string {
+	return o.getOverridesForUser(userID).RulerAllowedSourceTenants
+}
+
+// RulerMaxNotificationsPerSecond returns the rate limit of the notifications sent by the ruler for a given user.
+func (o *Overrides) RulerMaxNotificationsPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).RulerMaxNotificationsPerSecond
+}
+
+// RulerMaxConcurrentEvaluations returns the maximum number of rule evaluations running concurrently for a given user.
+func (o *Overrides) RulerMaxConcurrentEvaluations(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxConcurrentEvaluations
+}
+
+// RulerOutOfOrderTolerance returns how old, compared to the expected evaluation timestamp, the samples written
+// by rule evaluations can be before their timestamp is clamped, for a given user.
+func (o *Overrides) RulerOutOfOrderTolerance(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerOutOfOrderTolerance)
+}
+
+// RulerEvaluationTimeout returns the max time the query of each rule can run for, for a given user.
+func (o *Overrides) RulerEvaluationTimeout(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerEvaluationTimeout)
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
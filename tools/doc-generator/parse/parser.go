@@ -285,6 +285,7 @@ func config(block *ConfigBlock, cfg interface{}, flags map[uintptr]*flag.Flag, r
 				Required:      isFieldRequired(field),
 				FieldDesc:     getFieldDescription(field, ""),
 				FieldType:     fieldType,
+				FieldDefault:  getFieldDefault(field, ""),
 				FieldExample:  getFieldExample(fieldName, field.Type),
 				FieldCategory: getFieldCategory(field, ""),
 				Element:       element,
@@ -533,6 +534,17 @@ func getCustomFieldEntry(field reflect.StructField, fieldValue reflect.Value, fl
 		if err != nil {
 			return nil, err
 		}
+		if fieldFlag == nil {
+			// The field is only configurable via YAML (e.g. in a list of structs).
+			return &ConfigEntry{
+				Kind:          KindField,
+				Name:          getFieldName(field),
+				Required:      isFieldRequired(field),
+				FieldDesc:     getFieldDescription(field, ""),
+				FieldType:     "string",
+				FieldCategory: getFieldCategory(field, ""),
+			}, nil
+		}
 
 		return &ConfigEntry{
 			Kind:          KindField,
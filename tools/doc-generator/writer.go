@@ -70,6 +70,9 @@ func (w *specWriter) writeConfigEntry(e *parse.ConfigEntry, indent int) {
 
 		// Specification
 		fieldDefault := e.FieldDefault
+		if e.Kind == parse.KindSlice && fieldDefault == "" {
+			fieldDefault = "[]"
+		}
 		if e.FieldType == "string" {
 			fieldDefault = strconv.Quote(fieldDefault)
 		} else if e.FieldType == "duration" {
@@ -81,6 +84,12 @@ func (w *specWriter) writeConfigEntry(e *parse.ConfigEntry, indent int) {
 		} else {
 			w.out.WriteString(pad(indent) + "[" + e.Name + ": <" + e.FieldType + "> | default = " + fieldDefault + "]\n")
 		}
+
+		// Entries of each element of a list of structs
+		if e.Kind == parse.KindSlice && e.Element != nil && len(e.Element.Entries) > 0 {
+			w.out.WriteString("\n")
+			w.writeConfigBlock(e.Element, indent+tabWidth)
+		}
 	}
 }
 